@@ -0,0 +1,349 @@
+// Package cdp serves a small subset of the Chrome DevTools Protocol, so that
+// scripts written for headless Chrome can drive penny for simple pages. A
+// client finds the page's WebSocket at /json/list or /json/version, as it
+// would Chrome's, and then sends commands to it: Page.navigate,
+// Page.captureScreenshot, DOM.getDocument and the few others a session
+// needs to start. Each connection has a page of its own.
+package cdp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"time"
+
+	"github.com/myuon/penny/encode"
+	"github.com/myuon/penny/engine"
+	"github.com/myuon/penny/loader"
+	"github.com/myuon/penny/service"
+)
+
+// protocolVersion is the version of CDP the subset is of
+const protocolVersion = "1.3"
+
+// targetID is the ID of the single page target a server lists
+const targetID = "penny"
+
+// JSON-RPC error codes CDP answers with
+const (
+	codeInvalidParams  = -32602
+	codeMethodNotFound = -32601
+	codeServerError    = -32000
+)
+
+// Server serves CDP, loading pages with its Service
+type Server struct {
+	Service *service.Service
+	// Product is reported as the browser's name and version
+	Product string
+	// AllowOrigins are the origins of the web pages that may open the
+	// page's WebSocket, or "*" for any. Browsers send the Origin of the
+	// page opening a WebSocket, and a handshake with one that isn't listed
+	// is refused, so that a page the user visits can't drive penny. Clients
+	// outside a browser send none.
+	AllowOrigins []string
+}
+
+// New returns a server loading pages with s
+func New(s *service.Service) *Server {
+	return &Server{Service: s, Product: "penny"}
+}
+
+// Handler serves the discovery endpoints and the page's WebSocket
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/json/version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"Browser":              s.Product,
+			"Protocol-Version":     protocolVersion,
+			"User-Agent":           loader.UserAgent,
+			"webSocketDebuggerUrl": s.debuggerURL(r),
+		})
+	})
+	list := func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []map[string]string{{
+			"id":                   targetID,
+			"type":                 "page",
+			"title":                "penny",
+			"url":                  "about:blank",
+			"webSocketDebuggerUrl": s.debuggerURL(r),
+		}})
+	}
+	mux.HandleFunc("/json", list)
+	mux.HandleFunc("/json/list", list)
+	mux.HandleFunc("/devtools/page/"+targetID, func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !service.AllowsOrigin(s.AllowOrigins, origin) {
+			http.Error(w, "origin "+origin+" is not allowed", http.StatusForbidden)
+			return
+		}
+		conn := upgrade(w, r)
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+		newSession(s, conn.WriteMessage).serve(conn)
+	})
+	return mux
+}
+
+func (s *Server) debuggerURL(r *http.Request) string {
+	return "ws://" + r.Host + "/devtools/page/" + targetID
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+type request struct {
+	ID     int64           `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type response struct {
+	ID     int64     `json:"id"`
+	Result any       `json:"result,omitempty"`
+	Error  *cdpError `json:"error,omitempty"`
+}
+
+type event struct {
+	Method string `json:"method"`
+	Params any    `json:"params"`
+}
+
+type cdpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *cdpError) Error() string { return e.Message }
+
+// session is a client's connection to the page. It renders the page it last
+// navigated to, about:blank at first, in its viewport.
+type session struct {
+	server        *Server
+	send          func([]byte) error
+	url           string
+	width, height int
+	page          *engine.Page
+	result        *engine.RenderResult
+	pageEvents    bool // set by Page.enable
+	// frameID names the page's only frame, and loaderID its navigations
+	frameID  string
+	loaderID int
+}
+
+func newSession(s *Server, send func([]byte) error) *session {
+	return &session{server: s, send: send, url: "about:blank", width: 800, height: 600, frameID: targetID}
+}
+
+// serve answers the commands of a connection until it closes
+func (s *session) serve(conn *wsConn) {
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if s.handle(data) != nil {
+			return
+		}
+	}
+}
+
+// handle answers a command
+func (s *session) handle(data []byte) error {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return s.reply(response{Error: &cdpError{Code: codeInvalidParams, Message: err.Error()}})
+	}
+	result, events, err := s.call(req.Method, req.Params)
+	resp := response{ID: req.ID, Result: result}
+	var cdpErr *cdpError
+	switch {
+	case errors.As(err, &cdpErr):
+		resp.Result, resp.Error = nil, cdpErr
+	case err != nil:
+		resp.Result, resp.Error = nil, &cdpError{Code: codeServerError, Message: err.Error()}
+	case result == nil:
+		resp.Result = struct{}{}
+	}
+	if err := s.reply(resp); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := s.reply(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *session) reply(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.send(data)
+}
+
+// call runs a method, returning its result and the events it fires, which
+// are sent after the result
+func (s *session) call(method string, params json.RawMessage) (any, []event, error) {
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	switch method {
+	case "Page.enable":
+		s.pageEvents = true
+		return nil, nil, nil
+	case "Page.disable":
+		s.pageEvents = false
+		return nil, nil, nil
+	case "DOM.enable", "DOM.disable", "Runtime.enable", "Runtime.disable", "Network.enable", "Network.disable":
+		// Nothing is reported for these domains, so there is nothing to
+		// turn on or off
+		return nil, nil, nil
+	case "Browser.getVersion":
+		return map[string]string{
+			"protocolVersion": protocolVersion,
+			"product":         s.server.Product,
+			"userAgent":       loader.UserAgent,
+			"revision":        "",
+			"jsVersion":       "",
+		}, nil, nil
+	case "Page.navigate":
+		var p struct {
+			URL string `json:"url"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, nil, err
+		}
+		return s.navigate(p.URL)
+	case "Emulation.setDeviceMetricsOverride":
+		var p struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, nil, err
+		}
+		if p.Width <= 0 || p.Height <= 0 {
+			return nil, nil, &cdpError{Code: codeInvalidParams, Message: "width and height must be positive"}
+		}
+		if p.Width != s.width || p.Height != s.height {
+			s.width, s.height, s.result = p.Width, p.Height, nil
+		}
+		return nil, nil, nil
+	case "Page.captureScreenshot":
+		var p struct {
+			Format string `json:"format"`
+			Clip   *struct {
+				X, Y, Width, Height float64
+			} `json:"clip"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, nil, err
+		}
+		return s.captureScreenshot(p.Format, p.Clip)
+	case "DOM.getDocument":
+		p := struct {
+			Depth *int `json:"depth"`
+		}{}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, nil, err
+		}
+		depth := 1
+		if p.Depth != nil {
+			depth = *p.Depth
+		}
+		if err := s.load(); err != nil {
+			return nil, nil, err
+		}
+		return map[string]any{"root": documentNode(s.page.DOM, s.url, depth)}, nil, nil
+	}
+	return nil, nil, &cdpError{Code: codeMethodNotFound, Message: fmt.Sprintf("'%s' wasn't found", method)}
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &cdpError{Code: codeInvalidParams, Message: "Invalid parameters: " + err.Error()}
+	}
+	return nil
+}
+
+// navigate loads a page. A page that can't be loaded is reported in the
+// result's errorText, as Chrome does, and leaves the session's page as it
+// was.
+func (s *session) navigate(u string) (any, []event, error) {
+	if u == "" {
+		return nil, nil, &cdpError{Code: codeInvalidParams, Message: "url is required"}
+	}
+	prev := s.url
+	s.url, s.result = u, nil
+	s.loaderID++
+	result := map[string]any{"frameId": s.frameID, "loaderId": fmt.Sprint(s.loaderID)}
+	if err := s.load(); err != nil {
+		s.url, s.result = prev, nil
+		result["errorText"] = err.Error()
+		return result, nil, nil
+	}
+	if !s.pageEvents {
+		return result, nil, nil
+	}
+	timestamp := map[string]float64{"timestamp": float64(time.Now().UnixNano()) / 1e9}
+	return result, []event{
+		{Method: "Page.domContentEventFired", Params: timestamp},
+		{Method: "Page.loadEventFired", Params: timestamp},
+		{Method: "Page.frameStoppedLoading", Params: map[string]string{"frameId": s.frameID}},
+	}, nil
+}
+
+// load renders the session's page in its viewport, unless it has been
+// rendered since either changed
+func (s *session) load() error {
+	if s.result != nil {
+		return nil
+	}
+	p := service.Page{URL: s.url, Width: s.width, Height: s.height}
+	if s.url == "about:blank" {
+		p = service.Page{HTML: "<html><head></head><body></body></html>", Width: s.width, Height: s.height}
+	}
+	page, result, err := s.server.Service.Load(p)
+	if err != nil {
+		return err
+	}
+	s.page, s.result = page, result
+	return nil
+}
+
+// captureScreenshot returns the page's image, or the part of it in clip,
+// base64 encoded
+func (s *session) captureScreenshot(name string, clip *struct{ X, Y, Width, Height float64 }) (any, []event, error) {
+	if name == "" {
+		name = "png"
+	}
+	format, ok := encode.Lookup(name)
+	if !ok {
+		return nil, nil, &cdpError{Code: codeInvalidParams, Message: "unsupported format " + name}
+	}
+	if err := s.load(); err != nil {
+		return nil, nil, err
+	}
+	var img image.Image = s.result.Image
+	if clip != nil {
+		r := image.Rect(int(clip.X), int(clip.Y), int(clip.X+clip.Width), int(clip.Y+clip.Height)).Intersect(img.Bounds())
+		if r.Empty() {
+			return nil, nil, &cdpError{Code: codeInvalidParams, Message: "clip is outside the page"}
+		}
+		img = s.result.Image.SubImage(r)
+	}
+	var buf bytes.Buffer
+	if err := format.Encode(img, &buf); err != nil {
+		return nil, nil, err
+	}
+	return map[string][]byte{"data": buf.Bytes()}, nil, nil
+}
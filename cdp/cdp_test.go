@@ -0,0 +1,155 @@
+package cdp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/myuon/penny/service"
+)
+
+func testServer(t *testing.T) *httptest.Server {
+	files := map[string]string{
+		"http://example.com/": `<html><body style="margin: 0"><div id="box" style="height: 20px; background-color: blue"></div>text</body></html>`,
+	}
+	server := httptest.NewServer(New(service.New(func(u string) ([]byte, error) {
+		if data, ok := files[u]; ok {
+			return []byte(data), nil
+		}
+		return nil, errors.New("not found")
+	})).Handler())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// call sends a command and returns its response, and the events sent
+// before it
+func (c *testClient) call(id int, method string, params any) (map[string]any, []string) {
+	c.t.Helper()
+	data, _ := json.Marshal(map[string]any{"id": id, "method": method, "params": params})
+	c.writeFrame(true, opText, data)
+	var resp map[string]any
+	var events []string
+	for resp == nil {
+		_, payload := c.readFrame()
+		var message map[string]any
+		if err := json.Unmarshal(payload, &message); err != nil {
+			c.t.Fatal(err)
+		}
+		if m, ok := message["method"].(string); ok {
+			events = append(events, m)
+		} else {
+			resp = message
+		}
+	}
+	if resp["id"] != float64(id) {
+		c.t.Fatalf("expected the response to %d, got %v", id, resp)
+	}
+	return resp, events
+}
+
+func TestDiscovery(t *testing.T) {
+	server := testServer(t)
+	resp, err := http.Get(server.URL + "/json/list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var targets []map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		t.Fatal(err)
+	}
+	want := "ws://" + server.Listener.Addr().String() + "/devtools/page/penny"
+	if len(targets) != 1 || targets[0]["type"] != "page" || targets[0]["webSocketDebuggerUrl"] != want {
+		t.Errorf("expected the page at %s, got %v", want, targets)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	server := testServer(t)
+	handshake := func(origin string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/devtools/page/penny", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// A web page can't open the WebSocket unless its origin is allowed
+	if code := handshake("https://evil.example"); code != http.StatusForbidden {
+		t.Errorf("expected a page's handshake refused, got %d", code)
+	}
+	if code := handshake(""); code != http.StatusSwitchingProtocols {
+		t.Errorf("expected a handshake without an origin accepted, got %d", code)
+	}
+	server.Config.Handler = (&Server{Service: service.New(nil), AllowOrigins: []string{"http://localhost:3000"}}).Handler()
+	if code := handshake("http://localhost:3000"); code != http.StatusSwitchingProtocols {
+		t.Errorf("expected an allowed origin accepted, got %d", code)
+	}
+	if code := handshake("http://localhost:3001"); code != http.StatusForbidden {
+		t.Errorf("expected another origin refused, got %d", code)
+	}
+}
+
+func TestSession(t *testing.T) {
+	c := dial(t, testServer(t), "/devtools/page/penny")
+
+	if resp, _ := c.call(1, "Page.enable", nil); resp["result"] == nil {
+		t.Errorf("expected an empty result, got %v", resp)
+	}
+	resp, _ := c.call(2, "Page.navigate", map[string]any{"url": "http://example.com/"})
+	if result, _ := resp["result"].(map[string]any); result["frameId"] != "penny" || result["errorText"] != nil {
+		t.Errorf("expected the page navigated to, got %v", resp)
+	}
+	// The load events follow the response, as they do in Chrome
+	if _, events := c.call(3, "Page.enable", nil); len(events) != 3 || events[1] != "Page.loadEventFired" {
+		t.Errorf("expected the load events, got %v", events)
+	}
+
+	resp, _ = c.call(4, "Page.navigate", map[string]any{"url": "http://example.com/missing"})
+	if result, _ := resp["result"].(map[string]any); result["errorText"] == nil {
+		t.Errorf("expected an error text for a missing page, got %v", resp)
+	}
+
+	c.call(5, "Emulation.setDeviceMetricsOverride", map[string]any{"width": 50, "height": 40, "deviceScaleFactor": 1, "mobile": false})
+	resp, _ = c.call(6, "Page.captureScreenshot", map[string]any{"format": "png"})
+	var result struct{ Data []byte }
+	raw, _ := json.Marshal(resp["result"])
+	json.Unmarshal(raw, &result)
+	img, err := png.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("expected a PNG, got %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 50 || b.Dy() != 40 {
+		t.Errorf("expected the viewport's size, got %v", b)
+	}
+	if r, g, b, _ := img.At(5, 5).RGBA(); r != 0 || g != 0 || b != 0xffff {
+		t.Errorf("expected the page kept after a failed navigation, got %v", img.At(5, 5))
+	}
+
+	resp, _ = c.call(7, "Page.captureScreenshot", map[string]any{"clip": map[string]any{"x": 10, "y": 10, "width": 20, "height": 5, "scale": 1}})
+	raw, _ = json.Marshal(resp["result"])
+	json.Unmarshal(raw, &result)
+	if img, err := png.Decode(bytes.NewReader(result.Data)); err != nil || img.Bounds().Dx() != 20 || img.Bounds().Dy() != 5 {
+		t.Errorf("expected the clip, got %v", err)
+	}
+
+	resp, _ = c.call(8, "Runtime.evaluate", map[string]any{"expression": "1"})
+	if e, _ := resp["error"].(map[string]any); e["code"] != float64(codeMethodNotFound) {
+		t.Errorf("expected an unknown method, got %v", resp)
+	}
+}
@@ -0,0 +1,91 @@
+package cdp
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/myuon/penny/dom"
+)
+
+// The nodeTypes of DOM nodes
+const (
+	nodeTypeElement  = 1
+	nodeTypeText     = 3
+	nodeTypeDocument = 9
+)
+
+// documentNodeID is the nodeId of the document, which penny's DOM has no
+// node for. Its nodes are numbered after it.
+const documentNodeID = 1
+
+// node is a DOM.Node
+type node struct {
+	NodeID         int      `json:"nodeId"`
+	BackendNodeID  int      `json:"backendNodeId"`
+	NodeType       int      `json:"nodeType"`
+	NodeName       string   `json:"nodeName"`
+	LocalName      string   `json:"localName"`
+	NodeValue      string   `json:"nodeValue"`
+	ChildNodeCount int      `json:"childNodeCount"`
+	Children       []node   `json:"children,omitempty"`
+	Attributes     []string `json:"attributes,omitempty"`
+	DocumentURL    string   `json:"documentURL,omitempty"`
+	BaseURL        string   `json:"baseURL,omitempty"`
+}
+
+// documentNode returns the document of d as DOM.getDocument does, with its
+// descendants depth levels deep, or all of them if depth is -1
+func documentNode(d *dom.DOM, url string, depth int) node {
+	doc := node{
+		NodeID:         documentNodeID,
+		BackendNodeID:  documentNodeID,
+		NodeType:       nodeTypeDocument,
+		NodeName:       "#document",
+		ChildNodeCount: 1,
+		DocumentURL:    url,
+		BaseURL:        url,
+	}
+	if depth != 0 {
+		doc.Children = []node{domNode(d, d.Root, depth-1)}
+	}
+	return doc
+}
+
+// domNode returns a node of d, with its descendants depth levels deep. The
+// tree is walked with a stack of the nodes still to fill in, as a DOM may
+// be too deep to recurse through.
+func domNode(d *dom.DOM, id dom.NodeID, depth int) node {
+	type pending struct {
+		out   *node
+		id    dom.NodeID
+		depth int
+	}
+	var root node
+	stack := []pending{{&root, id, depth}}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		n := d.GetNode(p.id)
+		nodeID := int(p.id) + documentNodeID + 1
+		*p.out = node{NodeID: nodeID, BackendNodeID: nodeID, ChildNodeCount: len(n.Children)}
+		if n.Type == dom.NodeTypeText {
+			p.out.NodeType, p.out.NodeName, p.out.NodeValue = nodeTypeText, "#text", n.Text
+			continue
+		}
+		p.out.NodeType, p.out.NodeName, p.out.LocalName = nodeTypeElement, strings.ToUpper(n.Tag), n.Tag
+		// Attributes come as a flat list of names and values
+		for _, name := range slices.Sorted(maps.Keys(n.Attr)) {
+			p.out.Attributes = append(p.out.Attributes, name, n.Attr[name])
+		}
+		if p.depth != 0 {
+			// Children is allocated once, so the pointers into it hold
+			p.out.Children = make([]node, len(n.Children))
+			for i, child := range n.Children {
+				stack = append(stack, pending{&p.out.Children[i], child, p.depth - 1})
+			}
+		}
+	}
+	return root
+}
@@ -0,0 +1,70 @@
+package cdp
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestDocumentNode(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p id="a" class="x">hi</p></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := documentNode(d, "http://example.com/", 1)
+	if doc.NodeType != nodeTypeDocument || doc.DocumentURL != "http://example.com/" || len(doc.Children) != 1 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	html := doc.Children[0]
+	if html.NodeName != "HTML" || html.LocalName != "html" || html.ChildNodeCount == 0 || html.Children != nil {
+		t.Errorf("expected the html element counted but not expanded at depth 1, got %+v", html)
+	}
+
+	var find func(n node) *node
+	find = func(n node) *node {
+		if n.LocalName == "p" {
+			return &n
+		}
+		for _, child := range n.Children {
+			if found := find(child); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	p := find(documentNode(d, "", -1))
+	if p == nil {
+		t.Fatal("expected the whole tree at depth -1")
+	}
+	if !slices.Equal(p.Attributes, []string{"class", "x", "id", "a"}) {
+		t.Errorf("expected the attributes by name, got %v", p.Attributes)
+	}
+	if len(p.Children) != 1 || p.Children[0].NodeType != nodeTypeText || p.Children[0].NodeValue != "hi" {
+		t.Errorf("expected the text of p, got %+v", p.Children)
+	}
+	if p.NodeID <= documentNodeID || p.NodeID == html.NodeID {
+		t.Errorf("expected node IDs after the document's, got %d", p.NodeID)
+	}
+}
+
+func TestDocumentNodeDeeplyNested(t *testing.T) {
+	const depth = 100000
+	d, err := dom.ParseString("<html><body>" + strings.Repeat("<div>", depth) + "leaf" + strings.Repeat("</div>", depth) + "</body></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := documentNode(d, "", -1)
+	levels := 0
+	for len(n.Children) > 0 {
+		n = n.Children[0]
+		levels++
+	}
+	// the html, body and divs, and the text
+	if levels != depth+3 || n.NodeValue != "leaf" {
+		t.Errorf("expected the leaf %d levels down, got %q at %d", depth+3, n.NodeValue, levels)
+	}
+}
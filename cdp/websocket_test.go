@@ -0,0 +1,121 @@
+package cdp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testClient is the client side of a WebSocket, which masks its frames
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, server *httptest.Server, path string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	io.WriteString(conn, "GET "+path+" HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("expected the handshake accepted, got %s %v", resp.Status, resp.Header)
+	}
+	return &testClient{t: t, conn: conn, r: r}
+}
+
+func (c *testClient) writeFrame(fin bool, op byte, payload []byte) {
+	c.t.Helper()
+	head := []byte{op}
+	if fin {
+		head[0] |= 0x80
+	}
+	if len(payload) < 126 {
+		head = append(head, 0x80|byte(len(payload)))
+	} else {
+		head = append(head, 0x80|126)
+		head = binary.BigEndian.AppendUint16(head, uint16(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	if _, err := c.conn.Write(append(append(head, mask...), masked...)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) readFrame() (byte, []byte) {
+	c.t.Helper()
+	conn := &wsConn{conn: c.conn, r: c.r}
+	fin, op, payload, err := conn.readFrame()
+	if err != nil || !fin {
+		c.t.Fatalf("expected a whole frame, got %v", err)
+	}
+	return op, payload
+}
+
+func TestWebSocket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn := upgrade(w, r)
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(append([]byte("echo "), message...))
+		}
+	}))
+	defer server.Close()
+
+	c := dial(t, server, "/")
+	c.writeFrame(true, opText, []byte("hello"))
+	if op, payload := c.readFrame(); op != opText || string(payload) != "echo hello" {
+		t.Errorf("expected the message echoed, got %d %q", op, payload)
+	}
+
+	// A ping between the fragments of a message is answered at once
+	long := strings.Repeat("x", 300)
+	c.writeFrame(false, opText, []byte(long[:100]))
+	c.writeFrame(true, opPing, []byte("p"))
+	c.writeFrame(true, opContinuation, []byte(long[100:]))
+	if op, payload := c.readFrame(); op != opPong || string(payload) != "p" {
+		t.Errorf("expected a pong, got %d %q", op, payload)
+	}
+	if op, payload := c.readFrame(); op != opText || string(payload) != "echo "+long {
+		t.Errorf("expected the fragments joined, got %d %d bytes", op, len(payload))
+	}
+
+	c.writeFrame(true, opClose, nil)
+	if op, _ := c.readFrame(); op != opClose {
+		t.Errorf("expected the close answered, got %d", op)
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a plain request refused, got %d", resp.StatusCode)
+	}
+}
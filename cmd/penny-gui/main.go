@@ -1,18 +1,19 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"image"
 	"image/color"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
 	"gioui.org/app"
+	"gioui.org/f32"
 	"gioui.org/font/gofont"
+	"gioui.org/io/event"
+	"gioui.org/io/pointer"
 	"gioui.org/layout"
 	"gioui.org/op"
 	"gioui.org/op/clip"
@@ -23,7 +24,9 @@ import (
 	"gioui.org/widget/material"
 	"github.com/myuon/penny/css"
 	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/engine"
 	pennylayout "github.com/myuon/penny/layout"
+	"github.com/myuon/penny/loader"
 	"github.com/myuon/penny/paint"
 )
 
@@ -45,75 +48,120 @@ const (
 )
 
 type Browser struct {
+	page       *engine.Page
 	document   *dom.DOM
 	stylesheet *css.Stylesheet
+	styles     *pennylayout.StyleResolver
+	media      css.MediaContext
+	// loading receives the stages of the page's loading, and is closed once
+	// it has loaded
+	loading    chan engine.Progress
+	loaded     time.Time         // animations run from here
+	images     *paint.ImageCache // the page's, once they have been decoded
 	layoutTree *pennylayout.LayoutTree
 	paintList  *paint.PaintList
 	canvas     *image.RGBA
+	canvasOp   giopaint.ImageOp
+	pageHeight int
+	scroll     image.Point
+	// restyle is set when the DOM changed, such as the hover state, since
+	// the page was last rendered
+	restyle bool
+	// redraw is set when the selection or caret changed since the page was
+	// last painted
+	redraw bool
+
+	// The text selected by dragging runs from the text node the drag
+	// started on to the one it is over. selectFrom is InvalidNodeID when
+	// nothing is selected.
+	anchor, selectFrom, selectTo dom.NodeID
+	dragging                     bool
+	// focused is the focused element, which shows a caret if it is editable
+	focused dom.NodeID
 
 	// UI state
-	activeTab   DevTab
-	btnDOM      widget.Clickable
-	btnStyle    widget.Clickable
-	btnLayout   widget.Clickable
-	btnPaint    widget.Clickable
-	devScroll   widget.List
+	activeTab DevTab
+	btnDOM    widget.Clickable
+	btnStyle  widget.Clickable
+	btnLayout widget.Clickable
+	btnPaint  widget.Clickable
+	devScroll widget.List
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintln(os.Stderr, "Usage: penny-gui <URL or file>")
+	var mediaFeatures mediaFlag
+	mediaFeatures.MediaContext = css.DefaultMediaContext()
+	mediaFeatures.Width, mediaFeatures.Height = contentWidth, contentHeight
+	flag.Var(&mediaFeatures, "media-feature", "set a media feature for @media rules, e.g. 'prefers-color-scheme=dark' (repeatable)")
+	replayDir := flag.String("replay", "", "load the page from a directory saved with penny --record instead of the web")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: penny-gui [flags] <URL, HTML file or MHTML file>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
 		os.Exit(1)
 	}
 
-	input := os.Args[1]
-
-	var htmlContent string
-	var baseURL *url.URL
-	var baseDir string
+	input := flag.Arg(0)
 
-	if isURL(input) {
-		fmt.Printf("Fetching: %s\n", input)
-		content, err := fetchURL(input)
+	get := loader.Getter(loader.Web)
+	if *replayDir != "" {
+		archive, err := loader.OpenArchive(*replayDir)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to fetch URL: %v\n", err)
+			fmt.Fprintf(os.Stderr, "failed to open archive: %v\n", err)
 			os.Exit(1)
 		}
-		htmlContent = content
-		baseURL, _ = url.Parse(input)
-	} else {
-		data, err := os.ReadFile(input)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "failed to read file: %v\n", err)
-			os.Exit(1)
-		}
-		htmlContent = string(data)
-		baseDir = filepath.Dir(input)
+		get = archive.Get
 	}
 
-	document, err := dom.ParseString(htmlContent)
+	if engine.IsURL(input) {
+		fmt.Printf("Fetching: %s\n", input)
+	}
+	page, err := engine.Open(input, get)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to parse HTML: %v\n", err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	document := page.DOM
 
-	var stylesheet *css.Stylesheet
-	if baseURL != nil {
-		stylesheet = loadStylesheetsFromURL(document, baseURL)
-	} else {
-		stylesheet = loadStylesheetsFromDir(document, baseDir)
-	}
-
+	// The page is shown as it loads, restyled as each of its stylesheets
+	// arrives
+	loading := make(chan engine.Progress)
 	browser := &Browser{
+		page:       page,
 		document:   document,
-		stylesheet: stylesheet,
+		stylesheet: &css.Stylesheet{},
+		media:      mediaFeatures.MediaContext,
+		loading:    loading,
+		loaded:     time.Now(),
+		anchor:     dom.InvalidNodeID,
+		selectFrom: dom.InvalidNodeID,
+		selectTo:   dom.InvalidNodeID,
+		focused:    dom.InvalidNodeID,
 		activeTab:  TabDOM,
 	}
+	browser.styles = pennylayout.NewStyleResolver(document, browser.stylesheet)
+	browser.styles.SetViewport(contentWidth, contentHeight)
 	browser.devScroll.Axis = layout.Vertical
+	document.Observe(func(dom.Mutation) { browser.restyle = true })
 	browser.render()
 
+	w := new(app.Window)
+	go func() {
+		// A stylesheet that fails to load is left out of the rendering
+		_, err := page.Load(func(progress engine.Progress) {
+			loading <- progress
+			w.Invalidate()
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		close(loading)
+	}()
+
 	go func() {
-		w := new(app.Window)
 		w.Option(
 			app.Title("Penny Browser - "+input),
 			app.Size(unit.Dp(windowWidth), unit.Dp(windowHeight)),
@@ -129,16 +177,184 @@ func main() {
 	app.Main()
 }
 
+// mediaFlag is the media context set by -media-feature flags
+type mediaFlag struct {
+	css.MediaContext
+}
+
+func (f *mediaFlag) String() string { return "" }
+
+func (f *mediaFlag) Set(spec string) error {
+	name, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return fmt.Errorf("want name=value")
+	}
+	return f.SetFeature(name, value)
+}
+
+// applyLoaded restyles the page with the stylesheets that have arrived
+// since the last frame, and with its images once they have been decoded
+func (b *Browser) applyLoaded() {
+	for {
+		select {
+		case progress, ok := <-b.loading:
+			if !ok {
+				b.loading = nil
+				return
+			}
+			if progress.Stage == engine.StageImages {
+				b.images = b.page.Images
+			}
+			b.stylesheet = progress.Stylesheet
+			b.styles = pennylayout.NewStyleResolver(b.document, progress.Stylesheet.ForMedia(b.media))
+			b.styles.SetViewport(contentWidth, contentHeight)
+			b.restyle = true
+		default:
+			return
+		}
+	}
+}
+
 func (b *Browser) render() {
-	b.layoutTree = pennylayout.BuildLayoutTree(b.document, b.stylesheet)
+	b.restyle = false
+	b.layoutTree = b.styles.BuildLayoutTree()
+	if b.images != nil {
+		pennylayout.SizeImages(b.layoutTree, b.images.NaturalSize)
+	}
 	pennylayout.ComputeLayout(b.layoutTree, contentWidth, contentHeight)
 
-	b.paintList = paint.NewPaintList()
-	paint.PaintBackground(b.paintList, contentWidth, contentHeight, css.ColorWhite)
-	ops := paint.Paint(b.layoutTree)
-	b.paintList.Ops = append(b.paintList.Ops, ops.Ops...)
+	b.pageHeight = contentHeight
+	if root := b.layoutTree.GetNode(b.layoutTree.Root); root != nil && int(root.Rect.H) > b.pageHeight {
+		b.pageHeight = int(root.Rect.H)
+	}
+	b.repaint()
+}
+
+// repaint paints the laid out page, with the selection and caret over it,
+// and rasterizes it
+func (b *Browser) repaint() {
+	b.redraw = false
+
+	// Reuse the storage of the previous frame's list
+	if b.paintList != nil {
+		b.paintList.Release()
+	}
+	b.paintList = paint.AcquirePaintList()
+	b.paintList.Images = b.images
+	paint.PaintBackground(b.paintList, contentWidth, float32(b.pageHeight), css.ColorWhite)
+	paint.PaintInto(b.paintList, b.layoutTree)
+	if b.selectFrom != dom.InvalidNodeID {
+		paint.PaintSelection(b.paintList, b.layoutTree, b.selectFrom, b.selectTo)
+	}
+	if node := b.document.GetNode(b.focused); node != nil && isEditable(node) {
+		paint.PaintCaret(b.paintList, b.layoutTree, b.focused)
+	}
+
+	if b.canvas == nil {
+		b.canvas = image.NewRGBA(image.Rect(0, 0, contentWidth, contentHeight))
+	}
+	paint.RasterizeRect(b.canvas, b.paintList, b.scroll, b.canvas.Bounds())
+	b.canvasOp = giopaint.NewImageOp(b.canvas)
+}
+
+// scrollTo scrolls the content to y, clamped to the page. Only the strip that
+// comes into view is rasterized.
+func (b *Browser) scrollTo(y int) {
+	y = max(0, min(y, b.pageHeight-contentHeight))
+	to := image.Pt(b.scroll.X, y)
+	if to == b.scroll {
+		return
+	}
+
+	paint.Scroll(b.canvas, b.paintList, b.scroll, to)
+	b.scroll = to
+	// A new op makes gio upload the changed canvas
+	b.canvasOp = giopaint.NewImageOp(b.canvas)
+}
+
+// elementAt returns the element under a pointer position in the content
+// area
+func (b *Browser) elementAt(pos f32.Point) dom.NodeID {
+	return paint.HitTest(b.layoutTree, b.document, pos.X+float32(b.scroll.X), pos.Y+float32(b.scroll.Y))
+}
 
-	b.canvas = paint.Rasterize(b.paintList, contentWidth, contentHeight)
+// textAt returns the text node under a pointer position in the content
+// area
+func (b *Browser) textAt(pos f32.Point) dom.NodeID {
+	return paint.TextAt(b.layoutTree, pos.X+float32(b.scroll.X), pos.Y+float32(b.scroll.Y))
+}
+
+// press updates the element states for a click on an element: it becomes
+// active, focus moves to it or its nearest focusable ancestor, checkboxes
+// and radio buttons are checked, and links are marked visited.
+func (b *Browser) press(nodeID dom.NodeID) {
+	d := b.document
+	d.MoveState(dom.StateActive, nodeID)
+
+	focus := dom.InvalidNodeID
+	for id := nodeID; id != dom.InvalidNodeID; id = d.Nodes[id].Parent {
+		if isFocusable(&d.Nodes[id]) {
+			focus = id
+			break
+		}
+	}
+	d.MoveState(dom.StateFocus, focus)
+	if focus != b.focused {
+		b.focused = focus
+		b.redraw = true
+	}
+	if focus == dom.InvalidNodeID {
+		return
+	}
+
+	node := &d.Nodes[focus]
+	switch {
+	case node.Tag == "input" && node.Attr["type"] == "checkbox":
+		d.SetState(focus, dom.StateChecked, node.State&dom.StateChecked == 0)
+	case node.Tag == "input" && node.Attr["type"] == "radio":
+		// Checking a radio button unchecks the others of its group
+		for i := range d.Nodes {
+			other := &d.Nodes[i]
+			if other.Tag == "input" && other.Attr["type"] == "radio" && other.Attr["name"] == node.Attr["name"] {
+				d.SetState(other.ID, dom.StateChecked, other.ID == focus)
+			}
+		}
+	case node.Tag == "a" || node.Tag == "area":
+		d.SetState(focus, dom.StateVisited, true)
+	}
+}
+
+// isFocusable reports whether clicking an element focuses it
+func isFocusable(node *dom.Node) bool {
+	if _, ok := node.Attr["tabindex"]; ok {
+		return true
+	}
+	switch node.Tag {
+	case "a", "area":
+		_, ok := node.Attr["href"]
+		return ok
+	case "input", "button", "select", "textarea":
+		return true
+	}
+	return false
+}
+
+// isEditable reports whether an element takes typed text, and so shows a
+// caret when focused
+func isEditable(node *dom.Node) bool {
+	if editable, ok := node.Attr["contenteditable"]; ok && editable != "false" {
+		return true
+	}
+	switch node.Tag {
+	case "textarea":
+		return true
+	case "input":
+		switch node.Attr["type"] {
+		case "", "text", "search", "email", "url", "tel", "password", "number":
+			return true
+		}
+	}
+	return false
 }
 
 func (b *Browser) run(w *app.Window) error {
@@ -152,6 +368,17 @@ func (b *Browser) run(w *app.Window) error {
 			return e.Err
 		case app.FrameEvent:
 			gtx := app.NewContext(&ops, e)
+			b.applyLoaded()
+
+			// Play animations by laying the page out again at each frame's
+			// time for as long as any are running
+			if b.styles.Animating() {
+				b.styles.SetTime(gtx.Now.Sub(b.loaded))
+				b.render()
+			}
+			if b.styles.Animating() {
+				gtx.Execute(op.InvalidateCmd{})
+			}
 
 			// Handle button clicks
 			if b.btnDOM.Clicked(gtx) {
@@ -187,9 +414,56 @@ func (b *Browser) layout(gtx layout.Context, th *material.Theme) layout.Dimensio
 }
 
 func (b *Browser) layoutContent(gtx layout.Context) layout.Dimensions {
-	imgOp := giopaint.NewImageOp(b.canvas)
-	imgOp.Add(gtx.Ops)
+	for {
+		ev, ok := gtx.Event(pointer.Filter{
+			Target:  b,
+			Kinds:   pointer.Scroll | pointer.Move | pointer.Press | pointer.Drag | pointer.Release | pointer.Leave,
+			ScrollY: pointer.ScrollRange{Min: -b.scroll.Y, Max: b.pageHeight - contentHeight - b.scroll.Y},
+		})
+		if !ok {
+			break
+		}
+		e, ok := ev.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Kind {
+		case pointer.Scroll:
+			b.scrollTo(b.scroll.Y + int(e.Scroll.Y))
+		case pointer.Move:
+			b.document.MoveState(dom.StateHover, b.elementAt(e.Position))
+		case pointer.Press:
+			b.press(b.elementAt(e.Position))
+			// A press clears the selection; dragging from text selects
+			b.anchor = b.textAt(e.Position)
+			b.dragging = true
+			if b.selectFrom != dom.InvalidNodeID {
+				b.selectFrom, b.selectTo = dom.InvalidNodeID, dom.InvalidNodeID
+				b.redraw = true
+			}
+		case pointer.Drag:
+			// Dragging into the gaps between text keeps the selection as
+			// it was
+			if over := b.textAt(e.Position); b.dragging && b.anchor != dom.InvalidNodeID && over != dom.InvalidNodeID && over != b.selectTo {
+				b.selectFrom, b.selectTo = b.anchor, over
+				b.redraw = true
+			}
+		case pointer.Release:
+			b.dragging = false
+			b.document.MoveState(dom.StateActive, dom.InvalidNodeID)
+		case pointer.Leave:
+			b.document.MoveState(dom.StateHover, dom.InvalidNodeID)
+		}
+	}
+	if b.restyle {
+		b.render()
+	} else if b.redraw {
+		b.repaint()
+	}
+
+	b.canvasOp.Add(gtx.Ops)
 	stack := clip.Rect{Max: image.Pt(contentWidth, contentHeight)}.Push(gtx.Ops)
+	event.Op(gtx.Ops, b)
 	giopaint.PaintOp{}.Add(gtx.Ops)
 	stack.Pop()
 
@@ -270,149 +544,3 @@ func (b *Browser) layoutDevContent(gtx layout.Context, th *material.Theme) layou
 		})
 	})
 }
-
-func isURL(s string) bool {
-	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
-}
-
-func fetchURL(urlStr string) (string, error) {
-	resp, err := http.Get(urlStr)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	return string(body), nil
-}
-
-func loadStylesheetsFromDir(d *dom.DOM, baseDir string) *css.Stylesheet {
-	var allRules []css.Rule
-
-	var walk func(nodeID dom.NodeID)
-	walk = func(nodeID dom.NodeID) {
-		node := d.GetNode(nodeID)
-		if node == nil {
-			return
-		}
-
-		if node.Type == dom.NodeTypeElement && node.Tag == "link" {
-			rel, hasRel := node.Attr["rel"]
-			href, hasHref := node.Attr["href"]
-			if hasRel && rel == "stylesheet" && hasHref {
-				cssPath := filepath.Join(baseDir, href)
-				if data, err := os.ReadFile(cssPath); err == nil {
-					if sheet, err := css.Parse(string(data)); err == nil {
-						allRules = append(allRules, sheet.Rules...)
-						fmt.Printf("Loaded CSS: %s\n", cssPath)
-					}
-				}
-			}
-		}
-
-		if node.Type == dom.NodeTypeElement && node.Tag == "style" {
-			cssText := extractTextContent(d, nodeID)
-			if cssText != "" {
-				if sheet, err := css.Parse(cssText); err == nil {
-					allRules = append(allRules, sheet.Rules...)
-					fmt.Println("Loaded CSS: <style>")
-				}
-			}
-		}
-
-		for _, childID := range node.Children {
-			walk(childID)
-		}
-	}
-
-	walk(d.Root)
-
-	if len(allRules) == 0 {
-		return nil
-	}
-
-	return &css.Stylesheet{Rules: allRules}
-}
-
-func loadStylesheetsFromURL(d *dom.DOM, baseURL *url.URL) *css.Stylesheet {
-	var allRules []css.Rule
-
-	var walk func(nodeID dom.NodeID)
-	walk = func(nodeID dom.NodeID) {
-		node := d.GetNode(nodeID)
-		if node == nil {
-			return
-		}
-
-		if node.Type == dom.NodeTypeElement && node.Tag == "link" {
-			rel, hasRel := node.Attr["rel"]
-			href, hasHref := node.Attr["href"]
-			if hasRel && rel == "stylesheet" && hasHref {
-				cssURL := resolveURL(baseURL, href)
-				if content, err := fetchURL(cssURL); err == nil {
-					if sheet, err := css.Parse(content); err == nil {
-						allRules = append(allRules, sheet.Rules...)
-						fmt.Printf("Loaded CSS: %s\n", cssURL)
-					}
-				}
-			}
-		}
-
-		if node.Type == dom.NodeTypeElement && node.Tag == "style" {
-			cssText := extractTextContent(d, nodeID)
-			if cssText != "" {
-				if sheet, err := css.Parse(cssText); err == nil {
-					allRules = append(allRules, sheet.Rules...)
-					fmt.Println("Loaded CSS: <style>")
-				}
-			}
-		}
-
-		for _, childID := range node.Children {
-			walk(childID)
-		}
-	}
-
-	walk(d.Root)
-
-	if len(allRules) == 0 {
-		return nil
-	}
-
-	return &css.Stylesheet{Rules: allRules}
-}
-
-func resolveURL(base *url.URL, ref string) string {
-	refURL, err := url.Parse(ref)
-	if err != nil {
-		return ref
-	}
-	return base.ResolveReference(refURL).String()
-}
-
-func extractTextContent(d *dom.DOM, nodeID dom.NodeID) string {
-	var text string
-	var walk func(id dom.NodeID)
-	walk = func(id dom.NodeID) {
-		node := d.GetNode(id)
-		if node == nil {
-			return
-		}
-		if node.Type == dom.NodeTypeText {
-			text += node.Text
-		}
-		for _, childID := range node.Children {
-			walk(childID)
-		}
-	}
-	walk(nodeID)
-	return text
-}
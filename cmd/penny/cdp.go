@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/myuon/penny/cdp"
+	"github.com/myuon/penny/loader"
+	"github.com/myuon/penny/service"
+	"github.com/spf13/cobra"
+)
+
+func newCDPCmd() *cobra.Command {
+	var addr string
+	var allowFiles bool
+	var allowOrigins []string
+
+	cmd := &cobra.Command{
+		Use:   "cdp",
+		Short: "Serve a subset of the Chrome DevTools Protocol",
+		Long: `cdp serves a small subset of the Chrome DevTools Protocol on --addr, so that
+scripts written for headless Chrome can drive penny for simple pages. Clients
+find the page's WebSocket at /json/version or /json/list. Page.navigate,
+Page.captureScreenshot, DOM.getDocument and
+Emulation.setDeviceMetricsOverride are supported; JavaScript is not. Pages
+are loaded by http or https URL; local files only with --allow-files.
+
+Browsers send the origin of a web page opening a WebSocket, and the
+handshake is refused unless that origin is listed in --remote-allow-origins,
+so that pages the user visits can't drive penny. Clients outside a browser
+send no origin and are always accepted.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s := service.New(loader.Web)
+			s.AllowFiles = allowFiles
+			server := cdp.New(s)
+			server.Product = "penny/" + version
+			server.AllowOrigins = allowOrigins
+			fmt.Fprintf(os.Stderr, "serving CDP on ws://%s/devtools/page/penny\n", addr)
+			return newHTTPServer(addr, server.Handler()).ListenAndServe()
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "localhost:9222", "address to listen on")
+	cmd.Flags().BoolVar(&allowFiles, "allow-files", false, "let pages be loaded from local files by their path")
+	cmd.Flags().StringSliceVar(&allowOrigins, "remote-allow-origins", nil, "origins of web pages allowed to connect, or * for any")
+	return cmd
+}
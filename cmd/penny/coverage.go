@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/test/reftest"
+	"github.com/spf13/cobra"
+)
+
+func newCoverageCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "coverage <file or directory>...",
+		Short: "Report which CSS properties of a corpus penny supports",
+		Long: `coverage loads the stylesheets of every HTML file given (directories are
+searched recursively) and reports each property/value pair encountered as
+supported, parsed-but-ignored or unparsed, most frequent first.`,
+		Args: usageArgs(cobra.MinimumNArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch format {
+			case "text", "json":
+			default:
+				return fail(exitUsage, "unknown format: %s", format)
+			}
+
+			coverage := css.NewCoverage()
+			for _, arg := range args {
+				err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+					if info.IsDir() || !(strings.HasSuffix(path, ".html") || strings.HasSuffix(path, ".htm")) {
+						return nil
+					}
+					if err := reftest.CollectCoverage(coverage, path); err != nil {
+						fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			report := coverage.Report()
+			if format == "json" {
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+
+			fmt.Print(report.Dump())
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "output format (text|json)")
+
+	return cmd
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/encode"
+	"github.com/myuon/penny/engine"
+	"github.com/myuon/penny/loader"
+	"github.com/spf13/cobra"
+)
+
+// crawlEntry is a page of a crawl in its index
+type crawlEntry struct {
+	URL   string   `json:"url"`
+	Depth int      `json:"depth"`
+	Title string   `json:"title,omitempty"`
+	Image string   `json:"image,omitempty"`
+	Links []string `json:"links,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+var crawlIndexHTML = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Start}}</title>
+<style>
+body { font-family: sans-serif; }
+figure { display: inline-block; width: 400px; margin: 8px; vertical-align: top; }
+img { width: 400px; border: 1px solid #ccc; }
+figcaption { word-wrap: break-word; }
+</style></head>
+<body>
+<h1>{{.Start}}</h1>
+{{range .Pages}}<figure>
+{{if .Image}}<a href="{{.Image}}"><img src="{{.Image}}" alt=""></a>{{end}}
+<figcaption>{{.Depth}} <a href="{{.URL}}">{{if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</a>{{if .Error}} ({{.Error}}){{end}}</figcaption>
+</figure>
+{{end}}</body>
+</html>
+`))
+
+func newCrawlCmd() *cobra.Command {
+	var outputDir string
+	var depth int
+	var maxPages int
+	var delay time.Duration
+	var formatName string
+
+	cmd := &cobra.Command{
+		Use:   "crawl <URL>",
+		Short: "Render the pages of a site to images",
+		Long: `crawl renders a page and follows its links to the pages of the same origin,
+rendering each of them to an image in the output directory, up to --depth
+links away. index.json lists the pages with their images and links, and
+index.html shows them all, as a visual sitemap.
+
+The site's robots.txt is honoured, and fetches are spaced out by --delay or
+the robots.txt Crawl-delay, whichever is longer.`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, err := url.Parse(args[0])
+			if err != nil || !engine.IsURL(args[0]) {
+				return fail(exitUsage, "not an http or https URL: %s", args[0])
+			}
+			format, err := outputFormat(formatName, "")
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return fail(exitRender, "failed to create output directory: %w", err)
+			}
+
+			var pages []crawlEntry
+			crawler := &loader.Crawler{Get: loader.Web, Agent: loader.UserAgent, Depth: depth, MaxPages: maxPages, Delay: delay}
+			crawler.Crawl(start, func(page loader.Page) []*url.URL {
+				entry := crawlEntry{URL: page.URL.String(), Depth: page.Depth}
+				defer func() { pages = append(pages, entry) }()
+				if page.Err != nil {
+					entry.Error = page.Err.Error()
+					fmt.Fprintf(os.Stderr, "skipped %s: %v\n", entry.URL, page.Err)
+					return nil
+				}
+
+				entry.Image = fmt.Sprintf("%04d%s", len(pages), format.Extension())
+				title, links, err := renderCrawledPage(page, format, filepath.Join(outputDir, entry.Image))
+				if err != nil {
+					entry.Image, entry.Error = "", err.Error()
+					fmt.Fprintf(os.Stderr, "failed %s: %v\n", entry.URL, err)
+					return nil
+				}
+				entry.Title = title
+				for _, link := range links {
+					entry.Links = append(entry.Links, link.String())
+				}
+				fmt.Printf("Rendered %s to %s\n", entry.URL, entry.Image)
+				return links
+			})
+
+			data, err := json.MarshalIndent(pages, "", "  ")
+			if err != nil {
+				return fail(exitRender, "failed to encode index: %w", err)
+			}
+			if err := os.WriteFile(filepath.Join(outputDir, "index.json"), data, 0644); err != nil {
+				return fail(exitRender, "failed to write index: %w", err)
+			}
+			f, err := os.Create(filepath.Join(outputDir, "index.html"))
+			if err != nil {
+				return fail(exitRender, "failed to write index: %w", err)
+			}
+			defer f.Close()
+			if err := crawlIndexHTML.Execute(f, map[string]any{"Start": start.String(), "Pages": pages}); err != nil {
+				return fail(exitRender, "failed to write index: %w", err)
+			}
+			fmt.Printf("Crawled %d pages into %s\n", len(pages), outputDir)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputDir, "output", "o", "crawl", "directory to write the images and the index to")
+	cmd.Flags().IntVar(&depth, "depth", 1, "how many links away from the first page to follow")
+	cmd.Flags().IntVar(&maxPages, "max-pages", 100, "stop after this many pages, or never if 0")
+	cmd.Flags().DurationVar(&delay, "delay", time.Second, "least time between fetches")
+	cmd.Flags().StringVar(&formatName, "format", "png", "image format to write, "+strings.Join(encode.Names(), "|"))
+	return cmd
+}
+
+// renderCrawledPage renders a page to an image file and returns its title
+// and the links on it
+func renderCrawledPage(crawled loader.Page, format encode.Format, outputFile string) (string, []*url.URL, error) {
+	page, err := engine.Parse(crawled.Body, crawled.URL, loader.Web)
+	if err != nil {
+		return "", nil, err
+	}
+	defaults, err := engine.WithDefaults(nil, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	result := engine.RenderPage(page, defaults, css.DefaultMediaContext(), engine.Options{Width: 800, Height: 600})
+	for _, failed := range result.Failed {
+		fmt.Fprintf(os.Stderr, "warning: failed to load %s: %s\n", failed.Ref, failed.Error)
+	}
+	if err := saveImage(result.Image, format, outputFile); err != nil {
+		return "", nil, fmt.Errorf("failed to save %s: %w", strings.ToUpper(format.Name), err)
+	}
+	return result.Title, loader.Links(page.DOM, page.URL), nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/engine"
+	"github.com/myuon/penny/loader"
+	"github.com/myuon/penny/service"
+	"github.com/spf13/cobra"
+)
+
+func newDaemonCmd() *cobra.Command {
+	var socket string
+	var width, height int
+
+	cmd := &cobra.Command{
+		Use:   "daemon <input>",
+		Short: "Keep a page loaded and render it on command",
+		Long: `daemon loads an HTML file, MHTML web archive or URL once and keeps it in
+memory, reading commands a line at a time from stdin, or from each connection
+to --socket. Each command is answered with a line of JSON, with "ok" and, if
+it failed, "error". The document is never parsed again, so tools iterating on
+styles only pay for the stylesheets and the layout.
+
+Commands:
+  reload                 read the page's stylesheets and images again
+  viewport <w> <h>       lay the page out in a viewport of w x h
+  render <file>          write the page as an image, in the format of the
+                         file's extension
+  layout                 answer with the layout tree, as "layout"
+  query <selector> [<prop>...]
+                         answer with the elements a selector matches, as
+                         "elements", as the query command prints them
+  quit                   stop the daemon`,
+		Args: usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if width <= 0 || height <= 0 {
+				return fail(exitUsage, "invalid viewport %dx%d", width, height)
+			}
+			page, err := engine.Open(args[0], loader.Web)
+			if err != nil {
+				return err
+			}
+			d := &daemon{page: page, width: width, height: height}
+			if err := d.load(); err != nil {
+				return err
+			}
+
+			if socket == "" {
+				d.serve(os.Stdin, os.Stdout)
+				return nil
+			}
+			ln, err := net.Listen("unix", socket)
+			if err != nil {
+				return err
+			}
+			defer ln.Close()
+			for !d.stopped() {
+				conn, err := ln.Accept()
+				if err != nil {
+					return err
+				}
+				// Connections are served one at a time, so their commands
+				// don't interleave
+				d.serve(conn, conn)
+				conn.Close()
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&socket, "socket", "", "listen for commands on this Unix socket rather than stdin")
+	cmd.Flags().IntVar(&width, "width", 800, "initial viewport width")
+	cmd.Flags().IntVar(&height, "height", 600, "initial viewport height")
+	return cmd
+}
+
+// daemon is a page kept loaded between commands
+type daemon struct {
+	page *engine.Page
+	// stylesheet is the page's, after the user agent stylesheet
+	stylesheet    *css.Stylesheet
+	width, height int
+	// rendering is the page rendered in the viewport, nil until it is
+	// rendered again after a change
+	rendering *engine.Rendering
+
+	mu   sync.Mutex
+	quit bool
+}
+
+// response answers a command
+type response struct {
+	OK       bool                    `json:"ok"`
+	Error    string                  `json:"error,omitempty"`
+	Failed   []engine.FailedResource `json:"failed,omitempty"`
+	Layout   string                  `json:"layout,omitempty"`
+	Elements []map[string]any        `json:"elements,omitempty"`
+}
+
+// load loads the page's stylesheets and images. Those that fail are left
+// out, and are in the page's Failed.
+func (d *daemon) load() error {
+	sheet, _ := d.page.Load(nil)
+	stylesheet, err := engine.WithDefaults(sheet, nil)
+	if err != nil {
+		return err
+	}
+	d.stylesheet, d.rendering = stylesheet, nil
+	return nil
+}
+
+// render renders the page, unless it is unchanged since last rendered
+func (d *daemon) render() *engine.Rendering {
+	if d.rendering == nil {
+		media := css.DefaultMediaContext()
+		media.Width, media.Height = float32(d.width), float32(d.height)
+		d.rendering = engine.Render(d.page.DOM, d.stylesheet.ForMedia(media), engine.Options{
+			Width:  d.width,
+			Height: d.height,
+			Images: d.page.Images,
+		})
+	}
+	return d.rendering
+}
+
+func (d *daemon) stopped() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.quit
+}
+
+// serve answers the commands read from r on w until r ends or a quit
+// command
+func (d *daemon) serve(r io.Reader, w io.Writer) {
+	enc := json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		d.mu.Lock()
+		resp, err := d.run(line)
+		d.mu.Unlock()
+		if err != nil {
+			resp = response{Error: err.Error()}
+		} else {
+			resp.OK = true
+		}
+		if enc.Encode(resp) != nil || d.stopped() {
+			return
+		}
+	}
+}
+
+// run runs a command
+func (d *daemon) run(line string) (response, error) {
+	args := strings.Fields(line)
+	switch args[0] {
+	case "reload":
+		d.page.Reload()
+		if err := d.load(); err != nil {
+			return response{}, err
+		}
+		return response{Failed: d.page.Failed}, nil
+	case "viewport":
+		if len(args) != 3 {
+			return response{}, errors.New("usage: viewport <w> <h>")
+		}
+		w, errW := strconv.Atoi(args[1])
+		h, errH := strconv.Atoi(args[2])
+		if errW != nil || errH != nil || w <= 0 || h <= 0 {
+			return response{}, fmt.Errorf("invalid viewport %sx%s", args[1], args[2])
+		}
+		if w != d.width || h != d.height {
+			d.width, d.height, d.rendering = w, h, nil
+		}
+		return response{}, nil
+	case "render":
+		if len(args) != 2 {
+			return response{}, errors.New("usage: render <file>")
+		}
+		format, err := outputFormat("", args[1])
+		if err != nil {
+			return response{}, err
+		}
+		return response{}, saveImage(d.render().Image, format, args[1])
+	case "layout":
+		return response{Layout: d.render().Layout.Dump()}, nil
+	case "query":
+		if len(args) < 2 {
+			return response{}, errors.New("usage: query <selector> [<prop>...]")
+		}
+		q, err := service.ParseQuery(args[1], args[2:])
+		if err != nil {
+			return response{}, err
+		}
+		return response{Elements: flattenElements(q.Run(d.page.DOM, d.render().Layout))}, nil
+	case "quit":
+		d.quit = true
+		return response{}, nil
+	}
+	return response{}, fmt.Errorf("unknown command: %s", args[0])
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/engine"
+	"github.com/myuon/penny/loader"
+	"github.com/myuon/penny/test/reftest"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd() *cobra.Command {
+	var outputFile string
+	var formatName string
+	var width, height int
+
+	cmd := &cobra.Command{
+		Use:   "diff <a> <b>",
+		Short: "Render two pages and show how they differ",
+		Long: `diff renders two HTML files, MHTML web archives or URLs and writes an image
+of the two renderings side by side with their diff, in which the differing
+pixels are red, and a heatmap of how much they differ. It prints the
+percentage of pixels that differ.`,
+		Args: usageArgs(cobra.ExactArgs(2)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := outputFormat(formatName, outputFile)
+			if err != nil {
+				return err
+			}
+			if width <= 0 || height <= 0 {
+				return fail(exitUsage, "invalid viewport %dx%d", width, height)
+			}
+			opts := engine.Options{Width: width, Height: height}
+
+			var images [2]*image.RGBA
+			for i, input := range args {
+				_, result, err := renderInput(input, opts)
+				if err != nil {
+					return err
+				}
+				images[i] = result.Image
+			}
+
+			combined, percent := reftest.DiffImages(images[0], images[1])
+			if err := saveImage(combined, format, outputFile); err != nil {
+				return fail(exitRender, "failed to save diff: %w", err)
+			}
+			fmt.Printf("%.2f%% of pixels differ, diff written to %s\n", percent, outputFile)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "diff.png", "output file path")
+	cmd.Flags().StringVar(&formatName, "format", "", "image format to write (default: by the output file's extension, or png)")
+	cmd.Flags().IntVar(&width, "width", 800, "viewport width to render both pages in")
+	cmd.Flags().IntVar(&height, "height", 600, "viewport height to render both pages in")
+	return cmd
+}
+
+// renderInput renders a page as the root command does, with the user agent
+// stylesheet and the page's own, warning of the resources that failed to
+// load
+func renderInput(input string, opts engine.Options) (*engine.Page, *engine.RenderResult, error) {
+	page, err := engine.Open(input, loader.Web)
+	if err != nil {
+		return nil, nil, err
+	}
+	defaults, err := engine.WithDefaults(nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	media := css.DefaultMediaContext()
+	media.Width, media.Height = float32(opts.Width), float32(opts.Height)
+	result := engine.RenderPage(page, defaults, media, opts)
+	for _, failed := range result.Failed {
+		fmt.Fprintf(os.Stderr, "warning: %s: failed to load %s: %s\n", input, failed.Ref, failed.Error)
+	}
+	return page, result, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/myuon/penny/engine"
+	"github.com/spf13/cobra"
+)
+
+// exitCode is what penny exits with, so that scripts can tell failures apart
+type exitCode int
+
+const (
+	exitOK exitCode = iota
+	// exitFailure is any failure without a code of its own
+	exitFailure
+	// exitUsage is for invalid arguments or flags
+	exitUsage
+	// exitFetch is for an input or resource that couldn't be read
+	exitFetch
+	// exitParse is for an input that couldn't be parsed
+	exitParse
+	// exitRender is for a failure to lay out, paint or write the output
+	exitRender
+	// exitTimeout is for running out of --timeout, or a fetch timing out
+	exitTimeout
+)
+
+func (c exitCode) String() string {
+	switch c {
+	case exitOK:
+		return "ok"
+	case exitUsage:
+		return "usage"
+	case exitFetch:
+		return "fetch"
+	case exitParse:
+		return "parse"
+	case exitRender:
+		return "render"
+	case exitTimeout:
+		return "timeout"
+	default:
+		return "failure"
+	}
+}
+
+// cliError is an error that makes penny exit with code
+type cliError struct {
+	code exitCode
+	err  error
+}
+
+func (e *cliError) Error() string { return e.err.Error() }
+func (e *cliError) Unwrap() error { return e.err }
+
+// fail returns an error that makes penny exit with code, with a message
+// formatted as by fmt.Errorf
+func fail(code exitCode, format string, args ...any) error {
+	return &cliError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+// exitCodeOf returns the code to exit with for err. A timeout is reported as
+// one whatever failed on it.
+func exitCodeOf(err error) exitCode {
+	if err == nil {
+		return exitOK
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return exitTimeout
+	}
+	var e *cliError
+	var fetchErr *engine.FetchError
+	var parseErr *engine.ParseError
+	switch {
+	case errors.As(err, &e):
+		return e.code
+	case errors.As(err, &fetchErr):
+		return exitFetch
+	case errors.As(err, &parseErr):
+		return exitParse
+	}
+	return exitFailure
+}
+
+// reportError writes err to w, as a line of text or of JSON, and returns the
+// code to exit with
+func reportError(w io.Writer, err error, asJSON bool) exitCode {
+	code := exitCodeOf(err)
+	if !asJSON {
+		fmt.Fprintln(w, err)
+		if code == exitUsage {
+			fmt.Fprintln(w, "Run 'penny --help' for usage.")
+		}
+		return code
+	}
+	data, _ := json.Marshal(struct {
+		Code    exitCode `json:"code"`
+		Kind    string   `json:"kind"`
+		Message string   `json:"message"`
+	}{code, code.String(), err.Error()})
+	fmt.Fprintln(w, string(data))
+	return code
+}
+
+// exit reports err and exits with its code
+func exit(err error, asJSON bool) {
+	os.Exit(int(reportError(os.Stderr, err, asJSON)))
+}
+
+// usageArgs makes the errors of an argument check usage errors
+func usageArgs(check cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := check(cmd, args); err != nil {
+			return &cliError{code: exitUsage, err: err}
+		}
+		return nil
+	}
+}
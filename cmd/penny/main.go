@@ -1,18 +1,19 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/myuon/penny/css"
 	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/encode"
+	"github.com/myuon/penny/engine"
 	"github.com/myuon/penny/layout"
-	"github.com/myuon/penny/paint"
+	"github.com/myuon/penny/loader"
 	"github.com/spf13/cobra"
 )
 
@@ -20,47 +21,79 @@ var version = "0.1.0"
 
 func main() {
 	var outputFile string
+	var outputFormatName string
 	var dumpDOM bool
 	var dumpStylesheet bool
 	var dumpLayoutTree bool
 	var dumpPaintOps bool
+	var dumpLayoutTrace bool
+	var layoutTraceFile string
+	var metadataFile string
+	var atTime time.Duration
+	var forceStates []string
+	var userCSS []string
+	var mediaType string
+	var mediaFeatures []string
+	var recordDir string
+	var replayDir string
+	var timeout time.Duration
+	var jsonErrors bool
 
 	rootCmd := &cobra.Command{
-		Use:     "penny <input.html or URL>",
-		Short:   "penny - a simple HTML renderer",
-		Long:    `penny is a command line tool that renders HTML files or URLs to PNG images.`,
-		Args:    cobra.ExactArgs(1),
+		Use:   "penny <input.html, input.mhtml or URL>",
+		Short: "penny - a simple HTML renderer",
+		Long: `penny is a command line tool that renders HTML files, MHTML web archives or URLs to PNG, JPEG or WebP images.
+
+Exit codes:
+  0  success
+  1  any other failure
+  2  invalid arguments or flags
+  3  an input or resource couldn't be fetched or read
+  4  an input couldn't be parsed
+  5  the page couldn't be rendered or the output written
+  6  --timeout ran out, or a fetch timed out
+
+With --json-errors, a failure is reported on stderr as a line of JSON:
+{"code": 3, "kind": "fetch", "message": "..."}`,
+		Args:    usageArgs(cobra.ExactArgs(1)),
 		Version: version,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			input := args[0]
+			if timeout > 0 {
+				// Whatever penny is busy with, it gives up at the deadline
+				time.AfterFunc(timeout, func() {
+					exit(fail(exitTimeout, "timed out after %v", timeout), jsonErrors)
+				})
+			}
 
-			var htmlContent string
-			var baseURL *url.URL
-			var baseDir string
+			format, err := outputFormat(outputFormatName, outputFile)
+			if err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("output") {
+				outputFile = "output" + format.Extension()
+			}
 
-			// Check if input is URL
-			if isURL(input) {
-				fmt.Printf("Fetching: %s\n", input)
-				content, err := fetchURL(input)
-				if err != nil {
-					return fmt.Errorf("failed to fetch URL: %w", err)
-				}
-				htmlContent = content
-				baseURL, _ = url.Parse(input)
-			} else {
-				// Read local file
-				data, err := os.ReadFile(input)
-				if err != nil {
-					return fmt.Errorf("failed to read file: %w", err)
-				}
-				htmlContent = string(data)
-				baseDir = filepath.Dir(input)
+			media, err := mediaContext(mediaType, mediaFeatures)
+			if err != nil {
+				return err
 			}
 
-			// Parse HTML
-			document, err := dom.ParseString(htmlContent)
+			get, err := archiveGetter(recordDir, replayDir)
 			if err != nil {
-				return fmt.Errorf("failed to parse HTML: %w", err)
+				return err
+			}
+
+			if engine.IsURL(input) {
+				fmt.Printf("Fetching: %s\n", input)
+			}
+			page, err := engine.Open(input, get)
+			if err != nil {
+				return err
+			}
+			document := page.DOM
+			if document.Truncated {
+				fmt.Fprintf(os.Stderr, "warning: document truncated after %d nodes\n", len(document.Nodes))
 			}
 
 			if dumpDOM {
@@ -69,45 +102,62 @@ func main() {
 				fmt.Println()
 			}
 
-			// Find and load CSS files from <link> tags
-			var stylesheet *css.Stylesheet
-			if baseURL != nil {
-				stylesheet = loadStylesheetsFromURL(document, baseURL)
-			} else {
-				stylesheet = loadStylesheetsFromDir(document, baseDir)
+			for _, spec := range forceStates {
+				if err := forceState(document, spec); err != nil {
+					return err
+				}
+			}
+
+			defaults, err := engine.WithDefaults(nil, userCSS)
+			if err != nil {
+				return err
+			}
+			// A stylesheet or image that fails to load is left out of the
+			// rendering
+			result := engine.RenderPage(page, defaults, media, engine.Options{
+				Width:  800,
+				Height: 600,
+				At:     atTime,
+				Trace:  dumpLayoutTrace || layoutTraceFile != "",
+			})
+			rendering := result.Rendering
+			for _, failed := range result.Failed {
+				fmt.Fprintf(os.Stderr, "warning: failed to load %s: %s\n", failed.Ref, failed.Error)
+			}
+			for _, e := range result.Stylesheet.Errors {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", e)
 			}
 
 			if dumpStylesheet {
 				fmt.Println("=== Stylesheet ===")
-				if stylesheet != nil {
-					fmt.Print(stylesheet.Dump())
-				} else {
-					fmt.Println("(no stylesheet)")
-				}
+				fmt.Print(result.Stylesheet.Dump())
 				fmt.Println()
 			}
 
-			// Build layout tree
-			layoutTree := layout.BuildLayoutTree(document, stylesheet)
-
-			// Compute layout
-			layout.ComputeLayout(layoutTree, 800, 600)
+			if dumpLayoutTrace {
+				fmt.Println("=== Layout Trace ===")
+				fmt.Print(rendering.Trace.Dump())
+				fmt.Println()
+			}
+			if layoutTraceFile != "" {
+				data, err := json.MarshalIndent(rendering.Trace, "", "  ")
+				if err != nil {
+					return fail(exitRender, "failed to encode layout trace: %w", err)
+				}
+				if err := os.WriteFile(layoutTraceFile, data, 0644); err != nil {
+					return fail(exitRender, "failed to write layout trace: %w", err)
+				}
+			}
 
 			if dumpLayoutTree {
 				fmt.Println("=== Layout Tree ===")
-				fmt.Print(layoutTree.Dump())
+				fmt.Print(rendering.Layout.Dump())
 				fmt.Println()
 			}
 
-			// Paint
-			paintList := paint.NewPaintList()
-			paint.PaintBackground(paintList, 800, 600, css.ColorWhite)
-			ops := paint.Paint(layoutTree)
-			paintList.Ops = append(paintList.Ops, ops.Ops...)
-
 			if dumpPaintOps {
 				fmt.Println("=== Paint Ops ===")
-				fmt.Print(paintList.Dump())
+				fmt.Print(rendering.Paint.Dump())
 				fmt.Println()
 			}
 
@@ -115,14 +165,20 @@ func main() {
 			outputDir := filepath.Dir(outputFile)
 			if outputDir != "." {
 				if err := os.MkdirAll(outputDir, 0755); err != nil {
-					return fmt.Errorf("failed to create output directory: %w", err)
+					return fail(exitRender, "failed to create output directory: %w", err)
 				}
 			}
-
-			// Rasterize and save
-			img := paint.Rasterize(paintList, 800, 600)
-			if err := paint.SavePNG(img, outputFile); err != nil {
-				return fmt.Errorf("failed to save PNG: %w", err)
+			if err := saveImage(rendering.Image, format, outputFile); err != nil {
+				return fail(exitRender, "failed to save %s: %w", strings.ToUpper(format.Name), err)
+			}
+			if metadataFile != "" {
+				data, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					return fail(exitRender, "failed to encode metadata: %w", err)
+				}
+				if err := os.WriteFile(metadataFile, data, 0644); err != nil {
+					return fail(exitRender, "failed to write metadata: %w", err)
+				}
 			}
 
 			fmt.Printf("Rendered to %s\n", outputFile)
@@ -131,161 +187,97 @@ func main() {
 	}
 
 	rootCmd.Flags().StringVarP(&outputFile, "output", "o", "output.png", "output file path")
+	rootCmd.Flags().StringVar(&outputFormatName, "format", "", "image format to write, "+strings.Join(encode.Names(), "|")+" (default: by the output file's extension, or png)")
 	rootCmd.Flags().BoolVar(&dumpDOM, "dump-dom", false, "dump parsed DOM tree")
 	rootCmd.Flags().BoolVar(&dumpStylesheet, "dump-stylesheet", false, "dump parsed stylesheet")
 	rootCmd.Flags().BoolVar(&dumpLayoutTree, "dump-layout-tree", false, "dump layout tree")
 	rootCmd.Flags().BoolVar(&dumpPaintOps, "dump-paint-ops", false, "dump paint operations")
+	rootCmd.Flags().BoolVar(&dumpLayoutTrace, "dump-layout-trace", false, "dump the inputs and outputs of the sizing of each box")
+	rootCmd.Flags().StringVar(&layoutTraceFile, "layout-trace", "", "write the inputs and outputs of the sizing of each box to this file as JSON")
+	rootCmd.Flags().StringVar(&metadataFile, "metadata", "", "write the title, document size, timings and failed resources of the page to this file as JSON")
+	rootCmd.Flags().StringArrayVar(&forceStates, "force-state", nil, "force an element state for matching elements, e.g. 'a:hover' or '#menu:focus' (repeatable)")
+	rootCmd.Flags().StringArrayVar(&userCSS, "user-css", nil, "apply a user stylesheet to the page, between the defaults and the page's own CSS (repeatable)")
+	rootCmd.Flags().StringVar(&mediaType, "media-type", "screen", "media type to evaluate @media rules for, e.g. print")
+	rootCmd.Flags().StringArrayVar(&mediaFeatures, "media-feature", nil, "set a media feature for @media rules, e.g. 'prefers-color-scheme=dark' or 'width=400' (repeatable)")
+	rootCmd.Flags().DurationVar(&atTime, "at-time", 0, "time since load to capture CSS animations at, e.g. 1.5s")
+	rootCmd.Flags().StringVar(&recordDir, "record", "", "save everything fetched from the web to this directory, to replay later")
+	rootCmd.Flags().StringVar(&replayDir, "replay", "", "load the page from a directory saved with --record instead of the web")
+	rootCmd.MarkFlagsMutuallyExclusive("record", "replay")
+	rootCmd.Flags().DurationVar(&timeout, "timeout", 0, "give up rendering after this long, e.g. 30s")
+	rootCmd.PersistentFlags().BoolVar(&jsonErrors, "json-errors", false, "report a failure on stderr as a line of JSON with its code, kind and message")
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &cliError{code: exitUsage, err: err}
+	})
+	// Errors are reported, and the exit code chosen, below
+	rootCmd.SilenceErrors = true
+	rootCmd.SilenceUsage = true
+
+	addProfileFlags(rootCmd)
+
+	rootCmd.AddCommand(newReftestCmd())
+	rootCmd.AddCommand(newCoverageCmd())
+	rootCmd.AddCommand(newCrawlCmd())
+	rootCmd.AddCommand(newDiffCmd())
+	rootCmd.AddCommand(newQueryCmd())
+	rootCmd.AddCommand(newDaemonCmd())
+	rootCmd.AddCommand(newRPCCmd())
+	rootCmd.AddCommand(newCDPCmd())
 
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		exit(err, jsonErrors)
 	}
 }
 
-func isURL(s string) bool {
-	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
-}
-
-func fetchURL(urlStr string) (string, error) {
-	resp, err := http.Get(urlStr)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+// forceState applies a --force-state flag: a simple selector followed by the
+// pseudo-classes to force on the elements it matches
+func forceState(d *dom.DOM, spec string) error {
+	sheet, err := css.Parse(spec + " {}")
+	if err != nil || len(sheet.Rules) != 1 || len(sheet.Rules[0].Selectors) != 1 {
+		return fail(exitUsage, "invalid --force-state %q", spec)
 	}
-
-	body, err := io.ReadAll(resp.Body)
+	n, err := layout.ForceState(d, sheet.Rules[0].Selectors[0])
 	if err != nil {
-		return "", err
-	}
-
-	return string(body), nil
-}
-
-func loadStylesheetsFromDir(d *dom.DOM, baseDir string) *css.Stylesheet {
-	var allRules []css.Rule
-
-	var walk func(nodeID dom.NodeID)
-	walk = func(nodeID dom.NodeID) {
-		node := d.GetNode(nodeID)
-		if node == nil {
-			return
-		}
-
-		if node.Type == dom.NodeTypeElement && node.Tag == "link" {
-			rel, hasRel := node.Attr["rel"]
-			href, hasHref := node.Attr["href"]
-			if hasRel && rel == "stylesheet" && hasHref {
-				cssPath := filepath.Join(baseDir, href)
-				if data, err := os.ReadFile(cssPath); err == nil {
-					if sheet, err := css.Parse(string(data)); err == nil {
-						allRules = append(allRules, sheet.Rules...)
-						fmt.Printf("Loaded CSS: %s\n", cssPath)
-					}
-				}
-			}
-		}
-
-		// Handle <style> tags
-		if node.Type == dom.NodeTypeElement && node.Tag == "style" {
-			cssText := extractTextContent(d, nodeID)
-			if cssText != "" {
-				if sheet, err := css.Parse(cssText); err == nil {
-					allRules = append(allRules, sheet.Rules...)
-					fmt.Println("Loaded CSS: <style>")
-				}
-			}
-		}
-
-		for _, childID := range node.Children {
-			walk(childID)
-		}
+		return fail(exitUsage, "invalid --force-state %q: %w", spec, err)
 	}
-
-	walk(d.Root)
-
-	if len(allRules) == 0 {
-		return nil
+	if n == 0 {
+		fmt.Fprintf(os.Stderr, "warning: --force-state %q matched no elements\n", spec)
 	}
-
-	return &css.Stylesheet{Rules: allRules}
+	return nil
 }
 
-func loadStylesheetsFromURL(d *dom.DOM, baseURL *url.URL) *css.Stylesheet {
-	var allRules []css.Rule
-
-	var walk func(nodeID dom.NodeID)
-	walk = func(nodeID dom.NodeID) {
-		node := d.GetNode(nodeID)
-		if node == nil {
-			return
-		}
-
-		if node.Type == dom.NodeTypeElement && node.Tag == "link" {
-			rel, hasRel := node.Attr["rel"]
-			href, hasHref := node.Attr["href"]
-			if hasRel && rel == "stylesheet" && hasHref {
-				cssURL := resolveURL(baseURL, href)
-				if content, err := fetchURL(cssURL); err == nil {
-					if sheet, err := css.Parse(content); err == nil {
-						allRules = append(allRules, sheet.Rules...)
-						fmt.Printf("Loaded CSS: %s\n", cssURL)
-					}
-				}
-			}
-		}
-
-		// Handle <style> tags
-		if node.Type == dom.NodeTypeElement && node.Tag == "style" {
-			cssText := extractTextContent(d, nodeID)
-			if cssText != "" {
-				if sheet, err := css.Parse(cssText); err == nil {
-					allRules = append(allRules, sheet.Rules...)
-					fmt.Println("Loaded CSS: <style>")
-				}
-			}
+// mediaContext builds the context @media rules are evaluated in from the
+// --media-type and --media-feature flags
+func mediaContext(mediaType string, features []string) (css.MediaContext, error) {
+	media := css.DefaultMediaContext()
+	media.Type = mediaType
+	for _, spec := range features {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			return media, fail(exitUsage, "invalid --media-feature %q: want name=value", spec)
 		}
-
-		for _, childID := range node.Children {
-			walk(childID)
+		if err := media.SetFeature(name, value); err != nil {
+			return media, fail(exitUsage, "invalid --media-feature %q: %w", spec, err)
 		}
 	}
-
-	walk(d.Root)
-
-	if len(allRules) == 0 {
-		return nil
-	}
-
-	return &css.Stylesheet{Rules: allRules}
-}
-
-func resolveURL(base *url.URL, ref string) string {
-	refURL, err := url.Parse(ref)
-	if err != nil {
-		return ref
-	}
-	return base.ResolveReference(refURL).String()
+	return media, nil
 }
 
-func extractTextContent(d *dom.DOM, nodeID dom.NodeID) string {
-	var text string
-	var walk func(id dom.NodeID)
-	walk = func(id dom.NodeID) {
-		node := d.GetNode(id)
-		if node == nil {
-			return
-		}
-		if node.Type == dom.NodeTypeText {
-			text += node.Text
+// archiveGetter returns how URLs are got: from the web, recording into an
+// archive with --record, or from an archive with --replay
+func archiveGetter(recordDir, replayDir string) (loader.Getter, error) {
+	switch {
+	case recordDir != "":
+		archive, err := loader.CreateArchive(recordDir)
+		if err != nil {
+			return nil, fail(exitRender, "failed to create archive: %w", err)
 		}
-		for _, childID := range node.Children {
-			walk(childID)
+		return archive.Record(loader.Web), nil
+	case replayDir != "":
+		archive, err := loader.OpenArchive(replayDir)
+		if err != nil {
+			return nil, fail(exitFetch, "failed to open archive: %w", err)
 		}
+		return archive.Get, nil
 	}
-	walk(nodeID)
-	return text
+	return loader.Web, nil
 }
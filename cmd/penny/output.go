@@ -0,0 +1,38 @@
+package main
+
+import (
+	"image"
+	"os"
+	"strings"
+
+	"github.com/myuon/penny/encode"
+)
+
+// outputFormat returns the format images are written in: the --format
+// flag, or else the format of the output file's extension, or else PNG
+func outputFormat(name, outputFile string) (encode.Format, error) {
+	if name == "" {
+		if f, ok := encode.ForFile(outputFile); ok {
+			return f, nil
+		}
+		name = "png"
+	}
+	f, ok := encode.Lookup(name)
+	if !ok {
+		return f, fail(exitUsage, "unknown format: %s (want %s)", name, strings.Join(encode.Names(), "|"))
+	}
+	return f, nil
+}
+
+// saveImage writes an image to a file in a format
+func saveImage(img image.Image, f encode.Format, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Encode(img, file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/myuon/penny/profile"
+	"github.com/spf13/cobra"
+)
+
+// addProfileFlags adds --pprof and --pprof-addr to cmd and all of its
+// subcommands
+func addProfileFlags(cmd *cobra.Command) {
+	var cpuProfile string
+	var pprofAddr string
+	var stop func() error
+
+	cmd.PersistentFlags().StringVar(&cpuProfile, "pprof", "", "write a CPU profile labeled by pipeline phase to this file")
+	cmd.PersistentFlags().StringVar(&pprofAddr, "pprof-addr", "", "serve pprof endpoints on this address (e.g. localhost:6060)")
+
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if pprofAddr != "" {
+			addr, err := profile.Serve(pprofAddr)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "pprof: http://%s/debug/pprof/\n", addr)
+		}
+
+		if cpuProfile != "" {
+			var err error
+			stop, err = profile.StartCPUProfile(cpuProfile)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cmd.PersistentPostRunE = func(cmd *cobra.Command, args []string) error {
+		if stop == nil {
+			return nil
+		}
+		if err := stop(); err != nil {
+			return fmt.Errorf("failed to write profile: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "CPU profile written to %s\n", cpuProfile)
+		return nil
+	}
+}
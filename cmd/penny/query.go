@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/myuon/penny/engine"
+	"github.com/myuon/penny/service"
+	"github.com/spf13/cobra"
+)
+
+func newQueryCmd() *cobra.Command {
+	var selector string
+	var props []string
+	var width, height int
+
+	cmd := &cobra.Command{
+		Use:   "query <input>",
+		Short: "Print the computed styles and boxes of the elements a selector matches",
+		Long: `query renders an HTML file, MHTML web archive or URL and prints, as a JSON
+array, the elements a selector matches in document order. Each element is an
+object with its "element", such as "h1#title.big", and a key for each of
+--props: the computed value of a longhand property, as CSS text, or "rect",
+the element's box as laid out. Elements that aren't rendered are left out.`,
+		Example: `  penny query page.html --selector "h1" --props color,font-size,rect`,
+		Args:    usageArgs(cobra.ExactArgs(1)),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			q, err := service.ParseQuery(selector, props)
+			if err != nil {
+				return fail(exitUsage, "%v", err)
+			}
+			if width <= 0 || height <= 0 {
+				return fail(exitUsage, "invalid viewport %dx%d", width, height)
+			}
+
+			page, result, err := renderInput(args[0], engine.Options{Width: width, Height: height})
+			if err != nil {
+				return err
+			}
+			data, err := json.MarshalIndent(flattenElements(q.Run(page.DOM, result.Layout)), "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&selector, "selector", "s", "*", "the elements to print")
+	cmd.Flags().StringSliceVar(&props, "props", []string{"rect"}, "longhand properties to print, and rect for the box")
+	cmd.Flags().IntVar(&width, "width", 800, "viewport width to lay the page out in")
+	cmd.Flags().IntVar(&height, "height", 600, "viewport height to lay the page out in")
+	return cmd
+}
+
+// flattenElements returns matched elements as query prints them: each an
+// object of its description, rect and properties side by side
+func flattenElements(elements []service.Element) []map[string]any {
+	flat := make([]map[string]any, len(elements))
+	for i, e := range elements {
+		flat[i] = map[string]any{"element": e.Element}
+		if e.Rect != nil {
+			flat[i]["rect"] = e.Rect
+		}
+		for prop, value := range e.Props {
+			flat[i][prop] = value
+		}
+	}
+	return flat
+}
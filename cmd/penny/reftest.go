@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/myuon/penny/test/reftest"
+	"github.com/spf13/cobra"
+)
+
+func newReftestCmd() *cobra.Command {
+	var opts reftest.Options
+	var format string
+	var compareRev string
+	var shard string
+
+	cmd := &cobra.Command{
+		Use:   "reftest",
+		Short: "Compare penny renderings of a WPT suite against a reference engine",
+		Long: `reftest runs the tests of a web-platform-tests suite through penny and
+compares the result against browser screenshots (--engine chrome, firefox or
+webkit) or against the references the tests declare with <link rel="match">
+(--engine baseline).
+
+Several browsers can be given as a comma-separated list, e.g.
+--engine chrome,firefox,webkit. The first one decides pass/fail and the diff
+against each of them is reported per test.
+
+reftest exits with status 1 if any test fails or errors.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// "css-flexbox" is shorthand for "css/css-flexbox"
+			if !strings.Contains(opts.Suite, "/") {
+				opts.Suite = "css/" + opts.Suite
+			}
+
+			if shard != "" {
+				index, count, err := reftest.ParseShard(shard)
+				if err != nil {
+					return &cliError{code: exitUsage, err: err}
+				}
+				opts.ShardIndex, opts.ShardCount = index, count
+			}
+
+			switch format {
+			case "text", "json", "junit", "github":
+			default:
+				return fail(exitUsage, "unknown format: %s", format)
+			}
+
+			opts.OnResult = func(result reftest.WPTTestResult) {
+				fmt.Fprintf(os.Stderr, "%-5s %6.2f%% %s", strings.ToUpper(result.Status), result.DiffPercent, result.Name)
+				for _, engine := range strings.Split(opts.Engine, ",") {
+					if diff, ok := result.EngineDiffs[engine]; ok {
+						fmt.Fprintf(os.Stderr, " %s=%.2f%%", engine, diff)
+					}
+				}
+				fmt.Fprintln(os.Stderr)
+			}
+
+			suiteResult, err := reftest.RunSuite(opts)
+			if err != nil {
+				return err
+			}
+
+			var comparison *reftest.Comparison
+			if compareRev != "" {
+				base, err := reftest.LoadHistory(opts.HistoryDir(), compareRev)
+				if err != nil {
+					return fmt.Errorf("failed to load history for %s: %w", compareRev, err)
+				}
+				comparison = reftest.CompareResults(base, suiteResult)
+			}
+
+			switch format {
+			case "json":
+				out := struct {
+					*reftest.WPTSuiteResult
+					Comparison *reftest.Comparison `json:"comparison,omitempty"`
+				}{suiteResult, comparison}
+
+				data, err := json.MarshalIndent(out, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return suiteFailure(suiteResult)
+			case "junit":
+				if err := reftest.WriteJUnit(os.Stdout, suiteResult); err != nil {
+					return err
+				}
+				return suiteFailure(suiteResult)
+			case "github":
+				if err := reftest.WriteGitHubAnnotations(os.Stdout, suiteResult, opts.WPTRoot); err != nil {
+					return err
+				}
+				return suiteFailure(suiteResult)
+			}
+
+			if suiteResult.Total > 0 {
+				fmt.Printf("WPT Suite %s: %d/%d passed (%.1f%%), %d failed, %d errors\n",
+					suiteResult.Suite, suiteResult.Passed, suiteResult.Total,
+					float64(suiteResult.Passed)/float64(suiteResult.Total)*100,
+					suiteResult.Failed, suiteResult.Errors)
+			} else {
+				fmt.Printf("WPT Suite %s: no tests run\n", suiteResult.Suite)
+			}
+			if comparison != nil {
+				fmt.Print(comparison.Dump())
+			}
+			return suiteFailure(suiteResult)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Suite, "suite", "css-flexbox", "WPT suite to run")
+	cmd.Flags().StringVar(&opts.Filter, "filter", "", "only run tests whose path matches this regexp")
+	cmd.Flags().StringVar(&opts.Engine, "engine", reftest.EngineChrome, "reference engine: baseline, or a comma-separated list of chrome|firefox|webkit")
+	cmd.Flags().StringVar(&opts.WPTRoot, "wpt-root", "test/wpt", "path to the WPT checkout")
+	cmd.Flags().Float64Var(&opts.Threshold, "threshold", 10.0, "maximum diff percentage for a pass")
+	cmd.Flags().IntVar(&opts.MaxTests, "max-tests", 0, "sample this many tests (0 runs all)")
+	cmd.Flags().Int64Var(&opts.Seed, "seed", 0, "seed for sampling with --max-tests")
+	cmd.Flags().StringVar(&shard, "shard", "", "only run shard i of n (i/n, zero-based)")
+	cmd.Flags().IntVar(&opts.Workers, "workers", 0, "number of tests run in parallel (0 uses one per CPU)")
+	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", "", "directory for diff images and summary.json")
+	cmd.Flags().StringVar(&format, "format", "text", "output format (text|json|junit|github)")
+	cmd.Flags().StringVar(&compareRev, "compare", "", "compare against the recorded run of this revision")
+
+	return cmd
+}
+
+// suiteFailure returns an error if any test of a suite failed, so that the
+// run exits non-zero and CI can gate on it
+func suiteFailure(result *reftest.WPTSuiteResult) error {
+	if result.Failed == 0 && result.Errors == 0 {
+		return nil
+	}
+	return fail(exitFailure, "%s: %d failed, %d errors", result.Suite, result.Failed, result.Errors)
+}
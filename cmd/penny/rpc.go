@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/myuon/penny/loader"
+	"github.com/myuon/penny/service"
+	"github.com/spf13/cobra"
+)
+
+func newRPCCmd() *cobra.Command {
+	var addr string
+	var allowFiles bool
+	var allowOrigins []string
+
+	cmd := &cobra.Command{
+		Use:   "rpc",
+		Short: "Serve the rendering API as JSON-RPC over HTTP",
+		Long: `rpc serves penny's rendering API on --addr, as JSON-RPC 2.0 requests POSTed
+to /rpc. The methods Render, Screenshot, DumpLayout and Query, and their
+messages, are defined in service/penny.proto. Pages are given by http or https
+URL, or as inline HTML; local files are only rendered with --allow-files.
+
+Requests must be sent as application/json. Browsers send the origin of the
+web page making a request, and it is refused unless that origin is listed in
+--allow-origins, so that pages the user visits can't drive penny. Clients
+outside a browser send no origin and are always accepted.`,
+		Args: usageArgs(cobra.NoArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s := service.New(loader.Web)
+			s.AllowFiles = allowFiles
+			s.AllowOrigins = allowOrigins
+			mux := http.NewServeMux()
+			mux.Handle("/rpc", s.Handler())
+			fmt.Fprintf(os.Stderr, "serving JSON-RPC on http://%s/rpc\n", addr)
+			return newHTTPServer(addr, mux).ListenAndServe()
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	cmd.Flags().BoolVar(&allowFiles, "allow-files", false, "let requests render local files by their path")
+	cmd.Flags().StringSliceVar(&allowOrigins, "allow-origins", nil, "origins of web pages allowed to make requests, or * for any")
+	return cmd
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// newHTTPServer returns a server for handler on addr. A client that
+// connects and then stalls, or leaves a connection idle, is dropped rather
+// than held forever.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
@@ -0,0 +1,613 @@
+package css
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keyframes is a @keyframes rule
+type Keyframes struct {
+	Name   string
+	Frames []Keyframe // sorted by offset
+}
+
+// Keyframe is one block of a @keyframes rule
+type Keyframe struct {
+	Offset       float32       // 0 for from, 1 for to
+	Declarations []Declaration // as longhands
+	// Timing eases the interval from this keyframe to the next, overriding
+	// the animation-timing-function of the element
+	Timing *TimingFunction
+
+	values []keyframeValue // the declarations that can be animated, parsed
+}
+
+type keyframeValue struct {
+	id    PropertyID
+	value Value
+}
+
+func newKeyframe(decls []Declaration) Keyframe {
+	frame := Keyframe{Declarations: ExpandDeclarations(decls)}
+	for _, decl := range frame.Declarations {
+		id, v, err := ParseValue(decl)
+		if err != nil {
+			continue
+		}
+		switch {
+		case id == PropAnimationTimingFunction:
+			timing := v.Timing
+			frame.Timing = &timing
+		case properties[id].animation != animateNever:
+			frame.values = append(frame.values, keyframeValue{id, v})
+		}
+	}
+	return frame
+}
+
+func (f *Keyframe) value(id PropertyID) (Value, bool) {
+	// A later declaration of the property wins
+	for i := len(f.values) - 1; i >= 0; i-- {
+		if f.values[i].id == id {
+			return f.values[i].value, true
+		}
+	}
+	return Value{}, false
+}
+
+// TimingFunction is an easing function: the cubic Bézier curve from (0, 0)
+// to (1, 1) through the control points (X1, Y1) and (X2, Y2), or a step
+// function when Steps is set
+type TimingFunction struct {
+	X1, Y1, X2, Y2 float32
+	Steps          int
+	JumpStart      bool // steps jump at the start of each interval rather than the end
+}
+
+var (
+	TimingLinear    = TimingFunction{X1: 0, Y1: 0, X2: 1, Y2: 1}
+	TimingEase      = TimingFunction{X1: 0.25, Y1: 0.1, X2: 0.25, Y2: 1}
+	TimingEaseIn    = TimingFunction{X1: 0.42, Y1: 0, X2: 1, Y2: 1}
+	TimingEaseOut   = TimingFunction{X1: 0, Y1: 0, X2: 0.58, Y2: 1}
+	TimingEaseInOut = TimingFunction{X1: 0.42, Y1: 0, X2: 0.58, Y2: 1}
+)
+
+// At returns the eased progress for the input progress x, from 0 to 1
+func (f TimingFunction) At(x float32) float32 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+
+	if f.Steps > 0 {
+		n := float32(f.Steps)
+		step := float32(math.Floor(float64(x * n)))
+		if f.JumpStart {
+			step++
+		}
+		return min(step/n, 1)
+	}
+
+	if f.X1 == f.Y1 && f.X2 == f.Y2 {
+		return x
+	}
+	// The curve's x is monotonic in its parameter, so the parameter for x
+	// is found by bisection
+	lo, hi := 0.0, 1.0
+	for range 32 {
+		mid := (lo + hi) / 2
+		if bezier(float64(f.X1), float64(f.X2), mid) < float64(x) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return float32(bezier(float64(f.Y1), float64(f.Y2), (lo+hi)/2))
+}
+
+// bezier evaluates one coordinate of a cubic Bézier curve from 0 to 1 with
+// control points p1 and p2
+func bezier(p1, p2, t float64) float64 {
+	u := 1 - t
+	return 3*u*u*t*p1 + 3*u*t*t*p2 + t*t*t
+}
+
+type AnimationDirection uint8
+
+const (
+	DirectionNormal AnimationDirection = iota
+	DirectionReverse
+	DirectionAlternate
+	DirectionAlternateReverse
+)
+
+type FillMode uint8
+
+const (
+	FillNone FillMode = iota
+	FillForwards
+	FillBackwards
+	FillBoth
+)
+
+// Animation is the CSS animation of an element. Penny runs one animation
+// per element; of a list of animations, the first is used.
+type Animation struct {
+	Name           string // the @keyframes to run, or "" for none
+	Duration       time.Duration
+	Delay          time.Duration
+	IterationCount float32 // +Inf for infinite
+	Direction      AnimationDirection
+	FillMode       FillMode
+	Timing         TimingFunction
+}
+
+// Progress returns how far through its keyframes the animation is at time
+// at since the document loaded, from 0 to 1. It reports false when the
+// animation doesn't apply at that time: before its delay or after its end,
+// unless its fill mode extends it.
+func (a Animation) Progress(at time.Duration) (float32, bool) {
+	if a.Name == "" {
+		return 0, false
+	}
+
+	active := at - a.Delay
+	var iteration float64 // the current iteration, and how far through it
+	after := false
+	switch {
+	case active < 0:
+		if a.FillMode != FillBackwards && a.FillMode != FillBoth {
+			return 0, false
+		}
+	case !a.Running(at):
+		if a.FillMode != FillForwards && a.FillMode != FillBoth {
+			return 0, false
+		}
+		iteration, after = float64(a.IterationCount), true
+		if math.IsInf(iteration, 1) {
+			// Only reachable with a zero duration
+			iteration = 1
+		}
+	default:
+		iteration = float64(active) / float64(a.Duration)
+	}
+
+	index := math.Floor(iteration)
+	progress := float32(iteration - index)
+	// Having finished a whole number of iterations, the animation rests at
+	// the end of the last one rather than the start of the next
+	if after && progress == 0 && iteration > 0 {
+		index--
+		progress = 1
+	}
+
+	even := math.Mod(index, 2) == 0
+	switch {
+	case a.Direction == DirectionReverse,
+		a.Direction == DirectionAlternate && !even,
+		a.Direction == DirectionAlternateReverse && even:
+		progress = 1 - progress
+	}
+	return progress, true
+}
+
+// Running reports whether the animation is still playing at time at, so
+// later frames will differ
+func (a Animation) Running(at time.Duration) bool {
+	if a.Name == "" || a.Duration <= 0 {
+		return false
+	}
+	end := float64(a.Delay) + float64(a.Duration)*float64(a.IterationCount)
+	return float64(at) < end
+}
+
+// Transition is the CSS transition of an element. Penny runs one
+// transition definition per element; of a list, the first is used.
+type Transition struct {
+	Property string // the property to transition, "all" or "none"
+	Duration time.Duration
+	Delay    time.Duration
+	Timing   TimingFunction
+}
+
+// Covers reports whether a change of the property starts the transition.
+// A shorthand covers the longhands named after it, such as margin-top for
+// margin. Only properties that interpolate transition.
+func (t Transition) Covers(id PropertyID) bool {
+	if t.Duration <= 0 {
+		return false
+	}
+	switch properties[id].animation {
+	case animateLength, animateColor:
+	default:
+		return false
+	}
+	name := properties[id].name
+	return t.Property == "all" || t.Property == name || strings.HasPrefix(name, t.Property+"-")
+}
+
+// Changed returns the properties whose change from before to after starts
+// the transition
+func (t Transition) Changed(before, after *Style) []PropertyID {
+	var changed []PropertyID
+	for id := range numProperties {
+		if t.Covers(id) && getValue(before, id) != getValue(after, id) {
+			changed = append(changed, id)
+		}
+	}
+	return changed
+}
+
+// Animate applies the animation of style as it is at time at since the
+// document loaded, given the @keyframes it names, with relative lengths
+// resolved against units. A property the keyframes don't set at 0% or 100%
+// animates from or to the element's own value. It reports whether the
+// animation is still running.
+func Animate(style *Style, keyframes *Keyframes, units Units, at time.Duration) bool {
+	running := style.Animation.Running(at)
+	p, ok := style.Animation.Progress(at)
+	if !ok || keyframes == nil {
+		return running
+	}
+
+	base := *style
+	var done [numProperties]bool
+	for _, frame := range keyframes.Frames {
+		for _, kv := range frame.values {
+			if done[kv.id] {
+				continue
+			}
+			done[kv.id] = true
+			setValue(style, kv.id, sample(&base, keyframes.Frames, kv.id, units, p))
+		}
+	}
+	return running
+}
+
+// sample returns the value of a property p of the way through keyframes,
+// each interval eased by its timing function
+func sample(base *Style, frames []Keyframe, id PropertyID, units Units, p float32) Value {
+	type point struct {
+		offset float32
+		value  Value
+		timing *TimingFunction
+	}
+	own := getValue(base, id)
+	from := point{0, own, nil}
+	to := point{1, own, nil}
+	for i := range frames {
+		frame := &frames[i]
+		v, ok := frame.value(id)
+		if !ok {
+			continue
+		}
+		if v.CurrentColor {
+			v = Value{Color: base.Color}
+		}
+		v = units.resolve(id, v, units.FontSize)
+		if frame.Offset <= p {
+			from = point{frame.Offset, v, frame.Timing}
+			continue
+		}
+		to = point{frame.Offset, v, frame.Timing}
+		break
+	}
+
+	span := to.offset - from.offset
+	if span <= 0 {
+		return from.value
+	}
+	timing := base.Animation.Timing
+	if from.timing != nil {
+		timing = *from.timing
+	}
+	return Interpolate(id, from.value, to.value, timing.At((p-from.offset)/span))
+}
+
+// Interpolate returns the value of a property a fraction t of the way from
+// one value to another. Values that can't be interpolated, such as keywords
+// and auto lengths, switch halfway.
+func Interpolate(id PropertyID, from, to Value, t float32) Value {
+	switch properties[id].animation {
+	case animateLength:
+		if from.Auto || to.Auto {
+			break
+		}
+		return Value{Length: from.Length + (to.Length-from.Length)*t}
+	case animateColor:
+		return Value{Color: Color{
+			R: lerpChannel(from.Color.R, to.Color.R, t),
+			G: lerpChannel(from.Color.G, to.Color.G, t),
+			B: lerpChannel(from.Color.B, to.Color.B, t),
+			A: lerpChannel(from.Color.A, to.Color.A, t),
+		}}
+	}
+	if t < 0.5 {
+		return from
+	}
+	return to
+}
+
+// lerpChannel interpolates a color channel, clamping easing overshoot
+func lerpChannel(from, to uint8, t float32) uint8 {
+	v := float32(from) + (float32(to)-float32(from))*t
+	return uint8(max(0, min(255, math.Round(float64(v)))))
+}
+
+// firstListItem returns the first item of a comma separated list value
+func firstListItem(values []Token) []Token {
+	for i, tok := range values {
+		if tok.Type == TokenComma && !insideFunction(values, i) {
+			return values[:i]
+		}
+	}
+	return values
+}
+
+var zeroSeconds = Token{Type: TokenDimension, Value: "0", Unit: "s"}
+
+// parseTime parses a time such as 1s or 250ms
+func parseTime(values []Token) (time.Duration, bool) {
+	if len(values) != 1 {
+		return 0, false
+	}
+	tok := values[0]
+	v, err := strconv.ParseFloat(tok.Value, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case tok.Type == TokenDimension && tok.Unit == "s":
+		return time.Duration(v * float64(time.Second)), true
+	case tok.Type == TokenDimension && tok.Unit == "ms":
+		return time.Duration(v * float64(time.Millisecond)), true
+	}
+	return 0, false
+}
+
+var timingKeywords = map[string]TimingFunction{
+	"linear":      TimingLinear,
+	"ease":        TimingEase,
+	"ease-in":     TimingEaseIn,
+	"ease-out":    TimingEaseOut,
+	"ease-in-out": TimingEaseInOut,
+	"step-start":  {Steps: 1, JumpStart: true},
+	"step-end":    {Steps: 1},
+}
+
+func (f TimingFunction) String() string {
+	for _, keyword := range []string{"linear", "ease", "ease-in", "ease-out", "ease-in-out", "step-start", "step-end"} {
+		if timingKeywords[keyword] == f {
+			return keyword
+		}
+	}
+	if f.Steps > 0 {
+		jump := "jump-end"
+		if f.JumpStart {
+			jump = "jump-start"
+		}
+		return fmt.Sprintf("steps(%d, %s)", f.Steps, jump)
+	}
+	return fmt.Sprintf("cubic-bezier(%s, %s, %s, %s)", formatNumber(f.X1), formatNumber(f.Y1), formatNumber(f.X2), formatNumber(f.Y2))
+}
+
+// parseTimingFunction parses a timing keyword, cubic-bezier() or steps()
+func parseTimingFunction(values []Token) (TimingFunction, bool) {
+	if len(values) == 1 && values[0].Type == TokenIdent {
+		f, ok := timingKeywords[values[0].Value]
+		return f, ok
+	}
+	if len(values) < 2 || values[0].Type != TokenFunction || values[len(values)-1].Type != TokenRParen {
+		return TimingFunction{}, false
+	}
+
+	var args []Token
+	for _, tok := range values[1 : len(values)-1] {
+		if tok.Type != TokenComma {
+			args = append(args, tok)
+		}
+	}
+	switch values[0].Value {
+	case "cubic-bezier":
+		if len(args) != 4 {
+			return TimingFunction{}, false
+		}
+		var p [4]float32
+		for i, arg := range args {
+			v, err := strconv.ParseFloat(arg.Value, 32)
+			if arg.Type != TokenNumber || err != nil {
+				return TimingFunction{}, false
+			}
+			p[i] = float32(v)
+		}
+		// The x coordinates must stay in range for the curve to be a
+		// function of time
+		if p[0] < 0 || p[0] > 1 || p[2] < 0 || p[2] > 1 {
+			return TimingFunction{}, false
+		}
+		return TimingFunction{X1: p[0], Y1: p[1], X2: p[2], Y2: p[3]}, true
+	case "steps":
+		if len(args) == 0 || len(args) > 2 || args[0].Type != TokenNumber {
+			return TimingFunction{}, false
+		}
+		n, err := strconv.Atoi(args[0].Value)
+		if err != nil || n < 1 {
+			return TimingFunction{}, false
+		}
+		f := TimingFunction{Steps: n}
+		if len(args) == 2 {
+			switch args[1].Value {
+			case "start", "jump-start":
+				f.JumpStart = true
+			case "end", "jump-end":
+			default:
+				return TimingFunction{}, false
+			}
+		}
+		return f, true
+	}
+	return TimingFunction{}, false
+}
+
+var directionKeywords = map[string]AnimationDirection{
+	"normal":            DirectionNormal,
+	"reverse":           DirectionReverse,
+	"alternate":         DirectionAlternate,
+	"alternate-reverse": DirectionAlternateReverse,
+}
+
+var fillModeKeywords = map[string]FillMode{
+	"none":      FillNone,
+	"forwards":  FillForwards,
+	"backwards": FillBackwards,
+	"both":      FillBoth,
+}
+
+func parseAnimationName(decl Declaration) (Value, bool) {
+	values := firstListItem(decl.Values)
+	if len(values) != 1 || values[0].Type != TokenIdent && values[0].Type != TokenString {
+		return Value{}, false
+	}
+	if values[0].Type == TokenIdent && values[0].Value == "none" {
+		return Value{}, true
+	}
+	return Value{Name: values[0].Value}, true
+}
+
+func parseDuration(decl Declaration) (Value, bool) {
+	d, ok := parseTime(firstListItem(decl.Values))
+	return Value{Time: d}, ok && d >= 0
+}
+
+func parseDelay(decl Declaration) (Value, bool) {
+	d, ok := parseTime(firstListItem(decl.Values))
+	return Value{Time: d}, ok
+}
+
+func parseIterationCount(decl Declaration) (Value, bool) {
+	values := firstListItem(decl.Values)
+	if len(values) == 1 && values[0].Type == TokenIdent && values[0].Value == "infinite" {
+		return Value{Length: float32(math.Inf(1))}, true
+	}
+	v, ok := parseNumber(Declaration{Values: values})
+	return v, ok && v.Length >= 0
+}
+
+func parseAnimationDirection(decl Declaration) (Value, bool) {
+	values := firstListItem(decl.Values)
+	if len(values) != 1 {
+		return Value{}, false
+	}
+	d, ok := directionKeywords[values[0].Value]
+	return Value{Keyword: uint8(d)}, ok
+}
+
+func parseFillMode(decl Declaration) (Value, bool) {
+	values := firstListItem(decl.Values)
+	if len(values) != 1 {
+		return Value{}, false
+	}
+	f, ok := fillModeKeywords[values[0].Value]
+	return Value{Keyword: uint8(f)}, ok
+}
+
+func parseTimingValue(decl Declaration) (Value, bool) {
+	f, ok := parseTimingFunction(firstListItem(decl.Values))
+	return Value{Timing: f}, ok
+}
+
+func parseTransitionProperty(decl Declaration) (Value, bool) {
+	values := firstListItem(decl.Values)
+	if len(values) != 1 || values[0].Type != TokenIdent {
+		return Value{}, false
+	}
+	return Value{Name: values[0].Value}, true
+}
+
+// expandAnimation expands "animation: <name> <duration> <timing> <delay>
+// <iteration-count> <direction> <fill-mode>", in any order, to its
+// longhands. The first time is the duration and the second the delay. Only
+// the first animation of a list is kept.
+func expandAnimation(decl Declaration) ([]Declaration, bool) {
+	name := []Token{ident("none")}
+	duration, delay := []Token{zeroSeconds}, []Token{zeroSeconds}
+	timing := []Token{ident("ease")}
+	count := []Token{number("1")}
+	direction, fill := ident("normal"), ident("none")
+
+	var seenName, seenDuration, seenDelay, seenTiming, seenCount, seenDirection, seenFill bool
+	for _, comp := range components(firstListItem(decl.Values)) {
+		tok := comp[0]
+		_, isTime := parseTime(comp)
+		_, isTiming := parseTimingFunction(comp)
+		_, isDirection := directionKeywords[tok.Value]
+		_, isFill := fillModeKeywords[tok.Value]
+		switch {
+		case isTime && !seenDuration:
+			duration, seenDuration = comp, true
+		case isTime && !seenDelay:
+			delay, seenDelay = comp, true
+		case isTiming && !seenTiming:
+			timing, seenTiming = comp, true
+		case !seenCount && (tok.Type == TokenNumber || tok.Type == TokenIdent && tok.Value == "infinite"):
+			count, seenCount = comp, true
+		case isDirection && tok.Type == TokenIdent && !seenDirection:
+			direction, seenDirection = tok, true
+		case isFill && tok.Type == TokenIdent && !seenFill:
+			fill, seenFill = tok, true
+		case tok.Type == TokenIdent && (tok.Value == "running" || tok.Value == "paused"):
+			// Penny doesn't pause animations
+		case !seenName && len(comp) == 1 && (tok.Type == TokenIdent || tok.Type == TokenString):
+			name, seenName = comp, true
+		default:
+			return nil, false
+		}
+	}
+	return []Declaration{
+		longhand("animation-name", name...),
+		longhand("animation-duration", duration...),
+		longhand("animation-timing-function", timing...),
+		longhand("animation-delay", delay...),
+		longhand("animation-iteration-count", count...),
+		longhand("animation-direction", direction),
+		longhand("animation-fill-mode", fill),
+	}, true
+}
+
+// expandTransition expands "transition: <property> <duration> <timing>
+// <delay>", in any order, to its longhands. Only the first transition of a
+// list is kept.
+func expandTransition(decl Declaration) ([]Declaration, bool) {
+	property := []Token{ident("all")}
+	duration, delay := []Token{zeroSeconds}, []Token{zeroSeconds}
+	timing := []Token{ident("ease")}
+
+	var seenProperty, seenDuration, seenDelay, seenTiming bool
+	for _, comp := range components(firstListItem(decl.Values)) {
+		_, isTime := parseTime(comp)
+		_, isTiming := parseTimingFunction(comp)
+		switch {
+		case isTime && !seenDuration:
+			duration, seenDuration = comp, true
+		case isTime && !seenDelay:
+			delay, seenDelay = comp, true
+		case isTiming && !seenTiming:
+			timing, seenTiming = comp, true
+		case !seenProperty && len(comp) == 1 && comp[0].Type == TokenIdent:
+			property, seenProperty = comp, true
+		default:
+			return nil, false
+		}
+	}
+	return []Declaration{
+		longhand("transition-property", property...),
+		longhand("transition-duration", duration...),
+		longhand("transition-timing-function", timing...),
+		longhand("transition-delay", delay...),
+	}, true
+}
@@ -0,0 +1,183 @@
+package css
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeyframes(t *testing.T) {
+	sheet, err := Parse(`
+		@import url(a.css);
+		@media print { p { color: red; } }
+		@keyframes grow {
+			to { width: 100px; }
+			from, 50% { width: 10px; margin: 1px; }
+		}
+		p { animation: grow 2s linear infinite; }
+	`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	// The @media rule is kept with its condition; @import is left for the loader
+	if len(sheet.Rules) != 2 || sheet.Rules[0].Media == nil || sheet.Rules[1].Media != nil {
+		t.Fatalf("expected the print rule and the p rule, got %+v", sheet.Rules)
+	}
+	kf := sheet.Keyframes["grow"]
+	if kf == nil {
+		t.Fatalf("expected @keyframes grow, got %v", sheet.Keyframes)
+	}
+	var offsets []float32
+	for _, frame := range kf.Frames {
+		offsets = append(offsets, frame.Offset)
+	}
+	if len(offsets) != 3 || offsets[0] != 0 || offsets[1] != 0.5 || offsets[2] != 1 {
+		t.Errorf("expected keyframes at 0, 0.5 and 1, got %v", offsets)
+	}
+	if n := len(kf.Frames[0].Declarations); n != 5 {
+		t.Errorf("expected width and the margin longhands, got %d declarations", n)
+	}
+	if dump := sheet.Dump(); !strings.Contains(dump, "@keyframes grow {\n  0% {\n    width: 10px;") {
+		t.Errorf("expected the keyframes in the dump, got:\n%s", dump)
+	}
+}
+
+func TestAnimationShorthand(t *testing.T) {
+	style := DefaultStyle()
+	ApplyDeclaration(&style, firstDeclaration(t, "p { animation: 1s ease-in 500ms 3 alternate both slide, other 2s; }"))
+	want := Animation{
+		Name:           "slide",
+		Duration:       time.Second,
+		Delay:          500 * time.Millisecond,
+		IterationCount: 3,
+		Direction:      DirectionAlternate,
+		FillMode:       FillBoth,
+		Timing:         TimingEaseIn,
+	}
+	if style.Animation != want {
+		t.Errorf("expected %+v, got %+v", want, style.Animation)
+	}
+
+	ApplyDeclaration(&style, firstDeclaration(t, "p { transition: width .5s steps(4, start); }"))
+	wantTransition := Transition{
+		Property: "width",
+		Duration: 500 * time.Millisecond,
+		Timing:   TimingFunction{Steps: 4, JumpStart: true},
+	}
+	if style.Transition != wantTransition {
+		t.Errorf("expected %+v, got %+v", wantTransition, style.Transition)
+	}
+	if !style.Transition.Covers(PropWidth) || style.Transition.Covers(PropHeight) {
+		t.Errorf("expected the transition to cover width only")
+	}
+}
+
+func TestTimingFunction(t *testing.T) {
+	tests := []struct {
+		f    TimingFunction
+		x    float32
+		want float32
+	}{
+		{TimingLinear, 0.3, 0.3},
+		{TimingEaseInOut, 0.5, 0.5},
+		{TimingEase, 0.5, 0.8024},
+		{TimingFunction{Steps: 4}, 0.3, 0.25},
+		{TimingFunction{Steps: 4, JumpStart: true}, 0.3, 0.5},
+		{TimingEase, 1, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.f.At(tt.x); math.Abs(float64(got-tt.want)) > 1e-3 {
+			t.Errorf("%+v at %v: expected %v, got %v", tt.f, tt.x, tt.want, got)
+		}
+	}
+}
+
+func TestAnimationProgress(t *testing.T) {
+	a := Animation{Name: "a", Duration: time.Second, Delay: time.Second, IterationCount: 2, Direction: DirectionAlternate}
+	tests := []struct {
+		at   time.Duration
+		want float32
+		ok   bool
+	}{
+		{500 * time.Millisecond, 0, false}, // in the delay
+		{1250 * time.Millisecond, 0.25, true},
+		{2250 * time.Millisecond, 0.75, true}, // the second iteration runs backwards
+		{3 * time.Second, 0, false},           // finished
+	}
+	for _, tt := range tests {
+		got, ok := a.Progress(tt.at)
+		if ok != tt.ok || ok && math.Abs(float64(got-tt.want)) > 1e-6 {
+			t.Errorf("at %v: expected %v %v, got %v %v", tt.at, tt.want, tt.ok, got, ok)
+		}
+	}
+
+	a.FillMode = FillBoth
+	if p, ok := a.Progress(0); !ok || p != 0 {
+		t.Errorf("expected a backwards fill at the start, got %v %v", p, ok)
+	}
+	// The second iteration ran backwards, so it ends at the start
+	if p, ok := a.Progress(time.Hour); !ok || p != 0 {
+		t.Errorf("expected a forwards fill at the start, got %v %v", p, ok)
+	}
+	if a.Running(time.Hour) {
+		t.Errorf("expected the animation to have finished")
+	}
+}
+
+func TestAnimate(t *testing.T) {
+	sheet, err := Parse(`
+		@keyframes fade {
+			50% { background-color: #ff0000; animation-timing-function: linear; }
+			to { background-color: #0000ff; width: 100px; }
+		}
+	`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	style := DefaultStyle()
+	width := float32(20)
+	style.Width = &width
+	style.Background = ColorWhite
+	style.Animation = Animation{Name: "fade", Duration: 2 * time.Second, IterationCount: 1, Timing: TimingLinear}
+
+	s := style
+	if !Animate(&s, sheet.Keyframes["fade"], DefaultUnits(), 500*time.Millisecond) {
+		t.Errorf("expected the animation to be running")
+	}
+	// Halfway from the element's own white to the 50% red
+	if want := (Color{255, 128, 128, 255}); s.Background != want {
+		t.Errorf("expected %v, got %v", want, s.Background)
+	}
+	if *s.Width != 40 {
+		t.Errorf("expected width 40, got %v", *s.Width)
+	}
+
+	s = style
+	Animate(&s, sheet.Keyframes["fade"], DefaultUnits(), 1500*time.Millisecond)
+	if want := (Color{128, 0, 128, 255}); s.Background != want {
+		t.Errorf("expected %v, got %v", want, s.Background)
+	}
+
+	// Without a fill the element is back to its own style after the end
+	s = style
+	if Animate(&s, sheet.Keyframes["fade"], DefaultUnits(), 3*time.Second) || !s.Equal(style) {
+		t.Errorf("expected the finished animation to leave the style alone, got %+v", s)
+	}
+}
+
+func TestStylesheetAppendKeepsKeyframes(t *testing.T) {
+	first, _ := Parse("@keyframes a { to { width: 1px; } } p { color: red; }")
+	second, _ := Parse("@keyframes a { to { width: 2px; } } @keyframes b { to { width: 3px; } } div { color: blue; }")
+
+	all := &Stylesheet{}
+	all.Append(first)
+	all.Append(second)
+	if len(all.Rules) != 2 || all.Rules[1].Selectors[0].Tag != "div" {
+		t.Errorf("expected the rules in order, got %+v", all.Rules)
+	}
+	if all.Keyframes["a"] != second.Keyframes["a"] || all.Keyframes["b"] == nil {
+		t.Errorf("expected the later @keyframes a and b, got %v", all.Keyframes)
+	}
+}
@@ -0,0 +1,305 @@
+package css
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ClipLength is a length or a percentage of a reference length
+type ClipLength struct {
+	Value   float32
+	Percent bool
+}
+
+// Resolve returns the length in pixels, with percentages taken of basis
+func (l ClipLength) Resolve(basis float32) float32 {
+	if l.Percent {
+		return l.Value * basis / 100
+	}
+	return l.Value
+}
+
+func (l ClipLength) String() string {
+	if l.Percent {
+		return fmt.Sprintf("%g%%", l.Value)
+	}
+	return fmt.Sprintf("%gpx", l.Value)
+}
+
+type ClipShape uint8
+
+const (
+	ClipInset ClipShape = iota
+	ClipCircle
+	ClipPolygon
+)
+
+// ClipRadius is how the radius of a circle is given
+type ClipRadius uint8
+
+const (
+	RadiusClosestSide ClipRadius = iota
+	RadiusFarthestSide
+	RadiusLength
+)
+
+// ClipPath is the value of the clip-path property: a basic shape in the
+// element's border box, outside of which the element and its descendants
+// aren't drawn. Percentages are of the border box, and for the radius of a
+// circle of its diagonal divided by √2.
+type ClipPath struct {
+	Shape ClipShape
+
+	// Insets of an inset() from the top, right, bottom and left edges, and
+	// the radius of its rounded corners
+	Inset [4]ClipLength
+	Round ClipLength
+
+	// Radius and center of a circle()
+	RadiusKind ClipRadius
+	Radius     ClipLength
+	CenterX    ClipLength
+	CenterY    ClipLength
+
+	// Points of a polygon(), filled with the even-odd rule if EvenOdd is set
+	// and the nonzero rule otherwise
+	Points  [][2]ClipLength
+	EvenOdd bool
+}
+
+// Equal reports whether two clip paths, either of which may be nil, are the
+// same
+func (c *ClipPath) Equal(other *ClipPath) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	return c.Shape == other.Shape && c.Inset == other.Inset && c.Round == other.Round &&
+		c.RadiusKind == other.RadiusKind && c.Radius == other.Radius &&
+		c.CenterX == other.CenterX && c.CenterY == other.CenterY &&
+		c.EvenOdd == other.EvenOdd && slices.Equal(c.Points, other.Points)
+}
+
+func (c *ClipPath) String() string {
+	if c == nil {
+		return "none"
+	}
+	switch c.Shape {
+	case ClipInset:
+		s := fmt.Sprintf("inset(%s %s %s %s", c.Inset[0], c.Inset[1], c.Inset[2], c.Inset[3])
+		if c.Round.Value != 0 {
+			s += " round " + c.Round.String()
+		}
+		return s + ")"
+	case ClipCircle:
+		radius := c.Radius.String()
+		switch c.RadiusKind {
+		case RadiusClosestSide:
+			radius = "closest-side"
+		case RadiusFarthestSide:
+			radius = "farthest-side"
+		}
+		return fmt.Sprintf("circle(%s at %s %s)", radius, c.CenterX, c.CenterY)
+	}
+	points := make([]string, len(c.Points))
+	for i, p := range c.Points {
+		points[i] = p[0].String() + " " + p[1].String()
+	}
+	rule := ""
+	if c.EvenOdd {
+		rule = "evenodd, "
+	}
+	return "polygon(" + rule + strings.Join(points, ", ") + ")"
+}
+
+// parseClipPath parses "none" or a basic shape
+func parseClipPath(decl Declaration) (Value, bool) {
+	if decl.Value == "none" {
+		return Value{}, true
+	}
+	comps := components(decl.Values)
+	if len(comps) != 1 {
+		return Value{}, false
+	}
+	comp := comps[0]
+	if comp[0].Type != TokenFunction || comp[len(comp)-1].Type != TokenRParen {
+		return Value{}, false
+	}
+
+	var clip *ClipPath
+	args := comp[1 : len(comp)-1]
+	switch comp[0].Value {
+	case "inset":
+		clip = parseInset(args)
+	case "circle":
+		clip = parseCircle(args)
+	case "polygon":
+		clip = parsePolygon(args)
+	}
+	if clip == nil {
+		return Value{}, false
+	}
+	return Value{ClipPath: clip}, true
+}
+
+// parseInset parses "<length-percentage>{1,4} [round <length-percentage>]?"
+func parseInset(args []Token) *ClipPath {
+	clip := &ClipPath{Shape: ClipInset}
+	var insets []ClipLength
+	for i := 0; i < len(args); i++ {
+		if args[i].Type == TokenIdent && args[i].Value == "round" {
+			round, ok := parseClipLength(args[i+1:])
+			if !ok || len(args) != i+2 || round.Value < 0 {
+				return nil
+			}
+			clip.Round = round
+			break
+		}
+		l, ok := parseClipLength(args[i : i+1])
+		if !ok {
+			return nil
+		}
+		insets = append(insets, l)
+	}
+
+	// The insets repeat like the values of margin
+	switch len(insets) {
+	case 1:
+		clip.Inset = [4]ClipLength{insets[0], insets[0], insets[0], insets[0]}
+	case 2:
+		clip.Inset = [4]ClipLength{insets[0], insets[1], insets[0], insets[1]}
+	case 3:
+		clip.Inset = [4]ClipLength{insets[0], insets[1], insets[2], insets[1]}
+	case 4:
+		clip.Inset = [4]ClipLength(insets)
+	default:
+		return nil
+	}
+	return clip
+}
+
+// parseCircle parses "<radius>? [at <position>]?"
+func parseCircle(args []Token) *ClipPath {
+	center := ClipLength{Value: 50, Percent: true}
+	clip := &ClipPath{Shape: ClipCircle, CenterX: center, CenterY: center}
+	at := slices.IndexFunc(args, func(tok Token) bool { return tok.Type == TokenIdent && tok.Value == "at" })
+	radius := args
+	if at >= 0 {
+		radius = args[:at]
+		x, y, ok := parsePosition(args[at+1:])
+		if !ok {
+			return nil
+		}
+		clip.CenterX, clip.CenterY = x, y
+	}
+
+	switch {
+	case len(radius) == 0:
+	case len(radius) > 1:
+		return nil
+	case radius[0].Type == TokenIdent && radius[0].Value == "closest-side":
+	case radius[0].Type == TokenIdent && radius[0].Value == "farthest-side":
+		clip.RadiusKind = RadiusFarthestSide
+	default:
+		l, ok := parseClipLength(radius)
+		if !ok || l.Value < 0 {
+			return nil
+		}
+		clip.RadiusKind, clip.Radius = RadiusLength, l
+	}
+	return clip
+}
+
+// parsePosition parses a position of one or two values, each a
+// length-percentage or a keyword
+func parsePosition(args []Token) (x, y ClipLength, ok bool) {
+	x = ClipLength{Value: 50, Percent: true}
+	y = x
+	switch len(args) {
+	case 1:
+		if args[0].Type == TokenIdent && (args[0].Value == "top" || args[0].Value == "bottom") {
+			y, ok = positionValue(args[0], "top", "bottom")
+			return x, y, ok
+		}
+		x, ok = positionValue(args[0], "left", "right")
+		return x, y, ok
+	case 2:
+		first, second := args[0], args[1]
+		// Keywords may name the vertical position first
+		if first.Type == TokenIdent && (first.Value == "top" || first.Value == "bottom") ||
+			second.Type == TokenIdent && (second.Value == "left" || second.Value == "right") {
+			first, second = second, first
+		}
+		var okX, okY bool
+		x, okX = positionValue(first, "left", "right")
+		y, okY = positionValue(second, "top", "bottom")
+		return x, y, okX && okY
+	}
+	return x, y, false
+}
+
+// positionValue parses one value of a position along an axis whose ends
+// are named start and end
+func positionValue(tok Token, start, end string) (ClipLength, bool) {
+	if tok.Type == TokenIdent {
+		switch tok.Value {
+		case start:
+			return ClipLength{Percent: true}, true
+		case "center":
+			return ClipLength{Value: 50, Percent: true}, true
+		case end:
+			return ClipLength{Value: 100, Percent: true}, true
+		}
+		return ClipLength{}, false
+	}
+	return parseClipLength([]Token{tok})
+}
+
+// parsePolygon parses "[nonzero | evenodd ,]? [<length-percentage>{2}]#"
+func parsePolygon(args []Token) *ClipPath {
+	clip := &ClipPath{Shape: ClipPolygon}
+	if len(args) >= 2 && args[0].Type == TokenIdent && args[1].Type == TokenComma {
+		switch args[0].Value {
+		case "nonzero":
+		case "evenodd":
+			clip.EvenOdd = true
+		default:
+			return nil
+		}
+		args = args[2:]
+	}
+
+	for len(args) > 0 {
+		point := args
+		if i := slices.IndexFunc(args, func(tok Token) bool { return tok.Type == TokenComma }); i >= 0 {
+			point, args = args[:i], args[i+1:]
+		} else {
+			args = nil
+		}
+		if len(point) != 2 {
+			return nil
+		}
+		x, okX := parseClipLength(point[:1])
+		y, okY := parseClipLength(point[1:])
+		if !okX || !okY {
+			return nil
+		}
+		clip.Points = append(clip.Points, [2]ClipLength{x, y})
+	}
+	if len(clip.Points) < 3 {
+		return nil
+	}
+	return clip
+}
+
+// parseClipLength parses a single length or percentage
+func parseClipLength(values []Token) (ClipLength, bool) {
+	if len(values) != 1 {
+		return ClipLength{}, false
+	}
+	v, ok := parseLength(values)
+	if !ok {
+		return ClipLength{}, false
+	}
+	return ClipLength{Value: v, Percent: values[0].Type == TokenPercentage}, true
+}
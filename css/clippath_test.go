@@ -0,0 +1,36 @@
+package css
+
+import "testing"
+
+func TestParseClipPath(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"none", "none"},
+		{"inset(10px)", "inset(10px 10px 10px 10px)"},
+		{"inset(5% 10px round 4px)", "inset(5% 10px 5% 10px round 4px)"},
+		{"circle()", "circle(closest-side at 50% 50%)"},
+		{"circle(50%)", "circle(50% at 50% 50%)"},
+		{"circle(20px at left top)", "circle(20px at 0% 0%)"},
+		{"circle(farthest-side at bottom 10px)", "circle(farthest-side at 10px 100%)"},
+		{"polygon(50% 0%, 100% 100%, 0 100%)", "polygon(50% 0%, 100% 100%, 0px 100%)"},
+		{"polygon(evenodd, 0 0, 10px 0, 0 10px)", "polygon(evenodd, 0px 0px, 10px 0px, 0px 10px)"},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { clip-path: "+tt.input+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", tt.input)
+			continue
+		}
+		if got := style.ClipPath.String(); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.want, got)
+		}
+	}
+
+	for _, input := range []string{"inset()", "inset(1px 2px 3px 4px 5px)", "inset(1px round)", "circle(-1px)", "circle(at)", "polygon(0 0, 1px 1px)", "polygon(0 0 0, 1px 1px, 2px 2px)", "ellipse()", "url(#clip)"} {
+		if err := ValidateDeclaration(firstDeclaration(t, "p { clip-path: "+input+"; }")); err == nil {
+			t.Errorf("%s: expected an invalid value", input)
+		}
+	}
+}
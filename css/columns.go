@@ -0,0 +1,45 @@
+package css
+
+import "strconv"
+
+// Columns is how an element's content is laid out in columns, from
+// column-count and column-gap
+type Columns struct {
+	Count int // 0 = auto, laid out as a single column
+	Gap   float32
+	// NormalGap is set for a column-gap of normal, which is 1em
+	NormalGap bool
+}
+
+// ColumnGap returns the space between the columns of an element
+func (s Style) ColumnGap() float32 {
+	if s.Columns.NormalGap {
+		return s.FontSize
+	}
+	return s.Columns.Gap
+}
+
+func parseColumnCount(decl Declaration) (Value, bool) {
+	if decl.Value == "auto" {
+		return Value{Auto: true}, true
+	}
+	if len(decl.Values) != 1 || decl.Values[0].Type != TokenNumber {
+		return Value{}, false
+	}
+	n, err := strconv.Atoi(decl.Values[0].Value)
+	if err != nil || n < 1 {
+		return Value{}, false
+	}
+	return Value{Length: float32(n)}, true
+}
+
+func parseColumnGap(decl Declaration) (Value, bool) {
+	if decl.Value == "normal" {
+		return Value{Auto: true}, true
+	}
+	if len(decl.Values) != 1 || decl.Values[0].Type == TokenPercentage {
+		return Value{}, false
+	}
+	v, ok := parseLength(decl.Values)
+	return Value{Length: v}, ok && v >= 0
+}
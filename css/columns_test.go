@@ -0,0 +1,31 @@
+package css
+
+import "testing"
+
+func TestColumns(t *testing.T) {
+	style := DefaultStyle()
+	if style.Columns.Count != 0 || style.ColumnGap() != style.FontSize {
+		t.Errorf("expected auto columns with a 1em gap by default, got %+v", style.Columns)
+	}
+
+	ApplyDeclaration(&style, firstDeclaration(t, "div { column-count: 3; }"))
+	ApplyDeclaration(&style, firstDeclaration(t, "div { column-gap: 12px; }"))
+	if style.Columns.Count != 3 || style.ColumnGap() != 12 {
+		t.Errorf("expected 3 columns 12px apart, got %+v", style.Columns)
+	}
+	if got := InheritedStyle(style).Columns; got.Count != 0 {
+		t.Errorf("expected column-count not to be inherited, got %+v", got)
+	}
+
+	ApplyDeclaration(&style, firstDeclaration(t, "div { column-count: auto; }"))
+	ApplyDeclaration(&style, firstDeclaration(t, "div { column-gap: normal; }"))
+	if style.Columns != DefaultStyle().Columns {
+		t.Errorf("expected auto and normal to reset the columns, got %+v", style.Columns)
+	}
+
+	for _, decl := range []string{"column-count: 0", "column-count: 1.5", "column-gap: -1px", "column-gap: 10%"} {
+		if ApplyDeclaration(&style, firstDeclaration(t, "div { "+decl+"; }")) {
+			t.Errorf("%s: expected the declaration to be rejected", decl)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package css
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CoverageStatus classifies how penny handled a declaration
+type CoverageStatus string
+
+const (
+	// CoverageSupported declarations were applied to the style
+	CoverageSupported CoverageStatus = "supported"
+	// CoverageIgnored declarations were parsed but the property or value
+	// isn't implemented
+	CoverageIgnored CoverageStatus = "parsed-but-ignored"
+	// CoverageUnparsed declarations could not be parsed at all
+	CoverageUnparsed CoverageStatus = "unparsed"
+)
+
+// CoverageEntry counts the occurrences of a property/value pair
+type CoverageEntry struct {
+	Property string         `json:"property"`
+	Value    string         `json:"value"`
+	Status   CoverageStatus `json:"status"`
+	Count    int            `json:"count"`
+}
+
+// Coverage records every declaration encountered while rendering a corpus
+// and whether penny supports it. It is safe for concurrent use.
+type Coverage struct {
+	mu      sync.Mutex
+	entries map[CoverageEntry]int // keyed with Count = 0
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{entries: make(map[CoverageEntry]int)}
+}
+
+// AddStylesheet records the declarations of a stylesheet, which may be nil
+func (c *Coverage) AddStylesheet(sheet *Stylesheet) {
+	if sheet == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, rule := range sheet.Rules {
+		for _, decl := range rule.Declarations {
+			c.addDeclaration(decl)
+		}
+	}
+	for _, property := range sheet.Unparsed {
+		c.entries[CoverageEntry{Property: property, Status: CoverageUnparsed}]++
+	}
+}
+
+func (c *Coverage) addDeclaration(decl Declaration) {
+	// Apply to a scratch style to find out if penny understands it
+	style := DefaultStyle()
+	status := CoverageIgnored
+	if ApplyDeclaration(&style, decl) {
+		status = CoverageSupported
+	}
+	c.entries[CoverageEntry{Property: decl.Property, Value: decl.Value, Status: status}]++
+}
+
+// Entries returns the recorded property/value pairs, most frequent first
+func (c *Coverage) Entries() []CoverageEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]CoverageEntry, 0, len(c.entries))
+	for e, n := range c.entries {
+		e.Count = n
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		if entries[i].Property != entries[j].Property {
+			return entries[i].Property < entries[j].Property
+		}
+		return entries[i].Value < entries[j].Value
+	})
+	return entries
+}
+
+// CoverageReport summarizes a Coverage by status
+type CoverageReport struct {
+	Totals  map[CoverageStatus]int `json:"totals"`
+	Entries []CoverageEntry        `json:"entries"`
+}
+
+func (c *Coverage) Report() *CoverageReport {
+	report := &CoverageReport{
+		Totals:  make(map[CoverageStatus]int),
+		Entries: c.Entries(),
+	}
+	for _, e := range report.Entries {
+		report.Totals[e.Status] += e.Count
+	}
+	return report
+}
+
+func (r *CoverageReport) Dump() string {
+	var result string
+
+	total := 0
+	for _, n := range r.Totals {
+		total += n
+	}
+	result += fmt.Sprintf("Declarations: %d\n", total)
+	for _, status := range []CoverageStatus{CoverageSupported, CoverageIgnored, CoverageUnparsed} {
+		n := r.Totals[status]
+		percent := 0.0
+		if total > 0 {
+			percent = float64(n) / float64(total) * 100
+		}
+		result += fmt.Sprintf("  %-18s %6d (%.1f%%)\n", status, n, percent)
+	}
+
+	// Unsupported properties are what to work on next, so list them first
+	for _, status := range []CoverageStatus{CoverageUnparsed, CoverageIgnored, CoverageSupported} {
+		var lines []string
+		for _, e := range r.Entries {
+			if e.Status != status {
+				continue
+			}
+			decl := e.Property
+			if e.Value != "" {
+				decl += ": " + e.Value
+			}
+			lines = append(lines, fmt.Sprintf("  %6d %s\n", e.Count, decl))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		result += fmt.Sprintf("%s:\n", status)
+		result += strings.Join(lines, "")
+	}
+
+	return result
+}
@@ -0,0 +1,34 @@
+package css
+
+import (
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	sheet, err := Parse(`div { width: 100px; display: contents; float: left; margin } p { width: 100px; color: }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	cov := NewCoverage()
+	cov.AddStylesheet(sheet)
+	report := cov.Report()
+
+	if got := report.Totals[CoverageSupported]; got != 2 {
+		t.Errorf("expected 2 supported declarations, got %d", got)
+	}
+	if got := report.Totals[CoverageIgnored]; got != 2 {
+		t.Errorf("expected 2 parsed-but-ignored declarations, got %d", got)
+	}
+	if got := report.Totals[CoverageUnparsed]; got != 2 {
+		t.Errorf("expected 2 unparsed declarations, got %d", got)
+	}
+
+	// The most frequent pair comes first
+	first := report.Entries[0]
+	if first.Property != "width" || first.Value != "100px" || first.Count != 2 {
+		t.Errorf("expected width: 100px twice first, got %+v", first)
+	}
+
+	t.Logf("Coverage:\n%s", report.Dump())
+}
@@ -0,0 +1,162 @@
+package css
+
+import "strings"
+
+// TextDecorationLine is the value of text-decoration-line: a set of the
+// lines drawn along text
+type TextDecorationLine uint8
+
+const (
+	Underline TextDecorationLine = 1 << iota
+	Overline
+	LineThrough
+)
+
+var decorationLineNames = []struct {
+	line TextDecorationLine
+	name string
+}{
+	{Underline, "underline"},
+	{Overline, "overline"},
+	{LineThrough, "line-through"},
+}
+
+func (l TextDecorationLine) String() string {
+	if l == 0 {
+		return "none"
+	}
+	var names []string
+	for _, n := range decorationLineNames {
+		if l&n.line != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// TextDecoration is how an element decorates its text, from
+// text-decoration-line and text-decoration-color
+type TextDecoration struct {
+	Line  TextDecorationLine
+	Color Color
+	// CurrentColor is set when the lines are drawn in the color of the text
+	CurrentColor bool
+}
+
+// Decorations are the lines text is drawn with. Decorations propagate: the
+// text inside an element is decorated by the element and by its ancestors,
+// each line in the color of the element that set it, except inside a box
+// laid out on its own, such as an inline-block or a positioned box.
+type Decorations struct {
+	Lines TextDecorationLine
+	// Colors are those of the underline, the overline and the
+	// line-through
+	Colors [3]Color
+}
+
+// Add returns the decorations with those of an element whose text is in
+// color added, replacing the colors of the lines they share
+func (d Decorations) Add(t TextDecoration, color Color) Decorations {
+	if !t.CurrentColor {
+		color = t.Color
+	}
+	for i, n := range decorationLineNames {
+		if t.Line&n.line != 0 {
+			d.Lines |= n.line
+			d.Colors[i] = color
+		}
+	}
+	return d
+}
+
+// Color returns the color a line is drawn in
+func (d Decorations) Color(line TextDecorationLine) Color {
+	for i, n := range decorationLineNames {
+		if n.line == line {
+			return d.Colors[i]
+		}
+	}
+	return Color{}
+}
+
+// PropagateDecorations sets the decorations of an element's text once its
+// style is computed, from those it started with, which are its parent's
+func (s *Style) PropagateDecorations() {
+	if s.Display == DisplayInlineBlock || s.Position.OutOfFlow() {
+		s.Decorations = Decorations{}
+	}
+	s.Decorations = s.Decorations.Add(s.TextDecoration, s.Color)
+}
+
+func parseTextDecorationLine(decl Declaration) (Value, bool) {
+	if decl.Value == "none" {
+		return Value{}, true
+	}
+	var line TextDecorationLine
+	for _, tok := range decl.Values {
+		l, ok := decorationLine(tok)
+		if !ok || line&l != 0 {
+			return Value{}, false
+		}
+		line |= l
+	}
+	return Value{Keyword: uint8(line)}, line != 0
+}
+
+// decorationLine returns the line a keyword of text-decoration-line names
+func decorationLine(tok Token) (TextDecorationLine, bool) {
+	if tok.Type != TokenIdent {
+		return 0, false
+	}
+	for _, n := range decorationLineNames {
+		if n.name == tok.Value {
+			return n.line, true
+		}
+	}
+	return 0, false
+}
+
+func parseTextDecorationColor(decl Declaration) (Value, bool) {
+	if decl.Value == "currentcolor" {
+		return Value{CurrentColor: true}, true
+	}
+	return parseColorValue(decl)
+}
+
+// decorationStyles are the values of text-decoration-style, which penny
+// accepts in text-decoration and draws all as solid lines
+var decorationStyles = map[string]bool{
+	"solid": true, "double": true, "dotted": true, "dashed": true, "wavy": true,
+}
+
+// expandTextDecoration expands "text-decoration: <line> <style> <color>",
+// in any order, to its line and color longhands
+func expandTextDecoration(decl Declaration) ([]Declaration, bool) {
+	line := []Token{ident("none")}
+	color := []Token{ident("currentcolor")}
+
+	var lines []Token
+	var seenStyle, seenColor, seenNone bool
+	for _, comp := range components(decl.Values) {
+		_, isLine := decorationLine(comp[0])
+		switch {
+		case len(comp) == 1 && isLine && !seenNone:
+			lines = append(lines, comp[0])
+		case len(comp) == 1 && comp[0].Type == TokenIdent && comp[0].Value == "none" && !seenNone && lines == nil:
+			seenNone = true
+		case !seenStyle && len(comp) == 1 && comp[0].Type == TokenIdent && decorationStyles[comp[0].Value]:
+			seenStyle = true
+		case !seenColor && parseColor(Declaration{Value: tokensString(comp), Values: comp}) != nil:
+			color, seenColor = comp, true
+		default:
+			return nil, false
+		}
+	}
+	if lines != nil {
+		line = lines
+	}
+	return []Declaration{
+		longhand("text-decoration-line", line...),
+		longhand("text-decoration-color", color...),
+	}, true
+}
@@ -0,0 +1,63 @@
+package css
+
+import "testing"
+
+func TestTextDecoration(t *testing.T) {
+	tests := []struct {
+		value string
+		line  string
+		color string
+	}{
+		{"underline", "underline", "currentcolor"},
+		{"line-through overline red", "overline line-through", "rgba(255,0,0,255)"},
+		{"wavy underline blue", "underline", "rgba(0,0,255,255)"},
+		{"none", "none", "currentcolor"},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "a { text-decoration: "+tt.value+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", tt.value)
+			continue
+		}
+		if got := style.Serialize(PropTextDecorationLine); got != tt.line {
+			t.Errorf("%s: expected the line %s, got %s", tt.value, tt.line, got)
+		}
+		if got := style.Serialize(PropTextDecorationColor); got != tt.color {
+			t.Errorf("%s: expected the color %s, got %s", tt.value, tt.color, got)
+		}
+	}
+	for _, value := range []string{"underline underline", "none underline", "solid dotted"} {
+		if err := ValidateDeclaration(firstDeclaration(t, "a { text-decoration: "+value+"; }")); err == nil {
+			t.Errorf("%s: expected the value to be invalid", value)
+		}
+	}
+}
+
+func TestPropagateDecorations(t *testing.T) {
+	red, blue := Color{R: 255, A: 255}, Color{B: 255, A: 255}
+	parent := DefaultStyle()
+	parent.Color = red
+	parent.TextDecoration.Line = Underline
+	parent.PropagateDecorations()
+
+	// Text inside takes the underline in the parent's color, and adds its
+	// own lines in its own
+	child := InheritedStyle(parent)
+	child.Color = blue
+	child.TextDecoration.Line = LineThrough
+	child.PropagateDecorations()
+	if child.Decorations.Lines != Underline|LineThrough || child.Decorations.Color(Underline) != red || child.Decorations.Color(LineThrough) != blue {
+		t.Errorf("expected a red underline and a blue line-through, got %+v", child.Decorations)
+	}
+	if child.TextDecoration.Line != LineThrough {
+		t.Errorf("expected text-decoration-line not to be inherited, got %s", child.TextDecoration.Line)
+	}
+
+	// An inline-block is decorated on its own
+	block := InheritedStyle(parent)
+	block.Display = DisplayInlineBlock
+	block.PropagateDecorations()
+	if block.Decorations.Lines != 0 {
+		t.Errorf("expected no decorations inside an inline-block, got %+v", block.Decorations)
+	}
+}
@@ -0,0 +1,257 @@
+package css
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type FilterKind uint8
+
+const (
+	FilterBlur FilterKind = iota
+	FilterBrightness
+	FilterContrast
+	FilterDropShadow
+	FilterGrayscale
+	FilterHueRotate
+	FilterInvert
+	FilterOpacity
+	FilterSaturate
+	FilterSepia
+)
+
+var filterNames = [...]string{
+	FilterBlur:       "blur",
+	FilterBrightness: "brightness",
+	FilterContrast:   "contrast",
+	FilterDropShadow: "drop-shadow",
+	FilterGrayscale:  "grayscale",
+	FilterHueRotate:  "hue-rotate",
+	FilterInvert:     "invert",
+	FilterOpacity:    "opacity",
+	FilterSaturate:   "saturate",
+	FilterSepia:      "sepia",
+}
+
+func (k FilterKind) String() string {
+	if int(k) < len(filterNames) {
+		return filterNames[k]
+	}
+	return "unknown"
+}
+
+// FilterFunction is one function of a filter. Amount is the radius of a
+// blur or drop shadow in pixels, the angle of a hue rotation in degrees, and
+// for the other functions a number where 1 is 100%.
+type FilterFunction struct {
+	Kind             FilterKind
+	Amount           float32
+	OffsetX, OffsetY float32 // of a drop shadow
+	Color            Color   // of a drop shadow
+	// CurrentColor is set for a drop shadow without a color, which takes
+	// the color property
+	CurrentColor bool
+}
+
+// Filter is the value of the filter property: functions applied in order
+// to the rendering of an element and its descendants
+type Filter struct {
+	Functions []FilterFunction
+}
+
+// Equal reports whether two filters, either of which may be nil, are the
+// same
+func (f *Filter) Equal(other *Filter) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+	return slices.Equal(f.Functions, other.Functions)
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return "none"
+	}
+	parts := make([]string, len(f.Functions))
+	for i, fn := range f.Functions {
+		switch fn.Kind {
+		case FilterBlur:
+			parts[i] = fmt.Sprintf("blur(%gpx)", fn.Amount)
+		case FilterHueRotate:
+			parts[i] = fmt.Sprintf("hue-rotate(%gdeg)", fn.Amount)
+		case FilterDropShadow:
+			c := fn.Color
+			parts[i] = fmt.Sprintf("drop-shadow(%gpx %gpx %gpx rgba(%d,%d,%d,%d))", fn.OffsetX, fn.OffsetY, fn.Amount, c.R, c.G, c.B, c.A)
+		default:
+			parts[i] = fmt.Sprintf("%s(%g)", fn.Kind, fn.Amount)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseFilter parses "none" or a list of filter functions
+func parseFilter(decl Declaration) (Value, bool) {
+	if decl.Value == "none" {
+		return Value{}, true
+	}
+
+	filter := &Filter{}
+	for _, comp := range components(decl.Values) {
+		if comp[0].Type != TokenFunction || comp[len(comp)-1].Type != TokenRParen {
+			return Value{}, false
+		}
+		fn, ok := parseFilterFunction(comp[0].Value, comp[1:len(comp)-1])
+		if !ok {
+			return Value{}, false
+		}
+		filter.Functions = append(filter.Functions, fn)
+	}
+	return Value{Filter: filter}, true
+}
+
+// parseFilterFunction parses the arguments of a filter function
+func parseFilterFunction(name string, args []Token) (FilterFunction, bool) {
+	i := slices.Index(filterNames[:], name)
+	if i < 0 {
+		return FilterFunction{}, false
+	}
+	kind := FilterKind(i)
+	fn := FilterFunction{Kind: kind}
+
+	switch kind {
+	case FilterBlur:
+		if len(args) > 0 {
+			v, ok := parseLength(args)
+			if !ok || len(args) != 1 || v < 0 || args[0].Type == TokenPercentage {
+				return fn, false
+			}
+			fn.Amount = v
+		}
+		return fn, true
+
+	case FilterHueRotate:
+		if len(args) > 0 {
+			deg, ok := parseAngle(args)
+			if !ok {
+				return fn, false
+			}
+			fn.Amount = deg
+		}
+		return fn, true
+
+	case FilterDropShadow:
+		return parseDropShadow(args)
+	}
+
+	// The color matrix functions take a number or percentage, 1 by default
+	fn.Amount = 1
+	if len(args) > 0 {
+		if len(args) != 1 || args[0].Type != TokenNumber && args[0].Type != TokenPercentage {
+			return fn, false
+		}
+		v, err := strconv.ParseFloat(args[0].Value, 32)
+		if err != nil || v < 0 {
+			return fn, false
+		}
+		if args[0].Type == TokenPercentage {
+			v /= 100
+		}
+		fn.Amount = float32(v)
+	}
+	switch kind {
+	case FilterGrayscale, FilterInvert, FilterOpacity, FilterSepia:
+		// Amounts past 100% are clamped
+		fn.Amount = min(fn.Amount, 1)
+	}
+	return fn, true
+}
+
+// parseDropShadow parses "<color>? <offset-x> <offset-y> <blur>?", with the
+// color first or last
+func parseDropShadow(args []Token) (FilterFunction, bool) {
+	fn := FilterFunction{Kind: FilterDropShadow}
+	var lengths []float32
+	var color *Color
+	colorLast := false
+	for _, comp := range components(args) {
+		if v, ok := parseLength(comp); ok && len(comp) == 1 && comp[0].Type != TokenPercentage {
+			// The color can't come between the lengths
+			if colorLast {
+				return fn, false
+			}
+			lengths = append(lengths, v)
+			continue
+		}
+		c := parseColor(Declaration{Value: tokensString(comp), Values: comp})
+		if c == nil || color != nil {
+			return fn, false
+		}
+		color = c
+		colorLast = len(lengths) > 0
+	}
+	if len(lengths) < 2 || len(lengths) > 3 {
+		return fn, false
+	}
+	fn.OffsetX, fn.OffsetY = lengths[0], lengths[1]
+	if len(lengths) == 3 {
+		if lengths[2] < 0 {
+			return fn, false
+		}
+		fn.Amount = lengths[2]
+	}
+	if color == nil {
+		fn.CurrentColor = true
+		return fn, true
+	}
+	fn.Color = *color
+	return fn, true
+}
+
+// parseAngle parses an angle to degrees
+func parseAngle(values []Token) (float32, bool) {
+	if len(values) != 1 {
+		return 0, false
+	}
+	tok := values[0]
+	v, err := strconv.ParseFloat(tok.Value, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch {
+	case tok.Type == TokenNumber && v == 0:
+		return 0, true
+	case tok.Type != TokenDimension:
+		return 0, false
+	}
+	switch tok.Unit {
+	case "deg":
+	case "rad":
+		v *= 180 / math.Pi
+	case "grad":
+		v *= 0.9
+	case "turn":
+		v *= 360
+	default:
+		return 0, false
+	}
+	return float32(v), true
+}
+
+// resolveFilter returns filter with the drop shadows that have no color of
+// their own in color. A filter without such shadows is returned as is.
+func resolveFilter(filter *Filter, color Color) *Filter {
+	if filter == nil || !slices.ContainsFunc(filter.Functions, func(fn FilterFunction) bool { return fn.CurrentColor }) {
+		return filter
+	}
+	resolved := &Filter{Functions: slices.Clone(filter.Functions)}
+	for i := range resolved.Functions {
+		if resolved.Functions[i].CurrentColor {
+			resolved.Functions[i].Color = color
+			resolved.Functions[i].CurrentColor = false
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,47 @@
+package css
+
+import "testing"
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"none", "none"},
+		{"blur(2px)", "blur(2px)"},
+		{"blur()", "blur(0px)"},
+		{"grayscale(50%) sepia()", "grayscale(0.5) sepia(1)"},
+		{"invert(2) brightness(2)", "invert(1) brightness(2)"},
+		{"hue-rotate(0.5turn)", "hue-rotate(180deg)"},
+		{"drop-shadow(2px 3px 4px red)", "drop-shadow(2px 3px 4px rgba(255,0,0,255))"},
+		{"drop-shadow(rgb(0, 0, 0) 1px 1px) opacity(.5)", "drop-shadow(1px 1px 0px rgba(0,0,0,255)) opacity(0.5)"},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { filter: "+tt.input+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", tt.input)
+			continue
+		}
+		if got := style.Filter.String(); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.want, got)
+		}
+	}
+
+	for _, input := range []string{"blur(-1px)", "blur(50%)", "glow(1)", "grayscale(1px)", "drop-shadow(1px)", "drop-shadow(1px red 2px)", "red"} {
+		if err := ValidateDeclaration(firstDeclaration(t, "p { filter: "+input+"; }")); err == nil {
+			t.Errorf("%s: expected an invalid value", input)
+		}
+	}
+}
+
+func TestFilterCurrentColor(t *testing.T) {
+	style := DefaultStyle()
+	style.Color = Color{10, 20, 30, 255}
+	ApplyDeclaration(&style, firstDeclaration(t, "p { filter: drop-shadow(1px 2px); }"))
+	if style.Filter == nil || style.Filter.Functions[0].Color != style.Color {
+		t.Errorf("expected the shadow to take the color property, got %v", style.Filter)
+	}
+	if style.Equal(DefaultStyle()) {
+		t.Error("expected a filter to make styles differ")
+	}
+}
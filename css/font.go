@@ -0,0 +1,193 @@
+package css
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FamilyName is a family of a font-family list: a name, or a generic family
+// such as sans-serif
+type FamilyName struct {
+	Name    string
+	Generic bool
+}
+
+// genericFamilies are the keywords that name generic families when written
+// unquoted
+var genericFamilies = map[string]bool{
+	"serif": true, "sans-serif": true, "monospace": true, "cursive": true, "fantasy": true,
+	"system-ui": true, "ui-serif": true, "ui-sans-serif": true, "ui-monospace": true, "ui-rounded": true,
+	"emoji": true, "math": true, "fangsong": true,
+}
+
+// parseFontFamily parses a font-family list into the canonical form Style
+// keeps it in: the families separated by ", ", generic families as
+// keywords and names quoted. A name may be quoted or a run of identifiers.
+func parseFontFamily(decl Declaration) (Value, bool) {
+	var families []string
+	var words []string
+	flush := func() bool {
+		if len(words) == 0 {
+			return false
+		}
+		if len(words) == 1 && genericFamilies[strings.ToLower(words[0])] {
+			families = append(families, strings.ToLower(words[0]))
+		} else {
+			families = append(families, strconv.Quote(strings.Join(words, " ")))
+		}
+		words = words[:0]
+		return true
+	}
+
+	quoted := false
+	for _, tok := range decl.Values {
+		switch tok.Type {
+		case TokenIdent:
+			if quoted {
+				return Value{}, false
+			}
+			words = append(words, tok.Value)
+		case TokenString:
+			if quoted || len(words) > 0 {
+				return Value{}, false
+			}
+			families = append(families, strconv.Quote(tok.Value))
+			quoted = true
+		case TokenComma:
+			if !quoted && !flush() {
+				return Value{}, false
+			}
+			quoted = false
+		default:
+			return Value{}, false
+		}
+	}
+	if !quoted && !flush() {
+		return Value{}, false
+	}
+	return Value{Name: strings.Join(families, ", ")}, true
+}
+
+// FontFamilies splits a font-family list in the canonical form of
+// Style.FontFamily into its families, in order of preference
+func FontFamilies(list string) []FamilyName {
+	var families []FamilyName
+	for list != "" {
+		var family FamilyName
+		if list[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(list)
+			if err != nil {
+				break
+			}
+			family.Name, _ = strconv.Unquote(quoted)
+			list = list[len(quoted):]
+		} else {
+			family.Name, list, _ = strings.Cut(list, ",")
+			family.Generic = true
+		}
+		families = append(families, family)
+		list = strings.TrimLeft(list, ", ")
+	}
+	return families
+}
+
+// FontStyle is the slant of a font, the value of font-style
+type FontStyle uint8
+
+const (
+	FontStyleNormal FontStyle = iota
+	FontStyleItalic
+	FontStyleOblique
+)
+
+func (s FontStyle) String() string {
+	switch s {
+	case FontStyleItalic:
+		return "italic"
+	case FontStyleOblique:
+		return "oblique"
+	default:
+		return "normal"
+	}
+}
+
+// The weights of the normal and bold keywords of font-weight
+const (
+	FontWeightNormal = 400
+	FontWeightBold   = 700
+)
+
+// The keywords of font-weight that are relative to the inherited weight,
+// kept in Value.Keyword
+const (
+	weightBolder = iota + 1
+	weightLighter
+)
+
+func parseFontWeight(decl Declaration) (Value, bool) {
+	if len(decl.Values) != 1 {
+		return Value{}, false
+	}
+	tok := decl.Values[0]
+	if tok.Type == TokenNumber {
+		v, err := strconv.ParseFloat(tok.Value, 32)
+		if err != nil || v < 1 || v > 1000 {
+			return Value{}, false
+		}
+		return Value{Length: float32(v)}, true
+	}
+	switch decl.Value {
+	case "normal":
+		return Value{Length: FontWeightNormal}, true
+	case "bold":
+		return Value{Length: FontWeightBold}, true
+	case "bolder":
+		return Value{Keyword: weightBolder}, true
+	case "lighter":
+		return Value{Keyword: weightLighter}, true
+	}
+	return Value{}, false
+}
+
+// relativeWeight resolves bolder and lighter against a weight, by the
+// table of the CSS Fonts spec
+func relativeWeight(keyword uint8, weight uint16) uint16 {
+	if keyword == weightBolder {
+		switch {
+		case weight < 350:
+			return FontWeightNormal
+		case weight < 550:
+			return FontWeightBold
+		case weight < 900:
+			return 900
+		}
+		return weight
+	}
+	switch {
+	case weight < 100:
+		return weight
+	case weight < 550:
+		return 100
+	case weight < 750:
+		return FontWeightNormal
+	}
+	return FontWeightBold
+}
+
+func parseFontStyle(decl Declaration) (Value, bool) {
+	if len(decl.Values) == 0 || decl.Values[0].Type != TokenIdent {
+		return Value{}, false
+	}
+	switch decl.Values[0].Value {
+	case "normal":
+		return Value{Keyword: uint8(FontStyleNormal)}, len(decl.Values) == 1
+	case "italic":
+		return Value{Keyword: uint8(FontStyleItalic)}, len(decl.Values) == 1
+	case "oblique":
+		// The angle of an oblique isn't supported; text slants by the
+		// default 14 degrees
+		return Value{Keyword: uint8(FontStyleOblique)}, len(decl.Values) == 1 ||
+			len(decl.Values) == 2 && decl.Values[1].Type == TokenDimension
+	}
+	return Value{}, false
+}
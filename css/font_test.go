@@ -0,0 +1,97 @@
+package css
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFontFamily(t *testing.T) {
+	if got := DefaultStyle().FontFamily; got != "serif" {
+		t.Errorf("expected serif by default, got %q", got)
+	}
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"sans-serif", "sans-serif"},
+		{"Helvetica Neue, Arial, SANS-SERIF", `"Helvetica Neue", "Arial", sans-serif`},
+		{`"Fira Code", 'monospace', monospace`, `"Fira Code", "monospace", monospace`},
+		{"Go  Mono", `"Go Mono"`},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { font-family: "+tt.value+"; }")) {
+			t.Errorf("font-family: %s: expected the declaration to apply", tt.value)
+			continue
+		}
+		if style.FontFamily != tt.want {
+			t.Errorf("font-family: %s: expected %s, got %s", tt.value, tt.want, style.FontFamily)
+		}
+		if got := InheritedStyle(style).FontFamily; got != tt.want {
+			t.Errorf("font-family: %s: expected it to be inherited, got %s", tt.value, got)
+		}
+	}
+
+	for _, value := range []string{`"a" "b"`, "Arial,", ", serif", `"a" b`, "12px"} {
+		style := DefaultStyle()
+		if ApplyDeclaration(&style, firstDeclaration(t, "p { font-family: "+value+"; }")) {
+			t.Errorf("font-family: %s: expected the value to be invalid, got %s", value, style.FontFamily)
+		}
+	}
+
+	// The font shorthand sets the family too
+	style := DefaultStyle()
+	ApplyDeclaration(&style, firstDeclaration(t, "p { font: 12px/1.5 Arial, sans-serif; }"))
+	if style.FontFamily != `"Arial", sans-serif` {
+		t.Errorf("expected the family of the font shorthand, got %s", style.FontFamily)
+	}
+}
+
+func TestFontFamilies(t *testing.T) {
+	got := FontFamilies(`"Helvetica, Neue", serif, "monospace"`)
+	want := []FamilyName{{Name: "Helvetica, Neue"}, {Name: "serif", Generic: true}, {Name: "monospace"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFontWeightAndStyle(t *testing.T) {
+	tests := []struct {
+		decls  string
+		weight uint16
+		style  FontStyle
+	}{
+		{"font-weight: bold", FontWeightBold, FontStyleNormal},
+		{"font-weight: 300", 300, FontStyleNormal},
+		{"font-weight: 300; font-weight: bolder", FontWeightNormal, FontStyleNormal},
+		{"font-weight: 600; font-weight: bolder", 900, FontStyleNormal},
+		{"font-weight: bold; font-weight: lighter", FontWeightNormal, FontStyleNormal},
+		{"font-weight: 0", FontWeightNormal, FontStyleNormal},
+		{"font-style: italic", FontWeightNormal, FontStyleItalic},
+		{"font-style: oblique 10deg", FontWeightNormal, FontStyleOblique},
+		{"font: italic bold 12px serif", FontWeightBold, FontStyleItalic},
+	}
+	for _, tt := range tests {
+		sheet, err := Parse("p { " + tt.decls + "; }")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.decls, err)
+		}
+		style := DefaultStyle()
+		for _, decl := range sheet.Rules[0].Declarations {
+			ApplyDeclaration(&style, decl)
+		}
+		if style.FontWeight != tt.weight || style.FontStyle != tt.style {
+			t.Errorf("%s: expected %d %v, got %d %v", tt.decls, tt.weight, tt.style, style.FontWeight, style.FontStyle)
+		}
+	}
+
+	// bolder is relative to the inherited weight
+	parent := DefaultStyle()
+	parent.FontWeight = FontWeightBold
+	style := InheritedStyle(parent)
+	ApplyDeclaration(&style, firstDeclaration(t, "strong { font-weight: bolder; }"))
+	if style.FontWeight != 900 {
+		t.Errorf("expected bolder than bold to be 900, got %d", style.FontWeight)
+	}
+}
@@ -0,0 +1,287 @@
+package css
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+type GradientKind uint8
+
+const (
+	GradientLinear GradientKind = iota
+	GradientRadial
+)
+
+// ColorStop is a color of a gradient and where along it the color is
+type ColorStop struct {
+	Color Color
+	// Position is where the stop is on the gradient line, unless Auto is
+	// set for a stop without one, which is spaced evenly between its
+	// neighbours
+	Position ClipLength
+	Auto     bool
+}
+
+// Gradient is a linear-gradient() or radial-gradient() image
+type Gradient struct {
+	Kind GradientKind
+	// Angle is the direction of a linear gradient in degrees, clockwise
+	// from to top. ToX and ToY, each -1, 0 or 1, name a side or corner to
+	// go to instead, when either is set.
+	Angle    float32
+	ToX, ToY int8
+	// Circle is set for a radial gradient whose ending shape is a circle
+	// rather than an ellipse. Its center is at CenterX, CenterY in the box.
+	Circle           bool
+	CenterX, CenterY ClipLength
+	Stops            []ColorStop
+}
+
+// Equal reports whether two gradients, either of which may be nil, are the
+// same
+func (g *Gradient) Equal(other *Gradient) bool {
+	if g == nil || other == nil {
+		return g == other
+	}
+	return g.Kind == other.Kind && g.Angle == other.Angle && g.ToX == other.ToX && g.ToY == other.ToY &&
+		g.Circle == other.Circle && g.CenterX == other.CenterX && g.CenterY == other.CenterY &&
+		slices.Equal(g.Stops, other.Stops)
+}
+
+func (g *Gradient) String() string {
+	if g == nil {
+		return "none"
+	}
+	var args []string
+	switch {
+	case g.Kind == GradientRadial:
+		shape := "ellipse"
+		if g.Circle {
+			shape = "circle"
+		}
+		args = append(args, fmt.Sprintf("%s at %s %s", shape, g.CenterX, g.CenterY))
+	case g.ToX != 0 || g.ToY != 0:
+		var sides []string
+		if g.ToY != 0 {
+			sides = append(sides, map[int8]string{-1: "top", 1: "bottom"}[g.ToY])
+		}
+		if g.ToX != 0 {
+			sides = append(sides, map[int8]string{-1: "left", 1: "right"}[g.ToX])
+		}
+		args = append(args, "to "+strings.Join(sides, " "))
+	default:
+		args = append(args, fmt.Sprintf("%gdeg", g.Angle))
+	}
+	for _, stop := range g.Stops {
+		c := stop.Color
+		s := fmt.Sprintf("rgba(%d,%d,%d,%d)", c.R, c.G, c.B, c.A)
+		if !stop.Auto {
+			s += " " + stop.Position.String()
+		}
+		args = append(args, s)
+	}
+	name := "linear-gradient"
+	if g.Kind == GradientRadial {
+		name = "radial-gradient"
+	}
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Offsets returns where the stops are on a gradient line of length pixels,
+// as fractions of it. Stops without a position are spaced evenly between
+// their neighbours, the first and last defaulting to the ends, and a stop
+// is never before the one ahead of it.
+func (g *Gradient) Offsets(length float32) []float32 {
+	offsets := make([]float32, len(g.Stops))
+	var last float32
+	for i, stop := range g.Stops {
+		switch {
+		case !stop.Auto && length > 0:
+			offsets[i] = stop.Position.Resolve(length) / length
+		case !stop.Auto:
+			offsets[i] = stop.Position.Resolve(1)
+		case i == 0:
+			offsets[i] = 0
+		case i == len(g.Stops)-1:
+			offsets[i] = 1
+		default:
+			offsets[i] = float32(math.NaN())
+		}
+		if !math.IsNaN(float64(offsets[i])) {
+			if i > 0 {
+				offsets[i] = max(offsets[i], last)
+			}
+			last = offsets[i]
+		}
+	}
+	for i := 0; i < len(offsets); i++ {
+		if !math.IsNaN(float64(offsets[i])) {
+			continue
+		}
+		end := i
+		for math.IsNaN(float64(offsets[end])) {
+			end++
+		}
+		from, to := offsets[i-1], offsets[end]
+		for j := i; j < end; j++ {
+			offsets[j] = from + (to-from)*float32(j-i+1)/float32(end-i+1)
+		}
+		i = end
+	}
+	return offsets
+}
+
+// parseBackgroundImage parses "none" or a gradient
+func parseBackgroundImage(decl Declaration) (Value, bool) {
+	if decl.Value == "none" {
+		return Value{}, true
+	}
+	comps := components(decl.Values)
+	if len(comps) != 1 {
+		return Value{}, false
+	}
+	g := parseGradient(comps[0])
+	if g == nil {
+		return Value{}, false
+	}
+	return Value{Gradient: g}, true
+}
+
+// parseGradient parses a linear-gradient() or radial-gradient() function,
+// or returns nil
+func parseGradient(fn []Token) *Gradient {
+	if len(fn) < 2 || fn[0].Type != TokenFunction || fn[len(fn)-1].Type != TokenRParen {
+		return nil
+	}
+	g := &Gradient{Angle: 180}
+	switch strings.ToLower(fn[0].Value) {
+	case "linear-gradient":
+	case "radial-gradient":
+		g.Kind = GradientRadial
+		center := ClipLength{Value: 50, Percent: true}
+		g.CenterX, g.CenterY = center, center
+	default:
+		return nil
+	}
+
+	args := splitArguments(fn[1 : len(fn)-1])
+	if len(args) > 0 && len(args[0]) > 0 {
+		var ok bool
+		if g.Kind == GradientLinear {
+			ok = parseLinearDirection(g, args[0])
+		} else {
+			ok = parseRadialShape(g, args[0])
+		}
+		if ok {
+			args = args[1:]
+		}
+	}
+	if len(args) < 2 {
+		return nil
+	}
+	for _, arg := range args {
+		stops, ok := parseColorStop(arg)
+		if !ok {
+			return nil
+		}
+		g.Stops = append(g.Stops, stops...)
+	}
+	return g
+}
+
+// splitArguments splits the arguments of a function at its top-level
+// commas
+func splitArguments(values []Token) [][]Token {
+	var args [][]Token
+	start := 0
+	for i, tok := range values {
+		if tok.Type == TokenComma && !insideFunction(values, i) {
+			args = append(args, values[start:i])
+			start = i + 1
+		}
+	}
+	return append(args, values[start:])
+}
+
+// parseLinearDirection parses "<angle>" or "to <side-or-corner>" into g
+func parseLinearDirection(g *Gradient, arg []Token) bool {
+	if angle, ok := parseAngle(arg); ok {
+		g.Angle = angle
+		return true
+	}
+	if len(arg) < 2 || len(arg) > 3 || arg[0].Type != TokenIdent || arg[0].Value != "to" {
+		return false
+	}
+	for _, tok := range arg[1:] {
+		if tok.Type != TokenIdent {
+			return false
+		}
+		switch tok.Value {
+		case "left", "right":
+			if g.ToX != 0 {
+				return false
+			}
+			g.ToX = map[string]int8{"left": -1, "right": 1}[tok.Value]
+		case "top", "bottom":
+			if g.ToY != 0 {
+				return false
+			}
+			g.ToY = map[string]int8{"top": -1, "bottom": 1}[tok.Value]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseRadialShape parses "[circle | ellipse]? [at <position>]?" into g.
+// Only the default size, farthest-corner, is supported.
+func parseRadialShape(g *Gradient, arg []Token) bool {
+	at := slices.IndexFunc(arg, func(tok Token) bool { return tok.Type == TokenIdent && tok.Value == "at" })
+	shape := arg
+	if at >= 0 {
+		shape = arg[:at]
+		x, y, ok := parsePosition(arg[at+1:])
+		if !ok {
+			return false
+		}
+		g.CenterX, g.CenterY = x, y
+	}
+	for _, tok := range shape {
+		switch {
+		case tok.Type == TokenIdent && tok.Value == "circle":
+			g.Circle = true
+		case tok.Type == TokenIdent && (tok.Value == "ellipse" || tok.Value == "farthest-corner"):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// parseColorStop parses "<color> <length-percentage>{0,2}", where two
+// positions make two stops of the color
+func parseColorStop(arg []Token) ([]ColorStop, bool) {
+	comps := components(arg)
+	if len(comps) == 0 || len(comps) > 3 {
+		return nil, false
+	}
+	c := parseColor(Declaration{Value: tokensString(comps[0]), Values: comps[0]})
+	if c == nil {
+		return nil, false
+	}
+	if len(comps) == 1 {
+		return []ColorStop{{Color: *c, Auto: true}}, true
+	}
+	var stops []ColorStop
+	for _, comp := range comps[1:] {
+		l, ok := parseClipLength(comp)
+		if !ok {
+			return nil, false
+		}
+		stops = append(stops, ColorStop{Color: *c, Position: l})
+	}
+	return stops, true
+}
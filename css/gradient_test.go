@@ -0,0 +1,70 @@
+package css
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseGradient(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"none", "none"},
+		{"linear-gradient(red, blue)", "linear-gradient(180deg, rgba(255,0,0,255), rgba(0,0,255,255))"},
+		{"linear-gradient(45deg, red 10%, blue 20px)", "linear-gradient(45deg, rgba(255,0,0,255) 10%, rgba(0,0,255,255) 20px)"},
+		{"linear-gradient(to top right, red, blue)", "linear-gradient(to top right, rgba(255,0,0,255), rgba(0,0,255,255))"},
+		{"linear-gradient(0.25turn, red 0% 50%, blue)", "linear-gradient(90deg, rgba(255,0,0,255) 0%, rgba(255,0,0,255) 50%, rgba(0,0,255,255))"},
+		{"radial-gradient(red, blue)", "radial-gradient(ellipse at 50% 50%, rgba(255,0,0,255), rgba(0,0,255,255))"},
+		{"radial-gradient(circle at left top, rgb(0 0 0 / 0), white)", "radial-gradient(circle at 0% 0%, rgba(0,0,0,0), rgba(255,255,255,255))"},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { background-image: "+tt.input+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", tt.input)
+			continue
+		}
+		if got := style.BackgroundImage.String(); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.want, got)
+		}
+	}
+
+	for _, input := range []string{"linear-gradient(red)", "linear-gradient(to middle, red, blue)", "linear-gradient(to left right, red, blue)", "radial-gradient(square, red, blue)", "linear-gradient(red, 10px)", "url(a.png)"} {
+		if err := ValidateDeclaration(firstDeclaration(t, "p { background-image: "+input+"; }")); err == nil {
+			t.Errorf("%s: expected an invalid value", input)
+		}
+	}
+}
+
+func TestBackgroundGradient(t *testing.T) {
+	style := styleOf(t, "p { background: linear-gradient(red, blue) white; }")
+	if style.BackgroundImage == nil || len(style.BackgroundImage.Stops) != 2 {
+		t.Errorf("expected background to set the gradient, got %s", style.BackgroundImage)
+	}
+	if style.Background != (Color{255, 255, 255, 255}) {
+		t.Errorf("expected background to set the color, got %v", style.Background)
+	}
+
+	style = styleOf(t, "p { background-image: linear-gradient(red, blue); background: white; }")
+	if style.BackgroundImage != nil {
+		t.Errorf("expected background to reset the gradient, got %s", style.BackgroundImage)
+	}
+}
+
+func TestGradientOffsets(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []float32
+	}{
+		{"linear-gradient(red, green, blue)", []float32{0, 0.5, 1}},
+		{"linear-gradient(red 20%, green, blue, white 80%)", []float32{0.2, 0.4, 0.6, 0.8}},
+		{"linear-gradient(red 50px, blue 25%)", []float32{0.25, 0.25}},
+		{"linear-gradient(red 50%, green 10%, blue)", []float32{0.5, 0.5, 1}},
+	}
+	for _, tt := range tests {
+		style := styleOf(t, "p { background-image: "+tt.input+"; }")
+		if got := style.BackgroundImage.Offsets(200); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.want, got)
+		}
+	}
+}
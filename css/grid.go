@@ -0,0 +1,243 @@
+package css
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxGridTracks caps the tracks of a track list, so repeat() can't make a
+// grid too large to lay out
+const maxGridTracks = 1000
+
+// TrackKind is how a grid track is sized
+type TrackKind uint8
+
+const (
+	TrackAuto    TrackKind = iota // by its content
+	TrackLength                   // a length in pixels
+	TrackPercent                  // a percentage of the grid container
+	TrackFr                       // a share of the space left
+)
+
+// TrackSize is the size of a grid track. Value is the length, percentage or
+// number of fr units, as Kind says.
+type TrackSize struct {
+	Kind  TrackKind
+	Value float32
+}
+
+func (t TrackSize) String() string {
+	switch t.Kind {
+	case TrackLength:
+		return strconv.FormatFloat(float64(t.Value), 'g', -1, 32) + "px"
+	case TrackPercent:
+		return strconv.FormatFloat(float64(t.Value), 'g', -1, 32) + "%"
+	case TrackFr:
+		return strconv.FormatFloat(float64(t.Value), 'g', -1, 32) + "fr"
+	}
+	return "auto"
+}
+
+// GridTracks is the value of grid-template-columns or grid-template-rows:
+// the sizes of the explicit tracks, with repeat() expanded
+type GridTracks struct {
+	Sizes []TrackSize
+}
+
+// Equal reports whether two track lists, either of which may be nil, are
+// the same
+func (t *GridTracks) Equal(other *GridTracks) bool {
+	if t == nil || other == nil {
+		return t == other
+	}
+	return slices.Equal(t.Sizes, other.Sizes)
+}
+
+func (t *GridTracks) String() string {
+	if t == nil {
+		return "none"
+	}
+	parts := make([]string, len(t.Sizes))
+	for i, size := range t.Sizes {
+		parts[i] = size.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// Len returns the number of explicit tracks
+func (t *GridTracks) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.Sizes)
+}
+
+// GridLine is where an edge of a grid item is placed, from
+// grid-column-start and its siblings: on a line, numbered from 1 and from
+// -1 for the last line of the explicit grid, or spanning tracks from its
+// other edge. The zero value is auto.
+type GridLine struct {
+	Line int16
+	Span int16
+}
+
+func (l GridLine) String() string {
+	switch {
+	case l.Span > 0:
+		return "span " + strconv.Itoa(int(l.Span))
+	case l.Line != 0:
+		return strconv.Itoa(int(l.Line))
+	}
+	return "auto"
+}
+
+// GridPlacement is where a grid item is placed along an axis
+type GridPlacement struct {
+	Start, End GridLine
+}
+
+// GridGaps returns the space between the columns and between the rows of a
+// grid container. A column-gap of normal is none in a grid.
+func (s Style) GridGaps() (column, row float32) {
+	if !s.Columns.NormalGap {
+		column = s.Columns.Gap
+	}
+	return column, s.RowGap
+}
+
+func parseGridTracks(decl Declaration) (Value, bool) {
+	if decl.Value == "none" {
+		return Value{}, true
+	}
+	var sizes []TrackSize
+	for _, comp := range components(decl.Values) {
+		if comp[0].Type == TokenFunction && comp[0].Value == "repeat" {
+			repeated, ok := parseRepeat(comp)
+			if !ok || len(sizes)+len(repeated) > maxGridTracks {
+				return Value{}, false
+			}
+			sizes = append(sizes, repeated...)
+			continue
+		}
+		size, ok := parseTrackSize(comp)
+		if !ok || len(sizes) == maxGridTracks {
+			return Value{}, false
+		}
+		sizes = append(sizes, size)
+	}
+	if len(sizes) == 0 {
+		return Value{}, false
+	}
+	return Value{Tracks: &GridTracks{Sizes: sizes}}, true
+}
+
+// parseRepeat expands "repeat(<count>, <track sizes>)". The auto-fill and
+// auto-fit counts aren't supported.
+func parseRepeat(comp []Token) ([]TrackSize, bool) {
+	args := comp[1:]
+	if len(args) < 4 || args[0].Type != TokenNumber || args[1].Type != TokenComma || args[len(args)-1].Type != TokenRParen {
+		return nil, false
+	}
+	count, err := strconv.Atoi(args[0].Value)
+	if err != nil || count < 1 {
+		return nil, false
+	}
+	var pattern []TrackSize
+	for _, c := range components(args[2 : len(args)-1]) {
+		size, ok := parseTrackSize(c)
+		if !ok {
+			return nil, false
+		}
+		pattern = append(pattern, size)
+	}
+	if count > maxGridTracks/len(pattern) {
+		return nil, false
+	}
+	sizes := make([]TrackSize, 0, count*len(pattern))
+	for range count {
+		sizes = append(sizes, pattern...)
+	}
+	return sizes, true
+}
+
+func parseTrackSize(comp []Token) (TrackSize, bool) {
+	if len(comp) != 1 {
+		return TrackSize{}, false
+	}
+	tok := comp[0]
+	switch tok.Type {
+	case TokenIdent:
+		return TrackSize{Kind: TrackAuto}, tok.Value == "auto"
+	case TokenDimension:
+		if tok.Unit == "fr" {
+			v, err := strconv.ParseFloat(tok.Value, 32)
+			return TrackSize{Kind: TrackFr, Value: float32(v)}, err == nil && v >= 0
+		}
+	case TokenPercentage:
+		v, err := strconv.ParseFloat(tok.Value, 32)
+		return TrackSize{Kind: TrackPercent, Value: float32(v)}, err == nil && v >= 0
+	}
+	v, ok := parseLength(comp)
+	return TrackSize{Kind: TrackLength, Value: v}, ok && v >= 0
+}
+
+// parseGridLine parses "auto", "<integer>" or "span <integer>". Named lines
+// aren't supported.
+func parseGridLine(decl Declaration) (Value, bool) {
+	values := decl.Values
+	if len(values) == 1 && values[0].Type == TokenIdent && values[0].Value == "auto" {
+		return Value{}, true
+	}
+	span := len(values) == 2 && values[0].Type == TokenIdent && values[0].Value == "span"
+	if span {
+		values = values[1:]
+	}
+	if len(values) != 1 || values[0].Type != TokenNumber {
+		return Value{}, false
+	}
+	n, err := strconv.ParseInt(values[0].Value, 10, 16)
+	if err != nil || n == 0 || span && n < 0 {
+		return Value{}, false
+	}
+	if span {
+		return Value{GridLine: GridLine{Span: int16(n)}}, true
+	}
+	return Value{GridLine: GridLine{Line: int16(n)}}, true
+}
+
+func parseRowGap(decl Declaration) (Value, bool) {
+	v, ok := parseColumnGap(decl)
+	return Value{Length: v.Length}, ok
+}
+
+// gridLineShorthand expands "grid-column: <start> [/ <end>]" and the like to
+// its two longhands. A lone start leaves the end auto.
+func gridLineShorthand(start, end string) func(decl Declaration) ([]Declaration, bool) {
+	return func(decl Declaration) ([]Declaration, bool) {
+		startValues, endValues := decl.Values, []Token{ident("auto")}
+		if i := slices.IndexFunc(decl.Values, isSlash); i >= 0 {
+			startValues, endValues = decl.Values[:i], decl.Values[i+1:]
+		}
+		if len(startValues) == 0 || len(endValues) == 0 {
+			return nil, false
+		}
+		return []Declaration{longhand(start, startValues...), longhand(end, endValues...)}, true
+	}
+}
+
+// expandGap expands "gap: <row-gap> [<column-gap>]"
+func expandGap(decl Declaration) ([]Declaration, bool) {
+	comps := components(decl.Values)
+	switch len(comps) {
+	case 1:
+		return []Declaration{longhand("row-gap", comps[0]...), longhand("column-gap", comps[0]...)}, true
+	case 2:
+		return []Declaration{longhand("row-gap", comps[0]...), longhand("column-gap", comps[1]...)}, true
+	}
+	return nil, false
+}
+
+func isSlash(tok Token) bool {
+	return tok.Type == TokenDelim && tok.Value == "/"
+}
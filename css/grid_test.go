@@ -0,0 +1,64 @@
+package css
+
+import "testing"
+
+func TestGridProperties(t *testing.T) {
+	sheet, err := Parse(`div {
+		display: grid;
+		grid-template-columns: 100px repeat(2, 1fr auto) 25%;
+		grid-template-rows: none;
+		gap: 4px 8px;
+		grid-column: 2 / span 3;
+		grid-row: -1;
+	}`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	style := DefaultStyle()
+	for _, decl := range sheet.Rules[0].Declarations {
+		if !ApplyDeclaration(&style, decl) {
+			t.Errorf("%s: %s: expected the declaration to apply", decl.Property, decl.Value)
+		}
+	}
+
+	if style.Display != DisplayGrid {
+		t.Errorf("expected display grid, got %v", style.Display)
+	}
+	if got := style.GridTemplateColumns.String(); got != "100px 1fr auto 1fr auto 25%" {
+		t.Errorf("expected the columns with repeat() expanded, got %s", got)
+	}
+	if style.GridTemplateRows != nil {
+		t.Errorf("expected no rows, got %s", style.GridTemplateRows)
+	}
+	if column, row := style.GridGaps(); column != 8 || row != 4 {
+		t.Errorf("expected gaps of 8px between columns and 4px between rows, got %v and %v", column, row)
+	}
+	if want := (GridPlacement{Start: GridLine{Line: 2}, End: GridLine{Span: 3}}); style.GridColumn != want {
+		t.Errorf("expected column %v, got %v", want, style.GridColumn)
+	}
+	if want := (GridPlacement{Start: GridLine{Line: -1}}); style.GridRow != want {
+		t.Errorf("expected row %v, got %v", want, style.GridRow)
+	}
+
+	// A column-gap of normal is none in a grid
+	if column, _ := DefaultStyle().GridGaps(); column != 0 {
+		t.Errorf("expected no gap by default, got %v", column)
+	}
+
+	for _, decl := range []string{
+		"grid-template-columns: repeat(auto-fill, 100px)",
+		"grid-template-columns: repeat(0, 1fr)",
+		"grid-template-columns: minmax(10px, 1fr)",
+		"grid-template-columns: -1fr",
+		"grid-template-columns: repeat(2000, 1px)",
+		"grid-column-start: 0",
+		"grid-column-start: span -1",
+		"grid-row: 1 /",
+		"gap: 1px 2px 3px",
+	} {
+		style := DefaultStyle()
+		if ApplyDeclaration(&style, firstDeclaration(t, "div { "+decl+"; }")) {
+			t.Errorf("%s: expected the value to be invalid", decl)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package css
+
+import "strings"
+
+// Import is an @import rule: another stylesheet whose rules come before the
+// importing stylesheet's, in the media its query list matches
+type Import struct {
+	URL string // as written, relative to the importing stylesheet
+	// Media is the query list of the rule, or nil if it has none
+	Media []MediaQuery
+	Pos   Position
+}
+
+// importRule parses an @import after its at-keyword. An @import that comes
+// after any other rule is ignored, as CSS requires.
+func (p *Parser) importRule(sheet *Stylesheet, pos Position) {
+	var href string
+	switch {
+	case p.cur.Type == TokenString:
+		href = p.cur.Value
+		p.advance()
+	case p.cur.Type == TokenFunction && strings.EqualFold(p.cur.Value, "url"):
+		href = p.lexer.urlRest()
+		p.advance()
+	}
+
+	var prelude []Token
+	for p.cur.Type != TokenSemicolon && p.cur.Type != TokenLBrace && p.cur.Type != TokenEOF {
+		prelude = append(prelude, p.cur)
+		p.advance()
+	}
+	if p.cur.Type == TokenLBrace {
+		p.skipAtRule()
+		return
+	}
+	if p.cur.Type == TokenSemicolon {
+		p.advance()
+	}
+	if href == "" || p.pastImports {
+		return
+	}
+	imp := Import{URL: href, Pos: pos}
+	if len(prelude) > 0 {
+		imp.Media = parseMediaQueries(prelude)
+	}
+	sheet.Imports = append(sheet.Imports, imp)
+}
+
+// urlRest reads the rest of a url( function, whose argument is not a CSS
+// token but a URL, quoted or not, up to the closing parenthesis
+func (l *Lexer) urlRest() string {
+	start := l.pos
+	for l.pos < len(l.input) && l.peek() != ')' {
+		l.pos++
+	}
+	u := strings.TrimSpace(l.input[start:l.pos])
+	if l.peek() == ')' {
+		l.advance()
+	}
+	if len(u) >= 2 && (u[0] == '"' || u[0] == '\'') && u[len(u)-1] == u[0] {
+		u = u[1 : len(u)-1]
+	}
+	return u
+}
+
+// InMedia returns the stylesheet with its rules applying only where queries
+// match, as the rules of a stylesheet imported with a query list do. A rule
+// in a @media block of its own applies where both match.
+func (s *Stylesheet) InMedia(queries []MediaQuery) *Stylesheet {
+	if queries == nil {
+		return s
+	}
+	out := *s
+	out.Rules = make([]Rule, len(s.Rules))
+	for i, rule := range s.Rules {
+		rule.Media = AndMedia(queries, rule.Media)
+		out.Rules[i] = rule
+	}
+	return &out
+}
+
+// AndMedia returns a query list that matches where both a and b do. nil is
+// all media. A "not" query can't be combined with another, so such a
+// combination never matches.
+func AndMedia(a, b []MediaQuery) []MediaQuery {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	}
+	var out []MediaQuery
+	for _, qa := range a {
+		for _, qb := range b {
+			if qa.Not || qb.Not || !anyMedia(qa.Type) && !anyMedia(qb.Type) && qa.Type != qb.Type {
+				continue
+			}
+			q := MediaQuery{Type: qa.Type, Features: append(append([]MediaFeature(nil), qa.Features...), qb.Features...)}
+			if anyMedia(q.Type) {
+				q.Type = qb.Type
+			}
+			out = append(out, q)
+		}
+	}
+	if out == nil {
+		return []MediaQuery{notAll}
+	}
+	return out
+}
+
+// anyMedia reports whether a media type is matched by every device
+func anyMedia(t string) bool {
+	return t == "" || t == "all"
+}
@@ -0,0 +1,54 @@
+package css
+
+import "testing"
+
+func TestParseImports(t *testing.T) {
+	sheet, err := Parse(`@charset "utf-8";
+		@import "a.css";
+		@import url(../b.css?v=1) screen and (min-width: 100px), print;
+		@import url('c.css');
+		p { color: red; }
+		@import "late.css";`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	want := []string{"a.css", "../b.css?v=1", "c.css"}
+	if len(sheet.Imports) != len(want) {
+		t.Fatalf("expected %v, got %+v", want, sheet.Imports)
+	}
+	for i, imp := range sheet.Imports {
+		if imp.URL != want[i] {
+			t.Errorf("import %d: expected %s, got %s", i, want[i], imp.URL)
+		}
+	}
+	if m := mediaList(sheet.Imports[1].Media); m != "screen and (min-width: 100px), print" {
+		t.Errorf("expected the import's media, got %q", m)
+	}
+	if sheet.Imports[0].Media != nil || sheet.Imports[0].Pos.Line != 2 {
+		t.Errorf("expected an import for all media on line 2, got %+v", sheet.Imports[0])
+	}
+	if len(sheet.Rules) != 1 {
+		t.Errorf("expected the p rule, got %d rules", len(sheet.Rules))
+	}
+}
+
+func TestAndMedia(t *testing.T) {
+	parse := func(s string) []MediaQuery {
+		tokens := NewLexer(s).Tokenize()
+		return parseMediaQueries(tokens[:len(tokens)-1]) // without EOF
+	}
+	tests := []struct{ a, b, want string }{
+		{"screen", "(min-width: 100px)", "screen and (min-width: 100px)"},
+		{"all", "print, screen", "print, screen"},
+		{"print", "screen", "not all"},
+		{"not print", "screen", "not all"},
+	}
+	for _, tt := range tests {
+		if got := mediaList(AndMedia(parse(tt.a), parse(tt.b))); got != tt.want {
+			t.Errorf("%s and %s: expected %q, got %q", tt.a, tt.b, tt.want, got)
+		}
+	}
+	if AndMedia(nil, nil) != nil {
+		t.Error("expected all media and all media to be all media")
+	}
+}
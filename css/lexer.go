@@ -22,6 +22,11 @@ const (
 	TokenString     // "..." or '...'
 	TokenFunction   // rgb(
 	TokenRParen     // )
+	TokenAtKeyword  // @keyframes
+	TokenBang       // !, as in !important
+	TokenDelim      // a character used in selectors, > + ~ * = ^ $ |, or the / between values
+	TokenLBracket   // [
+	TokenRBracket   // ]
 )
 
 func (t TokenType) String() string {
@@ -56,6 +61,16 @@ func (t TokenType) String() string {
 		return "Function"
 	case TokenRParen:
 		return "RParen"
+	case TokenAtKeyword:
+		return "AtKeyword"
+	case TokenBang:
+		return "Bang"
+	case TokenDelim:
+		return "Delim"
+	case TokenLBracket:
+		return "LBracket"
+	case TokenRBracket:
+		return "RBracket"
 	default:
 		return "Unknown"
 	}
@@ -70,6 +85,9 @@ type Token struct {
 type Lexer struct {
 	input string
 	pos   int
+	start int // offset of the last token returned
+	// spaced is set when whitespace came before the last token returned
+	spaced bool
 }
 
 func NewLexer(input string) *Lexer {
@@ -117,7 +135,10 @@ func (l *Lexer) skipWhitespace() {
 }
 
 func (l *Lexer) NextToken() Token {
+	before := l.pos
 	l.skipWhitespace()
+	l.start = l.pos
+	l.spaced = l.pos > before
 
 	if l.pos >= len(l.input) {
 		return Token{Type: TokenEOF}
@@ -142,17 +163,44 @@ func (l *Lexer) NextToken() Token {
 		l.advance()
 		return Token{Type: TokenComma, Value: ","}
 	case '.':
+		// A number like .5, which can't be a class selector
+		if l.pos+1 < len(l.input) && unicode.IsDigit(rune(l.input[l.pos+1])) {
+			return l.number()
+		}
 		l.advance()
 		return Token{Type: TokenDot, Value: "."}
 	case ')':
 		l.advance()
 		return Token{Type: TokenRParen, Value: ")"}
+	case '!':
+		l.advance()
+		return Token{Type: TokenBang, Value: "!"}
+	case '[':
+		l.advance()
+		return Token{Type: TokenLBracket, Value: "["}
+	case ']':
+		l.advance()
+		return Token{Type: TokenRBracket, Value: "]"}
+	case '>', '+', '~', '*', '/', '=', '^', '$', '|':
+		return Token{Type: TokenDelim, Value: string(l.advance())}
 	case '#':
 		return l.hash()
 	case '"', '\'':
 		return l.str()
+	case '@':
+		l.advance()
+		start := l.pos
+		for l.pos < len(l.input) && isIdentChar(l.peek()) {
+			l.pos++
+		}
+		return Token{Type: TokenAtKeyword, Value: l.input[start:l.pos]}
 	}
 
+	// A minus starts a name like -webkit-box where one follows it, and a
+	// number otherwise
+	if ch == '-' && l.pos+1 < len(l.input) && isIdentStart(l.input[l.pos+1]) {
+		return l.ident()
+	}
 	if ch == '-' || unicode.IsDigit(rune(ch)) {
 		return l.number()
 	}
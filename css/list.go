@@ -0,0 +1,90 @@
+package css
+
+import "strconv"
+
+// ListStyleType is the value of list-style-type: the marker of a list item
+type ListStyleType uint8
+
+const (
+	ListStyleDisc ListStyleType = iota
+	ListStyleNone
+	ListStyleCircle
+	ListStyleSquare
+	ListStyleDecimal
+)
+
+var listStyleTypeKeywords = map[string]ListStyleType{
+	"disc":    ListStyleDisc,
+	"none":    ListStyleNone,
+	"circle":  ListStyleCircle,
+	"square":  ListStyleSquare,
+	"decimal": ListStyleDecimal,
+}
+
+// Marker returns the marker of the nth item of a list, counted from 1, with
+// the space that follows it, or "" for none
+func (t ListStyleType) Marker(n int) string {
+	switch t {
+	case ListStyleDisc:
+		return "• "
+	case ListStyleCircle:
+		return "◦ "
+	case ListStyleSquare:
+		return "▪ "
+	case ListStyleDecimal:
+		return strconv.Itoa(n) + ". "
+	}
+	return ""
+}
+
+// ListStylePosition is the value of list-style-position: whether the
+// marker of a list item is outside its box, beside its first line, or
+// starts the line
+type ListStylePosition uint8
+
+const (
+	ListStyleOutside ListStylePosition = iota
+	ListStyleInside
+)
+
+var listStylePositionKeywords = map[string]ListStylePosition{
+	"outside": ListStyleOutside,
+	"inside":  ListStyleInside,
+}
+
+func parseListStyleType(decl Declaration) (Value, bool) {
+	t, ok := listStyleTypeKeywords[decl.Value]
+	return Value{Keyword: uint8(t)}, ok
+}
+
+func parseListStylePosition(decl Declaration) (Value, bool) {
+	p, ok := listStylePositionKeywords[decl.Value]
+	return Value{Keyword: uint8(p)}, ok
+}
+
+// expandListStyle expands "list-style: <type> <position>", in either order,
+// to its longhands. Marker images aren't supported.
+func expandListStyle(decl Declaration) ([]Declaration, bool) {
+	typ := []Token{ident("disc")}
+	position := []Token{ident("outside")}
+	var seenType, seenPosition bool
+	for _, comp := range components(decl.Values) {
+		if len(comp) != 1 || comp[0].Type != TokenIdent {
+			return nil, false
+		}
+		_, isType := listStyleTypeKeywords[comp[0].Value]
+		_, isPosition := listStylePositionKeywords[comp[0].Value]
+		switch {
+		case isType && !seenType:
+			typ, seenType = comp, true
+		case isPosition && !seenPosition:
+			position, seenPosition = comp, true
+		default:
+			return nil, false
+		}
+	}
+	return []Declaration{
+		longhand("list-style-type", typ...),
+		longhand("list-style-position", position...),
+	}, true
+}
@@ -0,0 +1,57 @@
+package css
+
+import "testing"
+
+func TestListStyle(t *testing.T) {
+	tests := []struct {
+		input    string
+		typ      ListStyleType
+		position ListStylePosition
+	}{
+		{"li { list-style-type: square; }", ListStyleSquare, ListStyleOutside},
+		{"li { list-style-position: inside; }", ListStyleDisc, ListStyleInside},
+		{"li { list-style: inside decimal; }", ListStyleDecimal, ListStyleInside},
+		{"li { list-style-position: inside; list-style: circle; }", ListStyleCircle, ListStyleOutside},
+		{"li { list-style: none; }", ListStyleNone, ListStyleOutside},
+	}
+	for _, tt := range tests {
+		style := styleOf(t, tt.input)
+		if style.ListStyleType != tt.typ || style.ListStylePosition != tt.position {
+			t.Errorf("%s: expected %v %v, got %v %v", tt.input, tt.typ, tt.position, style.ListStyleType, style.ListStylePosition)
+		}
+	}
+
+	style := styleOf(t, "ol { list-style: decimal inside; }")
+	if got := style.Serialize(PropListStyleType) + " " + style.Serialize(PropListStylePosition); got != "decimal inside" {
+		t.Errorf("expected decimal inside, got %s", got)
+	}
+	// Both are inherited, so items take those of their list
+	if child := InheritedStyle(style); child.ListStyleType != ListStyleDecimal || child.ListStylePosition != ListStyleInside {
+		t.Errorf("expected list-style to be inherited, got %v %v", child.ListStyleType, child.ListStylePosition)
+	}
+
+	for _, input := range []string{"li { list-style: disc square; }", "li { list-style-type: lower-roman; }"} {
+		style := DefaultStyle()
+		if ApplyDeclaration(&style, firstDeclaration(t, input)) {
+			t.Errorf("%s: expected the declaration to be rejected", input)
+		}
+	}
+}
+
+func TestListMarker(t *testing.T) {
+	tests := []struct {
+		typ  ListStyleType
+		want string
+	}{
+		{ListStyleDisc, "• "},
+		{ListStyleCircle, "◦ "},
+		{ListStyleSquare, "▪ "},
+		{ListStyleDecimal, "3. "},
+		{ListStyleNone, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.typ.Marker(3); got != tt.want {
+			t.Errorf("%v: expected %q, got %q", tt.typ, tt.want, got)
+		}
+	}
+}
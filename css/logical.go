@@ -0,0 +1,150 @@
+package css
+
+// Logical properties name the sides of a box by where they are in the flow
+// of its content rather than on the page: the inline axis is the one lines
+// run along, and the block axis the one they are stacked along. Penny keeps
+// only the physical properties in Style, so a logical declaration is
+// applied to the physical property it maps to under the element's
+// writing-mode and direction, which the cascade settles first.
+
+// logicalSide is a side of a box in the flow of its content
+type logicalSide uint8
+
+const (
+	inlineStart logicalSide = iota
+	inlineEnd
+	blockStart
+	blockEnd
+)
+
+// physical returns the index of a logical side among top, right, bottom
+// and left
+func (l logicalSide) physical(wm WritingMode, dir Direction) int {
+	const top, right, bottom, left = 0, 1, 2, 3
+	switch l {
+	case inlineStart, inlineEnd:
+		start, end := left, right
+		if wm.Vertical() {
+			start, end = top, bottom
+		}
+		if dir == DirectionRTL {
+			start, end = end, start
+		}
+		if l == inlineStart {
+			return start
+		}
+		return end
+	default:
+		start, end := top, bottom
+		switch wm {
+		case VerticalRL:
+			start, end = right, left
+		case VerticalLR:
+			start, end = left, right
+		}
+		if l == blockStart {
+			return start
+		}
+		return end
+	}
+}
+
+// logicalLonghand is a logical property: a side of a property that has one
+// for each side of the box, whose physical names are in sides
+type logicalLonghand struct {
+	side  logicalSide
+	sides *[4]string
+}
+
+var (
+	marginSides      = [4]string{"margin-top", "margin-right", "margin-bottom", "margin-left"}
+	paddingSides     = [4]string{"padding-top", "padding-right", "padding-bottom", "padding-left"}
+	insetSides       = [4]string{"top", "right", "bottom", "left"}
+	borderWidthSides = [4]string{"border-top-width", "border-right-width", "border-bottom-width", "border-left-width"}
+)
+
+// logicalLonghands maps the logical properties to the sides they set
+var logicalLonghands = func() map[string]logicalLonghand {
+	families := []struct {
+		prefix, suffix string
+		sides          *[4]string
+	}{
+		{"margin-", "", &marginSides},
+		{"padding-", "", &paddingSides},
+		{"inset-", "", &insetSides},
+		{"border-", "-width", &borderWidthSides},
+	}
+	names := [...]string{inlineStart: "inline-start", inlineEnd: "inline-end", blockStart: "block-start", blockEnd: "block-end"}
+	longhands := make(map[string]logicalLonghand, len(families)*len(names))
+	for _, f := range families {
+		for side, name := range names {
+			longhands[f.prefix+name+f.suffix] = logicalLonghand{logicalSide(side), f.sides}
+		}
+	}
+	return longhands
+}()
+
+// physicalProperty returns the physical property a logical property sets
+// in a writing mode and direction, or false if the property isn't logical
+func physicalProperty(name string, wm WritingMode, dir Direction) (string, bool) {
+	switch name {
+	case "inline-size":
+		if wm.Vertical() {
+			return "height", true
+		}
+		return "width", true
+	case "block-size":
+		if wm.Vertical() {
+			return "width", true
+		}
+		return "height", true
+	}
+	longhand, ok := logicalLonghands[name]
+	if !ok {
+		return "", false
+	}
+	return longhand.sides[longhand.side.physical(wm, dir)], true
+}
+
+// isLogical reports whether a property is a logical one
+func isLogical(name string) bool {
+	_, ok := physicalProperty(name, HorizontalTB, DirectionLTR)
+	return ok
+}
+
+// pairShorthand expands the 1 or 2 values of a shorthand like
+// margin-inline to its start and end longhands
+func pairShorthand(start, end string) func(decl Declaration) ([]Declaration, bool) {
+	return func(decl Declaration) ([]Declaration, bool) {
+		comps := components(decl.Values)
+		for _, comp := range comps {
+			if len(comp) != 1 || !isLength(comp[0]) && comp[0].Type != TokenIdent {
+				return nil, false
+			}
+		}
+		switch len(comps) {
+		case 1:
+			return []Declaration{longhand(start, comps[0]...), longhand(end, comps[0]...)}, true
+		case 2:
+			return []Declaration{longhand(start, comps[0]...), longhand(end, comps[1]...)}, true
+		}
+		return nil, false
+	}
+}
+
+// borderSidesShorthand expands a shorthand like border-inline, which takes
+// the value of border, to the widths of its sides and the border color
+func borderSidesShorthand(widths ...string) func(decl Declaration) ([]Declaration, bool) {
+	return func(decl Declaration) ([]Declaration, bool) {
+		border, ok := expandBorder(decl)
+		if !ok {
+			return nil, false
+		}
+		// expandBorder sets the four widths alike, then the color
+		expanded := make([]Declaration, 0, len(widths)+1)
+		for _, w := range widths {
+			expanded = append(expanded, longhand(w, border[0].Values...))
+		}
+		return append(expanded, border[4]), true
+	}
+}
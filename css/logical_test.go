@@ -0,0 +1,62 @@
+package css
+
+import "testing"
+
+func TestLogicalProperties(t *testing.T) {
+	tests := []struct {
+		mode  WritingMode
+		dir   Direction
+		input string
+		want  Edges // of the margin
+	}{
+		{HorizontalTB, DirectionLTR, "margin-inline-start: 1px", Edges{Left: 1}},
+		{HorizontalTB, DirectionRTL, "margin-inline-start: 1px", Edges{Right: 1}},
+		{HorizontalTB, DirectionLTR, "margin-block: 1px 2px", Edges{Top: 1, Bottom: 2}},
+		{HorizontalTB, DirectionLTR, "margin-inline: 3px", Edges{Left: 3, Right: 3}},
+		{VerticalRL, DirectionLTR, "margin-inline-start: 1px", Edges{Top: 1}},
+		{VerticalRL, DirectionRTL, "margin-inline-end: 1px", Edges{Top: 1}},
+		{VerticalRL, DirectionLTR, "margin-block: 1px 2px", Edges{Right: 1, Left: 2}},
+		{VerticalLR, DirectionLTR, "margin-block: 1px 2px", Edges{Left: 1, Right: 2}},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		style.WritingMode, style.Direction = tt.mode, tt.dir
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { "+tt.input+"; }")) {
+			t.Errorf("%s in %s %s: expected the declaration to apply", tt.input, tt.mode, tt.dir)
+			continue
+		}
+		if style.Margin != tt.want {
+			t.Errorf("%s in %s %s: expected a margin of %+v, got %+v", tt.input, tt.mode, tt.dir, tt.want, style.Margin)
+		}
+	}
+}
+
+func TestLogicalPropertiesOtherFamilies(t *testing.T) {
+	style := DefaultStyle()
+	style.WritingMode = VerticalRL
+	for _, input := range []string{
+		"padding-inline: 1px 2px",
+		"inset-block-start: 3px",
+		"border-inline: 4px solid red",
+		"inline-size: 5px",
+	} {
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { "+input+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", input)
+		}
+	}
+	if style.Padding.Top != 1 || style.Padding.Bottom != 2 {
+		t.Errorf("expected the inline padding at the top and bottom, got %+v", style.Padding)
+	}
+	if style.Offsets.Right != (Offset{Length: 3}) {
+		t.Errorf("expected the block start inset on the right, got %+v", style.Offsets)
+	}
+	if style.Border.Top != 4 || style.Border.Bottom != 4 || style.Border.Left != 0 || style.BorderColor != (Color{R: 255, A: 255}) {
+		t.Errorf("expected a red border at the top and bottom, got %+v in %v", style.Border, style.BorderColor)
+	}
+	if style.Height == nil || *style.Height != 5 {
+		t.Errorf("expected the inline size as the height, got %v", style.Height)
+	}
+	if err := ValidateDeclaration(firstDeclaration(t, "p { margin-inline: 1px 2px 3px; }")); err == nil {
+		t.Error("expected three values for margin-inline to be invalid")
+	}
+}
@@ -0,0 +1,333 @@
+package css
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MediaContext describes the device a page is rendered for. @media rules
+// are evaluated against it.
+type MediaContext struct {
+	Type   string  // media type, such as "screen" or "print"
+	Width  float32 // of the viewport, in pixels
+	Height float32
+	DPI    float32
+	// ColorScheme is the scheme the user prefers, "light" or "dark"
+	ColorScheme string
+}
+
+// DefaultMediaContext returns the context penny renders pages in unless
+// told otherwise: a light 800x600 screen at 96 DPI
+func DefaultMediaContext() MediaContext {
+	return MediaContext{
+		Type:        "screen",
+		Width:       800,
+		Height:      600,
+		DPI:         96,
+		ColorScheme: "light",
+	}
+}
+
+// SetFeature sets the feature a media query would test by name, such as
+// "prefers-color-scheme" to "dark" or "width" to "1024"
+func (c *MediaContext) SetFeature(name, value string) error {
+	switch name {
+	case "width", "height":
+		v, err := strconv.ParseFloat(strings.TrimSuffix(value, "px"), 32)
+		if err != nil || v < 0 {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+		if name == "width" {
+			c.Width = float32(v)
+		} else {
+			c.Height = float32(v)
+		}
+	case "resolution":
+		dpi, ok := parseResolution(value)
+		if !ok {
+			return fmt.Errorf("invalid resolution %q", value)
+		}
+		c.DPI = dpi
+	case "prefers-color-scheme":
+		if value != "light" && value != "dark" {
+			return fmt.Errorf("invalid prefers-color-scheme %q (want light or dark)", value)
+		}
+		c.ColorScheme = value
+	default:
+		return fmt.Errorf("unknown media feature %q", name)
+	}
+	return nil
+}
+
+// parseResolution reads a resolution in dpi, dppx or x, or a bare number of
+// dpi
+func parseResolution(value string) (float32, bool) {
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(value, "dpi"):
+		value = strings.TrimSuffix(value, "dpi")
+	case strings.HasSuffix(value, "dppx"):
+		value, scale = strings.TrimSuffix(value, "dppx"), 96
+	case strings.HasSuffix(value, "x"):
+		value, scale = strings.TrimSuffix(value, "x"), 96
+	}
+	v, err := strconv.ParseFloat(value, 32)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	return float32(v * scale), true
+}
+
+// MediaQuery is one query of a @media rule, such as
+// "screen and (min-width: 600px)". A rule applies when any query of its
+// list matches.
+type MediaQuery struct {
+	Not      bool
+	Type     string // empty for any type
+	Features []MediaFeature
+}
+
+// MediaFeature is a condition of a media query, such as
+// "(prefers-color-scheme: dark)"
+type MediaFeature struct {
+	Name  string
+	Value Token // of type TokenEOF for a feature tested alone, as in "(color)"
+}
+
+// notAll is what an invalid query becomes, so it never matches
+var notAll = MediaQuery{Not: true, Type: "all"}
+
+// parseMediaQueries parses the prelude of a @media rule
+func parseMediaQueries(tokens []Token) []MediaQuery {
+	var queries []MediaQuery
+	for len(tokens) > 0 {
+		end := 0
+		for end < len(tokens) && tokens[end].Type != TokenComma {
+			end++
+		}
+		queries = append(queries, parseMediaQuery(tokens[:end]))
+		if end == len(tokens) {
+			break
+		}
+		tokens = tokens[end+1:]
+	}
+	return queries
+}
+
+// parseMediaQuery parses a query of a @media prelude. The lexer drops '(',
+// so a feature is an ident followed by ':' or ')'.
+func parseMediaQuery(tokens []Token) MediaQuery {
+	var q MediaQuery
+	isFeature := func(i int) bool {
+		return tokens[i].Type == TokenIdent && i+1 < len(tokens) &&
+			(tokens[i+1].Type == TokenColon || tokens[i+1].Type == TokenRParen)
+	}
+
+	i := 0
+	if i < len(tokens) && tokens[i].Type == TokenIdent && !isFeature(i) {
+		switch strings.ToLower(tokens[i].Value) {
+		case "not":
+			q.Not = true
+			i++
+		case "only":
+			i++
+		}
+		if i >= len(tokens) || tokens[i].Type != TokenIdent || isFeature(i) {
+			return notAll
+		}
+		q.Type = strings.ToLower(tokens[i].Value)
+		i++
+		if i < len(tokens) {
+			if !strings.EqualFold(tokens[i].Value, "and") {
+				return notAll
+			}
+			i++
+		}
+	}
+
+	for i < len(tokens) {
+		if !isFeature(i) {
+			return notAll
+		}
+		feature := MediaFeature{Name: strings.ToLower(tokens[i].Value)}
+		i++
+		if tokens[i].Type == TokenColon {
+			if i+2 >= len(tokens) || tokens[i+2].Type != TokenRParen {
+				return notAll
+			}
+			feature.Value = tokens[i+1]
+			i += 2
+		}
+		i++ // consume ')'
+		q.Features = append(q.Features, feature)
+
+		if i < len(tokens) {
+			if !strings.EqualFold(tokens[i].Value, "and") {
+				return notAll
+			}
+			i++
+			if i == len(tokens) {
+				return notAll
+			}
+		}
+	}
+	if q.Type == "" && len(q.Features) == 0 {
+		return notAll
+	}
+	return q
+}
+
+// String formats the query as CSS
+func (q MediaQuery) String() string {
+	var parts []string
+	if q.Not {
+		parts = append(parts, "not")
+	}
+	if q.Type != "" {
+		parts = append(parts, q.Type)
+	}
+	for _, f := range q.Features {
+		if len(parts) > 0 {
+			parts = append(parts, "and")
+		}
+		if f.Value.Type == TokenEOF {
+			parts = append(parts, "("+f.Name+")")
+		} else {
+			parts = append(parts, "("+f.Name+": "+f.Value.Value+f.Value.Unit+")")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Matches reports whether a rule conditioned on queries applies in c. A rule
+// outside any @media block, with no queries, always does.
+func (c MediaContext) Matches(queries []MediaQuery) bool {
+	if len(queries) == 0 {
+		return true
+	}
+	for _, q := range queries {
+		if c.matches(q) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c MediaContext) matches(q MediaQuery) bool {
+	ok := q.Type == "" || q.Type == "all" || q.Type == c.Type
+	for _, f := range q.Features {
+		ok = ok && c.matchesFeature(f)
+	}
+	return ok != q.Not
+}
+
+func (c MediaContext) matchesFeature(f MediaFeature) bool {
+	name, prefix := f.Name, ""
+	if rest, ok := strings.CutPrefix(name, "min-"); ok {
+		name, prefix = rest, "min"
+	} else if rest, ok := strings.CutPrefix(name, "max-"); ok {
+		name, prefix = rest, "max"
+	}
+
+	var actual float32
+	switch name {
+	case "width":
+		actual = c.Width
+	case "height":
+		actual = c.Height
+	case "resolution":
+		actual = c.DPI
+	case "prefers-color-scheme":
+		return prefix == "" && (f.Value.Type == TokenEOF || f.Value.Value == c.ColorScheme)
+	case "orientation":
+		orientation := "landscape"
+		if c.Height >= c.Width {
+			orientation = "portrait"
+		}
+		return prefix == "" && (f.Value.Type == TokenEOF || f.Value.Value == orientation)
+	case "color":
+		// Screens and printers alike are taken to be in color
+		return true
+	default:
+		return false
+	}
+
+	if f.Value.Type == TokenEOF {
+		return prefix == "" && actual != 0
+	}
+	want, ok := mediaValue(name, f.Value)
+	if !ok {
+		return false
+	}
+	switch prefix {
+	case "min":
+		return actual >= want
+	case "max":
+		return actual <= want
+	default:
+		return actual == want
+	}
+}
+
+// mediaValue reads the value a numeric feature is compared with, in pixels
+// for lengths and dpi for resolutions
+func mediaValue(name string, tok Token) (float32, bool) {
+	if name == "resolution" {
+		if tok.Type != TokenDimension {
+			return 0, false
+		}
+		return parseResolution(tok.Value + tok.Unit)
+	}
+	if tok.Type != TokenDimension && !(tok.Type == TokenNumber && tok.Value == "0") {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(tok.Value, 32)
+	if err != nil {
+		return 0, false
+	}
+	// Relative lengths in media queries are relative to the initial font
+	// size
+	if tok.Unit == "em" || tok.Unit == "rem" {
+		v *= 16
+	}
+	return float32(v), true
+}
+
+// ForMedia returns the stylesheet as it applies in ctx: the rules of @media
+// blocks that don't match are left out, and those that do lose their
+// condition. s itself is returned if it has no conditional rules.
+func (s *Stylesheet) ForMedia(ctx MediaContext) *Stylesheet {
+	conditional := false
+	for _, rule := range s.Rules {
+		if rule.Media != nil {
+			conditional = true
+			break
+		}
+	}
+	if !conditional {
+		return s
+	}
+
+	out := *s
+	out.Rules = make([]Rule, 0, len(s.Rules))
+	for _, rule := range s.Rules {
+		if ctx.Matches(rule.Media) {
+			rule.Media = nil
+			out.Rules = append(out.Rules, rule)
+		}
+	}
+	return &out
+}
+
+// mediaList formats a query list as CSS, or returns "" for nil
+func mediaList(queries []MediaQuery) string {
+	if queries == nil {
+		return ""
+	}
+	parts := make([]string, len(queries))
+	for i, q := range queries {
+		parts[i] = q.String()
+	}
+	return strings.Join(parts, ", ")
+}
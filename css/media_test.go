@@ -0,0 +1,85 @@
+package css
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMediaQueries(t *testing.T) {
+	dark := DefaultMediaContext()
+	if err := dark.SetFeature("prefers-color-scheme", "dark"); err != nil {
+		t.Fatal(err)
+	}
+	print := DefaultMediaContext()
+	print.Type = "print"
+
+	tests := []struct {
+		query string
+		ctx   MediaContext
+		want  bool
+	}{
+		{"screen", DefaultMediaContext(), true},
+		{"print", DefaultMediaContext(), false},
+		{"print", print, true},
+		{"not print", DefaultMediaContext(), true},
+		{"only screen and (min-width: 600px)", DefaultMediaContext(), true},
+		{"(max-width: 600px)", DefaultMediaContext(), false},
+		{"(min-width: 40em) and (max-height: 600px)", DefaultMediaContext(), true},
+		{"(prefers-color-scheme: dark)", DefaultMediaContext(), false},
+		{"(prefers-color-scheme: dark)", dark, true},
+		{"print, (prefers-color-scheme: dark)", dark, true},
+		{"(orientation: landscape)", DefaultMediaContext(), true},
+		{"(min-resolution: 2dppx)", DefaultMediaContext(), false},
+		{"(unknown-feature)", DefaultMediaContext(), false},
+		{"screen print", DefaultMediaContext(), false},
+	}
+	for _, tt := range tests {
+		sheet, err := Parse("@media " + tt.query + " { p { width: 1px; } }")
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if len(sheet.Rules) != 1 {
+			t.Fatalf("%s: expected 1 rule, got %d", tt.query, len(sheet.Rules))
+		}
+		if got := tt.ctx.Matches(sheet.Rules[0].Media); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestForMedia(t *testing.T) {
+	sheet, err := Parse(`p { color: black; }
+@media (prefers-color-scheme: dark) {
+  p { color: white; }
+  @font-face { font-family: x; }
+  a { color: cyan; }
+}
+h1 { margin: 0; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if len(sheet.Rules) != 4 {
+		t.Fatalf("expected 4 rules, got %d", len(sheet.Rules))
+	}
+	if !strings.Contains(sheet.Dump(), "@media (prefers-color-scheme: dark) {\np {") {
+		t.Errorf("expected the dump to group the @media rules, got:\n%s", sheet.Dump())
+	}
+
+	light := sheet.ForMedia(DefaultMediaContext())
+	if len(light.Rules) != 2 {
+		t.Errorf("expected 2 rules in light mode, got %d", len(light.Rules))
+	}
+	ctx := DefaultMediaContext()
+	ctx.ColorScheme = "dark"
+	dark := sheet.ForMedia(ctx)
+	if len(dark.Rules) != 4 || dark.Rules[1].Media != nil {
+		t.Errorf("expected 4 unconditional rules in dark mode, got %+v", dark.Rules)
+	}
+	if plain := light.ForMedia(ctx); plain != light {
+		t.Error("expected a stylesheet without @media rules to be returned as is")
+	}
+
+	if err := ctx.SetFeature("prefers-color-scheme", "blue"); err == nil {
+		t.Error("expected an error for an invalid color scheme")
+	}
+}
@@ -0,0 +1,134 @@
+package css
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Nth is a structural pseudo-class, which matches an element by where it
+// is among its siblings. It matches the elements whose position, counted
+// from 1, is A*n+B for some n >= 0, as :nth-child(An+B) does; :first-child
+// is :nth-child(1). FromEnd counts from the last sibling, and OfType counts
+// only the siblings of the element's type. Only matches an element that is
+// both the first and the last, as :only-child does.
+type Nth struct {
+	A, B    int
+	FromEnd bool
+	OfType  bool
+	Only    bool
+}
+
+// nthNames are the structural pseudo-classes without arguments
+var nthNames = map[string]Nth{
+	"first-child":   {B: 1},
+	"last-child":    {B: 1, FromEnd: true},
+	"only-child":    {B: 1, Only: true},
+	"first-of-type": {B: 1, OfType: true},
+	"last-of-type":  {B: 1, FromEnd: true, OfType: true},
+	"only-of-type":  {B: 1, OfType: true, Only: true},
+}
+
+// nthFunctions are the structural pseudo-classes taking an An+B argument
+var nthFunctions = map[string]Nth{
+	"nth-child()":        {},
+	"nth-last-child()":   {FromEnd: true},
+	"nth-of-type()":      {OfType: true},
+	"nth-last-of-type()": {FromEnd: true, OfType: true},
+}
+
+// Matches reports whether an element at a position among its siblings,
+// counted from 1, matches. Positions are counted from the end for an Nth
+// FromEnd.
+func (n Nth) Matches(pos int) bool {
+	if n.A == 0 {
+		return pos == n.B
+	}
+	d := pos - n.B
+	return d%n.A == 0 && d/n.A >= 0
+}
+
+func (n Nth) String() string {
+	kind := "child"
+	if n.OfType {
+		kind = "of-type"
+	}
+	switch {
+	case n.Only:
+		return "only-" + kind
+	case n.A == 0 && n.B == 1 && n.FromEnd:
+		return "last-" + kind
+	case n.A == 0 && n.B == 1:
+		return "first-" + kind
+	}
+	name := "nth-"
+	if n.FromEnd {
+		name += "last-"
+	}
+	return name + kind + "(" + formatAnB(n.A, n.B) + ")"
+}
+
+// formatAnB formats An+B the shortest way, as in "2n+1", "-n+3" or "4"
+func formatAnB(a, b int) string {
+	var s string
+	switch a {
+	case 0:
+		return strconv.Itoa(b)
+	case 1:
+		s = "n"
+	case -1:
+		s = "-n"
+	default:
+		s = strconv.Itoa(a) + "n"
+	}
+	switch {
+	case b > 0:
+		s += "+" + strconv.Itoa(b)
+	case b < 0:
+		s += strconv.Itoa(b)
+	}
+	return s
+}
+
+// parseAnB parses the argument of a structural pseudo-class: odd, even, an
+// integer, or An+B where A may be left out or be a sign alone. The "of S"
+// form of :nth-child isn't supported.
+func parseAnB(args []Token) (a, b int, ok bool) {
+	var sb strings.Builder
+	for _, tok := range args {
+		sb.WriteString(tok.Value)
+		sb.WriteString(tok.Unit)
+	}
+	s := strings.ToLower(sb.String())
+	switch s {
+	case "odd":
+		return 2, 1, true
+	case "even":
+		return 2, 0, true
+	}
+	coef, rest, hasN := strings.Cut(s, "n")
+	if !hasN {
+		b, err := strconv.Atoi(s)
+		return 0, b, err == nil
+	}
+	switch coef {
+	case "", "+":
+		a = 1
+	case "-":
+		a = -1
+	default:
+		n, err := strconv.Atoi(coef)
+		if err != nil {
+			return 0, 0, false
+		}
+		a = n
+	}
+	if rest == "" {
+		return a, 0, true
+	}
+	// B needs its sign
+	if rest[0] != '+' && rest[0] != '-' {
+		return 0, 0, false
+	}
+	b, err := strconv.Atoi(rest)
+	return a, b, err == nil
+}
@@ -0,0 +1,66 @@
+package css
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseNth(t *testing.T) {
+	sheet, err := Parse(`li:first-child, li:last-of-type, p:only-child, tr:nth-child(odd), tr:nth-child( 2n + 1 ), ` +
+		`li:nth-last-child(-n+3), td:nth-of-type(3), li:nth-child(n), li:nth-child(2 n), li:nth-child(x) { color: red; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sels := sheet.Rules[0].Selectors
+	want := []struct {
+		nth  Nth
+		text string
+	}{
+		{Nth{B: 1}, "li:first-child"},
+		{Nth{B: 1, FromEnd: true, OfType: true}, "li:last-of-type"},
+		{Nth{B: 1, Only: true}, "p:only-child"},
+		{Nth{A: 2, B: 1}, "tr:nth-child(2n+1)"},
+		{Nth{A: 2, B: 1}, "tr:nth-child(2n+1)"},
+		{Nth{A: -1, B: 3, FromEnd: true}, "li:nth-last-child(-n+3)"},
+		{Nth{B: 3, OfType: true}, "td:nth-of-type(3)"},
+		{Nth{A: 1}, "li:nth-child(n)"},
+	}
+	// "2 n" reads as 2n, but an argument that isn't An+B drops its selector
+	if len(sels) != len(want)+1 {
+		t.Fatalf("expected %d selectors, got %d", len(want)+1, len(sels))
+	}
+	for i, w := range want {
+		if !slices.Equal(sels[i].Nths, []Nth{w.nth}) || len(sels[i].PseudoClasses) != 0 {
+			t.Errorf("selector %d: expected %+v, got %+v", i, w.nth, sels[i])
+		}
+		if got := sels[i].String(); got != w.text {
+			t.Errorf("selector %d: expected %s, got %s", i, w.text, got)
+		}
+	}
+}
+
+func TestNthMatches(t *testing.T) {
+	tests := []struct {
+		nth  Nth
+		want []int // the positions up to 7 it matches
+	}{
+		{Nth{B: 1}, []int{1}},
+		{Nth{A: 2, B: 1}, []int{1, 3, 5, 7}},
+		{Nth{A: 2}, []int{2, 4, 6}},
+		{Nth{A: 3, B: -1}, []int{2, 5}},
+		{Nth{A: -1, B: 3}, []int{1, 2, 3}},
+		{Nth{A: 1, B: 5}, []int{5, 6, 7}},
+		{Nth{B: -1}, nil},
+	}
+	for _, tt := range tests {
+		var got []int
+		for pos := 1; pos <= 7; pos++ {
+			if tt.nth.Matches(pos) {
+				got = append(got, pos)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%v: expected %v, got %v", tt.nth, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package css
+
+// Origin is where a rule comes from. The cascade sorts declarations by
+// origin before anything else: rules of the page override those of the
+// user, which override those of the user agent.
+type Origin uint8
+
+const (
+	// OriginAuthor is the page's own stylesheets and style attributes. It
+	// is the zero value, so parsed rules are author rules unless told
+	// otherwise.
+	OriginAuthor Origin = iota
+	// OriginUser is stylesheets the user adds to every page
+	OriginUser
+	// OriginUserAgent is the default stylesheet of penny itself
+	OriginUserAgent
+)
+
+func (o Origin) String() string {
+	switch o {
+	case OriginAuthor:
+		return "author"
+	case OriginUser:
+		return "user"
+	case OriginUserAgent:
+		return "user-agent"
+	default:
+		return "unknown"
+	}
+}
+
+// Precedence ranks origins in cascade order: rules of an origin with a
+// higher precedence override those of a lower one
+func (o Origin) Precedence() int {
+	switch o {
+	case OriginUserAgent:
+		return 0
+	case OriginUser:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// SetOrigin marks every rule of the stylesheet as coming from origin
+func (s *Stylesheet) SetOrigin(origin Origin) {
+	for i := range s.Rules {
+		s.Rules[i].Origin = origin
+	}
+}
+
+// IsRevert reports whether a declaration's value is the revert keyword,
+// which rolls a property back to the value the preceding origins gave it
+func IsRevert(decl Declaration) bool {
+	return len(decl.Values) == 1 && decl.Values[0].Type == TokenIdent && decl.Values[0].Value == "revert"
+}
+
+// Revert applies a revert declaration: the property takes its value in
+// before, the style as the origins preceding the declaration's left it. It
+// reports whether the property is supported.
+func Revert(style, before *Style, decl Declaration) bool {
+	name := decl.Property
+	if physical, ok := physicalProperty(name, style.WritingMode, style.Direction); ok {
+		name = physical
+	}
+	id, ok := propertyIDs[name]
+	if !ok {
+		return false
+	}
+	setValue(style, id, getValue(before, id))
+	return true
+}
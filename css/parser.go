@@ -1,67 +1,362 @@
 package css
 
 import (
+	"cmp"
+	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/myuon/penny/intern"
 )
 
-type SelectorType int
+// Selector is a complex selector such as "ul > li.item a:hover". Its own
+// fields describe the compound that matches the element being styled, the
+// subject; Context holds the compounds that must match around it.
+type Selector struct {
+	// Tag is the type selector of the compound, "*" for the universal
+	// selector, or empty if it has none. Either way the compound matches
+	// elements of any type.
+	Tag     string
+	ID      string
+	Classes []string
+	Attrs   []AttrSelector
+	// PseudoClasses are the names of the pseudo-classes the selector is
+	// qualified with, such as "hover". Functional ones are recorded with
+	// parentheses, as in "dir()". Structural ones are in Nths, and the
+	// logical :is(), :where() and :not() in Lists instead.
+	PseudoClasses []string
+	Nths          []Nth
+	Lists         []SelectorList
+	PseudoElement string // such as "before" for ::before
+	// Context lists the other compounds of the selector from the subject
+	// outwards, each with the combinator joining it to the one before. For
+	// "ul > li a" it is li as an ancestor, then ul as li's parent.
+	Context []Relative
+}
+
+// empty reports whether the compound has no simple selectors at all, as
+// the parser returns for an invalid selector
+func (sel Selector) empty() bool {
+	return sel.Tag == "" && sel.ID == "" && len(sel.Classes) == 0 && len(sel.Attrs) == 0 &&
+		len(sel.PseudoClasses) == 0 && len(sel.Nths) == 0 && len(sel.Lists) == 0 && sel.PseudoElement == ""
+}
+
+// AttrSelector is an attribute selector of a compound, such as [type] or
+// [href^="https:"]
+type AttrSelector struct {
+	Name  string
+	Op    AttrOp
+	Value string
+}
+
+// AttrOp is how an attribute selector compares the value of the attribute
+type AttrOp uint8
 
 const (
-	SelectorTag SelectorType = iota
-	SelectorClass
-	SelectorID
+	AttrExists    AttrOp = iota // [attr]
+	AttrEquals                  // [attr=value]
+	AttrIncludes                // [attr~=value], one of its words
+	AttrDashMatch               // [attr|=value], the value or a prefix of it and "-"
+	AttrPrefix                  // [attr^=value]
+	AttrSuffix                  // [attr$=value]
+	AttrSubstring               // [attr*=value]
 )
 
-type Selector struct {
-	Type  SelectorType
-	Value string
+var attrOpNames = [...]string{
+	AttrEquals:    "=",
+	AttrIncludes:  "~=",
+	AttrDashMatch: "|=",
+	AttrPrefix:    "^=",
+	AttrSuffix:    "$=",
+	AttrSubstring: "*=",
+}
+
+// Matches reports whether the value of an attribute, which is set, matches
+func (a AttrSelector) Matches(value string) bool {
+	switch a.Op {
+	case AttrEquals:
+		return value == a.Value
+	case AttrIncludes:
+		return a.Value != "" && !strings.ContainsAny(a.Value, " \t\n\r\f") && slices.Contains(strings.Fields(value), a.Value)
+	case AttrDashMatch:
+		return value == a.Value || strings.HasPrefix(value, a.Value+"-")
+	case AttrPrefix:
+		return a.Value != "" && strings.HasPrefix(value, a.Value)
+	case AttrSuffix:
+		return a.Value != "" && strings.HasSuffix(value, a.Value)
+	case AttrSubstring:
+		return a.Value != "" && strings.Contains(value, a.Value)
+	}
+	return true
+}
+
+func (a AttrSelector) String() string {
+	if a.Op == AttrExists {
+		return "[" + a.Name + "]"
+	}
+	return "[" + a.Name + attrOpNames[a.Op] + strconv.Quote(a.Value) + "]"
+}
+
+// Combinator is how two compounds of a selector relate
+type Combinator uint8
+
+const (
+	CombinatorDescendant        Combinator = iota // "ul li"
+	CombinatorChild                               // "ul > li"
+	CombinatorNextSibling                         // "h1 + p"
+	CombinatorSubsequentSibling                   // "h1 ~ p"
+)
+
+// Relative is a compound of a selector's context. Its Selector has no
+// Context of its own.
+type Relative struct {
+	Combinator Combinator
+	Selector   Selector
 }
 
 type Declaration struct {
-	Property string
-	Value    string
-	Values   []Token // parsed tokens for complex values
+	Property  string
+	Value     string
+	Values    []Token // parsed tokens for complex values
+	Important bool    // declared !important
+	Pos       Position
 }
 
 type Rule struct {
 	Selectors    []Selector
 	Declarations []Declaration
+	Origin       Origin
+	Pos          Position // of the first selector
+	// Media is the query list of the @media block the rule is in, or nil
+	// if it is in none
+	Media []MediaQuery
 }
 
 type Stylesheet struct {
-	Rules []Rule
+	Rules     []Rule
+	Keyframes map[string]*Keyframes // @keyframes rules by name
+	Unparsed  []string              // properties of declarations that could not be parsed
+	Errors    []ParseError          // where and why declarations were skipped
+	// Imports are the @import rules, which the stylesheet's loader fetches
+	Imports []Import
 }
 
 type Parser struct {
-	lexer *Lexer
-	cur   Token
+	lexer     *Lexer
+	cur       Token
+	offset    int  // of cur in the input
+	spaced    bool // whitespace came before cur
+	positions positions
+	unparsed  []string
+	errors    []ParseError
+	// pastImports is set once a rule other than @import is read, after
+	// which an @import is ignored
+	pastImports bool
 }
 
 func Parse(input string) (*Stylesheet, error) {
+	return ParseSource(input, "")
+}
+
+// ParseSource parses a stylesheet read from source, a file path or URL,
+// which the positions of its rules and declarations refer to
+func ParseSource(input, source string) (*Stylesheet, error) {
+	parser := newParser(input, source)
+	return parser.parse(), nil
+}
+
+// ParseDeclarations parses a list of declarations without a selector or
+// braces, such as the value of a style attribute
+func ParseDeclarations(input string) []Declaration {
+	return newParser(input, "").declarations()
+}
+
+func newParser(input, source string) *Parser {
 	parser := &Parser{
-		lexer: NewLexer(input),
+		lexer:     NewLexer(input),
+		positions: newPositions(source, input),
 	}
 	parser.advance()
-	return parser.parse(), nil
+	return parser
 }
 
 func (p *Parser) advance() {
 	p.cur = p.lexer.NextToken()
+	p.offset = p.lexer.start
+	p.spaced = p.lexer.spaced
+}
+
+// pos returns the position of the current token
+func (p *Parser) pos() Position {
+	return p.positions.advance(p.offset)
 }
 
 func (p *Parser) parse() *Stylesheet {
-	var rules []Rule
+	sheet := &Stylesheet{}
 	for p.cur.Type != TokenEOF {
+		if p.cur.Type == TokenAtKeyword {
+			p.atRule(sheet)
+			continue
+		}
+		p.pastImports = true
+		rule := p.rule()
+		if len(rule.Selectors) > 0 {
+			sheet.Rules = append(sheet.Rules, rule)
+		}
+	}
+	sheet.Unparsed = p.unparsed
+	sheet.Errors = p.errors
+	return sheet
+}
+
+// atRule parses an at-rule. Only @import, @keyframes and @media are
+// understood; other at-rules are skipped along with their block.
+func (p *Parser) atRule(sheet *Stylesheet) {
+	name := p.cur.Value
+	pos := p.pos()
+	p.advance() // consume the at-keyword
+
+	if name == "import" {
+		p.importRule(sheet, pos)
+		return
+	}
+	if name != "charset" {
+		p.pastImports = true
+	}
+
+	if (name == "keyframes" || name == "-webkit-keyframes") && (p.cur.Type == TokenIdent || p.cur.Type == TokenString) {
+		kf := &Keyframes{Name: intern.String(p.cur.Value)}
+		p.advance()
+		if p.cur.Type == TokenLBrace {
+			p.advance() // consume '{'
+			kf.Frames = p.keyframes()
+			if p.cur.Type == TokenRBrace {
+				p.advance() // consume '}'
+			}
+			if sheet.Keyframes == nil {
+				sheet.Keyframes = make(map[string]*Keyframes)
+			}
+			// A later @keyframes with the same name replaces the earlier one
+			sheet.Keyframes[kf.Name] = kf
+			return
+		}
+	}
+	if name == "media" {
+		p.media(sheet)
+		return
+	}
+
+	p.skipAtRule()
+}
+
+// media parses a @media rule, adding the rules in its block to sheet with
+// its query list. At-rules nested in the block are skipped.
+func (p *Parser) media(sheet *Stylesheet) {
+	var prelude []Token
+	for p.cur.Type != TokenLBrace && p.cur.Type != TokenSemicolon && p.cur.Type != TokenEOF {
+		prelude = append(prelude, p.cur)
+		p.advance()
+	}
+	if p.cur.Type != TokenLBrace {
+		p.skipAtRule()
+		return
+	}
+	p.advance() // consume '{'
+
+	queries := parseMediaQueries(prelude)
+	for p.cur.Type != TokenRBrace && p.cur.Type != TokenEOF {
+		if p.cur.Type == TokenAtKeyword {
+			p.advance()
+			p.skipAtRule()
+			continue
+		}
 		rule := p.rule()
 		if len(rule.Selectors) > 0 {
-			rules = append(rules, rule)
+			rule.Media = queries
+			sheet.Rules = append(sheet.Rules, rule)
+		}
+	}
+	if p.cur.Type == TokenRBrace {
+		p.advance() // consume '}'
+	}
+}
+
+// skipAtRule skips to the end of an at-rule: its semicolon, or the end of
+// its block
+func (p *Parser) skipAtRule() {
+	depth := 0
+	for p.cur.Type != TokenEOF {
+		switch p.cur.Type {
+		case TokenSemicolon:
+			if depth == 0 {
+				p.advance()
+				return
+			}
+		case TokenLBrace:
+			depth++
+		case TokenRBrace:
+			depth--
+			if depth <= 0 {
+				p.advance()
+				return
+			}
 		}
+		p.advance()
+	}
+}
+
+// keyframes parses the keyframe blocks of a @keyframes rule, sorted by
+// offset. A block listing several selectors becomes a keyframe per offset.
+func (p *Parser) keyframes() []Keyframe {
+	var frames []Keyframe
+	for p.cur.Type != TokenRBrace && p.cur.Type != TokenEOF {
+		var offsets []float32
+		for p.cur.Type != TokenLBrace && p.cur.Type != TokenRBrace && p.cur.Type != TokenEOF {
+			if offset, ok := keyframeOffset(p.cur); ok {
+				offsets = append(offsets, offset)
+			}
+			p.advance()
+		}
+		if p.cur.Type != TokenLBrace {
+			break
+		}
+		p.advance() // consume '{'
+		decls := p.declarations()
+		if p.cur.Type == TokenRBrace {
+			p.advance() // consume '}'
+		}
+
+		frame := newKeyframe(decls)
+		for _, offset := range offsets {
+			frame.Offset = offset
+			frames = append(frames, frame)
+		}
+	}
+	slices.SortStableFunc(frames, func(a, b Keyframe) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+	return frames
+}
+
+// keyframeOffset returns the offset a keyframe selector stands for
+func keyframeOffset(tok Token) (float32, bool) {
+	switch {
+	case tok.Type == TokenIdent && tok.Value == "from":
+		return 0, true
+	case tok.Type == TokenIdent && tok.Value == "to":
+		return 1, true
+	case tok.Type == TokenPercentage:
+		v, err := strconv.ParseFloat(tok.Value, 32)
+		if err != nil || v < 0 || v > 100 {
+			return 0, false
+		}
+		return float32(v / 100), true
 	}
-	return &Stylesheet{Rules: rules}
+	return 0, false
 }
 
 func (p *Parser) rule() Rule {
+	pos := p.pos()
 	selectors := p.selectors()
 
 	if p.cur.Type != TokenLBrace {
@@ -84,6 +379,7 @@ func (p *Parser) rule() Rule {
 	return Rule{
 		Selectors:    selectors,
 		Declarations: declarations,
+		Pos:          pos,
 	}
 }
 
@@ -92,8 +388,14 @@ func (p *Parser) selectors() []Selector {
 
 	for {
 		sel := p.selector()
-		if sel.Value != "" {
+		if !sel.empty() {
 			selectors = append(selectors, sel)
+		} else {
+			// The rest of an invalid selector is skipped, keeping the
+			// others of the list
+			for p.cur.Type != TokenComma && p.cur.Type != TokenLBrace && p.cur.Type != TokenRBrace && p.cur.Type != TokenEOF {
+				p.advance()
+			}
 		}
 
 		if p.cur.Type == TokenComma {
@@ -106,25 +408,227 @@ func (p *Parser) selectors() []Selector {
 	return selectors
 }
 
+// selector parses a complex selector. It returns an empty selector if the
+// selector is invalid.
 func (p *Parser) selector() Selector {
+	sel := p.compound()
+	for !sel.empty() {
+		combinator, ok := p.combinator()
+		if !ok {
+			break
+		}
+		next := p.compound()
+		// A pseudo-element can only be on the subject
+		if next.empty() || sel.PseudoElement != "" {
+			return Selector{}
+		}
+		// The compound just parsed becomes the subject, with the one
+		// before it first in its context
+		context := sel.Context
+		sel.Context = nil
+		next.Context = append([]Relative{{Combinator: combinator, Selector: sel}}, context...)
+		sel = next
+	}
+	return sel
+}
+
+// combinator consumes the combinator between two compounds, if there is
+// one. Whitespace alone is a descendant combinator.
+func (p *Parser) combinator() (Combinator, bool) {
+	if p.cur.Type == TokenDelim {
+		var combinator Combinator
+		switch p.cur.Value {
+		case ">":
+			combinator = CombinatorChild
+		case "+":
+			combinator = CombinatorNextSibling
+		case "~":
+			combinator = CombinatorSubsequentSibling
+		case "*":
+			// The universal selector of the next compound
+			return CombinatorDescendant, p.spaced
+		default:
+			return 0, false
+		}
+		p.advance()
+		return combinator, true
+	}
+	if !p.spaced {
+		return 0, false
+	}
+	switch p.cur.Type {
+	case TokenIdent, TokenDot, TokenHash, TokenColon, TokenLBracket:
+		return CombinatorDescendant, true
+	}
+	return 0, false
+}
+
+// compound parses a compound selector: a type or universal selector, then
+// any IDs, classes, attribute selectors, pseudo-classes and pseudo-element,
+// with no whitespace between them
+func (p *Parser) compound() Selector {
+	var sel Selector
+	for first := true; first || !p.spaced; first = false {
+		switch p.cur.Type {
+		case TokenIdent:
+			if !first {
+				return Selector{}
+			}
+			sel.Tag = intern.String(p.cur.Value)
+			p.advance()
+		case TokenDelim:
+			if p.cur.Value != "*" || !first {
+				return sel
+			}
+			sel.Tag = "*"
+			p.advance()
+		case TokenDot:
+			p.advance() // consume '.'
+			if p.cur.Type != TokenIdent || p.spaced {
+				return Selector{}
+			}
+			sel.Classes = append(sel.Classes, intern.String(p.cur.Value))
+			p.advance()
+		case TokenHash:
+			// An element has one ID, so "#a#b" can never match; penny
+			// drops it like an invalid selector
+			if sel.ID != "" && sel.ID != p.cur.Value {
+				return Selector{}
+			}
+			sel.ID = intern.String(p.cur.Value)
+			p.advance()
+		case TokenLBracket:
+			attr, ok := p.attr()
+			if !ok {
+				return Selector{}
+			}
+			sel.Attrs = append(sel.Attrs, attr)
+		case TokenColon:
+			if !p.pseudo(&sel) {
+				return Selector{}
+			}
+		default:
+			return sel
+		}
+	}
+	return sel
+}
+
+// attr parses an attribute selector: a name, then optionally an operator
+// and an identifier or string to compare the value with, in brackets.
+// Whitespace is allowed inside them, but not within the operator. Names are
+// matched in lower case, as HTML attribute names are. It reports false for
+// an invalid attribute selector.
+func (p *Parser) attr() (AttrSelector, bool) {
+	p.advance() // consume '['
+	var attr AttrSelector
+	if p.cur.Type != TokenIdent {
+		return attr, false
+	}
+	attr.Name = intern.String(strings.ToLower(p.cur.Value))
+	p.advance()
+
+	if p.cur.Type == TokenDelim {
+		// The operator is "=", or a character and "=" with no space between
+		op := p.cur.Value
+		if op != "=" {
+			p.advance()
+			if p.cur.Type != TokenDelim || p.cur.Value != "=" || p.spaced {
+				return attr, false
+			}
+			op += "="
+		}
+		i := slices.Index(attrOpNames[:], op)
+		if i < 0 {
+			return attr, false
+		}
+		attr.Op = AttrOp(i)
+		p.advance() // consume '='
+		if p.cur.Type != TokenIdent && p.cur.Type != TokenString {
+			return attr, false
+		}
+		attr.Value = p.cur.Value
+		p.advance()
+	}
+
+	if p.cur.Type != TokenRBracket {
+		return attr, false
+	}
+	p.advance() // consume ']'
+	return attr, true
+}
+
+// pseudo parses a pseudo-class or pseudo-element of sel. It reports false
+// if there is no name after the colons.
+func (p *Parser) pseudo(sel *Selector) bool {
+	p.advance() // consume ':'
+	element := p.cur.Type == TokenColon
+	if element {
+		p.advance() // consume the second ':'
+	}
+
+	var name string
+	var args []Token
 	switch p.cur.Type {
 	case TokenIdent:
-		value := p.cur.Value
+		name = intern.String(p.cur.Value)
 		p.advance()
-		return Selector{Type: SelectorTag, Value: value}
-	case TokenDot:
-		p.advance() // consume '.'
-		if p.cur.Type == TokenIdent {
-			value := p.cur.Value
+	case TokenFunction:
+		name = intern.String(p.cur.Value + "()")
+		p.advance()
+		if kind, ok := listFunctions[name]; ok && !element {
+			list, ok := p.selectorList(kind)
+			sel.Lists = append(sel.Lists, list)
+			return ok
+		}
+		// The arguments run to the parenthesis closing the function, past
+		// those of any functions nested in them
+		for depth := 0; p.cur.Type != TokenLBrace && p.cur.Type != TokenEOF; p.advance() {
+			if p.cur.Type == TokenRParen {
+				if depth == 0 {
+					break
+				}
+				depth--
+			} else if p.cur.Type == TokenFunction {
+				depth++
+			}
+			args = append(args, p.cur)
+		}
+		if p.cur.Type == TokenRParen {
 			p.advance()
-			return Selector{Type: SelectorClass, Value: value}
 		}
-	case TokenHash:
-		value := p.cur.Value
-		p.advance()
-		return Selector{Type: SelectorID, Value: value}
+	default:
+		return false
 	}
-	return Selector{}
+
+	// Structural pseudo-classes are kept apart, with their arguments; those
+	// of the others aren't interpreted
+	if nth, ok := nthNames[name]; ok && !element {
+		sel.Nths = append(sel.Nths, nth)
+		return true
+	}
+	if nth, ok := nthFunctions[name]; ok && !element {
+		if nth.A, nth.B, ok = parseAnB(args); !ok {
+			return false
+		}
+		sel.Nths = append(sel.Nths, nth)
+		return true
+	}
+	if element || legacyPseudoElements[name] {
+		sel.PseudoElement = name
+	} else {
+		sel.PseudoClasses = append(sel.PseudoClasses, name)
+	}
+	return true
+}
+
+// legacyPseudoElements are the pseudo-elements of CSS 2, which may be
+// written with a single colon
+var legacyPseudoElements = map[string]bool{
+	"before":       true,
+	"after":        true,
+	"first-letter": true,
+	"first-line":   true,
 }
 
 func (p *Parser) declarations() []Declaration {
@@ -146,27 +650,20 @@ func (p *Parser) declaration() Declaration {
 		return Declaration{}
 	}
 
-	property := p.cur.Value
+	pos := p.pos()
+	property := intern.String(p.cur.Value)
 	p.advance()
 
 	if p.cur.Type != TokenColon {
+		p.skip(pos, property, "expected ':' after "+property)
 		return Declaration{}
 	}
 	p.advance() // consume ':'
 
 	// Collect value tokens until semicolon or closing brace
 	var values []Token
-	var valueStr strings.Builder
-
 	for p.cur.Type != TokenSemicolon && p.cur.Type != TokenRBrace && p.cur.Type != TokenEOF {
 		values = append(values, p.cur)
-		if valueStr.Len() > 0 {
-			valueStr.WriteString(" ")
-		}
-		valueStr.WriteString(p.cur.Value)
-		if p.cur.Unit != "" {
-			valueStr.WriteString(p.cur.Unit)
-		}
 		p.advance()
 	}
 
@@ -174,186 +671,70 @@ func (p *Parser) declaration() Declaration {
 		p.advance() // consume ';'
 	}
 
-	return Declaration{
-		Property: property,
-		Value:    valueStr.String(),
-		Values:   values,
+	important := false
+	if n := len(values); n >= 2 && values[n-2].Type == TokenBang &&
+		values[n-1].Type == TokenIdent && strings.EqualFold(values[n-1].Value, "important") {
+		values, important = values[:n-2], true
 	}
-}
-
-// ApplyDeclaration applies a CSS declaration to a Style
-func ApplyDeclaration(style *Style, decl Declaration) {
-	switch decl.Property {
-	case "display":
-		switch decl.Value {
-		case "block":
-			style.Display = DisplayBlock
-		case "inline":
-			style.Display = DisplayInline
-		case "none":
-			style.Display = DisplayNone
-		case "flex":
-			style.Display = DisplayFlex
-		}
-
-	case "width":
-		if v := parseLength(decl.Values); v != nil {
-			style.Width = v
-		}
-	case "height":
-		if v := parseLength(decl.Values); v != nil {
-			style.Height = v
-		}
-
-	case "margin":
-		style.Margin = parseEdges(decl.Values)
-	case "margin-top":
-		if v := parseLength(decl.Values); v != nil {
-			style.Margin.Top = *v
-		}
-	case "margin-right":
-		if v := parseLength(decl.Values); v != nil {
-			style.Margin.Right = *v
-		}
-	case "margin-bottom":
-		if v := parseLength(decl.Values); v != nil {
-			style.Margin.Bottom = *v
-		}
-	case "margin-left":
-		if v := parseLength(decl.Values); v != nil {
-			style.Margin.Left = *v
-		}
 
-	case "padding":
-		style.Padding = parseEdges(decl.Values)
-	case "padding-top":
-		if v := parseLength(decl.Values); v != nil {
-			style.Padding.Top = *v
-		}
-	case "padding-right":
-		if v := parseLength(decl.Values); v != nil {
-			style.Padding.Right = *v
-		}
-	case "padding-bottom":
-		if v := parseLength(decl.Values); v != nil {
-			style.Padding.Bottom = *v
-		}
-	case "padding-left":
-		if v := parseLength(decl.Values); v != nil {
-			style.Padding.Left = *v
-		}
-
-	case "font-size":
-		if v := parseLength(decl.Values); v != nil {
-			style.FontSize = *v
-		}
-
-	case "color":
-		if c := parseColor(decl); c != nil {
-			style.Color = *c
-		}
-
-	case "background", "background-color":
-		if c := parseColor(decl); c != nil {
-			style.Background = *c
-		}
-
-	case "border-width":
-		style.Border = parseEdges(decl.Values)
-
-	case "border-color":
-		if c := parseColor(decl); c != nil {
-			style.BorderColor = *c
-		}
+	if len(values) == 0 {
+		p.skip(pos, property, "missing value for "+property)
+		return Declaration{}
+	}
 
-	case "flex-grow":
-		if len(decl.Values) > 0 && decl.Values[0].Type == TokenNumber {
-			if v, err := strconv.ParseFloat(decl.Values[0].Value, 32); err == nil {
-				style.FlexGrow = float32(v)
-			}
-		}
+	return Declaration{
+		Property:  property,
+		Value:     tokensString(values),
+		Values:    values,
+		Important: important,
+		Pos:       pos,
+	}
+}
 
-	case "justify-content":
-		switch decl.Value {
-		case "flex-start":
-			style.JustifyContent = JustifyFlexStart
-		case "flex-end":
-			style.JustifyContent = JustifyFlexEnd
-		case "center":
-			style.JustifyContent = JustifyCenter
-		case "space-between":
-			style.JustifyContent = JustifySpaceBetween
-		case "space-around":
-			style.JustifyContent = JustifySpaceAround
-		}
+// skip records a declaration of property at pos that could not be parsed
+func (p *Parser) skip(pos Position, property, message string) {
+	p.unparsed = append(p.unparsed, property)
+	p.errors = append(p.errors, ParseError{Pos: pos, Message: message})
+}
 
-	case "align-items":
-		switch decl.Value {
-		case "flex-start":
-			style.AlignItems = AlignFlexStart
-		case "flex-end":
-			style.AlignItems = AlignFlexEnd
-		case "center":
-			style.AlignItems = AlignCenter
-		case "stretch":
-			style.AlignItems = AlignStretch
-		}
+// parseLength parses the length at the start of values. It reports false if
+// there is none. A relative length is resolved against DefaultUnits.
+func parseLength(values []Token) (float32, bool) {
+	v, unit, ok := parseLengthUnit(values)
+	if !ok || unit == UnitPercent {
+		// TODO: handle percentage properly
+		return v, ok
 	}
+	return DefaultUnits().Length(v, unit, 0, initialStyle.FontSize), true
 }
 
-func parseLength(values []Token) *float32 {
+// parseLengthUnit parses the length at the start of values, in pixels if it
+// is absolute and in its unit otherwise. An unknown unit is taken for
+// pixels. It reports false if there is no length.
+func parseLengthUnit(values []Token) (float32, Unit, bool) {
 	if len(values) == 0 {
-		return nil
+		return 0, UnitPx, false
 	}
 
 	tok := values[0]
-	var v float64
-	var err error
-
+	v, err := strconv.ParseFloat(tok.Value, 32)
+	unit, scale := UnitPx, float32(1)
 	switch tok.Type {
 	case TokenNumber:
-		v, err = strconv.ParseFloat(tok.Value, 32)
 	case TokenDimension:
-		v, err = strconv.ParseFloat(tok.Value, 32)
-		// For now, treat all units as pixels
-		// TODO: handle em, rem, etc.
+		if u, px, ok := lengthUnit(tok.Unit); ok {
+			unit, scale = u, px
+		}
 	case TokenPercentage:
-		// TODO: handle percentage properly
-		v, err = strconv.ParseFloat(tok.Value, 32)
+		unit = UnitPercent
 	default:
-		return nil
+		return 0, UnitPx, false
 	}
 
 	if err != nil {
-		return nil
-	}
-
-	f := float32(v)
-	return &f
-}
-
-func parseEdges(values []Token) Edges {
-	var lengths []float32
-	for _, tok := range values {
-		if tok.Type == TokenNumber || tok.Type == TokenDimension {
-			if v, err := strconv.ParseFloat(tok.Value, 32); err == nil {
-				lengths = append(lengths, float32(v))
-			}
-		}
-	}
-
-	switch len(lengths) {
-	case 1:
-		return Edges{lengths[0], lengths[0], lengths[0], lengths[0]}
-	case 2:
-		return Edges{lengths[0], lengths[1], lengths[0], lengths[1]}
-	case 3:
-		return Edges{lengths[0], lengths[1], lengths[2], lengths[1]}
-	case 4:
-		return Edges{lengths[0], lengths[1], lengths[2], lengths[3]}
-	default:
-		return Edges{}
+		return 0, UnitPx, false
 	}
+	return float32(v) * scale, unit, true
 }
 
 func parseColor(decl Declaration) *Color {
@@ -446,28 +827,116 @@ func parseRGBFunction(values []Token) *Color {
 	return nil
 }
 
+// Append adds the rules and @keyframes of other after those of s, as if
+// other's source followed s's
+func (s *Stylesheet) Append(other *Stylesheet) {
+	s.Rules = append(s.Rules, other.Rules...)
+	s.Unparsed = append(s.Unparsed, other.Unparsed...)
+	s.Errors = append(s.Errors, other.Errors...)
+	for name, kf := range other.Keyframes {
+		if s.Keyframes == nil {
+			s.Keyframes = make(map[string]*Keyframes)
+		}
+		s.Keyframes[name] = kf
+	}
+}
+
+// String formats the selector as CSS
+func (sel Selector) String() string {
+	var sb strings.Builder
+	for i := len(sel.Context) - 1; i >= 0; i-- {
+		rel := sel.Context[i]
+		sb.WriteString(rel.Selector.String())
+		sb.WriteString([...]string{" ", " > ", " + ", " ~ "}[rel.Combinator])
+	}
+	if sel.Tag == "" && sel.ID == "" && len(sel.Classes) == 0 && len(sel.Attrs) == 0 {
+		// Pseudo-classes and pseudo-elements alone qualify any element
+		sb.WriteString("*")
+	}
+	sb.WriteString(sel.Tag)
+	if sel.ID != "" {
+		sb.WriteString("#" + sel.ID)
+	}
+	for _, class := range sel.Classes {
+		sb.WriteString("." + class)
+	}
+	for _, attr := range sel.Attrs {
+		sb.WriteString(attr.String())
+	}
+	for _, pseudo := range sel.PseudoClasses {
+		sb.WriteString(":" + pseudo)
+	}
+	for _, nth := range sel.Nths {
+		sb.WriteString(":" + nth.String())
+	}
+	for _, list := range sel.Lists {
+		sb.WriteString(":" + list.String())
+	}
+	if sel.PseudoElement != "" {
+		sb.WriteString("::" + sel.PseudoElement)
+	}
+	return sb.String()
+}
+
 func (s *Stylesheet) Dump() string {
 	var result string
+	origin := OriginAuthor
+	media := ""
 	for _, rule := range s.Rules {
+		// Consecutive rules of the same @media block are dumped in one
+		if m := mediaList(rule.Media); m != media || rule.Origin != origin {
+			if media != "" {
+				result += "}\n"
+			}
+			media = m
+			if rule.Origin != origin {
+				result += "/* " + rule.Origin.String() + " origin */\n"
+				origin = rule.Origin
+			}
+			if media != "" {
+				result += "@media " + media + " {\n"
+			}
+		}
+		if rule.Pos.Source != "" {
+			result += "/* " + rule.Pos.Source + ":" + strconv.Itoa(rule.Pos.Line) + " */\n"
+		}
+
 		// Selectors
 		for i, sel := range rule.Selectors {
 			if i > 0 {
 				result += ", "
 			}
-			switch sel.Type {
-			case SelectorTag:
-				result += sel.Value
-			case SelectorClass:
-				result += "." + sel.Value
-			case SelectorID:
-				result += "#" + sel.Value
-			}
+			result += sel.String()
 		}
 		result += " {\n"
 
 		// Declarations
 		for _, decl := range rule.Declarations {
-			result += "  " + decl.Property + ": " + decl.Value + ";\n"
+			result += "  " + decl.Property + ": " + decl.Value
+			if decl.Important {
+				result += " !important"
+			}
+			result += ";\n"
+		}
+		result += "}\n"
+	}
+	if media != "" {
+		result += "}\n"
+	}
+
+	names := make([]string, 0, len(s.Keyframes))
+	for name := range s.Keyframes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		result += "@keyframes " + name + " {\n"
+		for _, frame := range s.Keyframes[name].Frames {
+			result += "  " + strconv.FormatFloat(float64(frame.Offset)*100, 'g', 4, 32) + "% {\n"
+			for _, decl := range frame.Declarations {
+				result += "    " + decl.Property + ": " + decl.Value + ";\n"
+			}
+			result += "  }\n"
 		}
 		result += "}\n"
 	}
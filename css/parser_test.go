@@ -0,0 +1,200 @@
+package css
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParsePseudoClasses(t *testing.T) {
+	sheet, err := Parse(`a:hover:focus, p::before, li:not(.x), #y { color: red; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sels := sheet.Rules[0].Selectors
+	if len(sels) != 4 {
+		t.Fatalf("expected 4 selectors, got %+v", sels)
+	}
+	if sels[0].Tag != "a" || !slices.Equal(sels[0].PseudoClasses, []string{"hover", "focus"}) {
+		t.Errorf("expected a:hover:focus, got %+v", sels[0])
+	}
+	if sels[1].PseudoElement != "before" {
+		t.Errorf("expected p::before, got %+v", sels[1])
+	}
+	if len(sels[2].Lists) != 1 || sels[2].Lists[0].Kind != ListNot || sels[2].PseudoClasses != nil {
+		t.Errorf("expected li:not(.x), got %+v", sels[2])
+	}
+	if sels[3].ID != "y" || sels[3].PseudoClasses != nil {
+		t.Errorf("expected #y, got %+v", sels[3])
+	}
+	if want := "a:hover:focus, p::before, li:not(.x), #y {\n  color: red;\n}\n"; sheet.Dump() != want {
+		t.Errorf("expected dump %q, got %q", want, sheet.Dump())
+	}
+}
+
+func TestParseDeclarations(t *testing.T) {
+	decls := ParseDeclarations("color: red; margin: 1px 2px ; width:")
+	if len(decls) != 2 || decls[0].Property != "color" || decls[1].Value != "1px 2px" {
+		t.Errorf("expected color and margin, got %+v", decls)
+	}
+}
+
+func TestDumpMarksOrigins(t *testing.T) {
+	sheet, _ := Parse("p { color: red; }")
+	sheet.SetOrigin(OriginUserAgent)
+	author, _ := Parse("a { color: blue; }")
+	sheet.Append(author)
+
+	want := "/* user-agent origin */\np {\n  color: red;\n}\n/* author origin */\na {\n  color: blue;\n}\n"
+	if got := sheet.Dump(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestSourcePositions(t *testing.T) {
+	sheet, err := ParseSource("p { color: red; }\n\n  .x,\n  a {\n    margin: 0 1px;\n    width;\n  }\n", "style.css")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if got := sheet.Rules[1].Pos.String(); got != "style.css:3:3" {
+		t.Errorf("expected the second rule at style.css:3:3, got %s", got)
+	}
+	if got := sheet.Rules[1].Declarations[0].Pos.String(); got != "style.css:5:5" {
+		t.Errorf("expected the margin at style.css:5:5, got %s", got)
+	}
+	// Longhands keep the position of their shorthand
+	for _, decl := range ExpandDeclarations(sheet.Rules[1].Declarations) {
+		if decl.Pos.Line != 5 {
+			t.Errorf("expected %s at line 5, got %s", decl.Property, decl.Pos)
+		}
+	}
+	if len(sheet.Errors) != 1 || sheet.Errors[0].Error() != "style.css:6:5: expected ':' after width" {
+		t.Errorf("expected an error for width, got %v", sheet.Errors)
+	}
+	if dump := sheet.Dump(); !strings.HasPrefix(dump, "/* style.css:1 */\np {") {
+		t.Errorf("expected the dump to give each rule's line, got:\n%s", dump)
+	}
+}
+
+func TestParseCombinators(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"div p", "div p"},
+		{".card>h1", ".card > h1"},
+		{"h1 + p ~ ul  li", "h1 + p ~ ul li"},
+		{"ul :hover", "ul *:hover"},
+		{"ul *", "ul *"},
+		{"a:hover span::first-line", "a:hover span::first-line"},
+		{"#nav > li:focus", "#nav > li:focus"},
+	}
+	for _, tt := range tests {
+		sheet, err := Parse(tt.input + " { color: red; }")
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if len(sheet.Rules) != 1 || len(sheet.Rules[0].Selectors) != 1 {
+			t.Errorf("%s: expected one selector, got %+v", tt.input, sheet.Rules)
+			continue
+		}
+		if got := sheet.Rules[0].Selectors[0].String(); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.input, tt.want, got)
+		}
+	}
+
+	sheet, _ := Parse("ul > li a { color: red; }")
+	sel := sheet.Rules[0].Selectors[0]
+	if sel.Tag != "a" || len(sel.Context) != 2 ||
+		sel.Context[0].Combinator != CombinatorDescendant || sel.Context[0].Selector.Tag != "li" ||
+		sel.Context[1].Combinator != CombinatorChild || sel.Context[1].Selector.Tag != "ul" {
+		t.Errorf("expected a with li and ul in its context, got %+v", sel)
+	}
+
+	// A pseudo-element is only valid at the end
+	if sheet, _ := Parse("p::before span, em { color: red; }"); len(sheet.Rules) != 1 || len(sheet.Rules[0].Selectors) != 1 {
+		t.Errorf("expected the invalid selector to be dropped, got %+v", sheet.Rules)
+	}
+}
+
+func TestParseCompoundSelectors(t *testing.T) {
+	sheet, err := Parse(`div.container, a.button.primary:hover, p#intro, *.x, #a#b, .x .y { color: red; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sels := sheet.Rules[0].Selectors
+	if len(sels) != 5 {
+		t.Fatalf("expected 5 selectors, got %+v", sels)
+	}
+	if sels[1].Tag != "a" || !slices.Equal(sels[1].Classes, []string{"button", "primary"}) || len(sels[1].PseudoClasses) != 1 {
+		t.Errorf("expected a.button.primary:hover, got %+v", sels[1])
+	}
+	if sels[2].Tag != "p" || sels[2].ID != "intro" {
+		t.Errorf("expected p#intro, got %+v", sels[2])
+	}
+	if want := "div.container, a.button.primary:hover, p#intro, *.x, .x .y"; sheet.Dump()[:len(want)] != want {
+		t.Errorf("expected dump to start with %q, got %q", want, sheet.Dump())
+	}
+	if got := sels[1].Specificity(); got != (Specificity{Classes: 3, Types: 1}) {
+		t.Errorf("expected a.button.primary:hover to weigh 0,3,1, got %+v", got)
+	}
+}
+
+func TestParseAttributeSelectors(t *testing.T) {
+	sheet, err := Parse(`input[type="text"], [hidden], a[ href ^= 'https:' ][rel~=nofollow], [lang|=en] p, ` +
+		`[src$=".png"], [class*=col-], [a=], [a ^ = b], [1], [a b] { color: red; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sels := sheet.Rules[0].Selectors
+	if len(sels) != 6 {
+		t.Fatalf("expected the last 4 selectors to be dropped, got %d", len(sels))
+	}
+	want := [][]AttrSelector{
+		{{"type", AttrEquals, "text"}},
+		{{"hidden", AttrExists, ""}},
+		{{"href", AttrPrefix, "https:"}, {"rel", AttrIncludes, "nofollow"}},
+		nil,
+		{{"src", AttrSuffix, ".png"}},
+		{{"class", AttrSubstring, "col-"}},
+	}
+	for i, sel := range sels {
+		if !slices.Equal(sel.Attrs, want[i]) {
+			t.Errorf("selector %d: expected %+v, got %+v", i, want[i], sel.Attrs)
+		}
+	}
+	if ctx := sels[3].Context; len(ctx) != 1 || !slices.Equal(ctx[0].Selector.Attrs, []AttrSelector{{"lang", AttrDashMatch, "en"}}) {
+		t.Errorf("expected [lang|=en] in the context of p, got %+v", ctx)
+	}
+	if got, want := sels[2].String(), `a[href^="https:"][rel~="nofollow"]`; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestAttrSelectorMatches(t *testing.T) {
+	tests := []struct {
+		attr  AttrSelector
+		value string
+		want  bool
+	}{
+		{AttrSelector{"a", AttrExists, ""}, "", true},
+		{AttrSelector{"a", AttrEquals, "x"}, "x", true},
+		{AttrSelector{"a", AttrEquals, "x"}, "X", false},
+		{AttrSelector{"a", AttrIncludes, "b"}, "a b c", true},
+		{AttrSelector{"a", AttrIncludes, "b"}, "abc", false},
+		{AttrSelector{"a", AttrDashMatch, "en"}, "en-US", true},
+		{AttrSelector{"a", AttrDashMatch, "en"}, "eng", false},
+		{AttrSelector{"a", AttrPrefix, "ht"}, "https:", true},
+		{AttrSelector{"a", AttrSuffix, ".png"}, "a.png", true},
+		{AttrSelector{"a", AttrSubstring, "ol"}, "col-2", true},
+		// An empty value matches nothing but with = and |=
+		{AttrSelector{"a", AttrPrefix, ""}, "x", false},
+		{AttrSelector{"a", AttrSubstring, ""}, "", false},
+		{AttrSelector{"a", AttrEquals, ""}, "", true},
+	}
+	for _, tt := range tests {
+		if got := tt.attr.Matches(tt.value); got != tt.want {
+			t.Errorf("%s on %q: expected %v, got %v", tt.attr, tt.value, tt.want, got)
+		}
+	}
+}
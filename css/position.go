@@ -0,0 +1,58 @@
+package css
+
+import "fmt"
+
+// Position is where a rule or declaration was written: the file or URL of
+// its stylesheet and a 1-based line and column. A zero Line means unknown.
+type Position struct {
+	Source       string
+	Line, Column int
+}
+
+// String formats the position as "source:line:column", leaving out the
+// source when there is none
+func (p Position) String() string {
+	if p.Source == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Source, p.Line, p.Column)
+}
+
+// ParseError describes a part of a stylesheet the parser skipped
+type ParseError struct {
+	Pos     Position
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
+// positions converts byte offsets in a stylesheet's source to positions.
+// The parser asks for them in increasing order, so each is found by
+// scanning on from the last one, which keeps parsing linear.
+type positions struct {
+	input string
+	last  int // the offset of the last position returned
+	at    Position
+}
+
+func newPositions(source, input string) positions {
+	return positions{input: input, at: Position{Source: source, Line: 1, Column: 1}}
+}
+
+// advance moves to an offset at or after the last one and returns its
+// position. Columns count characters, not bytes.
+func (ps *positions) advance(offset int) Position {
+	offset = min(offset, len(ps.input))
+	for _, r := range ps.input[ps.last:max(offset, ps.last)] {
+		if r == '\n' {
+			ps.at.Line++
+			ps.at.Column = 1
+		} else {
+			ps.at.Column++
+		}
+	}
+	ps.last = max(offset, ps.last)
+	return ps.at
+}
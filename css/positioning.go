@@ -0,0 +1,99 @@
+package css
+
+import "strconv"
+
+// Positioning is how a box is positioned, the value of position
+type Positioning uint8
+
+const (
+	PositionStatic Positioning = iota
+	PositionRelative
+	PositionAbsolute
+	PositionFixed
+)
+
+func (p Positioning) String() string {
+	switch p {
+	case PositionRelative:
+		return "relative"
+	case PositionAbsolute:
+		return "absolute"
+	case PositionFixed:
+		return "fixed"
+	default:
+		return "static"
+	}
+}
+
+// OutOfFlow reports whether a box positioned so is taken out of the normal
+// flow, and placed against its containing block instead
+func (p Positioning) OutOfFlow() bool {
+	return p == PositionAbsolute || p == PositionFixed
+}
+
+// Offset is the value of top, right, bottom or left
+type Offset struct {
+	Length float32
+	Auto   bool
+}
+
+// Offsets are where a positioned box is placed, from top, right, bottom
+// and left
+type Offsets struct {
+	Top, Right, Bottom, Left Offset
+}
+
+// autoOffsets is the initial value of the offsets
+var autoOffsets = Offsets{Offset{Auto: true}, Offset{Auto: true}, Offset{Auto: true}, Offset{Auto: true}}
+
+// ZIndex is the value of z-index: where a positioned box is painted among
+// the others in its stacking context. A box with a z-index other than auto
+// is a stacking context of its own.
+type ZIndex struct {
+	Value int32
+	Auto  bool
+}
+
+func (z ZIndex) String() string {
+	if z.Auto {
+		return "auto"
+	}
+	return strconv.Itoa(int(z.Value))
+}
+
+func parseZIndex(decl Declaration) (Value, bool) {
+	if decl.Value == "auto" {
+		return Value{ZIndex: ZIndex{Auto: true}}, true
+	}
+	if len(decl.Values) != 1 || decl.Values[0].Type != TokenNumber {
+		return Value{}, false
+	}
+	n, err := strconv.ParseInt(decl.Values[0].Value, 10, 32)
+	if err != nil {
+		return Value{}, false
+	}
+	return Value{ZIndex: ZIndex{Value: int32(n)}}, true
+}
+
+// parsePositioning parses the position keywords. sticky isn't supported.
+func parsePositioning(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "static":
+		return Value{Keyword: uint8(PositionStatic)}, true
+	case "relative":
+		return Value{Keyword: uint8(PositionRelative)}, true
+	case "absolute":
+		return Value{Keyword: uint8(PositionAbsolute)}, true
+	case "fixed":
+		return Value{Keyword: uint8(PositionFixed)}, true
+	}
+	return Value{}, false
+}
+
+func offsetValue(o Offset) Value {
+	return Value{Length: o.Length, Auto: o.Auto}
+}
+
+func (v Value) offset() Offset {
+	return Offset{Length: v.Length, Auto: v.Auto}
+}
@@ -0,0 +1,52 @@
+package css
+
+import "testing"
+
+func TestPositioning(t *testing.T) {
+	style := DefaultStyle()
+	if style.Position != PositionStatic || style.Offsets != autoOffsets {
+		t.Errorf("expected static with auto offsets by default, got %v %+v", style.Position, style.Offsets)
+	}
+
+	sheet, err := Parse("div { position: absolute; inset: 10px auto; left: 5px; }")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, decl := range sheet.Rules[0].Declarations {
+		if !ApplyDeclaration(&style, decl) {
+			t.Errorf("%s: expected the declaration to apply", decl.Property)
+		}
+	}
+	want := Offsets{Top: Offset{Length: 10}, Right: Offset{Auto: true}, Bottom: Offset{Length: 10}, Left: Offset{Length: 5}}
+	if style.Position != PositionAbsolute || style.Offsets != want {
+		t.Errorf("expected absolute at %+v, got %v at %+v", want, style.Position, style.Offsets)
+	}
+	if !style.Position.OutOfFlow() || PositionRelative.OutOfFlow() {
+		t.Error("expected absolute boxes out of flow and relative ones in it")
+	}
+
+	if ApplyDeclaration(&style, firstDeclaration(t, "div { position: sticky; }")) {
+		t.Error("expected sticky to be unsupported")
+	}
+}
+
+func TestZIndex(t *testing.T) {
+	if got := DefaultStyle().ZIndex; got != (ZIndex{Auto: true}) {
+		t.Errorf("expected auto by default, got %v", got)
+	}
+	for _, input := range []string{"auto", "0", "-3", "2147483647"} {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "div { z-index: "+input+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", input)
+			continue
+		}
+		if got := style.Serialize(PropZIndex); got != input {
+			t.Errorf("%s: expected it back, got %s", input, got)
+		}
+	}
+	for _, input := range []string{"1.5", "2px", "none"} {
+		if err := ValidateDeclaration(firstDeclaration(t, "div { z-index: "+input+"; }")); err == nil {
+			t.Errorf("%s: expected an invalid value", input)
+		}
+	}
+}
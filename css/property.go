@@ -0,0 +1,419 @@
+package css
+
+import (
+	"slices"
+	"strings"
+)
+
+// shorthands maps each shorthand property to the function that expands it
+// into its longhands. An expansion lists every longhand of the shorthand,
+// with the ones the value omits set to their initial value, so a shorthand
+// overrides earlier longhands in full and later longhands override it, as
+// the cascade requires. Expanding reports false for an invalid value.
+var shorthands = map[string]func(decl Declaration) ([]Declaration, bool){
+	"margin":       edgesShorthand("margin-top", "margin-right", "margin-bottom", "margin-left"),
+	"padding":      edgesShorthand("padding-top", "padding-right", "padding-bottom", "padding-left"),
+	"border-width": edgesShorthand("border-top-width", "border-right-width", "border-bottom-width", "border-left-width"),
+	"inset":        edgesShorthand("top", "right", "bottom", "left"),
+
+	"margin-inline":       pairShorthand("margin-inline-start", "margin-inline-end"),
+	"margin-block":        pairShorthand("margin-block-start", "margin-block-end"),
+	"padding-inline":      pairShorthand("padding-inline-start", "padding-inline-end"),
+	"padding-block":       pairShorthand("padding-block-start", "padding-block-end"),
+	"inset-inline":        pairShorthand("inset-inline-start", "inset-inline-end"),
+	"inset-block":         pairShorthand("inset-block-start", "inset-block-end"),
+	"border-inline-width": pairShorthand("border-inline-start-width", "border-inline-end-width"),
+	"border-block-width":  pairShorthand("border-block-start-width", "border-block-end-width"),
+	"border-inline":       borderSidesShorthand("border-inline-start-width", "border-inline-end-width"),
+	"border-block":        borderSidesShorthand("border-block-start-width", "border-block-end-width"),
+	"border-inline-start": borderSidesShorthand("border-inline-start-width"),
+	"border-inline-end":   borderSidesShorthand("border-inline-end-width"),
+	"border-block-start":  borderSidesShorthand("border-block-start-width"),
+	"border-block-end":    borderSidesShorthand("border-block-end-width"),
+
+	"border":      expandBorder,
+	"background":  expandBackground,
+	"font":        expandFont,
+	"flex":        expandFlex,
+	"gap":         expandGap,
+	"grid-column": gridLineShorthand("grid-column-start", "grid-column-end"),
+	"grid-row":    gridLineShorthand("grid-row-start", "grid-row-end"),
+	"animation":   expandAnimation,
+	"transition":  expandTransition,
+	"all":         expandAll,
+
+	"text-decoration":    expandTextDecoration,
+	"-webkit-line-clamp": expandWebkitLineClamp,
+	"list-style":         expandListStyle,
+}
+
+// ApplyDeclaration applies a CSS declaration to a Style, expanding
+// shorthands into their longhands. It reports whether the property and its
+// value are supported; unsupported declarations leave the style unchanged.
+// inherit is resolved to the initial value; the cascade uses
+// ApplyDeclarationFrom to inherit from the parent.
+func ApplyDeclaration(style *Style, decl Declaration) bool {
+	return ApplyDeclarationFrom(style, nil, decl)
+}
+
+// ApplyDeclarationFrom is like ApplyDeclaration, but resolves inherit and
+// unset against the style of the parent element, which is nil for the root.
+// em lengths are relative to the font size of style as it is, and other
+// relative lengths to DefaultUnits; the cascade uses ApplyDeclarationIn to
+// resolve them against the element's own units.
+func ApplyDeclarationFrom(style, parent *Style, decl Declaration) bool {
+	units := DefaultUnits()
+	units.FontSize = style.FontSize
+	return ApplyDeclarationIn(style, parent, decl, units)
+}
+
+// ApplyDeclarationIn is like ApplyDeclarationFrom, with relative lengths
+// resolved against units
+func ApplyDeclarationIn(style, parent *Style, decl Declaration, units Units) bool {
+	if _, ok := shorthands[decl.Property]; ok {
+		expanded, ok := ExpandDeclaration(decl)
+		if !ok {
+			return false
+		}
+		// A shorthand counts as supported if penny understands any of the
+		// longhands it sets
+		applied := false
+		for _, longhand := range expanded {
+			if applyLonghand(style, parent, longhand, units) {
+				applied = true
+			}
+		}
+		return applied
+	}
+	return applyLonghand(style, parent, decl, units)
+}
+
+// ExpandDeclaration returns the longhand declarations a declaration stands
+// for, in the order they apply. A longhand is returned as is. It reports
+// false for a shorthand whose value is invalid.
+func ExpandDeclaration(decl Declaration) ([]Declaration, bool) {
+	expand, ok := shorthands[decl.Property]
+	if !ok {
+		return []Declaration{decl}, true
+	}
+	// Longhands are declared where the shorthand was, with its importance
+	expanded, ok := expand(decl)
+	for i := range expanded {
+		expanded[i].Important = decl.Important
+		expanded[i].Pos = decl.Pos
+	}
+	return expanded, ok
+}
+
+// ExpandDeclarations expands the shorthands among decls, keeping the order
+// of the declarations. When there are none, decls itself is returned.
+// Invalid shorthands are dropped.
+func ExpandDeclarations(decls []Declaration) []Declaration {
+	i := slices.IndexFunc(decls, func(decl Declaration) bool {
+		_, ok := shorthands[decl.Property]
+		return ok
+	})
+	if i < 0 {
+		return decls
+	}
+
+	// Room for shorthands to expand to a few longhands each
+	expanded := make([]Declaration, i, 2*len(decls)+4)
+	copy(expanded, decls[:i])
+	for _, decl := range decls[i:] {
+		if longhands, ok := ExpandDeclaration(decl); ok {
+			expanded = append(expanded, longhands...)
+		}
+	}
+	return expanded
+}
+
+// longhand makes a declaration from a property and the tokens of its value
+func longhand(property string, values ...Token) Declaration {
+	return Declaration{Property: property, Value: tokensString(values), Values: values}
+}
+
+func ident(value string) Token {
+	return Token{Type: TokenIdent, Value: value}
+}
+
+func number(value string) Token {
+	return Token{Type: TokenNumber, Value: value}
+}
+
+// components splits a value into its space separated components, keeping a
+// function and its arguments, nested functions included, together
+func components(values []Token) [][]Token {
+	comps := make([][]Token, 0, len(values))
+	for i := 0; i < len(values); i++ {
+		if values[i].Type != TokenFunction {
+			comps = append(comps, values[i:i+1])
+			continue
+		}
+		start, depth := i, 0
+		for ; i < len(values); i++ {
+			if values[i].Type == TokenFunction {
+				depth++
+			} else if values[i].Type == TokenRParen {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		comps = append(comps, values[start:min(i+1, len(values))])
+	}
+	return comps
+}
+
+func isLength(tok Token) bool {
+	return tok.Type == TokenNumber || tok.Type == TokenDimension || tok.Type == TokenPercentage
+}
+
+// edgesShorthand expands the 1 to 4 values of a shorthand like margin to
+// the top, right, bottom and left longhands
+func edgesShorthand(top, right, bottom, left string) func(decl Declaration) ([]Declaration, bool) {
+	return func(decl Declaration) ([]Declaration, bool) {
+		comps := components(decl.Values)
+		for _, comp := range comps {
+			if len(comp) != 1 || !isLength(comp[0]) && comp[0].Type != TokenIdent {
+				return nil, false
+			}
+		}
+
+		var t, r, b, l []Token
+		switch len(comps) {
+		case 1:
+			t, r, b, l = comps[0], comps[0], comps[0], comps[0]
+		case 2:
+			t, r, b, l = comps[0], comps[1], comps[0], comps[1]
+		case 3:
+			t, r, b, l = comps[0], comps[1], comps[2], comps[1]
+		case 4:
+			t, r, b, l = comps[0], comps[1], comps[2], comps[3]
+		default:
+			return nil, false
+		}
+		return []Declaration{longhand(top, t...), longhand(right, r...), longhand(bottom, b...), longhand(left, l...)}, true
+	}
+}
+
+var borderStyles = map[string]bool{
+	"none": true, "hidden": true, "dotted": true, "dashed": true, "solid": true,
+	"double": true, "groove": true, "ridge": true, "inset": true, "outset": true,
+}
+
+// expandBorder expands "border: <width> <style> <color>", in any order, to
+// the widths of the four sides and the border color
+func expandBorder(decl Declaration) ([]Declaration, bool) {
+	width := []Token{ident("medium")}
+	style := "none"
+	color := []Token{ident("currentcolor")}
+
+	var seenWidth, seenStyle, seenColor bool
+	for _, comp := range components(decl.Values) {
+		switch {
+		case !seenWidth && len(comp) == 1 && isBorderWidth(comp[0]):
+			width, seenWidth = comp, true
+		case !seenStyle && len(comp) == 1 && comp[0].Type == TokenIdent && borderStyles[comp[0].Value]:
+			style, seenStyle = comp[0].Value, true
+		case !seenColor && parseColor(Declaration{Value: tokensString(comp), Values: comp}) != nil:
+			color, seenColor = comp, true
+		default:
+			return nil, false
+		}
+	}
+
+	// Penny doesn't model border-style, but a border without a visible
+	// style has no width, so that is resolved here
+	if style == "none" || style == "hidden" {
+		width = []Token{number("0")}
+	}
+	return []Declaration{
+		longhand("border-top-width", width...),
+		longhand("border-right-width", width...),
+		longhand("border-bottom-width", width...),
+		longhand("border-left-width", width...),
+		longhand("border-color", color...),
+	}, true
+}
+
+func isBorderWidth(tok Token) bool {
+	if tok.Type == TokenIdent {
+		_, ok := borderWidthKeywords[tok.Value]
+		return ok
+	}
+	return tok.Type == TokenNumber || tok.Type == TokenDimension
+}
+
+// expandBackground expands background to its color and image, the
+// background longhands penny draws. Positions and the other layer
+// components are accepted but not expanded. With several layers, the color
+// and image are taken from the last one.
+func expandBackground(decl Declaration) ([]Declaration, bool) {
+	values := decl.Values
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i].Type == TokenComma && !insideFunction(values, i) {
+			values = values[i+1:]
+			break
+		}
+	}
+
+	color := []Token{ident("transparent")}
+	image := []Token{ident("none")}
+	for _, comp := range components(values) {
+		if parseColor(Declaration{Value: tokensString(comp), Values: comp}) != nil {
+			color = comp
+		} else if parseGradient(comp) != nil {
+			image = comp
+		}
+	}
+	return []Declaration{longhand("background-color", color...), longhand("background-image", image...)}, true
+}
+
+// insideFunction reports whether values[i] is an argument of a function
+func insideFunction(values []Token, i int) bool {
+	depth := 0
+	for _, tok := range values[:i] {
+		switch tok.Type {
+		case TokenFunction:
+			depth++
+		case TokenRParen:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// expandFont expands "font: [style] [variant] [weight] <size>[/<line-height>]
+// <family>"
+func expandFont(decl Declaration) ([]Declaration, bool) {
+	values := decl.Values
+	style, variant, weight := ident("normal"), ident("normal"), ident("normal")
+
+	i := 0
+	for ; i < len(values); i++ {
+		tok := values[i]
+		if tok.Type == TokenDimension || tok.Type == TokenPercentage {
+			break
+		}
+		switch {
+		case tok.Type == TokenIdent && (tok.Value == "italic" || tok.Value == "oblique"):
+			style = tok
+		case tok.Type == TokenIdent && tok.Value == "small-caps":
+			variant = tok
+		case tok.Type == TokenNumber || tok.Type == TokenIdent && (tok.Value == "bold" || tok.Value == "bolder" || tok.Value == "lighter"):
+			weight = tok
+		case tok.Type == TokenIdent && tok.Value == "normal":
+		default:
+			// System fonts and keyword sizes aren't supported
+			return nil, false
+		}
+	}
+	if i >= len(values) {
+		return nil, false
+	}
+	size := values[i]
+	i++
+
+	lineHeight := ident("normal")
+	if i < len(values) && isSlash(values[i]) {
+		if i+1 >= len(values) || !isLength(values[i+1]) && values[i+1].Type != TokenIdent {
+			return nil, false
+		}
+		lineHeight = values[i+1]
+		i += 2
+	}
+
+	// The family is required
+	if i >= len(values) {
+		return nil, false
+	}
+	return []Declaration{
+		longhand("font-style", style),
+		longhand("font-variant", variant),
+		longhand("font-weight", weight),
+		longhand("font-size", size),
+		longhand("line-height", lineHeight),
+		longhand("font-family", values[i:]...),
+	}, true
+}
+
+// expandAll expands "all: <css-wide keyword>" to every longhand penny
+// supports
+func expandAll(decl Declaration) ([]Declaration, bool) {
+	if _, ok := cssWideKeyword(decl); !ok {
+		return nil, false
+	}
+	expanded := make([]Declaration, numProperties)
+	for id, p := range properties {
+		expanded[id] = Declaration{Property: p.name, Value: decl.Value, Values: decl.Values}
+	}
+	return expanded, true
+}
+
+// expandFlex expands flex to its grow, shrink and basis longhands
+func expandFlex(decl Declaration) ([]Declaration, bool) {
+	grow, shrink, basis := number("0"), number("1"), ident("auto")
+
+	values := decl.Values
+	switch {
+	case len(values) == 1 && values[0].Value == "none" && values[0].Type == TokenIdent:
+		shrink = number("0")
+	case len(values) == 1 && values[0].Value == "auto" && values[0].Type == TokenIdent:
+		grow = number("1")
+	case len(values) == 1 && values[0].Value == "initial" && values[0].Type == TokenIdent:
+	default:
+		// <grow> [<shrink>] [<basis>], or a lone <basis>. An omitted basis
+		// is 0 when a grow factor is given.
+		var numbers []Token
+		var sawBasis bool
+		for _, tok := range values {
+			switch {
+			case tok.Type == TokenNumber && !sawBasis && len(numbers) < 2:
+				numbers = append(numbers, tok)
+			case !sawBasis && (tok.Type == TokenDimension || tok.Type == TokenPercentage || tok.Type == TokenIdent && tok.Value == "auto"):
+				basis, sawBasis = tok, true
+			default:
+				return nil, false
+			}
+		}
+		switch len(numbers) {
+		case 0:
+			if !sawBasis {
+				return nil, false
+			}
+			grow = number("1")
+		case 2:
+			shrink = numbers[1]
+			fallthrough
+		case 1:
+			grow = numbers[0]
+			if !sawBasis {
+				basis = number("0")
+			}
+		}
+	}
+	return []Declaration{
+		longhand("flex-grow", grow),
+		longhand("flex-shrink", shrink),
+		longhand("flex-basis", basis),
+	}, true
+}
+
+// tokensString renders value tokens the way the parser records
+// Declaration.Value
+func tokensString(values []Token) string {
+	if len(values) == 1 && values[0].Unit == "" {
+		return values[0].Value
+	}
+	var sb strings.Builder
+	for _, tok := range values {
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(tok.Value)
+		sb.WriteString(tok.Unit)
+	}
+	return sb.String()
+}
@@ -0,0 +1,90 @@
+package css
+
+import (
+	"testing"
+)
+
+// styleOf applies the declarations of the first rule of a stylesheet
+func styleOf(t *testing.T, input string) Style {
+	t.Helper()
+	sheet, err := Parse(input)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	style := DefaultStyle()
+	for _, decl := range sheet.Rules[0].Declarations {
+		ApplyDeclaration(&style, decl)
+	}
+	return style
+}
+
+func TestShorthandOverridesFollowDeclarationOrder(t *testing.T) {
+	style := styleOf(t, "p { margin: 10px; margin-left: 5px; }")
+	if want := (Edges{10, 10, 10, 5}); style.Margin != want {
+		t.Errorf("longhand after shorthand: expected %v, got %v", want, style.Margin)
+	}
+
+	style = styleOf(t, "p { margin-left: 5px; margin: 10px; }")
+	if want := (Edges{10, 10, 10, 10}); style.Margin != want {
+		t.Errorf("shorthand after longhand: expected %v, got %v", want, style.Margin)
+	}
+
+	// background resets a background-color declared before it
+	style = styleOf(t, "p { background-color: red; background: url(a.png) no-repeat; }")
+	if style.Background != ColorTransparent {
+		t.Errorf("expected background to reset the color, got %v", style.Background)
+	}
+}
+
+func TestExpandShorthands(t *testing.T) {
+	tests := []struct {
+		input string
+		check func(s Style) bool
+	}{
+		{"p { padding: 1px 2px 3px; }", func(s Style) bool { return s.Padding == Edges{1, 2, 3, 2} }},
+		{"p { border-width: 1px 2px; }", func(s Style) bool { return s.Border == Edges{1, 2, 1, 2} }},
+		{"p { border: 2px solid red; }", func(s Style) bool {
+			return s.Border == Edges{2, 2, 2, 2} && s.BorderColor == Color{255, 0, 0, 255}
+		}},
+		{"p { border: red thick dashed; }", func(s Style) bool { return s.Border == Edges{5, 5, 5, 5} }},
+		// Without a style the border isn't drawn
+		{"p { border: 2px red; }", func(s Style) bool { return s.Border == Edges{} }},
+		{"p { color: blue; border: solid; }", func(s Style) bool {
+			return s.Border == Edges{3, 3, 3, 3} && s.BorderColor == Color{0, 0, 255, 255}
+		}},
+		{"p { background: rgb(1, 2, 3) url(a.png); }", func(s Style) bool { return s.Background == Color{1, 2, 3, 255} }},
+		{"p { background: url(a.png), #fff; }", func(s Style) bool { return s.Background == ColorWhite }},
+		{"p { font: italic bold 12px/1.5 Arial, sans-serif; }", func(s Style) bool { return s.FontSize == 12 }},
+		{"p { flex: 2; }", func(s Style) bool { return s.FlexGrow == 2 }},
+		{"p { flex: 3 1 10px; }", func(s Style) bool { return s.FlexGrow == 3 }},
+		{"p { flex-grow: 4; flex: none; }", func(s Style) bool { return s.FlexGrow == 0 }},
+		{"p { flex: auto; }", func(s Style) bool { return s.FlexGrow == 1 }},
+	}
+	for _, tt := range tests {
+		if style := styleOf(t, tt.input); !tt.check(style) {
+			t.Errorf("%s: unexpected style %+v", tt.input, style)
+		}
+	}
+}
+
+func TestInvalidShorthandsAreIgnored(t *testing.T) {
+	for _, input := range []string{
+		"p { margin: 1px 2px 3px 4px 5px; }",
+		"p { border: 1px 2px solid; }",
+		"p { font: 12px; }",
+		"p { flex: 1 2 3; }",
+	} {
+		sheet, err := Parse(input)
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		decl := sheet.Rules[0].Declarations[0]
+		style := DefaultStyle()
+		if ApplyDeclaration(&style, decl) {
+			t.Errorf("%s: expected the declaration to be rejected", input)
+		}
+		if _, ok := ExpandDeclaration(decl); ok {
+			t.Errorf("%s: expected expansion to fail", input)
+		}
+	}
+}
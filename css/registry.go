@@ -0,0 +1,913 @@
+package css
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// PropertyID identifies a longhand property penny supports
+type PropertyID uint8
+
+const (
+	PropDisplay PropertyID = iota
+	PropWidth
+	PropHeight
+	PropBoxSizing
+	PropMarginTop
+	PropMarginRight
+	PropMarginBottom
+	PropMarginLeft
+	PropPaddingTop
+	PropPaddingRight
+	PropPaddingBottom
+	PropPaddingLeft
+	PropBorderTopWidth
+	PropBorderRightWidth
+	PropBorderBottomWidth
+	PropBorderLeftWidth
+	PropBorderColor
+	PropPosition
+	PropTop
+	PropRight
+	PropBottom
+	PropLeft
+	PropZIndex
+	PropFontSize
+	PropFontFamily
+	PropFontWeight
+	PropFontStyle
+	PropColor
+	PropBackgroundColor
+	PropBackgroundImage
+	PropFlexGrow
+	PropJustifyContent
+	PropAlignItems
+	PropAnimationName
+	PropAnimationDuration
+	PropAnimationDelay
+	PropAnimationIterationCount
+	PropAnimationDirection
+	PropAnimationFillMode
+	PropAnimationTimingFunction
+	PropTransitionProperty
+	PropTransitionDuration
+	PropTransitionDelay
+	PropTransitionTimingFunction
+	PropFilter
+	PropClipPath
+	PropOpacity
+	PropOverflow
+	PropCaretColor
+	PropTabSize
+	PropTextAlign
+	PropWritingMode
+	PropDirection
+	PropLineClamp
+	PropWhiteSpace
+	PropBorderCollapse
+	PropCaptionSide
+	PropListStyleType
+	PropListStylePosition
+	PropTextDecorationLine
+	PropTextDecorationColor
+	PropColumnCount
+	PropColumnGap
+	PropRowGap
+	PropGridTemplateColumns
+	PropGridTemplateRows
+	PropGridColumnStart
+	PropGridColumnEnd
+	PropGridRowStart
+	PropGridRowEnd
+
+	numProperties
+)
+
+// Value is the parsed value of a longhand property. Which fields are used
+// depends on the property.
+type Value struct {
+	Length       float32 // lengths and numbers
+	Unit         Unit    // of a length, until it is applied to a style
+	Auto         bool    // an auto width or height
+	Color        Color
+	CurrentColor bool   // a color that follows the color property
+	Keyword      uint8  // keyword values, such as a Display
+	Name         string // names, such as an animation name
+	Time         time.Duration
+	Timing       TimingFunction
+	Filter       *Filter
+	ClipPath     *ClipPath
+	Gradient     *Gradient
+	TabSize      TabSize
+	ZIndex       ZIndex
+	Tracks       *GridTracks
+	GridLine     GridLine
+}
+
+// property describes a longhand: how its value is parsed, whether it is
+// inherited and how it animates. Initial values are those of DefaultStyle.
+type property struct {
+	name      string
+	inherited bool
+	animation animationKind
+	parse     func(decl Declaration) (Value, bool)
+}
+
+// animationKind is how a property's values are interpolated
+type animationKind uint8
+
+const (
+	animateDiscrete animationKind = iota // switches halfway
+	animateLength
+	animateColor
+	// animateNever is for the animation and transition properties, which
+	// keyframes can't set
+	animateNever
+)
+
+// properties is the registry of supported longhands. Adding a property
+// takes an entry here and a field in getValue and setValue.
+var properties = [numProperties]property{
+	PropDisplay: {name: "display", parse: parseDisplay},
+
+	PropWidth:  {name: "width", animation: animateLength, parse: parseAutoLength},
+	PropHeight: {name: "height", animation: animateLength, parse: parseAutoLength},
+
+	PropBoxSizing: {name: "box-sizing", parse: parseBoxSizing},
+
+	PropMarginTop:    {name: "margin-top", animation: animateLength, parse: parseLengthValue},
+	PropMarginRight:  {name: "margin-right", animation: animateLength, parse: parseLengthValue},
+	PropMarginBottom: {name: "margin-bottom", animation: animateLength, parse: parseLengthValue},
+	PropMarginLeft:   {name: "margin-left", animation: animateLength, parse: parseLengthValue},
+
+	PropPaddingTop:    {name: "padding-top", animation: animateLength, parse: parseLengthValue},
+	PropPaddingRight:  {name: "padding-right", animation: animateLength, parse: parseLengthValue},
+	PropPaddingBottom: {name: "padding-bottom", animation: animateLength, parse: parseLengthValue},
+	PropPaddingLeft:   {name: "padding-left", animation: animateLength, parse: parseLengthValue},
+
+	PropBorderTopWidth:    {name: "border-top-width", animation: animateLength, parse: parseBorderWidth},
+	PropBorderRightWidth:  {name: "border-right-width", animation: animateLength, parse: parseBorderWidth},
+	PropBorderBottomWidth: {name: "border-bottom-width", animation: animateLength, parse: parseBorderWidth},
+	PropBorderLeftWidth:   {name: "border-left-width", animation: animateLength, parse: parseBorderWidth},
+	// Style has a single border color, so penny treats border-color as a
+	// longhand rather than a shorthand for the four sides
+	PropBorderColor: {name: "border-color", animation: animateColor, parse: parseBorderColor},
+
+	PropPosition: {name: "position", parse: parsePositioning},
+	PropTop:      {name: "top", animation: animateLength, parse: parseAutoLength},
+	PropRight:    {name: "right", animation: animateLength, parse: parseAutoLength},
+	PropBottom:   {name: "bottom", animation: animateLength, parse: parseAutoLength},
+	PropLeft:     {name: "left", animation: animateLength, parse: parseAutoLength},
+	PropZIndex:   {name: "z-index", parse: parseZIndex},
+
+	PropFontSize:   {name: "font-size", inherited: true, animation: animateLength, parse: parseLengthValue},
+	PropFontFamily: {name: "font-family", inherited: true, parse: parseFontFamily},
+	PropFontWeight: {name: "font-weight", inherited: true, animation: animateLength, parse: parseFontWeight},
+	PropFontStyle:  {name: "font-style", inherited: true, parse: parseFontStyle},
+
+	PropColor:           {name: "color", inherited: true, animation: animateColor, parse: parseColorValue},
+	PropBackgroundColor: {name: "background-color", animation: animateColor, parse: parseColorValue},
+	PropBackgroundImage: {name: "background-image", parse: parseBackgroundImage},
+
+	PropFlexGrow:       {name: "flex-grow", animation: animateLength, parse: parseNumber},
+	PropJustifyContent: {name: "justify-content", parse: parseJustifyContent},
+	PropAlignItems:     {name: "align-items", parse: parseAlignItems},
+
+	PropAnimationName:            {name: "animation-name", animation: animateNever, parse: parseAnimationName},
+	PropAnimationDuration:        {name: "animation-duration", animation: animateNever, parse: parseDuration},
+	PropAnimationDelay:           {name: "animation-delay", animation: animateNever, parse: parseDelay},
+	PropAnimationIterationCount:  {name: "animation-iteration-count", animation: animateNever, parse: parseIterationCount},
+	PropAnimationDirection:       {name: "animation-direction", animation: animateNever, parse: parseAnimationDirection},
+	PropAnimationFillMode:        {name: "animation-fill-mode", animation: animateNever, parse: parseFillMode},
+	PropAnimationTimingFunction:  {name: "animation-timing-function", animation: animateNever, parse: parseTimingValue},
+	PropTransitionProperty:       {name: "transition-property", animation: animateNever, parse: parseTransitionProperty},
+	PropTransitionDuration:       {name: "transition-duration", animation: animateNever, parse: parseDuration},
+	PropTransitionDelay:          {name: "transition-delay", animation: animateNever, parse: parseDelay},
+	PropTransitionTimingFunction: {name: "transition-timing-function", animation: animateNever, parse: parseTimingValue},
+
+	PropFilter:   {name: "filter", parse: parseFilter},
+	PropClipPath: {name: "clip-path", parse: parseClipPath},
+	PropOpacity:  {name: "opacity", animation: animateLength, parse: parseOpacity},
+	PropOverflow: {name: "overflow", parse: parseOverflow},
+
+	PropCaretColor:  {name: "caret-color", inherited: true, parse: parseCaretColor},
+	PropTabSize:     {name: "tab-size", inherited: true, parse: parseTabSize},
+	PropTextAlign:   {name: "text-align", inherited: true, parse: parseTextAlign},
+	PropWritingMode: {name: "writing-mode", inherited: true, parse: parseWritingMode},
+	PropDirection:   {name: "direction", inherited: true, parse: parseDirection},
+	PropLineClamp:   {name: "line-clamp", parse: parseLineClamp},
+	PropWhiteSpace:  {name: "white-space", inherited: true, parse: parseWhiteSpace},
+
+	PropBorderCollapse: {name: "border-collapse", inherited: true, parse: parseBorderCollapse},
+	PropCaptionSide:    {name: "caption-side", inherited: true, parse: parseCaptionSide},
+
+	PropListStyleType:     {name: "list-style-type", inherited: true, parse: parseListStyleType},
+	PropListStylePosition: {name: "list-style-position", inherited: true, parse: parseListStylePosition},
+
+	PropTextDecorationLine:  {name: "text-decoration-line", parse: parseTextDecorationLine},
+	PropTextDecorationColor: {name: "text-decoration-color", parse: parseTextDecorationColor},
+
+	PropColumnCount: {name: "column-count", parse: parseColumnCount},
+	PropColumnGap:   {name: "column-gap", parse: parseColumnGap},
+	PropRowGap:      {name: "row-gap", parse: parseRowGap},
+
+	PropGridTemplateColumns: {name: "grid-template-columns", parse: parseGridTracks},
+	PropGridTemplateRows:    {name: "grid-template-rows", parse: parseGridTracks},
+	PropGridColumnStart:     {name: "grid-column-start", parse: parseGridLine},
+	PropGridColumnEnd:       {name: "grid-column-end", parse: parseGridLine},
+	PropGridRowStart:        {name: "grid-row-start", parse: parseGridLine},
+	PropGridRowEnd:          {name: "grid-row-end", parse: parseGridLine},
+}
+
+var propertyIDs = func() map[string]PropertyID {
+	ids := make(map[string]PropertyID, numProperties)
+	for id, p := range properties {
+		ids[p.name] = PropertyID(id)
+	}
+	return ids
+}()
+
+// initialStyle holds the initial value of every property
+var initialStyle = DefaultStyle()
+
+// LookupProperty returns the ID of a supported longhand property
+func LookupProperty(name string) (PropertyID, bool) {
+	id, ok := propertyIDs[name]
+	return id, ok
+}
+
+func (id PropertyID) String() string {
+	return properties[id].name
+}
+
+// Inherited reports whether the property is inherited by default
+func (id PropertyID) Inherited() bool {
+	return properties[id].inherited
+}
+
+// Initial returns the initial value of the property
+func (id PropertyID) Initial() Value {
+	return getValue(&initialStyle, id)
+}
+
+var (
+	// ErrUnsupportedProperty is returned for properties penny doesn't
+	// implement
+	ErrUnsupportedProperty = errors.New("unsupported property")
+	// ErrInvalidValue is returned for values that aren't valid, or not
+	// supported, for their property
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// ParseValue parses the value of a longhand declaration. CSS-wide keywords
+// such as inherit depend on the cascade and are rejected here; use
+// ValidateDeclaration to check a declaration as written.
+func ParseValue(decl Declaration) (PropertyID, Value, error) {
+	name := decl.Property
+	// A logical property takes the values of the physical ones it maps to
+	if physical, ok := physicalProperty(name, HorizontalTB, DirectionLTR); ok {
+		name = physical
+	}
+	id, ok := propertyIDs[name]
+	if !ok {
+		return 0, Value{}, fmt.Errorf("%w: %s", ErrUnsupportedProperty, decl.Property)
+	}
+	v, ok := properties[id].parse(decl)
+	if !ok {
+		return id, Value{}, fmt.Errorf("%w for %s: %q", ErrInvalidValue, decl.Property, decl.Value)
+	}
+	return id, v, nil
+}
+
+// ValidateDeclaration checks that penny can apply a declaration. A
+// shorthand is valid when it expands and penny supports at least one of its
+// longhands.
+func ValidateDeclaration(decl Declaration) error {
+	if _, ok := shorthands[decl.Property]; !ok {
+		return validateLonghand(decl)
+	}
+
+	expanded, ok := ExpandDeclaration(decl)
+	if !ok {
+		return fmt.Errorf("%w for %s: %q", ErrInvalidValue, decl.Property, decl.Value)
+	}
+	supported := false
+	for _, longhand := range expanded {
+		err := validateLonghand(longhand)
+		if errors.Is(err, ErrUnsupportedProperty) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		supported = true
+	}
+	if !supported {
+		return fmt.Errorf("%w: %s", ErrUnsupportedProperty, decl.Property)
+	}
+	return nil
+}
+
+func validateLonghand(decl Declaration) error {
+	if _, ok := cssWideKeyword(decl); ok {
+		if _, ok := propertyIDs[decl.Property]; !ok && !isLogical(decl.Property) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedProperty, decl.Property)
+		}
+		return nil
+	}
+	_, _, err := ParseValue(decl)
+	return err
+}
+
+// cssWideKeyword returns the CSS-wide keyword a declaration's value is, if
+// any. revert is treated as unset here; the cascade, which knows the origin
+// of each declaration, resolves it with Revert before it gets this far.
+func cssWideKeyword(decl Declaration) (string, bool) {
+	if len(decl.Values) != 1 || decl.Values[0].Type != TokenIdent {
+		return "", false
+	}
+	switch kw := decl.Values[0].Value; kw {
+	case "initial", "inherit", "unset":
+		return kw, true
+	case "revert":
+		return "unset", true
+	}
+	return "", false
+}
+
+// applyLonghand sets a longhand on style, resolving CSS-wide keywords
+// against parent, which is nil for the root, and relative lengths against
+// units. It reports whether the property and value are supported;
+// unsupported values leave the style unchanged.
+func applyLonghand(style, parent *Style, decl Declaration, units Units) bool {
+	if physical, ok := physicalProperty(decl.Property, style.WritingMode, style.Direction); ok {
+		decl.Property = physical
+	}
+	id, ok := propertyIDs[decl.Property]
+	if !ok {
+		return false
+	}
+
+	if kw, ok := cssWideKeyword(decl); ok {
+		if kw == "unset" {
+			kw = "initial"
+			if properties[id].inherited {
+				kw = "inherit"
+			}
+		}
+		if kw == "inherit" && parent != nil {
+			setValue(style, id, getValue(parent, id))
+		} else {
+			setValue(style, id, getValue(&initialStyle, id))
+		}
+		return true
+	}
+
+	v, ok := properties[id].parse(decl)
+	if !ok {
+		return false
+	}
+	parentFontSize := initialStyle.FontSize
+	if parent != nil {
+		parentFontSize = parent.FontSize
+	}
+	setValue(style, id, units.resolve(id, v, parentFontSize))
+	return true
+}
+
+// InheritedStyle returns the style an element starts from before its rules
+// apply: the initial values, with the inherited properties taken from
+// parent
+func InheritedStyle(parent Style) Style {
+	style := DefaultStyle()
+	for id := range numProperties {
+		if properties[id].inherited {
+			setValue(&style, id, getValue(&parent, id))
+		}
+	}
+	style.Selection = parent.Selection
+	style.Decorations = parent.Decorations
+	return style
+}
+
+// InheritedEqual reports whether two parent styles pass on the same values
+// to an element whose declarations don't read the parent
+func InheritedEqual(a, b Style) bool {
+	if a.Selection != b.Selection || a.Decorations != b.Decorations {
+		return false
+	}
+	for id := range numProperties {
+		if properties[id].inherited && getValue(&a, id) != getValue(&b, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// DependsOnParent reports whether applying a declaration reads the style of
+// the parent element beyond the inherited properties, as inherit and unset
+// do
+func DependsOnParent(decl Declaration) bool {
+	kw, ok := cssWideKeyword(decl)
+	return ok && kw != "initial"
+}
+
+// Get returns the value of a property
+func (s *Style) Get(id PropertyID) Value {
+	return getValue(s, id)
+}
+
+// Set sets the value of a property
+func (s *Style) Set(id PropertyID, v Value) {
+	setValue(s, id, v)
+}
+
+// getValue and setValue read and write the field of a property. They switch
+// on the ID rather than going through the registry, so the style doesn't
+// escape to the heap.
+func getValue(style *Style, id PropertyID) Value {
+	switch id {
+	case PropDisplay:
+		return Value{Keyword: uint8(style.Display)}
+	case PropWidth:
+		return autoLengthValue(style.Width)
+	case PropHeight:
+		return autoLengthValue(style.Height)
+	case PropBoxSizing:
+		return Value{Keyword: uint8(style.BoxSizing)}
+	case PropMarginTop:
+		return Value{Length: style.Margin.Top}
+	case PropMarginRight:
+		return Value{Length: style.Margin.Right}
+	case PropMarginBottom:
+		return Value{Length: style.Margin.Bottom}
+	case PropMarginLeft:
+		return Value{Length: style.Margin.Left}
+	case PropPaddingTop:
+		return Value{Length: style.Padding.Top}
+	case PropPaddingRight:
+		return Value{Length: style.Padding.Right}
+	case PropPaddingBottom:
+		return Value{Length: style.Padding.Bottom}
+	case PropPaddingLeft:
+		return Value{Length: style.Padding.Left}
+	case PropBorderTopWidth:
+		return Value{Length: style.Border.Top}
+	case PropBorderRightWidth:
+		return Value{Length: style.Border.Right}
+	case PropBorderBottomWidth:
+		return Value{Length: style.Border.Bottom}
+	case PropBorderLeftWidth:
+		return Value{Length: style.Border.Left}
+	case PropBorderColor:
+		return Value{Color: style.BorderColor}
+	case PropPosition:
+		return Value{Keyword: uint8(style.Position)}
+	case PropTop:
+		return offsetValue(style.Offsets.Top)
+	case PropRight:
+		return offsetValue(style.Offsets.Right)
+	case PropBottom:
+		return offsetValue(style.Offsets.Bottom)
+	case PropLeft:
+		return offsetValue(style.Offsets.Left)
+	case PropZIndex:
+		return Value{ZIndex: style.ZIndex}
+	case PropFontSize:
+		return Value{Length: style.FontSize}
+	case PropFontFamily:
+		return Value{Name: style.FontFamily}
+	case PropFontWeight:
+		return Value{Length: float32(style.FontWeight)}
+	case PropFontStyle:
+		return Value{Keyword: uint8(style.FontStyle)}
+	case PropColor:
+		return Value{Color: style.Color}
+	case PropBackgroundColor:
+		return Value{Color: style.Background}
+	case PropBackgroundImage:
+		return Value{Gradient: style.BackgroundImage}
+	case PropFlexGrow:
+		return Value{Length: style.FlexGrow}
+	case PropJustifyContent:
+		return Value{Keyword: uint8(style.JustifyContent)}
+	case PropAlignItems:
+		return Value{Keyword: uint8(style.AlignItems)}
+	case PropAnimationName:
+		return Value{Name: style.Animation.Name}
+	case PropAnimationDuration:
+		return Value{Time: style.Animation.Duration}
+	case PropAnimationDelay:
+		return Value{Time: style.Animation.Delay}
+	case PropAnimationIterationCount:
+		return Value{Length: style.Animation.IterationCount}
+	case PropAnimationDirection:
+		return Value{Keyword: uint8(style.Animation.Direction)}
+	case PropAnimationFillMode:
+		return Value{Keyword: uint8(style.Animation.FillMode)}
+	case PropAnimationTimingFunction:
+		return Value{Timing: style.Animation.Timing}
+	case PropTransitionProperty:
+		return Value{Name: style.Transition.Property}
+	case PropTransitionDuration:
+		return Value{Time: style.Transition.Duration}
+	case PropTransitionDelay:
+		return Value{Time: style.Transition.Delay}
+	case PropTransitionTimingFunction:
+		return Value{Timing: style.Transition.Timing}
+	case PropFilter:
+		return Value{Filter: style.Filter}
+	case PropClipPath:
+		return Value{ClipPath: style.ClipPath}
+	case PropOpacity:
+		return Value{Length: style.Opacity}
+	case PropOverflow:
+		return Value{Keyword: uint8(style.Overflow)}
+	case PropCaretColor:
+		return Value{Color: style.CaretColor.Color, Auto: style.CaretColor.Auto}
+	case PropTabSize:
+		return Value{TabSize: style.TabSize}
+	case PropTextAlign:
+		return Value{Keyword: uint8(style.TextAlign)}
+	case PropWritingMode:
+		return Value{Keyword: uint8(style.WritingMode)}
+	case PropDirection:
+		return Value{Keyword: uint8(style.Direction)}
+	case PropLineClamp:
+		return Value{Length: float32(style.LineClamp), Auto: style.LineClamp == 0}
+	case PropWhiteSpace:
+		return Value{Keyword: uint8(style.WhiteSpace)}
+	case PropBorderCollapse:
+		return Value{Keyword: uint8(style.BorderCollapse)}
+	case PropCaptionSide:
+		return Value{Keyword: uint8(style.CaptionSide)}
+	case PropListStyleType:
+		return Value{Keyword: uint8(style.ListStyleType)}
+	case PropListStylePosition:
+		return Value{Keyword: uint8(style.ListStylePosition)}
+	case PropTextDecorationLine:
+		return Value{Keyword: uint8(style.TextDecoration.Line)}
+	case PropTextDecorationColor:
+		return Value{Color: style.TextDecoration.Color, CurrentColor: style.TextDecoration.CurrentColor}
+	case PropColumnCount:
+		return Value{Length: float32(style.Columns.Count), Auto: style.Columns.Count == 0}
+	case PropColumnGap:
+		return Value{Length: style.Columns.Gap, Auto: style.Columns.NormalGap}
+	case PropRowGap:
+		return Value{Length: style.RowGap}
+	case PropGridTemplateColumns:
+		return Value{Tracks: style.GridTemplateColumns}
+	case PropGridTemplateRows:
+		return Value{Tracks: style.GridTemplateRows}
+	case PropGridColumnStart:
+		return Value{GridLine: style.GridColumn.Start}
+	case PropGridColumnEnd:
+		return Value{GridLine: style.GridColumn.End}
+	case PropGridRowStart:
+		return Value{GridLine: style.GridRow.Start}
+	case PropGridRowEnd:
+		return Value{GridLine: style.GridRow.End}
+	}
+	return Value{}
+}
+
+func setValue(style *Style, id PropertyID, v Value) {
+	switch id {
+	case PropDisplay:
+		style.Display = Display(v.Keyword)
+	case PropWidth:
+		style.Width = v.autoLength()
+	case PropHeight:
+		style.Height = v.autoLength()
+	case PropBoxSizing:
+		style.BoxSizing = BoxSizing(v.Keyword)
+	case PropMarginTop:
+		style.Margin.Top = v.Length
+	case PropMarginRight:
+		style.Margin.Right = v.Length
+	case PropMarginBottom:
+		style.Margin.Bottom = v.Length
+	case PropMarginLeft:
+		style.Margin.Left = v.Length
+	case PropPaddingTop:
+		style.Padding.Top = v.Length
+	case PropPaddingRight:
+		style.Padding.Right = v.Length
+	case PropPaddingBottom:
+		style.Padding.Bottom = v.Length
+	case PropPaddingLeft:
+		style.Padding.Left = v.Length
+	case PropBorderTopWidth:
+		style.Border.Top = v.Length
+	case PropBorderRightWidth:
+		style.Border.Right = v.Length
+	case PropBorderBottomWidth:
+		style.Border.Bottom = v.Length
+	case PropBorderLeftWidth:
+		style.Border.Left = v.Length
+	case PropBorderColor:
+		if v.CurrentColor {
+			style.BorderColor = style.Color
+		} else {
+			style.BorderColor = v.Color
+		}
+	case PropPosition:
+		style.Position = Positioning(v.Keyword)
+	case PropTop:
+		style.Offsets.Top = v.offset()
+	case PropRight:
+		style.Offsets.Right = v.offset()
+	case PropBottom:
+		style.Offsets.Bottom = v.offset()
+	case PropLeft:
+		style.Offsets.Left = v.offset()
+	case PropZIndex:
+		style.ZIndex = v.ZIndex
+	case PropFontSize:
+		style.FontSize = v.Length
+	case PropFontFamily:
+		style.FontFamily = v.Name
+	case PropFontWeight:
+		// bolder and lighter are relative to the weight the element
+		// inherits, or to one set by an earlier declaration
+		if v.Keyword != 0 {
+			style.FontWeight = relativeWeight(v.Keyword, style.FontWeight)
+		} else {
+			style.FontWeight = uint16(v.Length)
+		}
+	case PropFontStyle:
+		style.FontStyle = FontStyle(v.Keyword)
+	case PropColor:
+		style.Color = v.Color
+	case PropBackgroundColor:
+		style.Background = v.Color
+	case PropBackgroundImage:
+		style.BackgroundImage = v.Gradient
+	case PropFlexGrow:
+		style.FlexGrow = v.Length
+	case PropJustifyContent:
+		style.JustifyContent = JustifyContent(v.Keyword)
+	case PropAlignItems:
+		style.AlignItems = AlignItems(v.Keyword)
+	case PropAnimationName:
+		style.Animation.Name = v.Name
+	case PropAnimationDuration:
+		style.Animation.Duration = v.Time
+	case PropAnimationDelay:
+		style.Animation.Delay = v.Time
+	case PropAnimationIterationCount:
+		style.Animation.IterationCount = v.Length
+	case PropAnimationDirection:
+		style.Animation.Direction = AnimationDirection(v.Keyword)
+	case PropAnimationFillMode:
+		style.Animation.FillMode = FillMode(v.Keyword)
+	case PropAnimationTimingFunction:
+		style.Animation.Timing = v.Timing
+	case PropTransitionProperty:
+		style.Transition.Property = v.Name
+	case PropTransitionDuration:
+		style.Transition.Duration = v.Time
+	case PropTransitionDelay:
+		style.Transition.Delay = v.Time
+	case PropTransitionTimingFunction:
+		style.Transition.Timing = v.Timing
+	case PropFilter:
+		style.Filter = resolveFilter(v.Filter, style.Color)
+	case PropClipPath:
+		style.ClipPath = v.ClipPath
+	case PropOpacity:
+		style.Opacity = v.Length
+	case PropOverflow:
+		style.Overflow = Overflow(v.Keyword)
+	case PropCaretColor:
+		style.CaretColor = CaretColor{Color: v.Color, Auto: v.Auto}
+	case PropTabSize:
+		style.TabSize = v.TabSize
+	case PropTextAlign:
+		style.TextAlign = TextAlign(v.Keyword)
+	case PropWritingMode:
+		style.WritingMode = WritingMode(v.Keyword)
+	case PropDirection:
+		style.Direction = Direction(v.Keyword)
+	case PropLineClamp:
+		style.LineClamp = LineClamp(v.Length)
+	case PropWhiteSpace:
+		style.WhiteSpace = WhiteSpace(v.Keyword)
+	case PropBorderCollapse:
+		style.BorderCollapse = BorderCollapse(v.Keyword)
+	case PropCaptionSide:
+		style.CaptionSide = CaptionSide(v.Keyword)
+	case PropListStyleType:
+		style.ListStyleType = ListStyleType(v.Keyword)
+	case PropListStylePosition:
+		style.ListStylePosition = ListStylePosition(v.Keyword)
+	case PropTextDecorationLine:
+		style.TextDecoration.Line = TextDecorationLine(v.Keyword)
+	case PropTextDecorationColor:
+		style.TextDecoration.Color, style.TextDecoration.CurrentColor = v.Color, v.CurrentColor
+	case PropColumnCount:
+		style.Columns.Count = int(v.Length)
+		if v.Auto {
+			style.Columns.Count = 0
+		}
+	case PropColumnGap:
+		style.Columns.Gap, style.Columns.NormalGap = v.Length, v.Auto
+	case PropRowGap:
+		style.RowGap = v.Length
+	case PropGridTemplateColumns:
+		style.GridTemplateColumns = v.Tracks
+	case PropGridTemplateRows:
+		style.GridTemplateRows = v.Tracks
+	case PropGridColumnStart:
+		style.GridColumn.Start = v.GridLine
+	case PropGridColumnEnd:
+		style.GridColumn.End = v.GridLine
+	case PropGridRowStart:
+		style.GridRow.Start = v.GridLine
+	case PropGridRowEnd:
+		style.GridRow.End = v.GridLine
+	}
+}
+
+func autoLengthValue(length *float32) Value {
+	if length == nil {
+		return Value{Auto: true}
+	}
+	return Value{Length: *length}
+}
+
+func (v Value) autoLength() *float32 {
+	if v.Auto {
+		return nil
+	}
+	length := v.Length
+	return &length
+}
+
+func parseDisplay(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "block":
+		return Value{Keyword: uint8(DisplayBlock)}, true
+	case "inline":
+		return Value{Keyword: uint8(DisplayInline)}, true
+	case "none":
+		return Value{Keyword: uint8(DisplayNone)}, true
+	case "flex":
+		return Value{Keyword: uint8(DisplayFlex)}, true
+	case "grid":
+		return Value{Keyword: uint8(DisplayGrid)}, true
+	case "inline-block":
+		return Value{Keyword: uint8(DisplayInlineBlock)}, true
+	case "ruby":
+		return Value{Keyword: uint8(DisplayRuby)}, true
+	case "ruby-text":
+		return Value{Keyword: uint8(DisplayRubyText)}, true
+	case "table":
+		return Value{Keyword: uint8(DisplayTable)}, true
+	case "table-row-group":
+		return Value{Keyword: uint8(DisplayTableRowGroup)}, true
+	case "table-header-group":
+		return Value{Keyword: uint8(DisplayTableHeaderGroup)}, true
+	case "table-footer-group":
+		return Value{Keyword: uint8(DisplayTableFooterGroup)}, true
+	case "table-row":
+		return Value{Keyword: uint8(DisplayTableRow)}, true
+	case "table-cell":
+		return Value{Keyword: uint8(DisplayTableCell)}, true
+	case "table-caption":
+		return Value{Keyword: uint8(DisplayTableCaption)}, true
+	case "list-item":
+		return Value{Keyword: uint8(DisplayListItem)}, true
+	case "-webkit-box":
+		// The legacy box of the line-clamp pattern, which lays its content
+		// out as a block does
+		return Value{Keyword: uint8(DisplayBlock)}, true
+	}
+	return Value{}, false
+}
+
+func parseOverflow(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "visible":
+		return Value{Keyword: uint8(OverflowVisible)}, true
+	case "hidden":
+		return Value{Keyword: uint8(OverflowHidden)}, true
+	case "clip":
+		return Value{Keyword: uint8(OverflowClip)}, true
+	case "scroll":
+		return Value{Keyword: uint8(OverflowScroll)}, true
+	case "auto":
+		return Value{Keyword: uint8(OverflowAuto)}, true
+	}
+	return Value{}, false
+}
+
+func parseBoxSizing(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "content-box":
+		return Value{Keyword: uint8(BoxSizingContent)}, true
+	case "border-box":
+		return Value{Keyword: uint8(BoxSizingBorder)}, true
+	}
+	return Value{}, false
+}
+
+func parseJustifyContent(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "flex-start":
+		return Value{Keyword: uint8(JustifyFlexStart)}, true
+	case "flex-end":
+		return Value{Keyword: uint8(JustifyFlexEnd)}, true
+	case "center":
+		return Value{Keyword: uint8(JustifyCenter)}, true
+	case "space-between":
+		return Value{Keyword: uint8(JustifySpaceBetween)}, true
+	case "space-around":
+		return Value{Keyword: uint8(JustifySpaceAround)}, true
+	}
+	return Value{}, false
+}
+
+func parseAlignItems(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "flex-start":
+		return Value{Keyword: uint8(AlignFlexStart)}, true
+	case "flex-end":
+		return Value{Keyword: uint8(AlignFlexEnd)}, true
+	case "center":
+		return Value{Keyword: uint8(AlignCenter)}, true
+	case "stretch":
+		return Value{Keyword: uint8(AlignStretch)}, true
+	}
+	return Value{}, false
+}
+
+func parseLengthValue(decl Declaration) (Value, bool) {
+	v, unit, ok := parseLengthUnit(decl.Values)
+	return Value{Length: v, Unit: unit}, ok
+}
+
+func parseAutoLength(decl Declaration) (Value, bool) {
+	if decl.Value == "auto" {
+		return Value{Auto: true}, true
+	}
+	return parseLengthValue(decl)
+}
+
+func parseNumber(decl Declaration) (Value, bool) {
+	if len(decl.Values) == 0 || decl.Values[0].Type != TokenNumber {
+		return Value{}, false
+	}
+	v, err := strconv.ParseFloat(decl.Values[0].Value, 32)
+	if err != nil {
+		return Value{}, false
+	}
+	return Value{Length: float32(v)}, true
+}
+
+// parseOpacity parses a number or percentage, clamped to between 0 and 1
+func parseOpacity(decl Declaration) (Value, bool) {
+	if len(decl.Values) != 1 || decl.Values[0].Type != TokenNumber && decl.Values[0].Type != TokenPercentage {
+		return Value{}, false
+	}
+	v, err := strconv.ParseFloat(decl.Values[0].Value, 32)
+	if err != nil {
+		return Value{}, false
+	}
+	if decl.Values[0].Type == TokenPercentage {
+		v /= 100
+	}
+	return Value{Length: float32(min(max(v, 0), 1))}, true
+}
+
+// borderWidthKeywords are the widths browsers use for the keywords
+var borderWidthKeywords = map[string]float32{
+	"thin":   1,
+	"medium": 3,
+	"thick":  5,
+}
+
+func parseBorderWidth(decl Declaration) (Value, bool) {
+	if len(decl.Values) == 1 && decl.Values[0].Type == TokenIdent {
+		w, ok := borderWidthKeywords[decl.Values[0].Value]
+		return Value{Length: w}, ok
+	}
+	return parseLengthValue(decl)
+}
+
+func parseColorValue(decl Declaration) (Value, bool) {
+	c := parseColor(decl)
+	if c == nil {
+		return Value{}, false
+	}
+	return Value{Color: *c}, true
+}
+
+func parseBorderColor(decl Declaration) (Value, bool) {
+	if decl.Value == "currentcolor" {
+		return Value{CurrentColor: true}, true
+	}
+	return parseColorValue(decl)
+}
@@ -0,0 +1,145 @@
+package css
+
+import (
+	"errors"
+	"testing"
+)
+
+func firstDeclaration(t *testing.T, input string) Declaration {
+	t.Helper()
+	sheet, err := Parse(input)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	return sheet.Rules[0].Declarations[0]
+}
+
+func TestValidateDeclaration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  error
+	}{
+		{"p { width: 10px; }", nil},
+		{"p { width: auto; }", nil},
+		{"p { width: inherit; }", nil},
+		{"p { margin: 1px 2px; }", nil},
+		{"p { all: unset; }", nil},
+		{"p { float: left; }", ErrUnsupportedProperty},
+		{"p { box-sizing: border-box; }", nil},
+		{"p { display: inline-block; }", nil},
+		{"p { display: contents; }", ErrInvalidValue},
+		{"p { box-sizing: padding-box; }", ErrInvalidValue},
+		{"p { color: 12px; }", ErrInvalidValue},
+		{"p { all: red; }", ErrInvalidValue},
+		{"p { margin: 1px 2px 3px 4px 5px; }", ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		err := ValidateDeclaration(firstDeclaration(t, tt.input))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.want, err)
+		}
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	id, v, err := ParseValue(firstDeclaration(t, "p { justify-content: center; }"))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if id != PropJustifyContent || JustifyContent(v.Keyword) != JustifyCenter {
+		t.Errorf("expected justify-content center, got %v %+v", id, v)
+	}
+	if id.String() != "justify-content" || id.Inherited() {
+		t.Errorf("unexpected registry entry for %v", id)
+	}
+}
+
+func TestCSSWideKeywords(t *testing.T) {
+	parent := DefaultStyle()
+	parent.Color = Color{1, 2, 3, 255}
+	parent.FontSize = 30
+	parent.Padding = Edges{5, 5, 5, 5}
+
+	apply := func(input string) Style {
+		style := InheritedStyle(parent)
+		sheet, err := Parse(input)
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		for _, decl := range sheet.Rules[0].Declarations {
+			ApplyDeclarationFrom(&style, &parent, decl)
+		}
+		return style
+	}
+
+	style := apply("p { padding-top: inherit; color: initial; }")
+	if style.Padding.Top != 5 || style.Padding.Left != 0 {
+		t.Errorf("expected only padding-top to be inherited, got %v", style.Padding)
+	}
+	if style.Color != ColorBlack {
+		t.Errorf("expected the initial color, got %v", style.Color)
+	}
+
+	// unset inherits inherited properties and resets the others
+	style = apply("p { padding: 9px; font-size: 10px; all: unset; }")
+	if style.Padding != (Edges{}) || style.FontSize != 30 {
+		t.Errorf("expected all: unset to reset padding and inherit font-size, got %v %v", style.Padding, style.FontSize)
+	}
+
+	style = apply("p { all: inherit; }")
+	if !style.Equal(parent) {
+		t.Errorf("expected all: inherit to copy the parent, got %+v", style)
+	}
+
+	style = apply("p { font-size: 10px; all: initial; }")
+	if !style.Equal(DefaultStyle()) {
+		t.Errorf("expected all: initial to reset everything, got %+v", style)
+	}
+}
+
+func TestOpacity(t *testing.T) {
+	tests := []struct {
+		input string
+		want  float32
+	}{
+		{"0.5", 0.5},
+		{"25%", 0.25},
+		{"2", 1},
+		{"-1", 0},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { opacity: "+tt.input+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", tt.input)
+			continue
+		}
+		if style.Opacity != tt.want {
+			t.Errorf("%s: expected %g, got %g", tt.input, tt.want, style.Opacity)
+		}
+	}
+	if DefaultStyle().Opacity != 1 {
+		t.Errorf("expected elements to be opaque by default")
+	}
+	if err := ValidateDeclaration(firstDeclaration(t, "p { opacity: 1px; }")); err == nil {
+		t.Errorf("expected a length to be invalid")
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	for _, input := range []string{"visible", "hidden", "clip", "scroll", "auto"} {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { overflow: "+input+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", input)
+			continue
+		}
+		if got := style.Serialize(PropOverflow); got != input {
+			t.Errorf("%s: expected it back, got %s", input, got)
+		}
+		if style.Overflow.Clips() != (input != "visible") {
+			t.Errorf("%s: expected Clips to be %v", input, input != "visible")
+		}
+	}
+	if err := ValidateDeclaration(firstDeclaration(t, "p { overflow: hidden visible; }")); err == nil {
+		t.Error("expected two values to be invalid")
+	}
+}
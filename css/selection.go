@@ -0,0 +1,65 @@
+package css
+
+// CaretColor is the value of the caret-color property
+type CaretColor struct {
+	Color Color
+	// Auto is set for auto and currentcolor, which draw the caret in the
+	// color of the text
+	Auto bool
+}
+
+// Selection is how selected text is drawn, as set by ::selection rules.
+// It is inherited: the selected text of an element without ::selection
+// rules of its own is drawn like its parent's.
+type Selection struct {
+	Background Color
+	Color      Color
+	// CurrentColor is set when selected text keeps the color of the text
+	CurrentColor bool
+}
+
+// DefaultSelection is how the user agent draws selected text
+var DefaultSelection = Selection{Background: Color{179, 215, 255, 255}, CurrentColor: true}
+
+// Caret returns the color the caret is drawn in
+func (s Style) Caret() Color {
+	if s.CaretColor.Auto {
+		return s.Color
+	}
+	return s.CaretColor.Color
+}
+
+// SelectionColor returns the color selected text is drawn in
+func (s Style) SelectionColor() Color {
+	if s.Selection.CurrentColor {
+		return s.Color
+	}
+	return s.Selection.Color
+}
+
+// ApplySelectionDeclaration applies a declaration of a ::selection rule to
+// the selection style of an element. Only color and background-color apply
+// to selected text; it reports false for other properties and invalid
+// values.
+func ApplySelectionDeclaration(selection *Selection, decl Declaration) bool {
+	id, v, err := ParseValue(decl)
+	if err != nil {
+		return false
+	}
+	switch id {
+	case PropColor:
+		selection.Color, selection.CurrentColor = v.Color, false
+	case PropBackgroundColor:
+		selection.Background = v.Color
+	default:
+		return false
+	}
+	return true
+}
+
+func parseCaretColor(decl Declaration) (Value, bool) {
+	if decl.Value == "auto" || decl.Value == "currentcolor" {
+		return Value{Auto: true}, true
+	}
+	return parseColorValue(decl)
+}
@@ -0,0 +1,55 @@
+package css
+
+import "testing"
+
+func TestCaretColor(t *testing.T) {
+	style := DefaultStyle()
+	style.Color = Color{1, 2, 3, 255}
+	if got := style.Caret(); got != style.Color {
+		t.Errorf("expected the auto caret in the text color, got %v", got)
+	}
+
+	ApplyDeclaration(&style, firstDeclaration(t, "p { caret-color: red; }"))
+	if got := style.Caret(); got != (Color{255, 0, 0, 255}) {
+		t.Errorf("expected a red caret, got %v", got)
+	}
+	child := InheritedStyle(style)
+	if got := child.Caret(); got != (Color{255, 0, 0, 255}) {
+		t.Errorf("expected caret-color to be inherited, got %v", got)
+	}
+
+	ApplyDeclaration(&style, firstDeclaration(t, "p { caret-color: currentcolor; }"))
+	child = InheritedStyle(style)
+	child.Color = Color{4, 5, 6, 255}
+	if got := child.Caret(); got != child.Color {
+		t.Errorf("expected an inherited currentcolor caret to follow the child's color, got %v", got)
+	}
+}
+
+func TestApplySelectionDeclaration(t *testing.T) {
+	selection := DefaultSelection
+	if !ApplySelectionDeclaration(&selection, firstDeclaration(t, "::selection { color: white; }")) {
+		t.Fatal("expected color to apply to ::selection")
+	}
+	if ApplySelectionDeclaration(&selection, firstDeclaration(t, "::selection { width: 10px; }")) {
+		t.Error("expected width not to apply to ::selection")
+	}
+	want := Selection{Background: DefaultSelection.Background, Color: ColorWhite}
+	if selection != want {
+		t.Errorf("expected %+v, got %+v", want, selection)
+	}
+}
+
+func TestParseUniversalPseudoSelectors(t *testing.T) {
+	sheet, err := Parse(`::selection, *:hover { color: red; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sels := sheet.Rules[0].Selectors
+	if len(sels) != 2 || sels[0].PseudoElement != "selection" || sels[1].Tag != "*" {
+		t.Fatalf("expected two universal selectors, got %+v", sels)
+	}
+	if want := "*::selection, *:hover {\n  color: red;\n}\n"; sheet.Dump() != want {
+		t.Errorf("expected dump %q, got %q", want, sheet.Dump())
+	}
+}
@@ -0,0 +1,105 @@
+package css
+
+import "strings"
+
+// ListKind is which of the logical pseudo-classes a selector list is
+// given to
+type ListKind uint8
+
+const (
+	ListIs    ListKind = iota // :is(), matching any of the selectors
+	ListWhere                 // :where(), like :is() but without specificity
+	ListNot                   // :not(), matching none of the selectors
+)
+
+var listNames = [...]string{"is", "where", "not"}
+
+// listFunctions are the logical pseudo-classes by their function names,
+// along with :matches(), the old name of :is()
+var listFunctions = map[string]ListKind{
+	"is()":      ListIs,
+	"matches()": ListIs,
+	"where()":   ListWhere,
+	"not()":     ListNot,
+}
+
+// SelectorList is a logical pseudo-class with the complex selectors it
+// takes, which are matched against the element as a whole selector would
+// be
+type SelectorList struct {
+	Kind      ListKind
+	Selectors []Selector
+}
+
+// Specificity returns the specificity the pseudo-class adds to its
+// compound: that of the most specific of its selectors, or none for
+// :where()
+func (l SelectorList) Specificity() Specificity {
+	var s Specificity
+	if l.Kind == ListWhere {
+		return s
+	}
+	for _, sel := range l.Selectors {
+		if c := sel.Specificity(); c.Compare(s) > 0 {
+			s = c
+		}
+	}
+	return s
+}
+
+func (l SelectorList) String() string {
+	names := make([]string, len(l.Selectors))
+	for i, sel := range l.Selectors {
+		names[i] = sel.String()
+	}
+	return listNames[l.Kind] + "(" + strings.Join(names, ", ") + ")"
+}
+
+// selectorList parses the selectors of a logical pseudo-class up to its
+// closing parenthesis. :is() and :where() forgive invalid selectors,
+// dropping them from the list, but :not() is invalid if any of its
+// selectors is. Pseudo-elements are invalid in all of them.
+func (p *Parser) selectorList(kind ListKind) (SelectorList, bool) {
+	list := SelectorList{Kind: kind}
+	valid := true
+	for {
+		sel := p.selector()
+		if sel.empty() || sel.PseudoElement != "" || p.cur.Type != TokenComma && p.cur.Type != TokenRParen {
+			valid = false
+			p.skipNested()
+		} else {
+			list.Selectors = append(list.Selectors, sel)
+		}
+		if p.cur.Type != TokenComma {
+			break
+		}
+		p.advance() // consume ','
+	}
+	if p.cur.Type != TokenRParen || !valid && kind == ListNot {
+		return list, false
+	}
+	p.advance() // consume ')'
+	return list, true
+}
+
+// skipNested skips the rest of an invalid selector in the parentheses of a
+// pseudo-class, up to the comma or parenthesis ending it
+func (p *Parser) skipNested() {
+	for depth := 0; ; p.advance() {
+		switch p.cur.Type {
+		case TokenFunction:
+			depth++
+		case TokenRParen:
+			if depth == 0 {
+				return
+			}
+			depth--
+		case TokenComma:
+			if depth == 0 {
+				return
+			}
+		case TokenLBrace, TokenRBrace, TokenEOF:
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package css
+
+import "testing"
+
+func TestParseSelectorLists(t *testing.T) {
+	sheet, err := Parse(`li:not(.a, :first-child), :is(h1, ul > li):hover, :where(#a .b), a:matches(p), ` +
+		`:is(p!, .x), :is(), :not(li::before), :not(p!, .x), :not(:not(a)), p:is(:nth-child(2)) { color: red; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	// :is() and :where() drop the selectors that are invalid, and :not()
+	// is dropped with its own
+	want := []struct {
+		kind  ListKind
+		count int
+		text  string
+	}{
+		{ListNot, 2, "li:not(.a, *:first-child)"},
+		{ListIs, 2, "*:hover:is(h1, ul > li)"},
+		{ListWhere, 1, "*:where(#a .b)"},
+		{ListIs, 1, "a:is(p)"},
+		{ListIs, 1, "*:is(.x)"},
+		{ListIs, 0, "*:is()"},
+		{ListNot, 1, "*:not(*:not(a))"},
+		{ListIs, 1, "p:is(*:nth-child(2))"},
+	}
+	sels := sheet.Rules[0].Selectors
+	if len(sels) != len(want) {
+		t.Fatalf("expected %d selectors, got %d: %v", len(want), len(sels), sels)
+	}
+	for i, w := range want {
+		lists := sels[i].Lists
+		if len(lists) != 1 || lists[0].Kind != w.kind || len(lists[0].Selectors) != w.count {
+			t.Errorf("selector %d: expected %d selectors in %s, got %+v", i, w.count, listNames[w.kind], sels[i])
+		}
+		if got := sels[i].String(); got != w.text {
+			t.Errorf("selector %d: expected %s, got %s", i, w.text, got)
+		}
+	}
+
+	// The rule after a nested list parses as usual
+	sheet, err = Parse(`a:not(:nth-child(2)) b { color: red; } p { color: blue; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if len(sheet.Rules) != 2 || sheet.Rules[1].Selectors[0].Tag != "p" {
+		t.Errorf("expected the second rule to be p, got %+v", sheet.Rules)
+	}
+	if sel := sheet.Rules[0].Selectors[0]; sel.Tag != "b" || len(sel.Context) != 1 || len(sel.Context[0].Selector.Lists) != 1 {
+		t.Errorf("expected a:not(...) b, got %+v", sel)
+	}
+}
@@ -0,0 +1,147 @@
+package css
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Serialize returns the computed value of a property as CSS text, as
+// getComputedStyle would. Lengths are in pixels and colors are rgba().
+func (s *Style) Serialize(id PropertyID) string {
+	v := s.Get(id)
+	switch id {
+	case PropDisplay:
+		return Display(v.Keyword).String()
+	case PropBoxSizing:
+		return BoxSizing(v.Keyword).String()
+	case PropPosition:
+		return Positioning(v.Keyword).String()
+	case PropOverflow:
+		return Overflow(v.Keyword).String()
+	case PropZIndex:
+		return v.ZIndex.String()
+	case PropTextAlign:
+		return TextAlign(v.Keyword).String()
+	case PropWritingMode:
+		return WritingMode(v.Keyword).String()
+	case PropDirection:
+		return Direction(v.Keyword).String()
+	case PropLineClamp:
+		return LineClamp(v.Length).String()
+	case PropWhiteSpace:
+		return WhiteSpace(v.Keyword).String()
+	case PropBorderCollapse:
+		return BorderCollapse(v.Keyword).String()
+	case PropCaptionSide:
+		return CaptionSide(v.Keyword).String()
+	case PropListStyleType:
+		return keywordOf(listStyleTypeKeywords, ListStyleType(v.Keyword))
+	case PropListStylePosition:
+		return keywordOf(listStylePositionKeywords, ListStylePosition(v.Keyword))
+	case PropTextDecorationLine:
+		return TextDecorationLine(v.Keyword).String()
+	case PropTextDecorationColor:
+		if v.CurrentColor {
+			return "currentcolor"
+		}
+		return formatColor(v.Color)
+	case PropFontStyle:
+		return FontStyle(v.Keyword).String()
+	case PropJustifyContent:
+		return JustifyContent(v.Keyword).String()
+	case PropAlignItems:
+		return AlignItems(v.Keyword).String()
+	case PropAnimationDirection:
+		return keywordOf(directionKeywords, AnimationDirection(v.Keyword))
+	case PropAnimationFillMode:
+		return keywordOf(fillModeKeywords, FillMode(v.Keyword))
+
+	case PropWidth, PropHeight, PropTop, PropRight, PropBottom, PropLeft:
+		if v.Auto {
+			return "auto"
+		}
+		return formatPx(v.Length)
+	case PropMarginTop, PropMarginRight, PropMarginBottom, PropMarginLeft,
+		PropPaddingTop, PropPaddingRight, PropPaddingBottom, PropPaddingLeft,
+		PropBorderTopWidth, PropBorderRightWidth, PropBorderBottomWidth, PropBorderLeftWidth,
+		PropFontSize, PropRowGap:
+		return formatPx(v.Length)
+	case PropFontWeight, PropFlexGrow, PropOpacity:
+		return formatNumber(v.Length)
+
+	case PropColor, PropBackgroundColor, PropBorderColor:
+		return formatColor(v.Color)
+	case PropCaretColor:
+		if v.Auto {
+			return "auto"
+		}
+		return formatColor(v.Color)
+	case PropBackgroundImage:
+		return v.Gradient.String()
+	case PropFilter:
+		return v.Filter.String()
+	case PropClipPath:
+		return v.ClipPath.String()
+
+	case PropFontFamily, PropTransitionProperty:
+		return v.Name
+	case PropAnimationName:
+		if v.Name == "" {
+			return "none"
+		}
+		return v.Name
+	case PropAnimationDuration, PropAnimationDelay, PropTransitionDuration, PropTransitionDelay:
+		return formatNumber(float32(v.Time.Seconds())) + "s"
+	case PropAnimationIterationCount:
+		if math.IsInf(float64(v.Length), 1) {
+			return "infinite"
+		}
+		return formatNumber(v.Length)
+	case PropAnimationTimingFunction, PropTransitionTimingFunction:
+		return v.Timing.String()
+
+	case PropTabSize:
+		if v.TabSize.Spaces > 0 {
+			return formatNumber(v.TabSize.Spaces)
+		}
+		return formatPx(v.TabSize.Width)
+	case PropColumnCount:
+		if v.Auto {
+			return "auto"
+		}
+		return formatNumber(v.Length)
+	case PropColumnGap:
+		if v.Auto {
+			return "normal"
+		}
+		return formatPx(v.Length)
+	case PropGridTemplateColumns, PropGridTemplateRows:
+		return v.Tracks.String()
+	case PropGridColumnStart, PropGridColumnEnd, PropGridRowStart, PropGridRowEnd:
+		return v.GridLine.String()
+	}
+	return ""
+}
+
+// keywordOf returns the keyword of a value in a table of keywords
+func keywordOf[T comparable](keywords map[string]T, value T) string {
+	for keyword, v := range keywords {
+		if v == value {
+			return keyword
+		}
+	}
+	return ""
+}
+
+func formatNumber(f float32) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func formatPx(f float32) string {
+	return formatNumber(f) + "px"
+}
+
+func formatColor(c Color) string {
+	return fmt.Sprintf("rgba(%d,%d,%d,%d)", c.R, c.G, c.B, c.A)
+}
@@ -0,0 +1,56 @@
+package css
+
+import "testing"
+
+func TestSerialize(t *testing.T) {
+	style := styleOf(t, `p {
+		display: flex; height: 20px; margin: 4px 0; color: red;
+		justify-content: space-between; font-weight: bold; column-gap: normal;
+		animation: spin 1.5s ease-in 2 alternate both; transition-timing-function: steps(3, jump-start);
+		tab-size: 4; caret-color: auto; filter: blur(2px); grid-column-start: span 2;
+	}`)
+	tests := []struct {
+		prop string
+		want string
+	}{
+		{"display", "flex"},
+		{"width", "auto"},
+		{"height", "20px"},
+		{"margin-top", "4px"},
+		{"margin-left", "0px"},
+		{"color", "rgba(255,0,0,255)"},
+		{"background-color", "rgba(0,0,0,0)"},
+		{"background-image", "none"},
+		{"justify-content", "space-between"},
+		{"align-items", "stretch"},
+		{"font-weight", "700"},
+		{"column-gap", "normal"},
+		{"animation-name", "spin"},
+		{"animation-duration", "1.5s"},
+		{"animation-timing-function", "ease-in"},
+		{"animation-iteration-count", "2"},
+		{"animation-direction", "alternate"},
+		{"animation-fill-mode", "both"},
+		{"transition-timing-function", "steps(3, jump-start)"},
+		{"tab-size", "4"},
+		{"caret-color", "auto"},
+		{"filter", "blur(2px)"},
+		{"clip-path", "none"},
+		{"grid-column-start", "span 2"},
+	}
+	for _, tt := range tests {
+		id, ok := LookupProperty(tt.prop)
+		if !ok {
+			t.Fatalf("%s: unknown property", tt.prop)
+		}
+		if got := style.Serialize(id); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.prop, tt.want, got)
+		}
+	}
+
+	for id := range PropertyID(numProperties) {
+		if style.Serialize(id) == "" && id != PropFontFamily && id != PropTransitionProperty {
+			t.Errorf("%s: expected a value", id)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package css
+
+import "cmp"
+
+// Specificity is the weight of a selector in the cascade. Of two
+// declarations in the same origin, the one whose selector has more IDs
+// wins, then more classes, attributes and pseudo-classes, then more types
+// and pseudo-elements; a tie goes to the one that comes later.
+type Specificity struct {
+	IDs, Classes, Types int
+}
+
+// Compare returns -1, 0 or +1 as s is less than, equal to or greater than
+// other
+func (s Specificity) Compare(other Specificity) int {
+	return cmp.Or(
+		cmp.Compare(s.IDs, other.IDs),
+		cmp.Compare(s.Classes, other.Classes),
+		cmp.Compare(s.Types, other.Types),
+	)
+}
+
+// Specificity returns the specificity of the selector, the sum of those of
+// its compounds. The universal selector adds nothing, and a logical
+// pseudo-class adds that of its most specific selector.
+func (sel Selector) Specificity() Specificity {
+	var s Specificity
+	if sel.ID != "" {
+		s.IDs++
+	}
+	s.Classes += len(sel.Classes) + len(sel.Attrs) + len(sel.PseudoClasses) + len(sel.Nths)
+	if sel.Tag != "" && sel.Tag != "*" {
+		s.Types++
+	}
+	if sel.PseudoElement != "" {
+		s.Types++
+	}
+	for _, list := range sel.Lists {
+		s = s.plus(list.Specificity())
+	}
+	for _, rel := range sel.Context {
+		s = s.plus(rel.Selector.Specificity())
+	}
+	return s
+}
+
+func (s Specificity) plus(other Specificity) Specificity {
+	return Specificity{s.IDs + other.IDs, s.Classes + other.Classes, s.Types + other.Types}
+}
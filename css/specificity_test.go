@@ -0,0 +1,35 @@
+package css
+
+import "testing"
+
+func TestSpecificity(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Specificity
+	}{
+		{"p", Specificity{Types: 1}},
+		{".x", Specificity{Classes: 1}},
+		{"#a", Specificity{IDs: 1}},
+		{"a:hover:focus", Specificity{Classes: 2, Types: 1}},
+		{"input[type=text][required]", Specificity{Classes: 2, Types: 1}},
+		{"tr:nth-child(2n):hover", Specificity{Classes: 2, Types: 1}},
+		{"p::first-line", Specificity{Types: 2}},
+		{"li:not(.a, #b)", Specificity{IDs: 1, Types: 1}},
+		{"a:is(p, .x .y)", Specificity{Classes: 2, Types: 1}},
+		{":where(#a, .b) p", Specificity{Types: 1}},
+		{"::selection", Specificity{Types: 1}},
+	}
+	for _, tt := range tests {
+		sheet, err := Parse(tt.input + " {}")
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if got := sheet.Rules[0].Selectors[0].Specificity(); got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.input, tt.want, got)
+		}
+	}
+
+	if (Specificity{IDs: 1}).Compare(Specificity{Classes: 10, Types: 10}) <= 0 {
+		t.Error("expected an ID to outweigh any number of classes and types")
+	}
+}
@@ -7,6 +7,26 @@ const (
 	DisplayInline
 	DisplayNone
 	DisplayFlex
+	DisplayGrid
+	// DisplayInlineBlock is a block laid out as a single box in a line,
+	// as wide as its content
+	DisplayInlineBlock
+	// DisplayRuby lays out base text with its annotations above it, and
+	// DisplayRubyText is an annotation of the base text before it
+	DisplayRuby
+	DisplayRubyText
+	// DisplayTable lays its rows out in a grid of cells. Its rows are
+	// children of its row groups or of the table itself, and its captions
+	// are above or below them.
+	DisplayTable
+	DisplayTableRowGroup
+	DisplayTableHeaderGroup // rows above the other groups
+	DisplayTableFooterGroup // rows below the other groups
+	DisplayTableRow
+	DisplayTableCell
+	DisplayTableCaption
+	// DisplayListItem is a block with a list marker
+	DisplayListItem
 )
 
 func (d Display) String() string {
@@ -19,11 +39,52 @@ func (d Display) String() string {
 		return "none"
 	case DisplayFlex:
 		return "flex"
+	case DisplayGrid:
+		return "grid"
+	case DisplayInlineBlock:
+		return "inline-block"
+	case DisplayRuby:
+		return "ruby"
+	case DisplayRubyText:
+		return "ruby-text"
+	case DisplayTable:
+		return "table"
+	case DisplayTableRowGroup:
+		return "table-row-group"
+	case DisplayTableHeaderGroup:
+		return "table-header-group"
+	case DisplayTableFooterGroup:
+		return "table-footer-group"
+	case DisplayTableRow:
+		return "table-row"
+	case DisplayTableCell:
+		return "table-cell"
+	case DisplayTableCaption:
+		return "table-caption"
+	case DisplayListItem:
+		return "list-item"
 	default:
 		return "unknown"
 	}
 }
 
+// BoxSizing is which box width and height set, the value of box-sizing
+type BoxSizing uint8
+
+const (
+	// BoxSizingContent sets the content box, inside the padding and border
+	BoxSizingContent BoxSizing = iota
+	// BoxSizingBorder sets the border box, padding and border included
+	BoxSizingBorder
+)
+
+func (b BoxSizing) String() string {
+	if b == BoxSizingBorder {
+		return "border-box"
+	}
+	return "content-box"
+}
+
 type JustifyContent uint8
 
 const (
@@ -34,6 +95,53 @@ const (
 	JustifySpaceAround
 )
 
+func (j JustifyContent) String() string {
+	switch j {
+	case JustifyFlexEnd:
+		return "flex-end"
+	case JustifyCenter:
+		return "center"
+	case JustifySpaceBetween:
+		return "space-between"
+	case JustifySpaceAround:
+		return "space-around"
+	default:
+		return "flex-start"
+	}
+}
+
+// Overflow is what is done with content that overflows a box, the value of
+// overflow. There are no scrollbars, so scroll and auto clip as hidden does.
+type Overflow uint8
+
+const (
+	OverflowVisible Overflow = iota
+	OverflowHidden
+	OverflowClip
+	OverflowScroll
+	OverflowAuto
+)
+
+func (o Overflow) String() string {
+	switch o {
+	case OverflowHidden:
+		return "hidden"
+	case OverflowClip:
+		return "clip"
+	case OverflowScroll:
+		return "scroll"
+	case OverflowAuto:
+		return "auto"
+	default:
+		return "visible"
+	}
+}
+
+// Clips reports whether a box clips its content to its padding box
+func (o Overflow) Clips() bool {
+	return o != OverflowVisible
+}
+
 type AlignItems uint8
 
 const (
@@ -43,6 +151,19 @@ const (
 	AlignStretch
 )
 
+func (a AlignItems) String() string {
+	switch a {
+	case AlignFlexEnd:
+		return "flex-end"
+	case AlignCenter:
+		return "center"
+	case AlignStretch:
+		return "stretch"
+	default:
+		return "flex-start"
+	}
+}
+
 type Color struct {
 	R, G, B, A uint8
 }
@@ -58,18 +179,59 @@ type Edges struct {
 }
 
 type Style struct {
-	Display        Display
-	Width, Height  *float32 // nil = auto
-	Margin         Edges
-	Padding        Edges
-	Border         Edges
-	Background     Color
-	BorderColor    Color
-	FontSize       float32
+	Display         Display
+	Width, Height   *float32 // nil = auto
+	BoxSizing       BoxSizing
+	Margin          Edges
+	Padding         Edges
+	Border          Edges
+	Position        Positioning
+	Offsets         Offsets
+	ZIndex          ZIndex
+	Overflow        Overflow
+	Background      Color
+	BackgroundImage *Gradient // nil = none
+	BorderColor     Color
+	FontSize        float32
+	// FontFamily is the font-family list, with the families separated by
+	// ", ", generic families as keywords and names quoted
+	FontFamily     string
+	FontWeight     uint16 // from 1 to 1000
+	FontStyle      FontStyle
 	Color          Color
 	FlexGrow       float32
 	JustifyContent JustifyContent
 	AlignItems     AlignItems
+	Animation      Animation
+	Transition     Transition
+	Filter         *Filter   // nil = none
+	ClipPath       *ClipPath // nil = none
+	Opacity        float32   // from 0 to 1
+	CaretColor     CaretColor
+	Selection      Selection // from ::selection rules
+	TabSize        TabSize
+	TextAlign      TextAlign
+	WritingMode    WritingMode
+	Direction      Direction
+	LineClamp      LineClamp
+	WhiteSpace     WhiteSpace
+	BorderCollapse BorderCollapse
+	CaptionSide    CaptionSide
+	TextDecoration TextDecoration
+	Decorations    Decorations // its own text-decoration and its ancestors'
+	Columns        Columns
+	// GridTemplateColumns and GridTemplateRows are the explicit tracks of
+	// a grid container, nil for none
+	GridTemplateColumns, GridTemplateRows *GridTracks
+	GridColumn, GridRow                   GridPlacement
+	RowGap                                float32
+	// ListStyleType and ListStylePosition are how the marker of a list
+	// item is drawn
+	ListStyleType     ListStyleType
+	ListStylePosition ListStylePosition
+	// FirstLetter and FirstLine are set by ::first-letter and ::first-line
+	// rules on the element, and on the first text inside it
+	FirstLetter, FirstLine PseudoText
 }
 
 func DefaultStyle() Style {
@@ -80,12 +242,49 @@ func DefaultStyle() Style {
 		Margin:         Edges{},
 		Padding:        Edges{},
 		Border:         Edges{},
+		Offsets:        autoOffsets,
+		ZIndex:         ZIndex{Auto: true},
 		Background:     ColorTransparent,
 		BorderColor:    ColorBlack,
 		FontSize:       16,
+		FontFamily:     "serif",
+		FontWeight:     FontWeightNormal,
 		Color:          ColorBlack,
 		FlexGrow:       0,
 		JustifyContent: JustifyFlexStart,
 		AlignItems:     AlignStretch,
+		Animation:      Animation{IterationCount: 1, Timing: TimingEase},
+		Transition:     Transition{Property: "all", Timing: TimingEase},
+		CaretColor:     CaretColor{Auto: true},
+		TextDecoration: TextDecoration{CurrentColor: true},
+		Selection:      DefaultSelection,
+		TabSize:        TabSize{Spaces: 8},
+		Columns:        Columns{NormalGap: true},
+		Opacity:        1,
+	}
+}
+
+// Equal reports whether two styles have the same values. Widths, heights,
+// background images, filters, clip paths and grid tracks are compared by
+// value rather than by pointer.
+func (s Style) Equal(other Style) bool {
+	if !equalLength(s.Width, other.Width) || !equalLength(s.Height, other.Height) ||
+		!s.BackgroundImage.Equal(other.BackgroundImage) ||
+		!s.Filter.Equal(other.Filter) || !s.ClipPath.Equal(other.ClipPath) ||
+		!s.GridTemplateColumns.Equal(other.GridTemplateColumns) || !s.GridTemplateRows.Equal(other.GridTemplateRows) {
+		return false
+	}
+	s.Width, s.Height, s.Filter, s.ClipPath = nil, nil, nil, nil
+	other.Width, other.Height, other.Filter, other.ClipPath = nil, nil, nil, nil
+	s.BackgroundImage, other.BackgroundImage = nil, nil
+	s.GridTemplateColumns, s.GridTemplateRows = nil, nil
+	other.GridTemplateColumns, other.GridTemplateRows = nil, nil
+	return s == other
+}
+
+func equalLength(a, b *float32) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return *a == *b
 }
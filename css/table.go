@@ -0,0 +1,53 @@
+package css
+
+// BorderCollapse is the value of border-collapse: whether the borders of
+// neighboring table cells are drawn apart or over each other
+type BorderCollapse uint8
+
+const (
+	BorderCollapseSeparate BorderCollapse = iota
+	BorderCollapseCollapse
+)
+
+func (b BorderCollapse) String() string {
+	if b == BorderCollapseCollapse {
+		return "collapse"
+	}
+	return "separate"
+}
+
+func parseBorderCollapse(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "separate":
+		return Value{Keyword: uint8(BorderCollapseSeparate)}, true
+	case "collapse":
+		return Value{Keyword: uint8(BorderCollapseCollapse)}, true
+	}
+	return Value{}, false
+}
+
+// CaptionSide is the value of caption-side: whether a table's caption is
+// above its rows or below them
+type CaptionSide uint8
+
+const (
+	CaptionSideTop CaptionSide = iota
+	CaptionSideBottom
+)
+
+func (c CaptionSide) String() string {
+	if c == CaptionSideBottom {
+		return "bottom"
+	}
+	return "top"
+}
+
+func parseCaptionSide(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "top":
+		return Value{Keyword: uint8(CaptionSideTop)}, true
+	case "bottom":
+		return Value{Keyword: uint8(CaptionSideBottom)}, true
+	}
+	return Value{}, false
+}
@@ -0,0 +1,41 @@
+package css
+
+import "testing"
+
+func TestTableDisplays(t *testing.T) {
+	for _, display := range []string{"table", "table-row-group", "table-header-group", "table-footer-group", "table-row", "table-cell", "table-caption"} {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "div { display: "+display+"; }")) {
+			t.Errorf("expected display: %s to apply", display)
+			continue
+		}
+		if got := style.Serialize(PropDisplay); got != display {
+			t.Errorf("expected %s, got %s", display, got)
+		}
+	}
+}
+
+func TestBorderCollapse(t *testing.T) {
+	parent := DefaultStyle()
+	if parent.BorderCollapse != BorderCollapseSeparate || parent.CaptionSide != CaptionSideTop {
+		t.Errorf("expected separate borders and captions on top by default, got %v and %v", parent.BorderCollapse, parent.CaptionSide)
+	}
+	for _, decl := range []string{"table { border-collapse: collapse; }", "table { caption-side: bottom; }"} {
+		if !ApplyDeclaration(&parent, firstDeclaration(t, decl)) {
+			t.Fatalf("expected %s to apply", decl)
+		}
+	}
+	if got := parent.Serialize(PropBorderCollapse); got != "collapse" {
+		t.Errorf("expected collapse, got %s", got)
+	}
+	if got := parent.Serialize(PropCaptionSide); got != "bottom" {
+		t.Errorf("expected bottom, got %s", got)
+	}
+	// Both are inherited, so cells and captions see those of their table
+	if child := InheritedStyle(parent); child.BorderCollapse != BorderCollapseCollapse || child.CaptionSide != CaptionSideBottom {
+		t.Errorf("expected border-collapse and caption-side to be inherited, got %v and %v", child.BorderCollapse, child.CaptionSide)
+	}
+	if err := ValidateDeclaration(firstDeclaration(t, "table { border-collapse: merge; }")); err == nil {
+		t.Error("expected an unknown keyword to be invalid")
+	}
+}
@@ -0,0 +1,315 @@
+package css
+
+import "strconv"
+
+// TabSize is the value of the tab-size property: how far apart tab stops
+// are, either as a number of spaces or as a length
+type TabSize struct {
+	Spaces float32
+	Width  float32 // used when Spaces is 0
+}
+
+// Stop returns the distance between tab stops in pixels, for a font whose
+// space is advance wide
+func (t TabSize) Stop(advance float32) float32 {
+	if t.Spaces > 0 {
+		return t.Spaces * advance
+	}
+	return t.Width
+}
+
+func parseTabSize(decl Declaration) (Value, bool) {
+	if len(decl.Values) != 1 {
+		return Value{}, false
+	}
+	v, ok := parseLength(decl.Values)
+	if !ok || v < 0 || decl.Values[0].Type == TokenPercentage {
+		return Value{}, false
+	}
+	if decl.Values[0].Type == TokenNumber {
+		return Value{TabSize: TabSize{Spaces: v}}, true
+	}
+	return Value{TabSize: TabSize{Width: v}}, true
+}
+
+// TextAlign is the value of text-align: how the lines of a block are placed
+// between its sides. Text runs left to right, so start is left and end is
+// right.
+type TextAlign uint8
+
+const (
+	TextAlignStart TextAlign = iota
+	TextAlignEnd
+	TextAlignLeft
+	TextAlignRight
+	TextAlignCenter
+	TextAlignJustify
+)
+
+func (a TextAlign) String() string {
+	switch a {
+	case TextAlignEnd:
+		return "end"
+	case TextAlignLeft:
+		return "left"
+	case TextAlignRight:
+		return "right"
+	case TextAlignCenter:
+		return "center"
+	case TextAlignJustify:
+		return "justify"
+	default:
+		return "start"
+	}
+}
+
+// Offset returns how far a line is moved from the left of its box, given
+// the room left beside it, in a direction where start and end are swapped
+// for rtl. A line that overflows stays at the left. A justified line is
+// stretched rather than moved, and then only where it wraps, so it stays
+// at the left too.
+func (a TextAlign) Offset(room float32, dir Direction) float32 {
+	room = max(room, 0)
+	switch {
+	case a == TextAlignRight,
+		a == TextAlignEnd && dir == DirectionLTR,
+		a == TextAlignStart && dir == DirectionRTL:
+		return room
+	case a == TextAlignCenter:
+		return room / 2
+	}
+	return 0
+}
+
+func parseTextAlign(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "start":
+		return Value{Keyword: uint8(TextAlignStart)}, true
+	case "end":
+		return Value{Keyword: uint8(TextAlignEnd)}, true
+	case "left":
+		return Value{Keyword: uint8(TextAlignLeft)}, true
+	case "right":
+		return Value{Keyword: uint8(TextAlignRight)}, true
+	case "center":
+		return Value{Keyword: uint8(TextAlignCenter)}, true
+	case "justify":
+		return Value{Keyword: uint8(TextAlignJustify)}, true
+	}
+	return Value{}, false
+}
+
+// WritingMode is the value of writing-mode: whether lines run across the
+// page or down it, and for the latter which way they are stacked
+type WritingMode uint8
+
+const (
+	HorizontalTB WritingMode = iota
+	VerticalRL               // lines stacked from the right
+	VerticalLR               // lines stacked from the left
+)
+
+func (w WritingMode) String() string {
+	switch w {
+	case VerticalRL:
+		return "vertical-rl"
+	case VerticalLR:
+		return "vertical-lr"
+	default:
+		return "horizontal-tb"
+	}
+}
+
+// Vertical reports whether lines run down the page
+func (w WritingMode) Vertical() bool {
+	return w != HorizontalTB
+}
+
+func parseWritingMode(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "horizontal-tb":
+		return Value{Keyword: uint8(HorizontalTB)}, true
+	case "vertical-rl":
+		return Value{Keyword: uint8(VerticalRL)}, true
+	case "vertical-lr":
+		return Value{Keyword: uint8(VerticalLR)}, true
+	}
+	return Value{}, false
+}
+
+// Direction is the value of direction: which way text runs in a line
+type Direction uint8
+
+const (
+	DirectionLTR Direction = iota
+	DirectionRTL
+)
+
+func (d Direction) String() string {
+	if d == DirectionRTL {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+func parseDirection(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "ltr":
+		return Value{Keyword: uint8(DirectionLTR)}, true
+	case "rtl":
+		return Value{Keyword: uint8(DirectionRTL)}, true
+	}
+	return Value{}, false
+}
+
+// WhiteSpace is the value of white-space: whether the spaces and line
+// breaks in text are collapsed or kept. Text is only broken where it has
+// line breaks, so nowrap is drawn as normal is, and pre-wrap as pre is.
+type WhiteSpace uint8
+
+const (
+	WhiteSpaceNormal WhiteSpace = iota
+	WhiteSpacePre
+	WhiteSpaceNowrap
+	WhiteSpacePreWrap
+	WhiteSpacePreLine // spaces are collapsed, line breaks kept
+)
+
+func (w WhiteSpace) String() string {
+	switch w {
+	case WhiteSpacePre:
+		return "pre"
+	case WhiteSpaceNowrap:
+		return "nowrap"
+	case WhiteSpacePreWrap:
+		return "pre-wrap"
+	case WhiteSpacePreLine:
+		return "pre-line"
+	default:
+		return "normal"
+	}
+}
+
+// KeepsSpaces reports whether runs of spaces are kept rather than collapsed
+func (w WhiteSpace) KeepsSpaces() bool {
+	return w == WhiteSpacePre || w == WhiteSpacePreWrap
+}
+
+// KeepsLineBreaks reports whether line breaks are kept rather than
+// collapsed into spaces
+func (w WhiteSpace) KeepsLineBreaks() bool {
+	return w.KeepsSpaces() || w == WhiteSpacePreLine
+}
+
+func parseWhiteSpace(decl Declaration) (Value, bool) {
+	switch decl.Value {
+	case "normal":
+		return Value{Keyword: uint8(WhiteSpaceNormal)}, true
+	case "pre":
+		return Value{Keyword: uint8(WhiteSpacePre)}, true
+	case "nowrap":
+		return Value{Keyword: uint8(WhiteSpaceNowrap)}, true
+	case "pre-wrap":
+		return Value{Keyword: uint8(WhiteSpacePreWrap)}, true
+	case "pre-line":
+		return Value{Keyword: uint8(WhiteSpacePreLine)}, true
+	}
+	return Value{}, false
+}
+
+// PseudoText is how the ::first-letter or ::first-line of an element's text
+// is drawn: the properties its rules set, over the style of the text
+type PseudoText struct {
+	Color      Color
+	Background Color
+	FontSize   float32
+	// HasColor and HasFontSize are set when the rules set color and
+	// font-size; other text keeps its own
+	HasColor, HasFontSize bool
+}
+
+// IsSet reports whether any rule styles the pseudo-element
+func (p PseudoText) IsSet() bool {
+	return p != PseudoText{}
+}
+
+// Over returns p with the properties it doesn't set taken from under
+func (p PseudoText) Over(under PseudoText) PseudoText {
+	if !p.HasColor {
+		p.Color, p.HasColor = under.Color, under.HasColor
+	}
+	if !p.HasFontSize {
+		p.FontSize, p.HasFontSize = under.FontSize, under.HasFontSize
+	}
+	if p.Background.A == 0 {
+		p.Background = under.Background
+	}
+	return p
+}
+
+// Apply returns the style of text in the pseudo-element
+func (p PseudoText) Apply(style Style) Style {
+	if p.HasColor {
+		style.Color = p.Color
+	}
+	if p.HasFontSize {
+		style.FontSize = p.FontSize
+	}
+	style.Background = p.Background
+	return style
+}
+
+// ApplyPseudoTextDeclaration applies a declaration of a ::first-letter or
+// ::first-line rule, with relative lengths resolved against the units of
+// the element, whose font size em is relative to. Only color,
+// background-color and font-size are supported; it reports false for other
+// properties and invalid values.
+func ApplyPseudoTextDeclaration(pseudo *PseudoText, decl Declaration, units Units) bool {
+	id, v, err := ParseValue(decl)
+	if err != nil {
+		return false
+	}
+	v = units.resolve(id, v, units.FontSize)
+	switch id {
+	case PropColor:
+		pseudo.Color, pseudo.HasColor = v.Color, true
+	case PropBackgroundColor:
+		pseudo.Background = v.Color
+	case PropFontSize:
+		pseudo.FontSize, pseudo.HasFontSize = v.Length, true
+	default:
+		return false
+	}
+	return true
+}
+
+// LineClamp is the value of line-clamp: how many lines an element's content
+// is cut off after, with an ellipsis, or 0 for none
+type LineClamp int
+
+func (c LineClamp) String() string {
+	if c == 0 {
+		return "none"
+	}
+	return strconv.Itoa(int(c))
+}
+
+func parseLineClamp(decl Declaration) (Value, bool) {
+	if decl.Value == "none" {
+		return Value{Auto: true}, true
+	}
+	if len(decl.Values) != 1 || decl.Values[0].Type != TokenNumber {
+		return Value{}, false
+	}
+	n, err := strconv.Atoi(decl.Values[0].Value)
+	if err != nil || n < 1 {
+		return Value{}, false
+	}
+	return Value{Length: float32(n)}, true
+}
+
+// expandWebkitLineClamp expands -webkit-line-clamp, the prefixed name
+// pages write line-clamp with, to line-clamp
+func expandWebkitLineClamp(decl Declaration) ([]Declaration, bool) {
+	return []Declaration{longhand("line-clamp", decl.Values...)}, true
+}
@@ -0,0 +1,183 @@
+package css
+
+import "testing"
+
+func TestTabSize(t *testing.T) {
+	if got := DefaultStyle().TabSize; got != (TabSize{Spaces: 8}) {
+		t.Errorf("expected a tab-size of 8 by default, got %v", got)
+	}
+
+	tests := []struct {
+		value string
+		want  TabSize
+		stop  float32
+	}{
+		{"4", TabSize{Spaces: 4}, 28},
+		{"0", TabSize{}, 0},
+		{"28px", TabSize{Width: 28}, 28},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "pre { tab-size: "+tt.value+"; }")) {
+			t.Errorf("tab-size: %s: expected the declaration to apply", tt.value)
+			continue
+		}
+		if style.TabSize != tt.want {
+			t.Errorf("tab-size: %s: expected %v, got %v", tt.value, tt.want, style.TabSize)
+		}
+		if got := style.TabSize.Stop(7); got != tt.stop {
+			t.Errorf("tab-size: %s: expected stops %vpx apart, got %v", tt.value, tt.stop, got)
+		}
+		if got := InheritedStyle(style).TabSize; got != tt.want {
+			t.Errorf("tab-size: %s: expected it to be inherited, got %v", tt.value, got)
+		}
+	}
+
+	for _, value := range []string{"-1", "50%", "auto"} {
+		style := DefaultStyle()
+		if ApplyDeclaration(&style, firstDeclaration(t, "pre { tab-size: "+value+"; }")) {
+			t.Errorf("tab-size: %s: expected the declaration to be rejected", value)
+		}
+	}
+}
+
+func TestPseudoText(t *testing.T) {
+	var letter PseudoText
+	for _, decl := range []string{"color: red", "font-size: 32px", "margin: 4px"} {
+		ApplyPseudoTextDeclaration(&letter, firstDeclaration(t, "p::first-letter { "+decl+"; }"), DefaultUnits())
+	}
+	want := PseudoText{Color: Color{R: 255, A: 255}, FontSize: 32, HasColor: true, HasFontSize: true}
+	if letter != want {
+		t.Errorf("expected %+v, got %+v", want, letter)
+	}
+
+	line := PseudoText{Color: Color{B: 255, A: 255}, Background: Color{G: 255, A: 255}, HasColor: true}
+	got := PseudoText{FontSize: 20, HasFontSize: true}.Over(line).Apply(DefaultStyle())
+	if got.Color != line.Color || got.Background != line.Background || got.FontSize != 20 {
+		t.Errorf("expected the first line's colors at 20px, got %v %v %v", got.Color, got.Background, got.FontSize)
+	}
+}
+
+func TestParseLegacyPseudoElements(t *testing.T) {
+	sheet, err := Parse("p:first-letter, p::first-line, a:hover { color: red; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sels := sheet.Rules[0].Selectors
+	if sels[0].PseudoElement != "first-letter" || sels[1].PseudoElement != "first-line" {
+		t.Errorf("expected pseudo-elements, got %+v", sels[:2])
+	}
+	if sels[2].PseudoElement != "" || len(sels[2].PseudoClasses) != 1 {
+		t.Errorf("expected :hover to stay a pseudo-class, got %+v", sels[2])
+	}
+}
+
+func TestWhiteSpace(t *testing.T) {
+	parent := DefaultStyle()
+	if parent.WhiteSpace != WhiteSpaceNormal {
+		t.Errorf("expected normal by default, got %v", parent.WhiteSpace)
+	}
+	if !ApplyDeclaration(&parent, firstDeclaration(t, "div { white-space: pre-line; }")) {
+		t.Fatal("expected the declaration to apply")
+	}
+	if got := parent.Serialize(PropWhiteSpace); got != "pre-line" {
+		t.Errorf("expected pre-line, got %s", got)
+	}
+	if child := InheritedStyle(parent); child.WhiteSpace != WhiteSpacePreLine {
+		t.Errorf("expected white-space to be inherited, got %v", child.WhiteSpace)
+	}
+	if parent.WhiteSpace.KeepsSpaces() || !parent.WhiteSpace.KeepsLineBreaks() {
+		t.Error("expected pre-line to collapse spaces and keep line breaks")
+	}
+	if err := ValidateDeclaration(firstDeclaration(t, "div { white-space: break-spaces; }")); err == nil {
+		t.Error("expected an unsupported keyword to be invalid")
+	}
+}
+
+func TestTextAlign(t *testing.T) {
+	tests := []struct {
+		value  string
+		offset float32
+	}{
+		{"start", 0},
+		{"left", 0},
+		{"center", 5},
+		{"right", 10},
+		{"end", 10},
+		{"justify", 0},
+	}
+	for _, tt := range tests {
+		style := DefaultStyle()
+		if !ApplyDeclaration(&style, firstDeclaration(t, "p { text-align: "+tt.value+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", tt.value)
+			continue
+		}
+		if got := style.Serialize(PropTextAlign); got != tt.value {
+			t.Errorf("%s: expected it back, got %s", tt.value, got)
+		}
+		if got := style.TextAlign.Offset(10, DirectionLTR); got != tt.offset {
+			t.Errorf("%s: expected an offset of %v, got %v", tt.value, tt.offset, got)
+		}
+	}
+	if got := TextAlignCenter.Offset(-10, DirectionLTR); got != 0 {
+		t.Errorf("expected an overflowing line to stay at the left, got %v", got)
+	}
+	if start, end := TextAlignStart.Offset(10, DirectionRTL), TextAlignEnd.Offset(10, DirectionRTL); start != 10 || end != 0 {
+		t.Errorf("expected start and end swapped for rtl, got %v and %v", start, end)
+	}
+}
+
+func TestWritingMode(t *testing.T) {
+	parent := DefaultStyle()
+	if parent.WritingMode.Vertical() {
+		t.Error("expected horizontal text by default")
+	}
+	for _, value := range []string{"vertical-lr", "horizontal-tb", "vertical-rl"} {
+		if !ApplyDeclaration(&parent, firstDeclaration(t, "html { writing-mode: "+value+"; }")) {
+			t.Errorf("%s: expected the declaration to apply", value)
+		}
+		if got := parent.Serialize(PropWritingMode); got != value {
+			t.Errorf("%s: expected it back, got %s", value, got)
+		}
+	}
+	if child := InheritedStyle(parent); child.WritingMode != VerticalRL {
+		t.Errorf("expected writing-mode to be inherited, got %v", child.WritingMode)
+	}
+}
+
+func TestDirection(t *testing.T) {
+	parent := DefaultStyle()
+	if !ApplyDeclaration(&parent, firstDeclaration(t, "p { direction: rtl; }")) {
+		t.Fatal("expected direction: rtl to apply")
+	}
+	if parent.Direction != DirectionRTL || parent.Serialize(PropDirection) != "rtl" {
+		t.Errorf("expected rtl, got %s", parent.Serialize(PropDirection))
+	}
+	if child := InheritedStyle(parent); child.Direction != DirectionRTL {
+		t.Error("expected direction to be inherited")
+	}
+}
+
+func TestLineClamp(t *testing.T) {
+	sheet, err := Parse(`p { display: -webkit-box; -webkit-line-clamp: 3; }`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	style := DefaultStyle()
+	for _, decl := range sheet.Rules[0].Declarations {
+		if !ApplyDeclaration(&style, decl) {
+			t.Errorf("expected %s to apply", decl.Property)
+		}
+	}
+	if style.Display != DisplayBlock || style.LineClamp != 3 {
+		t.Errorf("expected a block clamped to 3 lines, got %s and %s", style.Display, style.Serialize(PropLineClamp))
+	}
+	if child := InheritedStyle(style); child.LineClamp != 0 {
+		t.Error("expected line-clamp not to be inherited")
+	}
+	for _, value := range []string{"0", "1.5", "-2"} {
+		if err := ValidateDeclaration(firstDeclaration(t, "p { line-clamp: "+value+"; }")); err == nil {
+			t.Errorf("%s: expected the value to be invalid", value)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package css
+
+import "sync"
+
+// userAgentCSS is the default stylesheet, after the one browsers share.
+// Lengths are in pixels for a 16px font, since penny reads every unit as
+// one.
+const userAgentCSS = `
+area, base, col, colgroup, link, meta, script, style, template, title, [hidden] { display: none; }
+audio:not([controls]) { display: none; }
+body { margin: 8px; }
+p, dl, pre { margin: 16px 0; }
+ul, ol { margin: 16px 0; padding-left: 40px; }
+ul ul, ul ol, ol ul, ol ol { margin: 0; }
+li { display: list-item; }
+ol { list-style-type: decimal; }
+ul ul, ol ul { list-style-type: circle; }
+ul ul ul, ul ol ul, ol ul ul, ol ol ul { list-style-type: square; }
+dd { margin-left: 40px; }
+blockquote, figure { margin: 16px 40px; }
+hr { margin: 8px 0; border: 1px inset gray; height: 0; }
+h1, h2, h3, h4, h5, h6, th { font-weight: bold; }
+th, center { text-align: center; }
+b, strong { font-weight: bolder; }
+i, em, cite, var, dfn, address { font-style: italic; }
+a { color: #0000ee; text-decoration: underline; }
+u, ins { text-decoration: underline; }
+s, strike, del { text-decoration: line-through; }
+h1 { font-size: 32px; margin: 21.44px 0; }
+h2 { font-size: 24px; margin: 19.92px 0; }
+h3 { font-size: 18.72px; margin: 18.72px 0; }
+h4 { font-size: 16px; margin: 21.28px 0; }
+h5 { font-size: 13.28px; margin: 22.18px 0; }
+h6 { font-size: 10.72px; margin: 24.98px 0; }
+pre, code, kbd, samp, tt { font-family: monospace; }
+pre, listing { white-space: pre; }
+textarea { white-space: pre-wrap; }
+table { display: table; }
+caption { display: table-caption; text-align: center; }
+thead { display: table-header-group; }
+tbody { display: table-row-group; }
+tfoot { display: table-footer-group; }
+tr { display: table-row; }
+td, th { display: table-cell; padding: 1px; }
+ruby { display: ruby; }
+rt { display: ruby-text; font-size: 50%; }
+rp { display: none; }
+`
+
+var userAgentStylesheet = sync.OnceValue(func() *Stylesheet {
+	sheet, _ := Parse(userAgentCSS)
+	sheet.SetOrigin(OriginUserAgent)
+	return sheet
+})
+
+// UserAgentStylesheet returns penny's default stylesheet, with its rules in
+// the user agent origin. The stylesheet is shared and must not be modified;
+// Append it to the page's stylesheet instead.
+func UserAgentStylesheet() *Stylesheet {
+	return userAgentStylesheet()
+}
@@ -0,0 +1,119 @@
+package css
+
+import "strings"
+
+// Unit is the unit of a length as written. Absolute lengths are converted
+// to pixels as they are parsed; relative ones are resolved when they are
+// applied to a style, against Units.
+type Unit uint8
+
+const (
+	UnitPx Unit = iota
+	// UnitEm is relative to the font size of the element, or of its parent
+	// for font-size itself
+	UnitEm
+	// UnitRem is relative to the font size of the root element
+	UnitRem
+	// UnitVw and UnitVh are hundredths of the viewport's width and height
+	UnitVw
+	UnitVh
+	// UnitVmin and UnitVmax are hundredths of the smaller and the larger
+	// side of the viewport
+	UnitVmin
+	UnitVmax
+	// UnitPercent is a percentage, relative to the parent's font size for
+	// font-size
+	UnitPercent
+)
+
+// pixelsPer is how many pixels an absolute unit is, at 96 pixels an inch
+var pixelsPer = map[string]float32{
+	"px": 1,
+	"in": 96,
+	"cm": 96 / 2.54,
+	"mm": 96 / 25.4,
+	"q":  96 / 101.6,
+	"pt": 96.0 / 72,
+	"pc": 16,
+}
+
+// relativeUnits are the relative units by name
+var relativeUnits = map[string]Unit{
+	"em":   UnitEm,
+	"rem":  UnitRem,
+	"vw":   UnitVw,
+	"vh":   UnitVh,
+	"vmin": UnitVmin,
+	"vmax": UnitVmax,
+}
+
+// Units are what relative lengths are resolved against when a declaration
+// applies to an element
+type Units struct {
+	// FontSize is the font size of the element
+	FontSize float32
+	// RootFontSize is the font size of the root element
+	RootFontSize float32
+	// ViewportWidth and ViewportHeight are the size of the viewport the
+	// page is laid out in
+	ViewportWidth, ViewportHeight float32
+}
+
+// DefaultUnits returns the units of an element of the initial font size in
+// the default media context
+func DefaultUnits() Units {
+	media := DefaultMediaContext()
+	return Units{
+		FontSize:       initialStyle.FontSize,
+		RootFontSize:   initialStyle.FontSize,
+		ViewportWidth:  media.Width,
+		ViewportHeight: media.Height,
+	}
+}
+
+// Length returns a length of unit in pixels. parentFontSize is what em and
+// percentages of font-size are relative to.
+func (u Units) Length(v float32, unit Unit, id PropertyID, parentFontSize float32) float32 {
+	switch unit {
+	case UnitEm:
+		if id == PropFontSize {
+			return v * parentFontSize
+		}
+		return v * u.FontSize
+	case UnitRem:
+		return v * u.RootFontSize
+	case UnitVw:
+		return v * u.ViewportWidth / 100
+	case UnitVh:
+		return v * u.ViewportHeight / 100
+	case UnitVmin:
+		return v * min(u.ViewportWidth, u.ViewportHeight) / 100
+	case UnitVmax:
+		return v * max(u.ViewportWidth, u.ViewportHeight) / 100
+	case UnitPercent:
+		if id == PropFontSize {
+			return v * parentFontSize / 100
+		}
+	}
+	return v
+}
+
+// resolve returns a value of property id with its length in pixels
+func (u Units) resolve(id PropertyID, v Value, parentFontSize float32) Value {
+	if v.Unit != UnitPx {
+		v.Length = u.Length(v.Length, v.Unit, id, parentFontSize)
+		v.Unit = UnitPx
+	}
+	return v
+}
+
+// lengthUnit returns the unit of a dimension, and how many pixels it is if
+// it is absolute. It reports false for an unknown unit.
+func lengthUnit(name string) (Unit, float32, bool) {
+	name = strings.ToLower(name)
+	if px, ok := pixelsPer[name]; ok {
+		return UnitPx, px, true
+	}
+	unit, ok := relativeUnits[name]
+	return unit, 1, ok
+}
@@ -0,0 +1,46 @@
+package css
+
+import "testing"
+
+func TestUnits(t *testing.T) {
+	parent := DefaultStyle()
+	parent.FontSize = 10
+	units := Units{FontSize: 20, RootFontSize: 8, ViewportWidth: 400, ViewportHeight: 300}
+
+	tests := []struct {
+		input string
+		get   func(s *Style) float32
+		want  float32
+	}{
+		// em is relative to the element's font size, and to the parent's
+		// for font-size itself
+		{"p { width: 2em; }", func(s *Style) float32 { return *s.Width }, 40},
+		{"p { font-size: 2em; }", func(s *Style) float32 { return s.FontSize }, 20},
+		{"p { font-size: 150%; }", func(s *Style) float32 { return s.FontSize }, 15},
+		{"p { margin-left: 3rem; }", func(s *Style) float32 { return s.Margin.Left }, 24},
+		{"p { padding-top: 10vw; }", func(s *Style) float32 { return s.Padding.Top }, 40},
+		{"p { height: 10vh; }", func(s *Style) float32 { return *s.Height }, 30},
+		{"p { top: 10vmin; }", func(s *Style) float32 { return s.Offsets.Top.Length }, 30},
+		{"p { left: 10VMAX; }", func(s *Style) float32 { return s.Offsets.Left.Length }, 40},
+		{"p { border-top-width: 3pt; }", func(s *Style) float32 { return s.Border.Top }, 4},
+		{"p { width: 1in; }", func(s *Style) float32 { return *s.Width }, 96},
+	}
+	for _, tt := range tests {
+		style := InheritedStyle(parent)
+		if !ApplyDeclarationIn(&style, &parent, firstDeclaration(t, tt.input), units) {
+			t.Errorf("%s: expected the declaration to apply", tt.input)
+			continue
+		}
+		if got := tt.get(&style); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.input, tt.want, got)
+		}
+	}
+
+	// Without units of an element, relative lengths take the defaults
+	style := DefaultStyle()
+	ApplyDeclaration(&style, firstDeclaration(t, "p { width: 2em; }"))
+	ApplyDeclaration(&style, firstDeclaration(t, "p { height: 50vw; }"))
+	if *style.Width != 32 || *style.Height != 400 {
+		t.Errorf("expected 32x400, got %vx%v", *style.Width, *style.Height)
+	}
+}
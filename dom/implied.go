@@ -0,0 +1,198 @@
+package dom
+
+import (
+	"slices"
+
+	"github.com/myuon/penny/intern"
+)
+
+// HTML lets many end tags be left out: "<p>one<p>two" is two paragraphs,
+// and a <li> or a table cell ends where the next one starts. The parser
+// closes these elements where the HTML5 tree construction rules imply their
+// end, and adds the <tbody> and <tr> a table row or cell needs, so that
+// pages written this way get the DOM browsers build for them.
+
+// closesParagraph are the start tags that close an open <p>
+var closesParagraph = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"center": true, "details": true, "dialog": true, "dir": true, "div": true,
+	"dl": true, "fieldset": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hgroup": true,
+	"hr": true, "li": true, "listing": true, "main": true, "menu": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "search": true,
+	"section": true, "summary": true, "table": true, "ul": true,
+	"dd": true, "dt": true, "xmp": true, "plaintext": true,
+}
+
+// scopeBoundaries are the elements an element in scope isn't searched for
+// past, such as a <p> outside of the table cell being parsed
+var scopeBoundaries = map[string]bool{
+	"html": true, "table": true, "td": true, "th": true, "caption": true,
+	"marquee": true, "object": true, "applet": true, "template": true,
+	"button": true,
+}
+
+// specialTags are the elements an open <li>, <dt> or <dd> isn't closed
+// past when the next one starts, such as the <ul> of a nested list.
+// <address>, <div> and <p> are left out, as the rules say.
+var specialTags = map[string]bool{
+	"applet": true, "article": true, "aside": true, "blockquote": true,
+	"body": true, "button": true, "caption": true, "center": true,
+	"details": true, "dialog": true, "dir": true, "dl": true,
+	"fieldset": true, "figcaption": true, "figure": true, "footer": true,
+	"form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "header": true, "hgroup": true, "html": true, "iframe": true,
+	"listing": true, "main": true, "marquee": true, "menu": true, "nav": true,
+	"object": true, "ol": true, "pre": true, "section": true, "select": true,
+	"summary": true, "table": true, "tbody": true, "td": true,
+	"template": true, "textarea": true, "tfoot": true, "th": true,
+	"thead": true, "tr": true, "ul": true, "xmp": true,
+}
+
+// closeImplied closes the open elements whose end is implied by a start
+// tag, and opens the table elements it implies
+func (p *Parser) closeImplied(tag string) {
+	if closesParagraph[tag] {
+		p.closeInScope("p")
+	}
+
+	switch tag {
+	case "li":
+		p.closeListItem("li")
+	case "dt", "dd":
+		p.closeListItem("dt", "dd")
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		// Headings don't nest
+		if isHeading(p.currentTag()) {
+			p.pop()
+		}
+	case "option":
+		if p.currentTag() == "option" {
+			p.pop()
+		}
+	case "optgroup":
+		if p.currentTag() == "option" {
+			p.pop()
+		}
+		if p.currentTag() == "optgroup" {
+			p.pop()
+		}
+	case "thead", "tbody", "tfoot":
+		p.closeInTable("tr", "td", "th", "thead", "tbody", "tfoot")
+	case "tr":
+		p.closeInTable("tr", "td", "th")
+		p.openImplied("table", "tbody")
+	case "td", "th":
+		p.closeInTable("td", "th")
+		p.openImplied("table", "tbody")
+		p.openImplied("tbody", "tr")
+		p.openImplied("thead", "tr")
+		p.openImplied("tfoot", "tr")
+	}
+}
+
+// closeInScope closes the innermost open element with the tag, unless a
+// scope boundary is open inside it
+func (p *Parser) closeInScope(tag string) bool {
+	// Searching only when one is open keeps deep nesting linear
+	if p.open[tag] == 0 {
+		return false
+	}
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		switch t := p.dom.Nodes[p.stack[i]].Tag; {
+		case t == tag:
+			p.popTo(i)
+			return true
+		case scopeBoundaries[t]:
+			return false
+		}
+	}
+	return false
+}
+
+// closeListItem closes the innermost open element with one of the tags, as
+// long as no special element other than <address>, <div> and <p> is open
+// inside it
+func (p *Parser) closeListItem(tags ...string) {
+	if !slices.ContainsFunc(tags, func(tag string) bool { return p.open[tag] > 0 }) {
+		return
+	}
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		t := p.dom.Nodes[p.stack[i]].Tag
+		for _, tag := range tags {
+			if t == tag {
+				p.popTo(i)
+				return
+			}
+		}
+		if specialTags[t] {
+			return
+		}
+	}
+}
+
+// closeInTable closes the open elements with the tags inside the innermost
+// table, up to the outermost of them
+func (p *Parser) closeInTable(tags ...string) {
+	end := -1
+	for i := len(p.stack) - 1; i >= 0; i-- {
+		t := p.dom.Nodes[p.stack[i]].Tag
+		if t == "table" {
+			break
+		}
+		for _, tag := range tags {
+			if t == tag {
+				end = i
+			}
+		}
+	}
+	if end >= 0 {
+		p.popTo(end)
+	}
+}
+
+// openImplied opens an element with the tag if the current element is
+// parent, as a <tbody> is implied around the rows of a table
+func (p *Parser) openImplied(parent, tag string) {
+	if p.currentTag() != parent {
+		return
+	}
+	nodeID := p.dom.CreateElement(intern.String(tag))
+	p.dom.AppendChild(p.currentParent(), nodeID)
+	p.push(nodeID)
+}
+
+// currentTag returns the tag of the current element, or "" if there is none
+func (p *Parser) currentTag() string {
+	if len(p.stack) == 0 {
+		return ""
+	}
+	return p.dom.Nodes[p.stack[len(p.stack)-1]].Tag
+}
+
+// push opens an element, making it the current one
+func (p *Parser) push(nodeID NodeID) {
+	p.stack = append(p.stack, nodeID)
+	p.open[p.dom.Nodes[nodeID].Tag]++
+}
+
+// popTo closes the open elements from the one at index i of the stack up
+func (p *Parser) popTo(i int) {
+	for _, nodeID := range p.stack[i:] {
+		p.open[p.dom.Nodes[nodeID].Tag]--
+	}
+	p.stack = p.stack[:i]
+}
+
+func (p *Parser) pop() {
+	p.popTo(len(p.stack) - 1)
+}
+
+func isHeading(tag string) bool {
+	switch tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+)
+
+// outline serializes the children of <body> as markup with every end tag
+// written out
+func outline(d *DOM) string {
+	var sb strings.Builder
+	var write func(id NodeID)
+	write = func(id NodeID) {
+		node := d.GetNode(id)
+		if node.Type == NodeTypeText {
+			sb.WriteString(node.Text)
+			return
+		}
+		sb.WriteString("<" + node.Tag + ">")
+		for _, child := range node.Children {
+			write(child)
+		}
+		sb.WriteString("</" + node.Tag + ">")
+	}
+	for _, node := range d.Nodes {
+		if node.Tag == "body" {
+			for _, child := range node.Children {
+				write(child)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func TestImpliedEndTags(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"<p>one<p>two", "<p>one</p><p>two</p>"},
+		{"<p>one<div>two</div>", "<p>one</p><div>two</div>"},
+		{"<p>one<span>two</span>", "<p>one<span>two</span></p>"},
+		{"<ul><li>one<li>two</ul>", "<ul><li>one</li><li>two</li></ul>"},
+		{"<ul><li>one<ul><li>two</ul><li>three</ul>", "<ul><li>one<ul><li>two</li></ul></li><li>three</li></ul>"},
+		{"<li><div>one<li>two", "<li><div>one</div></li><li>two</li>"},
+		{"<dl><dt>a<dd>b<dt>c<dd>d</dl>", "<dl><dt>a</dt><dd>b</dd><dt>c</dt><dd>d</dd></dl>"},
+		{"<select><option>a<option>b</select>", "<select><option>a</option><option>b</option></select>"},
+		{"<h1>a<h2>b", "<h1>a</h1><h2>b</h2>"},
+		{"<table><tr><td>a<td>b<tr><td>c</table>",
+			"<table><tbody><tr><td>a</td><td>b</td></tr><tr><td>c</td></tr></tbody></table>"},
+		{"<table><thead><tr><th>a<tbody><tr><td>b</table>",
+			"<table><thead><tr><th>a</th></tr></thead><tbody><tr><td>b</td></tr></tbody></table>"},
+		{"<table><td><p>a<table><td>b</table>c</table>",
+			"<table><tbody><tr><td><p>a</p><table><tbody><tr><td>b</td></tr></tbody></table>c</td></tr></tbody></table>"},
+		{"<div>a</p>b</div>", "<div>a<p></p>b</div>"},
+	}
+	for _, tt := range tests {
+		d, err := ParseString(tt.input)
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		if got := outline(d); got != tt.want {
+			t.Errorf("%s:\nexpected %s\n     got %s", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestImpliedEndTagsDeeplyNested(t *testing.T) {
+	// Each <div> would close an open <p>, and each <li> an open <li>, so
+	// with neither open the stack mustn't be searched for them, or this
+	// takes minutes
+	const depth = 100000
+	input := strings.Repeat("<div><span>", depth) + "<p>a<li>b<div>c"
+	d, err := ParseString(input)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	got := outline(d)
+	if want := "<span><p>a</p><li>b<div>c</div></li></span>"; !strings.Contains(got, want) {
+		t.Errorf("expected the innermost elements to be %s", want)
+	}
+}
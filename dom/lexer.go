@@ -64,6 +64,21 @@ func (t Token) String() string {
 type Lexer struct {
 	input string
 	pos   int
+	// rawText is the tag of the element whose contents are being read as
+	// raw text, if any
+	rawText string
+}
+
+// rawTextTags are the elements whose contents aren't markup: everything up
+// to their end tag is a single text token
+var rawTextTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"textarea": true,
+	"title":    true,
+	"xmp":      true,
+	"noembed":  true,
+	"noframes": true,
 }
 
 func NewLexer(input string) *Lexer {
@@ -108,6 +123,12 @@ func (l *Lexer) NextToken() Token {
 		return Token{Type: TokenEOF}
 	}
 
+	if l.rawText != "" {
+		if tok, ok := l.rawTextContent(); ok {
+			return tok
+		}
+	}
+
 	if l.peek() == '<' {
 		return l.tag()
 	}
@@ -115,6 +136,38 @@ func (l *Lexer) NextToken() Token {
 	return l.text()
 }
 
+// rawTextContent reads the contents of a raw text element up to its end
+// tag, which is matched without regard to case. Any other "</" is text.
+// It reports false if the element is empty, leaving the end tag to be read.
+func (l *Lexer) rawTextContent() (Token, bool) {
+	tag := l.rawText
+	l.rawText = ""
+
+	start := l.pos
+	for {
+		i := strings.Index(l.input[l.pos:], "</")
+		if i < 0 {
+			l.pos = len(l.input)
+			break
+		}
+		l.pos += i
+		name := l.input[l.pos+2 : min(l.pos+2+len(tag), len(l.input))]
+		next := byte('>')
+		if end := l.pos + 2 + len(tag); end < len(l.input) {
+			next = l.input[end]
+		}
+		if strings.EqualFold(name, tag) && (next == '>' || next == '/' || unicode.IsSpace(rune(next))) {
+			break
+		}
+		l.pos += 2
+	}
+
+	if l.pos == start {
+		return Token{}, false
+	}
+	return Token{Type: TokenText, Data: l.input[start:l.pos]}, true
+}
+
 func (l *Lexer) text() Token {
 	start := l.pos
 	for l.pos < len(l.input) && l.peek() != '<' {
@@ -207,6 +260,9 @@ func (l *Lexer) startTag() Token {
 		l.advance() // consume '>'
 	}
 
+	if rawTextTags[tagName] {
+		l.rawText = tagName
+	}
 	return Token{Type: TokenStartTag, Data: tagName, Attributes: attrs}
 }
 
@@ -80,3 +80,40 @@ func TestLexerComment(t *testing.T) {
 		t.Errorf("unexpected comment content: %q", tok.Data)
 	}
 }
+
+func TestLexerRawText(t *testing.T) {
+	input := `<script>if (a < b && c</d) { x = "</p>"; }</SCRIPT ><style></style><title>a <b> c</title><p>x</p>`
+	var got []string
+	for _, tok := range NewLexer(input).Tokenize() {
+		got = append(got, tok.String())
+	}
+	want := []string{
+		`StartTag<script []>`,
+		`Text("if (a < b && c</d) { x = \"</p>\"; }")`,
+		`EndTag</script>`,
+		`StartTag<style []>`,
+		`EndTag</style>`,
+		`StartTag<title []>`,
+		`Text("a <b> c")`,
+		`EndTag</title>`,
+		`StartTag<p []>`,
+		`Text("x")`,
+		`EndTag</p>`,
+		`EOF("")`,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d tokens, got %d: %q", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("token %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestLexerUnclosedRawText(t *testing.T) {
+	tokens := NewLexer(`<textarea><b>x`).Tokenize()
+	if len(tokens) != 3 || tokens[1].Type != TokenText || tokens[1].Data != "<b>x" {
+		t.Errorf("expected the rest of the input as text, got %v", tokens)
+	}
+}
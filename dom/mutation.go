@@ -0,0 +1,57 @@
+package dom
+
+type MutationType int
+
+const (
+	MutationAttribute MutationType = iota // an attribute was set or removed
+	MutationChildList                     // a child was appended or removed
+	MutationText                          // the text of a text node changed
+	MutationState                         // the ElementState of an element changed
+)
+
+// Mutation describes a change made to the DOM after it was observed
+type Mutation struct {
+	Type   MutationType
+	Target NodeID // the changed node, or the parent for MutationChildList
+	Attr   string // for MutationAttribute
+	Child  NodeID // the appended or removed child, for MutationChildList
+}
+
+// Observe registers fn to be called after every mutation of the DOM
+func (d *DOM) Observe(fn func(Mutation)) {
+	d.checkMutable()
+	d.observers = append(d.observers, fn)
+}
+
+func (d *DOM) notify(m Mutation) {
+	for _, fn := range d.observers {
+		fn(m)
+	}
+}
+
+func (d *DOM) RemoveAttribute(nodeID NodeID, key string) {
+	d.checkMutable()
+	delete(d.Nodes[nodeID].Attr, key)
+	d.notify(Mutation{Type: MutationAttribute, Target: nodeID, Attr: key})
+}
+
+// RemoveChild detaches child from parent. The node stays in the arena and
+// can be appended again.
+func (d *DOM) RemoveChild(parent, child NodeID) {
+	d.checkMutable()
+	children := d.Nodes[parent].Children
+	for i, id := range children {
+		if id == child {
+			d.Nodes[parent].Children = append(children[:i], children[i+1:]...)
+			break
+		}
+	}
+	d.Nodes[child].Parent = InvalidNodeID
+	d.notify(Mutation{Type: MutationChildList, Target: parent, Child: child})
+}
+
+func (d *DOM) SetText(nodeID NodeID, text string) {
+	d.checkMutable()
+	d.Nodes[nodeID].Text = text
+	d.notify(Mutation{Type: MutationText, Target: nodeID})
+}
@@ -1,5 +1,7 @@
 package dom
 
+import "strings"
+
 type NodeID int
 
 const InvalidNodeID NodeID = -1
@@ -17,13 +19,25 @@ type Node struct {
 	Tag      string            // element
 	Attr     map[string]string // element
 	Text     string            // text
+	State    ElementState      // element
 	Parent   NodeID
 	Children []NodeID
 }
 
+// DOM is a document tree stored as an arena of nodes indexed by NodeID.
+//
+// A DOM is not safe for concurrent mutation: it belongs to the goroutine that
+// builds or edits it. Once Freeze has been called it is immutable, and any
+// number of goroutines may read it, e.g. to build layout trees in parallel.
 type DOM struct {
 	Nodes []Node
 	Root  NodeID
+
+	// Truncated is set when parsing stopped at ParseOptions.MaxNodes
+	Truncated bool
+
+	observers []func(Mutation)
+	frozen    bool
 }
 
 func NewDOM() *DOM {
@@ -34,6 +48,7 @@ func NewDOM() *DOM {
 }
 
 func (d *DOM) CreateElement(tag string) NodeID {
+	d.checkMutable()
 	id := NodeID(len(d.Nodes))
 	d.Nodes = append(d.Nodes, Node{
 		ID:       id,
@@ -47,6 +62,7 @@ func (d *DOM) CreateElement(tag string) NodeID {
 }
 
 func (d *DOM) CreateText(text string) NodeID {
+	d.checkMutable()
 	id := NodeID(len(d.Nodes))
 	d.Nodes = append(d.Nodes, Node{
 		ID:       id,
@@ -59,50 +75,59 @@ func (d *DOM) CreateText(text string) NodeID {
 }
 
 func (d *DOM) AppendChild(parent, child NodeID) {
+	d.checkMutable()
 	d.Nodes[parent].Children = append(d.Nodes[parent].Children, child)
 	d.Nodes[child].Parent = parent
+	d.notify(Mutation{Type: MutationChildList, Target: parent, Child: child})
 }
 
 func (d *DOM) SetAttribute(nodeID NodeID, key, value string) {
+	d.checkMutable()
 	d.Nodes[nodeID].Attr[key] = value
+	d.notify(Mutation{Type: MutationAttribute, Target: nodeID, Attr: key})
 }
 
-func (d *DOM) GetNode(id NodeID) *Node {
-	if id < 0 || int(id) >= len(d.Nodes) {
-		return nil
-	}
-	return &d.Nodes[id]
+// Freeze makes the DOM immutable so that it can be shared between
+// goroutines. Mutating a frozen DOM panics.
+func (d *DOM) Freeze() {
+	d.frozen = true
 }
 
-func (d *DOM) Dump() string {
-	var result string
-	d.dumpNode(d.Root, 0, &result)
-	return result
+// Frozen reports whether Freeze has been called
+func (d *DOM) Frozen() bool {
+	return d.frozen
 }
 
-func (d *DOM) dumpNode(id NodeID, indent int, result *string) {
-	node := d.GetNode(id)
-	if node == nil {
-		return
+func (d *DOM) checkMutable() {
+	if d.frozen {
+		panic("dom: mutation of a frozen DOM")
 	}
+}
 
-	prefix := ""
-	for i := 0; i < indent; i++ {
-		prefix += "  "
+func (d *DOM) GetNode(id NodeID) *Node {
+	if id < 0 || int(id) >= len(d.Nodes) {
+		return nil
 	}
+	return &d.Nodes[id]
+}
 
-	switch node.Type {
-	case NodeTypeElement:
-		attrs := ""
-		for k, v := range node.Attr {
-			attrs += " " + k + "=\"" + v + "\""
+func (d *DOM) Dump() string {
+	var sb strings.Builder
+
+	Walk(d, d.Root, func(node *Node, depth int) WalkAction {
+		prefix := strings.Repeat("  ", depth)
+		switch node.Type {
+		case NodeTypeElement:
+			attrs := ""
+			for k, v := range node.Attr {
+				attrs += " " + k + "=\"" + v + "\""
+			}
+			sb.WriteString(prefix + "<" + node.Tag + attrs + ">\n")
+		case NodeTypeText:
+			sb.WriteString(prefix + "\"" + node.Text + "\"\n")
 		}
-		*result += prefix + "<" + node.Tag + attrs + ">\n"
-	case NodeTypeText:
-		*result += prefix + "\"" + node.Text + "\"\n"
-	}
+		return WalkContinue
+	})
 
-	for _, childID := range node.Children {
-		d.dumpNode(childID, indent+1, result)
-	}
+	return sb.String()
 }
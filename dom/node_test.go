@@ -0,0 +1,33 @@
+package dom
+
+import "testing"
+
+func TestFreezeRejectsMutation(t *testing.T) {
+	dom, err := ParseString(`<html><body><p>a</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	dom.Freeze()
+	if !dom.Frozen() {
+		t.Fatal("expected DOM to be frozen")
+	}
+
+	mutations := map[string]func(){
+		"CreateElement": func() { dom.CreateElement("div") },
+		"CreateText":    func() { dom.CreateText("b") },
+		"AppendChild":   func() { dom.AppendChild(dom.Root, dom.Root) },
+		"SetAttribute":  func() { dom.SetAttribute(dom.Root, "id", "x") },
+		"SetText":       func() { dom.SetText(dom.Root, "b") },
+		"Observe":       func() { dom.Observe(func(Mutation) {}) },
+	}
+	for name, mutate := range mutations {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic on frozen DOM", name)
+				}
+			}()
+			mutate()
+		}()
+	}
+}
@@ -3,28 +3,50 @@ package dom
 import (
 	"io"
 	"strings"
+
+	"github.com/myuon/penny/intern"
 )
 
+// DefaultMaxNodes is the number of nodes ParseString creates before it stops
+// and marks the document as truncated
+const DefaultMaxNodes = 1 << 20
+
+// ParseOptions limits the resources spent on a document
+type ParseOptions struct {
+	// MaxNodes is the maximum number of nodes created; the rest of the
+	// document is dropped. 0 means no limit.
+	MaxNodes int
+}
+
 // Parser builds a DOM tree from tokens
 type Parser struct {
-	lexer  *Lexer
-	dom    *DOM
-	stack  []NodeID // stack of open elements
+	lexer *Lexer
+	dom   *DOM
+	stack []NodeID       // stack of open elements
+	open  map[string]int // the number of open elements of each tag
+	opts  ParseOptions
 }
 
 func Parse(r io.Reader) (*DOM, error) {
-	data, err := io.ReadAll(r)
-	if err != nil {
+	// strings.Builder hands over its buffer without copying it again
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, r); err != nil {
 		return nil, err
 	}
-	return ParseString(string(data))
+	return ParseString(sb.String())
 }
 
 func ParseString(s string) (*DOM, error) {
+	return ParseStringWithOptions(s, ParseOptions{MaxNodes: DefaultMaxNodes})
+}
+
+func ParseStringWithOptions(s string, opts ParseOptions) (*DOM, error) {
 	parser := &Parser{
 		lexer: NewLexer(s),
 		dom:   NewDOM(),
 		stack: []NodeID{},
+		open:  map[string]int{},
+		opts:  opts,
 	}
 
 	parser.parse()
@@ -34,6 +56,11 @@ func ParseString(s string) (*DOM, error) {
 
 func (p *Parser) parse() {
 	for {
+		if p.opts.MaxNodes > 0 && len(p.dom.Nodes) >= p.opts.MaxNodes {
+			p.dom.Truncated = true
+			break
+		}
+
 		tok := p.lexer.NextToken()
 		if tok.Type == TokenEOF {
 			break
@@ -65,13 +92,7 @@ func (p *Parser) currentParent() NodeID {
 
 // hasTagInStack returns true if the given tag exists in the stack
 func (p *Parser) hasTagInStack(tag string) bool {
-	for _, nodeID := range p.stack {
-		node := p.dom.GetNode(nodeID)
-		if node != nil && node.Tag == tag {
-			return true
-		}
-	}
-	return false
+	return p.open[tag] > 0
 }
 
 // ensureHtmlHead ensures that <html> and <head> elements exist in the DOM
@@ -81,7 +102,7 @@ func (p *Parser) ensureHtmlHead() {
 	if !p.hasTagInStack("html") && p.dom.Root == InvalidNodeID {
 		htmlID := p.dom.CreateElement("html")
 		p.dom.Root = htmlID
-		p.stack = append(p.stack, htmlID)
+		p.push(htmlID)
 	}
 
 	// Create <head> if not present
@@ -91,7 +112,7 @@ func (p *Parser) ensureHtmlHead() {
 		if parent != InvalidNodeID {
 			p.dom.AppendChild(parent, headID)
 		}
-		p.stack = append(p.stack, headID)
+		p.push(headID)
 	}
 }
 
@@ -100,7 +121,7 @@ func (p *Parser) closeHead() {
 	for i := len(p.stack) - 1; i >= 0; i-- {
 		node := p.dom.GetNode(p.stack[i])
 		if node != nil && node.Tag == "head" {
-			p.stack = p.stack[:i]
+			p.popTo(i)
 			return
 		}
 	}
@@ -113,7 +134,7 @@ func (p *Parser) ensureHtmlBody() {
 	if !p.hasTagInStack("html") && p.dom.Root == InvalidNodeID {
 		htmlID := p.dom.CreateElement("html")
 		p.dom.Root = htmlID
-		p.stack = append(p.stack, htmlID)
+		p.push(htmlID)
 	}
 
 	// Close <head> if it's open (transitioning from head to body)
@@ -128,7 +149,7 @@ func (p *Parser) ensureHtmlBody() {
 		if parent != InvalidNodeID {
 			p.dom.AppendChild(parent, bodyID)
 		}
-		p.stack = append(p.stack, bodyID)
+		p.push(bodyID)
 	}
 }
 
@@ -144,11 +165,13 @@ func (p *Parser) handleStartTag(tok Token) {
 	if isBodyContent(tag) && !p.hasTagInStack("body") {
 		p.ensureHtmlBody()
 	}
+	p.closeImplied(tag)
 
-	nodeID := p.dom.CreateElement(tag)
+	nodeID := p.dom.CreateElement(intern.String(tag))
 	for _, attr := range tok.Attributes {
-		p.dom.SetAttribute(nodeID, attr.Key, attr.Value)
+		p.dom.SetAttribute(nodeID, intern.String(attr.Key), internAttrValue(attr.Key, attr.Value))
 	}
+	p.dom.Nodes[nodeID].State = initialState(&p.dom.Nodes[nodeID])
 
 	parent := p.currentParent()
 	if parent != InvalidNodeID {
@@ -162,16 +185,27 @@ func (p *Parser) handleStartTag(tok Token) {
 
 	// Push to stack (for non-void elements)
 	if !isVoidElement(tag) {
-		p.stack = append(p.stack, nodeID)
+		p.push(nodeID)
 	}
 }
 
 func (p *Parser) handleEndTag(tok Token) {
+	// Like in browsers, a </p> without an open <p> makes an empty one
+	if tok.Data == "p" {
+		if !p.closeInScope("p") {
+			p.handleSelfClosingTag(Token{Type: TokenSelfClosingTag, Data: "p"})
+		}
+		return
+	}
+
 	// Pop from stack, looking for matching tag
+	if !p.hasTagInStack(tok.Data) {
+		return
+	}
 	for i := len(p.stack) - 1; i >= 0; i-- {
 		node := p.dom.GetNode(p.stack[i])
 		if node != nil && node.Tag == tok.Data {
-			p.stack = p.stack[:i]
+			p.popTo(i)
 			return
 		}
 	}
@@ -189,11 +223,13 @@ func (p *Parser) handleSelfClosingTag(tok Token) {
 	if isBodyContent(tag) && !p.hasTagInStack("body") {
 		p.ensureHtmlBody()
 	}
+	p.closeImplied(tag)
 
-	nodeID := p.dom.CreateElement(tag)
+	nodeID := p.dom.CreateElement(intern.String(tag))
 	for _, attr := range tok.Attributes {
-		p.dom.SetAttribute(nodeID, attr.Key, attr.Value)
+		p.dom.SetAttribute(nodeID, intern.String(attr.Key), internAttrValue(attr.Key, attr.Value))
 	}
+	p.dom.Nodes[nodeID].State = initialState(&p.dom.Nodes[nodeID])
 
 	parent := p.currentParent()
 	if parent != InvalidNodeID {
@@ -208,17 +244,55 @@ func (p *Parser) handleSelfClosingTag(tok Token) {
 }
 
 func (p *Parser) handleText(tok Token) {
-	text := strings.TrimSpace(tok.Data)
+	text := tok.Data
+	parentID := p.currentParent()
+	parent := p.dom.GetNode(parentID)
+	// Text is kept as written, whitespace included; layout collapses its
+	// spaces as its white-space says
+	switch {
+	case parent == nil:
+		// Whitespace outside the document is left out
+		if strings.Trim(text, htmlSpace) == "" {
+			return
+		}
+	case isPreformatted(parent.Tag) && len(parent.Children) == 0:
+		// A newline right after the start tag is dropped, so that the
+		// content can begin on its own line
+		text = strings.TrimPrefix(text, "\r")
+		text = strings.TrimPrefix(text, "\n")
+	}
 	if text == "" {
-		return // Skip whitespace-only text nodes
+		return
 	}
 
 	nodeID := p.dom.CreateText(text)
+	if parentID != InvalidNodeID {
+		p.dom.AppendChild(parentID, nodeID)
+	}
+}
 
-	parent := p.currentParent()
-	if parent != InvalidNodeID {
-		p.dom.AppendChild(parent, nodeID)
+// isPreformatted returns true for the elements whose text is preformatted,
+// and may start after a newline that isn't part of it
+func isPreformatted(tag string) bool {
+	switch tag {
+	case "pre", "listing", "textarea":
+		return true
+	}
+	return false
+}
+
+// htmlSpace is the whitespace of HTML: ASCII spaces, tabs, line breaks and
+// form feeds
+const htmlSpace = " \t\n\r\f"
+
+// internAttrValue interns the values selectors match against; other
+// attribute values are mostly unique and not worth the lookup
+func internAttrValue(key, value string) string {
+	switch key {
+	case "class", "id":
+		return intern.String(value)
 	}
+	return value
 }
 
 // isVoidElement returns true for HTML void elements that don't have closing tags
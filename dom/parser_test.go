@@ -1,6 +1,7 @@
 package dom
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -150,9 +151,9 @@ func TestParseNoBodyTagWithDiv(t *testing.T) {
 		t.Errorf("expected class='container', got %q", divNode.Attr["class"])
 	}
 
-	// Should have 2 children (h1 and p)
-	if len(divNode.Children) != 2 {
-		t.Errorf("expected 2 children, got %d", len(divNode.Children))
+	// Should have 2 element children (h1 and p), between whitespace
+	if children := elementChildren(dom, divNode); len(children) != 2 {
+		t.Errorf("expected 2 children, got %d", len(children))
 	}
 
 	t.Logf("DOM:\n%s", dom.Dump())
@@ -184,14 +185,14 @@ func TestParseMultipleTopLevelElements(t *testing.T) {
 		t.Errorf("expected 'body', got %q", bodyNode.Tag)
 	}
 
-	// Body should have 3 <p> children
-	if len(bodyNode.Children) != 3 {
-		t.Errorf("expected 3 children, got %d", len(bodyNode.Children))
+	// Body should have 3 <p> children, between whitespace
+	children := elementChildren(dom, bodyNode)
+	if len(children) != 3 {
+		t.Fatalf("expected 3 children, got %d", len(children))
 	}
 
 	expectedTexts := []string{"First", "Second", "Third"}
-	for i, childID := range bodyNode.Children {
-		child := dom.GetNode(childID)
+	for i, child := range children {
 		if child.Tag != "p" {
 			t.Errorf("expected 'p', got %q", child.Tag)
 		}
@@ -316,15 +317,15 @@ func TestParseVoidElements(t *testing.T) {
 		t.Errorf("expected 'div', got %q", divNode.Tag)
 	}
 
-	// Should have 4 void element children
-	if len(divNode.Children) != 4 {
-		t.Errorf("expected 4 children, got %d", len(divNode.Children))
+	// Should have 4 void element children, between whitespace
+	children := elementChildren(dom, divNode)
+	if len(children) != 4 {
+		t.Fatalf("expected 4 children, got %d", len(children))
 	}
 
 	// Check each void element
 	expectedTags := []string{"br", "hr", "img", "input"}
-	for i, childID := range divNode.Children {
-		child := dom.GetNode(childID)
+	for i, child := range children {
 		if child.Tag != expectedTags[i] {
 			t.Errorf("expected %q, got %q", expectedTags[i], child.Tag)
 		}
@@ -359,7 +360,10 @@ func TestParseMixedContent(t *testing.T) {
 
 	// Should have 3 children: "Hello ", <strong>, "!"
 	if len(pNode.Children) != 3 {
-		t.Errorf("expected 3 children, got %d", len(pNode.Children))
+		t.Fatalf("expected 3 children, got %d", len(pNode.Children))
+	}
+	if text := dom.GetNode(pNode.Children[0]).Text; text != "Hello " {
+		t.Errorf("expected the space before <strong> to be kept, got %q", text)
 	}
 
 	t.Logf("DOM:\n%s", dom.Dump())
@@ -444,9 +448,9 @@ func TestParseLinkThenBody(t *testing.T) {
 		t.Errorf("expected 'head', got %q", headNode.Tag)
 	}
 
-	// <head> should have <link>
-	if len(headNode.Children) != 1 {
-		t.Errorf("expected 1 child in head, got %d", len(headNode.Children))
+	// <head> should have <link>, and the line break after it
+	if len(headNode.Children) != 2 {
+		t.Errorf("expected 2 children in head, got %d", len(headNode.Children))
 	}
 	linkNode := dom.GetNode(headNode.Children[0])
 	if linkNode.Tag != "link" {
@@ -498,17 +502,18 @@ func TestParseMetaAndTitle(t *testing.T) {
 		t.Errorf("expected 'head', got %q", headNode.Tag)
 	}
 
-	// Head should have meta and title
-	if len(headNode.Children) != 2 {
-		t.Errorf("expected 2 children in head, got %d", len(headNode.Children))
+	// Head should have meta and title, between whitespace
+	children := elementChildren(dom, headNode)
+	if len(children) != 2 {
+		t.Fatalf("expected 2 children in head, got %d", len(children))
 	}
 
-	metaNode := dom.GetNode(headNode.Children[0])
+	metaNode := children[0]
 	if metaNode.Tag != "meta" {
 		t.Errorf("expected 'meta', got %q", metaNode.Tag)
 	}
 
-	titleNode := dom.GetNode(headNode.Children[1])
+	titleNode := children[1]
 	if titleNode.Tag != "title" {
 		t.Errorf("expected 'title', got %q", titleNode.Tag)
 	}
@@ -561,3 +566,87 @@ body { color: red; }
 
 	t.Logf("DOM:\n%s", dom.Dump())
 }
+
+func TestParseMaxNodes(t *testing.T) {
+	input := "<html><body>" + strings.Repeat("<p>item</p>", 100) + "</body></html>"
+
+	dom, err := ParseStringWithOptions(input, ParseOptions{MaxNodes: 50})
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if !dom.Truncated {
+		t.Error("expected the document to be truncated")
+	}
+	if len(dom.Nodes) != 50 {
+		t.Errorf("expected 50 nodes, got %d", len(dom.Nodes))
+	}
+
+	dom, err = ParseStringWithOptions(input, ParseOptions{})
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if dom.Truncated {
+		t.Error("expected no truncation without a limit")
+	}
+}
+
+func TestParseWhitespace(t *testing.T) {
+	input := "<p>  a \n\t b  c </p><pre>\n  x\n\ty  </pre><pre><code>\n\n  z</code> </pre>"
+
+	dom, err := ParseString(input)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	var texts []string
+	for _, node := range dom.Nodes {
+		if node.Type == NodeTypeText {
+			texts = append(texts, node.Text)
+		}
+	}
+	// Text is kept as written, whitespace between elements included,
+	// except for the newline right after the start tag of a <pre>
+	want := []string{"  a \n\t b  c ", "  x\n\ty  ", "\n\n  z", " "}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %q, got %q", want, texts)
+	}
+}
+
+func TestParseRawText(t *testing.T) {
+	input := "<html><head><style>\n  p > a { color: red; }\n</style></head>" +
+		"<body><script>if (1 <p) {}</script><p>text</p></body></html>"
+
+	dom, err := ParseString(input)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	var texts []string
+	var ps int
+	for _, node := range dom.Nodes {
+		switch {
+		case node.Type == NodeTypeText:
+			texts = append(texts, node.Text)
+		case node.Tag == "p":
+			ps++
+		}
+	}
+	// Raw text is kept as written, and markup inside it isn't parsed
+	want := []string{"\n  p > a { color: red; }\n", "if (1 <p) {}", "text"}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %q, got %q", want, texts)
+	}
+	if ps != 1 {
+		t.Errorf("expected a single <p>, got %d", ps)
+	}
+}
+
+// elementChildren returns the children of a node that are elements,
+// leaving out the text between them
+func elementChildren(d *DOM, node *Node) []*Node {
+	var children []*Node
+	for _, id := range node.Children {
+		if child := d.GetNode(id); child.Type == NodeTypeElement {
+			children = append(children, child)
+		}
+	}
+	return children
+}
@@ -0,0 +1,96 @@
+package dom
+
+import "strings"
+
+// ElementState is a set of the dynamic states of an element that
+// pseudo-classes such as :hover match
+type ElementState uint8
+
+const (
+	StateHover ElementState = 1 << iota
+	StateFocus
+	StateActive
+	StateVisited
+	StateChecked
+)
+
+var stateNames = []struct {
+	state ElementState
+	name  string
+}{
+	{StateHover, "hover"},
+	{StateFocus, "focus"},
+	{StateActive, "active"},
+	{StateVisited, "visited"},
+	{StateChecked, "checked"},
+}
+
+// ParseElementState returns the state a pseudo-class name, such as
+// "hover", stands for
+func ParseElementState(name string) (ElementState, bool) {
+	for _, s := range stateNames {
+		if s.name == name {
+			return s.state, true
+		}
+	}
+	return 0, false
+}
+
+func (s ElementState) String() string {
+	var names []string
+	for _, sn := range stateNames {
+		if s&sn.state != 0 {
+			names = append(names, sn.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// SetState turns state on or off for an element
+func (d *DOM) SetState(nodeID NodeID, state ElementState, on bool) {
+	d.checkMutable()
+	node := &d.Nodes[nodeID]
+	before := node.State
+	if on {
+		node.State |= state
+	} else {
+		node.State &^= state
+	}
+	if node.State != before {
+		d.notify(Mutation{Type: MutationState, Target: nodeID})
+	}
+}
+
+// MoveState gives state to an element, taking it from every other element
+// that had it. Hover and active also apply to the ancestors of the element,
+// as the pointer is over them too. InvalidNodeID clears the state
+// everywhere.
+func (d *DOM) MoveState(state ElementState, nodeID NodeID) {
+	d.checkMutable()
+	keep := make(map[NodeID]bool)
+	for id := nodeID; id != InvalidNodeID; id = d.Nodes[id].Parent {
+		keep[id] = true
+		if state&(StateHover|StateActive) == 0 {
+			break
+		}
+	}
+
+	for i := range d.Nodes {
+		id := d.Nodes[i].ID
+		if d.Nodes[i].State&state != 0 && !keep[id] {
+			d.SetState(id, state, false)
+		}
+	}
+	for id := range keep {
+		d.SetState(id, state, true)
+	}
+}
+
+// initialState returns the state an element starts in from its attributes
+func initialState(node *Node) ElementState {
+	var state ElementState
+	if _, ok := node.Attr["checked"]; ok {
+		state |= StateChecked
+	}
+	return state
+}
@@ -0,0 +1,63 @@
+package dom
+
+import "testing"
+
+func TestMoveState(t *testing.T) {
+	d, err := ParseString(`<html><body><div id="a"><p id="b">x</p></div><input id="c" type="checkbox" checked></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	byID := func(id string) NodeID {
+		for _, node := range d.Nodes {
+			if node.Attr["id"] == id {
+				return node.ID
+			}
+		}
+		t.Fatalf("element #%s not found", id)
+		return InvalidNodeID
+	}
+	a, b, c := byID("a"), byID("b"), byID("c")
+
+	if d.Nodes[c].State != StateChecked {
+		t.Errorf("expected a checked attribute to start checked, got %v", d.Nodes[c].State)
+	}
+
+	var changed []NodeID
+	d.Observe(func(m Mutation) {
+		if m.Type == MutationState {
+			changed = append(changed, m.Target)
+		}
+	})
+
+	// Hover applies to the ancestors too
+	d.MoveState(StateHover, b)
+	if d.Nodes[a].State&StateHover == 0 || d.Nodes[b].State&StateHover == 0 {
+		t.Errorf("expected #a and #b to be hovered")
+	}
+	changed = nil
+	d.MoveState(StateHover, c)
+	if d.Nodes[a].State&StateHover != 0 || d.Nodes[b].State&StateHover != 0 {
+		t.Errorf("expected the hover to leave #a and #b")
+	}
+	if d.Nodes[c].State != StateHover|StateChecked {
+		t.Errorf("expected #c to be hovered and checked, got %v", d.Nodes[c].State)
+	}
+	// The body and html stay hovered, so only #a, #b and #c change
+	if len(changed) != 3 {
+		t.Errorf("expected 3 state mutations, got %v", changed)
+	}
+
+	// Focus only applies to the element itself
+	d.MoveState(StateFocus, b)
+	if d.Nodes[a].State&StateFocus != 0 || d.Nodes[b].State&StateFocus == 0 {
+		t.Errorf("expected only #b to be focused")
+	}
+	d.MoveState(StateFocus, InvalidNodeID)
+	if d.Nodes[b].State&StateFocus != 0 {
+		t.Errorf("expected the focus to be cleared")
+	}
+
+	if s, ok := ParseElementState("visited"); !ok || s != StateVisited {
+		t.Errorf("expected visited to parse, got %v %v", s, ok)
+	}
+}
@@ -0,0 +1,49 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	dom, err := ParseString(`<html><body><div><p>a</p><p>b</p></div><span>c</span></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	visited := func(visit func(node *Node) WalkAction) string {
+		var tags []string
+		Walk(dom, dom.Root, func(node *Node, depth int) WalkAction {
+			if node.Type == NodeTypeElement {
+				tags = append(tags, strings.Repeat("-", depth)+node.Tag)
+			}
+			return visit(node)
+		})
+		return strings.Join(tags, " ")
+	}
+
+	got := visited(func(node *Node) WalkAction { return WalkContinue })
+	if want := "html -body --div ---p ---p --span"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	got = visited(func(node *Node) WalkAction {
+		if node.Tag == "div" {
+			return WalkSkipChildren
+		}
+		return WalkContinue
+	})
+	if want := "html -body --div --span"; got != want {
+		t.Errorf("expected %q with skipped children, got %q", want, got)
+	}
+
+	got = visited(func(node *Node) WalkAction {
+		if node.Tag == "p" {
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	if want := "html -body --div ---p"; got != want {
+		t.Errorf("expected %q when stopping, got %q", want, got)
+	}
+}
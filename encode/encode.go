@@ -0,0 +1,159 @@
+// Package encode writes rendered pages out as image files. Encoders are
+// registered by format name, so that the CLI's --format and the content
+// negotiation of a server pick from the same set, and embedders can add
+// formats penny has no encoder for, such as AVIF.
+package encode
+
+import (
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoder writes an image in a format
+type Encoder interface {
+	Encode(img image.Image, w io.Writer) error
+}
+
+// EncoderFunc is a function used as an Encoder
+type EncoderFunc func(img image.Image, w io.Writer) error
+
+func (f EncoderFunc) Encode(img image.Image, w io.Writer) error {
+	return f(img, w)
+}
+
+// Format is an image format an Encoder writes
+type Format struct {
+	Name     string // as given to --format, e.g. "png"
+	MIMEType string
+	// Extensions are the file extensions of the format, without the dot,
+	// the first of which is used for new files
+	Extensions []string
+	Encoder
+}
+
+// Extension returns the file extension of new files of the format, with
+// the dot
+func (f Format) Extension() string {
+	return "." + f.Extensions[0]
+}
+
+// jpegQuality is the quality pages are encoded as JPEG at
+const jpegQuality = 90
+
+var (
+	mu      sync.RWMutex
+	formats []Format
+)
+
+func init() {
+	Register(Format{Name: "png", MIMEType: "image/png", Extensions: []string{"png"}, Encoder: EncoderFunc(func(img image.Image, w io.Writer) error {
+		return png.Encode(w, img)
+	})})
+	Register(Format{Name: "jpeg", MIMEType: "image/jpeg", Extensions: []string{"jpg", "jpeg"}, Encoder: EncoderFunc(func(img image.Image, w io.Writer) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	})})
+	Register(Format{Name: "webp", MIMEType: "image/webp", Extensions: []string{"webp"}, Encoder: EncoderFunc(EncodeWebP)})
+}
+
+// Register adds a format, replacing any of the same name. The formats are
+// kept in the order they were first registered, which is the order of
+// preference when a client accepts several equally.
+func Register(f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	f.Name = strings.ToLower(f.Name)
+	if i := slices.IndexFunc(formats, func(g Format) bool { return g.Name == f.Name }); i >= 0 {
+		formats[i] = f
+		return
+	}
+	formats = append(formats, f)
+}
+
+// Formats returns the registered formats, in order of preference
+func Formats() []Format {
+	mu.RLock()
+	defer mu.RUnlock()
+	return slices.Clone(formats)
+}
+
+// Names returns the names of the registered formats, in order of preference
+func Names() []string {
+	var names []string
+	for _, f := range Formats() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// Lookup returns the format of a name or file extension, such as "jpg"
+func Lookup(name string) (Format, bool) {
+	name = strings.ToLower(name)
+	for _, f := range Formats() {
+		if f.Name == name || slices.Contains(f.Extensions, name) {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
+
+// ForFile returns the format of a file by its extension
+func ForFile(path string) (Format, bool) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "" {
+		return Format{}, false
+	}
+	return Lookup(ext)
+}
+
+// Negotiate returns the format a client prefers by its Accept header: the
+// registered format of the highest quality value, "image/*" and "*/*"
+// matching the most preferred format. An empty header accepts anything.
+// It reports false if the client accepts none of the formats.
+func Negotiate(accept string) (Format, bool) {
+	all := Formats()
+	if len(all) == 0 {
+		return Format{}, false
+	}
+	if strings.TrimSpace(accept) == "" {
+		return all[0], true
+	}
+	best, bestQ, bestSpecific := -1, 0.0, false
+	for _, r := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(r)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		i, specific := -1, true
+		switch mediaType {
+		case "*/*", "image/*":
+			i, specific = 0, false
+		default:
+			i = slices.IndexFunc(all, func(f Format) bool { return f.MIMEType == mediaType })
+		}
+		if i < 0 || q <= 0 {
+			continue
+		}
+		// A format named outright wins over a wildcard of the same quality
+		if q > bestQ || q == bestQ && specific && !bestSpecific {
+			best, bestQ, bestSpecific = i, q, specific
+		}
+	}
+	if best < 0 {
+		return Format{}, false
+	}
+	return all[best], true
+}
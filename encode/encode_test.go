@@ -0,0 +1,92 @@
+package encode
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"png", "png", true},
+		{"JPEG", "jpeg", true},
+		{"jpg", "jpeg", true},
+		{"webp", "webp", true},
+		{"gif", "", false},
+	}
+	for _, tt := range tests {
+		f, ok := Lookup(tt.name)
+		if ok != tt.ok || f.Name != tt.want {
+			t.Errorf("Lookup(%q) = %q, %v, want %q, %v", tt.name, f.Name, ok, tt.want, tt.ok)
+		}
+	}
+
+	if f, ok := ForFile("out/page.JPG"); !ok || f.Name != "jpeg" {
+		t.Errorf("ForFile(page.JPG) = %q, %v, want jpeg", f.Name, ok)
+	}
+	if _, ok := ForFile("out/page"); ok {
+		t.Error("ForFile of a file without an extension: want false")
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	decoders := map[string]func(io.Reader) (image.Image, error){
+		"png":  png.Decode,
+		"jpeg": jpeg.Decode,
+	}
+	for name, decode := range decoders {
+		f, _ := Lookup(name)
+		var buf bytes.Buffer
+		if err := f.Encode(img, &buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := decode(&buf); err != nil {
+			t.Errorf("%s: decoding the encoded image: %v", name, err)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", "png", true},
+		{"image/webp", "webp", true},
+		{"image/avif,image/webp,*/*;q=0.8", "webp", true},
+		{"image/jpeg;q=0.5, image/webp;q=0.9", "webp", true},
+		{"image/*, image/jpeg", "jpeg", true},
+		{"text/html, */*;q=0.1", "png", true},
+		{"image/webp;q=0", "", false},
+		{"text/html", "", false},
+	}
+	for _, tt := range tests {
+		f, ok := Negotiate(tt.accept)
+		if ok != tt.ok || f.Name != tt.want {
+			t.Errorf("Negotiate(%q) = %q, %v, want %q, %v", tt.accept, f.Name, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func(saved []Format) { formats = saved }(Formats())
+
+	Register(Format{Name: "AVIF", MIMEType: "image/avif", Extensions: []string{"avif"}, Encoder: EncoderFunc(func(image.Image, io.Writer) error {
+		return nil
+	})})
+	if f, ok := Negotiate("image/avif,image/webp"); !ok || f.Name != "avif" {
+		t.Errorf("Negotiate with avif registered = %q, %v, want avif", f.Name, ok)
+	}
+	if names := Names(); names[len(names)-1] != "avif" {
+		t.Errorf("Names() = %v, want avif last", names)
+	}
+}
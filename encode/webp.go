@@ -0,0 +1,122 @@
+package encode
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+)
+
+// maxWebPSize is the largest width or height a lossless WebP can have
+const maxWebPSize = 1 << 14
+
+// EncodeWebP writes an image as a lossless WebP. The pixels are written
+// with a fixed code of 8 bits a channel and no transforms, so the file is
+// about the size of the raw pixels, but any decoder reads them exactly.
+func EncodeWebP(img image.Image, w io.Writer) error {
+	b := img.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > maxWebPSize || b.Dy() > maxWebPSize {
+		return errors.New("webp: image size out of range")
+	}
+
+	var bw bitWriter
+	bw.write(0x2f, 8) // the VP8L signature
+	bw.write(uint32(b.Dx()-1), 14)
+	bw.write(uint32(b.Dy()-1), 14)
+	bw.write(1, 1) // alpha may be used
+	bw.write(0, 3) // version
+	bw.write(0, 1) // no transforms
+	bw.write(0, 1) // no color cache
+	bw.write(0, 1) // a single prefix code group
+
+	// green with the lengths of backward references, red, blue, alpha, and
+	// distance, the last of which is never used
+	for _, size := range []int{256 + 24, 256, 256, 256} {
+		writeLiteralCode(&bw, size)
+	}
+	bw.write(1, 1) // a simple code
+	bw.write(0, 1) // of one symbol
+	bw.write(0, 1) // of 1 bit
+	bw.write(0, 1) // which is 0
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			for _, v := range []uint8{c.G, c.R, c.B, c.A} {
+				bw.write(uint32(bits.Reverse8(v)), 8)
+			}
+		}
+	}
+	data := bw.flush()
+
+	chunk := len(data) + len(data)%2
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+chunk))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// writeLiteralCode writes a prefix code of size symbols which gives the
+// first 256 a code of 8 bits, the symbol's bits reversed, and none to the
+// rest. The code lengths are themselves coded with 1 bit, 0 for a length of
+// 0 and 1 for a length of 8.
+func writeLiteralCode(bw *bitWriter, size int) {
+	bw.write(0, 1) // a normal code
+	// The code length code's lengths come in the order 17, 18, 0, 1, 2, 3,
+	// 4, 5, 16, 6, 7, 8, so the first 12 cover 0 and 8
+	bw.write(12-4, 4)
+	for i := range 12 {
+		switch i {
+		case 2, 11:
+			bw.write(1, 3)
+		default:
+			bw.write(0, 3)
+		}
+	}
+	bw.write(0, 1) // a length for every symbol
+	for i := range size {
+		if i < 256 {
+			bw.write(1, 1)
+		} else {
+			bw.write(0, 1)
+		}
+	}
+}
+
+// bitWriter packs bits least significant first, as VP8L reads them
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	nacc uint
+}
+
+// write appends the n low bits of v
+func (bw *bitWriter) write(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.nacc
+	bw.nacc += n
+	for bw.nacc >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nacc -= 8
+	}
+}
+
+// flush pads the bits written to a whole byte and returns them
+func (bw *bitWriter) flush() []byte {
+	if bw.nacc > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nacc = 0, 0
+	}
+	return bw.buf
+}
@@ -0,0 +1,44 @@
+package encode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 7, 3))
+	for y := range 3 {
+		for x := range 7 {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 40), uint8(y * 90), uint8(x*y + 7), uint8(255 - x*10)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := EncodeWebP(img, &buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := webp.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Fatalf("bounds = %v, want %v", decoded.Bounds(), img.Bounds())
+	}
+	for y := range 3 {
+		for x := range 7 {
+			got := color.NRGBAModel.Convert(decoded.At(x, y))
+			if want := img.NRGBAAt(x, y); got != want {
+				t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestEncodeWebPSize(t *testing.T) {
+	if err := EncodeWebP(image.NewRGBA(image.Rect(0, 0, 0, 4)), &bytes.Buffer{}); err == nil {
+		t.Error("EncodeWebP of an empty image: want an error")
+	}
+}
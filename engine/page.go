@@ -0,0 +1,180 @@
+// Package engine loads pages and renders them: the pipeline from an input
+// to pixels that the commands and the tests share
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/loader"
+	"github.com/myuon/penny/paint"
+	"github.com/myuon/penny/profile"
+	"github.com/myuon/penny/styleloader"
+)
+
+// Page is a parsed document and where what it references is read from
+type Page struct {
+	DOM *dom.DOM
+	// URL is where the page was loaded from, which its references resolve
+	// against. It is nil for a local file.
+	URL *url.URL
+	// Dir is the directory of a local file, which its references are
+	// relative to
+	Dir string
+	// Fetch reads a resource the page references
+	Fetch loader.Fetch
+	// Images are the decoded images of the page, once Load has decoded them
+	Images *paint.ImageCache
+	// Timings are how long the page took to parse and load, so far
+	Timings []Timing
+	// Failed are the resources Load couldn't load
+	Failed []FailedResource
+
+	resources *loader.Loader
+}
+
+// FetchError is returned when a page or a resource it references can't be
+// read
+type FetchError struct {
+	Ref string
+	Err error
+}
+
+func (e *FetchError) Error() string { return fmt.Sprintf("failed to fetch %s: %v", e.Ref, e.Err) }
+func (e *FetchError) Unwrap() error { return e.Err }
+
+// ParseError is returned when a page or a stylesheet can't be parsed
+type ParseError struct {
+	Ref string
+	Err error
+}
+
+func (e *ParseError) Error() string { return fmt.Sprintf("failed to parse %s: %v", e.Ref, e.Err) }
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// IsURL reports whether an input is a page on the web rather than a file
+func IsURL(input string) bool {
+	return strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
+}
+
+// IsMHTML reports whether a file is a web archive, by its extension
+func IsMHTML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".mhtml" || ext == ".mht"
+}
+
+// Open loads the page at input: a URL, got with get, an MHTML web archive,
+// whose resources are loaded from it as if from the URL it was saved from,
+// or a local HTML file. The resources the page references start loading in
+// the background.
+func Open(input string, get loader.Getter) (*Page, error) {
+	switch {
+	case IsURL(input):
+		u, err := url.Parse(input)
+		if err != nil {
+			return nil, &ParseError{Ref: input, Err: err}
+		}
+		body, err := get(input)
+		if err != nil {
+			return nil, &FetchError{Ref: input, Err: err}
+		}
+		return Parse(body, u, get)
+	case IsMHTML(input):
+		archive, err := loader.OpenMHTML(input)
+		if errors.Is(err, loader.ErrNotMHTML) {
+			return nil, &ParseError{Ref: input, Err: err}
+		} else if err != nil {
+			return nil, &FetchError{Ref: input, Err: err}
+		}
+		u, err := url.Parse(archive.Root)
+		if err != nil {
+			return nil, &ParseError{Ref: input, Err: err}
+		}
+		return Parse(archive.Page(), u, archive.Get)
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, &FetchError{Ref: input, Err: err}
+	}
+	d, parsing, err := parseHTML(data)
+	if err != nil {
+		return nil, &ParseError{Ref: input, Err: err}
+	}
+	dir := filepath.Dir(input)
+	return start(&Page{DOM: d, Dir: dir, Timings: []Timing{parsing}}, loader.Dir(dir)), nil
+}
+
+// Parse parses a page loaded from u, whose references are got with get. The
+// resources it references start loading in the background.
+func Parse(html []byte, u *url.URL, get loader.Getter) (*Page, error) {
+	d, parsing, err := parseHTML(html)
+	if err != nil {
+		return nil, &ParseError{Ref: u.String(), Err: err}
+	}
+	return start(&Page{DOM: d, URL: u, Timings: []Timing{parsing}}, loader.HTTP(u, get)), nil
+}
+
+func parseHTML(html []byte) (d *dom.DOM, t Timing, err error) {
+	t = timed(profile.PhaseParseHTML, func() {
+		d, err = dom.ParseString(string(html))
+	})
+	return d, t, err
+}
+
+// start starts loading the resources of a page with fetch, the most urgent
+// first, and reads them through the loader from then on
+func start(p *Page, fetch loader.Fetch) *Page {
+	resources := loader.New(fetch)
+	resources.Start(loader.Discover(p.DOM))
+	p.Fetch = resources.Get
+	p.resources = resources
+	return p
+}
+
+// Reload forgets what the page has loaded, so that loading it again reads
+// its stylesheets and images afresh. The document isn't parsed again.
+func (p *Page) Reload() {
+	if p.resources != nil {
+		p.resources.Forget()
+	}
+	p.Images = nil
+	p.Failed = nil
+	p.Timings = slices.DeleteFunc(p.Timings, func(t Timing) bool { return t.Phase != profile.PhaseParseHTML })
+}
+
+// Resolve returns the name of a reference of the page: its URL, or its path
+// for a local file
+func (p *Page) Resolve(ref string) string {
+	if u, err := url.Parse(ref); err == nil && u.IsAbs() {
+		return ref
+	}
+	if p.URL == nil {
+		return filepath.Join(p.Dir, ref)
+	}
+	u, err := p.URL.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// Title returns the text of the page's <title>, with its white space
+// collapsed
+func (p *Page) Title() string {
+	var title string
+	dom.Walk(p.DOM, p.DOM.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Type == dom.NodeTypeElement && node.Tag == "title" {
+			title = strings.Join(strings.Fields(styleloader.TextContent(p.DOM, node.ID)), " ")
+			return dom.WalkStop
+		}
+		return dom.WalkContinue
+	})
+	return title
+}
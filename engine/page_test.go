@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "page.html")
+	if err := os.WriteFile(path, []byte("<html><head><title> A\n page </title></head></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	page, err := Open(path, nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if page.URL != nil || page.Dir != dir || page.Resolve("a.css") != filepath.Join(dir, "a.css") {
+		t.Errorf("expected references relative to %s, got %+v", dir, page)
+	}
+	if got := page.Title(); got != "A page" {
+		t.Errorf("expected the title, got %q", got)
+	}
+
+	missing := errors.New("missing")
+	get := func(u string) ([]byte, error) {
+		if u != "https://example.com/dir/" {
+			return nil, missing
+		}
+		return []byte("<p>x</p>"), nil
+	}
+	page, err = Open("https://example.com/dir/", get)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := page.Resolve("../a.css"); got != "https://example.com/a.css" {
+		t.Errorf("expected references resolved against the URL, got %s", got)
+	}
+
+	var fetchErr *FetchError
+	if _, err := Open("https://example.com/other", get); !errors.As(err, &fetchErr) || !errors.Is(err, missing) {
+		t.Errorf("expected a fetch error, got %v", err)
+	}
+	if _, err := Open(filepath.Join(dir, "missing.html"), nil); !errors.As(err, &fetchErr) {
+		t.Errorf("expected a fetch error for a missing file, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "page.mhtml"), []byte("<html></html>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var parseErr *ParseError
+	if _, err := Open(filepath.Join(dir, "page.mhtml"), nil); !errors.As(err, &parseErr) {
+		t.Errorf("expected a parse error for HTML that isn't an archive, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"slices"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/paint"
+	"github.com/myuon/penny/profile"
+	"github.com/myuon/penny/styleloader"
+)
+
+// imageCacheBytes is the budget for the decoded images of a page
+const imageCacheBytes = 64 << 20
+
+// Stage is how far a page has loaded
+type Stage int
+
+const (
+	// StageParsed is the document parsed, before any of its stylesheets
+	StageParsed Stage = iota
+	// StageStylesheet is a stylesheet of the page arrived
+	StageStylesheet
+	// StageImages is the images of the page decoded, which completes it
+	StageImages
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageParsed:
+		return "parsed"
+	case StageStylesheet:
+		return "stylesheet"
+	default:
+		return "images"
+	}
+}
+
+// Progress is a stage a page reached while loading
+type Progress struct {
+	Stage Stage
+	// Source is the stylesheet that arrived, for StageStylesheet
+	Source string
+	// Stylesheet is the page's stylesheets that have arrived so far
+	Stylesheet *css.Stylesheet
+}
+
+// Frame is a page rendered at a stage of its loading
+type Frame struct {
+	Progress
+	*Rendering
+}
+
+// Load loads the page's stylesheets, as Stylesheets does, and then decodes
+// its images into Images, adding to the page's Timings and to Failed. If
+// progress is not nil, it is called after each stage, from the goroutine
+// Load runs on.
+func (p *Page) Load(progress func(Progress)) (stylesheet *css.Stylesheet, err error) {
+	report := func(stage Stage, source string, sheet *css.Stylesheet) {
+		if progress != nil {
+			progress(Progress{Stage: stage, Source: source, Stylesheet: sheet})
+		}
+	}
+	report(StageParsed, "", &css.Stylesheet{})
+	p.Timings = append(p.Timings, timed(profile.PhaseLoadCSS, func() {
+		stylesheet, err = styleloader.LoadEach(p.DOM, p.Fetch, p.Resolve, func(sheet *css.Stylesheet, source string) {
+			report(StageStylesheet, source, sheet)
+		})
+	}))
+	p.Failed = append(p.Failed, stylesheetFailures(err)...)
+	p.Timings = append(p.Timings, timed(profile.PhaseImages, p.decodeImages))
+	report(StageImages, "", stylesheet)
+	return stylesheet, err
+}
+
+// decodeImages decodes the images of the page's <img> elements into
+// Images. One that can't be read or decoded is cached as failed, and added
+// to Failed.
+func (p *Page) decodeImages() {
+	if p.Images == nil {
+		p.Images = paint.NewImageCache(imageCacheBytes, func(src string) (io.ReadCloser, error) {
+			data, err := p.Fetch(src)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(bytes.NewReader(data)), nil
+		})
+	}
+	seen := map[string]bool{}
+	dom.Walk(p.DOM, p.DOM.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		src := node.Attr["src"]
+		if node.Type != dom.NodeTypeElement || node.Tag != "img" || src == "" || seen[src] {
+			return dom.WalkContinue
+		}
+		seen[src] = true
+		if _, err := p.Images.Image(src); err != nil {
+			p.Failed = append(p.Failed, failedResource(p.Resolve(src), err))
+		}
+		return dom.WalkContinue
+	})
+}
+
+// RenderPage loads a page and renders it with defaults, such as the
+// stylesheets WithDefaults returns for no page, before its own, in media.
+// If opts.Progress is set, a frame is rendered and passed to it at each
+// stage of the loading, the last of which is the rendering returned. A
+// stylesheet or image that can't be loaded is left out, and listed in the
+// result.
+func RenderPage(p *Page, defaults *css.Stylesheet, media css.MediaContext, opts Options) *RenderResult {
+	withDefaults := func(sheet *css.Stylesheet) *css.Stylesheet {
+		all := &css.Stylesheet{}
+		all.Append(defaults)
+		all.Append(sheet)
+		return all
+	}
+	// The images are drawn once they have been decoded
+	withImages := func() Options {
+		o := opts
+		if o.Images == nil {
+			o.Images = p.Images
+		}
+		return o
+	}
+	var last *Rendering
+	stylesheet, _ := p.Load(func(progress Progress) {
+		if opts.Progress != nil {
+			last = Render(p.DOM, withDefaults(progress.Stylesheet).ForMedia(media), withImages())
+			opts.Progress(Frame{Progress: progress, Rendering: last})
+		}
+	})
+	stylesheet = withDefaults(stylesheet)
+	if last == nil {
+		last = Render(p.DOM, stylesheet.ForMedia(media), withImages())
+	}
+
+	result := &RenderResult{
+		Rendering:  last,
+		Stylesheet: stylesheet,
+		Title:      p.Title(),
+		Timings:    append(slices.Clone(p.Timings), last.Timings...),
+		Failed:     p.Failed,
+	}
+	result.DocumentWidth, result.DocumentHeight = documentSize(last.Layout, opts.Width, opts.Height)
+	return result
+}
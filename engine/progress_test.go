@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"testing"
+
+	"github.com/myuon/penny/css"
+)
+
+func TestRenderPage(t *testing.T) {
+	var img bytes.Buffer
+	pixel := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	pixel.Set(0, 0, color.Black)
+	if err := png.Encode(&img, pixel); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"http://example.com/a.css":   `div { background-color: red; }`,
+		"http://example.com/b.css":   `div { background-color: blue; }`,
+		"http://example.com/pic.png": img.String(),
+	}
+	get := func(u string) ([]byte, error) {
+		if data, ok := files[u]; ok {
+			return []byte(data), nil
+		}
+		return nil, errors.New("not found")
+	}
+	u, _ := url.Parse("http://example.com/")
+	page, err := Parse([]byte(`<html><head>
+		<link rel="stylesheet" href="a.css"><link rel="stylesheet" href="missing.css">
+		<link rel="stylesheet" href="b.css"></head>
+		<body><div></div><img src="pic.png"></body></html>`), u, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := WithDefaults(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := css.Parse(`body { margin: 0; } div { height: 10px; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults.Append(base)
+
+	// A frame is rendered once the document is parsed, as each stylesheet
+	// arrives and once the images are decoded
+	var stages []string
+	var colors []color.RGBA
+	opts := Options{Width: 20, Height: 20, Progress: func(f Frame) {
+		stages = append(stages, f.Stage.String()+" "+f.Source)
+		colors = append(colors, f.Image.RGBAAt(5, 5))
+	}}
+	r := RenderPage(page, defaults, css.DefaultMediaContext(), opts)
+	wantStages := []string{"parsed ", "stylesheet http://example.com/a.css", "stylesheet http://example.com/b.css", "images "}
+	if len(stages) != len(wantStages) {
+		t.Fatalf("expected stages %q, got %q", wantStages, stages)
+	}
+	for i, want := range wantStages {
+		if stages[i] != want {
+			t.Errorf("frame %d: expected %q, got %q", i, want, stages[i])
+		}
+	}
+	if colors[0] != (color.RGBA{255, 255, 255, 255}) || colors[1].R != 255 || colors[1].G != 0 || colors[2].B != 255 || colors[2].R != 0 {
+		t.Errorf("expected the box white, then red, then blue, got %v", colors)
+	}
+	if r.Image.RGBAAt(5, 5) != colors[3] {
+		t.Error("expected the last frame returned")
+	}
+	if decoded, err := page.Images.Image("pic.png"); err != nil || decoded.Bounds().Dx() != 2 {
+		t.Errorf("expected the image decoded, got %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	files := map[string]string{"http://example.com/a.css": `div { height: 10px; background-color: red; }`}
+	get := func(u string) ([]byte, error) {
+		if data, ok := files[u]; ok {
+			return []byte(data), nil
+		}
+		return nil, errors.New("not found")
+	}
+	u, _ := url.Parse("http://example.com/")
+	page, err := Parse([]byte(`<link rel="stylesheet" href="a.css"><link rel="stylesheet" href="b.css"><div></div>`), u, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Width: 20, Height: 20}
+	if r := RenderPage(page, &css.Stylesheet{}, css.DefaultMediaContext(), opts); r.Image.RGBAAt(5, 5).G != 0 || len(r.Failed) != 1 {
+		t.Fatalf("expected the box red and b.css failed, got %v and %v", r.Image.RGBAAt(5, 5), r.Failed)
+	}
+
+	files["http://example.com/a.css"] = `div { height: 10px; background-color: lime; }`
+	if r := RenderPage(page, &css.Stylesheet{}, css.DefaultMediaContext(), opts); r.Image.RGBAAt(5, 5).G != 0 {
+		t.Errorf("expected the stylesheet kept until the page is reloaded, got %v", r.Image.RGBAAt(5, 5))
+	}
+	page.Reload()
+	files["http://example.com/b.css"] = ``
+	r := RenderPage(page, &css.Stylesheet{}, css.DefaultMediaContext(), opts)
+	if r.Image.RGBAAt(5, 5).G != 255 || len(r.Failed) != 0 {
+		t.Errorf("expected the box lime and nothing failed once reloaded, got %v and %v", r.Image.RGBAAt(5, 5), r.Failed)
+	}
+}
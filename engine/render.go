@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"image"
+	"net/url"
+	"time"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/loader"
+	"github.com/myuon/penny/paint"
+	"github.com/myuon/penny/profile"
+)
+
+// Options are how a page is rendered
+type Options struct {
+	Width, Height int
+	// At is the time since load to capture CSS animations at
+	At time.Duration
+	// Trace records the inputs and outputs of the sizing of each box
+	Trace bool
+	// Progress, if set, is passed a frame at each stage of loading by
+	// RenderPage
+	Progress func(Frame)
+	// Images are the images <img> elements are sized by and draw. Without
+	// them, an <img> is sized by its width and height alone and draws
+	// nothing. RenderPage uses the page's.
+	Images *paint.ImageCache
+}
+
+// Rendering is a page rendered, with the trees it was painted from
+type Rendering struct {
+	Layout *layout.LayoutTree
+	Trace  *layout.Trace // if Options.Trace is set
+	Paint  *paint.PaintList
+	Image  *image.RGBA
+	// Timings are how long each phase of rendering took
+	Timings []Timing
+}
+
+// Render lays out, paints and rasterizes a document styled by stylesheet,
+// which may be nil, on a white background. The document and the layout
+// tree are frozen, as nothing may change them once rendered.
+func Render(d *dom.DOM, stylesheet *css.Stylesheet, opts Options) *Rendering {
+	d.Freeze()
+	w, h := float32(opts.Width), float32(opts.Height)
+	r := &Rendering{}
+	phase := func(name string, fn func()) {
+		r.Timings = append(r.Timings, timed(name, fn))
+	}
+	phase(profile.PhaseStyle, func() {
+		r.Layout = layout.BuildLayoutTreeIn(d, stylesheet, w, h, opts.At)
+		if opts.Images != nil {
+			layout.SizeImages(r.Layout, opts.Images.NaturalSize)
+		}
+	})
+	phase(profile.PhaseLayout, func() {
+		if opts.Trace {
+			r.Trace = layout.ComputeLayoutTraced(r.Layout, w, h)
+		} else {
+			layout.ComputeLayout(r.Layout, w, h)
+		}
+	})
+	r.Layout.Freeze()
+
+	r.Paint = paint.NewPaintList()
+	r.Paint.Images = opts.Images
+	phase(profile.PhasePaint, func() {
+		paint.PaintBackground(r.Paint, w, h, css.ColorWhite)
+		paint.PaintInto(r.Paint, r.Layout)
+	})
+	phase(profile.PhaseRasterize, func() {
+		r.Image = paint.Rasterize(r.Paint, opts.Width, opts.Height)
+	})
+	return r
+}
+
+// RenderToImage renders a page loaded from u, whose references are got with
+// get, to an image in a viewport of the size of opts, with the user agent
+// stylesheet and the page's own. A stylesheet that can't be loaded is left
+// out, as a browser would.
+func RenderToImage(html []byte, u *url.URL, get loader.Getter, opts Options) (*image.RGBA, error) {
+	page, err := Parse(html, u, get)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := WithDefaults(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	media := css.DefaultMediaContext()
+	media.Width, media.Height = float32(opts.Width), float32(opts.Height)
+	return RenderPage(page, defaults, media, opts).Image, nil
+}
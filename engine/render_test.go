@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/url"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestRender(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div></div></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := css.Parse(`body { margin: 0; } div { height: 10px; background-color: red; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Render(d, sheet, Options{Width: 40, Height: 30, Trace: true})
+	if r.Image.Bounds().Dx() != 40 || r.Image.Bounds().Dy() != 30 {
+		t.Errorf("expected a 40x30 image, got %v", r.Image.Bounds())
+	}
+	if c := r.Image.RGBAAt(5, 5); c.R != 255 || c.G != 0 {
+		t.Errorf("expected the box painted, got %v", c)
+	}
+	if c := r.Image.RGBAAt(5, 20); c.R != 255 || c.G != 255 {
+		t.Errorf("expected a white background, got %v", c)
+	}
+	if r.Trace == nil || !d.Frozen() {
+		t.Error("expected a trace and the document frozen")
+	}
+}
+
+func TestRenderToImage(t *testing.T) {
+	u, _ := url.Parse("http://example.com/")
+	get := func(string) ([]byte, error) { return nil, errors.New("not found") }
+	html := `<html><head><link rel="stylesheet" href="missing.css"><style>
+		body { margin: 0; } div { height: 10px; background-color: red; }
+		@media (max-width: 100px) { div { background-color: blue; } }
+		</style></head><body><div></div></body></html>`
+	img, err := RenderToImage([]byte(html), u, get, Options{Width: 40, Height: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The missing stylesheet is left out, and media queries see the viewport
+	if c := img.RGBAAt(5, 5); c.B != 255 || c.R != 0 {
+		t.Errorf("expected the box painted blue, got %v", c)
+	}
+}
+
+func TestRenderImages(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, red); err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://example.com/page/")
+	get := func(ref string) ([]byte, error) {
+		if ref == "http://example.com/page/red.png" {
+			return buf.Bytes(), nil
+		}
+		return nil, errors.New("not found")
+	}
+	html := `<html><head><style>body { margin: 0; } img { display: block; }</style></head>
+		<body><img src="red.png"><img src="red.png" width="40"></body></html>`
+	img, err := RenderToImage([]byte(html), u, get, Options{Width: 60, Height: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first image is drawn at its natural size, the second as wide as
+	// its width and as high as its aspect ratio makes it
+	for _, p := range []image.Point{{19, 9}, {39, 29}} {
+		if c := img.RGBAAt(p.X, p.Y); c != (color.RGBA{255, 0, 0, 255}) {
+			t.Errorf("expected the image drawn at %v, got %v", p, c)
+		}
+	}
+	for _, p := range []image.Point{{20, 5}, {40, 20}, {5, 30}} {
+		if c := img.RGBAAt(p.X, p.Y); c != (color.RGBA{255, 255, 255, 255}) {
+			t.Errorf("expected the background at %v, got %v", p, c)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/profile"
+	"github.com/myuon/penny/styleloader"
+)
+
+// Timing is how long a phase of loading or rendering a page took, named as
+// its profile phase
+type Timing struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// timed runs fn in a profile phase and returns how long it took
+func timed(phase string, fn func()) Timing {
+	start := time.Now()
+	profile.Phase(phase, fn)
+	return Timing{Phase: phase, Duration: time.Since(start)}
+}
+
+// FailedResource is a resource of a page that couldn't be loaded, which is
+// left out of its rendering
+type FailedResource struct {
+	Ref string `json:"ref"` // the URL or path it was loaded from
+	Err error  `json:"-"`
+	// Error is the message of Err
+	Error string `json:"error"`
+}
+
+func failedResource(ref string, err error) FailedResource {
+	return FailedResource{Ref: ref, Err: err, Error: err.Error()}
+}
+
+// stylesheetFailures splits the error of styleloader.Load into the
+// stylesheets it couldn't load
+func stylesheetFailures(err error) []FailedResource {
+	var errs []error
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	} else if err != nil {
+		errs = []error{err}
+	}
+	var failed []FailedResource
+	for _, err := range errs {
+		var loadErr *styleloader.Error
+		if errors.As(err, &loadErr) {
+			failed = append(failed, failedResource(loadErr.Source, loadErr.Err))
+		}
+	}
+	return failed
+}
+
+// RenderResult is a page rendered by RenderPage, with what embedders report
+// about it besides its pixels
+type RenderResult struct {
+	*Rendering `json:"-"`
+	// Stylesheet is what the page was rendered with, its defaults included
+	Stylesheet *css.Stylesheet `json:"-"`
+	Title      string          `json:"title"`
+	// DocumentWidth and DocumentHeight are the size of the laid out page,
+	// which is at least the viewport's and more where the page overflows it
+	DocumentWidth  int `json:"document_width"`
+	DocumentHeight int `json:"document_height"`
+	// Timings are the phases of loading and rendering the page, in the
+	// order they ran
+	Timings []Timing `json:"timings"`
+	// Failed are the stylesheets and images that couldn't be loaded
+	Failed []FailedResource `json:"failed"`
+}
+
+// documentSize returns the size of the laid out tree, at least w x h
+func documentSize(tree *layout.LayoutTree, w, h int) (int, int) {
+	right, bottom := float32(w), float32(h)
+	for i := range tree.Nodes {
+		r := tree.Nodes[i].Rect
+		right, bottom = max(right, r.X+r.W), max(bottom, r.Y+r.H)
+	}
+	return int(right + 0.5), int(bottom + 0.5)
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/profile"
+)
+
+func TestRenderResult(t *testing.T) {
+	get := func(u string) ([]byte, error) {
+		if u == "http://example.com/broken.png" {
+			return []byte("not an image"), nil
+		}
+		return nil, errors.New("not found")
+	}
+	u, _ := url.Parse("http://example.com/")
+	page, err := Parse([]byte(`<html><head><title>Tall page</title>
+		<link rel="stylesheet" href="missing.css"></head>
+		<body><div></div><img src="broken.png"><img src="broken.png"></body></html>`), u, get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := css.Parse(`body { margin: 0; } div { height: 500px; width: 30px; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := RenderPage(page, defaults, css.DefaultMediaContext(), Options{Width: 20, Height: 20})
+	if r.Title != "Tall page" {
+		t.Errorf("expected the title, got %q", r.Title)
+	}
+	if r.Image.Bounds().Dy() != 20 || r.DocumentWidth != 30 || r.DocumentHeight < 500 {
+		t.Errorf("expected a 20px image of a document at least 30x500, got %dx%d", r.DocumentWidth, r.DocumentHeight)
+	}
+
+	// An image that fails to decode is listed once
+	want := []string{"http://example.com/missing.css", "http://example.com/broken.png"}
+	if len(r.Failed) != len(want) {
+		t.Fatalf("expected %v to have failed, got %+v", want, r.Failed)
+	}
+	for i, ref := range want {
+		if r.Failed[i].Ref != ref || r.Failed[i].Error == "" {
+			t.Errorf("expected %s to have failed, got %+v", ref, r.Failed[i])
+		}
+	}
+
+	phases := []string{profile.PhaseParseHTML, profile.PhaseLoadCSS, profile.PhaseImages,
+		profile.PhaseStyle, profile.PhaseLayout, profile.PhasePaint, profile.PhaseRasterize}
+	if len(r.Timings) != len(phases) {
+		t.Fatalf("expected timings of %v, got %+v", phases, r.Timings)
+	}
+	for i, phase := range phases {
+		if r.Timings[i].Phase != phase {
+			t.Errorf("timing %d: expected %s, got %s", i, phase, r.Timings[i].Phase)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"os"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/profile"
+	"github.com/myuon/penny/styleloader"
+)
+
+// Stylesheets collects the page's stylesheets with styleloader. A
+// stylesheet that can't be loaded is left out, and the rest are returned
+// along with the errors of those left out.
+func (p *Page) Stylesheets() (stylesheet *css.Stylesheet, err error) {
+	profile.Phase(profile.PhaseLoadCSS, func() {
+		stylesheet, err = styleloader.Load(p.DOM, p.Fetch, p.Resolve)
+	})
+	return stylesheet, err
+}
+
+// WithDefaults puts the user agent stylesheet and the user stylesheets read
+// from userCSS, in their origins, before the page's stylesheet, which may be
+// nil
+func WithDefaults(page *css.Stylesheet, userCSS []string) (*css.Stylesheet, error) {
+	all := &css.Stylesheet{}
+	all.Append(css.UserAgentStylesheet())
+	for _, path := range userCSS {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, &FetchError{Ref: path, Err: err}
+		}
+		sheet, err := css.ParseSource(string(data), path)
+		if err != nil {
+			return nil, &ParseError{Ref: path, Err: err}
+		}
+		sheet.SetOrigin(css.OriginUser)
+		all.Append(sheet)
+	}
+	if page != nil {
+		all.Append(page)
+	}
+	return all, nil
+}
@@ -0,0 +1,17 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWithDefaults(t *testing.T) {
+	sheet, err := WithDefaults(nil, nil)
+	if err != nil || len(sheet.Rules) == 0 {
+		t.Errorf("expected the user agent stylesheet, got %d rules %v", len(sheet.Rules), err)
+	}
+	var fetchErr *FetchError
+	if _, err := WithDefaults(nil, []string{"missing.css"}); !errors.As(err, &fetchErr) {
+		t.Errorf("expected a fetch error for a missing user stylesheet, got %v", err)
+	}
+}
@@ -4,8 +4,10 @@ go 1.24.0
 
 require (
 	gioui.org v0.9.0
+	github.com/playwright-community/playwright-go v0.5200.1
 	github.com/spf13/cobra v1.10.2
 	golang.org/x/image v0.35.0
+	golang.org/x/text v0.33.0
 )
 
 require (
@@ -15,9 +17,7 @@ require (
 	github.com/go-stack/stack v1.8.1 // indirect
 	github.com/go-text/typesetting v0.3.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/playwright-community/playwright-go v0.5200.1 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
 	golang.org/x/exp/shiny v0.0.0-20250408133849-7e4ce0ab07d0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
-	golang.org/x/text v0.33.0 // indirect
 )
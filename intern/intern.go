@@ -0,0 +1,17 @@
+// Package intern deduplicates the strings that recur throughout a document,
+// such as tag names, attribute names, class names and CSS properties. The
+// dom, css and layout packages share the same table, so equal names are
+// backed by a single copy no matter where they were parsed.
+package intern
+
+import "unique"
+
+// String returns the canonical copy of s. Strings that are no longer
+// referenced are dropped from the table by the garbage collector, so it
+// doesn't grow without bound in long-running processes.
+func String(s string) string {
+	if s == "" {
+		return s
+	}
+	return unique.Make(s).Value()
+}
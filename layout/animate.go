@@ -0,0 +1,104 @@
+package layout
+
+import (
+	"slices"
+	"time"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+// runningTransition is a transition of one property of a node
+type runningTransition struct {
+	id       css.PropertyID
+	from, to css.Value
+	start    time.Duration // when it starts, after its delay
+	duration time.Duration
+	timing   css.TimingFunction
+}
+
+// SetTime sets the time since the document loaded that the next
+// BuildLayoutTree samples animations and transitions at. Transitions start
+// at the time a restyle changes a property.
+func (r *StyleResolver) SetTime(at time.Duration) {
+	r.now = at
+}
+
+// Animating reports whether the last BuildLayoutTree had animations or
+// transitions running, so that a later time would lay out differently
+func (r *StyleResolver) Animating() bool {
+	return r.animating
+}
+
+// startTransitions starts the transitions of a node whose style changed
+// from before, as it was last shown, to after. A property already
+// transitioning starts again from where it was.
+func (r *StyleResolver) startTransitions(nodeID dom.NodeID, before, after *css.Style) {
+	running := r.transitions[nodeID]
+	// Transitions to a value the property no longer has are cancelled
+	kept := running[:0]
+	for _, t := range running {
+		if after.Get(t.id) == t.to {
+			kept = append(kept, t)
+		}
+	}
+	running = kept
+
+	t := after.Transition
+	for _, id := range t.Changed(before, after) {
+		running = slices.DeleteFunc(running, func(rt runningTransition) bool { return rt.id == id })
+		running = append(running, runningTransition{
+			id:       id,
+			from:     before.Get(id),
+			to:       after.Get(id),
+			start:    r.now + t.Delay,
+			duration: t.Duration,
+			timing:   t.Timing,
+		})
+	}
+
+	if len(running) == 0 {
+		delete(r.transitions, nodeID)
+		return
+	}
+	r.transitions[nodeID] = running
+}
+
+// animate applies the animation and the running transitions of a node to
+// its style at the current time, with relative lengths resolved against
+// units. It reports whether any applied.
+func (r *StyleResolver) animate(nodeID dom.NodeID, style *css.Style, units css.Units) bool {
+	animated := style.Animation.Name != ""
+	if r.rules.animate(style, units, r.now) {
+		r.animating = true
+	}
+
+	running, ok := r.transitions[nodeID]
+	if !ok {
+		return animated
+	}
+	kept := running[:0]
+	for _, t := range running {
+		elapsed := r.now - t.start
+		switch {
+		case elapsed >= t.duration:
+			// Finished; the style already has the end value
+			continue
+		case elapsed < 0:
+			// The old value holds through the delay
+			style.Set(t.id, t.from)
+		default:
+			progress := float32(elapsed) / float32(t.duration)
+			style.Set(t.id, css.Interpolate(t.id, t.from, t.to, t.timing.At(progress)))
+		}
+		kept = append(kept, t)
+	}
+
+	if len(kept) == 0 {
+		delete(r.transitions, nodeID)
+	} else {
+		r.transitions[nodeID] = kept
+		r.animating = true
+	}
+	return true
+}
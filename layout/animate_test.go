@@ -0,0 +1,91 @@
+package layout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestBuildLayoutTreeAt(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="a"><p>text</p></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		@keyframes grow { from { width: 0px; font-size: 10px; } to { width: 200px; font-size: 30px; } }
+		#a { animation: grow 2s linear; }
+	`)
+
+	tree := BuildLayoutTreeAt(d, sheet, time.Second)
+	a := tree.GetNode(layoutNodeOf(t, tree, findElement(t, d, "a")))
+	if a.Style.Width == nil || *a.Style.Width != 100 || a.Style.FontSize != 20 {
+		t.Errorf("expected width 100 and font-size 20 halfway, got %v %v", a.Style.Width, a.Style.FontSize)
+	}
+	// Children inherit the animated values
+	p := tree.GetNode(a.FirstChild)
+	if p.Style.FontSize != 20 {
+		t.Errorf("expected the child to inherit font-size 20, got %v", p.Style.FontSize)
+	}
+
+	// Once the animation ends the element has its own style again
+	tree = BuildLayoutTreeAt(d, sheet, 5*time.Second)
+	a = tree.GetNode(layoutNodeOf(t, tree, findElement(t, d, "a")))
+	if a.Style.Width != nil {
+		t.Errorf("expected an auto width after the animation, got %v", *a.Style.Width)
+	}
+}
+
+func TestStyleResolverTransitions(t *testing.T) {
+	d, err := dom.ParseString(invalidateHTML)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		#b { transition: height 1s linear 100ms; }
+		div { height: 10px; }
+		.box { height: 110px; }
+	`)
+	b := findElement(t, d, "b")
+	heightAt := func(r *StyleResolver, at time.Duration) float32 {
+		t.Helper()
+		r.SetTime(at)
+		tree := r.BuildLayoutTree()
+		return *tree.GetNode(layoutNodeOf(t, tree, b)).Style.Height
+	}
+
+	r := NewStyleResolver(d, sheet)
+	if h := heightAt(r, 0); h != 10 || r.Animating() {
+		t.Fatalf("expected height 10 without a transition, got %v (animating %v)", h, r.Animating())
+	}
+
+	d.SetAttribute(b, "class", "box")
+	tests := []struct {
+		at   time.Duration
+		want float32
+	}{
+		{time.Second, 10},              // in the delay
+		{1600 * time.Millisecond, 60},  // halfway
+		{2100 * time.Millisecond, 110}, // done
+		{3 * time.Second, 110},
+	}
+	for _, tt := range tests {
+		if h := heightAt(r, tt.at); h != tt.want {
+			t.Errorf("at %v: expected height %v, got %v", tt.at, tt.want, h)
+		}
+	}
+	if r.Animating() {
+		t.Errorf("expected the transition to have finished")
+	}
+}
+
+func layoutNodeOf(t *testing.T, tree *LayoutTree, nodeID dom.NodeID) LayoutNodeID {
+	t.Helper()
+	for i := range tree.Nodes {
+		if tree.Nodes[i].DomNode == nodeID {
+			return LayoutNodeID(i)
+		}
+	}
+	t.Fatalf("no layout node for DOM node %d", nodeID)
+	return InvalidLayoutNodeID
+}
@@ -1,14 +1,59 @@
 package layout
 
 import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/myuon/penny/css"
 	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/text"
 )
 
 // BuildLayoutTree creates a layout tree from DOM and computed styles
 // Only builds from <body> element
 func BuildLayoutTree(d *dom.DOM, stylesheet *css.Stylesheet) *LayoutTree {
+	return BuildLayoutTreeAt(d, stylesheet, 0)
+}
+
+// BuildLayoutTreeAt is like BuildLayoutTree, with CSS animations as they are
+// at time at since the document loaded
+func BuildLayoutTreeAt(d *dom.DOM, stylesheet *css.Stylesheet, at time.Duration) *LayoutTree {
+	units := css.DefaultUnits()
+	return BuildLayoutTreeIn(d, stylesheet, units.ViewportWidth, units.ViewportHeight, at)
+}
+
+// BuildLayoutTreeIn is like BuildLayoutTreeAt, for a viewport of w x h,
+// which vw and vh lengths are relative to. ComputeLayout should be given
+// the same viewport.
+func BuildLayoutTreeIn(d *dom.DOM, stylesheet *css.Stylesheet, w, h float32, at time.Duration) *LayoutTree {
+	rules := newRuleIndex(stylesheet, nil)
+	units := css.DefaultUnits()
+	units.ViewportWidth, units.ViewportHeight = w, h
+	return buildLayoutTree(d, units, func(node *dom.Node, ancestors []*dom.Node, parentStyle css.Style, units css.Units) css.Style {
+		style := computeStyle(d, node, ancestors, parentStyle, rules, units)
+		units.FontSize = style.FontSize
+		rules.animate(&style, units, at)
+		return style
+	})
+}
+
+// styleFunc resolves the style of a node, given its ancestors, outermost
+// first, the style of its parent and the units of the document; the
+// ancestors slice is only valid for the duration of the call
+type styleFunc func(node *dom.Node, ancestors []*dom.Node, parentStyle css.Style, units css.Units) css.Style
+
+// buildLayoutTree creates a layout tree, resolving the style of each node
+// with styleOf. The elements around <body> are styled for <body> to inherit
+// from, and the root element's font size is what rem lengths are relative
+// to.
+func buildLayoutTree(d *dom.DOM, units css.Units, styleOf styleFunc) *LayoutTree {
 	tree := NewLayoutTree()
+	// There is at most one layout node per DOM node, so the slice never
+	// grows during the build
+	tree.Nodes = make([]LayoutNode, 0, len(d.Nodes))
 
 	// Find body element
 	bodyID := findBody(d, d.Root)
@@ -16,106 +61,818 @@ func BuildLayoutTree(d *dom.DOM, stylesheet *css.Stylesheet) *LayoutTree {
 		return tree
 	}
 
-	var build func(nodeID dom.NodeID, parentStyle css.Style) LayoutNodeID
-	build = func(nodeID dom.NodeID, parentStyle css.Style) LayoutNodeID {
-		node := d.GetNode(nodeID)
+	// Build with an explicit stack so that deeply nested documents can't
+	// overflow the goroutine stack. Nodes are created in pre-order, so
+	// children always have larger IDs than their parents.
+	//
+	// ancestors is the element stack of the node being built, so selectors
+	// can be matched against its context without walking parent pointers.
+	// Each frame records how deep in the stack its node sits.
+	type frame struct {
+		nodeID      dom.NodeID
+		parent      LayoutNodeID
+		parentStyle css.Style
+		depth       int
+	}
+	ancestors := ancestorsOf(d, bodyID)
+	bodyParent := css.DefaultStyle()
+	root := true
+	for i, node := range ancestors {
+		bodyParent = styleOf(node, ancestors[:i], bodyParent, units)
+		if root && node.Type == dom.NodeTypeElement {
+			units.RootFontSize, root = bodyParent.FontSize, false
+		}
+	}
+	var ordinals listOrdinals
+	stack := []frame{{bodyID, InvalidLayoutNodeID, bodyParent, len(ancestors)}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		node := d.GetNode(f.nodeID)
 		if node == nil {
-			return InvalidLayoutNodeID
+			continue
 		}
+		ancestors = ancestors[:f.depth]
 
 		// Compute style
-		style := computeStyle(node, parentStyle, stylesheet)
+		style := styleOf(node, ancestors, f.parentStyle, units)
 
 		// Skip display:none
 		if style.Display == css.DisplayNone {
-			return InvalidLayoutNodeID
+			continue
+		}
+		style.Display = tableDisplay(style.Display, f.parentStyle.Display)
+
+		// Text that collapses away isn't laid out
+		var content string
+		if node.Type == dom.NodeTypeText {
+			content = collapseSpaces(text.Normalize(node.Text), style.WhiteSpace)
+			if content == "" {
+				continue
+			}
 		}
 
 		// Create layout node
-		layoutID := tree.CreateNode(nodeID, style)
+		layoutID := tree.CreateNode(f.nodeID, style)
+		if f.parent == InvalidLayoutNodeID {
+			tree.Root = layoutID
+		} else {
+			tree.AppendChild(f.parent, layoutID)
+		}
 
-		// Set text for text nodes
+		// Set text for text nodes, in the form it is measured and drawn in
 		if node.Type == dom.NodeTypeText {
-			tree.Nodes[layoutID].Text = node.Text
+			tree.Nodes[layoutID].Text = content
+		} else if _, ok := replacedTags[node.Tag]; ok {
+			tree.Nodes[layoutID].Replaced = true
+			if attr, ok := imageAttrs[node.Tag]; ok {
+				initImage(&tree.Nodes[layoutID], node.Attr[attr], replacedTags[node.Tag])
+			}
+			if gaugeTags[node.Tag] {
+				tree.Nodes[layoutID].Gauge = gaugeOf(node)
+			}
+			tree.Nodes[layoutID].Media = mediaTags[node.Tag]
 		}
-
-		// Build children
-		for _, childID := range node.Children {
-			childLayoutID := build(childID, style)
-			if childLayoutID != InvalidLayoutNodeID {
-				tree.AppendChild(layoutID, childLayoutID)
+		if style.Display == css.DisplayTableCell {
+			initCellSpan(&tree.Nodes[layoutID], node)
+		}
+		if style.Display == css.DisplayListItem {
+			if ordinals == nil {
+				ordinals = listOrdinals{}
 			}
+			n := ordinals.next(d, node, f.parent)
+			tree.Nodes[layoutID].Marker.Text = style.ListStyleType.Marker(n)
 		}
 
-		return layoutID
+		// Build children, first child on top of the stack. Those of a
+		// replaced element are fallback content, shown only where the
+		// element isn't supported.
+		if tree.Nodes[layoutID].Replaced {
+			continue
+		}
+		if len(node.Children) > 0 {
+			ancestors = append(ancestors, node)
+		}
+		for i := len(node.Children) - 1; i >= 0; i-- {
+			stack = append(stack, frame{node.Children[i], layoutID, style, len(ancestors)})
+		}
 	}
 
-	tree.Root = build(bodyID, css.DefaultStyle())
+	passCollapseSpaces(tree)
+	passListMarkers(tree)
+	passFirstText(tree)
 	return tree
 }
 
+// passFirstText hands the ::first-letter and ::first-line styles of each
+// element to the first text inside it. Nodes are in pre-order, so those of
+// an inner element are laid over those of the elements around it.
+func passFirstText(tree *LayoutTree) {
+	for i := range tree.Nodes {
+		node := &tree.Nodes[i]
+		if node.Text != "" || !node.Style.FirstLetter.IsSet() && !node.Style.FirstLine.IsSet() {
+			continue
+		}
+		Walk(tree, node.ID, func(text *LayoutNode, depth int) WalkAction {
+			if text.Text == "" {
+				return WalkContinue
+			}
+			text.Style.FirstLetter = node.Style.FirstLetter.Over(text.Style.FirstLetter)
+			text.Style.FirstLine = node.Style.FirstLine.Over(text.Style.FirstLine)
+			return WalkStop
+		})
+	}
+}
+
+// findBody returns the first <body> element in document order
 func findBody(d *dom.DOM, nodeID dom.NodeID) dom.NodeID {
-	node := d.GetNode(nodeID)
-	if node == nil {
-		return dom.InvalidNodeID
+	bodyID := dom.InvalidNodeID
+	dom.Walk(d, nodeID, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Type == dom.NodeTypeElement && node.Tag == "body" {
+			bodyID = node.ID
+			return dom.WalkStop
+		}
+		return dom.WalkContinue
+	})
+	return bodyID
+}
+
+// ancestorsOf returns the ancestors of a node, outermost first
+func ancestorsOf(d *dom.DOM, nodeID dom.NodeID) []*dom.Node {
+	var ancestors []*dom.Node
+	for node := d.GetNode(nodeID); node != nil; {
+		parent := d.GetNode(node.Parent)
+		if parent == nil {
+			break
+		}
+		ancestors = append(ancestors, parent)
+		node = parent
+	}
+	slices.Reverse(ancestors)
+	return ancestors
+}
+
+// computeStyle resolves the style of a node, with relative lengths resolved
+// against units and its own font size
+func computeStyle(d *dom.DOM, node *dom.Node, ancestors []*dom.Node, parentStyle css.Style, rules *ruleIndex, units css.Units) css.Style {
+	style := css.InheritedStyle(parentStyle)
+
+	if node.Type != dom.NodeTypeElement {
+		return style
+	}
+
+	// A hidden element is dropped along with its subtree, so once matching
+	// shows it is display:none the rest of its style isn't resolved
+	matched := rules.match(d, node, ancestors)
+	inline := rules.inlineStyle(node)
+	if rules.hides(matched, inline) {
+		style.Display = css.DisplayNone
+		return style
 	}
 
-	if node.Type == dom.NodeTypeElement && node.Tag == "body" {
-		return nodeID
+	if size, ok := replacedTags[node.Tag]; ok {
+		applyReplacedDefaults(&style, node, size)
 	}
 
-	for _, childID := range node.Children {
-		if found := findBody(d, childID); found != dom.InvalidNodeID {
-			return found
+	// Apply matching rules
+	rules.applyRules(&style, &parentStyle, matched, inline, units)
+	units.FontSize = style.FontSize
+	rules.applyPseudoElements(&style, d, node, ancestors, units)
+	style.PropagateDecorations()
+
+	return style
+}
+
+// replacedTags are the elements whose content is replaced by an image or
+// another document, with their default width and height. An <img> or a
+// <video> is sized by its image instead, see SizeImages, and the size of a
+// <video> is that of a video until its poster's is known.
+var replacedTags = map[string][2]float32{
+	"img":    {0, 0},
+	"canvas": {300, 150},
+	"iframe": {300, 150},
+	"video":  {300, 150},
+	// A <progress> or <meter> is drawn as a gauge, see Gauge
+	"progress": {160, 16},
+	"meter":    {80, 16},
+	// An <audio> is drawn as its controls, see MediaKind
+	"audio": {300, 54},
+}
+
+// imageAttrs are the replaced elements sized by an image, with the
+// attribute naming it
+var imageAttrs = map[string]string{
+	"img":   "src",
+	"video": "poster",
+}
+
+// applyReplacedDefaults applies the user agent style of a replaced element:
+// it is inline, and sized by its width and height attributes, which author
+// rules override. An element sized by an image is left auto without them.
+func applyReplacedDefaults(style *css.Style, node *dom.Node, size [2]float32) {
+	style.Display = css.DisplayInline
+	for i, attr := range [2]string{"width", "height"} {
+		v := size[i]
+		if n, err := strconv.ParseFloat(strings.TrimSuffix(node.Attr[attr], "px"), 32); err == nil && n >= 0 {
+			v = float32(n)
+		} else if _, ok := imageAttrs[node.Tag]; ok {
+			continue
+		}
+		if i == 0 {
+			style.Width = &v
+		} else {
+			style.Height = &v
+		}
+	}
+}
+
+// initImage records the image of an element sized by one and which of its
+// width and height are auto, which are those of size until SizeImages
+// sizes them
+func initImage(node *LayoutNode, src string, size [2]float32) {
+	node.Src = src
+	node.autoSize = [2]bool{node.Style.Width == nil, node.Style.Height == nil}
+	sizeImage(node, size[0], size[1])
+}
+
+// SizeImages sizes the <img> elements of a tree, and the <video> elements
+// with a poster, whose width or height is auto by the natural size of their
+// image, which natural returns. Where only one of them is auto, it keeps
+// the image's aspect ratio. An image natural doesn't know keeps the default
+// size of its element in the auto sides, 0 for an <img>. It must be called
+// before ComputeLayout.
+func SizeImages(tree *LayoutTree, natural func(src string) (w, h float32, ok bool)) {
+	tree.checkMutable()
+	for i := range tree.Nodes {
+		node := &tree.Nodes[i]
+		if node.autoSize == [2]bool{} {
+			continue
+		}
+		if w, h, ok := natural(node.Src); ok {
+			sizeImage(node, w, h)
 		}
 	}
+}
+
+// sizeImage sets the auto width and height of an element whose image is
+// w x h
+func sizeImage(node *LayoutNode, w, h float32) {
+	s := &node.Style
+	auto := node.autoSize
+	switch {
+	case !auto[0] && auto[1] && w > 0:
+		h = *s.Width * h / w
+	case auto[0] && !auto[1] && h > 0:
+		w = *s.Height * w / h
+	}
+	if auto[0] {
+		s.Width = &w
+	}
+	if auto[1] {
+		s.Height = &h
+	}
+}
 
-	return dom.InvalidNodeID
+// ruleIndex buckets the rules of a stylesheet by the tag, class and id their
+// selectors match, so that an element is only tested against rules that can
+// apply to it instead of the whole stylesheet
+type ruleIndex struct {
+	rules []css.Rule
+	decls [][]css.Declaration // the declarations of each rule as longhands
+	// scopes is the root of the subtree each rule is scoped to, or
+	// InvalidNodeID for rules of the page. It is nil when no stylesheet is
+	// scoped.
+	scopes    []dom.NodeID
+	byTag     map[string][]selectorRef
+	byClass   map[string][]selectorRef
+	byID      map[string][]selectorRef
+	universal []selectorRef
+	display   map[int]declaredDisplay // of the rules that declare one
+	keyframes map[string]*css.Keyframes
+	// readsParent is set when a rule uses inherit or unset, which can make
+	// a style depend on any property of the parent
+	readsParent bool
+	// hasImportant is set when a rule has !important declarations
+	hasImportant bool
+	// hasContext is set when a selector has combinators, so that matching
+	// an element depends on the elements around it
+	hasContext bool
+	// attrs are the names of the attributes attribute selectors look at
+	attrs map[string]bool
+	// hasStructural is set when a selector has structural pseudo-classes,
+	// so that matching an element depends on its siblings
+	hasStructural bool
+	// hasPseudo has a bit set for each pseudo-element some selector has
+	hasPseudo uint8
+	// found and matched are scratch buffers reused across elements
+	found   []matchedRule
+	matched []int
+	// inline caches the parsed style attributes, which tend to repeat
+	inline map[string]*inlineStyle
 }
 
-func computeStyle(node *dom.Node, parentStyle css.Style, stylesheet *css.Stylesheet) css.Style {
-	style := css.DefaultStyle()
+// inlineStyle is the parsed style attribute of an element
+type inlineStyle struct {
+	decls        []css.Declaration // as longhands
+	display      declaredDisplay
+	hasImportant bool
+}
 
-	// Inherit from parent
-	style.Color = parentStyle.Color
-	style.FontSize = parentStyle.FontSize
+// declaredDisplay is the display set by the normal and by the !important
+// declarations of a rule or style attribute, if any
+type declaredDisplay struct {
+	normal, important       css.Display
+	hasNormal, hasImportant bool
+}
 
-	if node.Type != dom.NodeTypeElement {
-		return style
+func displayOf(decls []css.Declaration) (declaredDisplay, bool) {
+	var d declaredDisplay
+	for _, decl := range decls {
+		style := css.DefaultStyle()
+		if decl.Property != "display" || !css.ApplyDeclaration(&style, decl) {
+			continue
+		}
+		if decl.Important {
+			d.important, d.hasImportant = style.Display, true
+		} else {
+			d.normal, d.hasNormal = style.Display, true
+		}
 	}
+	return d, d.hasNormal || d.hasImportant
+}
 
-	// Apply matching rules
+func (d declaredDisplay) get(important bool) (css.Display, bool) {
+	if important {
+		return d.important, d.hasImportant
+	}
+	return d.normal, d.hasNormal
+}
+
+// selectorRef is a selector in a ruleIndex bucket: the rule it belongs to,
+// its specificity, the compound the element must match, the
+// pseudo-element of the element it styles, if any, the context that must
+// match around the element, and the root of the subtree the rule is scoped
+// to, if any
+type selectorRef struct {
+	rule     int
+	spec     css.Specificity
+	compound compound
+	pseudo   pseudoElement
+	context  []relative
+	scope    dom.NodeID
+}
+
+// matchedRule is a rule matching an element, with the specificity of the
+// most specific of its selectors that match, and the depth of its scope
+// root: 0 for a rule of the page, and more the closer the root is to the
+// element
+type matchedRule struct {
+	rule  int
+	spec  css.Specificity
+	depth int
+}
+
+// pseudoElement is a pseudo-element penny styles. Their rules are matched
+// like those of the element they belong to.
+type pseudoElement uint8
+
+const (
+	pseudoNone pseudoElement = iota
+	pseudoSelection
+	pseudoFirstLetter
+	pseudoFirstLine
+)
+
+var pseudoElements = map[string]pseudoElement{
+	"selection":    pseudoSelection,
+	"first-letter": pseudoFirstLetter,
+	"first-line":   pseudoFirstLine,
+}
+
+// selectorState returns the element state the pseudo-classes of a selector
+// require. It reports false for selectors penny can't match, such as those
+// with pseudo-elements or pseudo-classes it doesn't know.
+func selectorState(sel css.Selector) (dom.ElementState, bool) {
+	if sel.PseudoElement != "" {
+		return 0, false
+	}
+	var state dom.ElementState
+	for _, name := range sel.PseudoClasses {
+		s, ok := dom.ParseElementState(name)
+		if !ok {
+			return 0, false
+		}
+		state |= s
+	}
+	return state, true
+}
+
+// newRuleIndex indexes the rules of stylesheet, which may be nil, and of
+// the stylesheets scoped to subtrees of the document
+func newRuleIndex(stylesheet *css.Stylesheet, scoped []ScopedStylesheet) *ruleIndex {
+	ix := &ruleIndex{
+		byTag:   make(map[string][]selectorRef),
+		byClass: make(map[string][]selectorRef),
+		byID:    make(map[string][]selectorRef),
+		display: make(map[int]declaredDisplay),
+		inline:  make(map[string]*inlineStyle),
+	}
+	if stylesheet == nil && len(scoped) == 0 {
+		return ix
+	}
 	if stylesheet == nil {
-		return style
+		stylesheet = &css.Stylesheet{}
 	}
 
-	for _, rule := range stylesheet.Rules {
-		if matchesSelector(node, rule.Selectors) {
-			for _, decl := range rule.Declarations {
-				css.ApplyDeclaration(&style, decl)
+	// @media rules the embedder hasn't evaluated with ForMedia apply as
+	// they would on the default screen
+	stylesheet = stylesheet.ForMedia(css.DefaultMediaContext())
+	ix.rules = stylesheet.Rules
+	ix.keyframes = stylesheet.Keyframes
+	if len(scoped) > 0 {
+		ix.addScoped(scoped)
+	}
+
+	// Rules are ordered by origin, so that rules of the page override
+	// those of the user and the user agent wherever they were appended
+	byOrigin := func(a, b css.Rule) int {
+		return a.Origin.Precedence() - b.Origin.Precedence()
+	}
+	if !slices.IsSortedFunc(ix.rules, byOrigin) {
+		if ix.scopes == nil {
+			ix.rules = slices.Clone(ix.rules)
+			slices.SortStableFunc(ix.rules, byOrigin)
+		} else {
+			ix.sortScoped(byOrigin)
+		}
+	}
+	ix.decls = make([][]css.Declaration, len(ix.rules))
+	for i, rule := range ix.rules {
+		// Shorthands are expanded once here, so the cascade only deals with
+		// longhands and a later declaration overrides an earlier one
+		// whichever form either is written in
+		ix.decls[i] = css.ExpandDeclarations(rule.Declarations)
+		for _, decl := range ix.decls[i] {
+			if css.DependsOnParent(decl) {
+				ix.readsParent = true
+			}
+			if decl.Important {
+				ix.hasImportant = true
+			}
+		}
+		if display, ok := displayOf(ix.decls[i]); ok {
+			ix.display[i] = display
+		}
+
+		for _, sel := range rule.Selectors {
+			spec := sel.Specificity()
+			pseudo := pseudoElements[sel.PseudoElement]
+			if pseudo != pseudoNone {
+				sel.PseudoElement = ""
+				ix.hasPseudo |= 1 << pseudo
+			}
+			subject, context, ok := compileSelector(sel)
+			if !ok {
+				continue
+			}
+			ix.addSelector(subject, context)
+			ref := selectorRef{rule: i, spec: spec, compound: subject, pseudo: pseudo, context: context, scope: dom.InvalidNodeID}
+			if ix.scopes != nil {
+				ref.scope = ix.scopes[i]
+			}
+			// A compound is listed under the rarest of its parts: its ID,
+			// else its first class, else its tag
+			var bucket map[string][]selectorRef
+			var key string
+			switch {
+			case subject.id != "":
+				bucket, key = ix.byID, subject.id
+			case len(subject.classes) > 0:
+				bucket, key = ix.byClass, subject.classes[0]
+			case subject.tag != "":
+				bucket, key = ix.byTag, subject.tag
+			default:
+				ix.universal = append(ix.universal, ref)
+				continue
 			}
+			// A rule like "p, p" is only listed once
+			if n := len(bucket[key]); n > 0 && sameRef(bucket[key][n-1], ref) {
+				continue
+			}
+			bucket[key] = append(bucket[key], ref)
 		}
 	}
+	return ix
+}
 
-	return style
+// addSelector records what matching a selector depends on besides the
+// tag, class, id and state of the element: the elements around it for a
+// context, and what its compounds depend on
+func (ix *ruleIndex) addSelector(subject compound, context []relative) {
+	if len(context) > 0 {
+		ix.hasContext = true
+	}
+	ix.addCompound(subject)
+	for _, rel := range context {
+		ix.addCompound(rel.compound)
+	}
 }
 
-func matchesSelector(node *dom.Node, selectors []css.Selector) bool {
-	for _, sel := range selectors {
-		switch sel.Type {
-		case css.SelectorTag:
-			if node.Tag == sel.Value {
-				return true
-			}
-		case css.SelectorClass:
-			if class, ok := node.Attr["class"]; ok && class == sel.Value {
-				return true
+// addCompound records the attributes the attribute selectors of a compound
+// look at, whether it depends on the element's siblings, and what the
+// selectors of its logical pseudo-classes depend on
+func (ix *ruleIndex) addCompound(c compound) {
+	for _, attr := range c.attrs {
+		if ix.attrs == nil {
+			ix.attrs = make(map[string]bool)
+		}
+		ix.attrs[attr.Name] = true
+	}
+	if len(c.nths) > 0 {
+		ix.hasStructural = true
+	}
+	for _, list := range c.lists {
+		for _, sel := range list.selectors {
+			ix.addSelector(sel.subject, sel.context)
+		}
+	}
+}
+
+// sameRef reports whether two selectors of a bucket match the same
+// elements in the same way. Those with a context or logical pseudo-classes
+// are never taken to be the same.
+func sameRef(a, b selectorRef) bool {
+	return len(a.context) == 0 && len(b.context) == 0 && a.scope == b.scope &&
+		len(a.compound.lists) == 0 && len(b.compound.lists) == 0 &&
+		a.rule == b.rule && a.spec == b.spec && a.pseudo == b.pseudo &&
+		a.compound.tag == b.compound.tag && a.compound.id == b.compound.id &&
+		a.compound.state == b.compound.state && slices.Equal(a.compound.classes, b.compound.classes) &&
+		slices.Equal(a.compound.attrs, b.compound.attrs) && slices.Equal(a.compound.nths, b.compound.nths)
+}
+
+// apply applies the declarations of the rules matching node in cascade
+// order. ancestors is the node's element stack, outermost first.
+func (ix *ruleIndex) apply(style *css.Style, d *dom.DOM, node *dom.Node, ancestors []*dom.Node) {
+	ix.applyRules(style, nil, ix.match(d, node, ancestors), ix.inlineStyle(node), css.DefaultUnits())
+}
+
+// match returns the rules matching node in cascade order. The slice is
+// reused by the next call.
+func (ix *ruleIndex) match(d *dom.DOM, node *dom.Node, ancestors []*dom.Node) []int {
+	return ix.matchRules(d, node, ancestors, pseudoNone)
+}
+
+// matchRules returns the rules matching node, or its pseudo-element if
+// pseudo is set, in cascade order: by origin, then by how close the root of
+// their scope is, then by specificity, then in stylesheet order. The slice is reused by the next call.
+func (ix *ruleIndex) matchRules(d *dom.DOM, node *dom.Node, ancestors []*dom.Node, pseudo pseudoElement) []int {
+	if len(ix.rules) == 0 {
+		return nil
+	}
+
+	m := matcher{d, node, ancestors, pseudo}
+	found := ix.found[:0]
+	found = m.appendMatching(found, ix.universal)
+	found = m.appendMatching(found, ix.byTag[node.Tag])
+	if class, ok := node.Attr["class"]; ok {
+		for c := range strings.FieldsSeq(class) {
+			found = m.appendMatching(found, ix.byClass[c])
+		}
+	}
+	if id, ok := node.Attr["id"]; ok {
+		found = m.appendMatching(found, ix.byID[id])
+	}
+	if len(found) > 1 {
+		// A rule matched by several of its selectors weighs as the most
+		// specific of them
+		slices.SortFunc(found, func(a, b matchedRule) int {
+			return cmp.Or(cmp.Compare(a.rule, b.rule), b.spec.Compare(a.spec))
+		})
+		found = slices.CompactFunc(found, func(a, b matchedRule) bool {
+			return a.rule == b.rule
+		})
+		slices.SortFunc(found, func(a, b matchedRule) int {
+			return cmp.Or(
+				cmp.Compare(ix.rules[a.rule].Origin.Precedence(), ix.rules[b.rule].Origin.Precedence()),
+				cmp.Compare(a.depth, b.depth),
+				a.spec.Compare(b.spec),
+				cmp.Compare(a.rule, b.rule),
+			)
+		})
+	}
+	ix.found = found
+
+	matched := ix.matched[:0]
+	for _, m := range found {
+		matched = append(matched, m.rule)
+	}
+	ix.matched = matched
+	return matched
+}
+
+// matcher is an element being matched against the buckets of a ruleIndex,
+// with its ancestors outermost first, and the pseudo-element whose rules
+// are wanted, or pseudoNone for the element's own
+type matcher struct {
+	d         *dom.DOM
+	node      *dom.Node
+	ancestors []*dom.Node
+	pseudo    pseudoElement
+}
+
+// appendMatching appends the rules of the selectors in a bucket for the
+// matcher's pseudo-element whose pseudo-classes the element state satisfies
+// and whose context matches around the element
+func (m matcher) appendMatching(found []matchedRule, refs []selectorRef) []matchedRule {
+	for _, ref := range refs {
+		if ref.pseudo != m.pseudo || !matchesCompound(m.d, m.node, ref.compound) {
+			continue
+		}
+		ancestors, depth := m.ancestors, 0
+		if ref.scope != dom.InvalidNodeID {
+			// The context of a scoped selector stops at the scope root
+			if depth = scopeDepth(m.node, m.ancestors, ref.scope); depth == 0 {
+				continue
 			}
-		case css.SelectorID:
-			if id, ok := node.Attr["id"]; ok && id == sel.Value {
-				return true
+			ancestors = ancestors[depth-1:]
+		}
+		if matchesContext(m.d, m.node, ancestors, ref.context) {
+			found = append(found, matchedRule{ref.rule, ref.spec, depth})
+		}
+	}
+	return found
+}
+
+// applyPseudoElements applies the rules of the pseudo-elements of node:
+// ::selection rules to the selection style it inherited, and
+// ::first-letter and ::first-line rules to those of its text
+func (ix *ruleIndex) applyPseudoElements(style *css.Style, d *dom.DOM, node *dom.Node, ancestors []*dom.Node, units css.Units) {
+	if ix.hasPseudo == 0 {
+		return
+	}
+	for _, i := range ix.matchRules(d, node, ancestors, pseudoSelection) {
+		for _, decl := range ix.decls[i] {
+			css.ApplySelectionDeclaration(&style.Selection, decl)
+		}
+	}
+	// Like in browsers, inline elements have no first letter or line
+	if style.Display == css.DisplayInline {
+		return
+	}
+	for _, i := range ix.matchRules(d, node, ancestors, pseudoFirstLetter) {
+		for _, decl := range ix.decls[i] {
+			css.ApplyPseudoTextDeclaration(&style.FirstLetter, decl, units)
+		}
+	}
+	for _, i := range ix.matchRules(d, node, ancestors, pseudoFirstLine) {
+		for _, decl := range ix.decls[i] {
+			css.ApplyPseudoTextDeclaration(&style.FirstLine, decl, units)
+		}
+	}
+}
+
+// inlineStyle returns the parsed style attribute of node, or nil if it has
+// none
+func (ix *ruleIndex) inlineStyle(node *dom.Node) *inlineStyle {
+	attr, ok := node.Attr["style"]
+	if !ok {
+		return nil
+	}
+	if inline, ok := ix.inline[attr]; ok {
+		return inline
+	}
+
+	inline := &inlineStyle{decls: css.ExpandDeclarations(css.ParseDeclarations(attr))}
+	inline.display, _ = displayOf(inline.decls)
+	for _, decl := range inline.decls {
+		if css.DependsOnParent(decl) {
+			ix.readsParent = true
+		}
+		if decl.Important {
+			inline.hasImportant = true
+		}
+	}
+	ix.inline[attr] = inline
+	return inline
+}
+
+// hides reports whether the matched rules and style attribute leave an
+// element display:none
+func (ix *ruleIndex) hides(matched []int, inline *inlineStyle) bool {
+	var display css.Display
+	found := false
+	ix.cascade(matched, inline, func(rule int, important bool) {
+		var declared declaredDisplay
+		if rule >= 0 {
+			declared = ix.display[rule]
+		} else {
+			declared = inline.display
+		}
+		if d, ok := declared.get(important); ok {
+			display, found = d, true
+		}
+	})
+	return found && display == css.DisplayNone
+}
+
+// cascade calls fn with the matched rules, and the style attribute as rule
+// -1, in the order their declarations apply: the normal declarations by
+// origin from the user agent's up, then the !important ones from the
+// page's down. The style attribute comes after the page's rules.
+func (ix *ruleIndex) cascade(matched []int, inline *inlineStyle, fn func(rule int, important bool)) {
+	for _, i := range matched {
+		fn(i, false)
+	}
+	if inline != nil {
+		fn(-1, false)
+	}
+
+	if !ix.hasImportant && (inline == nil || !inline.hasImportant) {
+		return
+	}
+	for _, origin := range [...]css.Origin{css.OriginAuthor, css.OriginUser, css.OriginUserAgent} {
+		for _, i := range matched {
+			if ix.rules[i].Origin == origin {
+				fn(i, true)
 			}
 		}
+		if origin == css.OriginAuthor && inline != nil {
+			fn(-1, true)
+		}
+	}
+}
+
+// applyRules applies the declarations of the matched rules and the style
+// attribute in cascade order, inheriting from parent where they say so.
+// em lengths are relative to the element's own font size, and logical
+// properties map to sides by its writing-mode and direction, so the
+// declarations that set those are cascaded first.
+func (ix *ruleIndex) applyRules(style, parent *css.Style, matched []int, inline *inlineStyle, units css.Units) {
+	first := *style
+	ix.cascadeDeclarations(&first, parent, matched, inline, units, cascadedFirst)
+	units.FontSize = first.FontSize
+	style.WritingMode, style.Direction = first.WritingMode, first.Direction
+	ix.cascadeDeclarations(style, parent, matched, inline, units, nil)
+}
+
+// cascadeDeclarations applies the declarations of the matched rules and the
+// style attribute that only accepts, or all of them if it is nil, in
+// cascade order
+func (ix *ruleIndex) cascadeDeclarations(style, parent *css.Style, matched []int, inline *inlineStyle, units css.Units, only func(css.Declaration) bool) {
+	// before is the style as the origins preceding the current one left
+	// it, which revert rolls back to
+	before := *style
+	origin, important := css.OriginUserAgent, false
+	ix.cascade(matched, inline, func(rule int, imp bool) {
+		o, decls := css.OriginAuthor, []css.Declaration(nil)
+		if rule >= 0 {
+			o, decls = ix.rules[rule].Origin, ix.decls[rule]
+		} else {
+			decls = inline.decls
+		}
+		if o != origin || imp != important {
+			before, origin, important = *style, o, imp
+		}
+		applyDeclarations(style, parent, &before, decls, imp, units, only)
+	})
+}
+
+// cascadedFirst reports whether a declaration may set font-size,
+// writing-mode or direction
+func cascadedFirst(decl css.Declaration) bool {
+	switch decl.Property {
+	case "font-size", "font", "writing-mode", "direction", "all":
+		return true
 	}
 	return false
 }
+
+// applyDeclarations applies the declarations of decls that are !important
+// or not as important says, and that only accepts if it is not nil
+func applyDeclarations(style, parent, before *css.Style, decls []css.Declaration, important bool, units css.Units, only func(css.Declaration) bool) {
+	for _, decl := range decls {
+		if decl.Important != important || only != nil && !only(decl) {
+			continue
+		}
+		if css.IsRevert(decl) {
+			css.Revert(style, before, decl)
+			continue
+		}
+		css.ApplyDeclarationIn(style, parent, decl, units)
+	}
+}
+
+// animate applies the animation of style at time at, if it names a
+// @keyframes rule of the stylesheet, with relative lengths resolved against
+// units. It reports whether the animation is still running.
+func (ix *ruleIndex) animate(style *css.Style, units css.Units, at time.Duration) bool {
+	if style.Animation.Name == "" {
+		return false
+	}
+	keyframes, ok := ix.keyframes[style.Animation.Name]
+	if !ok {
+		return false
+	}
+	return css.Animate(style, keyframes, units, at)
+}
@@ -0,0 +1,330 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/text"
+)
+
+func TestRulesApplyInCascadeOrder(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p id="a" class="x">text</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	// More specific selectors win whatever their order, and !important
+	// declarations win over normal ones
+	sheet := mustParseCSS(t, `#a { width: 10px; height: 10px; } p { width: 20px; } .x, p { width: 30px; height: 30px; }
+		div { width: 40px; } p { margin: 1px !important; } .x { margin: 2px; } p:hover, .x { color: red; } #a { color: blue; }`)
+
+	tree := BuildLayoutTree(d, sheet)
+	var p *LayoutNode
+	for i := range tree.Nodes {
+		if n := d.GetNode(tree.Nodes[i].DomNode); n != nil && n.Tag == "p" {
+			p = &tree.Nodes[i]
+		}
+	}
+	if p == nil {
+		t.Fatal("no layout node for <p>")
+	}
+	if *p.Style.Width != 10 || *p.Style.Height != 10 {
+		t.Errorf("expected the id rule's width and height of 10, got %v and %v", *p.Style.Width, *p.Style.Height)
+	}
+	if p.Style.Margin.Top != 1 || p.Style.Margin.Left != 1 {
+		t.Errorf("expected the important margin of 1, got %+v", p.Style.Margin)
+	}
+	if p.Style.Color != (css.Color{B: 255, A: 255}) {
+		t.Errorf("expected the id rule's blue, got %+v", p.Style.Color)
+	}
+}
+
+func TestImportantReversesOrigins(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p id="a" style="width: 40px !important; height: 40px">text</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ua := mustParseCSS(t, "p { display: block !important; height: 10px !important; }")
+	ua.SetOrigin(css.OriginUserAgent)
+	sheet := mustParseCSS(t, "#a { display: none !important; width: 30px !important; height: 30px !important; }")
+	sheet.Append(ua)
+
+	tree := BuildLayoutTree(d, sheet)
+	if tree.Root == InvalidLayoutNodeID || tree.Nodes[tree.Root].FirstChild == InvalidLayoutNodeID {
+		t.Fatal("expected the user agent's important display to keep <p> shown")
+	}
+	p := tree.Nodes[tree.Nodes[tree.Root].FirstChild]
+	if *p.Style.Width != 40 || *p.Style.Height != 10 {
+		t.Errorf("expected width 40 and height 10, got %v and %v", *p.Style.Width, *p.Style.Height)
+	}
+}
+
+func TestRuleIndexSkipsUnrelatedRules(t *testing.T) {
+	sheet := mustParseCSS(t, "p { color: red; } .x { color: blue; } #y { color: green; }")
+	ix := newRuleIndex(sheet, nil)
+
+	node := &dom.Node{Type: dom.NodeTypeElement, Tag: "div", Attr: map[string]string{"class": "x"}}
+	style := css.DefaultStyle()
+	ix.apply(&style, nil, node, nil)
+
+	if len(ix.matched) != 1 || ix.matched[0] != 1 {
+		t.Errorf("expected only rule 1 to match, got %v", ix.matched)
+	}
+}
+
+func TestStyleOfReceivesAncestors(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div><p>a</p><span>b</span></div><p>c</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	var got []string
+	buildLayoutTree(d, css.DefaultUnits(), func(node *dom.Node, ancestors []*dom.Node, parentStyle css.Style, units css.Units) css.Style {
+		if node.Type == dom.NodeTypeElement {
+			var path []string
+			for _, a := range ancestors {
+				path = append(path, a.Tag)
+			}
+			got = append(got, strings.Join(append(path, node.Tag), ">"))
+		}
+		return parentStyle
+	})
+
+	// The elements around body are styled first, for it to inherit from
+	want := []string{
+		"html",
+		"html>body",
+		"html>body>div",
+		"html>body>div>p",
+		"html>body>div>span",
+		"html>body>p",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestHiddenSubtreesArePruned(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div class="banner"><p>cookies</p></div><p hidden>gone</p><script>var x;</script><p class="shown">kept</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, ".banner { display: none; } p { display: none; } .shown { display: block; }")
+	sheet.Append(css.UserAgentStylesheet())
+
+	styled := 0
+	rules := newRuleIndex(sheet, nil)
+	tree := buildLayoutTree(d, css.DefaultUnits(), func(node *dom.Node, ancestors []*dom.Node, parentStyle css.Style, units css.Units) css.Style {
+		styled++
+		return computeStyle(d, node, ancestors, parentStyle, rules, units)
+	})
+
+	var texts []string
+	for _, node := range tree.Nodes {
+		if node.Text != "" {
+			texts = append(texts, node.Text)
+		}
+	}
+	if got := strings.Join(texts, " "); got != "kept" {
+		t.Errorf("expected only %q to be laid out, got %q", "kept", got)
+	}
+	// html, body, the banner, the hidden p, the script, the shown p and
+	// its text
+	if styled != 7 {
+		t.Errorf("expected 7 nodes to be styled, got %d", styled)
+	}
+}
+
+func TestFirstLetterAndLine(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="a"><p>one</p><p>two</p></div>` +
+		`<span>three</span></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `#a::first-line { color: red; } p::first-letter { font-size: 32px; }
+		#a::first-letter { color: blue; } span { display: inline; } span::first-letter { color: green; }`)
+	tree := BuildLayoutTree(d, sheet)
+
+	texts := map[string]css.Style{}
+	for i := range tree.Nodes {
+		if node := &tree.Nodes[i]; node.Text != "" {
+			texts[node.Text] = node.Style
+		}
+	}
+	red, blue := css.Color{R: 255, A: 255}, css.Color{B: 255, A: 255}
+
+	// The first text of #a gets its first line and letter, with the
+	// paragraph's first letter laid over its own
+	one := texts["one"]
+	if !one.FirstLine.HasColor || one.FirstLine.Color != red {
+		t.Errorf("expected a red first line, got %+v", one.FirstLine)
+	}
+	if letter := one.FirstLetter; letter.Color != blue || letter.FontSize != 32 {
+		t.Errorf("expected a blue 32px first letter, got %+v", letter)
+	}
+	if two := texts["two"]; two.FirstLine.IsSet() || two.FirstLetter.Color == blue || two.FirstLetter.FontSize != 32 {
+		t.Errorf("expected only the paragraph's first letter on the second text, got %+v %+v", two.FirstLine, two.FirstLetter)
+	}
+	if three := texts["three"]; three.FirstLetter.IsSet() {
+		t.Errorf("expected an inline element to have no first letter, got %+v", three.FirstLetter)
+	}
+
+	ComputeLayout(tree, 800, 600)
+	for i := range tree.Nodes {
+		if node := &tree.Nodes[i]; node.Text == "one" && node.Rect.H != text.MetricsOf(text.Font{Size: 32}).LineHeight {
+			t.Errorf("expected the first line to fit the 32px letter, got height %v", node.Rect.H)
+		}
+	}
+}
+
+func TestOriginsCascadeInOrder(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p id="a">one</p><p id="b" style="width: 50px; margin-top: revert">two</p>` +
+		`<div style="display: none">three</div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	ua := mustParseCSS(t, "p { width: 10px; height: 10px; margin-top: 5px; }")
+	ua.SetOrigin(css.OriginUserAgent)
+	user := mustParseCSS(t, "p { width: 20px; height: 20px; }")
+	user.SetOrigin(css.OriginUser)
+
+	// The author sheet comes first, but its rules still override the others
+	sheet := mustParseCSS(t, "p { width: 30px; margin-top: 7px; }")
+	sheet.Append(user)
+	sheet.Append(ua)
+	tree := BuildLayoutTree(d, sheet)
+
+	styles := map[string]css.Style{}
+	for i := range tree.Nodes {
+		if n := d.GetNode(tree.Nodes[i].DomNode); n != nil && n.Tag != "" {
+			styles[n.Tag+"#"+n.Attr["id"]] = tree.Nodes[i].Style
+		}
+	}
+	if a := styles["p#a"]; *a.Width != 30 || *a.Height != 20 || a.Margin.Top != 7 {
+		t.Errorf("expected width 30, height 20 and margin 7, got %v, %v and %v", *a.Width, *a.Height, a.Margin.Top)
+	}
+	// The style attribute overrides the rules, and revert rolls back to the
+	// user agent's margin
+	if b := styles["p#b"]; *b.Width != 50 || b.Margin.Top != 5 {
+		t.Errorf("expected width 50 and margin 5, got %v and %v", *b.Width, b.Margin.Top)
+	}
+	if _, ok := styles["div#"]; ok {
+		t.Error("expected the div hidden by its style attribute to be pruned")
+	}
+}
+
+func TestMediaRules(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p>one</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `p { width: 10px; }
+		@media screen and (min-width: 600px) { p { height: 20px; } }
+		@media print { p { height: 30px; } }
+		@media (prefers-color-scheme: dark) { p { width: 40px; } }`)
+
+	// Unevaluated rules apply as on the default screen
+	p := BuildLayoutTree(d, sheet).Nodes[1].Style
+	if *p.Width != 10 || *p.Height != 20 {
+		t.Errorf("expected width 10 and height 20, got %v and %v", *p.Width, *p.Height)
+	}
+
+	media := css.DefaultMediaContext()
+	media.ColorScheme = "dark"
+	media.Width = 400
+	p = BuildLayoutTree(d, sheet.ForMedia(media)).Nodes[1].Style
+	if *p.Width != 40 || p.Height != nil {
+		t.Errorf("expected width 40 and no height, got %v and %v", *p.Width, p.Height)
+	}
+}
+
+func TestRelativeUnits(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p id="p">a</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	// The width in em follows the font size of a later rule, and rem
+	// follows the font size of html
+	sheet := mustParseCSS(t, `html { font-size: 10px; } body { font-size: 2em; }
+		p { width: 10em; height: 2rem; margin-left: 10vw; padding-top: 10vh; }
+		#p { font-size: 1.5em; }`)
+	tree := BuildLayoutTreeIn(d, sheet, 400, 300, 0)
+
+	style := tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "p"))].Style
+	if style.FontSize != 30 {
+		t.Errorf("expected a 30px font, got %v", style.FontSize)
+	}
+	if *style.Width != 300 || *style.Height != 20 {
+		t.Errorf("expected 300x20, got %vx%v", *style.Width, *style.Height)
+	}
+	if style.Margin.Left != 40 || style.Padding.Top != 30 {
+		t.Errorf("expected a 40px margin and 30px padding, got %v and %v", style.Margin.Left, style.Padding.Top)
+	}
+}
+
+func TestLogicalPropertiesFollowWritingMode(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p id="p">a</p><p id="q">b</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	// The writing mode and direction are declared after the logical
+	// properties, and still decide the sides they set; a later physical
+	// declaration overrides the logical one it maps to
+	sheet := mustParseCSS(t, `p { margin-inline-start: 10px; padding-block-start: 5px; }
+		#p { writing-mode: vertical-rl; }
+		#q { direction: rtl; margin-right: 20px; }`)
+	tree := BuildLayoutTree(d, sheet)
+
+	p := tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "p"))].Style
+	if p.Margin.Top != 10 || p.Padding.Right != 5 {
+		t.Errorf("expected the margin at the top and padding at the right, got %+v and %+v", p.Margin, p.Padding)
+	}
+	q := tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "q"))].Style
+	if q.Margin.Right != 20 || q.Padding.Top != 5 {
+		t.Errorf("expected the margin at the right overridden and padding at the top, got %+v and %+v", q.Margin, q.Padding)
+	}
+}
+
+func TestSizeImages(t *testing.T) {
+	d, err := dom.ParseString(`<html><body>` +
+		`<img id="natural" src="a.png">` +
+		`<img id="wide" src="a.png" width="40">` +
+		`<img id="tall" src="a.png">` +
+		`<img id="set" src="a.png" width="5" height="5">` +
+		`<img id="missing" src="missing.png">` +
+		`</body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `#tall { height: 30px; }`)
+	tree := BuildLayoutTree(d, sheet)
+
+	size := func(id string) [2]float32 {
+		s := tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Style
+		return [2]float32{*s.Width, *s.Height}
+	}
+	// Until their images are known, auto sides are 0
+	if got := size("natural"); got != [2]float32{0, 0} {
+		t.Errorf("expected an unsized image to be 0x0, got %v", got)
+	}
+
+	SizeImages(tree, func(src string) (float32, float32, bool) {
+		return 20, 10, src == "a.png"
+	})
+	tests := []struct {
+		id   string
+		want [2]float32
+	}{
+		{"natural", [2]float32{20, 10}},
+		{"wide", [2]float32{40, 20}},
+		{"tall", [2]float32{60, 30}},
+		{"set", [2]float32{5, 5}},
+		{"missing", [2]float32{0, 0}},
+	}
+	for _, tt := range tests {
+		if got := size(tt.id); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.id, tt.want, got)
+		}
+	}
+}
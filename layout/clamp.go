@@ -0,0 +1,138 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/myuon/penny/css"
+)
+
+// LineClamp marks the line of a text node where the line-clamp of an
+// element around it cuts the element's content off. The line ends in an
+// ellipsis where content follows it, and is cut short to make room for it
+// where it overflows. Lines don't wrap, so a line that overflows stands for
+// the ones it would have wrapped to.
+type LineClamp struct {
+	Line int  // of the text, from 1; 0 where the text isn't clamped
+	More bool // whether content follows the line
+}
+
+// lineBoxOf is a line box in the content of an element with a line-clamp
+type lineBoxOf struct {
+	bottom float32     // below the top of the element's content box
+	text   *LayoutNode // the last text in the line, or nil if it has none
+	line   int         // of text, from 1
+}
+
+// eachLineBox calls yield with the line boxes of the content of node in
+// order, until it returns false. They are found as layoutChildren lays the
+// content out: a text laid out as a block has a line box per line, and a
+// run laid out in line boxes is one line box, as high as its height is
+// estimated. The boxes in grids, tables, rubies and vertical or
+// multi-column elements aren't in lines of the flow and aren't counted.
+func eachLineBox(tree *LayoutTree, node *LayoutNode, heights []float32, yield func(lineBoxOf) bool) {
+	// next is the next box to look at, with its parent and the top of its
+	// margin box
+	type next struct {
+		parent, id LayoutNodeID
+		y          float32
+	}
+	stack := []next{{node.ID, node.FirstChild, 0}}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if n.id == InvalidLayoutNodeID {
+			continue
+		}
+		child := &tree.Nodes[n.id]
+		if !inFlow(child) {
+			stack = append(stack, next{n.parent, child.NextSibling, n.y})
+			continue
+		}
+
+		if end, atomic := inlineRun(tree, n.id); atomic {
+			parent := &tree.Nodes[n.parent]
+			line := lineBoxOf{bottom: n.y + runHeight(tree, parent, n.id, end, heights)}
+			for id := n.id; id != end; id = tree.Nodes[id].NextSibling {
+				if text := &tree.Nodes[id]; text.Text != "" {
+					line.text, line.line = text, strings.Count(text.Text, "\n")+1
+				}
+			}
+			if !yield(line) {
+				return
+			}
+			stack = append(stack, next{n.parent, end, line.bottom})
+			continue
+		}
+
+		top := n.y + child.Style.Margin.Top
+		h := heights[n.id]
+		if sh, ok := setHeight(&child.Style); ok {
+			h = sh
+		}
+		stack = append(stack, next{n.parent, child.NextSibling, top + h + child.Style.Margin.Bottom})
+
+		if child.Text != "" {
+			bottom := top + child.Style.Padding.Top + FirstLineHeight(child.Style)
+			for i := range strings.Count(child.Text, "\n") + 1 {
+				if i > 0 {
+					bottom += LineHeight(child.Style)
+				}
+				if !yield(lineBoxOf{bottom: bottom, text: child, line: i + 1}) {
+					return
+				}
+			}
+			continue
+		}
+		s := &child.Style
+		if s.Display == css.DisplayGrid || s.Display == css.DisplayTable || s.Display == css.DisplayRuby ||
+			s.WritingMode.Vertical() || s.Columns.Count > 1 || laidOutByTable(child) {
+			continue
+		}
+		// layoutChildren puts the content below the margin again
+		stack = append(stack, next{n.id, child.FirstChild, top + s.Margin.Top + s.Padding.Top})
+	}
+}
+
+// passLineClamp marks where the line-clamp of each element cuts its
+// content off: the last text in its clamped line box
+func passLineClamp(tree *LayoutTree, heights []float32) {
+	for i := range tree.Nodes {
+		node := &tree.Nodes[i]
+		if node.Style.LineClamp == 0 {
+			continue
+		}
+		left := int(node.Style.LineClamp)
+		var last *LayoutNode
+		eachLineBox(tree, node, heights, func(line lineBoxOf) bool {
+			if left == 0 {
+				if last != nil {
+					last.Clamp.More = true
+				}
+				return false
+			}
+			left--
+			if left == 0 && line.text != nil {
+				line.text.Clamp = LineClamp{Line: line.line}
+				last = line.text
+			}
+			return true
+		})
+	}
+}
+
+// clampedHeight returns how high the content of an element with a
+// line-clamp is through the bottom of its last line box, or false if it has
+// fewer line boxes than the clamp
+func clampedHeight(tree *LayoutTree, node *LayoutNode, heights []float32) (float32, bool) {
+	if node.Style.LineClamp == 0 {
+		return 0, false
+	}
+	left := int(node.Style.LineClamp)
+	var h float32
+	eachLineBox(tree, node, heights, func(line lineBoxOf) bool {
+		h = line.bottom
+		left--
+		return left > 0
+	})
+	return h, left == 0
+}
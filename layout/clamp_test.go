@@ -0,0 +1,66 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestLineClamp(t *testing.T) {
+	d, err := dom.ParseString("<html><body><div id=\"c\"><pre>one\ntwo</pre><pre id=\"p\">three\nfour\nfive</pre><pre>six</pre></div><p id=\"after\">after</p></body></html>")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body, pre, p { margin: 0; } pre { white-space: pre; } #c { display: -webkit-box; -webkit-line-clamp: 3; overflow: hidden; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	node := func(id string) *LayoutNode {
+		return &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))]
+	}
+	c, p := node("c"), node("p")
+
+	// The third line is the first of the second text, with more after it
+	text := &tree.Nodes[p.FirstChild]
+	if text.Clamp != (LineClamp{Line: 1, More: true}) {
+		t.Errorf("expected the clamp on the first line of the second text, got %+v", text.Clamp)
+	}
+	if h := 3 * LineHeight(c.Style); c.Rect.H != h {
+		t.Errorf("expected the box three lines high, %v, got %v", h, c.Rect.H)
+	}
+	if after := node("after").Rect; after.Y != c.Rect.Y+c.Rect.H {
+		t.Errorf("expected the next block right below the clamped box, got %v", after)
+	}
+}
+
+func TestLineClampParagraphs(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="c"><p id="p1">one</p><p id="p2">two <img id="i"> more</p><p id="p3">three</p></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { margin: 0; } p { margin: 10px 0; padding: 5px; } img { width: 20px; height: 40px; }
+		#c { display: -webkit-box; -webkit-line-clamp: 2; overflow: hidden; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	node := func(id string) *LayoutNode {
+		return &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))]
+	}
+	c, p2, img := node("c"), node("p2"), node("i")
+
+	// The second line box is the run of text and the image, marked on the
+	// last text in it
+	if first := &tree.Nodes[node("p1").FirstChild]; first.Clamp != (LineClamp{}) {
+		t.Errorf("expected the first line not to be clamped, got %+v", first.Clamp)
+	}
+	last := &tree.Nodes[p2.LastChild]
+	if last.Clamp != (LineClamp{Line: 1, More: true}) {
+		t.Errorf("expected the clamp on the text after the image, got %+v", last.Clamp)
+	}
+
+	// The box reaches to the bottom of that line box, below the margins and
+	// padding of the paragraphs above it
+	if bottom := last.Rect.Y + last.Rect.H; c.Rect.Y+c.Rect.H != bottom || bottom <= img.Rect.Y+img.Rect.H {
+		t.Errorf("expected the box to end with the line box at %v, got %v", bottom, c.Rect)
+	}
+}
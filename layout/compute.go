@@ -1,7 +1,32 @@
 package layout
 
-// ComputeLayout calculates the geometry (x, y, w, h) for all nodes
+import (
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/text"
+)
+
+// ComputeLayout calculates the geometry (x, y, w, h) for all nodes. vw and
+// vh lengths were resolved when the tree was built, against the viewport
+// given to BuildLayoutTreeIn, which should be this one.
 func ComputeLayout(tree *LayoutTree, viewportWidth, viewportHeight float32) {
+	computeLayout(tree, viewportWidth, viewportHeight, nil)
+}
+
+// ComputeLayoutTraced is like ComputeLayout, and also returns a trace of
+// how each box was sized
+func ComputeLayoutTraced(tree *LayoutTree, viewportWidth, viewportHeight float32) *Trace {
+	trace := newTrace(tree, viewportWidth, viewportHeight)
+	computeLayout(tree, viewportWidth, viewportHeight, trace)
+	return trace
+}
+
+// computeLayout lays out the tree, recording each box in trace if it is
+// not nil
+func computeLayout(tree *LayoutTree, viewportWidth, viewportHeight float32, trace *Trace) {
+	tree.checkMutable()
+
 	if tree.Root == InvalidLayoutNodeID {
 		return
 	}
@@ -18,15 +43,63 @@ func ComputeLayout(tree *LayoutTree, viewportWidth, viewportHeight float32) {
 	root.Rect.W = viewportWidth
 	root.Rect.H = viewportHeight
 
-	// Layout children
-	layoutChildren(tree, tree.Root)
+	// The tree is walked in flat passes over its pre-order instead of
+	// recursively, so deeply nested documents can't overflow the stack
+	order := preorder(tree)
+	heights := estimateHeights(tree, order)
+	passLineClamp(tree, heights)
+	positions := newPositioning(tree, root.Rect)
+	if trace != nil {
+		trace.record(root, traceRoot, root.Rect, heights)
+	}
+
+	// Position children top-down. A node's own rect is always set before its
+	// children are positioned, since parents precede them in pre-order.
+	//
+	// lineBottoms records the bottom of the last line box of the nodes whose
+	// content ends in one, which is below the boxes in the line
+	var lineBottoms map[LayoutNodeID]float32
+	for _, nodeID := range order {
+		if bottom, ok := layoutChildren(tree, nodeID, heights, positions, trace); ok {
+			if lineBottoms == nil {
+				lineBottoms = make(map[LayoutNodeID]float32)
+			}
+			lineBottoms[nodeID] = bottom
+		}
+	}
+
+	// Grow auto-height nodes to fit their last child, bottom-up
+	for i := len(order) - 1; i >= 0; i-- {
+		fitHeight(tree, order[i], lineBottoms[order[i]], heights)
+	}
+	placeMarkers(tree)
+	if trace != nil {
+		trace.finish(tree)
+	}
+}
+
+// preorder lists the nodes reachable from the root, parents before children
+func preorder(tree *LayoutTree) []LayoutNodeID {
+	var order []LayoutNodeID
+	Walk(tree, tree.Root, func(node *LayoutNode, depth int) WalkAction {
+		order = append(order, node.ID)
+		return WalkContinue
+	})
+	return order
 }
 
-func layoutChildren(tree *LayoutTree, nodeID LayoutNodeID) {
+// layoutChildren positions the children of a node, recording them in trace
+// if it is not nil. A relatively positioned node is first moved from where
+// its parent put it, taking its children along. If they end in a line box,
+// it returns the bottom of that line box and true.
+func layoutChildren(tree *LayoutTree, nodeID LayoutNodeID, heights []float32, positions *positioning, trace *Trace) (float32, bool) {
 	node := tree.GetNode(nodeID)
 	if node == nil {
-		return
+		return 0, false
 	}
+	dx, dy := relativeOffset(&node.Style)
+	node.Rect.X += dx
+	node.Rect.Y += dy
 
 	// Calculate content area (after padding/margin)
 	contentX := node.Rect.X + node.Style.Margin.Left + node.Style.Padding.Left
@@ -34,24 +107,114 @@ func layoutChildren(tree *LayoutTree, nodeID LayoutNodeID) {
 	contentW := node.Rect.W - node.Style.Margin.Left - node.Style.Margin.Right -
 		node.Style.Padding.Left - node.Style.Padding.Right
 
+	if node.Style.Display == css.DisplayGrid {
+		for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+			if child := &tree.Nodes[childID]; !inFlow(child) {
+				placeOutOfFlow(tree, child, positions, contentX, contentY, heights, trace)
+			}
+		}
+		layoutGrid(tree, node, contentX, contentY, contentW, heights, trace)
+		return 0, false
+	}
+	if node.Style.Display == css.DisplayRuby {
+		for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+			if child := &tree.Nodes[childID]; !inFlow(child) {
+				placeOutOfFlow(tree, child, positions, contentX, contentY, heights, trace)
+			}
+		}
+		layoutRuby(tree, node, contentX, contentY, heights, trace)
+		return 0, false
+	}
+	if node.Style.Display == css.DisplayTable {
+		for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+			if child := &tree.Nodes[childID]; !inFlow(child) {
+				placeOutOfFlow(tree, child, positions, contentX, contentY, heights, trace)
+			}
+		}
+		layoutTable(tree, node, contentX, contentY, contentW, heights, trace)
+		return 0, false
+	}
+	if laidOutByTable(node) {
+		for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+			if child := &tree.Nodes[childID]; !inFlow(child) {
+				placeOutOfFlow(tree, child, positions, contentX, contentY, heights, trace)
+			}
+		}
+		return 0, false
+	}
+	if node.Style.WritingMode.Vertical() {
+		for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+			if child := &tree.Nodes[childID]; !inFlow(child) {
+				placeOutOfFlow(tree, child, positions, contentX, contentY, heights, trace)
+			}
+		}
+		layoutVertical(tree, node, contentX, contentY, contentW, heights, trace)
+		return 0, false
+	}
+
 	// Track current Y position for block layout
 	currentY := contentY
 
-	for _, childID := range node.Children {
+	// A multi-column element lays its children out in columns side by side,
+	// moving to the next column at each break
+	var breaks []LayoutNodeID
+	count := node.Style.Columns.Count
+	if count > 1 {
+		gap := node.Style.ColumnGap()
+		contentW = max(0, (contentW-gap*float32(count-1))/float32(count))
+		breaks, _ = columnBreaks(tree, node, heights)
+	}
+
+	inLines := false
+	for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
 		child := tree.GetNode(childID)
-		if child == nil {
+
+		if len(breaks) > 0 && breaks[0] == childID {
+			breaks = breaks[1:]
+			contentX += contentW + node.Style.ColumnGap()
+			currentY = contentY
+		}
+
+		// An out-of-flow child takes no room in the flow
+		if !inFlow(child) {
+			placeOutOfFlow(tree, child, positions, contentX, currentY, heights, trace)
 			continue
 		}
 
+		if end, atomic := inlineRun(tree, childID); atomic {
+			if trace != nil {
+				for id := childID; id != end; id = tree.Nodes[id].NextSibling {
+					trace.record(&tree.Nodes[id], traceLine, Rect{contentX, currentY, contentW, 0}, heights)
+				}
+			}
+			currentY = layoutLines(tree, node, childID, end, contentX, currentY, contentW, heights)
+			inLines = end == InvalidLayoutNodeID
+			// A run isn't split between columns
+			for id := childID; id != end; id = tree.Nodes[id].NextSibling {
+				if len(breaks) > 0 && breaks[0] == id {
+					breaks = breaks[1:]
+				}
+				childID = id
+			}
+			continue
+		}
+
+		if trace != nil {
+			trace.record(child, traceBlock, Rect{contentX, currentY, contentW, 0}, heights)
+		}
+
 		// Calculate child dimensions
 		childW := contentW
-		if child.Style.Width != nil {
-			childW = *child.Style.Width
+		if w, ok := setWidth(&child.Style); ok {
+			childW = w
+		} else if child.Style.WritingMode.Vertical() {
+			// A vertical box is as wide as its columns
+			childW = verticalWidth(tree, childID, heights)
 		}
 
-		childH := estimateHeight(tree, childID)
-		if child.Style.Height != nil {
-			childH = *child.Style.Height
+		childH := heights[childID]
+		if h, ok := setHeight(&child.Style); ok {
+			childH = h
 		}
 
 		// Position child
@@ -62,50 +225,215 @@ func layoutChildren(tree *LayoutTree, nodeID LayoutNodeID) {
 
 		// Move Y for next sibling (block layout)
 		currentY = child.Rect.Y + child.Rect.H + child.Style.Margin.Bottom
-
-		// Recursively layout grandchildren
-		layoutChildren(tree, childID)
 	}
+	return currentY, inLines
+}
 
-	// Update parent height if auto
-	if node.Style.Height == nil && len(node.Children) > 0 {
-		lastChild := tree.GetNode(node.Children[len(node.Children)-1])
-		if lastChild != nil {
-			newH := (lastChild.Rect.Y + lastChild.Rect.H + lastChild.Style.Margin.Bottom) -
-				node.Rect.Y + node.Style.Padding.Bottom + node.Style.Margin.Bottom
-			if newH > node.Rect.H {
-				node.Rect.H = newH
+// fitHeight updates the height of an auto-height node to contain its last
+// child, or the bottom of its tallest column or grid item, and lineBottom
+func fitHeight(tree *LayoutTree, nodeID LayoutNodeID, lineBottom float32, heights []float32) {
+	node := tree.GetNode(nodeID)
+	// A clamped element's content overflows it past the clamp
+	if _, clamped := clampedHeight(tree, node, heights); clamped {
+		return
+	}
+	// A row or row group is as high as the table sized its rows, which the
+	// cells spanning down from it overflow
+	if laidOutByTable(node) {
+		return
+	}
+	if node.Style.Height == nil && node.LastChild != InvalidLayoutNodeID {
+		bottom := lineBottom
+		spread := node.Style.Columns.Count > 1 || node.Style.Display == css.DisplayGrid ||
+			node.Style.Display == css.DisplayRuby || node.Style.WritingMode.Vertical() ||
+			node.Style.Display == css.DisplayTable
+		var last *LayoutNode
+		for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+			child := tree.GetNode(childID)
+			if !inFlow(child) {
+				continue
+			}
+			if spread {
+				bottom = max(bottom, flowBottom(child))
 			}
+			last = child
+		}
+		if last == nil {
+			return
+		}
+		if !spread {
+			bottom = max(bottom, flowBottom(last))
+		}
+		newH := bottom - node.Rect.Y + node.Style.Padding.Bottom + node.Style.Margin.Bottom
+		if newH > node.Rect.H {
+			node.Rect.H = newH
 		}
 	}
 }
 
-func estimateHeight(tree *LayoutTree, nodeID LayoutNodeID) float32 {
-	node := tree.GetNode(nodeID)
-	if node == nil {
-		return 0
+// columnBreaks splits the children of a multi-column node between its
+// columns, balancing their heights. It returns the child starting each
+// column after the first and the height of the tallest column. Children
+// aren't fragmented themselves: a column breaks only between them.
+func columnBreaks(tree *LayoutTree, node *LayoutNode, heights []float32) ([]LayoutNodeID, float32) {
+	var total float32
+	for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+		child := tree.GetNode(childID)
+		if inFlow(child) {
+			total += heights[childID] + child.Style.Margin.Top + child.Style.Margin.Bottom
+		}
 	}
+	count := node.Style.Columns.Count
+	target := total / float32(count)
 
-	// Text node: estimate based on font size
-	if node.Text != "" {
-		lineHeight := node.Style.FontSize * 1.5
-		return lineHeight + node.Style.Padding.Top + node.Style.Padding.Bottom
+	var breaks []LayoutNodeID
+	var column, tallest float32
+	for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+		child := tree.GetNode(childID)
+		if !inFlow(child) {
+			continue
+		}
+		h := heights[childID] + child.Style.Margin.Top + child.Style.Margin.Bottom
+		// Break before a child that would overfill the column, unless it
+		// overfills less than leaving the column short would underfill it
+		if column > 0 && len(breaks) < count-1 && column+h > target && column+h-target > target-column {
+			breaks = append(breaks, childID)
+			column = 0
+		}
+		column += h
+		tallest = max(tallest, column)
 	}
+	return breaks, tallest
+}
 
-	// Element with explicit height
-	if node.Style.Height != nil {
-		return *node.Style.Height
+// setWidth returns the width of a box with a width set, its padding and
+// border included, or false if its width is auto. A width sets the content
+// box, unless box-sizing makes it set the border box.
+func setWidth(s *css.Style) (float32, bool) {
+	if s.Width == nil {
+		return 0, false
 	}
+	if s.BoxSizing == css.BoxSizingBorder {
+		return *s.Width, true
+	}
+	return *s.Width + s.Padding.Left + s.Padding.Right + s.Border.Left + s.Border.Right, true
+}
 
-	// Sum children heights
-	var totalH float32
-	for _, childID := range node.Children {
-		child := tree.GetNode(childID)
-		if child != nil {
-			totalH += estimateHeight(tree, childID)
+// setHeight is setWidth for a box's height
+func setHeight(s *css.Style) (float32, bool) {
+	if s.Height == nil {
+		return 0, false
+	}
+	if s.BoxSizing == css.BoxSizingBorder {
+		return *s.Height, true
+	}
+	return *s.Height + s.Padding.Top + s.Padding.Bottom + s.Border.Top + s.Border.Bottom, true
+}
+
+// LineHeight returns the height of a line of text in a style, the normal
+// line height of its font
+func LineHeight(style css.Style) float32 {
+	return text.MetricsOf(text.FontOf(&style)).LineHeight
+}
+
+// FirstLineHeight returns the height of the first line of text in a style,
+// which its ::first-letter and ::first-line can make taller
+func FirstLineHeight(style css.Style) float32 {
+	h := LineHeight(style)
+	for _, pseudo := range [2]css.PseudoText{style.FirstLetter, style.FirstLine} {
+		h = max(h, LineHeight(pseudo.Apply(style)))
+	}
+	return h
+}
+
+// estimateHeights computes the estimated height of every node in one
+// bottom-up pass, indexed by LayoutNodeID
+func estimateHeights(tree *LayoutTree, order []LayoutNodeID) []float32 {
+	heights := make([]float32, len(tree.Nodes))
+
+	for i := len(order) - 1; i >= 0; i-- {
+		nodeID := order[i]
+		node := tree.GetNode(nodeID)
+
+		// Vertical text reaches down as far as its longest line
+		if node.Text != "" && node.Style.WritingMode.Vertical() {
+			heights[nodeID] = verticalTextHeight(node)
+			continue
+		}
+
+		// Text node: estimate based on font size, a line per line break
+		if node.Text != "" {
+			lines := float32(strings.Count(node.Text, "\n"))
+			heights[nodeID] = FirstLineHeight(node.Style) + lines*LineHeight(node.Style) +
+				node.Style.Padding.Top + node.Style.Padding.Bottom
+			continue
+		}
+
+		// Element with explicit height
+		if h, ok := setHeight(&node.Style); ok {
+			heights[nodeID] = h
+			continue
+		}
+
+		// A grid container is as high as its rows
+		if node.Style.Display == css.DisplayGrid {
+			heights[nodeID] = gridHeight(tree, node, heights) + node.Style.Padding.Top + node.Style.Padding.Bottom
+			continue
+		}
+
+		// A table is as high as its rows and captions
+		if node.Style.Display == css.DisplayTable {
+			heights[nodeID] = tableHeight(tree, node, heights) + node.Style.Padding.Top + node.Style.Padding.Bottom
+			continue
+		}
+
+		// A clamped element is as high as its lines up to the clamp
+		if h, ok := clampedHeight(tree, node, heights); ok {
+			heights[nodeID] = h + node.Style.Padding.Top + node.Style.Padding.Bottom
+			continue
+		}
+
+		// A vertical element is as high as its longest column
+		if node.Style.WritingMode.Vertical() {
+			heights[nodeID] = verticalHeight(tree, node, heights)
+			continue
+		}
+
+		// A ruby is as high as its annotations and bases together
+		if node.Style.Display == css.DisplayRuby {
+			annotations, bases := rubyHeights(tree, node, heights)
+			heights[nodeID] = annotations + bases + node.Style.Padding.Top + node.Style.Padding.Bottom
+			continue
+		}
+
+		// A multi-column element is as high as its tallest column
+		if node.Style.Columns.Count > 1 {
+			_, tallest := columnBreaks(tree, node, heights)
+			heights[nodeID] = tallest + node.Style.Padding.Top + node.Style.Padding.Bottom
+			continue
+		}
+
+		// Sum children heights, counting a run laid out in line boxes as a
+		// single line
+		var totalH float32
+		for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+			child := tree.GetNode(childID)
+			if !inFlow(child) {
+				continue
+			}
+			if end, atomic := inlineRun(tree, childID); atomic {
+				totalH += runHeight(tree, node, childID, end, heights)
+				for next := child.NextSibling; next != end; next = tree.Nodes[next].NextSibling {
+					childID = next
+				}
+				continue
+			}
+			totalH += heights[childID]
 			totalH += child.Style.Margin.Top + child.Style.Margin.Bottom
 		}
+
+		heights[nodeID] = totalH + node.Style.Padding.Top + node.Style.Padding.Bottom
 	}
 
-	return totalH + node.Style.Padding.Top + node.Style.Padding.Bottom
+	return heights
 }
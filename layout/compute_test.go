@@ -0,0 +1,263 @@
+package layout
+
+import (
+	"math"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/text"
+)
+
+func TestColumnLayout(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="c">` +
+		`<p>1</p><p>2</p><p>3</p><p>4</p><p class="tall">5</p>` +
+		`</div><p id="after">x</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 0; margin: 0; } p { height: 10px; }
+		.tall { height: 20px; } #c { column-count: 3; column-gap: 20px; width: 340px; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	var ps []*LayoutNode
+	var container, after *LayoutNode
+	for i := range tree.Nodes {
+		node := &tree.Nodes[i]
+		n := d.GetNode(node.DomNode)
+		switch {
+		case n == nil || n.Type != dom.NodeTypeElement:
+		case n.Attr["id"] == "c":
+			container = node
+		case n.Attr["id"] == "after":
+			after = node
+		case n.Tag == "p":
+			ps = append(ps, node)
+		}
+	}
+
+	// 60px of content in three columns of 100px: two paragraphs in each of
+	// the first two, and the tall one alone in the last
+	want := []Rect{
+		{X: 0, Y: 0, W: 100, H: 10},
+		{X: 0, Y: 10, W: 100, H: 10},
+		{X: 120, Y: 0, W: 100, H: 10},
+		{X: 120, Y: 10, W: 100, H: 10},
+		{X: 240, Y: 0, W: 100, H: 20},
+	}
+	for i, p := range ps {
+		if got := p.Rect; got != want[i] {
+			t.Errorf("paragraph %d: expected %v, got %v", i+1, want[i], got)
+		}
+	}
+	if container.Rect.H != 20 {
+		t.Errorf("expected the columns to be 20px high, got %v", container.Rect.H)
+	}
+	if after.Rect.Y != 20 {
+		t.Errorf("expected the next block below the columns, got y=%v", after.Rect.Y)
+	}
+}
+
+func TestInlineImageBaseline(t *testing.T) {
+	d, err := dom.ParseString(`<html><body>` +
+		`<div id="a"><img width="40" height="40"></div>` +
+		`<div id="b">ab<img id="icon" width="10" height="10">cd</div>` +
+		`<div id="c"><img width="60" height="20"><img width="60" height="30"></div>` +
+		`</body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 0; margin: 0; } #c { width: 100px; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	byID := map[string]*LayoutNode{}
+	var imgs []*LayoutNode
+	texts := map[string]*LayoutNode{}
+	for i := range tree.Nodes {
+		node := &tree.Nodes[i]
+		if node.Text != "" {
+			texts[node.Text] = node
+			continue
+		}
+		n := d.GetNode(node.DomNode)
+		if id := n.Attr["id"]; id != "" {
+			byID[id] = node
+		}
+		if n.Tag == "img" {
+			imgs = append(imgs, node)
+		}
+	}
+
+	// A lone image sits on the baseline, with the descender gap of a 16px
+	// line below it
+	m := text.MetricsOf(text.Font{Size: 16})
+	gap := m.LineHeight - m.Baseline()
+	if got := imgs[0].Rect; got != (Rect{X: 0, Y: 0, W: 40, H: 40}) {
+		t.Errorf("expected the image at the top of its line, got %v", got)
+	}
+	if got := byID["a"].Rect.H; got != 40+gap {
+		t.Errorf("expected a %vpx line box, got %v", 40+gap, got)
+	}
+
+	// The icon shares the baseline of the text around it
+	b, ab, icon, cd := byID["b"], texts["ab"], byID["icon"], texts["cd"]
+	baseline := ab.Rect.Y + m.Baseline()
+	if icon.Rect.Y+icon.Rect.H != baseline || cd.Rect.Y+m.Baseline() != baseline {
+		t.Errorf("expected a shared baseline at %v, got icon bottom %v and text at %v", baseline, icon.Rect.Y+icon.Rect.H, cd.Rect.Y)
+	}
+	if w := text.Width("ab", text.Font{Size: 16}); icon.Rect.X != w || cd.Rect.X != w+10 {
+		t.Errorf("expected the boxes side by side, got x=%v and x=%v", icon.Rect.X, cd.Rect.X)
+	}
+	if b.Rect.Y != 40+gap || b.Rect.H != m.LineHeight {
+		t.Errorf("expected a single %vpx line, got %v", m.LineHeight, b.Rect)
+	}
+
+	// Images that don't fit on a line wrap to the next one
+	c := byID["c"]
+	if imgs[3].Rect.X != 0 || imgs[3].Rect.Y != c.Rect.Y+20+gap {
+		t.Errorf("expected the second image on a second line, got %v", imgs[3].Rect)
+	}
+	if c.Rect.H != 20+gap+30+gap {
+		t.Errorf("expected two line boxes, got height %v", c.Rect.H)
+	}
+}
+
+func TestInlineTabs(t *testing.T) {
+	d, err := dom.ParseString("<html><body><div>a\tb<img id=\"icon\" width=\"10\" height=\"10\"></div></body></html>")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { margin: 0; } div { white-space: pre; tab-size: 4; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	// The text takes the room its tab is painted with
+	font := text.Font{Size: 16}
+	want := text.Width(text.ExpandTabs("a\tb", css.TabSize{Spaces: 4}, font), font)
+	if icon := tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "icon"))]; icon.Rect.X != want {
+		t.Errorf("expected the icon after the expanded tab at x=%v, got %v", want, icon.Rect.X)
+	}
+}
+
+func TestInlineBlock(t *testing.T) {
+	d, err := dom.ParseString(`<html><body>` +
+		`<div id="line">ab<span id="button">OK</span>cd</div>` +
+		`<div id="block">wide</div>` +
+		`<div id="narrow"><span id="long">a long label</span></div>` +
+		`</body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 0; margin: 0; } span { display: inline-block; padding: 4px; }
+		#narrow { width: 50px; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 400, 300)
+
+	node := func(id string) *LayoutNode {
+		return &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))]
+	}
+	font := text.Font{Size: 16}
+	m := text.MetricsOf(font)
+
+	// The inline-block is as wide as its text and padding, between the
+	// text around it, with the bottom of its box on their baseline
+	button := node("button")
+	if got, want := button.Rect.W, text.Width("OK", font)+8; got != want {
+		t.Errorf("expected the inline-block to shrink to %v, got %v", want, got)
+	}
+	if got, want := button.Rect.X, text.Width("ab", font); got != want {
+		t.Errorf("expected the inline-block after the text, at x=%v, got %v", want, got)
+	}
+	var cd *LayoutNode
+	for i := range tree.Nodes {
+		if tree.Nodes[i].Text == "cd" {
+			cd = &tree.Nodes[i]
+		}
+	}
+	if got, want := cd.Rect.X, button.Rect.X+button.Rect.W; got != want {
+		t.Errorf("expected the text after the inline-block at x=%v, got %v", want, got)
+	}
+	if got, want := button.Rect.Y+button.Rect.H, cd.Rect.Y+m.Baseline(); got != want {
+		t.Errorf("expected the inline-block's bottom on the baseline at %v, got %v", want, got)
+	}
+
+	// A block still fills the width of its parent
+	if got := node("block").Rect.W; got != 400 {
+		t.Errorf("expected a block to fill its parent, got width %v", got)
+	}
+
+	// Content wider than the line is cut to the width available
+	if got := node("long").Rect.W; got != 50 {
+		t.Errorf("expected the inline-block no wider than its parent, got %v", got)
+	}
+}
+
+func TestBoxSizing(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="content"></div><div id="border"></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 0; margin: 0; }
+		div { width: 100px; height: 50px; padding: 10px; border: 2px solid black; }
+		#border { box-sizing: border-box; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 400, 300)
+
+	rect := func(id string) Rect {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Rect
+	}
+	// A content-box width and height leave out the padding and border,
+	// which the box grows by; a border-box one takes them in
+	if got, want := rect("content"), (Rect{X: 0, Y: 0, W: 124, H: 74}); got != want {
+		t.Errorf("content-box: expected %v, got %v", want, got)
+	}
+	if got, want := rect("border"), (Rect{X: 0, Y: 74, W: 100, H: 50}); got != want {
+		t.Errorf("border-box: expected %v, got %v", want, got)
+	}
+}
+
+func TestTextAlignLines(t *testing.T) {
+	d, err := dom.ParseString(`<html><body>` +
+		`<div id="center"><span id="c"></span></div>` +
+		`<div id="right"><span id="r"></span></div>` +
+		`<div id="justify"><span id="j1"></span> <span id="j2"></span> <span id="j3"></span></div>` +
+		`<div id="words">one two three<span id="w"></span></div>` +
+		`<div id="packed"><span id="p1"></span><span id="p2"></span><span id="p3"></span><span id="p4"></span></div>` +
+		`</body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 0; margin: 0; } div { width: 100px; }
+		span { display: inline-block; width: 30px; height: 10px; }
+		#center { text-align: center; } #right { text-align: right; } #justify, #words, #packed { text-align: justify; }
+		#justify span { width: 40px; } #w { width: 90px; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 400, 300)
+
+	x := func(id string) float32 {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Rect.X
+	}
+	if got := x("c"); got != 35 {
+		t.Errorf("expected the line centered at x=35, got %v", got)
+	}
+	if got := x("r"); got != 70 {
+		t.Errorf("expected the line at the right at x=70, got %v", got)
+	}
+	// The wrapped line is spread to both sides by widening the space
+	// between its boxes, but not the one hanging at its end, and the last
+	// line isn't
+	if got := []float32{x("j1"), x("j2"), x("j3")}; got[0] != 0 || math.Abs(float64(got[1]-60)) > 0.01 || got[2] != 0 {
+		t.Errorf("expected the first line justified and the last at the left, got %v", got)
+	}
+	// Spaces between words in a text are widened too
+	words := &tree.Nodes[tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "words"))].FirstChild]
+	if words.WordSpacing <= 0 || math.Abs(float64(words.Rect.W-100)) > 0.01 {
+		t.Errorf("expected the text spread over the line, got %v wide with spacing %v", words.Rect.W, words.WordSpacing)
+	}
+	// A line without spaces has nowhere to spread
+	if got := []float32{x("p1"), x("p2"), x("p3"), x("p4")}; got[1] != 30 || got[2] != 60 || got[3] != 0 {
+		t.Errorf("expected a line without spaces at the left, got %v", got)
+	}
+}
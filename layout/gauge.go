@@ -0,0 +1,100 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/myuon/penny/dom"
+)
+
+// GaugeKind is the element a gauge is drawn for
+type GaugeKind uint8
+
+const (
+	GaugeNone GaugeKind = iota
+	GaugeProgress
+	GaugeMeter
+)
+
+// GaugeLevel is how good the value of a <meter> is, by where it is
+// between its low, high and optimum values
+type GaugeLevel uint8
+
+const (
+	GaugeOptimum GaugeLevel = iota
+	GaugeSuboptimum
+	GaugeEvenLessGood
+)
+
+// Gauge is the bar drawn in place of a <progress> or <meter>. Fill is how
+// full the bar is, from 0 to 1, or negative for a progress bar without a
+// value, whose progress isn't known.
+type Gauge struct {
+	Kind  GaugeKind
+	Fill  float32
+	Level GaugeLevel
+}
+
+// gaugeTags are the elements drawn as a gauge
+var gaugeTags = map[string]bool{"progress": true, "meter": true}
+
+// numberAttr returns the value of an attribute of elem as a number, or def
+// if it isn't one
+func numberAttr(elem *dom.Node, name string, def float64) float64 {
+	if v, err := strconv.ParseFloat(strings.TrimSpace(elem.Attr[name]), 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// clamp returns v within lo and hi, or lo if hi is less than it
+func clamp(v, lo, hi float64) float64 {
+	return max(lo, min(v, hi))
+}
+
+// gaugeOf reads the gauge of a <progress> or <meter> from its attributes
+func gaugeOf(elem *dom.Node) Gauge {
+	if elem.Tag == "progress" {
+		limit := numberAttr(elem, "max", 1)
+		if limit <= 0 {
+			limit = 1
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSpace(elem.Attr["value"]), 64); err != nil {
+			return Gauge{Kind: GaugeProgress, Fill: -1}
+		}
+		value := clamp(numberAttr(elem, "value", 0), 0, limit)
+		return Gauge{Kind: GaugeProgress, Fill: float32(value / limit)}
+	}
+
+	lo := numberAttr(elem, "min", 0)
+	hi := max(lo, numberAttr(elem, "max", 1))
+	value := clamp(numberAttr(elem, "value", 0), lo, hi)
+	low := clamp(numberAttr(elem, "low", lo), lo, hi)
+	high := clamp(numberAttr(elem, "high", hi), low, hi)
+	optimum := clamp(numberAttr(elem, "optimum", (lo+hi)/2), lo, hi)
+
+	g := Gauge{Kind: GaugeMeter}
+	if hi > lo {
+		g.Fill = float32((value - lo) / (hi - lo))
+	}
+	// The region the optimum is in is optimal, the ones next to it
+	// suboptimal, and the one across from it even less good
+	region := func(v float64) int {
+		switch {
+		case v < low:
+			return 0
+		case v > high:
+			return 2
+		}
+		return 1
+	}
+	switch d := region(value) - region(optimum); {
+	case d == 0:
+		g.Level = GaugeOptimum
+	case d == 1 || d == -1:
+		g.Level = GaugeSuboptimum
+	default:
+		g.Level = GaugeEvenLessGood
+	}
+	return g
+}
@@ -0,0 +1,68 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestGaugeOf(t *testing.T) {
+	tests := []struct {
+		html string
+		want Gauge
+	}{
+		{`<progress value="30" max="120">`, Gauge{Kind: GaugeProgress, Fill: 0.25}},
+		{`<progress value="2">`, Gauge{Kind: GaugeProgress, Fill: 1}},
+		{`<progress max="10">`, Gauge{Kind: GaugeProgress, Fill: -1}},
+		{`<meter value="0.5">`, Gauge{Kind: GaugeMeter, Fill: 0.5}},
+		{`<meter min="10" max="20" value="5">`, Gauge{Kind: GaugeMeter, Fill: 0}},
+		// Past high with the optimum between low and high is suboptimal,
+		// and with the optimum below low even less good
+		{`<meter value="90" max="100" low="20" high="80">`, Gauge{Kind: GaugeMeter, Fill: 0.9, Level: GaugeSuboptimum}},
+		{`<meter value="90" max="100" low="20" high="80" optimum="10">`, Gauge{Kind: GaugeMeter, Fill: 0.9, Level: GaugeEvenLessGood}},
+		{`<meter value="10" max="100" low="20" high="80" optimum="10">`, Gauge{Kind: GaugeMeter, Fill: 0.1}},
+	}
+	for _, tt := range tests {
+		d, err := dom.ParseString(`<html><body>` + tt.html + `</body></html>`)
+		if err != nil {
+			t.Fatalf("parse error: %v", err)
+		}
+		var elem *dom.Node
+		for i := range d.Nodes {
+			if gaugeTags[d.Nodes[i].Tag] {
+				elem = &d.Nodes[i]
+			}
+		}
+		if got := gaugeOf(elem); got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.html, tt.want, got)
+		}
+	}
+}
+
+func TestGaugeAndRuleBoxes(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><progress id="p" value="1">fallback</progress><meter id="m"></meter><hr id="hr"></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { margin: 0; }`)
+	sheet.Append(css.UserAgentStylesheet())
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	node := func(id string) *LayoutNode {
+		return &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))]
+	}
+	p, m, hr := node("p"), node("m"), node("hr")
+	if p.Rect.W != 160 || p.Rect.H != 16 || m.Rect.W != 80 || m.Rect.H != 16 {
+		t.Errorf("expected the default gauge sizes, got %v and %v", p.Rect, m.Rect)
+	}
+	if p.FirstChild != InvalidLayoutNodeID {
+		t.Error("expected the fallback content of the progress bar not to be laid out")
+	}
+	// A height sets the content box, so with a height of 0 the rule is as
+	// high as its borders
+	if hr.Rect.W != 800 || hr.Rect.H != 2 || hr.Rect.Y < m.Rect.Y+m.Rect.H+8 {
+		t.Errorf("expected a 2px rule across the page below the gauges, got %v", hr.Rect)
+	}
+}
@@ -0,0 +1,398 @@
+package layout
+
+import (
+	"math"
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/text"
+)
+
+// A grid container places each of its in-flow children, text included, in
+// an area of its grid: a span of columns and a span of rows. Columns are
+// sized against the width of the container, rows against the heights
+// estimated for the items. Items are stretched to fill their area.
+
+// gridItem is a child of a grid container and the area it is placed in, as
+// track indices from 0 with the ends exclusive
+type gridItem struct {
+	id               LayoutNodeID
+	col, colEnd      int
+	row, rowEnd      int
+	colSpan, rowSpan int
+}
+
+// gridPlacement is where the items of a grid container are placed, and how
+// many tracks the grid has, implicit tracks included
+type gridPlacement struct {
+	items         []gridItem
+	columns, rows int
+}
+
+// resolveLines resolves the placement of an item along an axis of a grid
+// with explicit tracks. It returns the first track, the number of tracks
+// spanned, and whether the position is definite; an indefinite one is left
+// to auto-placement.
+func resolveLines(p css.GridPlacement, explicit int) (int, int, bool) {
+	line := func(l css.GridLine) int {
+		if l.Line < 0 {
+			return max(0, explicit+1+int(l.Line))
+		}
+		return int(l.Line) - 1
+	}
+	start, end := p.Start, p.End
+	switch {
+	case start.Line != 0 && end.Line != 0:
+		s, e := line(start), line(end)
+		if e < s {
+			s, e = e, s
+		}
+		return s, max(1, e-s), true
+	case start.Line != 0:
+		return line(start), max(1, int(end.Span)), true
+	case end.Line != 0:
+		span := max(1, int(start.Span))
+		return max(0, line(end)-span), span, true
+	}
+	return 0, max(1, int(start.Span), int(end.Span)), false
+}
+
+// placeGridItems places the children of a grid container. Items with a
+// definite row and column are placed first, then those with a definite
+// row in the first columns free, then the others in order of the document,
+// each after the last one placed, moving on to a new row where they don't
+// fit.
+func placeGridItems(tree *LayoutTree, node *LayoutNode) gridPlacement {
+	explicitCols := node.Style.GridTemplateColumns.Len()
+	explicitRows := node.Style.GridTemplateRows.Len()
+
+	var items []gridItem
+	var colDefinite, rowDefinite []bool
+	columns := max(1, explicitCols)
+	for id := node.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		if !inFlow(&tree.Nodes[id]) {
+			continue
+		}
+		s := &tree.Nodes[id].Style
+		col, colSpan, colOK := resolveLines(s.GridColumn, explicitCols)
+		row, rowSpan, rowOK := resolveLines(s.GridRow, explicitRows)
+		items = append(items, gridItem{id: id, col: col, colSpan: colSpan, row: row, rowSpan: rowSpan})
+		colDefinite = append(colDefinite, colOK)
+		rowDefinite = append(rowDefinite, rowOK)
+		if colOK {
+			columns = max(columns, col+colSpan)
+		} else {
+			columns = max(columns, colSpan)
+		}
+	}
+
+	var occupied [][]bool // by row, then column
+	fits := func(row, col, rowSpan, colSpan int) bool {
+		if col+colSpan > columns {
+			return false
+		}
+		for r := row; r < row+rowSpan && r < len(occupied); r++ {
+			for c := col; c < col+colSpan; c++ {
+				if occupied[r][c] {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	place := func(item *gridItem, row, col int) {
+		item.row, item.col = row, col
+		item.rowEnd, item.colEnd = row+item.rowSpan, col+item.colSpan
+		for len(occupied) < item.rowEnd {
+			occupied = append(occupied, make([]bool, columns))
+		}
+		for r := row; r < item.rowEnd; r++ {
+			for c := col; c < item.colEnd; c++ {
+				occupied[r][c] = true
+			}
+		}
+	}
+
+	for i := range items {
+		if rowDefinite[i] && colDefinite[i] {
+			place(&items[i], items[i].row, items[i].col)
+		}
+	}
+	rowCursors := map[int]int{}
+	for i := range items {
+		if rowDefinite[i] && !colDefinite[i] {
+			item := &items[i]
+			col := rowCursors[item.row]
+			for !fits(item.row, col, item.rowSpan, item.colSpan) && col+item.colSpan <= columns {
+				col++
+			}
+			if col+item.colSpan > columns {
+				// Overflow the row rather than add a column for it
+				col = max(0, columns-item.colSpan)
+			}
+			place(item, item.row, col)
+			rowCursors[item.row] = item.colEnd
+		}
+	}
+	row, col := 0, 0
+	for i := range items {
+		if rowDefinite[i] {
+			continue
+		}
+		item := &items[i]
+		if colDefinite[i] {
+			if item.col < col {
+				row++
+			}
+			for !fits(row, item.col, item.rowSpan, item.colSpan) {
+				row++
+			}
+			col = item.col
+		} else {
+			for !fits(row, col, item.rowSpan, item.colSpan) {
+				if col++; col+item.colSpan > columns {
+					row, col = row+1, 0
+				}
+			}
+		}
+		place(item, row, col)
+		col = item.colEnd
+	}
+
+	rows := max(explicitRows, len(occupied))
+	return gridPlacement{items: items, columns: columns, rows: rows}
+}
+
+// gridTracks sizes the tracks of an axis to fill space, which is negative
+// when it is indefinite. Fixed and percentage tracks take their size, auto
+// tracks the largest contribution of the items spanning them alone, and fr
+// tracks share what is left. Without fr tracks, the auto tracks share it.
+// When space is indefinite, an fr track is as large as needed for its
+// items, with the tracks keeping to their ratios.
+func gridTracks(sizes []css.TrackSize, count int, space, gap float32, items []gridItem, span func(gridItem) (int, int), contribution func(LayoutNodeID) float32) []float32 {
+	tracks := make([]float32, count)
+	size := func(i int) css.TrackSize {
+		if i < len(sizes) {
+			return sizes[i]
+		}
+		return css.TrackSize{Kind: css.TrackAuto}
+	}
+	var totalFr float32
+	for i := range tracks {
+		s := size(i)
+		switch {
+		case s.Kind == css.TrackLength:
+			tracks[i] = s.Value
+		case s.Kind == css.TrackPercent && space >= 0:
+			tracks[i] = s.Value / 100 * space
+		case s.Kind == css.TrackFr:
+			totalFr += s.Value
+		}
+	}
+
+	// Auto tracks, and fr tracks of an indefinite grid, grow to the items
+	// spanning only them
+	frSize := float32(0)
+	for _, item := range items {
+		start, n := span(item)
+		if n != 1 {
+			continue
+		}
+		s := size(start)
+		c := contribution(item.id)
+		switch {
+		case s.Kind == css.TrackAuto || s.Kind == css.TrackPercent && space < 0:
+			tracks[start] = max(tracks[start], c)
+		case s.Kind == css.TrackFr && space < 0 && s.Value > 0:
+			frSize = max(frSize, c/s.Value)
+		}
+	}
+
+	free := space - gap*float32(max(0, count-1))
+	for _, t := range tracks {
+		free -= t
+	}
+	switch {
+	case totalFr > 0 && space >= 0:
+		frSize = max(0, free) / max(1, totalFr)
+		fallthrough
+	case totalFr > 0:
+		for i := range tracks {
+			if s := size(i); s.Kind == css.TrackFr {
+				tracks[i] = s.Value * frSize
+			}
+		}
+	case space >= 0 && free > 0:
+		var autos int
+		for i := range tracks {
+			if size(i).Kind == css.TrackAuto {
+				autos++
+			}
+		}
+		for i := range tracks {
+			if autos > 0 && size(i).Kind == css.TrackAuto {
+				tracks[i] += free / float32(autos)
+			}
+		}
+	}
+
+	// An item spanning several tracks grows the auto tracks among them by
+	// what they lack for it
+	for _, item := range items {
+		start, n := span(item)
+		if n == 1 {
+			continue
+		}
+		have := gap * float32(n-1)
+		var autos int
+		for i := start; i < start+n; i++ {
+			have += tracks[i]
+			if size(i).Kind == css.TrackAuto {
+				autos++
+			}
+		}
+		if lack := contribution(item.id) - have; lack > 0 && autos > 0 {
+			for i := start; i < start+n; i++ {
+				if size(i).Kind == css.TrackAuto {
+					tracks[i] += lack / float32(autos)
+				}
+			}
+		}
+	}
+	return tracks
+}
+
+func columnSpan(item gridItem) (int, int) { return item.col, item.colSpan }
+func rowSpan(item gridItem) (int, int)    { return item.row, item.rowSpan }
+
+// gridRows sizes the rows of a grid container, to its height if it has one
+func gridRows(node *LayoutNode, g gridPlacement, heights []float32, tree *LayoutTree) []float32 {
+	space := float32(-1)
+	if h, ok := setHeight(&node.Style); ok {
+		space = max(0, h-node.Style.Padding.Top-node.Style.Padding.Bottom)
+	}
+	var sizes []css.TrackSize
+	if t := node.Style.GridTemplateRows; t != nil {
+		sizes = t.Sizes
+	}
+	_, gap := node.Style.GridGaps()
+	return gridTracks(sizes, g.rows, space, gap, g.items, rowSpan, func(id LayoutNodeID) float32 {
+		child := &tree.Nodes[id]
+		h := heights[id]
+		if set, ok := setHeight(&child.Style); ok {
+			h = set
+		}
+		return child.Style.Margin.Top + h + child.Style.Margin.Bottom
+	})
+}
+
+// gridHeight returns the height of the rows of a grid container, the gaps
+// between them included
+func gridHeight(tree *LayoutTree, node *LayoutNode, heights []float32) float32 {
+	g := placeGridItems(tree, node)
+	_, gap := node.Style.GridGaps()
+	var total float32
+	for i, h := range gridRows(node, g, heights, tree) {
+		if i > 0 {
+			total += gap
+		}
+		total += h
+	}
+	return total
+}
+
+// layoutGrid places the children of a grid container in its content box,
+// starting at (x, y) with width w
+func layoutGrid(tree *LayoutTree, node *LayoutNode, x, y, w float32, heights []float32, trace *Trace) {
+	g := placeGridItems(tree, node)
+	colGap, rowGap := node.Style.GridGaps()
+	var sizes []css.TrackSize
+	if t := node.Style.GridTemplateColumns; t != nil {
+		sizes = t.Sizes
+	}
+	columns := gridTracks(sizes, g.columns, w, colGap, g.items, columnSpan, func(id LayoutNodeID) float32 {
+		return maxContentWidth(tree, id, heights)
+	})
+	rows := gridRows(node, g, heights, tree)
+
+	// The start of each track, and the end of the last
+	lines := func(tracks []float32, start, gap float32) []float32 {
+		at := make([]float32, len(tracks)+1)
+		at[0] = start
+		for i, t := range tracks {
+			at[i+1] = at[i] + t + gap
+		}
+		return at
+	}
+	colAt, rowAt := lines(columns, x, colGap), lines(rows, y, rowGap)
+
+	for _, item := range g.items {
+		child := &tree.Nodes[item.id]
+		area := Rect{
+			X: colAt[item.col],
+			Y: rowAt[item.row],
+			W: colAt[item.colEnd] - colAt[item.col] - colGap,
+			H: rowAt[item.rowEnd] - rowAt[item.row] - rowGap,
+		}
+		if trace != nil {
+			trace.record(child, traceGrid, area, heights)
+		}
+
+		childW := area.W
+		if w, ok := setWidth(&child.Style); ok {
+			childW = w
+		}
+		childH := area.H - child.Style.Margin.Top - child.Style.Margin.Bottom
+		if h, ok := setHeight(&child.Style); ok {
+			childH = h
+		}
+		child.Rect.X = area.X + child.Style.Margin.Left
+		child.Rect.Y = area.Y + child.Style.Margin.Top
+		child.Rect.W = childW - child.Style.Margin.Left - child.Style.Margin.Right
+		child.Rect.H = childH
+	}
+}
+
+// maxContentWidth returns how wide a box is when nothing wraps or is
+// stretched: the widest of its lines of text and its children, or its own
+// width if it has one. The width of a box includes its margins, as a block
+// is sized.
+func maxContentWidth(tree *LayoutTree, id LayoutNodeID, heights []float32) float32 {
+	var widest float32
+	// edges[d] is the padding and margins around the content of the
+	// ancestor at depth d of the node visited, its own included
+	var edges []float32
+	Walk(tree, id, func(node *LayoutNode, depth int) WalkAction {
+		edges = edges[:depth]
+		var around float32
+		if depth > 0 {
+			around = edges[depth-1]
+		}
+		s := &node.Style
+		own := s.Padding.Left + s.Padding.Right + s.Margin.Left + s.Margin.Right
+		switch {
+		case node.Text != "":
+			font := text.FontOf(s)
+			var w float32
+			for line := range strings.SplitSeq(node.Text, "\n") {
+				w = max(w, text.Width(text.ExpandTabs(line, s.TabSize, font), font))
+			}
+			widest = max(widest, around+own+w)
+		case node.Replaced:
+			w := inlineWidth(tree, node, heights, math.MaxFloat32)
+			widest = max(widest, around+w)
+		case s.Width != nil:
+			w, _ := setWidth(s)
+			widest = max(widest, around+w)
+		case s.Display == css.DisplayRuby:
+			widest = max(widest, around+own+rubyWidth(tree, node, heights))
+		case s.Display == css.DisplayTable:
+			widest = max(widest, around+own+tableWidth(tree, node, heights))
+		default:
+			edges = append(edges, around+own)
+			return WalkContinue
+		}
+		return WalkSkipChildren
+	})
+	return widest
+}
@@ -0,0 +1,94 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+// gridRects lays out a grid container of divs and returns the rects of the
+// container and of its items, in order
+func gridRects(t *testing.T, items, style string) (Rect, []Rect) {
+	t.Helper()
+	d, err := dom.ParseString(`<html><body><div id="g">` + items + `</div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 0; margin: 0; } #g div { height: 10px; } #g { display: grid; width: 320px; }`+style)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	container := &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "g"))]
+	var rects []Rect
+	for id := container.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		rects = append(rects, tree.Nodes[id].Rect)
+	}
+	return container.Rect, rects
+}
+
+func TestGridTracks(t *testing.T) {
+	// Fixed and fr columns share the width, with a gap between them; the
+	// items flow into rows as high as their tallest item
+	container, rects := gridRects(t, `<div></div><div></div><div class="tall"></div><div></div>`,
+		`#g { grid-template-columns: 100px 1fr 3fr; gap: 5px 10px; } #g .tall { height: 30px; }`)
+	want := []Rect{
+		{X: 0, Y: 0, W: 100, H: 10},
+		{X: 110, Y: 0, W: 50, H: 10},
+		{X: 170, Y: 0, W: 150, H: 30},
+		{X: 0, Y: 35, W: 100, H: 10},
+	}
+	for i, r := range rects {
+		if r != want[i] {
+			t.Errorf("item %d: expected %v, got %v", i+1, want[i], r)
+		}
+	}
+	if container.H != 45 {
+		t.Errorf("expected the grid to be 45px high, got %v", container.H)
+	}
+
+	// repeat() and auto columns, which stretch to fill the width
+	_, rects = gridRects(t, `<div></div><div></div>`, `#g { grid-template-columns: repeat(2, auto); }`)
+	if rects[0].W != 160 || rects[1].X != 160 {
+		t.Errorf("expected two auto columns of 160px, got %v", rects)
+	}
+}
+
+func TestGridPlacement(t *testing.T) {
+	_, rects := gridRects(t,
+		`<div id="a"></div><div id="b"></div><div id="c"></div><div id="d"></div>`,
+		`#g { grid-template-columns: repeat(4, 80px); grid-template-rows: 20px 20px; }
+		#a { grid-column: 2 / 4; grid-row: 2; }
+		#b { grid-column: span 2; }
+		#c { grid-column: -2; }
+		#d { grid-row: 2; }`)
+	want := []Rect{
+		{X: 80, Y: 20, W: 160, H: 10}, // on the lines it names
+		{X: 0, Y: 0, W: 160, H: 10},   // spanning two columns from the first free
+		{X: 240, Y: 0, W: 80, H: 10},  // in the last column
+		{X: 0, Y: 20, W: 80, H: 10},   // in the first free column of its row
+	}
+	for i, r := range rects {
+		if r != want[i] {
+			t.Errorf("item %d: expected %v, got %v", i+1, want[i], r)
+		}
+	}
+
+	// Items are stretched to their area unless they have a height, and
+	// items past the explicit rows go in implicit rows
+	container, rects := gridRects(t, `<div id="auto"></div><div></div><div></div>`,
+		`#g { grid-template-columns: 1fr 1fr; grid-template-rows: 40px; } #g #auto { height: auto; }`)
+	if rects[0].H != 40 || rects[1].H != 10 {
+		t.Errorf("expected only the item without a height to stretch, got %v", rects)
+	}
+	if rects[2].Y != 40 || container.H != 50 {
+		t.Errorf("expected an implicit row of 10px below the explicit one, got %v in %v", rects[2], container)
+	}
+}
+
+func TestGridAutoColumnsFitContent(t *testing.T) {
+	_, rects := gridRects(t, `<div class="label">Label</div><div></div>`,
+		`#g { grid-template-columns: auto 1fr; } .label { width: 70px; }`)
+	if rects[0].W != 70 || rects[1].X != 70 || rects[1].W != 250 {
+		t.Errorf("expected the auto column to fit its item and the fr column the rest, got %v", rects)
+	}
+}
@@ -0,0 +1,149 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/myuon/penny/dom"
+)
+
+// An image map makes regions of an image into links. An <img> names its map
+// with usemap="#name", and the <map> whose name, or id, matches lists the
+// regions as <area> elements. Each area has a shape and coordinates in
+// pixels of the image, from the top left of the box it is drawn in. The
+// first area containing a point is the one hit there.
+
+// areaShape is the kind of region an <area> covers
+type areaShape uint8
+
+const (
+	areaRect areaShape = iota
+	areaCircle
+	areaPoly
+	areaDefault
+)
+
+// areaShapes maps the values of the shape attribute to shapes, along with
+// the abbreviations browsers accept
+var areaShapes = map[string]areaShape{
+	"rect":      areaRect,
+	"rectangle": areaRect,
+	"circle":    areaCircle,
+	"circ":      areaCircle,
+	"poly":      areaPoly,
+	"polygon":   areaPoly,
+	"default":   areaDefault,
+}
+
+// mapArea is the region of an <area>
+type mapArea struct {
+	shape  areaShape
+	coords []float32
+}
+
+// parseArea reads the shape and coordinates of an <area>. An area without
+// a shape is a rectangle. It returns false for an unknown shape or too few
+// coordinates, which make an area cover nothing.
+func parseArea(area *dom.Node) (mapArea, bool) {
+	a := mapArea{shape: areaRect}
+	if s, ok := area.Attr["shape"]; ok {
+		if a.shape, ok = areaShapes[strings.ToLower(strings.TrimSpace(s))]; !ok {
+			return a, false
+		}
+	}
+	for _, field := range strings.FieldsFunc(area.Attr["coords"], func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+	}) {
+		v, err := strconv.ParseFloat(field, 32)
+		if err != nil {
+			v = 0
+		}
+		a.coords = append(a.coords, float32(v))
+	}
+	switch a.shape {
+	case areaRect:
+		return a, len(a.coords) >= 4
+	case areaCircle:
+		return a, len(a.coords) >= 3 && a.coords[2] > 0
+	case areaPoly:
+		return a, len(a.coords) >= 6
+	}
+	return a, true
+}
+
+// contains reports whether a point of the image is inside an area
+func (a mapArea) contains(x, y float32) bool {
+	c := a.coords
+	switch a.shape {
+	case areaRect:
+		left, right := min(c[0], c[2]), max(c[0], c[2])
+		top, bottom := min(c[1], c[3]), max(c[1], c[3])
+		return x >= left && y >= top && x < right && y < bottom
+	case areaCircle:
+		dx, dy := x-c[0], y-c[1]
+		return dx*dx+dy*dy <= c[2]*c[2]
+	case areaPoly:
+		// Even-odd rule: a ray to the right of the point crosses the edges
+		// of the polygon an odd number of times when it is inside
+		inside := false
+		n := len(c) / 2
+		for i, j := 0, n-1; i < n; j, i = i, i+1 {
+			xi, yi, xj, yj := c[2*i], c[2*i+1], c[2*j], c[2*j+1]
+			if (yi > y) != (yj > y) && x < xi+(y-yi)*(xj-xi)/(yj-yi) {
+				inside = !inside
+			}
+		}
+		return inside
+	}
+	return true
+}
+
+// imageMap returns the <map> an <img> uses, or nil if it has none
+func imageMap(d *dom.DOM, img *dom.Node) *dom.Node {
+	name, ok := strings.CutPrefix(img.Attr["usemap"], "#")
+	if !ok || name == "" {
+		return nil
+	}
+	var found *dom.Node
+	dom.Walk(d, d.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Tag == "map" && (node.Attr["name"] == name || node.Attr["id"] == name) {
+			found = node
+			return dom.WalkStop
+		}
+		return dom.WalkContinue
+	})
+	return found
+}
+
+// AreaAt returns the first <area> of the map an image uses that contains a
+// point of the page, or dom.InvalidNodeID when there is none
+func AreaAt(d *dom.DOM, img *LayoutNode, x, y float32) dom.NodeID {
+	elem := d.GetNode(img.DomNode)
+	if elem == nil || elem.Tag != "img" {
+		return dom.InvalidNodeID
+	}
+	m := imageMap(d, elem)
+	if m == nil {
+		return dom.InvalidNodeID
+	}
+	// The areas cover the image, which is drawn inside the padding
+	x -= img.Rect.X + img.Style.Padding.Left
+	y -= img.Rect.Y + img.Style.Padding.Top
+	w := img.Rect.W - img.Style.Padding.Left - img.Style.Padding.Right
+	h := img.Rect.H - img.Style.Padding.Top - img.Style.Padding.Bottom
+	if x < 0 || y < 0 || x >= w || y >= h {
+		return dom.InvalidNodeID
+	}
+	hit := dom.InvalidNodeID
+	dom.Walk(d, m.ID, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Tag != "area" {
+			return dom.WalkContinue
+		}
+		if a, ok := parseArea(node); ok && a.contains(x, y) {
+			hit = node.ID
+			return dom.WalkStop
+		}
+		return dom.WalkContinue
+	})
+	return hit
+}
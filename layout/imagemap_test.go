@@ -0,0 +1,57 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestAreaAt(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><img id="img" src="a.png" usemap="#m">` +
+		`<map name="m">` +
+		`<area id="rect" shape="rect" coords="50,0,0,50" href="/a">` +
+		`<area id="circle" shape="circ" coords="100, 25, 20" href="/b">` +
+		`<area id="poly" shape="polygon" coords="0,60 60,60 0,100" href="/c">` +
+		`<area id="broken" shape="star" coords="0,0,200,100">` +
+		`<area id="default" shape="default" nohref>` +
+		`</map></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { margin: 0; } img { width: 200px; height: 100px; padding: 10px; }`)
+	sheet.Append(css.UserAgentStylesheet())
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	img := &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "img"))]
+
+	// Coordinates are those of the page, the image's inside the padding
+	tests := []struct {
+		x, y float32
+		want string
+	}{
+		{20, 20, "rect"},
+		{110, 35, "circle"},
+		{135, 35, "default"},
+		{15, 100, "poly"},
+		{65, 100, "default"},
+		{5, 5, ""},
+	}
+	for _, tt := range tests {
+		want := dom.InvalidNodeID
+		if tt.want != "" {
+			want = findElement(t, d, tt.want)
+		}
+		if got := AreaAt(d, img, tt.x, tt.y); got != want {
+			t.Errorf("(%v, %v): expected #%s, got %d", tt.x, tt.y, tt.want, got)
+		}
+	}
+
+	// Areas aren't laid out
+	for i := range tree.Nodes {
+		if d.GetNode(tree.Nodes[i].DomNode).Tag == "area" {
+			t.Errorf("expected areas to have no boxes")
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/text"
+)
+
+// Text is otherwise laid out a node per line, stacked like blocks. A run of
+// sibling text nodes and atomic inline boxes, inline replaced elements such
+// as <img> and inline-blocks, that holds at least one of the latter is laid
+// out in line boxes instead, so icons and buttons sit in the text around
+// them.
+
+// inlineRun returns the end, exclusive, of the run of inline-level siblings
+// starting at first, and whether it holds an atomic inline box
+func inlineRun(tree *LayoutTree, first LayoutNodeID) (LayoutNodeID, bool) {
+	atomic := false
+	id := first
+	for ; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		node := &tree.Nodes[id]
+		if node.Text == "" && !isAtomicInline(node) {
+			break
+		}
+		atomic = atomic || node.Text == ""
+	}
+	return id, atomic
+}
+
+// isAtomicInline reports whether a node is laid out as a single box in a
+// line: an inline replaced element, an inline-block or a ruby
+func isAtomicInline(node *LayoutNode) bool {
+	if !inFlow(node) {
+		return false
+	}
+	switch node.Style.Display {
+	case css.DisplayInline:
+		return node.Replaced
+	case css.DisplayInlineBlock, css.DisplayRuby:
+		return true
+	}
+	return false
+}
+
+// inlineMetrics returns how far a box in a line box reaches above and below
+// the baseline. Text sits on the baseline of its font size, and so do the
+// bases of a ruby; another atomic inline box has the bottom of its margin
+// box on it.
+func inlineMetrics(tree *LayoutTree, node *LayoutNode, heights []float32) (ascent, descent float32) {
+	s := &node.Style
+	if node.Text == "" {
+		outer := s.Margin.Top + heights[node.ID] + s.Margin.Bottom
+		if s.Display == css.DisplayRuby {
+			ascent = s.Margin.Top + rubyAscent(tree, node, heights)
+			return ascent, outer - ascent
+		}
+		return outer, 0
+	}
+	ascent = s.Padding.Top + text.MetricsOf(text.FontOf(s)).Baseline()
+	return ascent, heights[node.ID] - ascent
+}
+
+// inlineWidth returns how wide a box in a line box is, its margins
+// included. An atomic inline box without a width of its own shrinks to fit
+// its content, unwrapped, but is no wider than available.
+func inlineWidth(tree *LayoutTree, node *LayoutNode, heights []float32, available float32) float32 {
+	s := &node.Style
+	if node.Text != "" {
+		font := text.FontOf(s)
+		return s.Padding.Left + text.Width(text.ExpandTabs(node.Text, s.TabSize, font), font) + s.Padding.Right
+	}
+	if w, ok := setWidth(s); ok {
+		return s.Margin.Left + w + s.Margin.Right
+	}
+	own := s.Margin.Left + s.Padding.Left + s.Padding.Right + s.Margin.Right
+	return max(own, min(maxContentWidth(tree, node.ID, heights), available))
+}
+
+// lineBox returns the baseline and the height of a line box holding the
+// boxes from first to end, exclusive. The strut of the block, an empty run
+// of its text, keeps the line from being shorter than its own text would
+// be, which leaves the gap for descenders below an image.
+func lineBox(tree *LayoutTree, first, end LayoutNodeID, strut css.Style, heights []float32) (float32, float32) {
+	ascent := text.MetricsOf(text.FontOf(&strut)).Baseline()
+	descent := LineHeight(strut) - ascent
+	for id := first; id != end; id = tree.Nodes[id].NextSibling {
+		a, d := inlineMetrics(tree, &tree.Nodes[id], heights)
+		ascent, descent = max(ascent, a), max(descent, d)
+	}
+	return ascent, ascent + descent
+}
+
+// runHeight returns the height of a run laid out on a single line, before
+// widths are known
+func runHeight(tree *LayoutTree, parent *LayoutNode, first, end LayoutNodeID, heights []float32) float32 {
+	_, h := lineBox(tree, first, end, parent.Style, heights)
+	return h
+}
+
+// layoutLines positions the boxes of a run from first to end, exclusive, in
+// line boxes of width w starting at (x, y), breaking before a box that
+// doesn't fit, and aligned as the parent's text-align says. It returns the
+// bottom of the last line box.
+func layoutLines(tree *LayoutTree, parent *LayoutNode, first, end LayoutNodeID, x, y, w float32, heights []float32) float32 {
+	for start := first; start != end; {
+		// Fill the line with at least one box
+		stop := start
+		var lineW float32
+		for stop != end {
+			bw := inlineWidth(tree, &tree.Nodes[stop], heights, w)
+			if stop != start && lineW+bw > w {
+				break
+			}
+			lineW += bw
+			stop = tree.Nodes[stop].NextSibling
+		}
+
+		// Align the line. A line that wraps is justified instead by widening
+		// the spaces in its text, but for those ending it, which hang past
+		// its end, so that its words reach to both sides.
+		align := parent.Style.TextAlign
+		cx := x + align.Offset(w-lineW, parent.Style.Direction)
+		var spacing float32
+		if align == css.TextAlignJustify && stop != end {
+			spaces, hanging := lineSpaces(tree, start, stop)
+			if spaces > 0 {
+				spacing = max(w-lineW+hanging, 0) / float32(spaces)
+			}
+		}
+
+		baseline, lineH := lineBox(tree, start, stop, parent.Style, heights)
+		for id := start; id != stop; id = tree.Nodes[id].NextSibling {
+			node := &tree.Nodes[id]
+			ascent, descent := inlineMetrics(tree, node, heights)
+			bw := inlineWidth(tree, node, heights, w)
+			node.WordSpacing = spacing
+			if spacing > 0 {
+				words := node.Text
+				if node.NextSibling == stop {
+					words = strings.TrimRight(words, " ")
+				}
+				bw += spacing * float32(strings.Count(words, " "))
+			}
+			node.Rect.X = cx + node.Style.Margin.Left
+			node.Rect.Y = y + baseline - ascent + node.Style.Margin.Top
+			node.Rect.W = bw - node.Style.Margin.Left - node.Style.Margin.Right
+			node.Rect.H = ascent + descent - node.Style.Margin.Top - node.Style.Margin.Bottom
+			cx += bw
+		}
+		y += lineH
+		start = stop
+	}
+	return y
+}
+
+// lineSpaces returns the number of spaces in the text of a line box from
+// first to stop, exclusive, that justify widens, and how wide the spaces
+// ending the line, which it doesn't, are
+func lineSpaces(tree *LayoutTree, first, stop LayoutNodeID) (int, float32) {
+	spaces := 0
+	var hanging float32
+	for id := first; id != stop; id = tree.Nodes[id].NextSibling {
+		node := &tree.Nodes[id]
+		words := node.Text
+		if node.NextSibling == stop {
+			words = strings.TrimRight(words, " ")
+			if len(words) < len(node.Text) {
+				hanging = text.Width(node.Text[len(words):], text.FontOf(&node.Style))
+			}
+		}
+		spaces += strings.Count(words, " ")
+	}
+	return spaces, hanging
+}
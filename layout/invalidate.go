@@ -0,0 +1,299 @@
+package layout
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+// styleEntry is the cached computed style of a node along with the parent
+// style it inherited from and the units of the document it was resolved
+// in. shown is the style as last laid out, with animations and transitions
+// applied.
+type styleEntry struct {
+	style  css.Style
+	parent css.Style
+	units  css.Units
+	shown  css.Style
+}
+
+// StyleResolver caches computed styles across layout tree builds and
+// recomputes only the elements affected by DOM mutations or stylesheet
+// changes. A node is restyled when it was marked dirty or when the values it
+// inherits from its parent changed. If the stylesheet uses inherit or unset,
+// which can pull in any property of the parent, any change to the parent's
+// style restyles it.
+type StyleResolver struct {
+	dom        *dom.DOM
+	stylesheet *css.Stylesheet
+	scoped     []ScopedStylesheet
+	rules      *ruleIndex
+	units      css.Units
+
+	styles map[dom.NodeID]styleEntry
+	dirty  map[dom.NodeID]bool
+
+	now         time.Duration
+	transitions map[dom.NodeID][]runningTransition
+	animating   bool
+
+	// Restyled is the number of nodes whose style was recomputed by the last
+	// BuildLayoutTree
+	Restyled int
+}
+
+// NewStyleResolver creates a resolver for d and starts observing its
+// mutations
+func NewStyleResolver(d *dom.DOM, stylesheet *css.Stylesheet) *StyleResolver {
+	r := &StyleResolver{
+		dom:         d,
+		stylesheet:  stylesheet,
+		rules:       newRuleIndex(stylesheet, nil),
+		units:       css.DefaultUnits(),
+		styles:      make(map[dom.NodeID]styleEntry),
+		dirty:       make(map[dom.NodeID]bool),
+		transitions: make(map[dom.NodeID][]runningTransition),
+	}
+	d.Observe(r.handleMutation)
+	return r
+}
+
+func (r *StyleResolver) handleMutation(m dom.Mutation) {
+	switch m.Type {
+	case dom.MutationAttribute:
+		// Selectors only look at the tag, class and id of an element and
+		// the attributes of their attribute selectors, and the style
+		// attribute only at the element itself
+		switch {
+		case m.Attr == "class" || m.Attr == "id" || r.rules.attrs[m.Attr]:
+			r.invalidateMatches(m.Target)
+		case m.Attr == "style":
+			r.Invalidate(m.Target)
+		}
+	case dom.MutationChildList:
+		// A reattached subtree may inherit from a different parent; that is
+		// caught by the inherited value check, but its own cache may be stale
+		r.Invalidate(m.Child)
+		switch {
+		case r.rules.hasContext:
+			// Its ancestors and siblings changed
+			r.invalidateSubtree(m.Target)
+		case r.rules.hasStructural && r.dom.GetNode(m.Target) != nil:
+			// Its siblings moved
+			for _, id := range r.dom.GetNode(m.Target).Children {
+				r.Invalidate(id)
+			}
+		}
+	case dom.MutationText:
+		// Text nodes only carry inherited style
+	case dom.MutationState:
+		r.invalidateMatches(m.Target)
+	}
+}
+
+// invalidateMatches marks for restyle the elements whose selector matches
+// may change with those of nodeID: the node itself, and when selectors have
+// combinators, its descendants, its later siblings and theirs
+func (r *StyleResolver) invalidateMatches(nodeID dom.NodeID) {
+	if !r.rules.hasContext {
+		r.Invalidate(nodeID)
+		return
+	}
+	node := r.dom.GetNode(nodeID)
+	parent := r.dom.GetNode(node.Parent)
+	if parent == nil {
+		r.invalidateSubtree(nodeID)
+		return
+	}
+	i := slices.Index(parent.Children, nodeID)
+	for _, sibling := range parent.Children[max(i, 0):] {
+		r.invalidateSubtree(sibling)
+	}
+}
+
+// invalidateSubtree marks a node and all its descendants for restyle
+func (r *StyleResolver) invalidateSubtree(nodeID dom.NodeID) {
+	dom.Walk(r.dom, nodeID, func(node *dom.Node, depth int) dom.WalkAction {
+		r.dirty[node.ID] = true
+		return dom.WalkContinue
+	})
+}
+
+// Invalidate marks a node for restyle. Its descendants are restyled too if
+// that changes what they inherit.
+func (r *StyleResolver) Invalidate(nodeID dom.NodeID) {
+	r.dirty[nodeID] = true
+}
+
+// InvalidateAll marks every node for restyle
+func (r *StyleResolver) InvalidateAll() {
+	r.styles = make(map[dom.NodeID]styleEntry)
+	r.dirty = make(map[dom.NodeID]bool)
+}
+
+// SetStylesheet replaces the stylesheet, invalidating only the elements
+// matched by the selectors of rules that were added, removed or changed.
+// Every element is invalidated when rules were reordered or @keyframes
+// changed.
+func (r *StyleResolver) SetStylesheet(stylesheet *css.Stylesheet) {
+	changed, traced := changedSelectors(r.stylesheet, stylesheet)
+	r.stylesheet = stylesheet
+	r.rules = newRuleIndex(stylesheet, r.scoped)
+
+	if !traced {
+		r.invalidateSubtree(r.dom.Root)
+		return
+	}
+	if len(changed) == 0 {
+		return
+	}
+	// Walking the tree keeps the element stack of each node at hand for
+	// matching
+	var ancestors []*dom.Node
+	dom.Walk(r.dom, r.dom.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		ancestors = ancestors[:depth]
+		if node.Type == dom.NodeTypeElement && matchesSelector(r.dom, node, ancestors, changed) {
+			r.Invalidate(node.ID)
+		}
+		ancestors = append(ancestors, node)
+		return dom.WalkContinue
+	})
+}
+
+// SetViewport sets the size of the viewport that vw and vh lengths are
+// relative to, which is that of the default media context until it is set.
+// Nodes whose styles were resolved in another viewport are restyled by the
+// next BuildLayoutTree.
+func (r *StyleResolver) SetViewport(w, h float32) {
+	r.units.ViewportWidth, r.units.ViewportHeight = w, h
+}
+
+// BuildLayoutTree creates a layout tree, reusing the cached style of every
+// node that wasn't invalidated since the last build
+func (r *StyleResolver) BuildLayoutTree() *LayoutTree {
+	r.Restyled = 0
+	r.animating = false
+	tree := buildLayoutTree(r.dom, r.units, r.styleOf)
+	r.dirty = make(map[dom.NodeID]bool)
+	return tree
+}
+
+func (r *StyleResolver) styleOf(node *dom.Node, ancestors []*dom.Node, parentStyle css.Style, units css.Units) css.Style {
+	entry, ok := r.styles[node.ID]
+	restyle := !ok || r.dirty[node.ID] || entry.units != units || !r.sameParent(entry.parent, parentStyle)
+	if restyle {
+		r.Restyled++
+		style := computeStyle(r.dom, node, ancestors, parentStyle, r.rules, units)
+		if ok {
+			r.startTransitions(node.ID, &entry.shown, &style)
+		}
+		entry.style, entry.parent, entry.units = style, parentStyle, units
+	}
+
+	// Only styles that are or were just animated need storing again
+	shown := entry.style
+	units.FontSize = shown.FontSize
+	animated := r.animate(node.ID, &shown, units)
+	if restyle || animated || !entry.shown.Equal(entry.style) {
+		entry.shown = shown
+		r.styles[node.ID] = entry
+	}
+	return shown
+}
+
+// sameParent reports whether a style computed against the parent style
+// before can be reused with after
+func (r *StyleResolver) sameParent(before, after css.Style) bool {
+	if r.rules.readsParent {
+		return before.Equal(after)
+	}
+	return css.InheritedEqual(before, after)
+}
+
+// changedSelectors returns the selectors of the rules that differ between
+// two stylesheets, or false if the change reaches elements those don't
+// match: when rules in both apply in another order, or @keyframes changed
+func changedSelectors(before, after *css.Stylesheet) ([]css.Selector, bool) {
+	count := make(map[string]int)
+	selectors := make(map[string][]css.Selector)
+
+	keys := func(sheet *css.Stylesheet, delta int) []string {
+		if sheet == nil {
+			return nil
+		}
+		keys := make([]string, len(sheet.Rules))
+		for i, rule := range sheet.Rules {
+			keys[i] = ruleKey(rule)
+			count[keys[i]] += delta
+			selectors[keys[i]] = rule.Selectors
+		}
+		return keys
+	}
+	beforeKeys := keys(before, -1)
+	afterKeys := keys(after, 1)
+
+	// Of rules with the same specificity, the later one wins
+	differs := func(key string) bool { return count[key] != 0 }
+	if !slices.Equal(slices.DeleteFunc(beforeKeys, differs), slices.DeleteFunc(afterKeys, differs)) {
+		return nil, false
+	}
+	if keyframesKey(before) != keyframesKey(after) {
+		return nil, false
+	}
+
+	var changed []css.Selector
+	for key, n := range count {
+		if n != 0 {
+			changed = append(changed, selectors[key]...)
+		}
+	}
+	// A pseudo-element rule styles the elements it belongs to
+	for i := range changed {
+		if _, ok := pseudoElements[changed[i].PseudoElement]; ok {
+			changed[i].PseudoElement = ""
+		}
+	}
+	return changed, true
+}
+
+// ruleKey identifies a rule by everything but where it is in its source, so
+// that a rule that only moved is the same rule
+func ruleKey(rule css.Rule) string {
+	rule.Pos = css.Position{}
+	rule.Declarations = withoutPositions(rule.Declarations)
+	return fmt.Sprintf("%v", rule)
+}
+
+// keyframesKey identifies the @keyframes rules of a stylesheet by their
+// names and frames
+func keyframesKey(sheet *css.Stylesheet) string {
+	if sheet == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(sheet.Keyframes)) {
+		fmt.Fprintf(&sb, "%s{", name)
+		for _, frame := range sheet.Keyframes[name].Frames {
+			fmt.Fprintf(&sb, "%v %v", frame.Offset, withoutPositions(frame.Declarations))
+			if frame.Timing != nil {
+				fmt.Fprintf(&sb, " %v", *frame.Timing)
+			}
+			sb.WriteByte(';')
+		}
+		sb.WriteByte('}')
+	}
+	return sb.String()
+}
+
+func withoutPositions(decls []css.Declaration) []css.Declaration {
+	decls = slices.Clone(decls)
+	for i := range decls {
+		decls[i].Pos = css.Position{}
+	}
+	return decls
+}
@@ -0,0 +1,132 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+const invalidateHTML = `<html><body><div id="a" class="box"><p>one</p></div><div id="b"><p>two</p></div><span>three</span></body></html>`
+
+func findElement(t *testing.T, d *dom.DOM, id string) dom.NodeID {
+	t.Helper()
+	for _, node := range d.Nodes {
+		if node.Type == dom.NodeTypeElement && node.Attr["id"] == id {
+			return node.ID
+		}
+	}
+	t.Fatalf("element #%s not found", id)
+	return dom.InvalidNodeID
+}
+
+func mustParseCSS(t *testing.T, input string) *css.Stylesheet {
+	t.Helper()
+	sheet, err := css.Parse(input)
+	if err != nil {
+		t.Fatalf("css parse error: %v", err)
+	}
+	return sheet
+}
+
+func TestStyleResolverInvalidation(t *testing.T) {
+	d, err := dom.ParseString(invalidateHTML)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, ".box { width: 100px; } span { color: red; } #b { height: 10px; }")
+
+	r := NewStyleResolver(d, sheet)
+	first := r.BuildLayoutTree()
+	// html is styled too, for body to inherit from
+	total := r.Restyled
+	if total != len(first.Nodes)+1 {
+		t.Errorf("expected the first build to style all %d nodes and html, got %d", len(first.Nodes), total)
+	}
+
+	// Nothing changed
+	r.BuildLayoutTree()
+	if r.Restyled != 0 {
+		t.Errorf("expected no restyle without changes, got %d", r.Restyled)
+	}
+
+	// Changing the class of #b restyles only #b
+	b := findElement(t, d, "b")
+	d.SetAttribute(b, "class", "box")
+	tree := r.BuildLayoutTree()
+	if r.Restyled != 1 {
+		t.Errorf("expected 1 restyle after a class change, got %d", r.Restyled)
+	}
+	assertSameStyles(t, tree, BuildLayoutTree(d, sheet))
+
+	// Changing an inherited property restyles the element and its subtree
+	sheet = mustParseCSS(t, ".box { width: 100px; color: blue; } span { color: red; } #b { height: 10px; }")
+	r.SetStylesheet(sheet)
+	tree = r.BuildLayoutTree()
+	// #a, #b, their <p> and text children
+	if r.Restyled != 6 {
+		t.Errorf("expected 6 restyles after a rule change, got %d", r.Restyled)
+	}
+	assertSameStyles(t, tree, BuildLayoutTree(d, sheet))
+
+	// Unrelated attributes don't restyle
+	d.SetAttribute(b, "title", "hello")
+	r.BuildLayoutTree()
+	if r.Restyled != 0 {
+		t.Errorf("expected no restyle after an unrelated attribute change, got %d", r.Restyled)
+	}
+
+	// A new viewport restyles everything, as vw and vh may be relative to it
+	r.SetViewport(400, 300)
+	tree = r.BuildLayoutTree()
+	if r.Restyled != total {
+		t.Errorf("expected %d restyles after a viewport change, got %d", total, r.Restyled)
+	}
+	assertSameStyles(t, tree, BuildLayoutTreeIn(d, sheet, 400, 300, 0))
+}
+
+func TestStyleResolverReorderedRules(t *testing.T) {
+	d, err := dom.ParseString(invalidateHTML)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, "body p { color: red; } div p { color: blue; } @keyframes fade { to { opacity: 0; } }")
+	r := NewStyleResolver(d, sheet)
+	total := len(r.BuildLayoutTree().Nodes) + 1
+
+	// The rules match the same elements, but the other one wins
+	sheet = mustParseCSS(t, "div p { color: blue; } body p { color: red; } @keyframes fade { to { opacity: 0; } }")
+	r.SetStylesheet(sheet)
+	tree := r.BuildLayoutTree()
+	if r.Restyled != total {
+		t.Errorf("expected %d restyles after reordering rules, got %d", total, r.Restyled)
+	}
+	assertSameStyles(t, tree, BuildLayoutTree(d, sheet))
+
+	// Moving a rule in its source changes nothing
+	r.SetStylesheet(mustParseCSS(t, "\n\ndiv p { color: blue; }  body p { color: red; } @keyframes fade { to { opacity: 0; } }"))
+	r.BuildLayoutTree()
+	if r.Restyled != 0 {
+		t.Errorf("expected no restyle after moving rules, got %d", r.Restyled)
+	}
+
+	r.SetStylesheet(mustParseCSS(t, "div p { color: blue; } body p { color: red; } @keyframes fade { to { opacity: 0.5; } }"))
+	r.BuildLayoutTree()
+	if r.Restyled != total {
+		t.Errorf("expected %d restyles after changing keyframes, got %d", total, r.Restyled)
+	}
+}
+
+func assertSameStyles(t *testing.T, got, want *LayoutTree) {
+	t.Helper()
+	if len(got.Nodes) != len(want.Nodes) {
+		t.Fatalf("expected %d layout nodes, got %d", len(want.Nodes), len(got.Nodes))
+	}
+	for i := range want.Nodes {
+		g, w := got.Nodes[i].Style, want.Nodes[i].Style
+		if g.Display != w.Display || g.Color != w.Color || g.FontSize != w.FontSize ||
+			(g.Width == nil) != (w.Width == nil) || (g.Height == nil) != (w.Height == nil) {
+			t.Errorf("node %d: style differs from a full rebuild", i)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package layout
+
+import (
+	"strconv"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/text"
+)
+
+// ListMarker is the marker of a list item, such as a bullet or a number:
+// its text, the space after it included, and where it is drawn once laid
+// out. An outside marker is beside the first line of the item, ending where
+// the item's box starts. An inside marker starts the first text of the
+// item instead, and is only drawn on its own, at the start of the item's
+// content box, where the item has no text.
+type ListMarker struct {
+	Text string
+	Rect Rect
+}
+
+// listOrdinals counts the items of each list as they are built, by the
+// layout node of the list
+type listOrdinals map[LayoutNodeID]int
+
+// next returns the number of a list item: its value attribute, or one more
+// than that of the item before it. The first item of a list is numbered by
+// the list's start attribute, 1 by default.
+func (o listOrdinals) next(d *dom.DOM, item *dom.Node, list LayoutNodeID) int {
+	n, ok := o[list]
+	if !ok {
+		n = 1
+		if parent := d.GetNode(item.Parent); parent != nil {
+			if start, err := strconv.Atoi(parent.Attr["start"]); err == nil {
+				n = start
+			}
+		}
+	}
+	if value, err := strconv.Atoi(item.Attr["value"]); err == nil {
+		n = value
+	}
+	o[list] = n + 1
+	return n
+}
+
+// passListMarkers moves the inside marker of each list item to the start
+// of the first text inside it
+func passListMarkers(tree *LayoutTree) {
+	for i := range tree.Nodes {
+		item := &tree.Nodes[i]
+		if item.Marker.Text == "" || item.Style.ListStylePosition != css.ListStyleInside {
+			continue
+		}
+		Walk(tree, item.ID, func(node *LayoutNode, depth int) WalkAction {
+			if node.Text == "" {
+				return WalkContinue
+			}
+			node.Text = item.Marker.Text + node.Text
+			item.Marker.Text = ""
+			return WalkStop
+		})
+	}
+}
+
+// placeMarkers places the marker of each list item, on the baseline of the
+// first text inside it or at the top of its content box
+func placeMarkers(tree *LayoutTree) {
+	for i := range tree.Nodes {
+		item := &tree.Nodes[i]
+		if item.Marker.Text == "" {
+			continue
+		}
+		font := text.FontOf(&item.Style)
+		w := text.Width(item.Marker.Text, font)
+		r := Rect{X: item.Rect.X - w, Y: item.Rect.Y + item.Style.Padding.Top, W: w, H: LineHeight(item.Style)}
+		if item.Style.ListStylePosition == css.ListStyleInside {
+			r.X = item.Rect.X + item.Style.Padding.Left
+		}
+		Walk(tree, item.ID, func(node *LayoutNode, depth int) WalkAction {
+			if node.Text == "" {
+				return WalkContinue
+			}
+			baseline := node.Rect.Y + node.Style.Padding.Top + text.MetricsOf(text.FontOf(&node.Style)).Baseline()
+			r.Y = baseline - text.MetricsOf(font).Baseline()
+			return WalkStop
+		})
+		item.Marker.Rect = r
+	}
+}
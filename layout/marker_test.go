@@ -0,0 +1,59 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestListMarkers(t *testing.T) {
+	d, err := dom.ParseString(`<html><body>` +
+		`<ul id="ul"><li id="a">a</li><li id="b">b<ul><li id="nested">c</li></ul></li></ul>` +
+		`<ol start="3"><li id="three">x</li><li id="seven" value="7">y</li><li id="eight">z</li></ol>` +
+		`<ol style="list-style-position: inside"><li id="inside">item</li></ol>` +
+		`</body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { margin: 0; }`)
+	sheet.Append(css.UserAgentStylesheet())
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	node := func(id string) *LayoutNode {
+		return &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))]
+	}
+	for id, want := range map[string]string{
+		"a": "• ", "nested": "◦ ", "three": "3. ", "seven": "7. ", "eight": "8. ", "inside": "",
+	} {
+		if got := node(id).Marker.Text; got != want {
+			t.Errorf("#%s: expected marker %q, got %q", id, want, got)
+		}
+	}
+
+	// Lists are indented, and an outside marker ends where its item starts
+	a := node("a")
+	if a.Rect.X != 40 {
+		t.Errorf("expected the item indented by 40px, got %v", a.Rect)
+	}
+	if m := a.Marker.Rect; m.X+m.W != a.Rect.X || m.W <= 0 || m.Y != a.Rect.Y {
+		t.Errorf("expected the marker beside the item, got %v for %v", m, a.Rect)
+	}
+	if nested := node("nested"); nested.Rect.X != 80 {
+		t.Errorf("expected a nested list indented again, got %v", nested.Rect)
+	}
+
+	// An inside marker starts the item's text
+	found := false
+	Walk(tree, node("inside").ID, func(n *LayoutNode, depth int) WalkAction {
+		if n.Text != "" {
+			found = n.Text == "1. item"
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	if !found {
+		t.Error("expected the inside marker to start the item's text")
+	}
+}
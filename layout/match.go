@@ -0,0 +1,268 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+// compound is a compound of a selector as the matcher uses it: the tag, ID,
+// classes and attributes the element must have, where it must be among its
+// siblings, the state its pseudo-classes require, and the selectors its
+// logical pseudo-classes match it against. An empty tag matches any
+// element.
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []css.AttrSelector
+	nths    []css.Nth
+	state   dom.ElementState
+	lists   []selectorList
+}
+
+// selectorList is a logical pseudo-class of a compound. The element must
+// match one of its selectors, or none of them for :not().
+type selectorList struct {
+	not       bool
+	selectors []compiledSelector
+}
+
+// compiledSelector is a selector of a selectorList, compiled
+type compiledSelector struct {
+	subject compound
+	context []relative
+}
+
+// relative is a compound of a selector's context with its combinator
+type relative struct {
+	combinator css.Combinator
+	compound
+}
+
+// compileSelector turns a selector into its subject compound and context.
+// It reports false for selectors penny can't match, such as those with
+// pseudo-elements or pseudo-classes it doesn't know.
+func compileSelector(sel css.Selector) (compound, []relative, bool) {
+	subject, ok := compileCompound(sel)
+	if !ok {
+		return compound{}, nil, false
+	}
+	var context []relative
+	for _, rel := range sel.Context {
+		c, ok := compileCompound(rel.Selector)
+		if !ok {
+			return compound{}, nil, false
+		}
+		context = append(context, relative{rel.Combinator, c})
+	}
+	return subject, context, true
+}
+
+func compileCompound(sel css.Selector) (compound, bool) {
+	state, ok := selectorState(sel)
+	tag := sel.Tag
+	if tag == "*" {
+		tag = ""
+	}
+	c := compound{tag, sel.ID, sel.Classes, sel.Attrs, sel.Nths, state, nil}
+	for _, l := range sel.Lists {
+		list, listOK := compileList(l)
+		c.lists = append(c.lists, list)
+		ok = ok && listOK
+	}
+	return c, ok
+}
+
+// compileList compiles the selectors of a logical pseudo-class. Those
+// penny can't match are dropped from an :is() or :where(), which then
+// matches what the rest do, but make a :not() unmatchable.
+func compileList(l css.SelectorList) (selectorList, bool) {
+	list := selectorList{not: l.Kind == css.ListNot}
+	for _, sel := range l.Selectors {
+		subject, context, ok := compileSelector(sel)
+		if !ok {
+			if list.not {
+				return list, false
+			}
+			continue
+		}
+		list.selectors = append(list.selectors, compiledSelector{subject, context})
+	}
+	return list, true
+}
+
+// matchesCompound reports whether an element matches a compound
+func matchesCompound(d *dom.DOM, node *dom.Node, c compound) bool {
+	if node.Type != dom.NodeTypeElement || node.State&c.state != c.state {
+		return false
+	}
+	if c.tag != "" && node.Tag != c.tag {
+		return false
+	}
+	if c.id != "" && node.Attr["id"] != c.id {
+		return false
+	}
+	for _, class := range c.classes {
+		if !hasClass(node.Attr["class"], class) {
+			return false
+		}
+	}
+	for _, attr := range c.attrs {
+		if value, ok := node.Attr[attr.Name]; !ok || !attr.Matches(value) {
+			return false
+		}
+	}
+	for _, nth := range c.nths {
+		if !matchesNth(d, node, nth) {
+			return false
+		}
+	}
+	for _, list := range c.lists {
+		if matchesList(d, node, list) == list.not {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesList reports whether any selector of a logical pseudo-class
+// matches an element. The context of its selectors matches around the
+// element in the whole document.
+func matchesList(d *dom.DOM, node *dom.Node, list selectorList) bool {
+	var ancestors []*dom.Node
+	found := false
+	for _, sel := range list.selectors {
+		if !matchesCompound(d, node, sel.subject) {
+			continue
+		}
+		if len(sel.context) > 0 && !found {
+			ancestors, found = ancestorsOf(d, node.ID), true
+		}
+		if matchesContext(d, node, ancestors, sel.context) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNth reports whether an element is where a structural pseudo-class
+// wants it among the elements that are its siblings. The root element is
+// the only one of its siblings.
+func matchesNth(d *dom.DOM, node *dom.Node, nth css.Nth) bool {
+	siblings := []dom.NodeID{node.ID}
+	if parent := d.GetNode(node.Parent); parent != nil {
+		siblings = parent.Children
+	}
+	pos, count := 0, 0
+	for _, id := range siblings {
+		sibling := d.GetNode(id)
+		if sibling == nil || sibling.Type != dom.NodeTypeElement || nth.OfType && sibling.Tag != node.Tag {
+			continue
+		}
+		count++
+		if id == node.ID {
+			pos = count
+		}
+	}
+	if nth.Only {
+		return count == 1
+	}
+	if nth.FromEnd {
+		pos = count - pos + 1
+	}
+	return nth.Matches(pos)
+}
+
+// hasClass reports whether a class attribute lists class
+func hasClass(attr, class string) bool {
+	if attr == class {
+		return true
+	}
+	for c := range strings.FieldsSeq(attr) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesContext reports whether the context of a selector matches around
+// node, given its ancestors outermost first. Ancestors are walked from the
+// element outwards, and siblings looked up in the children of its parent.
+func matchesContext(d *dom.DOM, node *dom.Node, ancestors []*dom.Node, context []relative) bool {
+	if len(context) == 0 {
+		return true
+	}
+	rel, rest := context[0], context[1:]
+
+	switch rel.combinator {
+	case css.CombinatorChild:
+		n := len(ancestors)
+		return n > 0 && matchesCompound(d, ancestors[n-1], rel.compound) &&
+			matchesContext(d, ancestors[n-1], ancestors[:n-1], rest)
+	case css.CombinatorDescendant:
+		for i := len(ancestors) - 1; i >= 0; i-- {
+			if matchesCompound(d, ancestors[i], rel.compound) && matchesContext(d, ancestors[i], ancestors[:i], rest) {
+				return true
+			}
+		}
+	case css.CombinatorNextSibling:
+		prev := previousElement(d, node, ancestors)
+		return prev != nil && matchesCompound(d, prev, rel.compound) && matchesContext(d, prev, ancestors, rest)
+	case css.CombinatorSubsequentSibling:
+		// The siblings are walked back from node's index, found once, since
+		// a long list of siblings would otherwise be searched again for
+		// every one of them
+		siblings, i := siblingsOf(node, ancestors)
+		for i--; i >= 0; i-- {
+			prev := d.GetNode(siblings[i])
+			if prev == nil || prev.Type != dom.NodeTypeElement {
+				continue
+			}
+			if matchesCompound(d, prev, rel.compound) && matchesContext(d, prev, ancestors, rest) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// siblingsOf returns the children of node's parent, with node's index among
+// them, or no siblings for the root
+func siblingsOf(node *dom.Node, ancestors []*dom.Node) ([]dom.NodeID, int) {
+	if len(ancestors) == 0 {
+		return nil, 0
+	}
+	children := ancestors[len(ancestors)-1].Children
+	i := len(children) - 1
+	for i >= 0 && children[i] != node.ID {
+		i--
+	}
+	return children, i
+}
+
+// previousElement returns the element sibling before node, or nil if it is
+// the first
+func previousElement(d *dom.DOM, node *dom.Node, ancestors []*dom.Node) *dom.Node {
+	siblings, i := siblingsOf(node, ancestors)
+	for i--; i >= 0; i-- {
+		if sibling := d.GetNode(siblings[i]); sibling != nil && sibling.Type == dom.NodeTypeElement {
+			return sibling
+		}
+	}
+	return nil
+}
+
+// matchesSelector reports whether any of selectors matches node, given its
+// ancestors outermost first
+func matchesSelector(d *dom.DOM, node *dom.Node, ancestors []*dom.Node, selectors []css.Selector) bool {
+	for _, sel := range selectors {
+		subject, context, ok := compileSelector(sel)
+		if ok && matchesCompound(d, node, subject) && matchesContext(d, node, ancestors, context) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,323 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestCombinators(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div class="card"><h1 id="t">title</h1><p id="p1">one</p>` +
+		`<section><p id="p2">two</p></section><p id="p3">three</p></div><p id="p4">four</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		.card p { width: 10px; }
+		.card > p { height: 20px; }
+		h1 + p { margin-top: 3px; }
+		h1 ~ p { margin-bottom: 4px; }
+		body > .card > h1 { padding-top: 5px; }
+		section p { padding-left: 6px; }
+		div * p { padding-right: 7px; }`)
+	tree := BuildLayoutTree(d, sheet)
+	style := func(id string) css.Style {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Style
+	}
+
+	tests := []struct {
+		id                 string
+		width, height      float32
+		marginTop, marginB float32
+		paddingL, paddingR float32
+	}{
+		{"p1", 10, 20, 3, 4, 0, 0},
+		{"p2", 10, 0, 0, 0, 6, 7},
+		{"p3", 10, 20, 0, 4, 0, 0},
+		{"p4", 0, 0, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		s := style(tt.id)
+		var width, height float32
+		if s.Width != nil {
+			width = *s.Width
+		}
+		if s.Height != nil {
+			height = *s.Height
+		}
+		if width != tt.width || height != tt.height || s.Margin.Top != tt.marginTop || s.Margin.Bottom != tt.marginB ||
+			s.Padding.Left != tt.paddingL || s.Padding.Right != tt.paddingR {
+			t.Errorf("#%s: expected %v %v %v %v %v %v, got %v %v %+v %+v", tt.id,
+				tt.width, tt.height, tt.marginTop, tt.marginB, tt.paddingL, tt.paddingR, width, height, s.Margin, s.Padding)
+		}
+	}
+	if s := style("t"); s.Padding.Top != 5 {
+		t.Errorf("expected the heading's padding of 5, got %v", s.Padding.Top)
+	}
+}
+
+func TestSubsequentSiblingsOfManyElements(t *testing.T) {
+	// Every <p> looks back over all the siblings before it for an <h2>; if
+	// each step searched the children again this would take minutes
+	d, err := dom.ParseString("<html><body><h1>a</h1>" + strings.Repeat("<p></p>", 5000) + "</body></html>")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `h2 ~ p { margin-top: 1px; } h1 ~ p ~ p { margin-bottom: 2px; }`)
+	tree := BuildLayoutTree(d, sheet)
+	paragraphs := 0
+	for _, node := range tree.Nodes {
+		if n := d.GetNode(node.DomNode); n != nil && n.Tag == "p" {
+			if node.Style.Margin.Top != 0 || (node.Style.Margin.Bottom == 2) == (paragraphs == 0) {
+				t.Fatalf("paragraph %d: unexpected margins %+v", paragraphs, node.Style.Margin)
+			}
+			paragraphs++
+		}
+	}
+	if paragraphs != 5000 {
+		t.Errorf("expected 5000 paragraphs, got %d", paragraphs)
+	}
+}
+
+func TestCombinatorInvalidation(t *testing.T) {
+	d, err := dom.ParseString(invalidateHTML)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, ".box p { width: 10px; } .box + span { height: 5px; }")
+	r := NewStyleResolver(d, sheet)
+	r.BuildLayoutTree()
+
+	// Making #b a box restyles the paragraph inside it and the span after it
+	d.SetAttribute(findElement(t, d, "b"), "class", "box")
+	tree := r.BuildLayoutTree()
+	assertSameStyles(t, tree, BuildLayoutTree(d, sheet))
+	span := tree.Nodes[tree.Nodes[tree.Root].LastChild]
+	if span.Style.Height == nil || *span.Style.Height != 5 {
+		t.Errorf("expected the span after the new box to be 5px high, got %v", span.Style.Height)
+	}
+}
+
+func TestCompoundSelectors(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="a" class="box wide">one</div><div class="box">two</div>` +
+		`<p class="wide box">three</p><p id="b">four</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		.box { width: 10px; }
+		div.box { height: 20px; }
+		.box.wide { margin-top: 3px; }
+		div#a.wide { padding-top: 4px; }
+		p#b { padding-left: 5px; }
+		div#b { padding-right: 6px; }`)
+	tree := BuildLayoutTree(d, sheet)
+
+	var styles []css.Style
+	for i := range tree.Nodes {
+		if n := d.GetNode(tree.Nodes[i].DomNode); n != nil && (n.Tag == "div" || n.Tag == "p") {
+			styles = append(styles, tree.Nodes[i].Style)
+		}
+	}
+	if len(styles) != 4 {
+		t.Fatalf("expected 4 elements, got %d", len(styles))
+	}
+	height := func(s css.Style) float32 {
+		if s.Height == nil {
+			return 0
+		}
+		return *s.Height
+	}
+	tests := []struct {
+		width, height, marginTop, paddingTop, paddingLeft float32
+	}{
+		{10, 20, 3, 4, 0},
+		{10, 20, 0, 0, 0},
+		{10, 0, 3, 0, 0},
+		{0, 0, 0, 0, 5},
+	}
+	for i, tt := range tests {
+		s := styles[i]
+		var width float32
+		if s.Width != nil {
+			width = *s.Width
+		}
+		if width != tt.width || height(s) != tt.height || s.Margin.Top != tt.marginTop ||
+			s.Padding.Top != tt.paddingTop || s.Padding.Left != tt.paddingLeft || s.Padding.Right != 0 {
+			t.Errorf("element %d: expected %+v, got width %v height %v margin %+v padding %+v",
+				i, tt, width, height(s), s.Margin, s.Padding)
+		}
+	}
+}
+
+func TestAttributeSelectors(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><form><input id="text" type="text"><input id="box" type="checkbox" required>` +
+		`<a id="ext" href="https://example.com/a.pdf">x</a><a id="local" href="/b">y</a></form></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		input[type="text"] { width: 10px; }
+		[required] { height: 20px; }
+		a[href^="https:"][href$=".pdf"] { margin-top: 3px; }
+		a[href*=exam] { padding-top: 4px; }
+		form [type=checkbox] { padding-left: 5px; }
+		input[type=text i] { padding-right: 6px; }`)
+	r := NewStyleResolver(d, sheet)
+	tree := r.BuildLayoutTree()
+	style := func(id string) css.Style {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Style
+	}
+
+	if s := style("text"); s.Width == nil || *s.Width != 10 || s.Height != nil || s.Padding.Right != 0 {
+		t.Errorf("expected only input[type=text] to match #text, got %+v", s)
+	}
+	if s := style("box"); s.Height == nil || *s.Height != 20 || s.Padding.Left != 5 || s.Width != nil {
+		t.Errorf("expected [required] and [type=checkbox] to match #box, got %+v", s)
+	}
+	if s := style("ext"); s.Margin.Top != 3 || s.Padding.Top != 4 {
+		t.Errorf("expected the link to the PDF to match, got margin %+v padding %+v", s.Margin, s.Padding)
+	}
+	if s := style("local"); s.Margin.Top != 0 || s.Padding.Top != 0 {
+		t.Errorf("expected the local link not to match, got margin %+v padding %+v", s.Margin, s.Padding)
+	}
+
+	// Changing an attribute a selector looks at restyles the element
+	d.SetAttribute(findElement(t, d, "local"), "href", "https://example.com/c.pdf")
+	tree = r.BuildLayoutTree()
+	assertSameStyles(t, tree, BuildLayoutTree(d, sheet))
+	if s := style("local"); s.Margin.Top != 3 {
+		t.Errorf("expected the changed link to match, got margin %+v", s.Margin)
+	}
+}
+
+func TestStructuralSelectors(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><ul id="list"><li id="a">a</li><li id="b">b</li><li id="c">c</li></ul>` +
+		`<div id="mixed"><h2 id="h">h</h2><p id="p1">one</p><p id="p2">two</p></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		li:first-child { width: 10px; }
+		li:last-child { height: 20px; }
+		li:nth-child(odd) { margin-top: 3px; }
+		li:nth-last-child(2) { margin-bottom: 4px; }
+		p:first-of-type { padding-top: 5px; }
+		p:first-child { padding-left: 6px; }
+		h2:only-of-type { padding-right: 7px; }
+		ul > :nth-child(2n) { padding-bottom: 8px; }`)
+	r := NewStyleResolver(d, sheet)
+	tree := r.BuildLayoutTree()
+	style := func(id string) css.Style {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Style
+	}
+	size := func(v *float32) float32 {
+		if v == nil {
+			return 0
+		}
+		return *v
+	}
+
+	tests := []struct {
+		id                   string
+		width, height        float32
+		marginTop, marginBot float32
+		padding              css.Edges
+	}{
+		{"a", 10, 0, 3, 0, css.Edges{}},
+		{"b", 0, 0, 0, 4, css.Edges{Bottom: 8}},
+		{"c", 0, 20, 3, 0, css.Edges{}},
+		// Only the elements count, and of-type only those of the same tag
+		{"h", 0, 0, 0, 0, css.Edges{Right: 7}},
+		{"p1", 0, 0, 0, 0, css.Edges{Top: 5}},
+		{"p2", 0, 0, 0, 0, css.Edges{}},
+	}
+	for _, tt := range tests {
+		s := style(tt.id)
+		if size(s.Width) != tt.width || size(s.Height) != tt.height || s.Margin.Top != tt.marginTop ||
+			s.Margin.Bottom != tt.marginBot || s.Padding != tt.padding {
+			t.Errorf("#%s: expected %+v, got width %v height %v margin %+v padding %+v",
+				tt.id, tt, size(s.Width), size(s.Height), s.Margin, s.Padding)
+		}
+	}
+
+	// Adding an item moves the last child, and restyles its siblings
+	li := d.CreateElement("li")
+	d.AppendChild(findElement(t, d, "list"), li)
+	tree = r.BuildLayoutTree()
+	assertSameStyles(t, tree, BuildLayoutTree(d, sheet))
+	if s := style("c"); s.Height != nil || s.Margin.Bottom != 4 {
+		t.Errorf("expected #c to be second to last, got height %v margin %+v", size(s.Height), s.Margin)
+	}
+}
+
+func TestLogicalSelectors(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="theme"><nav><a id="nav" class="x">n</a></nav>` +
+		`<p id="p" class="note">p</p><h2 id="h">h</h2><span id="s" class="x">s</span></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		:is(h1, h2, .note) { width: 10px; }
+		span:not(.x, #y) { height: 5px; }
+		a:not(nav > *) { height: 6px; }
+		:is(nav a, span):not(.y) { margin-top: 3px; }
+		:where(#p) { margin-bottom: 4px; }
+		p { margin-bottom: 1px; }
+		:is(#p) { padding-top: 2px; }
+		.note { padding-top: 7px; }
+		:is(.dark *) { padding-left: 8px; }
+		:is(p!, h2) { padding-right: 9px; }
+		:not(p!, h2) { padding-bottom: 9px; }`)
+	r := NewStyleResolver(d, sheet)
+	tree := r.BuildLayoutTree()
+	style := func(id string) css.Style {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Style
+	}
+	size := func(v *float32) float32 {
+		if v == nil {
+			return 0
+		}
+		return *v
+	}
+
+	tests := []struct {
+		id                   string
+		width, height        float32
+		marginTop, marginBot float32
+		padding              css.Edges
+	}{
+		{"nav", 0, 0, 3, 0, css.Edges{}},
+		// :where(#p) weighs nothing, and :is(#p) as much as #p
+		{"p", 10, 0, 0, 1, css.Edges{Top: 2}},
+		// An invalid selector is dropped from :is(), but makes :not() invalid
+		{"h", 10, 0, 0, 0, css.Edges{Right: 9}},
+		{"s", 0, 0, 3, 0, css.Edges{}},
+	}
+	check := func() {
+		t.Helper()
+		for _, tt := range tests {
+			s := style(tt.id)
+			if size(s.Width) != tt.width || size(s.Height) != tt.height || s.Margin.Top != tt.marginTop ||
+				s.Margin.Bottom != tt.marginBot || s.Padding != tt.padding {
+				t.Errorf("#%s: expected %+v, got width %v height %v margin %+v padding %+v",
+					tt.id, tt, size(s.Width), size(s.Height), s.Margin, s.Padding)
+			}
+		}
+	}
+	check()
+
+	// A class on an ancestor restyles what the context of :is() matches,
+	// and one on the element what :not() rules out
+	d.SetAttribute(findElement(t, d, "theme"), "class", "dark")
+	d.SetAttribute(findElement(t, d, "s"), "class", "y")
+	tree = r.BuildLayoutTree()
+	assertSameStyles(t, tree, BuildLayoutTree(d, sheet))
+	for i := range tests {
+		tests[i].padding.Left = 8
+	}
+	tests[3].height, tests[3].marginTop = 5, 0
+	check()
+}
@@ -0,0 +1,18 @@
+package layout
+
+// MediaKind is the media element a box stands in for. Penny doesn't play
+// media, so a <video> is drawn as its poster, or as a placeholder with a
+// play button without one, and an <audio> with controls as a bar of them.
+type MediaKind uint8
+
+const (
+	MediaNone MediaKind = iota
+	MediaVideo
+	MediaAudio
+)
+
+// mediaTags are the media elements by their tag
+var mediaTags = map[string]MediaKind{
+	"video": MediaVideo,
+	"audio": MediaAudio,
+}
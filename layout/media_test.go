@@ -0,0 +1,55 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestMediaSizes(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><video id="plain">fallback</video><video id="wide" width="600"></video>` +
+		`<video id="poster" poster="p.png"></video><video id="unknown" poster="q.png" height="75"></video>` +
+		`<audio id="silent" src="a.mp3"></audio><audio id="controls" controls></audio></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	tree := BuildLayoutTree(d, css.UserAgentStylesheet())
+	SizeImages(tree, func(src string) (float32, float32, bool) {
+		return 640, 360, src == "p.png"
+	})
+	ComputeLayout(tree, 2000, 600)
+
+	node := func(id string) *LayoutNode {
+		return &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))]
+	}
+	// A video is 300x150 until its poster's size is known, keeping to that
+	// ratio where one side is set
+	tests := []struct {
+		id   string
+		w, h float32
+	}{
+		{"plain", 300, 150},
+		{"wide", 600, 300},
+		{"poster", 640, 360},
+		{"unknown", 150, 75},
+		{"controls", 300, 54},
+	}
+	for _, tt := range tests {
+		n := node(tt.id)
+		if n.Rect.W != tt.w || n.Rect.H != tt.h {
+			t.Errorf("#%s: expected %vx%v, got %v", tt.id, tt.w, tt.h, n.Rect)
+		}
+	}
+	if n := node("plain"); n.Media != MediaVideo || n.FirstChild != InvalidLayoutNodeID {
+		t.Errorf("expected a video placeholder without its fallback, got %+v", n)
+	}
+	if n := node("controls"); n.Media != MediaAudio {
+		t.Errorf("expected audio controls, got %v", n.Media)
+	}
+	for i := range tree.Nodes {
+		if tree.Nodes[i].DomNode == findElement(t, d, "silent") {
+			t.Error("expected an audio without controls not to be laid out")
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package layout
+
+import "github.com/myuon/penny/css"
+
+// A relatively positioned box is laid out in the normal flow, then moved by
+// its offsets along with its descendants; the boxes around it stay where
+// the flow put them. Absolutely positioned and fixed boxes are taken out of
+// the flow and placed against their containing block: the padding box of
+// the nearest positioned ancestor, or the viewport.
+
+// positioning is what the out-of-flow boxes of a tree are placed against
+type positioning struct {
+	viewport Rect
+	// containers maps each absolutely positioned box to its nearest
+	// positioned ancestor. A box without one is placed against the
+	// viewport, as fixed boxes are.
+	containers map[LayoutNodeID]LayoutNodeID
+}
+
+// newPositioning finds the containing blocks of the out-of-flow boxes of a
+// tree. It returns nil if there are none.
+func newPositioning(tree *LayoutTree, viewport Rect) *positioning {
+	if !hasOutOfFlow(tree) {
+		return nil
+	}
+	p := &positioning{viewport: viewport, containers: map[LayoutNodeID]LayoutNodeID{}}
+	// nearest[d] is the nearest positioned box among the node visited at
+	// depth d and its ancestors
+	var nearest []LayoutNodeID
+	Walk(tree, tree.Root, func(node *LayoutNode, depth int) WalkAction {
+		nearest = nearest[:depth]
+		container := InvalidLayoutNodeID
+		if depth > 0 {
+			container = nearest[depth-1]
+		}
+		if node.Style.Position == css.PositionAbsolute && container != InvalidLayoutNodeID {
+			p.containers[node.ID] = container
+		}
+		if node.Style.Position != css.PositionStatic {
+			container = node.ID
+		}
+		nearest = append(nearest, container)
+		return WalkContinue
+	})
+	return p
+}
+
+func hasOutOfFlow(tree *LayoutTree) bool {
+	for i := range tree.Nodes {
+		if tree.Nodes[i].Style.Position.OutOfFlow() {
+			return true
+		}
+	}
+	return false
+}
+
+// inFlow reports whether a box is laid out in the normal flow of its parent
+func inFlow(node *LayoutNode) bool {
+	return !node.Style.Position.OutOfFlow()
+}
+
+// relativeOffset returns how far a relatively positioned box is moved from
+// where the flow put it. Left wins over right and top over bottom.
+func relativeOffset(s *css.Style) (dx, dy float32) {
+	if s.Position != css.PositionRelative {
+		return 0, 0
+	}
+	o := s.Offsets
+	switch {
+	case !o.Left.Auto:
+		dx = o.Left.Length
+	case !o.Right.Auto:
+		dx = -o.Right.Length
+	}
+	switch {
+	case !o.Top.Auto:
+		dy = o.Top.Length
+	case !o.Bottom.Auto:
+		dy = -o.Bottom.Length
+	}
+	return dx, dy
+}
+
+// flowBottom returns the bottom of the margin box of an in-flow box where
+// the flow put it, before any relative offset
+func flowBottom(node *LayoutNode) float32 {
+	_, dy := relativeOffset(&node.Style)
+	return node.Rect.Y - dy + node.Rect.H + node.Style.Margin.Bottom
+}
+
+// paddingBox returns the box inside the borders of a node, which its
+// absolutely positioned descendants are placed against
+func paddingBox(node *LayoutNode) Rect {
+	s := &node.Style
+	return Rect{
+		X: node.Rect.X + s.Margin.Left,
+		Y: node.Rect.Y + s.Margin.Top,
+		W: node.Rect.W - s.Margin.Left - s.Margin.Right,
+		H: node.Rect.H - s.Margin.Top - s.Margin.Bottom,
+	}
+}
+
+// placeOutOfFlow places an absolutely positioned or fixed box against its
+// containing block. An offset that is auto leaves the box where the flow
+// would have put it, at (staticX, staticY). An auto width fills the space
+// between left and right when both are set, and else shrinks to fit the
+// content; an auto height likewise stretches or fits the content.
+func placeOutOfFlow(tree *LayoutTree, node *LayoutNode, p *positioning, staticX, staticY float32, heights []float32, trace *Trace) {
+	s := &node.Style
+	o := s.Offsets
+	cb := p.viewport
+	mode := traceFixed
+	if s.Position == css.PositionAbsolute {
+		mode = traceAbsolute
+		if id, ok := p.containers[node.ID]; ok {
+			cb = paddingBox(&tree.Nodes[id])
+		}
+	}
+	if trace != nil {
+		trace.record(node, mode, cb, heights)
+	}
+
+	// The width includes the margins, as a block's does
+	w, set := setWidth(s)
+	switch {
+	case set:
+	case !o.Left.Auto && !o.Right.Auto:
+		w = cb.W - o.Left.Length - o.Right.Length
+	default:
+		available := cb.W
+		if !o.Left.Auto {
+			available -= o.Left.Length
+		}
+		if !o.Right.Auto {
+			available -= o.Right.Length
+		}
+		w = min(maxContentWidth(tree, node.ID, heights), available)
+	}
+	w = max(0, w)
+	x := staticX
+	switch {
+	case !o.Left.Auto:
+		x = cb.X + o.Left.Length
+	case !o.Right.Auto:
+		x = cb.X + cb.W - o.Right.Length - w
+	}
+
+	h, set := setHeight(s)
+	switch {
+	case set:
+	case !o.Top.Auto && !o.Bottom.Auto:
+		h = max(0, cb.H-o.Top.Length-o.Bottom.Length-s.Margin.Top-s.Margin.Bottom)
+	default:
+		h = heights[node.ID]
+	}
+	y := staticY
+	switch {
+	case !o.Top.Auto:
+		y = cb.Y + o.Top.Length
+	case !o.Bottom.Auto:
+		y = cb.Y + cb.H - o.Bottom.Length - s.Margin.Top - h - s.Margin.Bottom
+	}
+
+	node.Rect = Rect{
+		X: x + s.Margin.Left,
+		Y: y + s.Margin.Top,
+		W: w - s.Margin.Left - s.Margin.Right,
+		H: h,
+	}
+}
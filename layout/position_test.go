@@ -0,0 +1,73 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestPositionedLayout(t *testing.T) {
+	d, err := dom.ParseString(`<html><body>` +
+		`<div id="before"></div>` +
+		`<div id="box"><div id="rel"></div><div id="abs"></div><div id="stretch"></div><div id="after"></div></div>` +
+		`<div id="fixed"></div>` +
+		`</body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 0; margin: 0; } div { height: 10px; }
+		#box { position: relative; top: 5px; height: auto; padding: 4px; }
+		#rel { position: relative; left: 3px; top: -2px; }
+		#abs { position: absolute; right: 10px; bottom: 0; width: 50px; }
+		#stretch { position: absolute; top: 0; bottom: 0; left: 20px; right: 20px; height: auto; }
+		#fixed { position: fixed; left: 0; bottom: 0; width: 100px; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 400, 300)
+
+	rect := func(id string) Rect {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Rect
+	}
+
+	// #box is moved down 5px after the flow is laid out, taking its
+	// children along; its height counts only #rel and #after, in flow
+	tests := []struct {
+		id   string
+		want Rect
+	}{
+		{"before", Rect{X: 0, Y: 0, W: 400, H: 10}},
+		{"box", Rect{X: 0, Y: 15, W: 400, H: 28}},
+		{"rel", Rect{X: 7, Y: 17, W: 392, H: 10}},
+		{"after", Rect{X: 4, Y: 29, W: 392, H: 10}},
+		// Against the padding box of #box, from (0, 15) to (400, 43)
+		{"abs", Rect{X: 340, Y: 33, W: 50, H: 10}},
+		{"stretch", Rect{X: 20, Y: 15, W: 360, H: 28}},
+		// Against the viewport
+		{"fixed", Rect{X: 0, Y: 290, W: 100, H: 10}},
+	}
+	for _, tt := range tests {
+		if got := rect(tt.id); got != tt.want {
+			t.Errorf("#%s: expected %v, got %v", tt.id, tt.want, got)
+		}
+	}
+}
+
+func TestAbsoluteShrinksToFit(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="abs"><div id="inner"></div></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 0; margin: 0; }
+		#abs { position: absolute; left: 30px; padding: 0 5px; }
+		#inner { width: 80px; height: 10px; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 400, 300)
+
+	abs := tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "abs"))].Rect
+	if abs.X != 30 || abs.W != 90 {
+		t.Errorf("expected the box to fit its content at x=30, got %v", abs)
+	}
+	// It is placed where the flow would have put it vertically
+	if abs.Y != 0 {
+		t.Errorf("expected the static position, got %v", abs)
+	}
+}
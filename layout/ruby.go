@@ -0,0 +1,131 @@
+package layout
+
+import (
+	"math"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/text"
+)
+
+// A ruby box is laid out as a single box in a line. Its children are split
+// into segments, each the base boxes up to an annotation, a ruby-text box,
+// and the annotation itself. The segments are set side by side, with the
+// annotations in a row above the bases, each centered over its base.
+
+// rubySegment is a base of a ruby box, the in-flow children from first to
+// end, exclusive, and the annotation after it, if there is one
+type rubySegment struct {
+	first, end LayoutNodeID
+	annotation LayoutNodeID
+}
+
+// rubySegments splits the children of a ruby box into its segments
+func rubySegments(tree *LayoutTree, node *LayoutNode) []rubySegment {
+	var segments []rubySegment
+	base := InvalidLayoutNodeID
+	for id := node.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		child := &tree.Nodes[id]
+		if !inFlow(child) {
+			continue
+		}
+		if child.Style.Display != css.DisplayRubyText {
+			if base == InvalidLayoutNodeID {
+				base = id
+			}
+			continue
+		}
+		if base == InvalidLayoutNodeID {
+			base = id
+		}
+		segments = append(segments, rubySegment{first: base, end: id, annotation: id})
+		base = InvalidLayoutNodeID
+	}
+	if base != InvalidLayoutNodeID {
+		segments = append(segments, rubySegment{first: base, end: InvalidLayoutNodeID, annotation: InvalidLayoutNodeID})
+	}
+	return segments
+}
+
+// bases calls f with each in-flow box of the base of a segment
+func (s rubySegment) bases(tree *LayoutTree, f func(*LayoutNode)) {
+	for id := s.first; id != s.end; id = tree.Nodes[id].NextSibling {
+		if child := &tree.Nodes[id]; inFlow(child) {
+			f(child)
+		}
+	}
+}
+
+// widths returns how wide the base and the annotation of a segment are,
+// their margins included
+func (s rubySegment) widths(tree *LayoutTree, heights []float32) (base, annotation float32) {
+	s.bases(tree, func(child *LayoutNode) {
+		base += inlineWidth(tree, child, heights, math.MaxFloat32)
+	})
+	if s.annotation != InvalidLayoutNodeID {
+		annotation = inlineWidth(tree, &tree.Nodes[s.annotation], heights, math.MaxFloat32)
+	}
+	return base, annotation
+}
+
+// rubyHeights returns the height of the row of annotations of a ruby box
+// and that of its bases, margins included
+func rubyHeights(tree *LayoutTree, node *LayoutNode, heights []float32) (annotations, bases float32) {
+	outer := func(child *LayoutNode) float32 {
+		return child.Style.Margin.Top + heights[child.ID] + child.Style.Margin.Bottom
+	}
+	for _, s := range rubySegments(tree, node) {
+		s.bases(tree, func(child *LayoutNode) {
+			bases = max(bases, outer(child))
+		})
+		if s.annotation != InvalidLayoutNodeID {
+			annotations = max(annotations, outer(&tree.Nodes[s.annotation]))
+		}
+	}
+	return annotations, bases
+}
+
+// rubyWidth returns how wide the content of a ruby box is
+func rubyWidth(tree *LayoutTree, node *LayoutNode, heights []float32) float32 {
+	var w float32
+	for _, s := range rubySegments(tree, node) {
+		base, annotation := s.widths(tree, heights)
+		w += max(base, annotation)
+	}
+	return w
+}
+
+// rubyAscent returns how far the baseline of a ruby box's bases is below
+// its top, so that they line up with the text around it
+func rubyAscent(tree *LayoutTree, node *LayoutNode, heights []float32) float32 {
+	annotations, _ := rubyHeights(tree, node, heights)
+	return node.Style.Padding.Top + annotations + text.MetricsOf(text.FontOf(&node.Style)).Baseline()
+}
+
+// layoutRuby positions the children of a ruby box whose content starts at
+// (x, y), recording them in trace if it is not nil
+func layoutRuby(tree *LayoutTree, node *LayoutNode, x, y float32, heights []float32, trace *Trace) {
+	annotations, _ := rubyHeights(tree, node, heights)
+	place := func(child *LayoutNode, x, y, w float32) {
+		if trace != nil {
+			trace.record(child, traceRuby, Rect{x, y, w, 0}, heights)
+		}
+		child.Rect.X = x + child.Style.Margin.Left
+		child.Rect.Y = y + child.Style.Margin.Top
+		child.Rect.W = w - child.Style.Margin.Left - child.Style.Margin.Right
+		child.Rect.H = heights[child.ID]
+	}
+	for _, s := range rubySegments(tree, node) {
+		baseW, annotationW := s.widths(tree, heights)
+		w := max(baseW, annotationW)
+		if s.annotation != InvalidLayoutNodeID {
+			place(&tree.Nodes[s.annotation], x+(w-annotationW)/2, y, annotationW)
+		}
+		bx := x + (w-baseW)/2
+		s.bases(tree, func(child *LayoutNode) {
+			cw := inlineWidth(tree, child, heights, math.MaxFloat32)
+			place(child, bx, y+annotations, cw)
+			bx += cw
+		})
+		x += w
+	}
+}
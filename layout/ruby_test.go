@@ -0,0 +1,41 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestRubyLayout(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p id="p"><img id="i"><ruby id="r">漢<rt id="a">かん</rt>字<rp>(</rp><rt id="b">じ</rt></ruby></p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body, p { margin: 0; } img { width: 10px; height: 10px; }`)
+	sheet.Append(css.UserAgentStylesheet())
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	rect := func(id string) Rect {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Rect
+	}
+	ruby, a, b := rect("r"), rect("a"), rect("b")
+	bases := tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, "r"))].FirstChild
+	kan := tree.Nodes[bases].Rect
+
+	// The ruby sits in the line after the image, with each annotation above
+	// and centered on its base
+	if ruby.X != 10 {
+		t.Errorf("expected the ruby after the image, got %v", ruby)
+	}
+	if a.Y != ruby.Y || b.Y != ruby.Y || kan.Y != a.Y+a.H {
+		t.Errorf("expected the annotations in a row above the bases, got %v %v and %v", a, b, kan)
+	}
+	if center := func(r Rect) float32 { return r.X + r.W/2 }; center(a) != center(kan) {
+		t.Errorf("expected the annotation centered on its base, got %v over %v", a, kan)
+	}
+	if b.X < a.X+a.W || ruby.H < a.H+kan.H {
+		t.Errorf("expected the segments side by side inside the ruby, got %v %v in %v", a, b, ruby)
+	}
+}
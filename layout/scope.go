@@ -0,0 +1,108 @@
+package layout
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+// ScopedStylesheet is a stylesheet whose rules only reach the subtree
+// rooted at Root, like the styles of an embedded component or an overlay.
+// The page's rules still reach into the subtree, but scoped rules can't see
+// out of it: their selectors match the root and its descendants only, and
+// their combinators don't look past the root. In the cascade a scoped rule
+// beats the page's rules of the same origin and importance whatever their
+// specificity, and rules of an inner scope beat those of an outer one.
+type ScopedStylesheet struct {
+	Root       dom.NodeID
+	Stylesheet *css.Stylesheet
+}
+
+// addScoped appends the rules of the scoped stylesheets to the index's,
+// recording their scope
+func (ix *ruleIndex) addScoped(scoped []ScopedStylesheet) {
+	rules := slices.Clone(ix.rules)
+	ix.scopes = make([]dom.NodeID, len(rules))
+	for i := range ix.scopes {
+		ix.scopes[i] = dom.InvalidNodeID
+	}
+	for _, s := range scoped {
+		if s.Stylesheet == nil {
+			continue
+		}
+		sheet := s.Stylesheet.ForMedia(css.DefaultMediaContext())
+		rules = append(rules, sheet.Rules...)
+		for range sheet.Rules {
+			ix.scopes = append(ix.scopes, s.Root)
+		}
+		if len(sheet.Keyframes) > 0 {
+			keyframes := maps.Clone(ix.keyframes)
+			if keyframes == nil {
+				keyframes = make(map[string]*css.Keyframes)
+			}
+			maps.Copy(keyframes, sheet.Keyframes)
+			ix.keyframes = keyframes
+		}
+	}
+	ix.rules = rules
+}
+
+// sortScoped stably sorts the rules of the index along with their scopes
+func (ix *ruleIndex) sortScoped(cmp func(a, b css.Rule) int) {
+	order := make([]int, len(ix.rules))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp(ix.rules[a], ix.rules[b])
+	})
+	rules := make([]css.Rule, len(order))
+	scopes := make([]dom.NodeID, len(order))
+	for i, j := range order {
+		rules[i], scopes[i] = ix.rules[j], ix.scopes[j]
+	}
+	ix.rules, ix.scopes = rules, scopes
+}
+
+// scopeDepth returns how deep the scope root sits in the element stack of
+// node, counting from 1 at the outermost ancestor, or 0 if node is outside
+// the subtree of root
+func scopeDepth(node *dom.Node, ancestors []*dom.Node, root dom.NodeID) int {
+	if node.ID == root {
+		return len(ancestors) + 1
+	}
+	for i, a := range ancestors {
+		if a.ID == root {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// AttachScoped scopes stylesheet to the subtree rooted at root, replacing
+// the stylesheet scoped to it before, if any, and marks the subtree for
+// restyle
+func (r *StyleResolver) AttachScoped(root dom.NodeID, stylesheet *css.Stylesheet) {
+	i := slices.IndexFunc(r.scoped, func(s ScopedStylesheet) bool { return s.Root == root })
+	if i < 0 {
+		r.scoped = append(r.scoped, ScopedStylesheet{root, stylesheet})
+	} else {
+		r.scoped[i].Stylesheet = stylesheet
+	}
+	r.rules = newRuleIndex(r.stylesheet, r.scoped)
+	r.invalidateSubtree(root)
+}
+
+// DetachScoped removes the stylesheet scoped to the subtree rooted at root,
+// and marks the subtree for restyle
+func (r *StyleResolver) DetachScoped(root dom.NodeID) {
+	n := len(r.scoped)
+	r.scoped = slices.DeleteFunc(r.scoped, func(s ScopedStylesheet) bool { return s.Root == root })
+	if len(r.scoped) == n {
+		return
+	}
+	r.rules = newRuleIndex(r.stylesheet, r.scoped)
+	r.invalidateSubtree(root)
+}
@@ -0,0 +1,47 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestScopedStylesheets(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p id="out">out</p>` +
+		`<div id="widget"><p id="in" class="x">in</p><div id="inner"><p id="deep" class="x">deep</p></div></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	r := NewStyleResolver(d, mustParseCSS(t, "p { width: 10px; height: 1px; } #widget p.x { height: 2px; }"))
+	widget := findElement(t, d, "widget")
+	inner := findElement(t, d, "inner")
+	r.AttachScoped(widget, mustParseCSS(t, "p { height: 20px; } body p { width: 30px; } div > p { margin-top: 5px; }"))
+	r.AttachScoped(inner, mustParseCSS(t, ".x { height: 40px; }"))
+
+	tree := r.BuildLayoutTree()
+	style := func(id string) (width, height, margin float32) {
+		s := tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Style
+		return *s.Width, *s.Height, s.Margin.Top
+	}
+
+	// Scoped rules don't reach outside the widget
+	if w, h, m := style("out"); w != 10 || h != 1 || m != 0 {
+		t.Errorf("#out: expected 10 1 0, got %v %v %v", w, h, m)
+	}
+	// The scoped rule beats the more specific page rule, and "body p"
+	// can't match since body is outside the scope; "div > p" matches the
+	// root as the parent
+	if w, h, m := style("in"); w != 10 || h != 20 || m != 5 {
+		t.Errorf("#in: expected 10 20 5, got %v %v %v", w, h, m)
+	}
+	// The inner scope beats the outer one
+	if w, h, m := style("deep"); w != 10 || h != 40 || m != 5 {
+		t.Errorf("#deep: expected 10 40 5, got %v %v %v", w, h, m)
+	}
+
+	r.DetachScoped(inner)
+	tree = r.BuildLayoutTree()
+	if _, h, _ := style("deep"); h != 20 {
+		t.Errorf("#deep: expected height 20 once the inner sheet is detached, got %v", h)
+	}
+}
@@ -0,0 +1,48 @@
+package layout
+
+import (
+	"fmt"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+// ForceState turns on the states named by the pseudo-classes of sel for
+// every element the rest of the selector matches, the way browser developer
+// tools force :hover to inspect its styles. It returns the number of
+// elements changed.
+func ForceState(d *dom.DOM, sel css.Selector) (int, error) {
+	state, ok := selectorState(sel)
+	if !ok {
+		return 0, fmt.Errorf("unsupported pseudo-class in %v", sel.PseudoClasses)
+	}
+	if state == 0 {
+		return 0, fmt.Errorf("no state to force in selector %q", sel.String())
+	}
+
+	base := sel
+	base.PseudoClasses = nil
+	matched := QuerySelectorAll(d, []css.Selector{base})
+	// States are set once matching is done, so that forcing one doesn't
+	// change which elements the context of the selector matches
+	for _, nodeID := range matched {
+		d.SetState(nodeID, state, true)
+	}
+	return len(matched), nil
+}
+
+// QuerySelectorAll returns the elements of the document any of selectors
+// matches, in document order
+func QuerySelectorAll(d *dom.DOM, selectors []css.Selector) []dom.NodeID {
+	var matched []dom.NodeID
+	var ancestors []*dom.Node
+	dom.Walk(d, d.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		ancestors = ancestors[:depth]
+		if node.Type == dom.NodeTypeElement && matchesSelector(d, node, ancestors, selectors) {
+			matched = append(matched, node.ID)
+		}
+		ancestors = append(ancestors, node)
+		return dom.WalkContinue
+	})
+	return matched
+}
@@ -0,0 +1,121 @@
+package layout
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestStateSelectors(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><a id="l" href="#">link</a><p id="p">text</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		a { height: 10px; }
+		a:hover { height: 20px; }
+		a:hover:focus { height: 30px; }
+		p::before { height: 99px; }
+	`)
+	l := findElement(t, d, "l")
+	heightOf := func(tree *LayoutTree, nodeID dom.NodeID) *float32 {
+		return tree.GetNode(layoutNodeOf(t, tree, nodeID)).Style.Height
+	}
+
+	r := NewStyleResolver(d, sheet)
+	tree := r.BuildLayoutTree()
+	if h := heightOf(tree, l); *h != 10 {
+		t.Errorf("expected height 10, got %v", *h)
+	}
+	if h := heightOf(tree, findElement(t, d, "p")); h != nil {
+		t.Errorf("expected the ::before rule not to apply, got %v", *h)
+	}
+
+	d.SetState(l, dom.StateHover, true)
+	tree = r.BuildLayoutTree()
+	if h := heightOf(tree, l); *h != 20 || r.Restyled != 1 {
+		t.Errorf("expected height 20 from 1 restyle, got %v from %d", *h, r.Restyled)
+	}
+	d.SetState(l, dom.StateFocus, true)
+	if h := heightOf(r.BuildLayoutTree(), l); *h != 30 {
+		t.Errorf("expected height 30, got %v", *h)
+	}
+}
+
+func TestForceState(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><a class="nav">a</a><a class="nav">b</a><a>c</a></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	n, err := ForceState(d, css.Selector{Classes: []string{"nav"}, PseudoClasses: []string{"hover", "visited"}})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 elements forced, got %d %v", n, err)
+	}
+	for _, node := range d.Nodes {
+		want := dom.ElementState(0)
+		if node.Attr["class"] == "nav" {
+			want = dom.StateHover | dom.StateVisited
+		}
+		if node.State != want {
+			t.Errorf("expected <%s class=%q> to be %v, got %v", node.Tag, node.Attr["class"], want, node.State)
+		}
+	}
+
+	if _, err := ForceState(d, css.Selector{Tag: "a", PseudoClasses: []string{"first-child"}}); err == nil {
+		t.Errorf("expected an error for an unsupported pseudo-class")
+	}
+}
+
+func TestQuerySelectorAll(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="a"><p id="b">x</p></div><p id="c">y</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, "#a p, #c {}")
+	got := QuerySelectorAll(d, sheet.Rules[0].Selectors)
+	want := []dom.NodeID{findElement(t, d, "b"), findElement(t, d, "c")}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestSelectionStyle(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="a"><p id="b">x</p></div><p id="c">y</p></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `
+		::selection { background-color: yellow; }
+		#a::selection { color: red; }
+		#c:hover::selection { background: blue; }
+		#c { caret-color: green; }
+	`)
+	selectionOf := func(tree *LayoutTree, id string) css.Selection {
+		return tree.GetNode(layoutNodeOf(t, tree, findElement(t, d, id))).Style.Selection
+	}
+	yellow, red, blue := css.Color{R: 255, G: 255, A: 255}, css.Color{R: 255, A: 255}, css.Color{B: 255, A: 255}
+
+	r := NewStyleResolver(d, sheet)
+	tree := r.BuildLayoutTree()
+	if got, want := selectionOf(tree, "b"), (css.Selection{Background: yellow, Color: red}); got != want {
+		t.Errorf("expected #b to inherit the red selection color, got %+v", got)
+	}
+	if got, want := selectionOf(tree, "c"), (css.Selection{Background: yellow, CurrentColor: true}); got != want {
+		t.Errorf("expected #c to keep its text color, got %+v", got)
+	}
+	if got := tree.GetNode(layoutNodeOf(t, tree, findElement(t, d, "c"))).Style.Height; got != nil {
+		t.Errorf("expected ::selection declarations not to style the element, got height %v", *got)
+	}
+
+	d.SetState(findElement(t, d, "c"), dom.StateHover, true)
+	tree = r.BuildLayoutTree()
+	if got := selectionOf(tree, "c"); got.Background != blue {
+		t.Errorf("expected a blue selection while hovered, got %+v", got)
+	}
+	style := tree.GetNode(layoutNodeOf(t, tree, findElement(t, d, "c"))).Style
+	if got := style.Caret(); got != (css.Color{G: 128, A: 255}) {
+		t.Errorf("expected a green caret, got %v", got)
+	}
+}
@@ -0,0 +1,382 @@
+package layout
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+// A table lays its rows out in a grid of cells. Its rows are those of its
+// header groups, then those of its row groups and the rows of the table
+// itself in order, then those of its footer groups. Each cell of a row takes
+// the first columns free after the cell before it, as many as its colspan,
+// and as many rows as its rowspan, up to the end of its row group. Columns
+// are as wide as the widest content of their cells, and a table without a
+// width shrinks to them. Rows are as high as their tallest cell, and cells
+// are stretched to fill their area. A cell spanning several columns or rows
+// widens or heightens them by what they lack for it, in proportion to their
+// sizes. Captions are as wide as the table, and
+// above or below its rows as their caption-side says.
+//
+// Where the borders of a table collapse, each cell is drawn over the one
+// before it in its row and the one above it by its own border, so the
+// borders between them are drawn once. Border spacing isn't supported:
+// separate cells are drawn side by side.
+
+// tableRow is a row of a table and its cells. A child of a table or a row
+// group that isn't a row is a row of its own, with itself as its only
+// cell, and has no row box.
+type tableRow struct {
+	id    LayoutNodeID // InvalidLayoutNodeID for a row without a box
+	group LayoutNodeID // the row group, or InvalidLayoutNodeID
+	cells []LayoutNodeID
+}
+
+// tableGrid is the structure of a table: its captions, its rows in the
+// order they are laid out, and the area of each cell
+type tableGrid struct {
+	captions []LayoutNodeID
+	rows     []tableRow
+	cells    gridPlacement
+}
+
+// tableOf finds the rows and captions of a table and places its cells
+func tableOf(tree *LayoutTree, node *LayoutNode) tableGrid {
+	var t tableGrid
+	var head, body, foot []tableRow
+	for id := node.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		child := &tree.Nodes[id]
+		if !inFlow(child) {
+			continue
+		}
+		switch child.Style.Display {
+		case css.DisplayTableCaption:
+			t.captions = append(t.captions, id)
+		case css.DisplayTableHeaderGroup:
+			head = appendGroupRows(tree, head, id)
+		case css.DisplayTableFooterGroup:
+			foot = appendGroupRows(tree, foot, id)
+		case css.DisplayTableRowGroup:
+			body = appendGroupRows(tree, body, id)
+		default:
+			body = append(body, rowOf(tree, id, InvalidLayoutNodeID))
+		}
+	}
+	t.rows = append(append(head, body...), foot...)
+	t.cells = placeTableCells(tree, t.rows)
+	return t
+}
+
+// appendGroupRows appends the rows of a row group to rows
+func appendGroupRows(tree *LayoutTree, rows []tableRow, group LayoutNodeID) []tableRow {
+	for id := tree.Nodes[group].FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		if inFlow(&tree.Nodes[id]) {
+			rows = append(rows, rowOf(tree, id, group))
+		}
+	}
+	return rows
+}
+
+// rowOf returns the row a child of a table or row group is
+func rowOf(tree *LayoutTree, id, group LayoutNodeID) tableRow {
+	node := &tree.Nodes[id]
+	if node.Style.Display != css.DisplayTableRow {
+		return tableRow{id: InvalidLayoutNodeID, group: group, cells: []LayoutNodeID{id}}
+	}
+	row := tableRow{id: id, group: group}
+	for cell := node.FirstChild; cell != InvalidLayoutNodeID; cell = tree.Nodes[cell].NextSibling {
+		if inFlow(&tree.Nodes[cell]) {
+			row.cells = append(row.cells, cell)
+		}
+	}
+	return row
+}
+
+// placeTableCells places the cells of each row in the first columns free,
+// skipping those taken by the cells spanning down from the rows above. A
+// rowspan doesn't reach past the last row of its group, the rows of the
+// table itself next to each other making up a group of their own.
+func placeTableCells(tree *LayoutTree, rows []tableRow) gridPlacement {
+	g := gridPlacement{rows: len(rows)}
+	var taken [][]bool // by row, then column
+	isTaken := func(r, c int) bool {
+		return r < len(taken) && c < len(taken[r]) && taken[r][c]
+	}
+	groupEnd := 0
+	for r, row := range rows {
+		if r == groupEnd {
+			for groupEnd < len(rows) && rows[groupEnd].group == row.group {
+				groupEnd++
+			}
+		}
+		col := 0
+		for _, id := range row.cells {
+			for isTaken(r, col) {
+				col++
+			}
+			colSpan, rowSpan := cellSpan(&tree.Nodes[id])
+			rowSpan = min(rowSpan, groupEnd-r)
+			item := gridItem{id: id, col: col, colEnd: col + colSpan, row: r, rowEnd: r + rowSpan, colSpan: colSpan, rowSpan: rowSpan}
+			for len(taken) < item.rowEnd {
+				taken = append(taken, nil)
+			}
+			for rr := r; rr < item.rowEnd; rr++ {
+				for len(taken[rr]) < item.colEnd {
+					taken[rr] = append(taken[rr], false)
+				}
+				for c := col; c < item.colEnd; c++ {
+					taken[rr][c] = true
+				}
+			}
+			g.items = append(g.items, item)
+			g.columns = max(g.columns, item.colEnd)
+			col = item.colEnd
+		}
+	}
+	return g
+}
+
+// cellSpan returns how many columns and rows a cell spans
+func cellSpan(node *LayoutNode) (int, int) {
+	return max(1, int(node.span[0])), max(1, int(node.span[1]))
+}
+
+// maxCellSpan is the largest colspan and rowspan of a cell, as browsers
+// limit them
+var maxCellSpan = [2]int{1000, 65534}
+
+// initCellSpan reads the colspan and rowspan of a table cell. A rowspan of
+// 0 spans the rest of the cell's row group.
+func initCellSpan(node *LayoutNode, elem *dom.Node) {
+	for i, name := range [2]string{"colspan", "rowspan"} {
+		n, err := strconv.Atoi(elem.Attr[name])
+		switch {
+		case err != nil:
+		case n > 1:
+			node.span[i] = uint16(min(n, maxCellSpan[i]))
+		case n == 0 && name == "rowspan":
+			node.span[i] = uint16(maxCellSpan[i])
+		}
+	}
+}
+
+// tableColumns sizes the columns of a table to the widest content of their
+// cells. A table with a width shares the room left over between them.
+func tableColumns(tree *LayoutTree, node *LayoutNode, t tableGrid, w float32, heights []float32) []float32 {
+	space := float32(-1)
+	if _, ok := setWidth(&node.Style); ok {
+		space = w
+	}
+	return tableTracks(t.cells.columns, space, t.cells.items, columnSpan, func(id LayoutNodeID) float32 {
+		return maxContentWidth(tree, id, heights)
+	})
+}
+
+// tableRows sizes the rows of a table to their tallest cells
+func tableRows(tree *LayoutTree, t tableGrid, heights []float32) []float32 {
+	return tableTracks(t.cells.rows, -1, t.cells.items, rowSpan, func(id LayoutNodeID) float32 {
+		if h, ok := setHeight(&tree.Nodes[id].Style); ok {
+			return h
+		}
+		return heights[id]
+	})
+}
+
+// tableTracks sizes the columns or rows of a table to the cells in them,
+// then grows them to fill space unless it is negative. The cells spanning a
+// single track are sized first, then those spanning more, fewest first.
+func tableTracks(count int, space float32, items []gridItem, span func(gridItem) (int, int), contribution func(LayoutNodeID) float32) []float32 {
+	tracks := make([]float32, count)
+	var spanning []gridItem
+	for _, item := range items {
+		start, n := span(item)
+		if n > 1 {
+			spanning = append(spanning, item)
+			continue
+		}
+		tracks[start] = max(tracks[start], contribution(item.id))
+	}
+	slices.SortStableFunc(spanning, func(a, b gridItem) int {
+		_, n := span(a)
+		_, m := span(b)
+		return n - m
+	})
+	for _, item := range spanning {
+		start, n := span(item)
+		growTracks(tracks[start:start+n], contribution(item.id))
+	}
+	if space >= 0 {
+		growTracks(tracks, space)
+	}
+	return tracks
+}
+
+// growTracks grows tracks to size together, each by a share of what they
+// lack in proportion to its size, or by an equal share where they are all
+// empty
+func growTracks(tracks []float32, size float32) {
+	var have float32
+	for _, t := range tracks {
+		have += t
+	}
+	lack := size - have
+	if lack <= 0 {
+		return
+	}
+	for i := range tracks {
+		if have > 0 {
+			tracks[i] += lack * tracks[i] / have
+		} else {
+			tracks[i] += lack / float32(len(tracks))
+		}
+	}
+}
+
+// tableWidth returns how wide the columns of a table are together
+func tableWidth(tree *LayoutTree, node *LayoutNode, heights []float32) float32 {
+	var total float32
+	for _, w := range tableColumns(tree, node, tableOf(tree, node), -1, heights) {
+		total += w
+	}
+	return total
+}
+
+// tableHeight returns how high the rows and captions of a table are
+// together
+func tableHeight(tree *LayoutTree, node *LayoutNode, heights []float32) float32 {
+	t := tableOf(tree, node)
+	var total float32
+	for _, h := range tableRows(tree, t, heights) {
+		total += h
+	}
+	for _, id := range t.captions {
+		caption := &tree.Nodes[id]
+		total += caption.Style.Margin.Top + heights[id] + caption.Style.Margin.Bottom
+	}
+	return total
+}
+
+// layoutTable places the captions, row groups, rows and cells of a table in
+// its content box, starting at (x, y) with width w. A table without a
+// width is made as wide as its columns.
+func layoutTable(tree *LayoutTree, node *LayoutNode, x, y, w float32, heights []float32, trace *Trace) {
+	t := tableOf(tree, node)
+	columns := tableColumns(tree, node, t, w, heights)
+	if _, ok := setWidth(&node.Style); !ok {
+		var total float32
+		for _, c := range columns {
+			total += c
+		}
+		node.Rect.W += total - w
+		w = total
+	}
+	rows := tableRows(tree, t, heights)
+
+	placeCaptions := func(side css.CaptionSide) {
+		for _, id := range t.captions {
+			caption := &tree.Nodes[id]
+			if caption.Style.CaptionSide != side {
+				continue
+			}
+			if trace != nil {
+				trace.record(caption, traceTable, Rect{x, y, w, 0}, heights)
+			}
+			caption.Rect.X = x + caption.Style.Margin.Left
+			caption.Rect.Y = y + caption.Style.Margin.Top
+			caption.Rect.W = w - caption.Style.Margin.Left - caption.Style.Margin.Right
+			caption.Rect.H = heights[id]
+			if h, ok := setHeight(&caption.Style); ok {
+				caption.Rect.H = h
+			}
+			y = caption.Rect.Y + caption.Rect.H + caption.Style.Margin.Bottom
+		}
+	}
+	placeCaptions(css.CaptionSideTop)
+
+	// The start of each track, and the end of the last
+	lines := func(tracks []float32, start float32) []float32 {
+		at := make([]float32, len(tracks)+1)
+		at[0] = start
+		for i, size := range tracks {
+			at[i+1] = at[i] + size
+		}
+		return at
+	}
+	colAt, rowAt := lines(columns, x), lines(rows, y)
+
+	for i, row := range t.rows {
+		box := Rect{X: x, Y: rowAt[i], W: w, H: rows[i]}
+		if row.id != InvalidLayoutNodeID {
+			tree.Nodes[row.id].Rect = box
+		}
+		// A group spans its rows, which are laid out together
+		if group := row.group; group != InvalidLayoutNodeID {
+			if i == 0 || t.rows[i-1].group != group {
+				tree.Nodes[group].Rect = box
+			} else {
+				tree.Nodes[group].Rect.H = box.Y + box.H - tree.Nodes[group].Rect.Y
+			}
+		}
+	}
+
+	collapse := node.Style.BorderCollapse == css.BorderCollapseCollapse
+	for _, item := range t.cells.items {
+		cell := &tree.Nodes[item.id]
+		area := Rect{
+			X: colAt[item.col],
+			Y: rowAt[item.row],
+			W: colAt[item.colEnd] - colAt[item.col],
+			H: rowAt[item.rowEnd] - rowAt[item.row],
+		}
+		if collapse && item.col > 0 {
+			area.X -= cell.Style.Border.Left
+			area.W += cell.Style.Border.Left
+		}
+		if collapse && item.row > 0 {
+			area.Y -= cell.Style.Border.Top
+			area.H += cell.Style.Border.Top
+		}
+		if trace != nil {
+			trace.record(cell, traceTable, area, heights)
+		}
+		cell.Rect = area
+	}
+
+	y = rowAt[len(rows)]
+	placeCaptions(css.CaptionSideBottom)
+}
+
+// tableDisplay returns how a box with a table display is laid out under a
+// parent with display parent. The parts of a table are laid out as blocks
+// outside the table, row group or row they belong in, rather than in a
+// table made up around them.
+func tableDisplay(display, parent css.Display) css.Display {
+	var fits bool
+	switch display {
+	case css.DisplayTableRowGroup, css.DisplayTableHeaderGroup, css.DisplayTableFooterGroup, css.DisplayTableCaption:
+		fits = parent == css.DisplayTable
+	case css.DisplayTableRow:
+		fits = parent == css.DisplayTable || isRowGroup(parent)
+	case css.DisplayTableCell:
+		fits = parent == css.DisplayTableRow
+	default:
+		return display
+	}
+	if !fits {
+		return css.DisplayBlock
+	}
+	return display
+}
+
+// isRowGroup reports whether a display is that of a row group
+func isRowGroup(d css.Display) bool {
+	return d == css.DisplayTableRowGroup || d == css.DisplayTableHeaderGroup || d == css.DisplayTableFooterGroup
+}
+
+// laidOutByTable reports whether a box is placed, along with its children,
+// by the table it is in: a row group or a row
+func laidOutByTable(node *LayoutNode) bool {
+	return isRowGroup(node.Style.Display) || node.Style.Display == css.DisplayTableRow
+}
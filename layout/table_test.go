@@ -0,0 +1,144 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestTableLayout(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><table id="t"><caption id="cap">Title</caption>` +
+		`<thead><tr id="h"><th id="a">Name</th><th id="b">Value</th></tr></thead>` +
+		`<tfoot><tr id="f"><td id="total" colspan="2">total</td></tr></tfoot>` +
+		`<tbody id="body"><tr id="r1"><td id="c">a</td><td id="d">a longer value</td></tr><tr id="r2"><td>b<br></td></tr></tbody>` +
+		`</table></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { margin: 0; } td, th { padding: 0; }`)
+	sheet.Append(css.UserAgentStylesheet())
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	rect := func(id string) Rect {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Rect
+	}
+	table, caption := rect("t"), rect("cap")
+	h, r1, r2, f := rect("h"), rect("r1"), rect("r2"), rect("f")
+
+	// The caption is on top, and the footer is below the body, though it
+	// comes before it
+	if caption.Y != table.Y || h.Y != caption.Y+caption.H {
+		t.Errorf("expected the caption above the header, got %v and %v", caption, h)
+	}
+	if r1.Y != h.Y+h.H || r2.Y != r1.Y+r1.H || f.Y != r2.Y+r2.H {
+		t.Errorf("expected the header, body and footer rows in order, got %v %v %v %v", h, r1, r2, f)
+	}
+	if body := rect("body"); body.Y != r1.Y || body.H != r1.H+r2.H {
+		t.Errorf("expected the row group to span its rows, got %v", body)
+	}
+
+	// The cells line up in columns, the table shrinks to them, and a
+	// colspan spans both
+	a, b, c, dd := rect("a"), rect("b"), rect("c"), rect("d")
+	if c.X != a.X || dd.X != b.X || dd.X != c.X+c.W || dd.W != b.W {
+		t.Errorf("expected the cells in columns, got %v %v over %v %v", a, b, c, dd)
+	}
+	if table.W >= 800 || table.W != c.W+dd.W {
+		t.Errorf("expected the table as wide as its columns, got %v", table.W)
+	}
+	if total := rect("total"); total.X != c.X || total.W != table.W {
+		t.Errorf("expected the colspan to cover both columns, got %v", total)
+	}
+	if caption.W != table.W {
+		t.Errorf("expected the caption as wide as the table, got %v", caption)
+	}
+}
+
+func TestTableBorderCollapse(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><table><tr><td id="a">a</td><td id="b">b</td></tr><tr><td id="c">c</td></tr></table></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { margin: 0; } td { padding: 0; border: 2px solid black; } table { border-collapse: collapse; }`)
+	sheet.Append(css.UserAgentStylesheet())
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	rect := func(id string) Rect {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Rect
+	}
+	a, b, c := rect("a"), rect("b"), rect("c")
+	// Each cell is drawn over the border of the one before and above it
+	if b.X != a.X+a.W-2 {
+		t.Errorf("expected the borders between a and b to overlap, got %v and %v", a, b)
+	}
+	if c.Y != a.Y+a.H-2 {
+		t.Errorf("expected the borders between a and c to overlap, got %v and %v", a, c)
+	}
+}
+
+func TestTableDisplay(t *testing.T) {
+	tests := []struct {
+		display, parent, want css.Display
+	}{
+		{css.DisplayTableRow, css.DisplayTableRowGroup, css.DisplayTableRow},
+		{css.DisplayTableRow, css.DisplayTable, css.DisplayTableRow},
+		{css.DisplayTableRow, css.DisplayBlock, css.DisplayBlock},
+		{css.DisplayTableCell, css.DisplayTableRow, css.DisplayTableCell},
+		{css.DisplayTableCell, css.DisplayTable, css.DisplayBlock},
+		{css.DisplayTableCaption, css.DisplayTable, css.DisplayTableCaption},
+		{css.DisplayTableHeaderGroup, css.DisplayFlex, css.DisplayBlock},
+		{css.DisplayInline, css.DisplayTableRow, css.DisplayInline},
+	}
+	for _, tt := range tests {
+		if got := tableDisplay(tt.display, tt.parent); got != tt.want {
+			t.Errorf("tableDisplay(%v, %v) = %v, want %v", tt.display, tt.parent, got, tt.want)
+		}
+	}
+}
+
+func TestTableSpans(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><table>` +
+		`<tbody><tr><td id="a">a</td><td id="b">bbbbbbbb</td><td id="tall" rowspan="2">1<br>2<br>3<br>4</td></tr>` +
+		`<tr id="r2"><td id="wide" colspan="2">a much longer line of text than either</td></tr></tbody>` +
+		`<tbody><tr id="g1"><td id="rest" rowspan="0">x</td><td id="clipped" rowspan="5">y</td><td>w</td></tr><tr id="g2"><td>z</td></tr></tbody>` +
+		`</table></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { margin: 0; } td { padding: 0; }`)
+	sheet.Append(css.UserAgentStylesheet())
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	rect := func(id string) Rect {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Rect
+	}
+	near := func(a, b float32) bool { return a-b < 0.01 && b-a < 0.01 }
+
+	// The columns a colspan widens keep to the ratio of their own cells
+	a, b, wide := rect("a"), rect("b"), rect("wide")
+	aw := maxContentWidth(tree, layoutNodeOf(t, tree, findElement(t, d, "a")), nil)
+	bw := maxContentWidth(tree, layoutNodeOf(t, tree, findElement(t, d, "b")), nil)
+	if !near(a.W+b.W, wide.W) || !near(a.W/b.W, aw/bw) {
+		t.Errorf("expected %v and %v to share the colspan's %v as %v to %v", a.W, b.W, wide.W, aw, bw)
+	}
+
+	// The rows a rowspan heightens share what they lack alike, as they are
+	// as high as each other
+	tall, r2 := rect("tall"), rect("r2")
+	if !near(a.H, r2.H) || !near(a.H+r2.H, tall.H) || tall.H < 4*LineHeight(css.DefaultStyle()) {
+		t.Errorf("expected the rows to share the rowspan's height, got %v and %v for %v", a.H, r2.H, tall.H)
+	}
+
+	// A rowspan of 0 reaches the end of its group, as larger ones stop at it
+	g1, g2 := rect("g1"), rect("g2")
+	if rest := rect("rest"); rest.Y != g1.Y || rest.H != g1.H+g2.H {
+		t.Errorf("expected rowspan=0 to span the group, got %v", rest)
+	}
+	if clipped := rect("clipped"); clipped.H != g1.H+g2.H {
+		t.Errorf("expected the rowspan to stop at the end of its group, got %v", clipped)
+	}
+}
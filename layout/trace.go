@@ -0,0 +1,141 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+// Trace records the inputs and outputs of the sizing of each box by
+// ComputeLayoutTraced, to find out why a page lays out differently than in
+// a browser
+type Trace struct {
+	Viewport Rect       `json:"viewport"`
+	Boxes    []TraceBox `json:"boxes"` // in tree order
+
+	boxes []TraceBox // indexed by LayoutNodeID while layout runs
+}
+
+// TraceBox is how a box was sized: the containing block it was placed in
+// and its specified values, then the rect it was given
+type TraceBox struct {
+	Node    LayoutNodeID `json:"node"`
+	DomNode dom.NodeID   `json:"dom_node"`
+	Depth   int          `json:"depth"`
+	Text    string       `json:"text,omitempty"`
+	// Mode is how the box was placed: as the root, as a block, in a line
+	// box, in the area of a grid, in a ruby box, as a column of a vertical
+	// box, or out of flow as an absolute or fixed box
+	Mode string `json:"mode"`
+	// Containing is the content box of the containing block where the box
+	// was placed: its left edge and width, and the y the box started at.
+	// Its height is not known yet at that point and left 0, except for the
+	// grid area of a grid item and the containing block of an out-of-flow
+	// box.
+	Containing Rect           `json:"containing"`
+	Specified  TraceSpecified `json:"specified"`
+	// Estimated is the height estimated bottom up before the box was
+	// placed, which auto heights start from
+	Estimated float32 `json:"estimated"`
+	Used      Rect    `json:"used"`
+}
+
+// TraceSpecified are the specified values of a box that its size depends on
+type TraceSpecified struct {
+	Display string    `json:"display"`
+	Width   *float32  `json:"width"` // nil for auto
+	Height  *float32  `json:"height"`
+	Margin  css.Edges `json:"margin"`
+	Padding css.Edges `json:"padding"`
+	Columns int       `json:"columns,omitempty"`
+}
+
+const (
+	traceRoot     = "root"
+	traceBlock    = "block"
+	traceLine     = "line"
+	traceGrid     = "grid"
+	traceRuby     = "ruby"
+	traceVertical = "vertical"
+	traceTable    = "table"
+	traceAbsolute = "absolute"
+	traceFixed    = "fixed"
+)
+
+func newTrace(tree *LayoutTree, viewportWidth, viewportHeight float32) *Trace {
+	return &Trace{
+		Viewport: Rect{0, 0, viewportWidth, viewportHeight},
+		boxes:    make([]TraceBox, len(tree.Nodes)),
+	}
+}
+
+// record records the inputs of the sizing of node as it is placed
+func (t *Trace) record(node *LayoutNode, mode string, containing Rect, heights []float32) {
+	s := &node.Style
+	t.boxes[node.ID] = TraceBox{
+		Node:       node.ID,
+		DomNode:    node.DomNode,
+		Text:       node.Text,
+		Mode:       mode,
+		Containing: containing,
+		Specified: TraceSpecified{
+			Display: s.Display.String(),
+			Width:   s.Width,
+			Height:  s.Height,
+			Margin:  s.Margin,
+			Padding: s.Padding,
+			Columns: s.Columns.Count,
+		},
+		Estimated: heights[node.ID],
+	}
+}
+
+// finish records the rects the boxes ended up with, and lists the boxes in
+// tree order
+func (t *Trace) finish(tree *LayoutTree) {
+	Walk(tree, tree.Root, func(node *LayoutNode, depth int) WalkAction {
+		box := t.boxes[node.ID]
+		box.Depth = depth
+		box.Used = node.Rect
+		t.Boxes = append(t.Boxes, box)
+		return WalkContinue
+	})
+	t.boxes = nil
+}
+
+// Dump formats the trace as text, a box per line
+func (t *Trace) Dump() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "viewport %.1fx%.1f\n", t.Viewport.W, t.Viewport.H)
+	for _, box := range t.Boxes {
+		sb.WriteString(strings.Repeat("  ", box.Depth))
+		if box.Text != "" {
+			fmt.Fprintf(&sb, "[text] %q", box.Text)
+		} else {
+			fmt.Fprintf(&sb, "[%d] display=%s", box.DomNode, box.Specified.Display)
+		}
+		s := box.Specified
+		fmt.Fprintf(&sb, " %s in (%.1f, %.1f, %.1f) width=%s height=%s margin=%s padding=%s",
+			box.Mode, box.Containing.X, box.Containing.Y, box.Containing.W,
+			traceLength(s.Width), traceLength(s.Height), traceEdges(s.Margin), traceEdges(s.Padding))
+		if s.Columns > 1 {
+			fmt.Fprintf(&sb, " columns=%d", s.Columns)
+		}
+		fmt.Fprintf(&sb, " estimated=%.1f -> (%.1f, %.1f, %.1f, %.1f)\n",
+			box.Estimated, box.Used.X, box.Used.Y, box.Used.W, box.Used.H)
+	}
+	return sb.String()
+}
+
+func traceLength(v *float32) string {
+	if v == nil {
+		return "auto"
+	}
+	return fmt.Sprintf("%.1f", *v)
+}
+
+func traceEdges(e css.Edges) string {
+	return fmt.Sprintf("(%g %g %g %g)", e.Top, e.Right, e.Bottom, e.Left)
+}
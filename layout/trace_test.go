@@ -0,0 +1,48 @@
+package layout
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestLayoutTrace(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div id="a">text</div><img id="i"></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body { padding: 8px; } #a { width: 100px; margin: 4px; } img { width: 16px; height: 16px; display: inline; }`)
+	tree := BuildLayoutTree(d, sheet)
+	trace := ComputeLayoutTraced(tree, 800, 600)
+
+	if len(trace.Boxes) != len(tree.Nodes) {
+		t.Fatalf("expected a box per node, got %d for %d nodes", len(trace.Boxes), len(tree.Nodes))
+	}
+	for _, box := range trace.Boxes {
+		if box.Used != tree.Nodes[box.Node].Rect {
+			t.Errorf("box %d: expected the used rect %+v, got %+v", box.Node, tree.Nodes[box.Node].Rect, box.Used)
+		}
+	}
+
+	a := trace.Boxes[1]
+	if a.DomNode != findElement(t, d, "a") || a.Mode != traceBlock || a.Depth != 1 {
+		t.Fatalf("expected #a as a block at depth 1, got %+v", a)
+	}
+	if a.Containing != (Rect{8, 8, 784, 0}) || *a.Specified.Width != 100 || a.Specified.Margin.Left != 4 {
+		t.Errorf("expected #a in the body's content box with its width and margin, got %+v", a)
+	}
+	if text := trace.Boxes[2]; text.Text != "text" || text.Depth != 2 {
+		t.Errorf("expected the text of #a, got %+v", text)
+	}
+
+	dump := trace.Dump()
+	if !strings.Contains(dump, "width=100.0 height=auto margin=(4 4 4 4)") {
+		t.Errorf("expected the dump to list the specified values of #a, got:\n%s", dump)
+	}
+	data, err := json.Marshal(trace)
+	if err != nil || !strings.Contains(string(data), `"mode":"block"`) {
+		t.Errorf("expected the trace to encode as JSON, got %s, %v", data, err)
+	}
+}
@@ -2,6 +2,7 @@ package layout
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/myuon/penny/css"
 	"github.com/myuon/penny/dom"
@@ -15,18 +16,54 @@ type Rect struct {
 	X, Y, W, H float32
 }
 
+// LayoutNode is a box in the layout tree. Children are linked through
+// FirstChild and NextSibling, so a node needs no allocation of its own:
+//
+//	for id := node.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+//		...
+//	}
 type LayoutNode struct {
-	ID       LayoutNodeID
-	DomNode  dom.NodeID
-	Style    css.Style
-	Children []LayoutNodeID
-	Rect     Rect
-	Text     string // for text nodes
+	ID          LayoutNodeID
+	DomNode     dom.NodeID
+	Style       css.Style
+	FirstChild  LayoutNodeID
+	LastChild   LayoutNodeID
+	NextSibling LayoutNodeID
+	Rect        Rect
+	Text        string // for text nodes
+	Replaced    bool   // for replaced elements, such as <img>
+	Src         string // the image of an <img>, as written
+	// WordSpacing is the room added to each space of a text in a justified
+	// line box
+	WordSpacing float32
+	// Gauge is the bar of a <progress> or <meter>
+	Gauge Gauge
+	// Media is the media element a replaced box stands in for, if any
+	Media MediaKind
+	// Clamp is where the line-clamp of an element around a text node cuts
+	// it off
+	Clamp LineClamp
+	// Marker is the marker of a list item
+	Marker ListMarker
+	// span is the colspan and rowspan of a table cell, 0 where it has none
+	span [2]uint16
+	// autoSize is which of the width and height of an <img> are auto, and
+	// so given by its image's natural size once SizeImages knows it
+	autoSize [2]bool
 }
 
+// LayoutTree stores its nodes in a single slice indexed by LayoutNodeID.
+// Pointers returned by GetNode stay valid until the next CreateNode that
+// grows the slice beyond its capacity; BuildLayoutTree reserves room for
+// every node up front, so they are stable while the tree is built.
+//
+// Like a DOM, a LayoutTree belongs to the goroutine that builds and lays it
+// out. After Freeze it may be read, e.g. painted, from several goroutines.
 type LayoutTree struct {
 	Nodes []LayoutNode
 	Root  LayoutNodeID
+
+	frozen bool
 }
 
 func NewLayoutTree() *LayoutTree {
@@ -37,53 +74,128 @@ func NewLayoutTree() *LayoutTree {
 }
 
 func (t *LayoutTree) CreateNode(domNode dom.NodeID, style css.Style) LayoutNodeID {
+	t.checkMutable()
 	id := LayoutNodeID(len(t.Nodes))
 	t.Nodes = append(t.Nodes, LayoutNode{
-		ID:       id,
-		DomNode:  domNode,
-		Style:    style,
-		Children: []LayoutNodeID{},
-		Rect:     Rect{},
+		ID:          id,
+		DomNode:     domNode,
+		Style:       style,
+		FirstChild:  InvalidLayoutNodeID,
+		LastChild:   InvalidLayoutNodeID,
+		NextSibling: InvalidLayoutNodeID,
+		Rect:        Rect{},
 	})
 	return id
 }
 
 func (t *LayoutTree) AppendChild(parent, child LayoutNodeID) {
-	t.Nodes[parent].Children = append(t.Nodes[parent].Children, child)
+	t.checkMutable()
+	p := &t.Nodes[parent]
+	if p.LastChild == InvalidLayoutNodeID {
+		p.FirstChild = child
+	} else {
+		t.Nodes[p.LastChild].NextSibling = child
+	}
+	p.LastChild = child
 }
 
-func (t *LayoutTree) GetNode(id LayoutNodeID) *LayoutNode {
-	if id < 0 || int(id) >= len(t.Nodes) {
-		return nil
+// removeNodes removes the leaves marked in drop from the tree. The nodes
+// after them are renumbered, keeping their order.
+func (t *LayoutTree) removeNodes(drop []bool) {
+	t.checkMutable()
+	for i := range t.Nodes {
+		if drop[i] {
+			continue
+		}
+		p := &t.Nodes[i]
+		prev := InvalidLayoutNodeID
+		for id := p.FirstChild; id != InvalidLayoutNodeID; id = t.Nodes[id].NextSibling {
+			if drop[id] {
+				continue
+			}
+			if prev == InvalidLayoutNodeID {
+				p.FirstChild = id
+			} else {
+				t.Nodes[prev].NextSibling = id
+			}
+			prev = id
+		}
+		if prev == InvalidLayoutNodeID {
+			p.FirstChild = InvalidLayoutNodeID
+		} else {
+			t.Nodes[prev].NextSibling = InvalidLayoutNodeID
+		}
+		p.LastChild = prev
 	}
-	return &t.Nodes[id]
+
+	ids := make([]LayoutNodeID, len(t.Nodes))
+	var n LayoutNodeID
+	for i := range t.Nodes {
+		ids[i] = InvalidLayoutNodeID
+		if !drop[i] {
+			ids[i] = n
+			n++
+		}
+	}
+	renumber := func(id LayoutNodeID) LayoutNodeID {
+		if id == InvalidLayoutNodeID {
+			return id
+		}
+		return ids[id]
+	}
+	for i := range t.Nodes {
+		if drop[i] {
+			continue
+		}
+		node := t.Nodes[i]
+		node.ID = ids[i]
+		node.FirstChild = renumber(node.FirstChild)
+		node.LastChild = renumber(node.LastChild)
+		node.NextSibling = renumber(node.NextSibling)
+		t.Nodes[ids[i]] = node
+	}
+	t.Nodes = t.Nodes[:n]
+	t.Root = renumber(t.Root)
 }
 
-func (t *LayoutTree) Dump() string {
-	var result string
-	t.dumpNode(t.Root, 0, &result)
-	return result
+// Freeze makes the tree immutable once layout has been computed, so that it
+// can be shared between goroutines. Building on or laying out a frozen tree
+// panics.
+func (t *LayoutTree) Freeze() {
+	t.frozen = true
 }
 
-func (t *LayoutTree) dumpNode(id LayoutNodeID, indent int, result *string) {
-	node := t.GetNode(id)
-	if node == nil {
-		return
-	}
+// Frozen reports whether Freeze has been called
+func (t *LayoutTree) Frozen() bool {
+	return t.frozen
+}
 
-	prefix := ""
-	for i := 0; i < indent; i++ {
-		prefix += "  "
+func (t *LayoutTree) checkMutable() {
+	if t.frozen {
+		panic("layout: mutation of a frozen layout tree")
 	}
+}
 
-	rect := fmt.Sprintf("(%.1f, %.1f, %.1f, %.1f)", node.Rect.X, node.Rect.Y, node.Rect.W, node.Rect.H)
-	if node.Text != "" {
-		*result += fmt.Sprintf("%s[text] %s \"%s\"\n", prefix, rect, node.Text)
-	} else {
-		*result += fmt.Sprintf("%s[%d] %s display=%s\n", prefix, node.DomNode, rect, node.Style.Display)
+func (t *LayoutTree) GetNode(id LayoutNodeID) *LayoutNode {
+	if id < 0 || int(id) >= len(t.Nodes) {
+		return nil
 	}
+	return &t.Nodes[id]
+}
 
-	for _, childID := range node.Children {
-		t.dumpNode(childID, indent+1, result)
-	}
+func (t *LayoutTree) Dump() string {
+	var sb strings.Builder
+
+	Walk(t, t.Root, func(node *LayoutNode, depth int) WalkAction {
+		prefix := strings.Repeat("  ", depth)
+		rect := fmt.Sprintf("(%.1f, %.1f, %.1f, %.1f)", node.Rect.X, node.Rect.Y, node.Rect.W, node.Rect.H)
+		if node.Text != "" {
+			fmt.Fprintf(&sb, "%s[text] %s \"%s\"\n", prefix, rect, node.Text)
+		} else {
+			fmt.Fprintf(&sb, "%s[%d] %s display=%s\n", prefix, node.DomNode, rect, node.Style.Display)
+		}
+		return WalkContinue
+	})
+
+	return sb.String()
 }
@@ -0,0 +1,218 @@
+package layout
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestAppendChildOrder(t *testing.T) {
+	tree := NewLayoutTree()
+	root := tree.CreateNode(dom.InvalidNodeID, css.DefaultStyle())
+	var want []LayoutNodeID
+	for i := 0; i < 3; i++ {
+		child := tree.CreateNode(dom.InvalidNodeID, css.DefaultStyle())
+		tree.AppendChild(root, child)
+		want = append(want, child)
+	}
+
+	var got []LayoutNodeID
+	node := tree.GetNode(root)
+	for id := node.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		got = append(got, id)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d children, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("child %d: expected %d, got %d", i, want[i], got[i])
+		}
+	}
+	if node.LastChild != want[len(want)-1] {
+		t.Errorf("expected last child %d, got %d", want[len(want)-1], node.LastChild)
+	}
+}
+
+func TestRemoveNodes(t *testing.T) {
+	tree := NewLayoutTree()
+	tree.Root = tree.CreateNode(dom.NodeID(0), css.DefaultStyle())
+	for i := 1; i <= 4; i++ {
+		tree.AppendChild(tree.Root, tree.CreateNode(dom.NodeID(i), css.DefaultStyle()))
+	}
+	// Remove the first and last children
+	tree.removeNodes([]bool{false, true, false, false, true})
+
+	var got []dom.NodeID
+	root := &tree.Nodes[tree.Root]
+	for id := root.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		if tree.Nodes[id].ID != id {
+			t.Errorf("expected node %d to be renumbered, got %d", id, tree.Nodes[id].ID)
+		}
+		got = append(got, tree.Nodes[id].DomNode)
+	}
+	if len(tree.Nodes) != 3 || len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("expected the middle children left, got %v of %d nodes", got, len(tree.Nodes))
+	}
+	if last := tree.Nodes[root.LastChild].DomNode; last != 3 {
+		t.Errorf("expected the last child to be updated, got %d", last)
+	}
+}
+
+func TestBuildKeepsNodePointersStable(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div><p>a</p><p>b</p></div><div>c</div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	tree := BuildLayoutTree(d, nil)
+	if cap(tree.Nodes) != len(d.Nodes) {
+		t.Errorf("expected the node slice to be reserved for %d nodes, got capacity %d", len(d.Nodes), cap(tree.Nodes))
+	}
+}
+
+func TestDeeplyNestedDocument(t *testing.T) {
+	const depth = 100000
+	input := "<html><body>" + strings.Repeat("<div>", depth) + "leaf" + strings.Repeat("</div>", depth) + "</body></html>"
+	d, err := dom.ParseString(input)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	tree := BuildLayoutTree(d, mustParseCSS(t, "div { padding: 1px; }"))
+	ComputeLayout(tree, 800, 600)
+	if len(tree.Nodes) != depth+2 {
+		t.Errorf("expected %d layout nodes, got %d", depth+2, len(tree.Nodes))
+	}
+
+	// The leaf sits inside one pixel of padding per level
+	leaf := tree.Nodes[len(tree.Nodes)-1]
+	if leaf.Rect.Y != depth {
+		t.Errorf("expected the leaf at y=%d, got %.0f", depth, leaf.Rect.Y)
+	}
+}
+
+func TestFrozenTreesRenderInParallel(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div class="a"><p>one</p><p>two</p></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	stylesheet, err := css.Parse(`.a { padding: 4px; } p { margin: 2px; }`)
+	if err != nil {
+		t.Fatalf("css parse error: %v", err)
+	}
+	d.Freeze()
+
+	want := BuildLayoutTree(d, stylesheet)
+	ComputeLayout(want, 800, 600)
+	want.Freeze()
+
+	var wg sync.WaitGroup
+	dumps := make([]string, 8)
+	for i := range dumps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tree := BuildLayoutTree(d, stylesheet)
+			ComputeLayout(tree, 800, 600)
+			tree.Freeze()
+			dumps[i] = tree.Dump()
+		}()
+	}
+	wg.Wait()
+
+	for i, got := range dumps {
+		if got != want.Dump() {
+			t.Errorf("goroutine %d: layout differs from serial layout", i)
+		}
+	}
+}
+
+func TestFrozenTreeRejectsLayout(t *testing.T) {
+	tree := NewLayoutTree()
+	tree.Root = tree.CreateNode(dom.InvalidNodeID, css.DefaultStyle())
+	tree.Freeze()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic when laying out a frozen tree")
+		}
+	}()
+	ComputeLayout(tree, 800, 600)
+}
+
+// benchTreeHTML is a page of nested sections with several paragraphs each,
+// for the tree benchmarks
+var benchTreeHTML = "<html><body>" + strings.Repeat("<section><div><p>one <b>two</b></p><p>three</p><p>four</p></div></section>", 200) + "</body></html>"
+
+func BenchmarkCreateNodes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tree := NewLayoutTree()
+		tree.Root = tree.CreateNode(dom.InvalidNodeID, css.DefaultStyle())
+		for j := 0; j < 100; j++ {
+			parent := tree.CreateNode(dom.InvalidNodeID, css.DefaultStyle())
+			tree.AppendChild(tree.Root, parent)
+			for k := 0; k < 10; k++ {
+				tree.AppendChild(parent, tree.CreateNode(dom.InvalidNodeID, css.DefaultStyle()))
+			}
+		}
+	}
+}
+
+func BenchmarkWalkChildren(b *testing.B) {
+	d, err := dom.ParseString(benchTreeHTML)
+	if err != nil {
+		b.Fatalf("parse error: %v", err)
+	}
+	tree := BuildLayoutTree(d, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stack := []LayoutNodeID{tree.Root}
+		for len(stack) > 0 {
+			node := &tree.Nodes[stack[len(stack)-1]]
+			stack = stack[:len(stack)-1]
+			for id := node.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+				stack = append(stack, id)
+			}
+		}
+	}
+}
+
+func BenchmarkBuildLayoutTree(b *testing.B) {
+	d, err := dom.ParseString(benchTreeHTML)
+	if err != nil {
+		b.Fatalf("parse error: %v", err)
+	}
+	sheet, err := css.Parse("section { padding: 4px; } p { margin: 2px 0; } b { color: red; }")
+	if err != nil {
+		b.Fatalf("css parse error: %v", err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		BuildLayoutTree(d, sheet)
+	}
+}
+
+func BenchmarkComputeLayout(b *testing.B) {
+	d, err := dom.ParseString(benchTreeHTML)
+	if err != nil {
+		b.Fatalf("parse error: %v", err)
+	}
+	sheet, err := css.Parse("section { padding: 4px; } p { margin: 2px 0; } b { color: red; }")
+	if err != nil {
+		b.Fatalf("css parse error: %v", err)
+	}
+	tree := BuildLayoutTree(d, sheet)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ComputeLayout(tree, 800, 600)
+	}
+}
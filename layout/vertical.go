@@ -0,0 +1,120 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/text"
+)
+
+// A box in a vertical writing mode lays out its children as columns, from
+// its right side for vertical-rl and from its left for vertical-lr. Each
+// column is as high as the box's content, and as wide as the child's
+// content across: its lines of text, a line height each, and the columns
+// of its own children. What is laid out down a column doesn't wrap, so a
+// vertical box without a height of its own is as high as its longest
+// column.
+
+// verticalTextHeight returns how far the lines of a text node in a
+// vertical writing mode reach down the page
+func verticalTextHeight(node *LayoutNode) float32 {
+	font := text.FontOf(&node.Style)
+	var h float32
+	for line := range strings.SplitSeq(node.Text, "\n") {
+		h = max(h, text.VerticalAdvance(line, font))
+	}
+	return h + node.Style.Padding.Top + node.Style.Padding.Bottom
+}
+
+// verticalHeight returns the estimated height of an element in a vertical
+// writing mode, with those of its children in heights: its longest column
+func verticalHeight(tree *LayoutTree, node *LayoutNode, heights []float32) float32 {
+	var h float32
+	for id := node.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		child := &tree.Nodes[id]
+		if inFlow(child) {
+			h = max(h, child.Style.Margin.Top+heights[id]+child.Style.Margin.Bottom)
+		}
+	}
+	return h + node.Style.Padding.Top + node.Style.Padding.Bottom
+}
+
+// verticalWidth returns how wide a box is as a column of a vertical box,
+// its margins included. A box in a horizontal writing mode is as wide as
+// its content unwrapped.
+func verticalWidth(tree *LayoutTree, id LayoutNodeID, heights []float32) float32 {
+	// Widths are summed bottom-up over the subtree's pre-order, rather than
+	// recursively, as heights are estimated
+	var order []LayoutNodeID
+	Walk(tree, id, func(node *LayoutNode, depth int) WalkAction {
+		order = append(order, node.ID)
+		if node.ID != id && !node.Style.WritingMode.Vertical() {
+			return WalkSkipChildren
+		}
+		return WalkContinue
+	})
+	widths := make(map[LayoutNodeID]float32, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		node := &tree.Nodes[order[i]]
+		s := &node.Style
+		var w float32
+		switch {
+		case !s.WritingMode.Vertical():
+			w = maxContentWidth(tree, node.ID, heights)
+			widths[node.ID] = w
+			continue
+		case s.Width != nil:
+			w, _ = setWidth(s)
+		case node.Text != "":
+			w = float32(strings.Count(node.Text, "\n")+1)*LineHeight(*s) + s.Padding.Left + s.Padding.Right
+		default:
+			for child := node.FirstChild; child != InvalidLayoutNodeID; child = tree.Nodes[child].NextSibling {
+				if inFlow(&tree.Nodes[child]) {
+					w += widths[child]
+				}
+			}
+			w += s.Padding.Left + s.Padding.Right
+		}
+		widths[node.ID] = s.Margin.Left + w + s.Margin.Right
+	}
+	return widths[id]
+}
+
+// layoutVertical positions the children of a box in a vertical writing
+// mode, whose content box is at (x, y) and w wide, as columns as high as
+// the box's content, recording them in trace if it is not nil
+func layoutVertical(tree *LayoutTree, node *LayoutNode, x, y, w float32, heights []float32, trace *Trace) {
+	s := &node.Style
+	h := node.Rect.H - s.Padding.Top - s.Padding.Bottom
+	cx := x + w
+	if s.WritingMode == css.VerticalLR {
+		cx = x
+	}
+	for id := node.FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		child := &tree.Nodes[id]
+		if !inFlow(child) {
+			continue
+		}
+		cw := verticalWidth(tree, id, heights)
+		if s.WritingMode == css.VerticalRL {
+			cx -= cw
+		}
+		if trace != nil {
+			trace.record(child, traceVertical, Rect{cx, y, cw, h}, heights)
+		}
+
+		child.Rect.X = cx + child.Style.Margin.Left
+		child.Rect.Y = y + child.Style.Margin.Top
+		child.Rect.W = cw - child.Style.Margin.Left - child.Style.Margin.Right
+		// A vertical column stretches down the box; a horizontal box is
+		// as high as its content
+		child.Rect.H = heights[id]
+		if child.Style.WritingMode.Vertical() && child.Style.Height == nil {
+			child.Rect.H = max(heights[id], h-child.Style.Margin.Top-child.Style.Margin.Bottom)
+		}
+
+		if s.WritingMode == css.VerticalLR {
+			cx += cw
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestVerticalLayout(t *testing.T) {
+	for _, tt := range []struct {
+		mode        string
+		rightToLeft bool
+	}{
+		{"vertical-rl", true},
+		{"vertical-lr", false},
+	} {
+		t.Run(tt.mode, func(t *testing.T) {
+			d, err := dom.ParseString(`<html><body><div id="v"><p id="a">one</p><p id="b">two<span>three</span></p></div><p id="after">after</p></body></html>`)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			tree := BuildLayoutTree(d, mustParseCSS(t, `body, p { margin: 0; } #v { writing-mode: `+tt.mode+`; }`))
+			ComputeLayout(tree, 800, 600)
+
+			node := func(id string) *LayoutNode {
+				return &tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))]
+			}
+			v, a, b, after := node("v").Rect, node("a").Rect, node("b").Rect, node("after").Rect
+
+			// The paragraphs are columns side by side, a line height wide
+			// for each line of text in them
+			line := LineHeight(node("a").Style)
+			if a.W != line || b.W != 2*line {
+				t.Errorf("expected columns a line of text wide each, got %v and %v", a, b)
+			}
+			if tt.rightToLeft && (a.X != v.X+v.W-a.W || b.X != a.X-b.W) {
+				t.Errorf("expected columns from the right, got %v and %v in %v", a, b, v)
+			}
+			if !tt.rightToLeft && (a.X != v.X || b.X != a.X+a.W) {
+				t.Errorf("expected columns from the left, got %v and %v in %v", a, b, v)
+			}
+
+			// The box is as wide as its columns and as high as the longest,
+			// which both stretch down it
+			if v.W != a.W+b.W {
+				t.Errorf("expected the box as wide as its columns, got %v", v)
+			}
+			if a.H != v.H || b.H != v.H || v.H <= 0 {
+				t.Errorf("expected the columns as high as the box, got %v and %v in %v", a, b, v)
+			}
+			if after.Y != v.Y+v.H {
+				t.Errorf("expected the next block below the box, got %v after %v", after, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package layout
+
+import "slices"
+
+// WalkAction tells Walk how to continue after visiting a node
+type WalkAction int
+
+const (
+	WalkContinue     WalkAction = iota // visit the node's children next
+	WalkSkipChildren                   // don't descend into the node
+	WalkStop                           // end the walk
+)
+
+// Walk visits the subtree rooted at root in tree order, passing each node and
+// its depth below root. It uses an explicit stack, so arbitrarily deep trees
+// can't overflow the goroutine stack.
+func Walk(tree *LayoutTree, root LayoutNodeID, visit func(node *LayoutNode, depth int) WalkAction) {
+	type frame struct {
+		id    LayoutNodeID
+		depth int
+	}
+	stack := []frame{{root, 0}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		node := tree.GetNode(f.id)
+		if node == nil {
+			continue
+		}
+
+		switch visit(node, f.depth) {
+		case WalkStop:
+			return
+		case WalkSkipChildren:
+			continue
+		}
+
+		// Push children in reverse so that the first child is visited first
+		mark := len(stack)
+		for childID := node.FirstChild; childID != InvalidLayoutNodeID; childID = tree.Nodes[childID].NextSibling {
+			stack = append(stack, frame{childID, f.depth + 1})
+		}
+		slices.Reverse(stack[mark:])
+	}
+}
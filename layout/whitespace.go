@@ -0,0 +1,135 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/myuon/penny/css"
+)
+
+// Whitespace collapses in two steps. As a text node is built, each run of
+// whitespace in it becomes a single space, and line breaks become spaces
+// unless white-space keeps them. Once the tree is built, and the boxes
+// around each text are known, passCollapseSpaces removes the spaces that
+// collapse with the one before them or are at the start or end of a line,
+// and the text nodes they leave empty.
+
+// collapseSpaces returns the text of a text node with its whitespace
+// collapsed as its white-space says, keeping a space at either end for
+// passCollapseSpaces to settle
+func collapseSpaces(s string, ws css.WhiteSpace) string {
+	switch {
+	case ws.KeepsSpaces():
+		return s
+	case !ws.KeepsLineBreaks():
+		return collapseRuns(s)
+	}
+	// Spaces around a kept line break collapse into it
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = collapseRuns(line)
+		if i > 0 {
+			line = strings.TrimPrefix(line, " ")
+		}
+		if i < len(lines)-1 {
+			line = strings.TrimSuffix(line, " ")
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// collapseRuns replaces each run of whitespace in s with a single space
+func collapseRuns(s string) string {
+	// Whitespace between elements collapses to a space whatever its length
+	if s != "" && strings.Trim(s, collapsible) == "" {
+		return " "
+	}
+	// Most text has no runs to collapse and is returned without copying
+	collapsed := true
+	for i := 0; i < len(s); i++ {
+		if isCollapsible(s[i]) && (s[i] != ' ' || i+1 < len(s) && isCollapsible(s[i+1])) {
+			collapsed = false
+			break
+		}
+	}
+	if collapsed {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if !isCollapsible(s[i]) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		sb.WriteByte(' ')
+		for i+1 < len(s) && isCollapsible(s[i+1]) {
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// collapsible is the whitespace that collapses: ASCII spaces, tabs, line
+// breaks and form feeds, but not other spaces such as no-break spaces
+const collapsible = " \t\n\r\f"
+
+func isCollapsible(ch byte) bool {
+	return strings.IndexByte(collapsible, ch) >= 0
+}
+
+// passCollapseSpaces removes the spaces of text nodes that collapse. Text
+// laid out a node per line loses the spaces at its ends. In a run laid out
+// in line boxes, a space collapses at the start and end of the run and
+// after another space, even one in the text before; the spaces next to an
+// atomic inline box are kept, so two of them are a space apart. Text nodes
+// left empty are removed from the tree.
+func passCollapseSpaces(tree *LayoutTree) {
+	var drop []bool
+	set := func(node *LayoutNode, text string) {
+		node.Text = text
+		if text == "" {
+			if drop == nil {
+				drop = make([]bool, len(tree.Nodes))
+			}
+			drop[node.ID] = true
+		}
+	}
+	for i := range tree.Nodes {
+		for id := tree.Nodes[i].FirstChild; id != InvalidLayoutNodeID; {
+			end, atomic := inlineRun(tree, id)
+			if end == id {
+				id = tree.Nodes[id].NextSibling
+				continue
+			}
+			// space is set after a space that the next collapses with, as
+			// the start of a line is; last is the text ending the run so
+			// far, if its trailing space collapses
+			space := true
+			var last *LayoutNode
+			for ; id != end; id = tree.Nodes[id].NextSibling {
+				node := &tree.Nodes[id]
+				switch {
+				case node.Text == "" || node.Style.WhiteSpace.KeepsSpaces():
+					space, last = false, nil
+				case !atomic:
+					set(node, strings.Trim(node.Text, " "))
+				default:
+					if space {
+						set(node, strings.TrimPrefix(node.Text, " "))
+					}
+					if node.Text != "" {
+						space, last = strings.HasSuffix(node.Text, " "), node
+					}
+				}
+			}
+			if last != nil {
+				set(last, strings.TrimSuffix(last.Text, " "))
+			}
+		}
+	}
+	if drop != nil {
+		tree.removeNodes(drop)
+	}
+}
@@ -0,0 +1,108 @@
+package layout
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/text"
+)
+
+func TestCollapseSpaces(t *testing.T) {
+	tests := []struct {
+		in   string
+		ws   css.WhiteSpace
+		want string
+	}{
+		{"one two", css.WhiteSpaceNormal, "one two"},
+		{"  one \n\t two  ", css.WhiteSpaceNormal, " one two "},
+		{"one\u00a0\u00a0two", css.WhiteSpaceNormal, "one\u00a0\u00a0two"},
+		{" \n\t ", css.WhiteSpaceNowrap, " "},
+		{"  one \n\t two  ", css.WhiteSpacePre, "  one \n\t two  "},
+		{"  one \n\t two  ", css.WhiteSpacePreWrap, "  one \n\t two  "},
+		{"  one  two \n\t three  ", css.WhiteSpacePreLine, " one two\nthree "},
+		{"one\n\ntwo", css.WhiteSpacePreLine, "one\n\ntwo"},
+	}
+	for _, tt := range tests {
+		if got := collapseSpaces(tt.in, tt.ws); got != tt.want {
+			t.Errorf("collapseSpaces(%q, %v) = %q, want %q", tt.in, tt.ws, got, tt.want)
+		}
+	}
+}
+
+func TestWhiteSpaceLayout(t *testing.T) {
+	d, err := dom.ParseString("<html><body><p id=\"p\">  one <b>two</b>\n three  </p><pre id=\"pre\">\n  a\n  b</pre><div id=\"d\"> x </div></body></html>")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `#d { white-space: pre; }`)
+	sheet.Append(css.UserAgentStylesheet())
+	tree := BuildLayoutTree(d, sheet)
+
+	texts := func(id string) []string {
+		var texts []string
+		Walk(tree, layoutNodeOf(t, tree, findElement(t, d, id)), func(node *LayoutNode, depth int) WalkAction {
+			if node.Text != "" {
+				texts = append(texts, node.Text)
+			}
+			return WalkContinue
+		})
+		return texts
+	}
+	// Spaces collapse in a paragraph, and are kept in a <pre> and where
+	// white-space: pre is set
+	for id, want := range map[string][]string{
+		"p":   {"one", "two", "three"},
+		"pre": {"  a\n  b"},
+		"d":   {" x "},
+	} {
+		got := texts(id)
+		if len(got) != len(want) {
+			t.Errorf("expected %q in #%s, got %q", want, id, got)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("expected %q in #%s, got %q", want, id, got)
+				break
+			}
+		}
+	}
+}
+
+func TestCollapseSpacesAroundInlineBoxes(t *testing.T) {
+	d, err := dom.ParseString("<html><body>\n  <p id=\"p\">\n  <span id=\"a\">A</span>\n  <span id=\"b\">B</span>  tail  <span id=\"c\">C</span>\n</p>\n</body></html>")
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	sheet := mustParseCSS(t, `body, p { margin: 0; } span { display: inline-block; }`)
+	tree := BuildLayoutTree(d, sheet)
+	ComputeLayout(tree, 800, 600)
+
+	p := layoutNodeOf(t, tree, findElement(t, d, "p"))
+	var texts []string
+	for id := tree.Nodes[p].FirstChild; id != InvalidLayoutNodeID; id = tree.Nodes[id].NextSibling {
+		if node := &tree.Nodes[id]; node.Text != "" {
+			texts = append(texts, node.Text)
+		}
+	}
+	// The whitespace at the ends of the line is gone, and each run between
+	// the boxes is a single space
+	if want := []string{" ", " tail "}; strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %q between the boxes, got %q", want, texts)
+	}
+	rect := func(id string) Rect {
+		return tree.Nodes[layoutNodeOf(t, tree, findElement(t, d, id))].Rect
+	}
+	a, b := rect("a"), rect("b")
+	space := text.Width(" ", text.FontOf(&tree.Nodes[p].Style))
+	if a.X != 0 || b.X != a.X+a.W+space {
+		t.Errorf("expected the boxes a space apart from the start of the line, got %v and %v", a, b)
+	}
+	// Whitespace-only text between blocks isn't laid out
+	body := tree.Nodes[tree.Root]
+	if body.FirstChild != p || tree.Nodes[p].NextSibling != InvalidLayoutNodeID {
+		t.Error("expected the whitespace around the paragraph to be removed")
+	}
+}
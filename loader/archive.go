@@ -0,0 +1,137 @@
+package loader
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// archiveIndex is the file of an archive that lists what it holds
+const archiveIndex = "index.json"
+
+// ErrNotArchived is returned when replaying a URL that wasn't recorded
+var ErrNotArchived = errors.New("not in the archive")
+
+// Archive is a directory of recorded resources, so that a page loaded from
+// the web once can be loaded again exactly as it was. Each body is a file of
+// its own, and index.json maps the URLs to them. It is safe for concurrent
+// use.
+type Archive struct {
+	dir string
+
+	mu      sync.Mutex
+	entries map[string]archiveEntry // by URL
+}
+
+// archiveEntry is how a recorded fetch ended: with the body kept in File,
+// or with Error
+type archiveEntry struct {
+	File  string `json:"file,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// CreateArchive creates an archive to record into, in place of any archive
+// in dir before
+func CreateArchive(dir string) (*Archive, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	a := &Archive{dir: dir, entries: map[string]archiveEntry{}}
+	return a, a.writeIndex()
+}
+
+// OpenArchive opens a recorded archive to replay
+func OpenArchive(dir string) (*Archive, error) {
+	data, err := os.ReadFile(filepath.Join(dir, archiveIndex))
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{dir: dir}
+	if err := json.Unmarshal(data, &a.entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", archiveIndex, err)
+	}
+	return a, nil
+}
+
+// Record returns a Getter that gets with get and records every URL it is
+// asked for, failures included, so that they replay the same
+func (a *Archive) Record(get Getter) Getter {
+	return func(u string) ([]byte, error) {
+		data, err := get(u)
+		if rerr := a.record(u, data, err); rerr != nil {
+			return nil, fmt.Errorf("recording %s: %w", u, rerr)
+		}
+		return data, err
+	}
+}
+
+func (a *Archive) record(u string, data []byte, fetchErr error) error {
+	var entry archiveEntry
+	if fetchErr != nil {
+		entry.Error = fetchErr.Error()
+	} else {
+		sum := sha256.Sum256([]byte(u))
+		entry.File = hex.EncodeToString(sum[:8])
+		if err := os.WriteFile(filepath.Join(a.dir, entry.File), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries[u] = entry
+	return a.writeIndex()
+}
+
+// writeIndex saves the index. It is written after every fetch, so an
+// archive stays complete when recording is cut short. a.mu must be held.
+func (a *Archive) writeIndex() error {
+	data, err := json.MarshalIndent(a.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(a.dir, archiveIndex), data, 0644)
+}
+
+// Get replays a URL: it returns the body or the error recorded for it, or
+// ErrNotArchived. It is a Getter.
+func (a *Archive) Get(u string) ([]byte, error) {
+	a.mu.Lock()
+	entry, ok := a.entries[u]
+	a.mu.Unlock()
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%s: %w", u, ErrNotArchived)
+	case entry.Error != "":
+		return nil, errors.New(entry.Error)
+	}
+	return os.ReadFile(filepath.Join(a.dir, entry.File))
+}
+
+// Handler serves the archive to a browser that loads a recorded page from it
+// in place of origin: a request is answered with the recording of the same
+// path and query on origin. A failed or missing recording is a 404.
+func (a *Archive) Handler(origin *url.URL) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := *origin
+		u.Path, u.RawPath, u.RawQuery = r.URL.Path, r.URL.RawPath, r.URL.RawQuery
+		data, err := a.Get(u.String())
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, u.Path, time.Time{}, bytes.NewReader(data))
+	})
+}
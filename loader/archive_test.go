@@ -0,0 +1,77 @@
+package loader
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveRecordAndReplay(t *testing.T) {
+	pages := map[string]string{
+		"https://example.com/":          "<p>page</p>",
+		"https://example.com/style.css": "p { color: red; }",
+	}
+	fetches := 0
+	web := func(u string) ([]byte, error) {
+		fetches++
+		if body, ok := pages[u]; ok {
+			return []byte(body), nil
+		}
+		return nil, errors.New("HTTP 404 Not Found")
+	}
+
+	dir := filepath.Join(t.TempDir(), "archive")
+	archive, err := CreateArchive(dir)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	base, _ := url.Parse("https://example.com/")
+	fetch := HTTP(base, archive.Record(web))
+	for _, ref := range []string{"/", "style.css", "missing.png"} {
+		fetch(ref)
+	}
+
+	replay, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for u, want := range pages {
+		if got, err := replay.Get(u); err != nil || string(got) != want {
+			t.Errorf("%s: expected %q, got %q %v", u, want, got, err)
+		}
+	}
+	if _, err := replay.Get("https://example.com/missing.png"); err == nil || errors.Is(err, ErrNotArchived) {
+		t.Errorf("expected the recorded failure, got %v", err)
+	}
+	if _, err := replay.Get("https://example.com/other.css"); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("expected a URL that wasn't recorded to fail, got %v", err)
+	}
+	if fetches != 3 {
+		t.Errorf("expected replaying to fetch nothing, got %d fetches", fetches)
+	}
+
+	// A browser loads the page from a server standing in for the origin
+	server := httptest.NewServer(replay.Handler(base))
+	defer server.Close()
+	for path, want := range map[string]string{"/style.css": pages["https://example.com/style.css"], "/missing.png": ""} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want == "" {
+			if resp.StatusCode != http.StatusNotFound {
+				t.Errorf("%s: expected 404, got %d", path, resp.StatusCode)
+			}
+			continue
+		}
+		if string(body) != want || resp.Header.Get("Content-Type") != "text/css; charset=utf-8" {
+			t.Errorf("%s: expected %q as CSS, got %q %s", path, want, body, resp.Header.Get("Content-Type"))
+		}
+	}
+}
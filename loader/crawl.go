@@ -0,0 +1,144 @@
+package loader
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/myuon/penny/dom"
+)
+
+// ErrNotHTML is returned for a linked page that isn't an HTML document
+var ErrNotHTML = errors.New("not an HTML page")
+
+// ErrDisallowed is returned for a page the site's robots.txt asks crawlers
+// not to fetch
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// Page is a page a Crawler reached
+type Page struct {
+	URL   *url.URL
+	Depth int // how many links away from the first page it is
+	Body  []byte
+	Err   error // why the page couldn't be got, with no Body
+}
+
+// Crawler follows the links of a site breadth first, from one page to the
+// pages of the same origin it links to, and on to the pages they link to up
+// to Depth links away. It honours the site's robots.txt and waits between
+// fetches, so as not to load the site.
+type Crawler struct {
+	Get   Getter
+	Agent string // the name robots.txt rules are chosen by
+	Depth int
+	// MaxPages stops the crawl after that many pages, if not 0
+	MaxPages int
+	// Delay is the least time between fetches. The robots.txt Crawl-delay
+	// makes it longer.
+	Delay time.Duration
+}
+
+// Crawl crawls from start. Each page reached is passed to visit, which
+// returns the links to follow from it; a page that couldn't be got comes with
+// its Err set and its links are ignored. Pages are visited once each, in
+// order of depth.
+func (c *Crawler) Crawl(start *url.URL, visit func(Page) []*url.URL) {
+	robots := c.robots(start)
+	delay := c.Delay
+	if robots.Delay > delay {
+		delay = robots.Delay
+	}
+
+	seen := map[string]bool{}
+	queue := []Page{{URL: pageURL(start)}}
+	seen[queue[0].URL.String()] = true
+	var last time.Time
+	for visited := 0; len(queue) > 0; visited++ {
+		if c.MaxPages > 0 && visited == c.MaxPages {
+			return
+		}
+		page := queue[0]
+		queue = queue[1:]
+
+		if !robots.Allowed(page.URL.RequestURI()) {
+			page.Err = ErrDisallowed
+		} else {
+			if wait := delay - time.Since(last); wait > 0 {
+				time.Sleep(wait)
+			}
+			page.Body, page.Err = c.Get(page.URL.String())
+			last = time.Now()
+			if page.Err == nil && !isHTML(page.Body) {
+				page.Body, page.Err = nil, ErrNotHTML
+			}
+		}
+
+		links := visit(page)
+		if page.Err != nil || page.Depth == c.Depth {
+			continue
+		}
+		for _, link := range links {
+			if !sameOrigin(link, start) {
+				continue
+			}
+			link = pageURL(link)
+			if key := link.String(); !seen[key] {
+				seen[key] = true
+				queue = append(queue, Page{URL: link, Depth: page.Depth + 1})
+			}
+		}
+	}
+}
+
+// robots gets the robots.txt of the origin of u. A site without one may be
+// crawled everywhere.
+func (c *Crawler) robots(u *url.URL) *Robots {
+	data, err := c.Get((&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}).String())
+	if err != nil {
+		return &Robots{}
+	}
+	return ParseRobots(data, c.Agent)
+}
+
+// Links returns the pages a document links to with <a href>, resolved
+// against base. Links to fragments of the page itself and to other schemes,
+// such as mailto:, are left out.
+func Links(d *dom.DOM, base *url.URL) []*url.URL {
+	var links []*url.URL
+	dom.Walk(d, d.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Type != dom.NodeTypeElement || node.Tag != "a" {
+			return dom.WalkContinue
+		}
+		href, ok := node.Attr["href"]
+		if !ok || strings.HasPrefix(href, "#") {
+			return dom.WalkContinue
+		}
+		u, err := base.Parse(strings.TrimSpace(href))
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			return dom.WalkContinue
+		}
+		links = append(links, u)
+		return dom.WalkContinue
+	})
+	return links
+}
+
+// pageURL returns u without its fragment, which names a part of the same
+// page
+func pageURL(u *url.URL) *url.URL {
+	page := *u
+	page.Fragment, page.RawFragment = "", ""
+	return &page
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && strings.EqualFold(a.Host, b.Host)
+}
+
+// isHTML reports whether a body may be a page, rather than an image or some
+// other binary. HTML that doesn't start with a tag sniffs as plain text.
+func isHTML(body []byte) bool {
+	return strings.HasPrefix(http.DetectContentType(body), "text/")
+}
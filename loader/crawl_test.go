@@ -0,0 +1,76 @@
+package loader
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestCrawl(t *testing.T) {
+	site := map[string]string{
+		"https://example.com/robots.txt": "User-agent: *\nDisallow: /secret\n",
+		"https://example.com/":           `<html><a href="a.html#top">a</a><a href="/b.html">b</a><a href="https://other.com/">x</a><a href="mailto:x@example.com">m</a></html>`,
+		"https://example.com/a.html":     `<html><a href="/">home</a><a href="c.html">c</a><a href="logo.png">logo</a></html>`,
+		"https://example.com/b.html":     `<html><a href="/secret/">s</a></html>`,
+		"https://example.com/c.html":     `<html><a href="d.html">d</a></html>`,
+		"https://example.com/logo.png":   "\x89PNG\r\n\x1a\n",
+	}
+	get := func(u string) ([]byte, error) {
+		body, ok := site[u]
+		if !ok {
+			return nil, ErrNotArchived
+		}
+		return []byte(body), nil
+	}
+
+	type visit struct {
+		url   string
+		depth int
+		err   error
+	}
+	var visits []visit
+	start, _ := url.Parse("https://example.com/")
+	c := &Crawler{Get: get, Agent: "penny", Depth: 2}
+	c.Crawl(start, func(p Page) []*url.URL {
+		visits = append(visits, visit{p.URL.String(), p.Depth, p.Err})
+		if p.Err != nil {
+			return nil
+		}
+		d, err := dom.ParseString(string(p.Body))
+		if err != nil {
+			t.Fatalf("%s: %v", p.URL, err)
+		}
+		return Links(d, p.URL)
+	})
+
+	// d.html is three links away; other.com is another origin
+	want := []visit{
+		{"https://example.com/", 0, nil},
+		{"https://example.com/a.html", 1, nil},
+		{"https://example.com/b.html", 1, nil},
+		{"https://example.com/c.html", 2, nil},
+		{"https://example.com/logo.png", 2, ErrNotHTML},
+		{"https://example.com/secret/", 2, ErrDisallowed},
+	}
+	if len(visits) != len(want) {
+		t.Fatalf("expected %v, got %v", want, visits)
+	}
+	for i := range want {
+		if visits[i].url != want[i].url || visits[i].depth != want[i].depth || !errors.Is(visits[i].err, want[i].err) {
+			t.Errorf("visit %d: expected %v, got %v", i, want[i], visits[i])
+		}
+	}
+
+	visits = nil
+	c.MaxPages = 2
+	c.Crawl(start, func(p Page) []*url.URL {
+		visits = append(visits, visit{url: p.URL.String()})
+		d, _ := dom.ParseString(string(p.Body))
+		return Links(d, p.URL)
+	})
+	if len(visits) != 2 {
+		t.Errorf("expected the crawl to stop after 2 pages, got %v", visits)
+	}
+}
@@ -0,0 +1,104 @@
+package loader
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// client is shared by every fetch, so that connections to a host are kept
+// alive and reused across the resources of a page, and multiplexed over
+// HTTP/2 where the server supports it
+var client = newClient()
+
+// fetchTimeout bounds a whole fetch, reading the body included, so that an
+// origin that stalls can't hold up a page or a crawl forever
+const fetchTimeout = 60 * time.Second
+
+// maxBodyBytes bounds the body of a fetch, so that a huge or endless
+// response fails rather than exhausting memory
+const maxBodyBytes = 64 << 20
+
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			// A host gets no more connections than a loader runs fetches,
+			// and they are all kept for the next page
+			MaxConnsPerHost:     maxConcurrent,
+			MaxIdleConnsPerHost: maxConcurrent,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// Dir returns a Fetch that reads references as paths relative to a
+// directory
+func Dir(baseDir string) Fetch {
+	return func(ref string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(baseDir, ref))
+	}
+}
+
+// UserAgent is what penny calls itself to the servers it fetches from
+const UserAgent = "penny"
+
+// Getter gets the body of an absolute URL
+type Getter func(u string) ([]byte, error)
+
+// HTTP returns a Fetch that resolves references against base and gets them
+// with get
+func HTTP(base *url.URL, get Getter) Fetch {
+	return func(ref string) ([]byte, error) {
+		u, err := base.Parse(ref)
+		if err != nil {
+			return nil, err
+		}
+		return get(u.String())
+	}
+}
+
+// Web gets a URL over HTTP, on the connections shared by every fetch. It is
+// a Getter.
+func Web(u string) ([]byte, error) {
+	return get(client, u)
+}
+
+func get(c *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Drain the body so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBodyBytes {
+		return nil, fmt.Errorf("%s: body larger than %d bytes", u, maxBodyBytes)
+	}
+	return body, nil
+}
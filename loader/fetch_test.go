@@ -0,0 +1,112 @@
+package loader
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPReusesConnections(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	protos := map[string]bool{}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		protos[r.Proto] = true
+		mu.Unlock()
+		if r.URL.Path == "/missing.css" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	ts.EnableHTTP2 = true
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	c := newClient()
+	c.Transport.(*http.Transport).TLSClientConfig = ts.Client().Transport.(*http.Transport).TLSClientConfig
+	base, _ := url.Parse(ts.URL + "/page/")
+	for _, ref := range []string{"a.css", "../b.css", "/missing.css", "c.png"} {
+		u, _ := base.Parse(ref)
+		data, err := get(c, u.String())
+		if ref == "/missing.css" {
+			if err == nil || err.Error() != "HTTP 404 Not Found" {
+				t.Errorf("expected an error for a missing resource, got %v", err)
+			}
+			continue
+		}
+		if err != nil || string(data) != u.Path {
+			t.Errorf("%s: expected %s, got %q %v", ref, u.Path, data, err)
+		}
+	}
+
+	if conns != 1 {
+		t.Errorf("expected the fetches to share a connection, got %d", conns)
+	}
+	if !protos["HTTP/2.0"] || len(protos) != 1 {
+		t.Errorf("expected HTTP/2, got %v", protos)
+	}
+}
+
+func TestGetTimesOut(t *testing.T) {
+	stall := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			w.Write([]byte("part"))
+			w.(http.Flusher).Flush()
+		}
+		<-stall
+	}))
+	defer ts.Close()
+	defer close(stall)
+
+	// A server that stalls before its headers or in its body fails the
+	// fetch rather than holding it
+	c := newClient()
+	c.Transport.(*http.Transport).ResponseHeaderTimeout = 50 * time.Millisecond
+	if _, err := get(c, ts.URL+"/headers"); err == nil {
+		t.Error("expected a fetch without headers to time out")
+	}
+	c.Timeout = 100 * time.Millisecond
+	if _, err := get(c, ts.URL+"/body"); err == nil {
+		t.Error("expected a fetch with a stalled body to time out")
+	}
+}
+
+func TestGetStatusAndSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/partial":
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte("part"))
+		case "/huge":
+			w.Write(make([]byte, maxBodyBytes+1))
+		}
+	}))
+	defer ts.Close()
+
+	c := newClient()
+	if data, err := get(c, ts.URL+"/empty"); err != nil || len(data) != 0 {
+		t.Errorf("expected an empty body for 204, got %q %v", data, err)
+	}
+	if data, err := get(c, ts.URL+"/partial"); err != nil || string(data) != "part" {
+		t.Errorf("expected the body of a 206, got %q %v", data, err)
+	}
+	if _, err := get(c, ts.URL+"/huge"); err == nil {
+		t.Error("expected a body over the limit to fail")
+	}
+}
@@ -0,0 +1,150 @@
+package loader
+
+import (
+	"strings"
+
+	"github.com/myuon/penny/dom"
+)
+
+// Kind is what a sub-resource is used as
+type Kind int
+
+const (
+	KindOther Kind = iota
+	KindStylesheet
+	KindFont
+	KindImage
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindStylesheet:
+		return "stylesheet"
+	case KindFont:
+		return "font"
+	case KindImage:
+		return "image"
+	default:
+		return "other"
+	}
+}
+
+// Hint is how a page asked for a resource ahead of its use
+type Hint int
+
+const (
+	HintNone Hint = iota
+	// HintPreload is <link rel=preload>: the page needs the resource soon
+	HintPreload
+	// HintPrefetch is <link rel=prefetch>: a later page may need it
+	HintPrefetch
+)
+
+// Priority orders fetches, the lowest value first
+type Priority int
+
+const (
+	// PriorityHighest is for stylesheets, which block rendering
+	PriorityHighest Priority = iota
+	// PriorityHigh is for fonts, which text can't be measured without
+	PriorityHigh
+	// PriorityMedium is for images the page preloads
+	PriorityMedium
+	PriorityLow
+	// PriorityIdle is for prefetches, fetched when nothing else is waiting
+	PriorityIdle
+)
+
+// Resource is a sub-resource a document references
+type Resource struct {
+	Ref  string // as written in the document
+	Kind Kind
+	Hint Hint
+}
+
+// Priority returns how soon the resource should be fetched. Stylesheets and
+// fonts come before images; a preload hint moves an image ahead of the
+// others, and a prefetch comes after everything the page itself uses.
+func (r Resource) Priority() Priority {
+	if r.Hint == HintPrefetch {
+		return PriorityIdle
+	}
+	switch r.Kind {
+	case KindStylesheet:
+		return PriorityHighest
+	case KindFont:
+		return PriorityHigh
+	case KindImage:
+		if r.Hint == HintPreload {
+			return PriorityMedium
+		}
+		return PriorityLow
+	default:
+		return PriorityIdle
+	}
+}
+
+// Discover returns the sub-resources a document references, in document
+// order: linked stylesheets, images, and the resources of preload and
+// prefetch hints. A resource referenced more than once is listed once, at
+// its first reference, with the most urgent of its hints.
+func Discover(d *dom.DOM) []Resource {
+	var resources []Resource
+	index := map[string]int{}
+	add := func(r Resource) {
+		if r.Ref == "" || strings.HasPrefix(r.Ref, "data:") {
+			return
+		}
+		i, ok := index[r.Ref]
+		if !ok {
+			index[r.Ref] = len(resources)
+			resources = append(resources, r)
+			return
+		}
+		if r.Priority() < resources[i].Priority() {
+			resources[i].Kind, resources[i].Hint = r.Kind, r.Hint
+		}
+	}
+
+	dom.Walk(d, d.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Type != dom.NodeTypeElement {
+			return dom.WalkContinue
+		}
+		switch node.Tag {
+		case "img":
+			add(Resource{Ref: node.Attr["src"], Kind: KindImage})
+		case "link":
+			href := node.Attr["href"]
+			for _, rel := range strings.Fields(strings.ToLower(node.Attr["rel"])) {
+				switch rel {
+				case "stylesheet":
+					add(Resource{Ref: href, Kind: KindStylesheet})
+				case "preload":
+					// A preload of a kind penny doesn't use, such as a
+					// script, isn't fetched
+					if kind := kindOf(node.Attr["as"]); kind != KindOther {
+						add(Resource{Ref: href, Kind: kind, Hint: HintPreload})
+					}
+				case "prefetch":
+					add(Resource{Ref: href, Kind: kindOf(node.Attr["as"]), Hint: HintPrefetch})
+				}
+			}
+		}
+		return dom.WalkContinue
+	})
+	return resources
+}
+
+// kindOf returns the kind of the as attribute of a hint
+func kindOf(as string) Kind {
+	switch strings.ToLower(as) {
+	case "style":
+		return KindStylesheet
+	case "font":
+		return KindFont
+	case "image":
+		return KindImage
+	default:
+		return KindOther
+	}
+}
@@ -0,0 +1,53 @@
+package loader
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+func TestDiscover(t *testing.T) {
+	d, err := dom.ParseString(`<html><head>` +
+		`<link rel="preload" href="hero.png" as="image">` +
+		`<link rel="preload" href="app.js" as="script">` +
+		`<link rel="Preload" href="font.woff2" as="font" crossorigin>` +
+		`<link rel="prefetch" href="next.html">` +
+		`<link rel="stylesheet" href="site.css">` +
+		`</head><body><img src="hero.png"><img src="icon.png"><img src="data:image/gif;base64,R0lG">` +
+		`</body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	want := []Resource{
+		{Ref: "hero.png", Kind: KindImage, Hint: HintPreload},
+		{Ref: "font.woff2", Kind: KindFont, Hint: HintPreload},
+		{Ref: "next.html", Kind: KindOther, Hint: HintPrefetch},
+		{Ref: "site.css", Kind: KindStylesheet},
+		{Ref: "icon.png", Kind: KindImage},
+	}
+	got := Discover(d)
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("resource %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestPriority(t *testing.T) {
+	order := []Resource{
+		{Kind: KindStylesheet},
+		{Kind: KindFont, Hint: HintPreload},
+		{Kind: KindImage, Hint: HintPreload},
+		{Kind: KindImage},
+		{Kind: KindStylesheet, Hint: HintPrefetch},
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i-1].Priority() >= order[i].Priority() {
+			t.Errorf("expected %v before %v", order[i-1], order[i])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package loader fetches the sub-resources of a page, such as stylesheets,
+// fonts and images, in the order rendering needs them
+package loader
+
+import (
+	"slices"
+	"sync"
+)
+
+// maxConcurrent is how many fetches a Loader runs at once
+const maxConcurrent = 6
+
+// Fetch reads the resource a reference in the document points to
+type Fetch func(ref string) ([]byte, error)
+
+// Loader fetches resources in the background, the highest priority first,
+// and hands them out once fetched. Each reference is fetched once. It is safe
+// for concurrent use.
+type Loader struct {
+	fetch Fetch
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	queue   []queued // waiting to be fetched, in the order they will be
+	running int      // workers fetching from the queue
+}
+
+type entry struct {
+	started bool
+	done    chan struct{} // closed once data and err are set
+	data    []byte
+	err     error
+}
+
+type queued struct {
+	Resource
+	entry *entry
+}
+
+// New creates a loader that fetches with fetch
+func New(fetch Fetch) *Loader {
+	return &Loader{fetch: fetch, entries: map[string]*entry{}}
+}
+
+// Start queues resources to be fetched in the background. They are fetched
+// in order of priority, and in the order given within a priority, so
+// stylesheets and fonts don't wait behind images. Resources already queued
+// or fetched are skipped.
+func (l *Loader) Start(resources []Resource) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range resources {
+		if _, ok := l.entries[r.Ref]; ok {
+			continue
+		}
+		e := &entry{done: make(chan struct{})}
+		l.entries[r.Ref] = e
+		// Insert after the queued resources of the same priority
+		i, _ := slices.BinarySearchFunc(l.queue, r.Priority(), func(q queued, p Priority) int {
+			if q.Priority() <= p {
+				return -1
+			}
+			return 1
+		})
+		l.queue = slices.Insert(l.queue, i, queued{r, e})
+	}
+	for l.running < maxConcurrent && l.running < len(l.queue) {
+		l.running++
+		go l.work()
+	}
+}
+
+// work fetches from the queue until it is empty
+func (l *Loader) work() {
+	for {
+		l.mu.Lock()
+		if len(l.queue) == 0 {
+			l.running--
+			l.mu.Unlock()
+			return
+		}
+		q := l.queue[0]
+		l.queue = l.queue[1:]
+		q.entry.started = true
+		l.mu.Unlock()
+
+		l.run(q.Ref, q.entry)
+	}
+}
+
+func (l *Loader) run(ref string, e *entry) {
+	e.data, e.err = l.fetch(ref)
+	close(e.done)
+}
+
+// Get returns the resource ref points to, waiting for its fetch if it is
+// under way. A resource that is still queued, or was never queued, is
+// fetched right away instead of waiting for its turn.
+func (l *Loader) Get(ref string) ([]byte, error) {
+	l.mu.Lock()
+	e, ok := l.entries[ref]
+	if !ok {
+		e = &entry{done: make(chan struct{})}
+		l.entries[ref] = e
+	}
+	fetchNow := !e.started
+	if fetchNow {
+		e.started = true
+		l.queue = slices.DeleteFunc(l.queue, func(q queued) bool { return q.entry == e })
+	}
+	l.mu.Unlock()
+
+	if fetchNow {
+		l.run(ref, e)
+	}
+	<-e.done
+	return e.data, e.err
+}
+
+// Forget drops the resources fetched so far, so that the next Get of each
+// fetches it again. Resources that are queued or being fetched are kept.
+func (l *Loader) Forget() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ref, e := range l.entries {
+		select {
+		case <-e.done:
+			delete(l.entries, ref)
+		default:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestLoaderFetchesByPriority(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	l := New(func(ref string) ([]byte, error) {
+		mu.Lock()
+		order = append(order, ref)
+		mu.Unlock()
+		return []byte(ref), nil
+	})
+
+	// With every worker taken, the resources wait in the queue
+	l.running = maxConcurrent
+	l.Start([]Resource{
+		{Ref: "a.png", Kind: KindImage},
+		{Ref: "b.png", Kind: KindImage},
+		{Ref: "font.woff2", Kind: KindFont},
+	})
+	l.Start([]Resource{{Ref: "site.css", Kind: KindStylesheet}})
+	var queued []string
+	for _, q := range l.queue {
+		queued = append(queued, q.Ref)
+	}
+	want := []string{"site.css", "font.woff2", "a.png", "b.png"}
+	if !slices.Equal(queued, want) {
+		t.Fatalf("expected the queue %v, got %v", want, queued)
+	}
+
+	// Getting a queued resource fetches it without waiting for its turn
+	if data, err := l.Get("b.png"); err != nil || string(data) != "b.png" {
+		t.Errorf("expected b.png, got %q %v", data, err)
+	}
+	if !slices.Equal(order, []string{"b.png"}) {
+		t.Errorf("expected only b.png to be fetched, got %v", order)
+	}
+
+	// A single worker takes the rest in order
+	l.running = 1
+	l.work()
+	if want := []string{"b.png", "site.css", "font.woff2", "a.png"}; !slices.Equal(order, want) {
+		t.Errorf("expected the fetches %v, got %v", want, order)
+	}
+}
+
+func TestLoaderFetchesOnce(t *testing.T) {
+	var mu sync.Mutex
+	fetches := map[string]int{}
+	l := New(func(ref string) ([]byte, error) {
+		mu.Lock()
+		fetches[ref]++
+		mu.Unlock()
+		if ref == "missing.css" {
+			return nil, errors.New("not found")
+		}
+		return []byte(ref), nil
+	})
+	l.Start([]Resource{{Ref: "site.css", Kind: KindStylesheet}, {Ref: "missing.css", Kind: KindStylesheet}})
+	l.Start([]Resource{{Ref: "site.css", Kind: KindStylesheet}})
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.Get("site.css"); err != nil {
+				t.Errorf("expected site.css, got %v", err)
+			}
+			if _, err := l.Get("missing.css"); err == nil {
+				t.Error("expected the error of missing.css")
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches["site.css"] != 1 || fetches["missing.css"] != 1 {
+		t.Errorf("expected each resource fetched once, got %v", fetches)
+	}
+}
+
+func TestLoaderForget(t *testing.T) {
+	version := 0
+	l := New(func(ref string) ([]byte, error) {
+		version++
+		return []byte(fmt.Sprint(ref, version)), nil
+	})
+	if data, _ := l.Get("site.css"); string(data) != "site.css1" {
+		t.Fatalf("expected the first fetch, got %q", data)
+	}
+	if data, _ := l.Get("site.css"); string(data) != "site.css1" {
+		t.Errorf("expected the fetch to be kept, got %q", data)
+	}
+	l.Forget()
+	if data, _ := l.Get("site.css"); string(data) != "site.css2" {
+		t.Errorf("expected a forgotten resource to be fetched again, got %q", data)
+	}
+}
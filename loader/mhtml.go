@@ -0,0 +1,104 @@
+package loader
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"strings"
+)
+
+// MHTML is a page saved with its resources in a single MIME file, as
+// browsers save web archives (.mhtml or .mht). It serves the resources by
+// the URL they were saved from, or as cid: URLs by their Content-ID.
+type MHTML struct {
+	// Root is the URL the page was saved from, which its references are
+	// resolved against. It is empty if the archive doesn't say.
+	Root string
+	page []byte
+
+	parts map[string][]byte
+}
+
+// ErrNotMHTML is returned for a file that isn't a multipart MIME message
+var ErrNotMHTML = errors.New("not an MHTML archive")
+
+// OpenMHTML reads an MHTML file
+func OpenMHTML(path string) (*MHTML, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadMHTML(f)
+}
+
+// ReadMHTML reads an MHTML archive. The page is the part named by the start
+// parameter of the message, or else the first part.
+func ReadMHTML(r io.Reader) (*MHTML, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotMHTML, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, ErrNotMHTML
+	}
+	start := params["start"]
+
+	m := &MHTML{parts: map[string][]byte{}}
+	first := true
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Quoted-printable parts are decoded by the reader itself
+		var body io.Reader = part
+		if strings.EqualFold(part.Header.Get("Content-Transfer-Encoding"), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, part)
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", part.Header.Get("Content-Location"), err)
+		}
+
+		location := part.Header.Get("Content-Location")
+		id := strings.Trim(part.Header.Get("Content-ID"), "<>")
+		if location != "" {
+			m.parts[location] = data
+		}
+		if id != "" {
+			m.parts["cid:"+id] = data
+		}
+		if start == "" && first || start != "" && strings.Trim(start, "<>") == id {
+			m.Root, m.page = location, data
+		}
+		first = false
+	}
+	if m.page == nil {
+		return nil, fmt.Errorf("%w: no page in the archive", ErrNotMHTML)
+	}
+	return m, nil
+}
+
+// Page returns the HTML of the page
+func (m *MHTML) Page() []byte {
+	return m.page
+}
+
+// Get returns a resource of the archive. It is a Getter.
+func (m *MHTML) Get(u string) ([]byte, error) {
+	if data, ok := m.parts[u]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s: %w", u, ErrNotArchived)
+}
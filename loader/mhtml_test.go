@@ -0,0 +1,65 @@
+package loader
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testMHTML = "From: <Saved by a browser>\r\n" +
+	"Subject: Example\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/related;\r\n" +
+	"\ttype=\"text/html\";\r\n" +
+	"\tboundary=\"----=_Part\"\r\n" +
+	"\r\n" +
+	"------=_Part\r\n" +
+	"Content-Type: text/html\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"Content-Location: https://example.com/page/\r\n" +
+	"\r\n" +
+	"<link rel=3D\"stylesheet\" href=3D\"style.css\"><p>a long line that is =\r\n" +
+	"wrapped</p>\r\n" +
+	"------=_Part\r\n" +
+	"Content-Type: text/css\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"Content-Location: https://example.com/page/style.css\r\n" +
+	"\r\n" +
+	"cCB7IGNvbG9yOiBy\r\n" +
+	"ZWQ7IH0=\r\n" +
+	"------=_Part\r\n" +
+	"Content-Type: image/png\r\n" +
+	"Content-ID: <logo@example>\r\n" +
+	"\r\n" +
+	"png\r\n" +
+	"------=_Part--\r\n"
+
+func TestReadMHTML(t *testing.T) {
+	m, err := ReadMHTML(strings.NewReader(testMHTML))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if m.Root != "https://example.com/page/" {
+		t.Errorf("expected the page's URL, got %q", m.Root)
+	}
+	if want := `<link rel="stylesheet" href="style.css"><p>a long line that is wrapped</p>`; string(m.Page()) != want {
+		t.Errorf("expected the page decoded, got %q", m.Page())
+	}
+
+	// References resolve against the page to the resources saved with it
+	base, _ := url.Parse(m.Root)
+	fetch := HTTP(base, m.Get)
+	for ref, want := range map[string]string{"style.css": "p { color: red; }", "cid:logo@example": "png"} {
+		if got, err := fetch(ref); err != nil || string(got) != want {
+			t.Errorf("%s: expected %q, got %q %v", ref, want, got, err)
+		}
+	}
+	if _, err := fetch("missing.png"); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("expected a resource that wasn't saved to be missing, got %v", err)
+	}
+
+	if _, err := ReadMHTML(strings.NewReader("<html></html>")); !errors.Is(err, ErrNotMHTML) {
+		t.Errorf("expected HTML not to be read as an archive, got %v", err)
+	}
+}
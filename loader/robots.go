@@ -0,0 +1,124 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots is what a site's robots.txt asks of a crawler: which paths it may
+// fetch and how long to wait between fetches
+type Robots struct {
+	rules []robotsRule
+	// Delay is the Crawl-delay, or 0 if the site gives none
+	Delay time.Duration
+}
+
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// ParseRobots parses a robots.txt for a crawler that calls itself agent. The
+// group that names the agent applies, or else the group for every agent, *.
+func ParseRobots(data []byte, agent string) *Robots {
+	agent = strings.ToLower(agent)
+	var mine, any *Robots
+	// groups are the groups the lines being read apply to. Consecutive
+	// User-agent lines start one group for all of them.
+	var groups []*Robots
+	inAgents := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key == "user-agent" {
+			if !inAgents {
+				groups = nil
+			}
+			inAgents = true
+			name := strings.ToLower(value)
+			switch {
+			case name == "*" && any == nil:
+				any = &Robots{}
+				groups = append(groups, any)
+			case name != "*" && strings.Contains(agent, name) && mine == nil:
+				mine = &Robots{}
+				groups = append(groups, mine)
+			}
+			continue
+		}
+		inAgents = false
+		for _, g := range groups {
+			switch key {
+			case "allow", "disallow":
+				// An empty Disallow allows everything
+				if value != "" {
+					g.rules = append(g.rules, robotsRule{pattern: value, allow: key == "allow"})
+				}
+			case "crawl-delay":
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					g.Delay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+	switch {
+	case mine != nil:
+		return mine
+	case any != nil:
+		return any
+	}
+	return &Robots{}
+}
+
+// Allowed reports whether a path, with its query, may be fetched. The rule
+// with the longest pattern that matches decides, and Allow wins a tie; a
+// path no rule matches is allowed.
+func (r *Robots) Allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || n == longest && rule.allow {
+			allowed, longest = rule.allow, n
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches a path against a pattern of a prefix, in which * is
+// any run of characters and a trailing $ anchors the end
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if anchored && rest != "" {
+		// The last part may match later on than where it was found first
+		last := parts[len(parts)-1]
+		return len(parts) > 1 && strings.HasSuffix(path, last)
+	}
+	return true
+}
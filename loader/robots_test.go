@@ -0,0 +1,46 @@
+package loader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	data := []byte(`# comments are ignored
+User-agent: *
+Disallow: /private/
+Allow: /private/open$
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: googlebot
+User-agent: penny
+Disallow: /
+Allow: /docs
+`)
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"other", "/", true},
+		{"other", "/private/page", false},
+		{"other", "/private/open", true},
+		{"other", "/private/open/more", false},
+		{"other", "/files/a.pdf", false},
+		{"other", "/files/a.pdf?x", true},
+		{"penny", "/", false},
+		{"penny", "/docs/intro", true},
+	}
+	for _, tt := range tests {
+		if got := ParseRobots(data, tt.agent).Allowed(tt.path); got != tt.want {
+			t.Errorf("%s %s: expected %v, got %v", tt.agent, tt.path, tt.want, got)
+		}
+	}
+	if d := ParseRobots(data, "other").Delay; d != 2*time.Second {
+		t.Errorf("expected the crawl delay, got %v", d)
+	}
+	if !ParseRobots([]byte("User-agent: *\nDisallow:\n"), "penny").Allowed("/a") {
+		t.Error("expected an empty Disallow to allow everything")
+	}
+}
@@ -0,0 +1,162 @@
+package paint
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+)
+
+// clipSamples is the number of samples per pixel along each axis used to
+// find how much of a pixel a clip shape covers
+const clipSamples = 4
+
+// ClipShape is a clip-path resolved against the box it clips, in page
+// coordinates
+type ClipShape struct {
+	Kind   css.ClipShape
+	Bounds layout.Rect // the inset rect, or the bounding box of the shape
+	// Radius is the radius of a circle, or of the rounded corners of an
+	// inset
+	Radius float32
+	// Points are the corners of a polygon, filled with the even-odd rule if
+	// EvenOdd is set and the nonzero rule otherwise
+	Points  [][2]float32
+	EvenOdd bool
+}
+
+// resolveClip resolves a clip path against the border box of the node it
+// clips. A nil path resolves to a nil shape.
+func resolveClip(path *css.ClipPath, box layout.Rect) *ClipShape {
+	if path == nil {
+		return nil
+	}
+	clip := &ClipShape{Kind: path.Shape, EvenOdd: path.EvenOdd}
+	switch path.Shape {
+	case css.ClipInset:
+		top, right := path.Inset[0].Resolve(box.H), path.Inset[1].Resolve(box.W)
+		bottom, left := path.Inset[2].Resolve(box.H), path.Inset[3].Resolve(box.W)
+		clip.Bounds = layout.Rect{
+			X: box.X + left,
+			Y: box.Y + top,
+			W: max(0, box.W-left-right),
+			H: max(0, box.H-top-bottom),
+		}
+		side := min(clip.Bounds.W, clip.Bounds.H)
+		clip.Radius = min(path.Round.Resolve(side), side/2)
+
+	case css.ClipCircle:
+		cx := box.X + path.CenterX.Resolve(box.W)
+		cy := box.Y + path.CenterY.Resolve(box.H)
+		sides := [4]float32{abs32(cx - box.X), abs32(box.X + box.W - cx), abs32(cy - box.Y), abs32(box.Y + box.H - cy)}
+		switch path.RadiusKind {
+		case css.RadiusClosestSide:
+			clip.Radius = min(sides[0], sides[1], sides[2], sides[3])
+		case css.RadiusFarthestSide:
+			clip.Radius = max(sides[0], sides[1], sides[2], sides[3])
+		case css.RadiusLength:
+			diagonal := float32(math.Hypot(float64(box.W), float64(box.H)) / math.Sqrt2)
+			clip.Radius = path.Radius.Resolve(diagonal)
+		}
+		clip.Bounds = layout.Rect{X: cx - clip.Radius, Y: cy - clip.Radius, W: 2 * clip.Radius, H: 2 * clip.Radius}
+
+	case css.ClipPolygon:
+		clip.Points = make([][2]float32, len(path.Points))
+		for i, p := range path.Points {
+			clip.Points[i] = [2]float32{box.X + p[0].Resolve(box.W), box.Y + p[1].Resolve(box.H)}
+		}
+		x0, y0 := clip.Points[0][0], clip.Points[0][1]
+		x1, y1 := x0, y0
+		for _, p := range clip.Points[1:] {
+			x0, y0 = min(x0, p[0]), min(y0, p[1])
+			x1, y1 = max(x1, p[0]), max(y1, p[1])
+		}
+		clip.Bounds = layout.Rect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0}
+	}
+	return clip
+}
+
+// contains reports whether a point is inside the shape
+func (c *ClipShape) contains(x, y float32) bool {
+	b := c.Bounds
+	if x < b.X || y < b.Y || x >= b.X+b.W || y >= b.Y+b.H {
+		return false
+	}
+	switch c.Kind {
+	case css.ClipInset:
+		// Only the corners are rounded: measure from the center of the
+		// corner's circle when the point is beyond it on both axes
+		r := c.Radius
+		dx := max(b.X+r-x, x-(b.X+b.W-r), 0)
+		dy := max(b.Y+r-y, y-(b.Y+b.H-r), 0)
+		return dx == 0 || dy == 0 || dx*dx+dy*dy <= r*r
+	case css.ClipCircle:
+		dx, dy := x-(b.X+c.Radius), y-(b.Y+c.Radius)
+		return dx*dx+dy*dy <= c.Radius*c.Radius
+	}
+
+	// Count the edges crossing a ray to the right of the point, signed by
+	// their direction for the nonzero rule
+	winding := 0
+	for i, p := range c.Points {
+		q := c.Points[(i+1)%len(c.Points)]
+		if (p[1] <= y) == (q[1] <= y) {
+			continue
+		}
+		cross := p[0] + (y-p[1])/(q[1]-p[1])*(q[0]-p[0])
+		if cross <= x {
+			continue
+		}
+		if q[1] > p[1] {
+			winding++
+		} else {
+			winding--
+		}
+	}
+	if c.EvenOdd {
+		return winding%2 != 0
+	}
+	return winding != 0
+}
+
+// mask returns how much of each pixel of r the shape covers
+func (c *ClipShape) mask(r image.Rectangle) *image.Alpha {
+	m := image.NewAlpha(r)
+	area := pixelRect(c.Bounds).Intersect(r)
+	const step = 1.0 / clipSamples
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			covered := 0
+			for sy := range clipSamples {
+				for sx := range clipSamples {
+					if c.contains(float32(x)+(float32(sx)+0.5)*step, float32(y)+(float32(sy)+0.5)*step) {
+						covered++
+					}
+				}
+			}
+			m.Pix[m.PixOffset(x, y)] = uint8(covered * 255 / (clipSamples * clipSamples))
+		}
+	}
+	return m
+}
+
+func (c *ClipShape) String() string {
+	if c == nil {
+		return "none"
+	}
+	b := c.Bounds
+	switch c.Kind {
+	case css.ClipInset:
+		return fmt.Sprintf("inset(%.1f, %.1f, %.1f, %.1f) round %.1f", b.X, b.Y, b.W, b.H, c.Radius)
+	case css.ClipCircle:
+		return fmt.Sprintf("circle(%.1f, %.1f) r=%.1f", b.X+c.Radius, b.Y+c.Radius, c.Radius)
+	}
+	points := make([]string, len(c.Points))
+	for i, p := range c.Points {
+		points[i] = fmt.Sprintf("%.1f %.1f", p[0], p[1])
+	}
+	return "polygon(" + strings.Join(points, ", ") + ")"
+}
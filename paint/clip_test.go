@@ -0,0 +1,74 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+)
+
+// clipTestImage rasterizes a 20x20 black square at (10, 10) clipped by the
+// clip-path value
+func clipTestImage(t *testing.T, value string) func(x, y int) uint8 {
+	t.Helper()
+	sheet, err := css.Parse("p { clip-path: " + value + "; }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	style := css.DefaultStyle()
+	css.ApplyDeclaration(&style, sheet.Rules[0].Declarations[0])
+
+	box := layout.Rect{X: 10, Y: 10, W: 20, H: 20}
+	list := NewPaintList()
+	start := list.PushLayer(Layer{Clip: resolveClip(style.ClipPath, box)})
+	list.PushFillRect(box, css.Color{A: 255})
+	list.PopLayer(start)
+	img := Rasterize(list, 40, 40)
+	return func(x, y int) uint8 { return img.RGBAAt(x, y).A }
+}
+
+func TestClipPathShapes(t *testing.T) {
+	tests := []struct {
+		value   string
+		inside  [][2]int
+		outside [][2]int
+	}{
+		{"inset(5px)", [][2]int{{15, 15}, {24, 24}}, [][2]int{{12, 20}, {20, 26}}},
+		{"inset(0 round 10px)", [][2]int{{20, 20}, {20, 10}}, [][2]int{{10, 10}, {29, 29}}},
+		{"circle(50%)", [][2]int{{20, 20}, {11, 20}}, [][2]int{{11, 11}, {28, 28}}},
+		{"circle(5px at left top)", [][2]int{{11, 11}}, [][2]int{{16, 16}, {20, 20}}},
+		{"polygon(50% 0, 100% 100%, 0 100%)", [][2]int{{20, 20}, {12, 29}}, [][2]int{{12, 12}, {28, 12}}},
+	}
+	for _, tt := range tests {
+		alpha := clipTestImage(t, tt.value)
+		for _, p := range tt.inside {
+			if a := alpha(p[0], p[1]); a != 255 {
+				t.Errorf("%s: expected %v to be drawn, got alpha %d", tt.value, p, a)
+			}
+		}
+		for _, p := range tt.outside {
+			if a := alpha(p[0], p[1]); a != 0 {
+				t.Errorf("%s: expected %v to be clipped, got alpha %d", tt.value, p, a)
+			}
+		}
+	}
+}
+
+func TestClipPathAntialiasing(t *testing.T) {
+	alpha := clipTestImage(t, "circle(50%)")
+	// The circle's edge crosses this pixel
+	if a := alpha(12, 13); a == 0 || a == 255 {
+		t.Errorf("expected a partly covered edge pixel, got alpha %d", a)
+	}
+}
+
+func TestClipBoundsLayer(t *testing.T) {
+	list := NewPaintList()
+	clip := resolveClip(&css.ClipPath{Shape: css.ClipInset, Inset: [4]css.ClipLength{{Value: 5}, {Value: 5}, {Value: 5}, {Value: 5}}}, layout.Rect{W: 100, H: 100})
+	start := list.PushLayer(Layer{Clip: clip})
+	list.PushFillRect(layout.Rect{W: 100, H: 100}, css.Color{A: 255})
+	list.PopLayer(start)
+	if got, want := list.Ops[start].Rect, (layout.Rect{X: 5, Y: 5, W: 90, H: 90}); got != want {
+		t.Errorf("expected the layer to be bounded by its clip %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,47 @@
+package paint
+
+import (
+	"math"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/text"
+)
+
+// pushDecorations draws the lines decorating the text of a span: the
+// underline and overline, which go under the text, or when over is set the
+// line-through, which goes over it. Vertical text isn't decorated.
+func pushDecorations(list *PaintList, span textSpan, over bool) {
+	d := span.Style.Decorations
+	if d.Lines == 0 || span.Style.WritingMode.Vertical() {
+		return
+	}
+	font := text.FontOf(&span.Style)
+	m := text.MetricsOf(font)
+	baseline := span.Rect.Y + m.Baseline()
+	thickness := max(1, float32(math.Round(float64(font.Size/16))))
+
+	r := span.ink()
+	r.H = thickness
+	for _, line := range [...]css.TextDecorationLine{css.Underline, css.Overline, css.LineThrough} {
+		if d.Lines&line == 0 || (line == css.LineThrough) != over {
+			continue
+		}
+		r.Y = decorationTop(line, baseline, m, thickness)
+		list.PushFillRect(r, d.Color(line))
+	}
+}
+
+// decorationTop returns where a line decorating text on a baseline starts,
+// placed by the metrics of its font: an underline just below the baseline,
+// an overline at the top of the tallest glyphs, and a line-through across
+// the middle of lowercase letters, about a third of the ascent up
+func decorationTop(line css.TextDecorationLine, baseline float32, m text.Metrics, thickness float32) float32 {
+	switch line {
+	case css.Underline:
+		return baseline + m.Descent/4
+	case css.Overline:
+		return baseline - m.Ascent
+	default:
+		return baseline - m.Ascent/3 - thickness/2
+	}
+}
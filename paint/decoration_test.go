@@ -0,0 +1,48 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/text"
+)
+
+func TestPaintTextDecoration(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		`<html><body><p><span>link</span></p></body></html>`,
+		`body, p { margin: 0; } p { text-decoration: underline red; font-size: 16px; }
+		span { text-decoration: line-through; color: blue; }`)
+	list := Paint(tree)
+
+	// The text inside the span is underlined by the paragraph and struck
+	// through by the span: the underline before it and the line-through
+	// after, each in the color of the element that set it
+	red, blue := css.Color{R: 255, A: 255}, css.Color{B: 255, A: 255}
+	var kinds []string
+	var under, through, drawn PaintOp
+	for _, op := range list.Ops {
+		switch {
+		case op.Kind == OpDrawText:
+			kinds, drawn = append(kinds, "text"), op
+		case op.Kind == OpFillRect && op.Color == red:
+			kinds, under = append(kinds, "underline"), op
+		case op.Kind == OpFillRect && op.Color == blue:
+			kinds, through = append(kinds, "line-through"), op
+		}
+	}
+	if len(kinds) != 3 || kinds[0] != "underline" || kinds[1] != "text" || kinds[2] != "line-through" {
+		t.Fatalf("expected an underline, the text and a line-through, got %v in:\n%s", kinds, list.Dump())
+	}
+
+	m := text.MetricsOf(text.Font{Size: 16})
+	baseline := drawn.Rect.Y + m.Baseline()
+	if under.Rect.Y < baseline || under.Rect.Y > baseline+m.Descent {
+		t.Errorf("expected the underline below the baseline at %v, got %v", baseline, under.Rect)
+	}
+	if through.Rect.Y >= baseline || through.Rect.Y <= baseline-m.Ascent {
+		t.Errorf("expected the line-through across the text, got %v", through.Rect)
+	}
+	if w := text.Width("link", text.Font{Size: 16}); under.Rect.X != drawn.Rect.X || under.Rect.W != w {
+		t.Errorf("expected the underline as wide as the text, %v, got %v", w, under.Rect)
+	}
+}
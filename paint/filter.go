@@ -0,0 +1,326 @@
+package paint
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"slices"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+)
+
+// A gaussian blur is approximated by this many successive box blurs
+const blurPasses = 3
+
+// newLayer returns the offscreen image for a PushLayer op drawing onto
+// parent, within view, the area being shown. It covers what the layer
+// draws, grown by how far its filter can spread it, and only what of that
+// can reach parent. However far the filter spreads, the layer stays within
+// view grown by its larger side, so a layer is never more than three times
+// as wide and high as the view.
+func newLayer(parent *image.RGBA, view image.Rectangle, op PaintOp, filter *css.Filter) *image.RGBA {
+	limit := filterLimit(view)
+	outset := min(filterOutset(filter), limit)
+	spread := int(math.Ceil(float64(outset)))
+	reach := parent.Bounds().Inset(-spread).Intersect(view.Inset(-int(limit)))
+	return image.NewRGBA(pixelRect(outsetRect(op.Rect, outset)).Intersect(reach))
+}
+
+// filterLimit returns the largest blur radius and shadow offset filters
+// shown in view are applied with: its larger side
+func filterLimit(view image.Rectangle) float32 {
+	return float32(max(view.Dx(), view.Dy()))
+}
+
+// limitFilter returns filter with its blur radii and shadow offsets at most
+// limit. A blur that wide already spreads what it blurs across the view, and
+// a shadow offset that far already moves it out of view, so the filter
+// looks much the same at a fraction of the cost.
+func limitFilter(filter *css.Filter, limit float32) *css.Filter {
+	if filter == nil {
+		return nil
+	}
+	limited := &css.Filter{Functions: slices.Clone(filter.Functions)}
+	for i, fn := range limited.Functions {
+		switch fn.Kind {
+		case css.FilterBlur, css.FilterDropShadow:
+			fn.Amount = min(fn.Amount, limit)
+			fn.OffsetX = max(-limit, min(fn.OffsetX, limit))
+			fn.OffsetY = max(-limit, min(fn.OffsetY, limit))
+		}
+		limited.Functions[i] = fn
+	}
+	return limited
+}
+
+// compositeLayer draws the image of a layer, once filtered and clipped,
+// over its parent
+func compositeLayer(parent, img *image.RGBA, layer Layer) {
+	filtered := applyFilter(img, layer.Filter)
+	if layer.Opacity > 0 && layer.Opacity < 1 {
+		fade(filtered, layer.Opacity)
+	}
+	b := filtered.Bounds()
+	if layer.Clip == nil {
+		draw.Draw(parent, b, filtered, b.Min, draw.Over)
+		return
+	}
+	draw.DrawMask(parent, b, filtered, b.Min, layer.Clip.mask(b), b.Min, draw.Over)
+}
+
+// fade multiplies the alpha of an image by opacity, in place
+func fade(img *image.RGBA, opacity float32) {
+	// The pixels are premultiplied, so every channel is scaled
+	for i, v := range img.Pix {
+		img.Pix[i] = uint8(float32(v)*opacity + 0.5)
+	}
+}
+
+// applyFilter applies the functions of a filter in order. The image may be
+// modified in place.
+func applyFilter(img *image.RGBA, filter *css.Filter) *image.RGBA {
+	if filter == nil {
+		return img
+	}
+	for _, fn := range filter.Functions {
+		switch fn.Kind {
+		case css.FilterBlur:
+			gaussianBlur(img, fn.Amount)
+		case css.FilterDropShadow:
+			img = dropShadow(img, fn)
+		default:
+			matrixOf(fn).apply(img)
+		}
+	}
+	return img
+}
+
+// filterOutset returns how far a filter can spread what it filters
+func filterOutset(filter *css.Filter) float32 {
+	if filter == nil {
+		return 0
+	}
+	var outset float32
+	for _, fn := range filter.Functions {
+		switch fn.Kind {
+		case css.FilterBlur:
+			outset += 3 * fn.Amount
+		case css.FilterDropShadow:
+			outset += max(abs32(fn.OffsetX), abs32(fn.OffsetY)) + 3*fn.Amount/2
+		}
+	}
+	return outset
+}
+
+// dropShadow draws a blurred copy of the image's alpha in the shadow color,
+// offset, under the image
+func dropShadow(img *image.RGBA, fn css.FilterFunction) *image.RGBA {
+	b := img.Bounds()
+	shadow := image.NewRGBA(b)
+	dx, dy := int(math.Round(float64(fn.OffsetX))), int(math.Round(float64(fn.OffsetY)))
+	c := fn.Color
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		sy := y - dy
+		if sy < b.Min.Y || sy >= b.Max.Y {
+			continue
+		}
+		for x := b.Min.X; x < b.Max.X; x++ {
+			sx := x - dx
+			if sx < b.Min.X || sx >= b.Max.X {
+				continue
+			}
+			a := uint32(img.Pix[img.PixOffset(sx, sy)+3]) * uint32(c.A) / 255
+			i := shadow.PixOffset(x, y)
+			shadow.Pix[i+0] = uint8(uint32(c.R) * a / 255)
+			shadow.Pix[i+1] = uint8(uint32(c.G) * a / 255)
+			shadow.Pix[i+2] = uint8(uint32(c.B) * a / 255)
+			shadow.Pix[i+3] = uint8(a)
+		}
+	}
+	// The shadow's blur radius is twice the standard deviation
+	gaussianBlur(shadow, fn.Amount/2)
+	draw.Draw(shadow, b, img, b.Min, draw.Over)
+	return shadow
+}
+
+// gaussianBlur blurs the image in place with a standard deviation of sigma
+// pixels. Pixels are premultiplied, so color doesn't bleed out of
+// transparent areas, and the area outside the image counts as transparent.
+func gaussianBlur(img *image.RGBA, sigma float32) {
+	if sigma <= 0 || img.Bounds().Empty() {
+		return
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	tmp := make([]uint8, len(img.Pix))
+	for _, size := range boxSizes(float64(sigma)) {
+		r := (size - 1) / 2
+		boxBlurLines(img.Pix, tmp, h, img.Stride, w, 4, r)
+		boxBlurLines(tmp, img.Pix, w, 4, h, img.Stride, r)
+	}
+}
+
+// boxSizes returns the widths of the box blurs that together approximate a
+// gaussian blur
+func boxSizes(sigma float64) [blurPasses]int {
+	const n = blurPasses
+	ideal := math.Sqrt(12*sigma*sigma/n + 1)
+	lower := int(math.Floor(ideal))
+	if lower%2 == 0 {
+		lower--
+	}
+	upper := lower + 2
+	m := int(math.Round((12*sigma*sigma - n*float64(lower*lower) - 4*n*float64(lower) - 3*n) / (-4*float64(lower) - 4)))
+
+	var sizes [n]int
+	for i := range sizes {
+		if i < m {
+			sizes[i] = lower
+		} else {
+			sizes[i] = upper
+		}
+	}
+	return sizes
+}
+
+// boxBlurLines box blurs count lines of n pixels from src into dst with a
+// window of 2r+1 pixels. Pixel i of line l is at l*lineStep + i*pixStep.
+func boxBlurLines(src, dst []uint8, count, lineStep, n, pixStep, r int) {
+	window := 2*r + 1
+	for l := range count {
+		base := l * lineStep
+		for c := range 4 {
+			sum := 0
+			for i := 0; i < min(r, n); i++ {
+				sum += int(src[base+i*pixStep+c])
+			}
+			for i := range n {
+				if j := i + r; j < n {
+					sum += int(src[base+j*pixStep+c])
+				}
+				if j := i - r - 1; j >= 0 {
+					sum -= int(src[base+j*pixStep+c])
+				}
+				dst[base+i*pixStep+c] = uint8((sum + window/2) / window)
+			}
+		}
+	}
+}
+
+// colorMatrix maps an unpremultiplied color with channels from 0 to 1: each
+// of red, green and blue becomes a weighted sum of the three plus an offset,
+// and alpha is scaled
+type colorMatrix struct {
+	rgb   [3][4]float32
+	alpha float32
+}
+
+// matrixOf returns the color matrix of a filter function, as defined by
+// the Filter Effects specification
+func matrixOf(fn css.FilterFunction) colorMatrix {
+	a := fn.Amount
+	switch fn.Kind {
+	case css.FilterGrayscale:
+		s := 1 - a
+		return colorMatrix{rgb: [3][4]float32{
+			{0.2126 + 0.7874*s, 0.7152 - 0.7152*s, 0.0722 - 0.0722*s, 0},
+			{0.2126 - 0.2126*s, 0.7152 + 0.2848*s, 0.0722 - 0.0722*s, 0},
+			{0.2126 - 0.2126*s, 0.7152 - 0.7152*s, 0.0722 + 0.9278*s, 0},
+		}, alpha: 1}
+	case css.FilterSepia:
+		s := 1 - a
+		return colorMatrix{rgb: [3][4]float32{
+			{0.393 + 0.607*s, 0.769 - 0.769*s, 0.189 - 0.189*s, 0},
+			{0.349 - 0.349*s, 0.686 + 0.314*s, 0.168 - 0.168*s, 0},
+			{0.272 - 0.272*s, 0.534 - 0.534*s, 0.131 + 0.869*s, 0},
+		}, alpha: 1}
+	case css.FilterSaturate:
+		return colorMatrix{rgb: [3][4]float32{
+			{0.213 + 0.787*a, 0.715 - 0.715*a, 0.072 - 0.072*a, 0},
+			{0.213 - 0.213*a, 0.715 + 0.285*a, 0.072 - 0.072*a, 0},
+			{0.213 - 0.213*a, 0.715 - 0.715*a, 0.072 + 0.928*a, 0},
+		}, alpha: 1}
+	case css.FilterHueRotate:
+		rad := float64(a) * math.Pi / 180
+		cos, sin := float32(math.Cos(rad)), float32(math.Sin(rad))
+		return colorMatrix{rgb: [3][4]float32{
+			{0.213 + 0.787*cos - 0.213*sin, 0.715 - 0.715*cos - 0.715*sin, 0.072 - 0.072*cos + 0.928*sin, 0},
+			{0.213 - 0.213*cos + 0.143*sin, 0.715 + 0.285*cos + 0.140*sin, 0.072 - 0.072*cos - 0.283*sin, 0},
+			{0.213 - 0.213*cos - 0.787*sin, 0.715 - 0.715*cos + 0.715*sin, 0.072 + 0.928*cos + 0.072*sin, 0},
+		}, alpha: 1}
+	case css.FilterBrightness:
+		return colorMatrix{rgb: [3][4]float32{{a, 0, 0, 0}, {0, a, 0, 0}, {0, 0, a, 0}}, alpha: 1}
+	case css.FilterContrast:
+		o := 0.5 - 0.5*a
+		return colorMatrix{rgb: [3][4]float32{{a, 0, 0, o}, {0, a, 0, o}, {0, 0, a, o}}, alpha: 1}
+	case css.FilterInvert:
+		return colorMatrix{rgb: [3][4]float32{{1 - 2*a, 0, 0, a}, {0, 1 - 2*a, 0, a}, {0, 0, 1 - 2*a, a}}, alpha: 1}
+	case css.FilterOpacity:
+		return colorMatrix{rgb: [3][4]float32{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}}, alpha: a}
+	}
+	return colorMatrix{rgb: [3][4]float32{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}}, alpha: 1}
+}
+
+// apply maps every pixel of the image in place
+func (m colorMatrix) apply(img *image.RGBA) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):img.PixOffset(b.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			a := row[i+3]
+			if a == 0 {
+				continue
+			}
+			fa := float32(a)
+			r, g, bl := float32(row[i])/fa, float32(row[i+1])/fa, float32(row[i+2])/fa
+
+			na := min(255, fa*m.alpha)
+			for c, w := range m.rgb {
+				v := w[0]*r + w[1]*g + w[2]*bl + w[3]
+				row[i+c] = uint8(max(0, min(1, v))*na + 0.5)
+			}
+			row[i+3] = uint8(na + 0.5)
+		}
+	}
+}
+
+// pixelRect returns the pixels a rect touches
+func pixelRect(r layout.Rect) image.Rectangle {
+	return image.Rect(
+		int(math.Floor(float64(r.X))), int(math.Floor(float64(r.Y))),
+		int(math.Ceil(float64(r.X+r.W))), int(math.Ceil(float64(r.Y+r.H))),
+	)
+}
+
+func outsetRect(r layout.Rect, outset float32) layout.Rect {
+	return layout.Rect{X: r.X - outset, Y: r.Y - outset, W: r.W + 2*outset, H: r.H + 2*outset}
+}
+
+// unionRect returns the smallest rect containing a and b, where an empty
+// rect contains nothing
+func unionRect(a, b layout.Rect) layout.Rect {
+	if a.W <= 0 || a.H <= 0 {
+		return b
+	}
+	if b.W <= 0 || b.H <= 0 {
+		return a
+	}
+	x0, y0 := min(a.X, b.X), min(a.Y, b.Y)
+	x1, y1 := max(a.X+a.W, b.X+b.W), max(a.Y+a.H, b.Y+b.H)
+	return layout.Rect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0}
+}
+
+// intersectRect returns the area both a and b cover, which is empty if they
+// don't overlap
+func intersectRect(a, b layout.Rect) layout.Rect {
+	x0, y0 := max(a.X, b.X), max(a.Y, b.Y)
+	x1, y1 := min(a.X+a.W, b.X+b.W), min(a.Y+a.H, b.Y+b.H)
+	if x1 <= x0 || y1 <= y0 {
+		return layout.Rect{}
+	}
+	return layout.Rect{X: x0, Y: y0, W: x1 - x0, H: y1 - y0}
+}
+
+func abs32(v float32) float32 {
+	return max(v, -v)
+}
@@ -0,0 +1,133 @@
+package paint
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+)
+
+func TestPaintFilterLayers(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div class="a"><p class="b">x</p></div><p>y</p></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := css.Parse(`.a { filter: blur(2px); height: 40px; } .b { filter: grayscale(); background-color: red; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := layout.BuildLayoutTree(d, sheet)
+	layout.ComputeLayout(tree, 200, 200)
+
+	var kinds []string
+	for _, op := range Paint(tree).Ops {
+		if op.Kind == OpPushLayer || op.Kind == OpPopLayer {
+			kinds = append(kinds, op.Kind.String())
+		}
+	}
+	want := "PushLayer PushLayer PopLayer PopLayer"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestRasterizeLayerBlur(t *testing.T) {
+	list := NewPaintList()
+	start := list.PushLayer(Layer{Filter: &css.Filter{Functions: []css.FilterFunction{{Kind: css.FilterBlur, Amount: 2}}}})
+	list.PushFillRect(layout.Rect{X: 20, Y: 20, W: 10, H: 10}, css.Color{A: 255})
+	list.PopLayer(start)
+	if got := list.Ops[start].Rect; got != (layout.Rect{X: 20, Y: 20, W: 10, H: 10}) {
+		t.Errorf("expected the layer to bound its rect, got %v", got)
+	}
+
+	img := Rasterize(list, 50, 50)
+	if a := img.RGBAAt(25, 25).A; a < 200 {
+		t.Errorf("expected the center to stay nearly opaque, got alpha %d", a)
+	}
+	if a := img.RGBAAt(18, 25).A; a == 0 || a > 128 {
+		t.Errorf("expected the blur to spread past the edge, got alpha %d", a)
+	}
+	if a := img.RGBAAt(5, 5).A; a != 0 {
+		t.Errorf("expected far pixels to stay clear, got alpha %d", a)
+	}
+}
+
+func TestColorMatrixFilters(t *testing.T) {
+	red := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		copy(img.Pix, []uint8{255, 0, 0, 255})
+		return img
+	}
+	tests := []struct {
+		fn   css.FilterFunction
+		want [4]uint8
+	}{
+		{css.FilterFunction{Kind: css.FilterGrayscale, Amount: 1}, [4]uint8{54, 54, 54, 255}},
+		{css.FilterFunction{Kind: css.FilterInvert, Amount: 1}, [4]uint8{0, 255, 255, 255}},
+		{css.FilterFunction{Kind: css.FilterOpacity, Amount: 0.5}, [4]uint8{128, 0, 0, 128}},
+		{css.FilterFunction{Kind: css.FilterBrightness, Amount: 0.5}, [4]uint8{128, 0, 0, 255}},
+		{css.FilterFunction{Kind: css.FilterHueRotate, Amount: 0}, [4]uint8{255, 0, 0, 255}},
+	}
+	for _, tt := range tests {
+		img := applyFilter(red(), &css.Filter{Functions: []css.FilterFunction{tt.fn}})
+		if got := [4]uint8(img.Pix); got != tt.want {
+			t.Errorf("%v: expected %v, got %v", tt.fn.Kind, tt.want, got)
+		}
+	}
+}
+
+func TestDropShadow(t *testing.T) {
+	list := NewPaintList()
+	shadow := css.FilterFunction{Kind: css.FilterDropShadow, OffsetX: 5, OffsetY: 5, Color: css.Color{B: 255, A: 255}}
+	start := list.PushLayer(Layer{Filter: &css.Filter{Functions: []css.FilterFunction{shadow}}})
+	list.PushFillRect(layout.Rect{X: 10, Y: 10, W: 10, H: 10}, css.Color{R: 255, A: 255})
+	list.PopLayer(start)
+
+	img := Rasterize(list, 40, 40)
+	if got := img.RGBAAt(12, 12); got.R != 255 || got.B != 0 {
+		t.Errorf("expected the content over its shadow, got %v", got)
+	}
+	if got := img.RGBAAt(22, 22); got.B != 255 || got.A != 255 {
+		t.Errorf("expected the shadow offset past the content, got %v", got)
+	}
+}
+
+func TestRasterizeHugeFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   css.FilterFunction
+	}{
+		{"blur", css.FilterFunction{Kind: css.FilterBlur, Amount: 100000}},
+		{"shadow offset", css.FilterFunction{Kind: css.FilterDropShadow, OffsetX: 100000, OffsetY: 1, Color: css.Color{A: 255}}},
+		{"shadow blur", css.FilterFunction{Kind: css.FilterDropShadow, OffsetX: 1, OffsetY: 1, Amount: 10000, Color: css.Color{A: 255}}},
+	}
+	for _, tt := range tests {
+		list := NewPaintList()
+		start := list.PushLayer(Layer{Filter: &css.Filter{Functions: []css.FilterFunction{tt.fn}}})
+		list.PushFillRect(layout.Rect{X: 20, Y: 20, W: 10, H: 10}, css.Color{R: 255, A: 255})
+		list.PopLayer(start)
+
+		// The layer is limited by the view however far the filter spreads
+		view := image.Rect(0, 0, 50, 50)
+		filter := limitFilter(list.Layer(list.Ops[start]).Filter, filterLimit(view))
+		layer := newLayer(image.NewRGBA(view), view, list.Ops[start], filter)
+		if b := layer.Bounds(); b.Dx() > 150 || b.Dy() > 150 {
+			t.Errorf("%s: expected the layer within three times the view, got %v", tt.name, b)
+		}
+
+		img := Rasterize(list, 50, 50)
+		if tt.fn.Kind == css.FilterDropShadow {
+			if c := img.RGBAAt(25, 25); c.R != 255 || c.A != 255 {
+				t.Errorf("%s: expected the content over its shadow, got %v", tt.name, c)
+			}
+		}
+		if tt.name == "shadow offset" {
+			if a := img.RGBAAt(40, 25).A; a != 0 {
+				t.Errorf("%s: expected the shadow out of view, got alpha %d", tt.name, a)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package paint
+
+import (
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+)
+
+// The colors of gauges, as browsers draw them by default
+var (
+	gaugeTrack   = css.Color{R: 0xef, G: 0xef, B: 0xef, A: 255}
+	progressFill = css.Color{R: 0x00, G: 0x75, B: 0xff, A: 255}
+	meterFills   = [...]css.Color{
+		layout.GaugeOptimum:      {R: 0x10, G: 0x7c, B: 0x10, A: 255},
+		layout.GaugeSuboptimum:   {R: 0xff, G: 0xb9, B: 0x00, A: 255},
+		layout.GaugeEvenLessGood: {R: 0xd8, G: 0x3b, B: 0x01, A: 255},
+	}
+)
+
+// paintGauge draws the bar of a <progress> or <meter> in its content box:
+// the track, then the part of it that is full, from the start of the line.
+// A progress bar whose progress isn't known is an empty track.
+func paintGauge(node *layout.LayoutNode, list *PaintList) {
+	r := contentRect(node)
+	list.PushFillRect(r, gaugeTrack)
+	if node.Gauge.Fill <= 0 {
+		return
+	}
+	fill := progressFill
+	if node.Gauge.Kind == layout.GaugeMeter {
+		fill = meterFills[node.Gauge.Level]
+	}
+	bar := r
+	bar.W = r.W * node.Gauge.Fill
+	if node.Style.Direction == css.DirectionRTL {
+		bar.X = r.X + r.W - bar.W
+	}
+	list.PushFillRect(bar, fill)
+}
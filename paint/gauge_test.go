@@ -0,0 +1,34 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/layout"
+)
+
+func TestPaintGauges(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		`<html><body><progress value="0.25"></progress><meter value="9" max="10" low="3" high="5" optimum="1"></meter><progress></progress></body></html>`,
+		`body { margin: 0; }`)
+
+	var fills []PaintOp
+	for _, op := range Paint(tree).Ops {
+		if op.Kind == OpFillRect {
+			fills = append(fills, op)
+		}
+	}
+	// Each gauge has a track, and those that are full some way a bar
+	if len(fills) != 5 {
+		t.Fatalf("expected 5 fills, got %d", len(fills))
+	}
+	track, bar := fills[0], fills[1]
+	if track.Color != gaugeTrack || track.Rect.W != 160 || bar.Color != progressFill || bar.Rect.X != track.Rect.X || bar.Rect.W != 40 {
+		t.Errorf("expected a quarter of the progress bar filled, got %v over %v", bar.Rect, track.Rect)
+	}
+	if meter := fills[3]; meter.Color != meterFills[layout.GaugeEvenLessGood] || meter.Rect.W != 72 {
+		t.Errorf("expected the meter filled to 90%% in the color of a bad value, got %v in %v", meter.Rect, meter.Color)
+	}
+	if last := fills[4]; last.Color != gaugeTrack {
+		t.Errorf("expected a progress bar without a value to be an empty track, got %v", last.Color)
+	}
+}
@@ -0,0 +1,110 @@
+package paint
+
+import (
+	"image"
+	"math"
+
+	"github.com/myuon/penny/css"
+)
+
+// fillGradient fills the rect of a FillGradient op with its gradient,
+// composited over what is already drawn. Colors are interpolated
+// premultiplied, so a stop fading to transparent doesn't darken.
+func fillGradient(img *image.RGBA, op PaintOp, g *css.Gradient) {
+	r := op.Rect
+	dst := image.Rect(int(r.X), int(r.Y), int(r.X+r.W), int(r.Y+r.H)).Intersect(img.Bounds())
+	if dst.Empty() || len(g.Stops) == 0 {
+		return
+	}
+
+	// position maps a point of the box to where it is on the gradient line,
+	// 0 at its start and 1 at its end
+	var position func(x, y float32) float32
+	var length float32
+	switch g.Kind {
+	case css.GradientRadial:
+		cx, cy := g.CenterX.Resolve(r.W), g.CenterY.Resolve(r.H)
+		// The ending shape passes through the farthest corner
+		dx, dy := max(cx, r.W-cx), max(cy, r.H-cy)
+		rx, ry := dx*math.Sqrt2, dy*math.Sqrt2
+		if g.Circle {
+			rx = float32(math.Hypot(float64(dx), float64(dy)))
+			ry = rx
+		}
+		length = rx
+		position = func(x, y float32) float32 {
+			if rx == 0 || ry == 0 {
+				return 1
+			}
+			return float32(math.Hypot(float64((x-cx)/rx), float64((y-cy)/ry)))
+		}
+	default:
+		var dirX, dirY float32
+		if g.ToX != 0 || g.ToY != 0 {
+			// To a corner, the line is perpendicular to the diagonal
+			// between the other two corners
+			dirX, dirY = float32(g.ToX)*r.H, float32(g.ToY)*r.W
+			if g.ToX == 0 || g.ToY == 0 {
+				dirX, dirY = float32(g.ToX), float32(g.ToY)
+			}
+			n := float32(math.Hypot(float64(dirX), float64(dirY)))
+			dirX, dirY = dirX/n, dirY/n
+		} else {
+			rad := float64(g.Angle) * math.Pi / 180
+			dirX, dirY = float32(math.Sin(rad)), float32(-math.Cos(rad))
+		}
+		// The line is long enough that its ends' perpendiculars pass
+		// through the corners
+		length = float32(math.Abs(float64(r.W*dirX)) + math.Abs(float64(r.H*dirY)))
+		position = func(x, y float32) float32 {
+			if length == 0 {
+				return 0
+			}
+			return ((x-r.W/2)*dirX+(y-r.H/2)*dirY)/length + 0.5
+		}
+	}
+
+	offsets := g.Offsets(length)
+	colors := make([][4]float32, len(g.Stops))
+	for i, stop := range g.Stops {
+		a := float32(stop.Color.A) / 255
+		colors[i] = [4]float32{float32(stop.Color.R) * a, float32(stop.Color.G) * a, float32(stop.Color.B) * a, float32(stop.Color.A)}
+	}
+
+	for y := dst.Min.Y; y < dst.Max.Y; y++ {
+		for x := dst.Min.X; x < dst.Max.X; x++ {
+			c := gradientColor(offsets, colors, position(float32(x)+0.5-r.X, float32(y)+0.5-r.Y))
+			if c[3] <= 0 {
+				continue
+			}
+			i := img.PixOffset(x, y)
+			px := img.Pix[i : i+4 : i+4]
+			inv := 1 - c[3]/255
+			for k := range px {
+				px[k] = uint8(min(c[k]+float32(px[k])*inv+0.5, 255))
+			}
+		}
+	}
+}
+
+// gradientColor returns the premultiplied color at t along a gradient line
+// whose stops are at offsets. Before the first stop and after the last, the
+// line is the color of that stop.
+func gradientColor(offsets []float32, colors [][4]float32, t float32) [4]float32 {
+	if t <= offsets[0] {
+		return colors[0]
+	}
+	for i := 1; i < len(offsets); i++ {
+		if t >= offsets[i] {
+			continue
+		}
+		from, to := offsets[i-1], offsets[i]
+		f := (t - from) / (to - from)
+		var c [4]float32
+		for k := range c {
+			c[k] = colors[i-1][k] + (colors[i][k]-colors[i-1][k])*f
+		}
+		return c
+	}
+	return colors[len(colors)-1]
+}
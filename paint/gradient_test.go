@@ -0,0 +1,75 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+)
+
+func gradientOf(t *testing.T, value string) *css.Gradient {
+	t.Helper()
+	decls := css.ParseDeclarations("background-image: " + value)
+	if len(decls) != 1 {
+		t.Fatalf("%s: expected one declaration", value)
+	}
+	style := css.DefaultStyle()
+	if !css.ApplyDeclaration(&style, decls[0]) || style.BackgroundImage == nil {
+		t.Fatalf("%s: expected a gradient", value)
+	}
+	return style.BackgroundImage
+}
+
+func TestFillLinearGradient(t *testing.T) {
+	list := NewPaintList()
+	list.PushFillGradient(layout.Rect{X: 0, Y: 0, W: 100, H: 10}, gradientOf(t, "linear-gradient(to right, black, white)"))
+	img := Rasterize(list, 100, 10)
+
+	for _, tt := range []struct{ x, want int }{{0, 1}, {49, 126}, {99, 254}} {
+		if got := int(img.RGBAAt(tt.x, 5).R); got < tt.want-2 || got > tt.want+2 {
+			t.Errorf("x=%d: expected red about %d, got %d", tt.x, tt.want, got)
+		}
+	}
+	if img.RGBAAt(10, 0) != img.RGBAAt(10, 9) {
+		t.Errorf("expected a horizontal gradient to be the same down a column")
+	}
+}
+
+func TestFillGradientOver(t *testing.T) {
+	list := NewPaintList()
+	list.PushFillRect(layout.Rect{W: 10, H: 10}, css.Color{R: 255, A: 255})
+	list.PushFillGradient(layout.Rect{W: 10, H: 10}, gradientOf(t, "linear-gradient(transparent, transparent)"))
+	list.PushFillGradient(layout.Rect{X: 5, W: 5, H: 10}, gradientOf(t, "linear-gradient(blue, blue)"))
+	img := Rasterize(list, 10, 10)
+
+	if c := img.RGBAAt(2, 5); c.R != 255 || c.B != 0 {
+		t.Errorf("expected a transparent gradient to leave the red below, got %v", c)
+	}
+	if c := img.RGBAAt(7, 5); c.R != 0 || c.B != 255 {
+		t.Errorf("expected an opaque gradient to cover the red, got %v", c)
+	}
+}
+
+func TestFillRadialGradient(t *testing.T) {
+	list := NewPaintList()
+	list.PushFillGradient(layout.Rect{W: 20, H: 20}, gradientOf(t, "radial-gradient(circle, white, black)"))
+	img := Rasterize(list, 20, 20)
+
+	center, edge, corner := img.RGBAAt(10, 10).R, img.RGBAAt(19, 10).R, img.RGBAAt(0, 0).R
+	if !(center > edge && edge > corner) {
+		t.Errorf("expected the gradient to darken away from the center, got %d, %d, %d", center, edge, corner)
+	}
+	if img.RGBAAt(0, 10) != img.RGBAAt(10, 0) {
+		t.Errorf("expected a centered circle to be symmetric")
+	}
+}
+
+func TestPaintBackgroundImage(t *testing.T) {
+	style := css.DefaultStyle()
+	style.BackgroundImage = gradientOf(t, "linear-gradient(red, blue)")
+	list := NewPaintList()
+	paintNode(&layout.LayoutNode{Rect: layout.Rect{W: 10, H: 10}, Style: style}, list)
+	if len(list.Ops) != 1 || list.Ops[0].Kind != OpFillGradient || list.GradientOf(list.Ops[0]) != style.BackgroundImage {
+		t.Errorf("expected a FillGradient op, got\n%s", list.Dump())
+	}
+}
@@ -0,0 +1,91 @@
+package paint
+
+import (
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+)
+
+// hitTester finds the boxes painted at a point. It is handed the nodes in
+// painting order, so the box painted last over the point is on top.
+type hitTester struct {
+	x, y float32
+	// clips holds, for each clip and layer pushed, whether the point is
+	// still inside all of them
+	clips []bool
+	// hits are the boxes over the point that aren't clipped away from it,
+	// back to front
+	hits []*layout.LayoutNode
+}
+
+// inside reports whether the point is inside every clip pushed
+func (h *hitTester) inside() bool {
+	return len(h.clips) == 0 || h.clips[len(h.clips)-1]
+}
+
+// skips leaves out nothing: a box is hit however transparent it is
+func (h *hitTester) skips(node *layout.LayoutNode) bool { return false }
+
+func (h *hitTester) node(node *layout.LayoutNode) {
+	r := node.Rect
+	if h.inside() && h.x >= r.X && h.y >= r.Y && h.x < r.X+r.W && h.y < r.Y+r.H {
+		h.hits = append(h.hits, node)
+	}
+}
+
+func (h *hitTester) pushLayer(node *layout.LayoutNode) {
+	clip := resolveClip(node.Style.ClipPath, node.Rect)
+	h.clips = append(h.clips, h.inside() && (clip == nil || clip.contains(h.x, h.y)))
+}
+
+func (h *hitTester) pushClip(r layout.Rect) {
+	h.clips = append(h.clips, h.inside() && h.x >= r.X && h.y >= r.Y && h.x < r.X+r.W && h.y < r.Y+r.H)
+}
+
+func (h *hitTester) popLayer() { h.clips = h.clips[:len(h.clips)-1] }
+func (h *hitTester) popClip()  { h.clips = h.clips[:len(h.clips)-1] }
+
+// hitsAt returns the boxes painted over a point of the page, where no clip
+// cuts them away, front to back
+func hitsAt(tree *layout.LayoutTree, x, y float32) []*layout.LayoutNode {
+	if tree.Root == layout.InvalidLayoutNodeID {
+		return nil
+	}
+	h := &hitTester{x: x, y: y}
+	paintContext(h, tree, tree.Root)
+	for i, j := 0, len(h.hits)-1; i < j; i, j = i+1, j-1 {
+		h.hits[i], h.hits[j] = h.hits[j], h.hits[i]
+	}
+	return h.hits
+}
+
+// HitTest returns the element at a point of the page: the DOM node of the
+// topmost box painted there, or of its parent for a text box. Boxes are
+// stacked as they are painted, and clipped where their painting is, but
+// are hit however transparent they are. On an
+// image with an image map, it is the <area> containing the point, if any.
+// It returns dom.InvalidNodeID when no box contains the point.
+func HitTest(tree *layout.LayoutTree, d *dom.DOM, x, y float32) dom.NodeID {
+	hits := hitsAt(tree, x, y)
+	if len(hits) == 0 {
+		return dom.InvalidNodeID
+	}
+	hit := hits[0]
+	if area := layout.AreaAt(d, hit, x, y); area != dom.InvalidNodeID {
+		return area
+	}
+	if n := d.GetNode(hit.DomNode); n != nil && n.Type == dom.NodeTypeText {
+		return n.Parent
+	}
+	return hit.DomNode
+}
+
+// TextAt returns the topmost text node painted at a point of the page, or
+// dom.InvalidNodeID when there is none
+func TextAt(tree *layout.LayoutTree, x, y float32) dom.NodeID {
+	for _, hit := range hitsAt(tree, x, y) {
+		if hit.Text != "" {
+			return hit.DomNode
+		}
+	}
+	return dom.InvalidNodeID
+}
@@ -0,0 +1,86 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+// elementByID returns the element of a document with an id
+func elementByID(t *testing.T, d *dom.DOM, id string) dom.NodeID {
+	t.Helper()
+	for _, node := range d.Nodes {
+		if node.Type == dom.NodeTypeElement && node.Attr["id"] == id {
+			return node.ID
+		}
+	}
+	t.Fatalf("element #%s not found", id)
+	return dom.InvalidNodeID
+}
+
+func TestHitTest(t *testing.T) {
+	d, tree := selectionTestPage(t, `<html><body><div id="a"><p id="b">text</p></div></body></html>`,
+		"body { margin: 0; } #a { padding: 10px; } #b { margin: 0; height: 20px; }")
+
+	if got := HitTest(tree, d, 15, 15); got != elementByID(t, d, "b") {
+		t.Errorf("expected #b under the point, got %d", got)
+	}
+	if got := HitTest(tree, d, 5, 5); got != elementByID(t, d, "a") {
+		t.Errorf("expected #a in its padding, got %d", got)
+	}
+	if got := HitTest(tree, d, 5, 300); got != dom.InvalidNodeID {
+		t.Errorf("expected nothing below the page, got %d", got)
+	}
+}
+
+func TestHitTestPaintOrder(t *testing.T) {
+	d, tree := selectionTestPage(t, `<html><body>`+
+		`<div id="flow"></div><div id="under"></div>`+
+		`<div id="clip"><div id="inside"></div></div>`+
+		`<div id="hidden"></div>`+
+		`</body></html>`, `
+		body { margin: 0; } div { height: 20px; }
+		#under { position: absolute; top: 0; left: 0; width: 50px; z-index: -1; }
+		#flow { position: relative; z-index: 1; width: 20px; }
+		#clip { overflow: hidden; width: 50px; height: 20px; }
+		#inside { width: 100px; height: 20px; }
+		#hidden { opacity: 0; width: 50px; }`)
+
+	page := tree.Nodes[tree.Root].DomNode
+	tests := []struct {
+		x, y float32
+		want string
+	}{
+		// A box with a negative z-index is below the flow, though it comes
+		// later
+		{10, 5, "flow"},
+		{40, 5, "under"},
+		// A child is hit inside its parent's clip, and not past it
+		{10, 30, "inside"},
+		{70, 30, ""},
+		// A transparent box is still hit
+		{10, 50, "hidden"},
+	}
+	for _, tt := range tests {
+		want := page
+		if tt.want != "" {
+			want = elementByID(t, d, tt.want)
+		}
+		if got := HitTest(tree, d, tt.x, tt.y); got != want {
+			t.Errorf("(%v, %v): expected #%s, got %d", tt.x, tt.y, tt.want, got)
+		}
+	}
+}
+
+func TestTextAt(t *testing.T) {
+	d, tree := selectionTestPage(t, `<html><body><p id="a">first</p><p id="b">second</p></body></html>`,
+		"body { margin: 0; } p { margin: 0; height: 20px; }")
+
+	second := d.GetNode(elementByID(t, d, "b")).Children[0]
+	if got := TextAt(tree, 5, 25); got != second {
+		t.Errorf("expected the text of #b, got %d", got)
+	}
+	if got := TextAt(tree, 5, 300); got != dom.InvalidNodeID {
+		t.Errorf("expected no text below the page, got %d", got)
+	}
+}
@@ -0,0 +1,163 @@
+package paint
+
+import (
+	"container/list"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"sync"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ImageCache decodes the images of a document once per source and keeps the
+// most recently used bitmaps up to a memory budget, so that re-rendering a
+// page doesn't decode its images again. It is safe for concurrent use.
+type ImageCache struct {
+	open     func(src string) (io.ReadCloser, error)
+	maxBytes int
+
+	mu      sync.Mutex
+	bytes   int
+	entries map[imageKey]*list.Element
+	lru     *list.List // of *imageEntry, most recently used first
+}
+
+// imageKey identifies a bitmap: an image at its natural size when w and h
+// are zero, otherwise scaled to fit w x h
+type imageKey struct {
+	src  string
+	w, h int
+}
+
+type imageEntry struct {
+	key   imageKey
+	img   image.Image
+	err   error
+	bytes int
+}
+
+// NewImageCache creates a cache that reads image sources with open and holds
+// up to maxBytes of decoded pixels
+func NewImageCache(maxBytes int, open func(src string) (io.ReadCloser, error)) *ImageCache {
+	return &ImageCache{
+		open:     open,
+		maxBytes: maxBytes,
+		entries:  make(map[imageKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Image returns the decoded image of src at its natural size. Failures are
+// cached too, so a missing image isn't fetched on every render.
+func (c *ImageCache) Image(src string) (image.Image, error) {
+	key := imageKey{src: src}
+	if entry := c.lookup(key); entry != nil {
+		return entry.img, entry.err
+	}
+
+	img, err := c.decode(src)
+	c.store(key, img, err)
+	return img, err
+}
+
+// Scaled returns src scaled down to fit its layout size of w x h pixels.
+// Images that already fit are returned at their natural size; keeping only
+// the small bitmap of a large photo is what bounds the memory of image-heavy
+// pages.
+func (c *ImageCache) Scaled(src string, w, h int) (image.Image, error) {
+	if w <= 0 || h <= 0 {
+		return c.Image(src)
+	}
+
+	key := imageKey{src: src, w: w, h: h}
+	if entry := c.lookup(key); entry != nil {
+		return entry.img, entry.err
+	}
+
+	img, err := c.Image(src)
+	if err != nil {
+		return nil, err
+	}
+	size := img.Bounds().Size()
+	if size.X <= w && size.Y <= h {
+		return img, nil
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, min(w, size.X), min(h, size.Y)))
+	draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), img, img.Bounds(), draw.Src, nil)
+	c.store(key, scaled, nil)
+	return scaled, nil
+}
+
+// NaturalSize returns the size of the image of src, decoding it if it
+// hasn't been. It reports false if the image can't be loaded.
+func (c *ImageCache) NaturalSize(src string) (w, h float32, ok bool) {
+	img, err := c.Image(src)
+	if err != nil {
+		return 0, 0, false
+	}
+	size := img.Bounds().Size()
+	return float32(size.X), float32(size.Y), true
+}
+
+// Len returns the number of cached bitmaps
+func (c *ImageCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *ImageCache) decode(src string) (image.Image, error) {
+	r, err := c.open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+// lookup returns the cached entry for key, or nil, marking it as recently
+// used
+func (c *ImageCache) lookup(key imageKey) *imageEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*imageEntry)
+}
+
+func (c *ImageCache) store(key imageKey, img image.Image, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		// Another goroutine decoded the same image concurrently
+		return
+	}
+	entry := &imageEntry{key: key, img: img, err: err}
+	if img != nil {
+		size := img.Bounds().Size()
+		entry.bytes = size.X * size.Y * 4
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.bytes
+
+	// Evict least recently used bitmaps, but always keep the newest one even
+	// if it alone exceeds the budget
+	for c.bytes > c.maxBytes && c.lru.Len() > 1 {
+		oldest := c.lru.Back()
+		evicted := c.lru.Remove(oldest).(*imageEntry)
+		delete(c.entries, evicted.key)
+		c.bytes -= evicted.bytes
+	}
+}
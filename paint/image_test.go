@@ -0,0 +1,141 @@
+package paint
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/layout"
+)
+
+func pngSource(t *testing.T, images map[string]image.Rectangle) (func(src string) (io.ReadCloser, error), map[string]int) {
+	t.Helper()
+	encoded := make(map[string][]byte)
+	for src, rect := range images {
+		img := image.NewRGBA(rect)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				img.Set(x, y, color.RGBA{uint8(x), uint8(y), 100, 255})
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		encoded[src] = buf.Bytes()
+	}
+
+	opens := make(map[string]int)
+	return func(src string) (io.ReadCloser, error) {
+		opens[src]++
+		data, ok := encoded[src]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}, opens
+}
+
+func TestImageCacheDecodesOnce(t *testing.T) {
+	open, opens := pngSource(t, map[string]image.Rectangle{"a.png": image.Rect(0, 0, 4, 4)})
+	cache := NewImageCache(1<<20, open)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Image("a.png"); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if _, err := cache.Image("missing.png"); err == nil {
+			t.Fatal("expected an error for a missing image")
+		}
+	}
+	if opens["a.png"] != 1 || opens["missing.png"] != 1 {
+		t.Errorf("expected each source to be opened once, got %v", opens)
+	}
+}
+
+func TestImageCacheScalesDown(t *testing.T) {
+	open, _ := pngSource(t, map[string]image.Rectangle{
+		"big.png":   image.Rect(0, 0, 200, 100),
+		"small.png": image.Rect(0, 0, 10, 10),
+	})
+	cache := NewImageCache(1<<20, open)
+
+	img, err := cache.Scaled("big.png", 50, 50)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(50, 50) {
+		t.Errorf("expected big.png scaled to 50x50, got %v", got)
+	}
+	if again, _ := cache.Scaled("big.png", 50, 50); again != img {
+		t.Error("expected the scaled bitmap to be cached")
+	}
+
+	img, err = cache.Scaled("small.png", 50, 50)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(10, 10) {
+		t.Errorf("expected small.png at its natural size, got %v", got)
+	}
+}
+
+func TestImageCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	open, opens := pngSource(t, map[string]image.Rectangle{
+		"a.png": image.Rect(0, 0, 10, 10),
+		"b.png": image.Rect(0, 0, 10, 10),
+		"c.png": image.Rect(0, 0, 10, 10),
+	})
+	// Room for two 10x10 bitmaps
+	cache := NewImageCache(2*10*10*4, open)
+
+	cache.Image("a.png")
+	cache.Image("b.png")
+	cache.Image("a.png") // a is now more recently used than b
+	cache.Image("c.png") // evicts b
+
+	if n := cache.Len(); n != 2 {
+		t.Errorf("expected 2 cached bitmaps, got %d", n)
+	}
+	cache.Image("a.png")
+	cache.Image("b.png")
+	if opens["a.png"] != 1 {
+		t.Errorf("expected a.png to stay cached, opened %d times", opens["a.png"])
+	}
+	if opens["b.png"] != 2 {
+		t.Errorf("expected b.png to be evicted and decoded again, opened %d times", opens["b.png"])
+	}
+}
+
+func TestDrawImage(t *testing.T) {
+	open, _ := pngSource(t, map[string]image.Rectangle{"a.png": image.Rect(0, 0, 4, 2)})
+	list := NewPaintList()
+	list.PushDrawImage(layout.Rect{X: 2, Y: 2, W: 8, H: 4}, "a.png")
+	list.PushDrawImage(layout.Rect{X: 0, Y: 8, W: 4, H: 4}, "missing.png")
+
+	// Without images, nothing is drawn
+	if img := Rasterize(list, 12, 12); img.RGBAAt(4, 4).A != 0 {
+		t.Errorf("expected nothing drawn without images, got %v", img.RGBAAt(4, 4))
+	}
+
+	list.Images = NewImageCache(1<<20, open)
+	img := Rasterize(list, 12, 12)
+	for _, p := range []image.Point{{2, 2}, {9, 5}} {
+		if c := img.RGBAAt(p.X, p.Y); c.A != 255 || c.B != 100 {
+			t.Errorf("expected the image scaled over %v, got %v", p, c)
+		}
+	}
+	for _, p := range []image.Point{{1, 2}, {10, 5}, {2, 6}, {1, 9}} {
+		if c := img.RGBAAt(p.X, p.Y); c.A != 0 {
+			t.Errorf("expected nothing drawn at %v, got %v", p, c)
+		}
+	}
+	if got := list.Dump(); !strings.Contains(got, `DrawImage (2.0, 2.0, 8.0, 4.0) "a.png"`) {
+		t.Errorf("expected the op dumped, got %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package paint
+
+import (
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
+)
+
+// The colors of media placeholders, as browsers draw them by default
+var (
+	videoBackground    = css.Color{A: 255}
+	controlsBackground = css.Color{R: 0xf1, G: 0xf3, B: 0xf4, A: 255}
+	controlsForeground = css.Color{R: 0x20, G: 0x21, B: 0x24, A: 255}
+	controlsTrack      = css.Color{R: 0xc4, G: 0xc4, B: 0xc4, A: 255}
+)
+
+// playGlyph is the play button of media placeholders
+const playGlyph = "►"
+
+// paintMedia draws the placeholder of a media element in its content box. A
+// video without a poster is a black box with a play button in its middle,
+// and the controls of an audio a gray bar with a play button at its start
+// and a seek track after it. A video with a poster is drawn as its image
+// instead.
+func paintMedia(node *layout.LayoutNode, list *PaintList) {
+	r := contentRect(node)
+	switch node.Media {
+	case layout.MediaVideo:
+		if node.Src != "" {
+			return
+		}
+		list.PushFillRect(r, videoBackground)
+		pushGlyph(list, playGlyph, r.X+r.W/2, r.Y+r.H/2, min(r.W, r.H)/3, css.ColorWhite)
+	case layout.MediaAudio:
+		list.PushFillRect(r, controlsBackground)
+		pushGlyph(list, playGlyph, r.X+r.H/2, r.Y+r.H/2, min(r.H/3, 16), controlsForeground)
+		track := layout.Rect{X: r.X + r.H, Y: r.Y + r.H/2 - 2, W: r.W - r.H*3/2, H: 4}
+		if track.W > 0 {
+			list.PushFillRect(track, controlsTrack)
+		}
+	}
+}
+
+// pushGlyph draws a glyph at a font size, centered on (x, y)
+func pushGlyph(list *PaintList, glyph string, x, y, size float32, color css.Color) {
+	if size <= 0 {
+		return
+	}
+	style := css.DefaultStyle()
+	style.FontSize = size
+	font := text.FontOf(&style)
+	w, h := text.Width(glyph, font), layout.LineHeight(style)
+	list.PushDrawText(layout.Rect{X: x - w/2, Y: y - h/2, W: w, H: h}, glyph, color, font)
+}
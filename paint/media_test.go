@@ -0,0 +1,43 @@
+package paint
+
+import "testing"
+
+func TestPaintMediaPlaceholders(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		`<html><body><video width="120" height="60"></video><audio controls></audio><video poster="p.png"></video></body></html>`,
+		`body { margin: 0; } audio { display: block; }`)
+
+	list := Paint(tree)
+	var fills, texts, images []PaintOp
+	for _, op := range list.Ops {
+		switch op.Kind {
+		case OpFillRect:
+			fills = append(fills, op)
+		case OpDrawText:
+			texts = append(texts, op)
+		case OpDrawImage:
+			images = append(images, op)
+		}
+	}
+	if len(fills) != 3 || len(texts) != 2 || len(images) != 1 {
+		t.Fatalf("expected 3 fills, 2 play buttons and the poster, got %d, %d and %d", len(fills), len(texts), len(images))
+	}
+
+	// The video is black with a play button in its middle
+	video, play := fills[0], texts[0]
+	if video.Color != videoBackground || video.Rect.W != 120 || video.Rect.H != 60 {
+		t.Errorf("expected a black 120x60 video, got %v in %v", video.Rect, video.Color)
+	}
+	if list.Text(play) != playGlyph || play.Rect.X+play.Rect.W/2 != 60 || play.Rect.Y+play.Rect.H/2 != 30 {
+		t.Errorf("expected a play button in the middle of the video, got %q at %v", list.Text(play), play.Rect)
+	}
+
+	// The audio controls are a bar with a play button before a seek track
+	bar, track := fills[1], fills[2]
+	if bar.Color != controlsBackground || bar.Rect.W != 300 || bar.Rect.H != 54 {
+		t.Errorf("expected a 300x54 bar of controls, got %v", bar.Rect)
+	}
+	if track.Color != controlsTrack || track.Rect.X <= texts[1].Rect.X+texts[1].Rect.W || track.Rect.X+track.Rect.W > bar.Rect.X+bar.Rect.W {
+		t.Errorf("expected the seek track after the play button, got %v", track.Rect)
+	}
+}
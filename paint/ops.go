@@ -2,9 +2,11 @@ package paint
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/myuon/penny/css"
 	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
 )
 
 type PaintOpKind uint8
@@ -14,6 +16,12 @@ const (
 	OpStrokeRect
 	OpDrawText
 	OpClipRect
+	OpPushLayer
+	OpPopLayer
+	OpDrawImage
+	OpFillGradient
+	OpPopClip
+	OpDrawSidewaysText
 )
 
 func (k PaintOpKind) String() string {
@@ -26,21 +34,72 @@ func (k PaintOpKind) String() string {
 		return "DrawText"
 	case OpClipRect:
 		return "ClipRect"
+	case OpPushLayer:
+		return "PushLayer"
+	case OpPopLayer:
+		return "PopLayer"
+	case OpDrawImage:
+		return "DrawImage"
+	case OpFillGradient:
+		return "FillGradient"
+	case OpPopClip:
+		return "PopClip"
+	case OpDrawSidewaysText:
+		return "DrawSidewaysText"
 	default:
 		return "Unknown"
 	}
 }
 
+// PaintOp is a single drawing operation. It holds no pointers: the text of
+// a DrawText op, the source of a DrawImage op, the gradient of a
+// FillGradient op and the Layer of a PushLayer op live in tables of their
+// PaintList, so the garbage collector never has to scan the ops of a large
+// page.
+//
+// The ops between a PushLayer and its PopLayer are drawn into a layer of
+// their own, which is composited through the layer's filter and clip. The
+// Rect of a PushLayer bounds what the layer draws. Likewise, the ops between
+// a ClipRect and its PopClip only draw inside the ClipRect's Rect.
 type PaintOp struct {
 	Kind     PaintOpKind
-	Rect     layout.Rect
 	Color    css.Color
-	Text     string
-	FontSize float32
+	Rect     layout.Rect
+	Text     int32 // index into PaintList.Texts, for DrawText
+	Layer    int32 // index into PaintList.Layers, for PushLayer
+	Source   int32 // index into PaintList.Sources, for DrawImage
+	Gradient int32 // index into PaintList.Gradients, for FillGradient
+	Font     text.Font
 }
 
+// PaintList is the flat list of operations produced by Paint. It is plain
+// data: once painting has finished, Rasterize and Dump only read it, so one
+// list may be rasterized from several goroutines.
+//
+// Ops refer to their list's Texts and Layers, so ops can't be copied from
+// one list to another; paint into the target list with PaintInto instead.
 type PaintList struct {
-	Ops []PaintOp
+	Ops     []PaintOp
+	Texts   []string
+	Layers  []Layer
+	Sources []string
+	// Gradients are the gradients of FillGradient ops
+	Gradients []*css.Gradient
+	// Images are the images DrawImage ops draw, by their source. Without
+	// it, they draw nothing.
+	Images *ImageCache
+
+	painter listPainter // of PaintInto
+}
+
+// Layer is how a layer is composited: filtered, faded, then clipped. Filter
+// and Clip may be nil.
+type Layer struct {
+	Filter *css.Filter
+	Clip   *ClipShape
+	// Opacity is multiplied into the layer's alpha. 0 leaves the layer
+	// opaque, as a fully transparent element isn't painted at all.
+	Opacity float32
 }
 
 func NewPaintList() *PaintList {
@@ -49,6 +108,58 @@ func NewPaintList() *PaintList {
 	}
 }
 
+// reserve grows an empty list for the ops of a tree, most nodes painting an
+// op or two and each text at least one, so that painting doesn't regrow it
+// as often
+func (p *PaintList) reserve(tree *layout.LayoutTree) {
+	texts := 0
+	for i := range tree.Nodes {
+		if tree.Nodes[i].Text != "" {
+			texts++
+		}
+	}
+	p.Ops = make([]PaintOp, 0, len(tree.Nodes)*2)
+	p.Texts = make([]string, 0, texts)
+}
+
+var listPool = sync.Pool{
+	New: func() any { return NewPaintList() },
+}
+
+// AcquirePaintList returns an empty list, reusing the storage of a released
+// one when possible. Rendering continuously, as the GUI does, then doesn't
+// allocate a new list every frame.
+func AcquirePaintList() *PaintList {
+	return listPool.Get().(*PaintList)
+}
+
+// Release empties the list and returns it to the pool used by
+// AcquirePaintList. The list must not be used afterwards.
+func (p *PaintList) Release() {
+	p.Reset()
+	listPool.Put(p)
+}
+
+// Reset empties the list, keeping its storage
+func (p *PaintList) Reset() {
+	p.Ops = p.Ops[:0]
+	clear(p.Texts) // don't keep the strings of the old page alive
+	p.Texts = p.Texts[:0]
+	clear(p.Layers)
+	p.Layers = p.Layers[:0]
+	clear(p.Sources)
+	p.Sources = p.Sources[:0]
+	clear(p.Gradients)
+	p.Gradients = p.Gradients[:0]
+	p.Images = nil
+}
+
+// Text returns the text drawn by a DrawText or DrawSidewaysText op of the
+// list
+func (p *PaintList) Text(op PaintOp) string {
+	return p.Texts[op.Text]
+}
+
 func (p *PaintList) PushFillRect(rect layout.Rect, color css.Color) {
 	p.Ops = append(p.Ops, PaintOp{
 		Kind:  OpFillRect,
@@ -65,16 +176,64 @@ func (p *PaintList) PushStrokeRect(rect layout.Rect, color css.Color) {
 	})
 }
 
-func (p *PaintList) PushDrawText(rect layout.Rect, text string, color css.Color, fontSize float32) {
+func (p *PaintList) PushDrawText(rect layout.Rect, s string, color css.Color, font text.Font) {
+	p.Texts = append(p.Texts, s)
+	p.Ops = append(p.Ops, PaintOp{
+		Kind:  OpDrawText,
+		Rect:  rect,
+		Text:  int32(len(p.Texts) - 1),
+		Color: color,
+		Font:  font,
+	})
+}
+
+// PushDrawSidewaysText draws a line of text turned a quarter turn
+// clockwise, running down rect, as a line of vertical text sets the
+// characters that aren't upright. The line's top is at the right of rect.
+func (p *PaintList) PushDrawSidewaysText(rect layout.Rect, s string, color css.Color, font text.Font) {
+	p.Texts = append(p.Texts, s)
+	p.Ops = append(p.Ops, PaintOp{
+		Kind:  OpDrawSidewaysText,
+		Rect:  rect,
+		Text:  int32(len(p.Texts) - 1),
+		Color: color,
+		Font:  font,
+	})
+}
+
+// PushDrawImage draws the image of src scaled to rect
+func (p *PaintList) PushDrawImage(rect layout.Rect, src string) {
+	p.Sources = append(p.Sources, src)
+	p.Ops = append(p.Ops, PaintOp{
+		Kind:   OpDrawImage,
+		Rect:   rect,
+		Source: int32(len(p.Sources) - 1),
+	})
+}
+
+// Source returns the source of the image drawn by a DrawImage op of the
+// list
+func (p *PaintList) Source(op PaintOp) string {
+	return p.Sources[op.Source]
+}
+
+// PushFillGradient fills rect with a gradient, which is sized to the rect
+func (p *PaintList) PushFillGradient(rect layout.Rect, g *css.Gradient) {
+	p.Gradients = append(p.Gradients, g)
 	p.Ops = append(p.Ops, PaintOp{
-		Kind:     OpDrawText,
+		Kind:     OpFillGradient,
 		Rect:     rect,
-		Text:     text,
-		Color:    color,
-		FontSize: fontSize,
+		Gradient: int32(len(p.Gradients) - 1),
 	})
 }
 
+// GradientOf returns the gradient filled by a FillGradient op of the list
+func (p *PaintList) GradientOf(op PaintOp) *css.Gradient {
+	return p.Gradients[op.Gradient]
+}
+
+// PushClipRect clips the ops that follow to rect, inside any clip already
+// pushed, until PopClip
 func (p *PaintList) PushClipRect(rect layout.Rect) {
 	p.Ops = append(p.Ops, PaintOp{
 		Kind: OpClipRect,
@@ -82,6 +241,69 @@ func (p *PaintList) PushClipRect(rect layout.Rect) {
 	})
 }
 
+// PopClip removes the clip pushed last
+func (p *PaintList) PopClip() {
+	p.Ops = append(p.Ops, PaintOp{Kind: OpPopClip})
+}
+
+// PushLayer starts a layer and returns the index of its op, which PopLayer
+// takes to close it
+func (p *PaintList) PushLayer(layer Layer) int {
+	p.Layers = append(p.Layers, layer)
+	p.Ops = append(p.Ops, PaintOp{
+		Kind:  OpPushLayer,
+		Layer: int32(len(p.Layers) - 1),
+	})
+	return len(p.Ops) - 1
+}
+
+// PopLayer closes the layer started by the PushLayer op at index start,
+// recording the bounds of what the layer draws in that op
+func (p *PaintList) PopLayer(start int) {
+	var bounds layout.Rect
+	depth := 0
+	for _, op := range p.Ops[start+1:] {
+		switch op.Kind {
+		case OpFillRect, OpStrokeRect, OpDrawText, OpDrawSidewaysText, OpDrawImage, OpFillGradient:
+			if depth == 0 {
+				bounds = unionRect(bounds, op.Rect)
+			}
+		case OpPushLayer:
+			// A nested layer has already been closed, so its op bounds all
+			// of it
+			if depth == 0 {
+				bounds = unionRect(bounds, p.layerExtent(op))
+			}
+			depth++
+		case OpPopLayer:
+			depth--
+		}
+	}
+	// The filter applies before the clip, so content just outside the clip
+	// can still be blurred into it
+	if layer := p.Layer(p.Ops[start]); layer.Clip != nil {
+		bounds = intersectRect(bounds, outsetRect(layer.Clip.Bounds, filterOutset(layer.Filter)))
+	}
+	p.Ops[start].Rect = bounds
+	p.Ops = append(p.Ops, PaintOp{Kind: OpPopLayer})
+}
+
+// layerExtent returns the area a closed layer covers once composited: its
+// filter can draw outside what the layer draws, and its clip can't
+func (p *PaintList) layerExtent(op PaintOp) layout.Rect {
+	layer := p.Layer(op)
+	extent := outsetRect(op.Rect, filterOutset(layer.Filter))
+	if layer.Clip != nil {
+		extent = intersectRect(extent, layer.Clip.Bounds)
+	}
+	return extent
+}
+
+// Layer returns the layer started by a PushLayer op of the list
+func (p *PaintList) Layer(op PaintOp) Layer {
+	return p.Layers[op.Layer]
+}
+
 func (p *PaintList) Dump() string {
 	var result string
 	for i, op := range p.Ops {
@@ -94,9 +316,26 @@ func (p *PaintList) Dump() string {
 		case OpStrokeRect:
 			result += fmt.Sprintf("%d: StrokeRect %s %s\n", i, rect, color)
 		case OpDrawText:
-			result += fmt.Sprintf("%d: DrawText %s %s fontSize=%.1f \"%s\"\n", i, rect, color, op.FontSize, op.Text)
+			result += fmt.Sprintf("%d: DrawText %s %s fontSize=%.1f \"%s\"\n", i, rect, color, op.Font.Size, p.Text(op))
+		case OpDrawSidewaysText:
+			result += fmt.Sprintf("%d: DrawSidewaysText %s %s fontSize=%.1f \"%s\"\n", i, rect, color, op.Font.Size, p.Text(op))
+		case OpDrawImage:
+			result += fmt.Sprintf("%d: DrawImage %s \"%s\"\n", i, rect, p.Source(op))
+		case OpFillGradient:
+			result += fmt.Sprintf("%d: FillGradient %s %s\n", i, rect, p.GradientOf(op))
 		case OpClipRect:
 			result += fmt.Sprintf("%d: ClipRect %s\n", i, rect)
+		case OpPushLayer:
+			layer := p.Layer(op)
+			result += fmt.Sprintf("%d: PushLayer %s filter=%s clip=%s", i, rect, layer.Filter, layer.Clip)
+			if layer.Opacity > 0 && layer.Opacity < 1 {
+				result += fmt.Sprintf(" opacity=%g", layer.Opacity)
+			}
+			result += "\n"
+		case OpPopLayer:
+			result += fmt.Sprintf("%d: PopLayer\n", i)
+		case OpPopClip:
+			result += fmt.Sprintf("%d: PopClip\n", i)
 		}
 	}
 	return result
@@ -0,0 +1,93 @@
+package paint
+
+import (
+	"image"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
+)
+
+func TestPaintListTexts(t *testing.T) {
+	list := AcquirePaintList()
+	list.PushFillRect(layout.Rect{W: 10, H: 10}, css.ColorWhite)
+	list.PushDrawText(layout.Rect{}, "first", css.Color{A: 255}, text.Font{Size: 12})
+	list.PushDrawText(layout.Rect{}, "second", css.Color{A: 255}, text.Font{Size: 12})
+
+	if got := list.Text(list.Ops[1]); got != "first" {
+		t.Errorf("expected %q, got %q", "first", got)
+	}
+	if got := list.Text(list.Ops[2]); got != "second" {
+		t.Errorf("expected %q, got %q", "second", got)
+	}
+
+	list.Reset()
+	if len(list.Ops) != 0 || len(list.Texts) != 0 {
+		t.Errorf("expected an empty list after Reset, got %d ops and %d texts", len(list.Ops), len(list.Texts))
+	}
+	list.PushDrawText(layout.Rect{}, "third", css.Color{A: 255}, text.Font{Size: 12})
+	if got := list.Text(list.Ops[0]); got != "third" {
+		t.Errorf("expected %q after reuse, got %q", "third", got)
+	}
+	list.Release()
+}
+
+func TestRasterizeSidewaysText(t *testing.T) {
+	list := NewPaintList()
+	list.PushDrawSidewaysText(layout.Rect{X: 10, Y: 10, W: 20, H: 60}, "MMMM", css.Color{A: 255}, text.Font{Size: 16})
+	img := Rasterize(list, 40, 80)
+
+	// The letters run down the page, in the rect
+	inked := image.Rectangle{}
+	for y := range 80 {
+		for x := range 40 {
+			if img.RGBAAt(x, y).A > 0 {
+				inked = inked.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	if inked.Dy() <= inked.Dx() || !inked.In(image.Rect(10, 10, 30, 70)) {
+		t.Errorf("expected a column of ink in the rect, got %v", inked)
+	}
+}
+
+func TestRasterizeClipStack(t *testing.T) {
+	black := css.Color{A: 255}
+	list := NewPaintList()
+	list.PushClipRect(layout.Rect{X: 10, Y: 10, W: 20, H: 20})
+	list.PushClipRect(layout.Rect{X: 20, Y: 0, W: 20, H: 40})
+	list.PushFillRect(layout.Rect{W: 40, H: 40}, black)
+	list.PopClip()
+	list.PushDrawText(layout.Rect{X: 0, Y: 10}, "MMMMMM", black, text.Font{Size: 16})
+	list.PopClip()
+	list.PushFillRect(layout.Rect{X: 35, Y: 35, W: 5, H: 5}, black)
+	img := Rasterize(list, 40, 40)
+
+	// The fill is inside both clips, the text inside the first only, and
+	// the last fill isn't clipped at all
+	tests := []struct {
+		x, y   int
+		filled bool
+	}{
+		{25, 15, true},
+		{15, 35, false},
+		{35, 15, false},
+		{5, 20, false},
+		{37, 37, true},
+	}
+	for _, tt := range tests {
+		if filled := img.RGBAAt(tt.x, tt.y).A > 0; filled != tt.filled {
+			t.Errorf("(%d, %d): expected filled %v, got %v", tt.x, tt.y, tt.filled, filled)
+		}
+	}
+	inked := false
+	for x := 10; x < 20; x++ {
+		for y := 10; y < 30; y++ {
+			inked = inked || img.RGBAAt(x, y).A > 0
+		}
+	}
+	if !inked {
+		t.Error("expected the text to be drawn inside the first clip")
+	}
+}
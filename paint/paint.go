@@ -1,6 +1,9 @@
 package paint
 
 import (
+	"cmp"
+	"slices"
+
 	"github.com/myuon/penny/css"
 	"github.com/myuon/penny/layout"
 )
@@ -8,25 +11,210 @@ import (
 // Paint generates paint operations from a layout tree
 func Paint(tree *layout.LayoutTree) *PaintList {
 	list := NewPaintList()
+	list.reserve(tree)
+	PaintInto(list, tree)
+	return list
+}
 
+// PaintInto appends the paint operations of a layout tree to list
+func PaintInto(list *PaintList, tree *layout.LayoutTree) {
 	if tree.Root == layout.InvalidLayoutNodeID {
-		return list
+		return
 	}
+	// The painter is kept in the list, so that painting into a pooled list
+	// allocates neither it nor its layer stack
+	list.painter.list = list
+	paintContext(&list.painter, tree, tree.Root)
+}
 
-	paintNode(tree, tree.Root, list)
-	return list
+// painter is handed the nodes of a tree in painting order, back to front,
+// along with the layers and clips they are painted in. Painting and hit
+// testing share the order through it.
+type painter interface {
+	// skips reports whether to leave out a node and its subtree
+	skips(node *layout.LayoutNode) bool
+	node(node *layout.LayoutNode)
+	// pushLayer starts the layer of a node with a filter, clip-path or
+	// opacity, holding its subtree
+	pushLayer(node *layout.LayoutNode)
+	popLayer()
+	pushClip(r layout.Rect)
+	popClip()
+}
+
+// listPainter paints nodes into a paint list
+type listPainter struct {
+	list   *PaintList
+	layers []int // the starts of the layers pushed
 }
 
-func paintNode(tree *layout.LayoutTree, nodeID layout.LayoutNodeID, list *PaintList) {
-	node := tree.GetNode(nodeID)
-	if node == nil {
+// skips leaves out a fully transparent node, which paints nothing
+func (p *listPainter) skips(node *layout.LayoutNode) bool { return node.Style.Opacity <= 0 }
+func (p *listPainter) node(node *layout.LayoutNode)       { paintNode(node, p.list) }
+
+func (p *listPainter) pushLayer(node *layout.LayoutNode) {
+	p.layers = append(p.layers, p.list.PushLayer(Layer{
+		Filter:  node.Style.Filter,
+		Clip:    resolveClip(node.Style.ClipPath, node.Rect),
+		Opacity: node.Style.Opacity,
+	}))
+}
+
+func (p *listPainter) popLayer() {
+	p.list.PopLayer(p.layers[len(p.layers)-1])
+	p.layers = p.layers[:len(p.layers)-1]
+}
+
+func (p *listPainter) pushClip(r layout.Rect) { p.list.PushClipRect(r) }
+func (p *listPainter) popClip()               { p.list.PopClip() }
+
+// paintContext paints a stacking context in CSS painting order: root's own
+// background and border, the stacked boxes in it with a negative z-index,
+// the boxes in flow, then the other stacked boxes, those with a z-index of
+// auto or 0 in tree order and then the rest by z-index. Stacked boxes are
+// positioned boxes and stacking contexts, which are painted whole where
+// they are stacked.
+//
+// A filtered or clipped node, or one with opacity below 1, paints its
+// subtree into a layer, and is a stacking context of its own, so the boxes
+// stacked inside it stay in its layer. A fully transparent node paints
+// nothing, but is still hit. A node whose overflow is clipped is painted as a stacking
+// context too, so that the boxes stacked inside it are painted before its
+// clip is popped.
+func paintContext(p painter, tree *layout.LayoutTree, root layout.LayoutNodeID) {
+	node := &tree.Nodes[root]
+	if p.skips(node) {
 		return
 	}
+	layered := hasLayer(node)
+	if layered {
+		p.pushLayer(node)
+	}
+	p.node(node)
+	clipped := clipsOverflow(tree, node)
+	if clipped {
+		p.pushClip(paddingRect(node))
+	}
+
+	stacked := stackedIn(tree, root)
+	slices.SortStableFunc(stacked, func(a, b layout.LayoutNodeID) int {
+		return cmp.Compare(zIndex(&tree.Nodes[a]), zIndex(&tree.Nodes[b]))
+	})
+	i := 0
+	for ; i < len(stacked) && zIndex(&tree.Nodes[stacked[i]]) < 0; i++ {
+		paintStacked(p, tree, stacked[i])
+	}
+	paintFlow(p, tree, root)
+	for _, id := range stacked[i:] {
+		paintStacked(p, tree, id)
+	}
+
+	if clipped {
+		p.popClip()
+	}
+	if layered {
+		p.popLayer()
+	}
+}
+
+// stackedIn returns the boxes stacked in the context of root, in tree
+// order. The boxes stacked inside a positioned box without a z-index are
+// stacked in the same context, and those inside a nested context in that.
+func stackedIn(tree *layout.LayoutTree, root layout.LayoutNodeID) []layout.LayoutNodeID {
+	var stacked []layout.LayoutNodeID
+	layout.Walk(tree, root, func(node *layout.LayoutNode, depth int) layout.WalkAction {
+		switch {
+		case node.ID == root:
+			return layout.WalkContinue
+		case isStacked(node):
+			stacked = append(stacked, node.ID)
+			if isContext(tree, node) {
+				return layout.WalkSkipChildren
+			}
+		case isContext(tree, node):
+			// Painted in flow, with what is stacked inside it
+			return layout.WalkSkipChildren
+		}
+		return layout.WalkContinue
+	})
+	return stacked
+}
 
+// paintStacked paints a stacked box: a whole context, or a positioned box
+// with what is in flow inside it
+func paintStacked(p painter, tree *layout.LayoutTree, id layout.LayoutNodeID) {
+	if isContext(tree, &tree.Nodes[id]) {
+		paintContext(p, tree, id)
+		return
+	}
+	p.node(&tree.Nodes[id])
+	paintFlow(p, tree, id)
+}
+
+// paintFlow paints the descendants of top in flow, in tree order, leaving
+// out the stacked boxes, which the context paints. The contexts that are
+// only clipped are painted in place.
+func paintFlow(p painter, tree *layout.LayoutTree, top layout.LayoutNodeID) {
+	layout.Walk(tree, top, func(node *layout.LayoutNode, depth int) layout.WalkAction {
+		switch {
+		case node.ID == top:
+			return layout.WalkContinue
+		case isStacked(node):
+			return layout.WalkSkipChildren
+		case isContext(tree, node):
+			paintContext(p, tree, node.ID)
+			return layout.WalkSkipChildren
+		}
+		p.node(node)
+		return layout.WalkContinue
+	})
+}
+
+func hasLayer(node *layout.LayoutNode) bool {
+	return node.Style.Filter != nil || node.Style.ClipPath != nil || node.Style.Opacity < 1
+}
+
+// isStackingContext reports whether a node is a stacking context: it has a
+// layer, or is positioned with a z-index
+func isStackingContext(node *layout.LayoutNode) bool {
+	return hasLayer(node) || node.Style.Position != css.PositionStatic && !node.Style.ZIndex.Auto
+}
+
+// isStacked reports whether a node is painted by its stacking context in
+// z-index order rather than in flow
+func isStacked(node *layout.LayoutNode) bool {
+	return node.Style.Position != css.PositionStatic || isStackingContext(node)
+}
+
+// zIndex returns the z-index a stacked box is painted at
+func zIndex(node *layout.LayoutNode) int32 {
+	if node.Style.Position == css.PositionStatic || node.Style.ZIndex.Auto {
+		return 0
+	}
+	return node.Style.ZIndex.Value
+}
+
+// isContext reports whether a node is painted with paintContext
+func isContext(tree *layout.LayoutTree, node *layout.LayoutNode) bool {
+	return isStackingContext(node) || clipsOverflow(tree, node)
+}
+
+// clipsOverflow reports whether what is inside a node is clipped to its
+// padding box. The overflow of the root applies to the viewport instead,
+// which clips anyway.
+func clipsOverflow(tree *layout.LayoutTree, node *layout.LayoutNode) bool {
+	return node.Style.Overflow.Clips() && node.ID != tree.Root
+}
+
+// paintNode paints a single node; its children are painted by the caller
+func paintNode(node *layout.LayoutNode, list *PaintList) {
 	// Paint background
 	if node.Style.Background.A > 0 {
 		list.PushFillRect(node.Rect, node.Style.Background)
 	}
+	if node.Style.BackgroundImage != nil {
+		list.PushFillGradient(node.Rect, node.Style.BackgroundImage)
+	}
 
 	// Paint border
 	if node.Style.Border.Top > 0 || node.Style.Border.Right > 0 ||
@@ -34,20 +222,57 @@ func paintNode(tree *layout.LayoutTree, nodeID layout.LayoutNodeID, list *PaintL
 		paintBorder(node, list)
 	}
 
+	// Paint the image of a replaced element, the bar of a gauge, or the
+	// placeholder of a media element, inside its padding
+	if node.Replaced && node.Src != "" {
+		list.PushDrawImage(contentRect(node), node.Src)
+	}
+	if node.Gauge.Kind != layout.GaugeNone {
+		paintGauge(node, list)
+	}
+	if node.Media != layout.MediaNone {
+		paintMedia(node, list)
+	}
+
+	// Paint the marker of a list item
+	if node.Marker.Text != "" {
+		pushText(list, textSpan{Text: node.Marker.Text, Rect: node.Marker.Rect, Style: node.Style}, node.Style.Color)
+	}
+
 	// Paint text
 	if node.Text != "" {
-		textRect := layout.Rect{
-			X: node.Rect.X + node.Style.Padding.Left,
-			Y: node.Rect.Y + node.Style.Padding.Top,
-			W: node.Rect.W - node.Style.Padding.Left - node.Style.Padding.Right,
-			H: node.Rect.H - node.Style.Padding.Top - node.Style.Padding.Bottom,
+		// Most text is a single span, which needs no allocation
+		var one [1]textSpan
+		for _, span := range textSpans(one[:0], node) {
+			if span.Style.Background.A > 0 {
+				list.PushFillRect(span.ink(), span.Style.Background)
+			}
+			pushDecorations(list, span, false)
+			pushText(list, span, span.Style.Color)
+			pushDecorations(list, span, true)
 		}
-		list.PushDrawText(textRect, node.Text, node.Style.Color, node.Style.FontSize)
 	}
+}
+
+// paddingRect returns the box of a node inside its border
+func paddingRect(node *layout.LayoutNode) layout.Rect {
+	b := node.Style.Border
+	return layout.Rect{
+		X: node.Rect.X + b.Left,
+		Y: node.Rect.Y + b.Top,
+		W: node.Rect.W - b.Left - b.Right,
+		H: node.Rect.H - b.Top - b.Bottom,
+	}
+}
 
-	// Paint children
-	for _, childID := range node.Children {
-		paintNode(tree, childID, list)
+// contentRect returns the box of a node inside its padding, where its text
+// is drawn
+func contentRect(node *layout.LayoutNode) layout.Rect {
+	return layout.Rect{
+		X: node.Rect.X + node.Style.Padding.Left,
+		Y: node.Rect.Y + node.Style.Padding.Top,
+		W: node.Rect.W - node.Style.Padding.Left - node.Style.Padding.Right,
+		H: node.Rect.H - node.Style.Padding.Top - node.Style.Padding.Bottom,
 	}
 }
 
@@ -0,0 +1,172 @@
+package paint
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+)
+
+func TestPaintPositionedOnTop(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div class="a"></div><div class="b"></div></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := css.Parse(`div { height: 20px; }
+		.a { position: absolute; top: 0; width: 50px; background-color: red; }
+		.b { background-color: blue; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := layout.BuildLayoutTree(d, sheet)
+	layout.ComputeLayout(tree, 200, 200)
+
+	// The absolute box comes first in the tree but is painted over the
+	// box that follows it in the flow
+	var fills []css.Color
+	for _, op := range Paint(tree).Ops {
+		if op.Kind == OpFillRect {
+			fills = append(fills, op.Color)
+		}
+	}
+	red, blue := css.Color{R: 255, A: 255}, css.Color{B: 255, A: 255}
+	if len(fills) < 2 || fills[len(fills)-2] != blue || fills[len(fills)-1] != red {
+		t.Errorf("expected blue then red painted last, got %v", fills)
+	}
+}
+
+func TestPaintStackingOrder(t *testing.T) {
+	d, err := dom.ParseString(`<html><body>
+		<div class="a"><div class="a1"></div></div>
+		<div class="b"></div><div class="c"></div><div class="d"></div><div class="e"></div>
+	</body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := css.Parse(`div { height: 10px; width: 10px; }
+		.a { position: absolute; z-index: 2; background-color: rgb(1, 0, 0); }
+		.a1 { position: absolute; z-index: 100; background-color: rgb(2, 0, 0); }
+		.b { position: relative; background-color: rgb(3, 0, 0); }
+		.c { opacity: 0.5; background-color: rgb(4, 0, 0); }
+		.d { position: absolute; z-index: -1; background-color: rgb(5, 0, 0); }
+		.e { background-color: rgb(6, 0, 0); }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := layout.BuildLayoutTree(d, sheet)
+	layout.ComputeLayout(tree, 200, 200)
+
+	// The negative z-index goes under the flow, and the contexts without a
+	// z-index over it in tree order. The z-index of a1 only orders it
+	// inside a, its stacking context.
+	var order []uint8
+	for _, op := range Paint(tree).Ops {
+		if op.Kind == OpFillRect {
+			order = append(order, op.Color.R)
+		}
+	}
+	want := []uint8{5, 6, 3, 4, 1, 2}
+	if !slices.Equal(order, want) {
+		t.Errorf("expected fills in order %v, got %v", want, order)
+	}
+}
+
+func TestRasterizeBlendsOver(t *testing.T) {
+	list := NewPaintList()
+	list.PushFillRect(layout.Rect{W: 10, H: 10}, css.Color{R: 255, A: 255})
+	list.PushFillRect(layout.Rect{W: 10, H: 10}, css.Color{B: 255, A: 128})
+	img := Rasterize(list, 10, 10)
+	c := img.RGBAAt(5, 5)
+	if c.A != 255 || c.R < 120 || c.R > 135 || c.B < 120 || c.B > 135 {
+		t.Errorf("expected half blue over red, got %v", c)
+	}
+}
+
+func TestPaintOpacity(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div class="a"><div class="b"></div></div><div class="c"></div></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := css.Parse(`body { margin: 0; } div { height: 10px; }
+		.a { opacity: 0.5; background-color: red; } .b { background-color: red; } .c { opacity: 0; background-color: red; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := layout.BuildLayoutTree(d, sheet)
+	layout.ComputeLayout(tree, 20, 20)
+	img := Rasterize(Paint(tree), 20, 20)
+
+	// The child is painted over its parent in the layer, which is faded as
+	// a whole, rather than each being faded and blended
+	if c := img.RGBAAt(5, 5); c.R < 120 || c.R > 135 || c.A < 120 || c.A > 135 {
+		t.Errorf("expected the layer at half opacity, got %v", c)
+	}
+	if c := img.RGBAAt(5, 15); c.A != 0 {
+		t.Errorf("expected a transparent element to paint nothing, got %v", c)
+	}
+}
+
+func TestPaintOverflowHidden(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><div class="a"><div class="b"></div><div class="c"></div></div></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := css.Parse(`body { margin: 0; }
+		.a { overflow: hidden; width: 20px; height: 10px; border: 2px solid blue; }
+		.b { width: 40px; height: 30px; background-color: red; }
+		.c { position: relative; height: 10px; background-color: green; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := layout.BuildLayoutTree(d, sheet)
+	layout.ComputeLayout(tree, 50, 50)
+	list := Paint(tree)
+
+	var kinds []string
+	for _, op := range list.Ops {
+		kinds = append(kinds, op.Kind.String())
+	}
+	// The positioned box is painted inside the clip
+	want := "FillRect FillRect FillRect FillRect ClipRect FillRect FillRect PopClip"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	img := Rasterize(list, 50, 50)
+	if c := img.RGBAAt(10, 8); c.R != 255 {
+		t.Errorf("expected the child inside the padding box, got %v", c)
+	}
+	if c := img.RGBAAt(30, 8); c.A != 0 {
+		t.Errorf("expected the child clipped on the right, got %v", c)
+	}
+	if c := img.RGBAAt(10, 20); c.A != 0 {
+		t.Errorf("expected the positioned child clipped below, got %v", c)
+	}
+}
+
+func TestPaintListMarker(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		`<html><body><ol><li>one</li></ol></body></html>`,
+		`body { margin: 0; } ol { padding-left: 40px; list-style-type: decimal; } li { display: list-item; color: red; }`)
+
+	list := Paint(tree)
+	var texts []PaintOp
+	for _, op := range list.Ops {
+		if op.Kind == OpDrawText {
+			texts = append(texts, op)
+		}
+	}
+	if len(texts) != 2 || list.Text(texts[0]) != "1. " || list.Text(texts[1]) != "one" {
+		t.Fatalf("expected the marker drawn before the item's text, got %d ops", len(texts))
+	}
+	marker, item := texts[0], texts[1]
+	if marker.Rect.X+marker.Rect.W != item.Rect.X || marker.Rect.Y != item.Rect.Y {
+		t.Errorf("expected the marker to end where the text starts, on its line, got %v and %v", marker.Rect, item.Rect)
+	}
+	if marker.Color != (css.Color{R: 255, A: 255}) {
+		t.Errorf("expected the marker in the item's color, got %v", marker.Color)
+	}
+}
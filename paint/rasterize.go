@@ -6,29 +6,84 @@ import (
 	"image/png"
 	"os"
 
-	"golang.org/x/image/font"
-	"golang.org/x/image/font/basicfont"
-	"golang.org/x/image/math/fixed"
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/text"
+	"golang.org/x/image/draw"
 )
 
 // Rasterize converts paint operations to an image
 func Rasterize(list *PaintList, width, height int) *image.RGBA {
 	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rasterizeOps(img, img.Bounds(), list)
+	return img
+}
 
+// rasterizeOps draws the operations onto img, whose bounds are in page
+// coordinates. Operations are clipped to those bounds. img may be part of
+// view, the whole area being shown, which the spread of filters is limited
+// by, so that they look the same however the view is redrawn.
+//
+// The ops of a layer are drawn into an image of their own, which is filtered
+// and composited onto the image below it when the layer is popped. A clip
+// narrows the image ops are drawn into to a view of part of it, which every
+// op already keeps to.
+func rasterizeOps(img *image.RGBA, view image.Rectangle, list *PaintList) {
+	type open struct {
+		parent *image.RGBA
+		layer  Layer
+	}
+	var layers []open
+	var clips []*image.RGBA // the targets clips were pushed onto
+	target := img
 	for _, op := range list.Ops {
 		switch op.Kind {
 		case OpFillRect:
-			fillRect(img, op)
+			fillRect(target, op)
 		case OpStrokeRect:
-			strokeRect(img, op)
+			strokeRect(target, op)
 		case OpDrawText:
-			drawText(img, op)
+			drawText(target, op, list.Text(op))
+		case OpDrawSidewaysText:
+			drawSidewaysText(target, op, list.Text(op))
+		case OpDrawImage:
+			drawImage(target, op, list)
+		case OpFillGradient:
+			fillGradient(target, op, list.GradientOf(op))
 		case OpClipRect:
-			// TODO: implement clipping
+			clips = append(clips, target)
+			target = target.SubImage(pixelRect(op.Rect)).(*image.RGBA)
+		case OpPopClip:
+			target = clips[len(clips)-1]
+			clips = clips[:len(clips)-1]
+		case OpPushLayer:
+			layer := list.Layer(op)
+			layer.Filter = limitFilter(layer.Filter, filterLimit(view))
+			layers = append(layers, open{target, layer})
+			target = newLayer(target, view, op, layer.Filter)
+		case OpPopLayer:
+			l := layers[len(layers)-1]
+			layers = layers[:len(layers)-1]
+			compositeLayer(l.parent, target, l.layer)
+			target = l.parent
 		}
 	}
+}
 
-	return img
+// drawImage draws the image of a DrawImage op scaled to its rect. An image
+// that isn't in the list's Images, or failed to load, draws nothing.
+func drawImage(img *image.RGBA, op PaintOp, list *PaintList) {
+	if list.Images == nil {
+		return
+	}
+	dst := image.Rect(int(op.Rect.X), int(op.Rect.Y), int(op.Rect.X+op.Rect.W), int(op.Rect.Y+op.Rect.H))
+	if dst.Empty() || !dst.Overlaps(img.Bounds()) {
+		return
+	}
+	src, err := list.Images.Scaled(list.Source(op), dst.Dx(), dst.Dy())
+	if err != nil {
+		return
+	}
+	draw.ApproxBiLinear.Scale(img, dst, src, src.Bounds(), draw.Over, nil)
 }
 
 // SavePNG saves the image to a PNG file
@@ -42,78 +97,67 @@ func SavePNG(img *image.RGBA, path string) error {
 	return png.Encode(file, img)
 }
 
+// fillRect fills the rect of an op with its color, composited over what is
+// already drawn
 func fillRect(img *image.RGBA, op PaintOp) {
-	col := color.RGBA{op.Color.R, op.Color.G, op.Color.B, op.Color.A}
-
-	x0 := int(op.Rect.X)
-	y0 := int(op.Rect.Y)
-	x1 := int(op.Rect.X + op.Rect.W)
-	y1 := int(op.Rect.Y + op.Rect.H)
-
-	bounds := img.Bounds()
-	if x0 < bounds.Min.X {
-		x0 = bounds.Min.X
-	}
-	if y0 < bounds.Min.Y {
-		y0 = bounds.Min.Y
-	}
-	if x1 > bounds.Max.X {
-		x1 = bounds.Max.X
-	}
-	if y1 > bounds.Max.Y {
-		y1 = bounds.Max.Y
-	}
-
-	for y := y0; y < y1; y++ {
-		for x := x0; x < x1; x++ {
-			img.Set(x, y, col)
-		}
-	}
+	r := image.Rect(int(op.Rect.X), int(op.Rect.Y), int(op.Rect.X+op.Rect.W), int(op.Rect.Y+op.Rect.H))
+	draw.Draw(img, r, image.NewUniform(nrgba(op.Color)), image.Point{}, draw.Over)
 }
 
+// strokeRect draws a 1px outline inside the rect of an op, composited over
+// what is already drawn. Each pixel is drawn once, corners included.
 func strokeRect(img *image.RGBA, op PaintOp) {
-	col := color.RGBA{op.Color.R, op.Color.G, op.Color.B, op.Color.A}
-
-	x0 := int(op.Rect.X)
-	y0 := int(op.Rect.Y)
-	x1 := int(op.Rect.X + op.Rect.W)
-	y1 := int(op.Rect.Y + op.Rect.H)
-
-	// Top edge
-	for x := x0; x < x1; x++ {
-		img.Set(x, y0, col)
+	src := image.NewUniform(nrgba(op.Color))
+	x0, y0 := int(op.Rect.X), int(op.Rect.Y)
+	x1, y1 := int(op.Rect.X+op.Rect.W), int(op.Rect.Y+op.Rect.H)
+	if x1 <= x0 || y1 <= y0 {
+		return
 	}
-	// Bottom edge
-	for x := x0; x < x1; x++ {
-		img.Set(x, y1-1, col)
+	edges := []image.Rectangle{
+		image.Rect(x0, y0, x1, y0+1),     // top
+		image.Rect(x0, y1-1, x1, y1),     // bottom
+		image.Rect(x0, y0+1, x0+1, y1-1), // left
+		image.Rect(x1-1, y0+1, x1, y1-1), // right
 	}
-	// Left edge
-	for y := y0; y < y1; y++ {
-		img.Set(x0, y, col)
+	if x1-x0 == 1 {
+		edges = edges[:3]
 	}
-	// Right edge
-	for y := y0; y < y1; y++ {
-		img.Set(x1-1, y, col)
+	if y1-y0 == 1 {
+		edges = edges[:1]
+	}
+	for _, edge := range edges {
+		draw.Draw(img, edge, src, image.Point{}, draw.Over)
 	}
 }
 
-func drawText(img *image.RGBA, op PaintOp) {
-	face := basicfont.Face7x13
-	col := color.RGBA{op.Color.R, op.Color.G, op.Color.B, op.Color.A}
+// nrgba returns a CSS color, whose channels aren't premultiplied, as a Go
+// color
+func nrgba(c css.Color) color.NRGBA {
+	return color.NRGBA{c.R, c.G, c.B, c.A}
+}
 
-	drawer := &font.Drawer{
-		Dst:  img,
-		Src:  image.NewUniform(col),
-		Face: face,
-	}
+func drawText(img *image.RGBA, op PaintOp, s string) {
+	col := nrgba(op.Color)
 
-	// Position text with baseline offset
+	// The rect is the top of the line; the dot goes on its baseline
 	x := int(op.Rect.X)
-	y := int(op.Rect.Y + op.FontSize) // Approximate baseline
+	y := int(op.Rect.Y + text.MetricsOf(op.Font).Baseline())
 
-	drawer.Dot = fixed.Point26_6{
-		X: fixed.I(x),
-		Y: fixed.I(y),
-	}
-	drawer.DrawString(op.Text)
+	dot := image.Pt(x, y)
+	run := textRuns.get(s, op.Font, img.Bounds().Sub(dot))
+	run.draw(img, dot, image.NewUniform(col))
+}
+
+// drawSidewaysText draws a run turned a quarter turn clockwise, with the
+// top of its line at the right of the op's rect
+func drawSidewaysText(img *image.RGBA, op PaintOp, s string) {
+	col := nrgba(op.Color)
+	x := int(op.Rect.X + op.Rect.W - text.MetricsOf(op.Font).Baseline())
+	y := int(op.Rect.Y)
+
+	// Turned back, the part of img right of the dot is above the baseline
+	dot := image.Pt(x, y)
+	r := img.Bounds().Sub(dot)
+	run := textRuns.get(s, op.Font, image.Rect(r.Min.Y, -r.Max.X, r.Max.Y, -r.Min.X))
+	run.drawSideways(img, dot, image.NewUniform(col))
 }
@@ -0,0 +1,64 @@
+package paint
+
+import (
+	"image"
+	"image/draw"
+)
+
+// RasterizeRect redraws rect of dst, a viewport onto the page scrolled to
+// scroll. Pixels inside rect are cleared and repainted from list; the rest of
+// dst is left untouched.
+func RasterizeRect(dst *image.RGBA, list *PaintList, scroll image.Point, rect image.Rectangle) {
+	rect = rect.Intersect(dst.Bounds())
+	if rect.Empty() {
+		return
+	}
+
+	// View dst in page coordinates, so the operations can be drawn as they
+	// are and land scrolled into place
+	page := &image.RGBA{Pix: dst.Pix, Stride: dst.Stride, Rect: dst.Rect.Add(scroll)}
+	region := page.SubImage(rect.Add(scroll)).(*image.RGBA)
+
+	draw.Draw(region, region.Bounds(), image.Transparent, image.Point{}, draw.Src)
+	rasterizeOps(region, page.Bounds(), list)
+}
+
+// Scroll updates dst, which shows the page scrolled to from, to show it
+// scrolled to to. Pixels that stay visible are moved rather than redrawn, so
+// only the newly exposed strips are rasterized.
+func Scroll(dst *image.RGBA, list *PaintList, from, to image.Point) {
+	delta := to.Sub(from)
+	if delta == (image.Point{}) {
+		return
+	}
+
+	bounds := dst.Bounds()
+	if abs(delta.X) >= bounds.Dx() || abs(delta.Y) >= bounds.Dy() {
+		RasterizeRect(dst, list, to, bounds)
+		return
+	}
+
+	// draw.Draw copies in the right direction when src and dst overlap
+	kept := bounds.Intersect(bounds.Sub(delta))
+	draw.Draw(dst, kept, dst, kept.Min.Add(delta), draw.Src)
+
+	switch {
+	case delta.Y > 0:
+		RasterizeRect(dst, list, to, image.Rect(bounds.Min.X, bounds.Max.Y-delta.Y, bounds.Max.X, bounds.Max.Y))
+	case delta.Y < 0:
+		RasterizeRect(dst, list, to, image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y-delta.Y))
+	}
+	switch {
+	case delta.X > 0:
+		RasterizeRect(dst, list, to, image.Rect(bounds.Max.X-delta.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y))
+	case delta.X < 0:
+		RasterizeRect(dst, list, to, image.Rect(bounds.Min.X, bounds.Min.Y, bounds.Min.X-delta.X, bounds.Max.Y))
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,53 @@
+package paint
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
+)
+
+func scrollTestList() *PaintList {
+	list := NewPaintList()
+	PaintBackground(list, 200, 400, css.ColorWhite)
+	for y := float32(0); y < 400; y += 30 {
+		list.PushFillRect(layout.Rect{X: 10 + y/10, Y: y, W: 50, H: 12.5}, css.Color{R: uint8(y), G: 80, B: 160, A: 255})
+		list.PushStrokeRect(layout.Rect{X: 70, Y: y + 3, W: 40, H: 20}, css.Color{R: 200, A: 255})
+		list.PushDrawText(layout.Rect{X: 120, Y: y}, "row", css.Color{A: 255}, text.Font{Size: 12})
+	}
+	return list
+}
+
+func TestScrollMatchesFullRedraw(t *testing.T) {
+	list := scrollTestList()
+	viewport := image.Rect(0, 0, 150, 100)
+
+	canvas := image.NewRGBA(viewport)
+	RasterizeRect(canvas, list, image.Point{}, viewport)
+
+	scroll := image.Point{}
+	for _, to := range []image.Point{{0, 7}, {0, 60}, {5, 45}, {0, 300}, {-3, 290}, {0, 0}} {
+		Scroll(canvas, list, scroll, to)
+		scroll = to
+
+		want := image.NewRGBA(viewport)
+		RasterizeRect(want, list, to, viewport)
+		if !bytes.Equal(canvas.Pix, want.Pix) {
+			t.Errorf("scrolled to %v: canvas differs from a full redraw", to)
+		}
+	}
+}
+
+func TestRasterizeRectMatchesRasterize(t *testing.T) {
+	list := scrollTestList()
+	want := Rasterize(list, 150, 100)
+
+	got := image.NewRGBA(image.Rect(0, 0, 150, 100))
+	RasterizeRect(got, list, image.Point{}, got.Bounds())
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Error("RasterizeRect differs from Rasterize")
+	}
+}
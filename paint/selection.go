@@ -0,0 +1,79 @@
+package paint
+
+import (
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
+)
+
+// PaintSelection paints the text between the text nodes from and to, both
+// included and in either order, as selected: over a background and in the
+// colors of its ::selection style. It is painted over a page already
+// painted into list.
+func PaintSelection(list *PaintList, tree *layout.LayoutTree, from, to dom.NodeID) {
+	if tree.Root == layout.InvalidLayoutNodeID {
+		return
+	}
+	inside := false
+	layout.Walk(tree, tree.Root, func(node *layout.LayoutNode, depth int) layout.WalkAction {
+		if node.Text == "" {
+			return layout.WalkContinue
+		}
+		end := node.DomNode == from || node.DomNode == to
+		if !inside && !end {
+			return layout.WalkContinue
+		}
+
+		for _, span := range textSpans(nil, node) {
+			list.PushFillRect(span.ink(), node.Style.Selection.Background)
+			pushDecorations(list, span, false)
+			pushText(list, span, node.Style.SelectionColor())
+			pushDecorations(list, span, true)
+		}
+
+		if end && (inside || from == to) {
+			return layout.WalkStop
+		}
+		inside = true
+		return layout.WalkContinue
+	})
+}
+
+// PaintCaret paints the text insertion caret of an element, in its
+// caret-color, after the end of its last text or at the start of its
+// content box when it has none
+func PaintCaret(list *PaintList, tree *layout.LayoutTree, element dom.NodeID) {
+	if tree.Root == layout.InvalidLayoutNodeID {
+		return
+	}
+	var box, textNode *layout.LayoutNode
+	layout.Walk(tree, tree.Root, func(node *layout.LayoutNode, depth int) layout.WalkAction {
+		if node.DomNode != element {
+			return layout.WalkContinue
+		}
+		box = node
+		layout.Walk(tree, node.ID, func(node *layout.LayoutNode, depth int) layout.WalkAction {
+			if node.Text != "" {
+				textNode = node
+			}
+			return layout.WalkContinue
+		})
+		return layout.WalkStop
+	})
+	if box == nil {
+		return
+	}
+
+	caret := contentRect(box)
+	caret.H = layout.LineHeight(box.Style)
+	if textNode != nil {
+		r := contentRect(textNode)
+		caret.X, caret.Y = r.X, r.Y
+		if spans := textSpans(nil, textNode); len(spans) > 0 {
+			last := spans[len(spans)-1]
+			caret.X, caret.Y = last.Rect.X+min(last.Rect.W, text.Width(last.Text, text.FontOf(&last.Style))), last.Rect.Y
+		}
+	}
+	caret.W = 1
+	list.PushFillRect(caret, box.Style.Caret())
+}
@@ -0,0 +1,90 @@
+package paint
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
+)
+
+func selectionTestPage(t *testing.T, html, stylesheet string) (*dom.DOM, *layout.LayoutTree) {
+	t.Helper()
+	d, err := dom.ParseString(html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheet, err := css.Parse(stylesheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := layout.BuildLayoutTree(d, sheet)
+	layout.ComputeLayout(tree, 200, 200)
+	return d, tree
+}
+
+func TestPaintSelection(t *testing.T) {
+	d, tree := selectionTestPage(t,
+		`<html><body><p id="a">one</p><p id="b">two</p><p id="c">three</p></body></html>`,
+		`::selection { background-color: blue; color: white; }`)
+	textOf := func(id string) dom.NodeID {
+		for _, node := range d.Nodes {
+			if node.Attr["id"] == id {
+				return node.Children[0]
+			}
+		}
+		t.Fatalf("no element #%s", id)
+		return dom.InvalidNodeID
+	}
+
+	// The selection runs in document order whichever end it starts from
+	list := NewPaintList()
+	PaintSelection(list, tree, textOf("c"), textOf("b"))
+	var texts []string
+	for _, op := range list.Ops {
+		switch op.Kind {
+		case OpFillRect:
+			if op.Color != (css.Color{B: 255, A: 255}) || op.Rect.W != text.Width("two", text.Font{Size: 16}) && op.Rect.W != text.Width("three", text.Font{Size: 16}) {
+				t.Errorf("unexpected highlight %v %v", op.Rect, op.Color)
+			}
+		case OpDrawText:
+			if op.Color != css.ColorWhite {
+				t.Errorf("expected selected text in white, got %v", op.Color)
+			}
+			texts = append(texts, list.Text(op))
+		}
+	}
+	if len(texts) != 2 || texts[0] != "two" || texts[1] != "three" {
+		t.Errorf("expected two and three selected, got %v", texts)
+	}
+}
+
+func TestPaintCaret(t *testing.T) {
+	d, tree := selectionTestPage(t,
+		`<html><body><textarea id="a">abc</textarea><textarea id="b"></textarea></body></html>`,
+		`#a { caret-color: red; padding: 4px; } #b { color: blue; }`)
+	for _, tt := range []struct {
+		id    string
+		x     float32
+		color css.Color
+	}{
+		{"a", 4 + text.Width("abc", text.Font{Size: 16}), css.Color{R: 255, A: 255}},
+		{"b", 0, css.Color{B: 255, A: 255}},
+	} {
+		var element dom.NodeID
+		for _, node := range d.Nodes {
+			if node.Attr["id"] == tt.id {
+				element = node.ID
+			}
+		}
+		list := NewPaintList()
+		PaintCaret(list, tree, element)
+		if len(list.Ops) != 1 {
+			t.Fatalf("#%s: expected a caret, got %d ops", tt.id, len(list.Ops))
+		}
+		if op := list.Ops[0]; op.Rect.X != tt.x || op.Rect.W != 1 || op.Color != tt.color {
+			t.Errorf("#%s: expected a caret at x=%v in %v, got %v in %v", tt.id, tt.x, tt.color, op.Rect, op.Color)
+		}
+	}
+}
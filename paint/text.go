@@ -0,0 +1,240 @@
+package paint
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
+)
+
+// textSpan is a run of a text node drawn in one style: a line, or a part of
+// the first line set apart by ::first-letter or ::first-line. Tabs are
+// expanded, and it is placed within the node's content box. In a vertical
+// writing mode, it is a run of a column, turned sideways or a single
+// upright character.
+type textSpan struct {
+	Text     string
+	Rect     layout.Rect
+	Style    css.Style
+	Sideways bool
+}
+
+// ink returns the part of a span's rect its text covers
+func (s textSpan) ink() layout.Rect {
+	r := s.Rect
+	if s.Sideways {
+		r.H = min(r.H, text.Width(s.Text, text.FontOf(&s.Style)))
+	} else {
+		r.W = min(r.W, text.Width(s.Text, text.FontOf(&s.Style)))
+	}
+	return r
+}
+
+// pushText draws the text of a span in a color
+func pushText(list *PaintList, s textSpan, color css.Color) {
+	if s.Sideways {
+		list.PushDrawSidewaysText(s.Rect, s.Text, color, text.FontOf(&s.Style))
+	} else {
+		list.PushDrawText(s.Rect, s.Text, color, text.FontOf(&s.Style))
+	}
+}
+
+// textSpans splits the text of a node at its line breaks, one line per
+// line height, and expands tabs to the tab stops of its tab-size. The
+// first line is split further where its ::first-letter and ::first-line
+// styles apply. Each line is aligned in the box as its text-align says, or
+// split into words spread across it where it is justified. Empty lines are
+// left out. The spans are appended to dst.
+func textSpans(dst []textSpan, node *layout.LayoutNode) []textSpan {
+	if node.Style.WritingMode.Vertical() {
+		return append(dst, verticalSpans(node)...)
+	}
+	r := contentRect(node)
+	style := node.Style
+	pseudo := style.FirstLetter.IsSet() || style.FirstLine.IsSet()
+	if !pseudo && node.Clamp.Line == 0 && node.WordSpacing == 0 && !strings.ContainsAny(node.Text, "\n\t") {
+		spans := append(dst, textSpan{Text: node.Text, Rect: r, Style: style})
+		alignLine(spans[len(dst):], r, style.TextAlign, style.Direction)
+		return spans
+	}
+
+	firstHeight, lineHeight := layout.FirstLineHeight(style), layout.LineHeight(style)
+	spans := dst
+	for i, line := range strings.Split(node.Text, "\n") {
+		line = text.ExpandTabs(line, style.TabSize, text.FontOf(&style))
+		if i+1 == node.Clamp.Line {
+			line = ellipsize(line, r.W, text.FontOf(&style), node.Clamp.More)
+		}
+		if line == "" {
+			continue
+		}
+		n := len(spans)
+		switch {
+		case i > 0:
+			y := r.Y + firstHeight + float32(i-1)*lineHeight
+			spans = append(spans, textSpan{Text: line, Rect: layout.Rect{X: r.X, Y: y, W: r.W, H: lineHeight}, Style: style})
+		case !pseudo:
+			spans = append(spans, textSpan{Text: line, Rect: layout.Rect{X: r.X, Y: r.Y, W: r.W, H: firstHeight}, Style: style})
+		default:
+			spans = appendFirstLine(spans, line, layout.Rect{X: r.X, Y: r.Y, W: r.W, H: firstHeight}, style)
+		}
+		alignLine(spans[n:], r, style.TextAlign, style.Direction)
+		if node.WordSpacing > 0 {
+			spans = append(spans[:n], spreadWords(spans[n:], node.WordSpacing)...)
+		}
+	}
+	return spans
+}
+
+// spreadWords splits the spans of a line into its words, moving each word
+// right by spacing for every space before it on the line, as justify lays
+// the line out
+func spreadWords(spans []textSpan, spacing float32) []textSpan {
+	var words []textSpan
+	var shift float32
+	for _, s := range spans {
+		font := text.FontOf(&s.Style)
+		space := text.Width(" ", font)
+		x := s.Rect.X + shift
+		for i, word := range strings.Split(s.Text, " ") {
+			if i > 0 {
+				x += space + spacing
+				shift += spacing
+			}
+			if word == "" {
+				continue
+			}
+			w := text.Width(word, font)
+			words = append(words, textSpan{Text: word, Rect: layout.Rect{X: x, Y: s.Rect.Y, W: w, H: s.Rect.H}, Style: s.Style})
+			x += w
+		}
+	}
+	return words
+}
+
+// verticalSpans splits the text of a node in a vertical writing mode into
+// columns, one per line, a line height wide each and laid from the right or
+// from the left as the writing mode says. Down each column, wide characters
+// are upright, each centered in a square as high as the font size, and the
+// rest turned sideways.
+func verticalSpans(node *layout.LayoutNode) []textSpan {
+	r := contentRect(node)
+	style := node.Style
+	font := text.FontOf(&style)
+	lineHeight := layout.LineHeight(style)
+	var spans []textSpan
+	for i, line := range strings.Split(node.Text, "\n") {
+		x := r.X + r.W - float32(i+1)*lineHeight
+		if style.WritingMode == css.VerticalLR {
+			x = r.X + float32(i)*lineHeight
+		}
+		y := r.Y
+		for _, run := range text.VerticalRuns(text.ExpandTabs(line, style.TabSize, font)) {
+			if !run.Upright {
+				h := run.Advance(font)
+				spans = append(spans, textSpan{Text: run.Text, Rect: layout.Rect{X: x, Y: y, W: lineHeight, H: h}, Style: style, Sideways: true})
+				y += h
+				continue
+			}
+			for _, ch := range run.Text {
+				w := text.Width(string(ch), font)
+				cell := layout.Rect{X: x + (lineHeight-w)/2, Y: y + (font.Size-lineHeight)/2, W: w, H: lineHeight}
+				spans = append(spans, textSpan{Text: string(ch), Rect: cell, Style: style})
+				y += font.Size
+			}
+		}
+	}
+	return spans
+}
+
+// ellipsis ends a line cut off by a line-clamp
+const ellipsis = "\u2026"
+
+// ellipsize ends the line a line-clamp cuts text off at with an ellipsis,
+// when more follows it or it is wider than w. The line is cut short so
+// that the ellipsis fits.
+func ellipsize(line string, w float32, font text.Font, more bool) string {
+	if !more && text.Width(line, font) <= w {
+		return line
+	}
+	// Search the rune boundaries for the longest start of the line that
+	// fits with the ellipsis
+	var ends []int
+	for i := range line {
+		ends = append(ends, i)
+	}
+	ends = append(ends, len(line))
+	n := sort.Search(len(ends), func(i int) bool {
+		return text.Width(line[:ends[i]]+ellipsis, font) > w
+	})
+	line = line[:ends[max(n-1, 0)]]
+	return strings.TrimRightFunc(line, unicode.IsSpace) + ellipsis
+}
+
+// alignLine moves the spans of a line, which start at the left of box, as
+// align places the line in it in a direction
+func alignLine(spans []textSpan, box layout.Rect, align css.TextAlign, dir css.Direction) {
+	// Lines that would stay put however short aren't measured
+	if len(spans) == 0 || align.Offset(box.W, dir) == 0 {
+		return
+	}
+	last := spans[len(spans)-1]
+	w := last.Rect.X + text.Width(last.Text, text.FontOf(&last.Style)) - box.X
+	dx := align.Offset(box.W-w, dir)
+	for i := range spans {
+		spans[i].Rect.X += dx
+		spans[i].Rect.W -= dx
+	}
+}
+
+// appendFirstLine appends the spans of the first line of a text: its first
+// letter and the rest, in the styles of ::first-letter and ::first-line.
+// They share a baseline, set by the largest font on the line.
+func appendFirstLine(spans []textSpan, s string, line layout.Rect, style css.Style) []textSpan {
+	lineStyle := style.FirstLine.Apply(style)
+	letterStyle := style.FirstLetter.Over(style.FirstLine).Apply(style)
+	n := firstLetter(s)
+	if !style.FirstLetter.IsSet() {
+		n = 0
+	}
+	baseline := text.MetricsOf(text.FontOf(&lineStyle)).Baseline()
+	if n > 0 {
+		baseline = max(baseline, text.MetricsOf(text.FontOf(&letterStyle)).Baseline())
+	}
+
+	x := line.X
+	for _, part := range [2]struct {
+		text  string
+		style css.Style
+	}{{s[:n], letterStyle}, {s[n:], lineStyle}} {
+		if part.text == "" {
+			continue
+		}
+		font := text.FontOf(&part.style)
+		spans = append(spans, textSpan{
+			Text:  part.text,
+			Rect:  layout.Rect{X: x, Y: line.Y + baseline - text.MetricsOf(font).Baseline(), W: line.X + line.W - x, H: line.H},
+			Style: part.style,
+		})
+		x += text.Width(part.text, font)
+	}
+	return spans
+}
+
+// firstLetter returns the length of the first letter of a line, along with
+// the punctuation before it, or 0 if it has none
+func firstLetter(line string) int {
+	for i, ch := range line {
+		if unicode.IsSpace(ch) {
+			return 0
+		}
+		if !unicode.IsPunct(ch) {
+			return i + utf8.RuneLen(ch)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,233 @@
+package paint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
+)
+
+func TestPaintPreformattedText(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		"<html><body><pre>a\tb\n\n\tc</pre></body></html>",
+		`pre { white-space: pre; tab-size: 4; }`)
+
+	var lines []PaintOp
+	list := Paint(tree)
+	for _, op := range list.Ops {
+		if op.Kind == OpDrawText {
+			lines = append(lines, op)
+		}
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected the empty line to be skipped, got %d lines", len(lines))
+	}
+	// "a" is two spaces wide, half of a stop
+	if got := list.Text(lines[0]); got != "a  b" {
+		t.Errorf("expected the tab to reach the next stop, got %q", got)
+	}
+	if got := list.Text(lines[1]); got != "    c" {
+		t.Errorf("expected a leading tab to be a full stop, got %q", got)
+	}
+	lineHeight := layout.LineHeight(css.DefaultStyle())
+	if dy := lines[1].Rect.Y - lines[0].Rect.Y; dy != 2*lineHeight {
+		t.Errorf("expected the third line two line heights down, got %v", dy)
+	}
+
+	var pre *layout.LayoutNode
+	layout.Walk(tree, tree.Root, func(node *layout.LayoutNode, depth int) layout.WalkAction {
+		if node.Text != "" {
+			pre = node
+			return layout.WalkStop
+		}
+		return layout.WalkContinue
+	})
+	if pre.Rect.H != 3*lineHeight {
+		t.Errorf("expected the text to be three lines high, got %v", pre.Rect.H)
+	}
+}
+
+func TestPaintFirstLetterAndLine(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		"<html><body><pre>\"Once\nupon</pre></body></html>",
+		`pre { white-space: pre; } pre::first-letter { font-size: 32px; background-color: yellow; } pre::first-line { color: red; }`)
+
+	list := Paint(tree)
+	var texts []PaintOp
+	var fills int
+	for _, op := range list.Ops {
+		switch op.Kind {
+		case OpDrawText:
+			texts = append(texts, op)
+		case OpFillRect:
+			fills++
+		}
+	}
+	if len(texts) != 3 {
+		t.Fatalf("expected the letter, the rest of the line and the next line, got %d ops", len(texts))
+	}
+	red := css.Color{R: 255, A: 255}
+	letter, rest, next := texts[0], texts[1], texts[2]
+	if list.Text(letter) != "\"O" || letter.Font.Size != 32 || letter.Color != red {
+		t.Errorf("expected a red 32px \"O, got %q %v %v", list.Text(letter), letter.Font.Size, letter.Color)
+	}
+	if list.Text(rest) != "nce" || rest.Color != red || rest.Rect.X != letter.Rect.X+text.Width("\"O", text.Font{Size: 32}) {
+		t.Errorf("expected a red nce after the letter, got %q %v at %v", list.Text(rest), rest.Color, rest.Rect)
+	}
+	// Both share a baseline
+	restBaseline := rest.Rect.Y + text.MetricsOf(rest.Font).Baseline()
+	letterBaseline := letter.Rect.Y + text.MetricsOf(letter.Font).Baseline()
+	if restBaseline != letterBaseline {
+		t.Errorf("expected a shared baseline, got %v and %v", restBaseline, letterBaseline)
+	}
+	if first := text.MetricsOf(text.Font{Size: 32}).LineHeight; next.Color != css.ColorBlack || next.Rect.Y != letter.Rect.Y+first {
+		t.Errorf("expected the next line in black below the %vpx first line, got %v at %v", first, next.Color, next.Rect)
+	}
+	if fills != 1 {
+		t.Errorf("expected the letter's background, got %d fills", fills)
+	}
+}
+
+func TestFirstLetter(t *testing.T) {
+	for line, want := range map[string]string{"abc": "a", "«Yes»": "«Y", "...": "", " x": "", "éa": "é"} {
+		if got := line[:firstLetter(line)]; got != want {
+			t.Errorf("%q: expected %q, got %q", line, want, got)
+		}
+	}
+}
+
+func TestPaintStrongAndEm(t *testing.T) {
+	d, err := dom.ParseString(`<html><body><p>plain<strong>bold</strong><em>slanted</em></p><h1>heading</h1></body></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := layout.BuildLayoutTree(d, css.UserAgentStylesheet())
+	layout.ComputeLayout(tree, 400, 200)
+
+	fonts := map[string]text.Font{}
+	list := Paint(tree)
+	for _, op := range list.Ops {
+		if op.Kind == OpDrawText {
+			fonts[list.Text(op)] = op.Font
+		}
+	}
+	plain := fonts["plain"]
+	for _, s := range []string{"bold", "slanted", "heading"} {
+		if f, ok := fonts[s]; !ok || f.ID == plain.ID {
+			t.Errorf("expected %s in a different font than plain text, got %+v", s, f)
+		}
+	}
+}
+
+func TestPaintTextAlign(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		"<html><body><h1>title</h1><pre>a\nbcd</pre></body></html>",
+		`body { margin: 0; } h1 { text-align: center; font-size: 16px; } pre { white-space: pre; text-align: right; }`)
+	font := text.Font{Size: 16}
+	var drawn []PaintOp
+	list := Paint(tree)
+	for _, op := range list.Ops {
+		if op.Kind == OpDrawText {
+			drawn = append(drawn, op)
+		}
+	}
+	if len(drawn) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(drawn))
+	}
+	if got, want := drawn[0].Rect.X, (200-text.Width("title", font))/2; got != want {
+		t.Errorf("expected the heading centered at x=%v, got %v", want, got)
+	}
+	// Each line of preformatted text is aligned on its own
+	for _, op := range drawn[1:] {
+		if got, want := op.Rect.X+text.Width(list.Text(op), op.Font), float32(200); got != want {
+			t.Errorf("expected %q to end at the right, at %v, got %v", list.Text(op), want, got)
+		}
+	}
+}
+
+func TestPaintJustifiedText(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		"<html><body><div>one two three<span></span></div></body></html>",
+		`body { margin: 0; } div { width: 150px; font-size: 16px; text-align: justify; }
+		span { display: inline-block; width: 120px; height: 10px; }`)
+	font := text.Font{Size: 16}
+	list := Paint(tree)
+	var words []string
+	var ends []float32
+	for _, op := range list.Ops {
+		if op.Kind == OpDrawText {
+			words = append(words, list.Text(op))
+			ends = append(ends, op.Rect.X+text.Width(list.Text(op), font))
+		}
+	}
+	// The words are drawn apart, the last ending at the right of the line
+	if strings.Join(words, "|") != "one|two|three" {
+		t.Fatalf("expected the words drawn one by one, got %q", words)
+	}
+	if got := ends[2]; got < 149.99 || got > 150.01 {
+		t.Errorf("expected the last word to end at the right, at 150, got %v", got)
+	}
+}
+
+func TestPaintVerticalText(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		"<html><body><p>ab漢字</p></body></html>",
+		`html { writing-mode: vertical-rl; } body, p { margin: 0; } p { font-size: 16px; }`)
+	list := Paint(tree)
+	var drawn []PaintOp
+	for _, op := range list.Ops {
+		if op.Kind == OpDrawText || op.Kind == OpDrawSidewaysText {
+			drawn = append(drawn, op)
+		}
+	}
+	if len(drawn) != 3 {
+		t.Fatalf("expected a sideways run and two upright characters, got:\n%s", list.Dump())
+	}
+
+	// The Latin run is turned sideways at the top of the column, at the
+	// right of the page, and each wide character is upright below it
+	font := text.Font{Size: 16}
+	side, kan, ji := drawn[0], drawn[1], drawn[2]
+	if side.Kind != OpDrawSidewaysText || list.Text(side) != "ab" || side.Rect.X+side.Rect.W != 200 || side.Rect.Y != 0 {
+		t.Errorf("expected \"ab\" sideways at the top right, got %s %q at %v", side.Kind, list.Text(side), side.Rect)
+	}
+	if kan.Kind != OpDrawText || list.Text(kan) != "漢" || ji.Kind != OpDrawText || list.Text(ji) != "字" {
+		t.Fatalf("expected the wide characters upright, got %s %q and %s %q", kan.Kind, list.Text(kan), ji.Kind, list.Text(ji))
+	}
+	if top := side.Rect.Y + text.Width("ab", font); kan.Rect.Y+(kan.Rect.H-font.Size)/2 != top || ji.Rect.Y-kan.Rect.Y != font.Size {
+		t.Errorf("expected the characters a square each below the run, got %v and %v", kan.Rect, ji.Rect)
+	}
+	if center := side.Rect.X + side.Rect.W/2; kan.Rect.X+kan.Rect.W/2 != center {
+		t.Errorf("expected the characters centered in the column, got %v in %v", kan.Rect, side.Rect)
+	}
+}
+
+func TestPaintLineClamp(t *testing.T) {
+	_, tree := selectionTestPage(t,
+		"<html><body><pre>one\ntwo\nthree</pre><pre class=\"long\">a line much too long to fit in the box</pre></body></html>",
+		`body, pre { margin: 0; } pre { white-space: pre; line-clamp: 2; } .long { line-clamp: 1; }`)
+	list := Paint(tree)
+	var drawn []PaintOp
+	for _, op := range list.Ops {
+		if op.Kind == OpDrawText {
+			drawn = append(drawn, op)
+		}
+	}
+	if len(drawn) != 4 {
+		t.Fatalf("expected 4 lines, got:\n%s", list.Dump())
+	}
+
+	// The last line before the clamp ends in an ellipsis, and the lines
+	// after it are still drawn, for overflow to hide
+	if two, three := list.Text(drawn[1]), list.Text(drawn[2]); two != "two\u2026" || three != "three" {
+		t.Errorf("expected an ellipsis after the second line, got %q and %q", two, three)
+	}
+	// A line too wide for its box is cut short to fit the ellipsis
+	long := drawn[3]
+	if s := list.Text(long); !strings.HasSuffix(s, "\u2026") || len(s) < 10 || text.Width(s, long.Font) > 200 {
+		t.Errorf("expected the long line cut short to fit with an ellipsis, got %q", s)
+	}
+}
@@ -0,0 +1,118 @@
+package paint
+
+import (
+	"image"
+	"image/draw"
+	"sync"
+
+	"github.com/myuon/penny/text"
+)
+
+// maxTextRunBytes bounds the bytes of the masks kept by a textRunCache. When
+// adding a run would pass it the cache is emptied rather than evicting
+// entries one by one; pages rarely repeat more text than this.
+const maxTextRunBytes = 32 << 20
+
+// maxCachedRunBytes is the largest mask a textRunCache keeps, as charged by
+// textRun.bytes. A run in a font large enough to need more is rasterized
+// each time it is drawn, for only the part of it that shows, since it
+// rarely repeats and would crowd out everything else.
+const maxCachedRunBytes = 1 << 20
+
+type textRunKey struct {
+	text string
+	font text.Font
+}
+
+// textRun is a run of text laid out and rasterized once. The mask holds the
+// glyph coverage with its bounds relative to the dot the run is drawn at, so
+// it can be composited in any color at any position.
+type textRun struct {
+	mask *image.Alpha
+
+	// sideways is the mask turned a quarter turn clockwise, made the first
+	// time the run is drawn sideways
+	sidewaysOnce sync.Once
+	sideways     *image.Alpha
+}
+
+// textRunCache maps (text, font) to rasterized runs so that strings
+// repeated across a page, such as menu items and table cells, are laid out
+// and rendered only once. It is safe for concurrent use.
+type textRunCache struct {
+	mu    sync.Mutex
+	runs  map[textRunKey]*textRun
+	bytes int // of the masks of runs, as charged by textRun.bytes
+}
+
+var textRuns = &textRunCache{runs: map[textRunKey]*textRun{}}
+
+// get returns the run of s in font. A run too large to cache is rasterized
+// for only the part of it within clip, relative to its dot, where it will
+// be drawn.
+func (c *textRunCache) get(s string, font text.Font, clip image.Rectangle) *textRun {
+	key := textRunKey{text: s, font: font}
+
+	c.mu.Lock()
+	run, ok := c.runs[key]
+	c.mu.Unlock()
+	if ok {
+		return run
+	}
+
+	b := text.MaskBounds(s, font)
+	if size := 2 * b.Dx() * b.Dy(); size > maxCachedRunBytes {
+		return &textRun{mask: text.MaskIn(s, font, clip)}
+	}
+	run = &textRun{mask: text.Mask(s, font)}
+	size := run.bytes()
+
+	c.mu.Lock()
+	if c.bytes+size > maxTextRunBytes {
+		c.runs = map[textRunKey]*textRun{}
+		c.bytes = 0
+	}
+	if _, ok := c.runs[key]; !ok {
+		c.runs[key] = run
+		c.bytes += size
+	}
+	c.mu.Unlock()
+	return run
+}
+
+// bytes returns what a run is charged in a textRunCache: its mask, twice
+// over for the sideways copy it may make
+func (r *textRun) bytes() int {
+	return 2 * len(r.mask.Pix)
+}
+
+func (c *textRunCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.runs)
+}
+
+// draw composites the run onto dst in src's color with its dot at the given
+// point
+func (r *textRun) draw(dst draw.Image, dot image.Point, src image.Image) {
+	bounds := r.mask.Bounds()
+	draw.DrawMask(dst, bounds.Add(dot), src, image.Point{}, r.mask, bounds.Min, draw.Over)
+}
+
+// drawSideways is draw for the run turned a quarter turn clockwise, about
+// its dot
+func (r *textRun) drawSideways(dst draw.Image, dot image.Point, src image.Image) {
+	r.sidewaysOnce.Do(func() {
+		// The pixel at (x, y) goes to (-y-1, x), so that what is above the
+		// baseline ends up right of the dot
+		b := r.mask.Bounds()
+		r.sideways = image.NewAlpha(image.Rect(-b.Max.Y, b.Min.X, -b.Min.Y, b.Max.X))
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r.sideways.SetAlpha(-y-1, x, r.mask.AlphaAt(x, y))
+			}
+		}
+	})
+	bounds := r.sideways.Bounds()
+	draw.DrawMask(dst, bounds.Add(dot), src, image.Point{}, r.sideways, bounds.Min, draw.Over)
+}
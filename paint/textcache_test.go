@@ -0,0 +1,101 @@
+package paint
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/text"
+)
+
+func TestDrawTextOnBaseline(t *testing.T) {
+	const s = "Hello, penny!"
+	op := PaintOp{
+		Kind:  OpDrawText,
+		Rect:  layout.Rect{X: 5, Y: 3},
+		Color: css.Color{R: 20, G: 40, B: 200, A: 255},
+		Font:  text.Font{Size: 16},
+	}
+
+	want := image.NewRGBA(image.Rect(0, 0, 120, 30))
+	mask := text.Mask(s, text.Font{Size: 16})
+	dot := image.Pt(5, int(3+text.MetricsOf(text.Font{Size: 16}).Baseline()))
+	draw.DrawMask(want, mask.Bounds().Add(dot), image.NewUniform(color.RGBA{20, 40, 200, 255}), image.Point{}, mask, mask.Bounds().Min, draw.Over)
+
+	// Draw twice so the second draw comes from the cache
+	for i := 0; i < 2; i++ {
+		got := image.NewRGBA(want.Bounds())
+		drawText(got, op, s)
+		for j := range want.Pix {
+			if got.Pix[j] != want.Pix[j] {
+				t.Fatalf("draw %d: pixel data differs from the mask drawn on the baseline at byte %d", i, j)
+			}
+		}
+	}
+}
+
+func TestTextRunCacheReusesRuns(t *testing.T) {
+	cache := &textRunCache{runs: map[textRunKey]*textRun{}}
+	a := cache.get("menu", text.Font{Size: 16}, image.Rectangle{})
+	if b := cache.get("menu", text.Font{Size: 16}, image.Rectangle{}); a != b {
+		t.Error("expected the same run for a repeated string")
+	}
+	if c := cache.get("menu", text.Font{Size: 24}, image.Rectangle{}); a == c {
+		t.Error("expected a different run for a different size")
+	}
+	if n := cache.len(); n != 2 {
+		t.Errorf("expected 2 cached runs, got %d", n)
+	}
+}
+
+func TestTextRunCacheBudget(t *testing.T) {
+	cache := &textRunCache{runs: map[textRunKey]*textRun{}}
+	// A run too large to cache is rasterized where it is drawn
+	clip := image.Rect(0, -50, 100, 50)
+	big := cache.get("MMMMMMMM", text.Font{Size: 400}, clip)
+	if !big.mask.Bounds().In(clip) || cache.len() != 0 {
+		t.Errorf("expected a run within %v not to be cached, got %v and %d runs", clip, big.mask.Bounds(), cache.len())
+	}
+
+	// Passing the budget empties the cache before the run is added
+	cache.get("a", text.Font{Size: 16}, image.Rectangle{})
+	cache.bytes = maxTextRunBytes - 1
+	run := cache.get("b", text.Font{Size: 16}, image.Rectangle{})
+	if cache.len() != 1 || cache.bytes != run.bytes() {
+		t.Errorf("expected only the new run cached, got %d runs of %d bytes", cache.len(), cache.bytes)
+	}
+}
+
+func TestDrawHugeText(t *testing.T) {
+	// Runs too large to cache are clipped to where they are drawn, whether
+	// upright or sideways
+	op := PaintOp{Rect: layout.Rect{X: -20, Y: -300}, Color: css.Color{A: 255}, Font: text.Font{Size: 400}}
+	const s = "MMMMMMMM"
+	mask := text.Mask(s, op.Font)
+	dot := image.Pt(-20, int(-300+text.MetricsOf(op.Font).Baseline()))
+	want := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	draw.DrawMask(want, mask.Bounds().Add(dot), image.Black, image.Point{}, mask, mask.Bounds().Min, draw.Over)
+	got := image.NewRGBA(want.Bounds())
+	drawText(got, op, s)
+	if string(got.Pix) != string(want.Pix) {
+		t.Error("expected the clipped run to match the whole one drawn")
+	}
+
+	sideways := op
+	sideways.Rect = layout.Rect{X: -300, Y: -20, W: 60}
+	run := &textRun{mask: mask}
+	dot = image.Pt(int(-300+60-text.MetricsOf(op.Font).Baseline()), -20)
+	want = image.NewRGBA(want.Bounds())
+	run.drawSideways(want, dot, image.Black)
+	got = image.NewRGBA(want.Bounds())
+	drawSidewaysText(got, sideways, s)
+	if string(got.Pix) != string(want.Pix) {
+		t.Error("expected the clipped sideways run to match the whole one drawn")
+	}
+
+	got = image.NewRGBA(image.Rect(0, 0, 100, 100))
+	drawText(got, PaintOp{Color: css.Color{A: 255}, Font: text.Font{Size: 100000}}, "H")
+}
@@ -0,0 +1,70 @@
+// Package profile lets users profile penny on their own pages. Each phase of
+// the rendering pipeline runs under a pprof label, so CPU profiles can be
+// broken down with e.g. `go tool pprof -tagfocus phase=layout`.
+package profile
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	rpprof "runtime/pprof"
+)
+
+// Pipeline phases
+const (
+	PhaseParseHTML = "parse-html"
+	PhaseLoadCSS   = "load-css"
+	PhaseImages    = "decode-images"
+	PhaseStyle     = "style" // style resolution and layout tree construction
+	PhaseLayout    = "layout"
+	PhasePaint     = "paint"
+	PhaseRasterize = "rasterize"
+)
+
+// Phase runs fn with the pprof label phase=name
+func Phase(name string, fn func()) {
+	rpprof.Do(context.Background(), rpprof.Labels("phase", name), func(context.Context) {
+		fn()
+	})
+}
+
+// StartCPUProfile writes a CPU profile to path until the returned function is
+// called
+func StartCPUProfile(path string) (stop func() error, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+	if err := rpprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+
+	return func() error {
+		rpprof.StopCPUProfile()
+		return f.Close()
+	}, nil
+}
+
+// Serve exposes the net/http/pprof endpoints under /debug/pprof/ on addr in
+// the background. It returns the address actually listened on, which differs
+// from addr if its port is 0.
+func Serve(addr string) (string, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen for pprof: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	go http.Serve(ln, mux)
+	return ln.Addr().String(), nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// maxRequestBytes is the largest JSON-RPC request the handler reads
+const maxRequestBytes = 8 << 20
+
+// JSON-RPC 2.0 error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	// codeRenderError is for a page that couldn't be loaded or rendered
+	codeRenderError = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// method calls a method of the service with the JSON of its request
+type method func(s *Service, params json.RawMessage) (any, error)
+
+// call returns the method of a request type and response
+func call[Req, Resp any](fn func(*Service, Req) (Resp, error)) method {
+	return func(s *Service, params json.RawMessage) (any, error) {
+		var req Req
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return fn(s, req)
+	}
+}
+
+// methods are the service's methods by their name in penny.proto
+var methods = map[string]method{
+	"Render":     call((*Service).Render),
+	"Screenshot": call((*Service).Screenshot),
+	"DumpLayout": call((*Service).DumpLayout),
+	"Query":      call((*Service).Query),
+}
+
+// Handler serves the service as JSON-RPC 2.0 over HTTP: a request is
+// POSTed as JSON, with the method named as in penny.proto, such as
+// "Render", and its request message as params. Batches aren't supported.
+//
+// Requests must have the application/json content type, which browsers
+// don't send across origins without asking first, and those from web pages
+// must come from an origin in AllowOrigins.
+func (s *Service) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && !AllowsOrigin(s.AllowOrigins, origin) {
+			http.Error(w, "origin "+origin+" is not allowed", http.StatusForbidden)
+			return
+		}
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			http.Error(w, "want an application/json request", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeResponse(w, rpcResponse{Error: &rpcError{Code: codeParseError, Message: err.Error()}, ID: json.RawMessage("null")})
+			return
+		}
+		resp := rpcResponse{ID: req.ID}
+		if resp.ID == nil {
+			resp.ID = json.RawMessage("null")
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			resp.Error = &rpcError{Code: codeInvalidRequest, Message: `want a "2.0" request with a method`}
+			writeResponse(w, resp)
+			return
+		}
+		m, ok := methods[req.Method]
+		if !ok {
+			resp.Error = &rpcError{Code: codeMethodNotFound, Message: "no method " + req.Method}
+			writeResponse(w, resp)
+			return
+		}
+		if req.Params == nil {
+			req.Params = json.RawMessage("{}")
+		}
+		result, err := m(s, req.Params)
+		var rpcErr *rpcError
+		switch {
+		case errors.As(err, &rpcErr):
+			resp.Error = rpcErr
+		case errors.Is(err, ErrInvalidRequest):
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		case err != nil:
+			resp.Error = &rpcError{Code: codeRenderError, Message: err.Error()}
+		default:
+			resp.Result = result
+		}
+		// A notification, without an id, is answered with nothing
+		if req.ID == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeResponse(w, resp)
+	})
+}
+
+// AllowsOrigin reports whether a web page of origin may make requests, given
+// the allowed origins, in which "*" allows any
+func AllowsOrigin(allowed []string, origin string) bool {
+	return slices.ContainsFunc(allowed, func(a string) bool {
+		return a == "*" || strings.EqualFold(a, origin)
+	})
+}
+
+func writeResponse(w http.ResponseWriter, resp rpcResponse) {
+	resp.JSONRPC = "2.0"
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
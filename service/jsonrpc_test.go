@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(testService().Handler())
+	defer server.Close()
+
+	post := func(body string) (int, map[string]any) {
+		t.Helper()
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]any
+		json.NewDecoder(resp.Body).Decode(&decoded)
+		return resp.StatusCode, decoded
+	}
+
+	_, resp := post(`{"jsonrpc": "2.0", "id": 1, "method": "Query", "params": {"page": {"url": "http://example.com/"}, "selector": "#box", "props": ["background-color"]}}`)
+	want := `{"id":1,"jsonrpc":"2.0","result":{"elements":[{"element":"div#box","props":{"background-color":"rgba(0,0,255,255)"}}]}}`
+	if got, _ := json.Marshal(resp); string(got) != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	_, resp = post(`{"jsonrpc": "2.0", "id": "a", "method": "Render", "params": {"page": {"url": "http://example.com/"}}}`)
+	if result, _ := resp["result"].(map[string]any); resp["id"] != "a" || result["title"] != "Example" {
+		t.Errorf("expected the page's metadata, got %v", resp)
+	}
+
+	errorCodes := []struct {
+		body string
+		code float64
+	}{
+		{`{"jsonrpc": "2.0", "id": 1`, codeParseError},
+		{`{"id": 1, "method": "Render"}`, codeInvalidRequest},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "Print"}`, codeMethodNotFound},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "Render", "params": {"page": 1}}`, codeInvalidParams},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "Render", "params": {"page": {"url": "file:///etc/passwd"}}}`, codeInvalidParams},
+		{`{"jsonrpc": "2.0", "id": 1, "method": "Render", "params": {"page": {"url": "http://example.com/missing"}}}`, codeRenderError},
+	}
+	for _, tt := range errorCodes {
+		_, resp := post(tt.body)
+		if rpcErr, _ := resp["error"].(map[string]any); rpcErr["code"] != tt.code {
+			t.Errorf("%s: expected error %v, got %v", tt.body, tt.code, resp)
+		}
+	}
+
+	// Notifications aren't answered
+	if status, _ := post(`{"jsonrpc": "2.0", "method": "Render", "params": {"page": {"html": "<p>"}}}`); status != http.StatusNoContent {
+		t.Errorf("expected no content for a notification, got %d", status)
+	}
+	resp2, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET not to be allowed, got %d", resp2.StatusCode)
+	}
+}
+
+func TestHandlerRefusesCrossSiteRequests(t *testing.T) {
+	s := testService()
+	s.AllowOrigins = []string{"http://localhost:3000"}
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		contentType string
+		origin      string
+		want        int
+	}{
+		{"application/json", "", http.StatusOK},
+		{"application/json; charset=utf-8", "", http.StatusOK},
+		{"application/json", "http://localhost:3000", http.StatusOK},
+		{"application/json", "https://evil.example", http.StatusForbidden},
+		{"text/plain", "", http.StatusUnsupportedMediaType},
+		{"", "", http.StatusUnsupportedMediaType},
+	}
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "Render", "params": {"page": {"html": "<p>"}}}`
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%q from %q: expected status %d, got %d", tt.contentType, tt.origin, tt.want, resp.StatusCode)
+		}
+	}
+}
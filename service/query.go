@@ -0,0 +1,104 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+)
+
+// Rect is the box of an element as laid out
+type Rect struct {
+	X      float32 `json:"x"`
+	Y      float32 `json:"y"`
+	Width  float32 `json:"width"`
+	Height float32 `json:"height"`
+}
+
+// Element is an element a query matched
+type Element struct {
+	// Element describes the element by its tag, ID and classes, such as
+	// "h1#title.big"
+	Element string `json:"element"`
+	// Rect is the element's box, if the query asked for rect
+	Rect *Rect `json:"rect,omitempty"`
+	// Props are the computed values, as CSS text, of the longhands the
+	// query asked for
+	Props map[string]string `json:"props,omitempty"`
+}
+
+// Query is a selector and what to return of the elements it matches
+type Query struct {
+	selectors []css.Selector
+	props     []string
+	ids       []css.PropertyID // of props, but for rect
+}
+
+// ParseQuery parses a selector and the properties to return, each a
+// longhand or rect
+func ParseQuery(selector string, props []string) (*Query, error) {
+	sheet, err := css.Parse(selector + " {}")
+	if err != nil || len(sheet.Rules) != 1 {
+		return nil, fmt.Errorf("invalid selector: %s", selector)
+	}
+	q := &Query{selectors: sheet.Rules[0].Selectors, props: props, ids: make([]css.PropertyID, len(props))}
+	for i, prop := range props {
+		if prop == "rect" {
+			continue
+		}
+		id, ok := css.LookupProperty(prop)
+		if !ok {
+			return nil, fmt.Errorf("unsupported property: %s", prop)
+		}
+		q.ids[i] = id
+	}
+	return q, nil
+}
+
+// Run returns the rendered elements the query matches, in document order.
+// An element that isn't rendered, such as one that is display:none, is left
+// out.
+func (q *Query) Run(d *dom.DOM, tree *layout.LayoutTree) []Element {
+	boxes := make(map[dom.NodeID]*layout.LayoutNode)
+	for i := range tree.Nodes {
+		node := &tree.Nodes[i]
+		if node.Text == "" {
+			boxes[node.DomNode] = node
+		}
+	}
+
+	elements := []Element{}
+	for _, id := range layout.QuerySelectorAll(d, q.selectors) {
+		box, ok := boxes[id]
+		if !ok {
+			continue
+		}
+		element := Element{Element: describeElement(d.GetNode(id))}
+		for i, prop := range q.props {
+			if prop == "rect" {
+				element.Rect = &Rect{box.Rect.X, box.Rect.Y, box.Rect.W, box.Rect.H}
+				continue
+			}
+			if element.Props == nil {
+				element.Props = make(map[string]string)
+			}
+			element.Props[prop] = box.Style.Serialize(q.ids[i])
+		}
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+// describeElement returns a selector of an element's tag, ID and classes
+func describeElement(node *dom.Node) string {
+	s := node.Tag
+	if id := node.Attr["id"]; id != "" {
+		s += "#" + id
+	}
+	for _, class := range strings.Fields(node.Attr["class"]) {
+		s += "." + class
+	}
+	return s
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/engine"
+)
+
+func TestQuery(t *testing.T) {
+	page, err := engine.Parse([]byte(`<body style="margin: 0">
+		<h1 id="title" class="big red" style="color: red; height: 40px">Title</h1>
+		<p style="display: none">hidden</p><p>shown</p></body>`), &url.URL{Scheme: "about", Opaque: "blank"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, _ := engine.WithDefaults(nil, nil)
+	result := engine.RenderPage(page, defaults, css.DefaultMediaContext(), engine.Options{Width: 200, Height: 100})
+
+	q, err := ParseQuery("h1, p", []string{"color", "rect"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	elements := q.Run(page.DOM, result.Layout)
+	if len(elements) != 2 {
+		t.Fatalf("expected the h1 and the shown p, got %+v", elements)
+	}
+	h1 := elements[0]
+	if h1.Element != "h1#title.big.red" || h1.Props["color"] != "rgba(255,0,0,255)" || h1.Rect == nil || h1.Rect.Height != 40 {
+		t.Errorf("unexpected h1 %+v, rect %+v", h1, h1.Rect)
+	}
+	if elements[1].Element != "p" || elements[1].Props["color"] != "rgba(0,0,0,255)" {
+		t.Errorf("unexpected p %+v", elements[1])
+	}
+
+	if q, _ := ParseQuery("h1", nil); q.Run(page.DOM, result.Layout)[0].Props != nil {
+		t.Error("expected no props unless asked for")
+	}
+	for _, bad := range [][2]string{{"}", "color"}, {"h1", "colour"}} {
+		if _, err := ParseQuery(bad[0], []string{bad[1]}); err == nil {
+			t.Errorf("%q %q: expected an error", bad[0], bad[1])
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package service is penny's rendering API for clients that aren't written
+// in Go. Its methods render a page given by URL or inline HTML and return
+// what the commands print: the page's metadata, a screenshot, its layout
+// tree or the computed styles of its elements. Handler serves them as
+// JSON-RPC 2.0 over HTTP; penny.proto defines their messages, whose JSON
+// field names are those of the proto.
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/encode"
+	"github.com/myuon/penny/engine"
+	"github.com/myuon/penny/loader"
+)
+
+// The viewport a page is rendered in when a request doesn't give one
+const (
+	defaultWidth  = 800
+	defaultHeight = 600
+)
+
+// maxViewport is the largest width or height a request may render at
+const maxViewport = 16384
+
+// ErrInvalidRequest is returned for a request that doesn't name a page, or
+// names one the service won't render
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Page is the page a request renders: a URL, or an HTML document given
+// inline, laid out in a viewport of Width x Height
+type Page struct {
+	URL  string `json:"url,omitempty"`
+	HTML string `json:"html,omitempty"`
+	// BaseURL is what the references of an inline document resolve
+	// against. Without it, they can't be loaded.
+	BaseURL string `json:"base_url,omitempty"`
+	Width   int    `json:"width,omitempty"`  // 800 if unset
+	Height  int    `json:"height,omitempty"` // 600 if unset
+}
+
+type RenderRequest struct {
+	Page Page `json:"page"`
+}
+
+// RenderResponse is the page's metadata, as the root command's --metadata
+// writes it
+type RenderResponse = engine.RenderResult
+
+type ScreenshotRequest struct {
+	Page Page `json:"page"`
+	// Format is the name of the image format, such as "png" or "webp", png
+	// if unset
+	Format string `json:"format,omitempty"`
+}
+
+type ScreenshotResponse struct {
+	MIMEType string `json:"mime_type"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Data     []byte `json:"data"` // base64 in JSON
+}
+
+type DumpLayoutRequest struct {
+	Page Page `json:"page"`
+}
+
+type DumpLayoutResponse struct {
+	Layout string `json:"layout"`
+}
+
+type QueryRequest struct {
+	Page     Page     `json:"page"`
+	Selector string   `json:"selector"`
+	Props    []string `json:"props,omitempty"`
+}
+
+type QueryResponse struct {
+	Elements []Element `json:"elements"`
+}
+
+// Service renders pages for its clients. It is safe for concurrent use.
+type Service struct {
+	// Get fetches pages on the web and what they reference
+	Get loader.Getter
+	// AllowFiles lets a request's URL be the path of a local file, which a
+	// service open to untrusted clients must not allow
+	AllowFiles bool
+	// AllowOrigins are the origins of the web pages that may call the
+	// JSON-RPC handler, or "*" for any. Browsers send the Origin of a page
+	// making a request, and requests from one that isn't listed are
+	// refused, so that a page the user visits can't drive penny. Clients
+	// outside a browser send none.
+	AllowOrigins []string
+}
+
+// New returns a service that fetches with get, and renders no local files
+func New(get loader.Getter) *Service {
+	return &Service{Get: get}
+}
+
+// Render renders a page and returns its metadata
+func (s *Service) Render(req RenderRequest) (*RenderResponse, error) {
+	_, result, err := s.Load(req.Page)
+	return result, err
+}
+
+// Screenshot renders a page and returns its image, encoded in a format
+func (s *Service) Screenshot(req ScreenshotRequest) (*ScreenshotResponse, error) {
+	name := req.Format
+	if name == "" {
+		name = "png"
+	}
+	format, ok := encode.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidRequest, req.Format)
+	}
+	_, result, err := s.Load(req.Page)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := format.Encode(result.Image, &buf); err != nil {
+		return nil, err
+	}
+	b := result.Image.Bounds()
+	return &ScreenshotResponse{MIMEType: format.MIMEType, Width: b.Dx(), Height: b.Dy(), Data: buf.Bytes()}, nil
+}
+
+// DumpLayout renders a page and returns its layout tree, as the root
+// command's --dump-layout-tree prints it
+func (s *Service) DumpLayout(req DumpLayoutRequest) (*DumpLayoutResponse, error) {
+	_, result, err := s.Load(req.Page)
+	if err != nil {
+		return nil, err
+	}
+	return &DumpLayoutResponse{Layout: result.Layout.Dump()}, nil
+}
+
+// Query renders a page and returns the elements a selector matches, with
+// the properties asked for
+func (s *Service) Query(req QueryRequest) (*QueryResponse, error) {
+	q, err := ParseQuery(req.Selector, req.Props)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	page, result, err := s.Load(req.Page)
+	if err != nil {
+		return nil, err
+	}
+	return &QueryResponse{Elements: q.Run(page.DOM, result.Layout)}, nil
+}
+
+// Load loads a page and renders it with the user agent stylesheet. A
+// stylesheet or image that can't be loaded is left out, and listed in the
+// result.
+func (s *Service) Load(p Page) (*engine.Page, *engine.RenderResult, error) {
+	w, h := p.Width, p.Height
+	if w == 0 {
+		w = defaultWidth
+	}
+	if h == 0 {
+		h = defaultHeight
+	}
+	if w < 0 || h < 0 || w > maxViewport || h > maxViewport {
+		return nil, nil, fmt.Errorf("%w: viewport %dx%d", ErrInvalidRequest, w, h)
+	}
+
+	page, err := s.open(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	defaults, err := engine.WithDefaults(nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	media := css.DefaultMediaContext()
+	media.Width, media.Height = float32(w), float32(h)
+	return page, engine.RenderPage(page, defaults, media, engine.Options{Width: w, Height: h}), nil
+}
+
+// open loads the page a request names
+func (s *Service) open(p Page) (*engine.Page, error) {
+	switch {
+	case p.URL != "" && p.HTML != "":
+		return nil, fmt.Errorf("%w: both url and html are set", ErrInvalidRequest)
+	case p.HTML != "":
+		base := &url.URL{Scheme: "about", Opaque: "blank"}
+		if p.BaseURL != "" {
+			u, err := url.Parse(p.BaseURL)
+			if err != nil || !u.IsAbs() {
+				return nil, fmt.Errorf("%w: invalid base_url %q", ErrInvalidRequest, p.BaseURL)
+			}
+			base = u
+		}
+		return engine.Parse([]byte(p.HTML), base, s.Get)
+	case p.URL == "":
+		return nil, fmt.Errorf("%w: neither url nor html is set", ErrInvalidRequest)
+	case !engine.IsURL(p.URL) && !s.AllowFiles:
+		return nil, fmt.Errorf("%w: %q is not an http or https URL", ErrInvalidRequest, p.URL)
+	}
+	return engine.Open(p.URL, s.Get)
+}
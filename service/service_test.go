@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testService() *Service {
+	files := map[string]string{
+		"http://example.com/":         `<link rel="stylesheet" href="site.css"><title>Example</title><div id="box"></div>`,
+		"http://example.com/site.css": `body { margin: 0 } #box { height: 30px; background-color: blue; }`,
+	}
+	return New(func(u string) ([]byte, error) {
+		if data, ok := files[u]; ok {
+			return []byte(data), nil
+		}
+		return nil, errors.New("not found")
+	})
+}
+
+func TestRender(t *testing.T) {
+	s := testService()
+	resp, err := s.Render(RenderRequest{Page: Page{URL: "http://example.com/", Width: 100, Height: 50}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Title != "Example" || resp.DocumentWidth != 100 || resp.DocumentHeight != 50 || len(resp.Failed) != 0 {
+		t.Errorf("unexpected metadata %+v", resp)
+	}
+
+	// Inline HTML loads what it references from its base URL
+	resp, err = s.Render(RenderRequest{Page: Page{HTML: `<link rel="stylesheet" href="site.css"><link rel="stylesheet" href="gone.css">`, BaseURL: "http://example.com/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Failed) != 1 || resp.Failed[0].Ref != "http://example.com/gone.css" {
+		t.Errorf("expected gone.css to fail, got %+v", resp.Failed)
+	}
+
+	for _, page := range []Page{
+		{},
+		{URL: "http://example.com/", HTML: "<p>"},
+		{URL: "/etc/passwd"},
+		{HTML: "<p>", BaseURL: "relative"},
+		{URL: "http://example.com/", Width: -1},
+	} {
+		if _, err := s.Render(RenderRequest{Page: page}); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%+v: expected an invalid request, got %v", page, err)
+		}
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	resp, err := testService().Screenshot(ScreenshotRequest{Page: Page{URL: "http://example.com/", Width: 40, Height: 40}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.MIMEType != "image/png" || resp.Width != 40 || resp.Height != 40 {
+		t.Errorf("unexpected screenshot %s %dx%d", resp.MIMEType, resp.Width, resp.Height)
+	}
+	img, err := png.Decode(bytes.NewReader(resp.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := img.At(5, 5).RGBA(); r != 0 || g != 0 || b != 0xffff {
+		t.Errorf("expected the box blue, got %v", img.At(5, 5))
+	}
+
+	if _, err := testService().Screenshot(ScreenshotRequest{Page: Page{URL: "http://example.com/"}, Format: "bmp"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("expected an unknown format to be invalid, got %v", err)
+	}
+}
+
+func TestDumpLayout(t *testing.T) {
+	resp, err := testService().DumpLayout(DumpLayoutRequest{Page: Page{URL: "http://example.com/", Width: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Layout, "(0.0, 0.0, 100.0, 30.0)") {
+		t.Errorf("expected the box in the layout, got\n%s", resp.Layout)
+	}
+}
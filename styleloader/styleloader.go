@@ -0,0 +1,201 @@
+// Package styleloader collects the stylesheets of a document: those linked
+// with <link rel=stylesheet>, those in <style> elements, and those they
+// @import, in the order they cascade in
+package styleloader
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/loader"
+)
+
+// maxImportDepth is how deep @imports are followed. A deeper chain is
+// almost certainly a loop through different URLs.
+const maxImportDepth = 16
+
+// Error is a stylesheet that couldn't be loaded
+type Error struct {
+	Source string // the URL or path of the stylesheet
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("failed to load stylesheet %s: %v", e.Source, e.Err)
+}
+func (e *Error) Unwrap() error { return e.Err }
+
+// ErrImportLoop is the error of an @import of a stylesheet that is already
+// being imported
+var ErrImportLoop = errors.New("@import loop")
+
+// Load collects the stylesheets of d, in document order with each
+// stylesheet's @imports before its own rules. References are resolved
+// against the document's <base href>, if it has one, and @imports against
+// the stylesheet they are in; fetch reads them, as references relative to
+// the document. name turns such a reference into the URL or path that the
+// positions of its rules refer to; it may be nil to leave references as
+// they are.
+//
+// A stylesheet that can't be loaded is left out, as a browser would, and the
+// rest are returned along with the errors of those left out, joined, as
+// *Errors.
+func Load(d *dom.DOM, fetch loader.Fetch, name func(ref string) string) (*css.Stylesheet, error) {
+	return LoadEach(d, fetch, name, nil)
+}
+
+// LoadEach is Load, calling loaded, if it is not nil, each time a linked
+// stylesheet or a <style> element has been added, along with what it
+// imports. loaded is given a copy of the stylesheets collected so far and
+// the source of the one added, so that a page can be shown as its
+// stylesheets arrive.
+func LoadEach(d *dom.DOM, fetch loader.Fetch, name func(ref string) string, loaded func(sheet *css.Stylesheet, source string)) (*css.Stylesheet, error) {
+	if name == nil {
+		name = func(ref string) string { return ref }
+	}
+	l := &styleLoader{fetch: fetch, name: name, all: &css.Stylesheet{}}
+	base := ""
+	dom.Walk(d, d.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Type != dom.NodeTypeElement {
+			return dom.WalkContinue
+		}
+		switch node.Tag {
+		case "base":
+			// The first <base href> applies to the whole document
+			if href, ok := node.Attr["href"]; ok && base == "" {
+				base = strings.TrimSpace(href)
+				if base == "" {
+					base = "."
+				}
+			}
+		case "link":
+			href, ok := node.Attr["href"]
+			if !ok || !isStylesheetLink(node.Attr["rel"]) {
+				break
+			}
+			ref := resolve(base, strings.TrimSpace(href))
+			if l.link(ref) && loaded != nil {
+				loaded(l.snapshot(), name(ref))
+			}
+		case "style":
+			text := TextContent(d, node.ID)
+			if text != "" && l.add(text, "<style>", base, nil) && loaded != nil {
+				loaded(l.snapshot(), "<style>")
+			}
+		}
+		return dom.WalkContinue
+	})
+	return l.all, errors.Join(l.errs...)
+}
+
+type styleLoader struct {
+	fetch loader.Fetch
+	name  func(ref string) string
+	all   *css.Stylesheet
+	errs  []error
+	// importing are the stylesheets whose @imports are being loaded
+	importing []string
+}
+
+// link loads the stylesheet ref points to, and reports whether it could
+func (l *styleLoader) link(ref string) bool {
+	source := l.name(ref)
+	data, err := l.fetch(ref)
+	if err != nil {
+		l.errs = append(l.errs, &Error{Source: source, Err: err})
+		return false
+	}
+	return l.add(string(data), source, ref, nil)
+}
+
+// add parses a stylesheet read from ref, which its @imports are relative
+// to, and adds it, after what it imports, where media match. It reports
+// whether the stylesheet could be parsed.
+func (l *styleLoader) add(text, source, ref string, media []css.MediaQuery) bool {
+	sheet, err := css.ParseSource(text, source)
+	if err != nil {
+		l.errs = append(l.errs, &Error{Source: source, Err: err})
+		return false
+	}
+	if len(sheet.Imports) > 0 {
+		l.importing = append(l.importing, ref)
+		for _, imp := range sheet.Imports {
+			l.importSheet(resolve(ref, imp.URL), css.AndMedia(media, imp.Media))
+		}
+		l.importing = l.importing[:len(l.importing)-1]
+	}
+	l.all.Append(sheet.InMedia(media))
+	return true
+}
+
+// snapshot returns a copy of the stylesheets collected so far, which
+// adding more won't change
+func (l *styleLoader) snapshot() *css.Stylesheet {
+	sheet := &css.Stylesheet{}
+	sheet.Append(l.all)
+	return sheet
+}
+
+// importSheet loads the stylesheet of an @import
+func (l *styleLoader) importSheet(ref string, media []css.MediaQuery) {
+	source := l.name(ref)
+	if slices.Contains(l.importing, ref) || len(l.importing) > maxImportDepth {
+		l.errs = append(l.errs, &Error{Source: source, Err: ErrImportLoop})
+		return
+	}
+	data, err := l.fetch(ref)
+	if err != nil {
+		l.errs = append(l.errs, &Error{Source: source, Err: err})
+		return
+	}
+	l.add(string(data), source, ref, media)
+}
+
+// resolve returns ref, as written in a stylesheet or document read from
+// base, relative to the document. base is "" for the document itself.
+func resolve(base, ref string) string {
+	r, err := url.Parse(ref)
+	if err != nil || r.IsAbs() || base == "" {
+		return ref
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	if b.IsAbs() || strings.HasPrefix(ref, "/") {
+		return b.ResolveReference(r).String()
+	}
+	// Relative to a reference that is itself relative to the document
+	dir := path.Dir(b.Path)
+	if strings.HasSuffix(b.Path, "/") {
+		dir = b.Path
+	}
+	r.Path = path.Join(dir, r.Path)
+	return r.String()
+}
+
+// isStylesheetLink reports whether a link's rel applies a stylesheet. An
+// alternate stylesheet is only applied when the user picks it, which penny
+// has no way to do.
+func isStylesheetLink(rel string) bool {
+	rels := strings.Fields(strings.ToLower(rel))
+	return slices.Contains(rels, "stylesheet") && !slices.Contains(rels, "alternate")
+}
+
+// TextContent returns the text of the descendants of a node, concatenated
+func TextContent(d *dom.DOM, id dom.NodeID) string {
+	var sb strings.Builder
+	dom.Walk(d, id, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Type == dom.NodeTypeText {
+			sb.WriteString(node.Text)
+		}
+		return dom.WalkContinue
+	})
+	return sb.String()
+}
@@ -0,0 +1,151 @@
+package styleloader
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+)
+
+func TestLoad(t *testing.T) {
+	d, err := dom.ParseString(`<html><head>
+		<base href="site/">
+		<link rel="stylesheet" href="css/a.css">
+		<style>@import "b.css" print; p { color: blue; }</style>
+		<link rel="alternate stylesheet" href="alt.css">
+		<link rel="Stylesheet" href="missing.css">
+		</head></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"site/css/a.css":      `@import url( "../common.css" ); @import url(loop.css) screen; p { color: red; }`,
+		"site/common.css":     `div { color: green; }`,
+		"site/css/loop.css":   `@media (min-width: 100px) { i { color: red; } } @import "a.css";`,
+		"site/b.css":          `b { color: red; }`,
+		"site/alt.css":        `p { color: green; }`,
+		"site/css/unused.css": ``,
+	}
+	missing := errors.New("missing")
+	fetch := func(ref string) ([]byte, error) {
+		if text, ok := files[ref]; ok {
+			return []byte(text), nil
+		}
+		return nil, missing
+	}
+
+	sheet, err := Load(d, fetch, func(ref string) string { return "/root/" + ref })
+	var loadErr *Error
+	if !errors.As(err, &loadErr) || loadErr.Source != "/root/site/missing.css" || !errors.Is(err, missing) {
+		t.Errorf("expected the missing stylesheet reported, got %v", err)
+	}
+
+	// Imports come before the rules of the stylesheet importing them, in
+	// the media of the import; an @import after a rule is ignored
+	type rule struct {
+		selector, media, source string
+	}
+	want := []rule{
+		{"div", "", "/root/site/common.css"},
+		{"i", "screen and (min-width: 100px)", "/root/site/css/loop.css"},
+		{"p", "", "/root/site/css/a.css"},
+		{"b", "print", "/root/site/b.css"},
+		{"p", "", "<style>"},
+	}
+	var got []rule
+	for _, r := range sheet.Rules {
+		media := ""
+		for _, q := range r.Media {
+			media += q.String()
+		}
+		got = append(got, rule{r.Selectors[0].String(), media, r.Pos.Source})
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rule %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+	if screen := sheet.ForMedia(css.DefaultMediaContext()); len(screen.Rules) != 4 {
+		t.Errorf("expected the print rule left out on screen, got %d rules", len(screen.Rules))
+	}
+}
+
+func TestLoadImportLoop(t *testing.T) {
+	d, err := dom.ParseString(`<link rel="stylesheet" href="a.css">`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetch := func(ref string) ([]byte, error) {
+		return []byte(`@import "a.css"; p { color: red; }`), nil
+	}
+	sheet, err := Load(d, fetch, nil)
+	if !errors.Is(err, ErrImportLoop) {
+		t.Errorf("expected the loop reported, got %v", err)
+	}
+	if len(sheet.Rules) != 1 {
+		t.Errorf("expected the stylesheet loaded once, got %d rules", len(sheet.Rules))
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct{ base, ref, want string }{
+		{"", "a.css", "a.css"},
+		{"css/a.css", "b.css", "css/b.css"},
+		{"css/a.css", "../b.css?v=1", "b.css?v=1"},
+		{"site/", "a.css", "site/a.css"},
+		{"https://example.com/css/a.css", "b.css", "https://example.com/css/b.css"},
+		{"https://example.com/css/a.css", "/b.css", "https://example.com/b.css"},
+		{"css/a.css", "https://cdn.example.com/b.css", "https://cdn.example.com/b.css"},
+	}
+	for _, tt := range tests {
+		if got := resolve(tt.base, tt.ref); got != tt.want {
+			t.Errorf("resolve(%q, %q): expected %q, got %q", tt.base, tt.ref, tt.want, got)
+		}
+	}
+}
+
+func TestLoadEach(t *testing.T) {
+	d, err := dom.ParseString(`<html><head>
+		<link rel="stylesheet" href="a.css">
+		<link rel="stylesheet" href="missing.css">
+		<style>p { color: blue; }</style>
+		</head></html>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetch := func(ref string) ([]byte, error) {
+		if ref == "a.css" {
+			return []byte(`@import "b.css"; p { color: red; }`), nil
+		}
+		if ref == "b.css" {
+			return []byte(`b { color: red; }`), nil
+		}
+		return nil, errors.New("missing")
+	}
+
+	// A stylesheet that fails to load isn't reported, and one reported comes
+	// with its imports
+	var sources []string
+	var counts []int
+	var first *css.Stylesheet
+	sheet, _ := LoadEach(d, fetch, nil, func(sheet *css.Stylesheet, source string) {
+		if first == nil {
+			first = sheet
+		}
+		sources = append(sources, source)
+		counts = append(counts, len(sheet.Rules))
+	})
+	if len(sources) != 2 || sources[0] != "a.css" || sources[1] != "<style>" {
+		t.Errorf("expected a.css then <style>, got %v", sources)
+	}
+	if len(counts) != 2 || counts[0] != 2 || counts[1] != 3 {
+		t.Errorf("expected 2 then 3 rules, got %v", counts)
+	}
+	if len(first.Rules) != 2 || len(sheet.Rules) != 3 {
+		t.Errorf("expected each stylesheet reported to be a copy, got %d rules", len(first.Rules))
+	}
+}
@@ -0,0 +1,162 @@
+package reftest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/paint"
+)
+
+// benchPages are representative pages the pipeline is measured against
+var benchPages = []string{"small", "medium", "image-heavy", "deep"}
+
+// readBenchPage reads a page from testdata/bench
+func readBenchPage(b *testing.B, name string) (htmlContent, baseDir string) {
+	b.Helper()
+
+	path := filepath.Join("testdata", "bench", name+".html")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatalf("failed to read %s: %v", path, err)
+	}
+	return string(data), filepath.Dir(path)
+}
+
+// runBenchPages runs fn as a sub-benchmark for every page
+func runBenchPages(b *testing.B, fn func(b *testing.B, htmlContent, baseDir string)) {
+	for _, name := range benchPages {
+		b.Run(name, func(b *testing.B) {
+			htmlContent, baseDir := readBenchPage(b, name)
+			b.ReportAllocs()
+			fn(b, htmlContent, baseDir)
+		})
+	}
+}
+
+func mustParseBenchPage(b *testing.B, htmlContent string) *dom.DOM {
+	b.Helper()
+
+	document, err := dom.ParseString(htmlContent)
+	if err != nil {
+		b.Fatalf("parse error: %v", err)
+	}
+	return document
+}
+
+func BenchmarkParseHTML(b *testing.B) {
+	runBenchPages(b, func(b *testing.B, htmlContent, baseDir string) {
+		for i := 0; i < b.N; i++ {
+			if _, err := dom.ParseString(htmlContent); err != nil {
+				b.Fatalf("parse error: %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkLoadStylesheets(b *testing.B) {
+	runBenchPages(b, func(b *testing.B, htmlContent, baseDir string) {
+		document := mustParseBenchPage(b, htmlContent)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			loadStylesheets(document, baseDir)
+		}
+	})
+}
+
+func BenchmarkBuildLayoutTree(b *testing.B) {
+	runBenchPages(b, func(b *testing.B, htmlContent, baseDir string) {
+		document := mustParseBenchPage(b, htmlContent)
+		stylesheet := loadStylesheets(document, baseDir)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			layout.BuildLayoutTree(document, stylesheet)
+		}
+	})
+}
+
+func BenchmarkComputeLayout(b *testing.B) {
+	runBenchPages(b, func(b *testing.B, htmlContent, baseDir string) {
+		document := mustParseBenchPage(b, htmlContent)
+		stylesheet := loadStylesheets(document, baseDir)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Layout mutates the tree, so every iteration needs a fresh one
+			b.StopTimer()
+			layoutTree := layout.BuildLayoutTree(document, stylesheet)
+			b.StartTimer()
+
+			layout.ComputeLayout(layoutTree, viewportWidth, viewportHeight)
+		}
+	})
+}
+
+func BenchmarkPaint(b *testing.B) {
+	runBenchPages(b, func(b *testing.B, htmlContent, baseDir string) {
+		document := mustParseBenchPage(b, htmlContent)
+		layoutTree := layout.BuildLayoutTree(document, loadStylesheets(document, baseDir))
+		layout.ComputeLayout(layoutTree, viewportWidth, viewportHeight)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			paint.Paint(layoutTree)
+		}
+	})
+}
+
+// BenchmarkPaintPooled paints into lists reused through the pool, as the GUI
+// does when it renders continuously
+func BenchmarkPaintPooled(b *testing.B) {
+	runBenchPages(b, func(b *testing.B, htmlContent, baseDir string) {
+		document := mustParseBenchPage(b, htmlContent)
+		layoutTree := layout.BuildLayoutTree(document, loadStylesheets(document, baseDir))
+		layout.ComputeLayout(layoutTree, viewportWidth, viewportHeight)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			paintList := paint.AcquirePaintList()
+			paint.PaintInto(paintList, layoutTree)
+			paintList.Release()
+		}
+	})
+}
+
+func BenchmarkRasterize(b *testing.B) {
+	runBenchPages(b, func(b *testing.B, htmlContent, baseDir string) {
+		document := mustParseBenchPage(b, htmlContent)
+		layoutTree := layout.BuildLayoutTree(document, loadStylesheets(document, baseDir))
+		layout.ComputeLayout(layoutTree, viewportWidth, viewportHeight)
+
+		paintList := paint.NewPaintList()
+		paint.PaintBackground(paintList, viewportWidth, viewportHeight, css.ColorWhite)
+		paint.PaintInto(paintList, layoutTree)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			paint.Rasterize(paintList, viewportWidth, viewportHeight)
+		}
+	})
+}
+
+// BenchmarkPipeline measures the whole path from HTML source to pixels
+func BenchmarkPipeline(b *testing.B) {
+	runBenchPages(b, func(b *testing.B, htmlContent, baseDir string) {
+		for i := 0; i < b.N; i++ {
+			document := mustParseBenchPage(b, htmlContent)
+			layoutTree := layout.BuildLayoutTree(document, loadStylesheets(document, baseDir))
+			layout.ComputeLayout(layoutTree, viewportWidth, viewportHeight)
+
+			paintList := paint.NewPaintList()
+			paint.PaintBackground(paintList, viewportWidth, viewportHeight, css.ColorWhite)
+			paint.PaintInto(paintList, layoutTree)
+
+			paint.Rasterize(paintList, viewportWidth, viewportHeight)
+		}
+	})
+}
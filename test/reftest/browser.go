@@ -0,0 +1,229 @@
+package reftest
+
+import (
+	"fmt"
+	"image"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// pagePool hands out reusable Playwright pages so that parallel reftests
+// don't pay for a fresh browser context on every capture.
+type pagePool struct {
+	contexts []playwright.BrowserContext
+	pages    chan playwright.Page
+}
+
+// newPagePool creates size browser contexts, each with one page sized to the
+// reftest viewport. Pixels map 1:1 to CSS pixels regardless of the host
+// display, and pages that honor prefers-reduced-motion skip their animations.
+func newPagePool(browser playwright.Browser, size int) (*pagePool, error) {
+	pool := &pagePool{
+		pages: make(chan playwright.Page, size),
+	}
+
+	for i := 0; i < size; i++ {
+		ctx, err := browser.NewContext(playwright.BrowserNewContextOptions{
+			Viewport: &playwright.Size{
+				Width:  viewportWidth,
+				Height: viewportHeight,
+			},
+			DeviceScaleFactor: playwright.Float(1),
+			ReducedMotion:     playwright.ReducedMotionReduce,
+		})
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.contexts = append(pool.contexts, ctx)
+
+		page, err := ctx.NewPage()
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.pages <- page
+	}
+
+	return pool, nil
+}
+
+// acquire blocks until a page is available
+func (p *pagePool) acquire() playwright.Page {
+	return <-p.pages
+}
+
+// release returns a page to the pool
+func (p *pagePool) release(page playwright.Page) {
+	p.pages <- page
+}
+
+func (p *pagePool) Close() {
+	for _, ctx := range p.contexts {
+		ctx.Close()
+	}
+}
+
+// poolSize returns the number of parallel workers. It defaults to the number
+// of CPUs and can be overridden with PENNY_REFTEST_WORKERS.
+func poolSize() int {
+	if v := os.Getenv("PENNY_REFTEST_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return runtime.NumCPU()
+}
+
+// startBrowser launches the browser for engine (EngineChrome, EngineFirefox
+// or EngineWebKit) and returns a page pool of the given size along with a
+// function that tears everything down
+func startBrowser(engine string, size int) (*pagePool, func(), error) {
+	pw, err := playwright.Run()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not start playwright: %w", err)
+	}
+
+	var browserType playwright.BrowserType
+	switch engine {
+	case EngineChrome:
+		browserType = pw.Chromium
+	case EngineFirefox:
+		browserType = pw.Firefox
+	case EngineWebKit:
+		browserType = pw.WebKit
+	default:
+		pw.Stop()
+		return nil, nil, fmt.Errorf("unknown browser engine: %s", engine)
+	}
+
+	browser, err := browserType.Launch()
+	if err != nil {
+		pw.Stop()
+		return nil, nil, fmt.Errorf("could not launch %s: %w", engine, err)
+	}
+
+	pool, err := newPagePool(browser, size)
+	if err != nil {
+		browser.Close()
+		pw.Stop()
+		return nil, nil, fmt.Errorf("could not create page pool: %w", err)
+	}
+
+	stop := func() {
+		pool.Close()
+		browser.Close()
+		pw.Stop()
+	}
+	return pool, stop, nil
+}
+
+// startTestServer serves dir over HTTP on a free local port. The listening
+// address is available as server.Addr.
+func startTestServer(dir string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", serveResources(http.FileServer(http.Dir(dir))))
+
+	server := &http.Server{
+		Addr:    ln.Addr().String(),
+		Handler: mux,
+	}
+
+	go server.Serve(ln)
+	return server, nil
+}
+
+// fontTypes are the content types of web fonts, which Go doesn't know about
+var fontTypes = map[string]string{
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+}
+
+// serveResources wraps the file server so that browsers accept the images
+// and fonts test pages reference: fonts get their content type and may be
+// loaded from other origins, as WPT does for its www subdomains
+func serveResources(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType, ok := fontTypes[strings.ToLower(path.Ext(r.URL.Path))]; ok {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		// Resources may change between runs
+		w.Header().Set("Cache-Control", "no-store")
+		next.ServeHTTP(w, r)
+	})
+}
+
+func captureBrowser(pool *pagePool, serverAddr, htmlFileName string) (*image.RGBA, error) {
+	url := fmt.Sprintf("http://%s/%s", serverAddr, htmlFileName)
+	return captureBrowserURL(pool, url)
+}
+
+func captureBrowserURL(pool *pagePool, testURL string) (*image.RGBA, error) {
+	page := pool.acquire()
+	defer pool.release(page)
+
+	if _, err := page.Goto(testURL); err != nil {
+		return nil, err
+	}
+
+	// Wait for page to load
+	if err := page.WaitForLoadState(playwright.PageWaitForLoadStateOptions{
+		State: playwright.LoadStateNetworkidle,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := stabilizePage(page); err != nil {
+		return nil, err
+	}
+
+	// Take screenshot
+	screenshot, err := page.Screenshot(playwright.PageScreenshotOptions{
+		Type:       playwright.ScreenshotTypePng,
+		Animations: playwright.ScreenshotAnimationsDisabled,
+		Caret:      playwright.ScreenshotCaretHide,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePNG(screenshot)
+}
+
+// stabilizeStyle stops CSS animations and transitions, which penny doesn't
+// run, so that the screenshot shows the final state of the page
+const stabilizeStyle = `*, *::before, *::after {
+  animation: none !important;
+  transition: none !important;
+}`
+
+// stabilizePage removes sources of nondeterminism from a loaded page before
+// it is captured: animations are stopped and web fonts have finished loading
+func stabilizePage(page playwright.Page) error {
+	if _, err := page.AddStyleTag(playwright.PageAddStyleTagOptions{
+		Content: playwright.String(stabilizeStyle),
+	}); err != nil {
+		return fmt.Errorf("failed to disable animations: %w", err)
+	}
+
+	// Evaluate waits for the returned promise to settle
+	if _, err := page.Evaluate(`() => document.fonts.ready.then(() => true)`); err != nil {
+		return fmt.Errorf("failed to wait for fonts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package reftest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/paint"
+)
+
+// pageBudget is the upper bound on the work penny may do for a page. The
+// limits sit comfortably above the current numbers, so they only trip on
+// real regressions such as duplicated nodes or quadratic allocation.
+type pageBudget struct {
+	LayoutNodes int
+	PaintOps    int
+	ParseAllocs float64 // allocations of dom.ParseString
+	BuildAllocs float64 // allocations of loading CSS and building the layout tree
+}
+
+var pageBudgets = map[string]pageBudget{
+	"small":       {LayoutNodes: 10, PaintOps: 10, ParseAllocs: 60, BuildAllocs: 120},
+	"medium":      {LayoutNodes: 300, PaintOps: 300, ParseAllocs: 700, BuildAllocs: 650},
+	"image-heavy": {LayoutNodes: 650, PaintOps: 400, ParseAllocs: 2500, BuildAllocs: 1800},
+	"deep":        {LayoutNodes: 300, PaintOps: 300, ParseAllocs: 1200, BuildAllocs: 1100},
+}
+
+func TestPageBudgets(t *testing.T) {
+	for _, name := range benchPages {
+		budget, ok := pageBudgets[name]
+		if !ok {
+			t.Fatalf("no budget for page %s", name)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", "bench", name+".html")
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", path, err)
+			}
+			htmlContent, baseDir := string(data), filepath.Dir(path)
+
+			document, err := dom.ParseString(htmlContent)
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+			layoutTree := layout.BuildLayoutTree(document, loadStylesheets(document, baseDir))
+			layout.ComputeLayout(layoutTree, viewportWidth, viewportHeight)
+			ops := paint.Paint(layoutTree)
+
+			if n := len(layoutTree.Nodes); n > budget.LayoutNodes {
+				t.Errorf("layout tree has %d nodes, budget is %d", n, budget.LayoutNodes)
+			}
+			if n := len(ops.Ops); n > budget.PaintOps {
+				t.Errorf("paint list has %d ops, budget is %d", n, budget.PaintOps)
+			}
+
+			parseAllocs := testing.AllocsPerRun(10, func() {
+				dom.ParseString(htmlContent)
+			})
+			if parseAllocs > budget.ParseAllocs {
+				t.Errorf("parsing allocates %.0f times, budget is %.0f", parseAllocs, budget.ParseAllocs)
+			}
+
+			buildAllocs := testing.AllocsPerRun(10, func() {
+				layout.BuildLayoutTree(document, loadStylesheets(document, baseDir))
+			})
+			if buildAllocs > budget.BuildAllocs {
+				t.Errorf("building the layout tree allocates %.0f times, budget is %.0f", buildAllocs, budget.BuildAllocs)
+			}
+		})
+	}
+}
+
+// TestTreeConstructionScales checks that building the trees of a page four
+// times as large costs at most about four times as much
+func TestTreeConstructionScales(t *testing.T) {
+	shapes := map[string]func(n int) string{
+		"siblings": func(n int) string {
+			return "<html><body>" + strings.Repeat(`<div class="box">item</div>`, n) + "</body></html>"
+		},
+		"nested": func(n int) string {
+			return "<html><body>" + strings.Repeat(`<div class="box">`, n) + "leaf" + strings.Repeat("</div>", n) + "</body></html>"
+		},
+	}
+
+	stylesheet, err := css.Parse(".box { padding: 1px; background: red; }")
+	if err != nil {
+		t.Fatalf("css parse error: %v", err)
+	}
+
+	for name, shape := range shapes {
+		t.Run(name, func(t *testing.T) {
+			cost := func(n int) (nodes, ops int, allocs float64) {
+				htmlContent := shape(n)
+				allocs = testing.AllocsPerRun(5, func() {
+					document, err := dom.ParseString(htmlContent)
+					if err != nil {
+						t.Fatalf("parse error: %v", err)
+					}
+					layoutTree := layout.BuildLayoutTree(document, stylesheet)
+					layout.ComputeLayout(layoutTree, viewportWidth, viewportHeight)
+					paintList := paint.Paint(layoutTree)
+					nodes, ops = len(layoutTree.Nodes), len(paintList.Ops)
+				})
+				return nodes, ops, allocs
+			}
+
+			const n = 100
+			smallNodes, smallOps, smallAllocs := cost(n)
+			largeNodes, largeOps, largeAllocs := cost(4 * n)
+
+			check := func(what string, small, large float64) {
+				// Allow some slack for amortized slice growth
+				if large > 6*small {
+					t.Errorf("%s grew from %.0f to %.0f for %d -> %d elements; expected linear growth",
+						what, small, large, n, 4*n)
+				}
+			}
+			check("layout nodes", float64(smallNodes), float64(largeNodes))
+			check("paint ops", float64(smallOps), float64(largeOps))
+			check("allocations", smallAllocs, largeAllocs)
+
+			t.Logf("n=%d: %d nodes, %d ops, %.0f allocs; n=%d: %d nodes, %d ops, %.0f allocs",
+				n, smallNodes, smallOps, smallAllocs, 4*n, largeNodes, largeOps, largeAllocs)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package reftest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/myuon/penny/dom"
+)
+
+// fuzzyRange is an inclusive range of allowed values
+type fuzzyRange struct {
+	Min, Max int
+}
+
+func (r fuzzyRange) contains(v int) bool {
+	return v >= r.Min && v <= r.Max
+}
+
+// wptFuzzy is a <meta name="fuzzy"> tolerance annotation. It allows the
+// rendering to differ from the reference by up to MaxDifference in any color
+// channel on TotalPixels pixels.
+type wptFuzzy struct {
+	Ref           string // reference the annotation applies to; "" for all
+	MaxDifference fuzzyRange
+	TotalPixels   fuzzyRange
+}
+
+// allows reports whether the given pixel statistics are within tolerance
+func (f wptFuzzy) allows(maxDiff, diffPixels int) bool {
+	// An identical rendering is always acceptable
+	if diffPixels == 0 {
+		return true
+	}
+	return f.MaxDifference.contains(maxDiff) && f.TotalPixels.contains(diffPixels)
+}
+
+// findWPTFuzzy returns the fuzzy annotations declared in the document.
+// Malformed annotations are ignored.
+func findWPTFuzzy(d *dom.DOM) []wptFuzzy {
+	var fuzzies []wptFuzzy
+
+	dom.Walk(d, d.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Type == dom.NodeTypeElement && node.Tag == "meta" && node.Attr["name"] == "fuzzy" {
+			if fuzzy, err := parseWPTFuzzy(node.Attr["content"]); err == nil {
+				fuzzies = append(fuzzies, fuzzy)
+			}
+		}
+
+		return dom.WalkContinue
+	})
+	return fuzzies
+}
+
+// fuzzyFor returns the annotation that applies to the given reference href.
+// An annotation naming the reference takes precedence over a global one.
+func fuzzyFor(fuzzies []wptFuzzy, href string) (wptFuzzy, bool) {
+	var global *wptFuzzy
+	for i, f := range fuzzies {
+		if f.Ref == "" {
+			if global == nil {
+				global = &fuzzies[i]
+			}
+			continue
+		}
+		if f.Ref == href || strings.HasSuffix(href, "/"+f.Ref) {
+			return f, true
+		}
+	}
+	if global != nil {
+		return *global, true
+	}
+	return wptFuzzy{}, false
+}
+
+// parseWPTFuzzy parses the content of a fuzzy annotation, e.g.
+// "maxDifference=0-2;totalPixels=0-300", "0-2;0-300" or
+// "ref.html:maxDifference=2;totalPixels=300"
+func parseWPTFuzzy(content string) (wptFuzzy, error) {
+	var fuzzy wptFuzzy
+
+	if idx := strings.LastIndex(content, ":"); idx >= 0 {
+		fuzzy.Ref = strings.TrimSpace(content[:idx])
+		content = content[idx+1:]
+	}
+
+	parts := strings.Split(content, ";")
+	if len(parts) != 2 {
+		return wptFuzzy{}, fmt.Errorf("expected 2 ranges, got %d", len(parts))
+	}
+
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		key := ""
+		if k, v, ok := strings.Cut(part, "="); ok {
+			key = strings.TrimSpace(k)
+			part = strings.TrimSpace(v)
+		} else if i == 0 {
+			key = "maxDifference"
+		} else {
+			key = "totalPixels"
+		}
+
+		r, err := parseFuzzyRange(part)
+		if err != nil {
+			return wptFuzzy{}, err
+		}
+
+		switch key {
+		case "maxDifference":
+			fuzzy.MaxDifference = r
+		case "totalPixels":
+			fuzzy.TotalPixels = r
+		default:
+			return wptFuzzy{}, fmt.Errorf("unknown fuzzy key %q", key)
+		}
+	}
+
+	return fuzzy, nil
+}
+
+// parseFuzzyRange parses "n" (exactly n) or "n-m"
+func parseFuzzyRange(s string) (fuzzyRange, error) {
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		minValue, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return fuzzyRange{}, err
+		}
+		maxValue, err := strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil {
+			return fuzzyRange{}, err
+		}
+		return fuzzyRange{Min: minValue, Max: maxValue}, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fuzzyRange{}, err
+	}
+	return fuzzyRange{Min: v, Max: v}, nil
+}
@@ -0,0 +1,48 @@
+package reftest
+
+import (
+	"testing"
+)
+
+func TestParseWPTFuzzy(t *testing.T) {
+	tests := []struct {
+		content string
+		want    wptFuzzy
+	}{
+		{"maxDifference=0-2;totalPixels=0-300", wptFuzzy{MaxDifference: fuzzyRange{0, 2}, TotalPixels: fuzzyRange{0, 300}}},
+		{"0-2;0-300", wptFuzzy{MaxDifference: fuzzyRange{0, 2}, TotalPixels: fuzzyRange{0, 300}}},
+		{"totalPixels=10;maxDifference=5", wptFuzzy{MaxDifference: fuzzyRange{5, 5}, TotalPixels: fuzzyRange{10, 10}}},
+		{"ref.html:maxDifference=1-3;totalPixels=4", wptFuzzy{Ref: "ref.html", MaxDifference: fuzzyRange{1, 3}, TotalPixels: fuzzyRange{4, 4}}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWPTFuzzy(tt.content)
+		if err != nil {
+			t.Errorf("parseWPTFuzzy(%q) failed: %v", tt.content, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseWPTFuzzy(%q) = %+v, want %+v", tt.content, got, tt.want)
+		}
+	}
+
+	for _, content := range []string{"", "0-2", "foo=1;bar=2", "a-b;0-1"} {
+		if _, err := parseWPTFuzzy(content); err == nil {
+			t.Errorf("parseWPTFuzzy(%q) should fail", content)
+		}
+	}
+}
+
+func TestWPTFuzzyAllows(t *testing.T) {
+	fuzzy := wptFuzzy{MaxDifference: fuzzyRange{1, 3}, TotalPixels: fuzzyRange{1, 100}}
+
+	if !fuzzy.allows(2, 50) {
+		t.Error("expected difference within range to be allowed")
+	}
+	if fuzzy.allows(4, 50) {
+		t.Error("expected maxDifference above range to be rejected")
+	}
+	if fuzzy.allows(2, 101) {
+		t.Error("expected totalPixels above range to be rejected")
+	}
+}
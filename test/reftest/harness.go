@@ -0,0 +1,273 @@
+package reftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/myuon/penny/css"
+)
+
+// Engines that penny's rendering can be compared against
+const (
+	// EngineChrome pixel-diffs penny against a Chromium screenshot
+	EngineChrome = "chrome"
+	// EngineFirefox pixel-diffs penny against a Firefox screenshot
+	EngineFirefox = "firefox"
+	// EngineWebKit pixel-diffs penny against a WebKit screenshot
+	EngineWebKit = "webkit"
+	// EngineBaseline renders the test's rel=match/mismatch references with
+	// penny and checks the test agrees with them
+	EngineBaseline = "baseline"
+)
+
+// Options configures a suite run
+type Options struct {
+	WPTRoot   string  // path to the WPT checkout
+	Suite     string  // suite directory relative to WPTRoot, e.g. "css/css-flexbox"
+	Filter    string  // regexp matched against test paths; empty matches all
+	Engine    string  // EngineBaseline, or a comma-separated list of browser engines
+	Threshold float64 // maximum diff percentage for a pass (browser engines)
+	MaxTests  int     // sample this many tests of the shard; 0 runs all
+	Seed      int64   // seed for sampling, so that runs are reproducible
+	Workers   int     // number of tests run in parallel; 0 uses one per CPU
+	OutputDir string  // where diff images and summary.json are written
+
+	// ShardIndex and ShardCount split the suite across jobs: this run takes
+	// every ShardCount-th test starting at ShardIndex. ShardCount 0 means no
+	// sharding.
+	ShardIndex int
+	ShardCount int
+
+	// OnResult, if set, is called as each test finishes
+	OnResult func(WPTTestResult)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Engine == "" {
+		o.Engine = EngineChrome
+	}
+	if o.Workers <= 0 {
+		o.Workers = poolSize()
+	}
+	if o.OutputDir == "" {
+		dir := "wpt"
+		switch o.Engine {
+		case EngineChrome:
+		case EngineBaseline:
+			dir = "wpt-reftest"
+		default:
+			dir = "wpt-" + strings.ReplaceAll(o.Engine, ",", "-")
+		}
+		o.OutputDir = filepath.Join("output", dir, o.Suite)
+	}
+	return o
+}
+
+// RunSuite runs the tests of a WPT suite and writes summary.json,
+// coverage.json and a per-revision history entry to the output directory
+func RunSuite(opts Options) (*WPTSuiteResult, error) {
+	opts = opts.withDefaults()
+
+	if opts.ShardCount > 0 && (opts.ShardIndex < 0 || opts.ShardIndex >= opts.ShardCount) {
+		return nil, fmt.Errorf("invalid shard %d/%d", opts.ShardIndex, opts.ShardCount)
+	}
+
+	testFiles, err := FindWPTTestFiles(opts.WPTRoot, opts.Suite)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Filter != "" {
+		re, err := regexp.Compile(opts.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		var filtered []string
+		for _, testFile := range testFiles {
+			relPath, _ := filepath.Rel(opts.WPTRoot, testFile)
+			if re.MatchString(relPath) {
+				filtered = append(filtered, testFile)
+			}
+		}
+		testFiles = filtered
+	}
+
+	testFiles = selectTests(testFiles, opts)
+
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	var run func(testFile string) WPTTestResult
+	if opts.Engine == EngineBaseline {
+		run = func(testFile string) WPTTestResult {
+			return runWPTReftest(opts.WPTRoot, testFile, opts.OutputDir)
+		}
+	} else {
+		server, err := startTestServer(opts.WPTRoot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start test server: %w", err)
+		}
+		defer server.Close()
+
+		var engines []browserEngine
+		for _, name := range strings.Split(opts.Engine, ",") {
+			name = strings.TrimSpace(name)
+			pool, stop, err := startBrowser(name, opts.Workers)
+			if err != nil {
+				return nil, err
+			}
+			defer stop()
+
+			engines = append(engines, browserEngine{Name: name, Pool: pool})
+		}
+
+		run = func(testFile string) WPTTestResult {
+			return runWPTTest(engines, server.Addr, opts.WPTRoot, testFile, opts.OutputDir, opts.Threshold)
+		}
+	}
+
+	suiteResult := &WPTSuiteResult{
+		Suite:     opts.Suite,
+		Threshold: opts.Threshold,
+		Seed:      opts.Seed,
+	}
+	if opts.ShardCount > 0 {
+		suiteResult.Shard = fmt.Sprintf("%d/%d", opts.ShardIndex, opts.ShardCount)
+	}
+
+	coverage := css.NewCoverage()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for testFile := range jobs {
+				result := runIsolated(run, opts.WPTRoot, testFile)
+				// Failing to collect coverage doesn't fail the test; the
+				// render error is already reported in the result
+				_ = CollectCoverage(coverage, testFile)
+
+				mu.Lock()
+				suiteResult.add(result)
+				if opts.OnResult != nil {
+					opts.OnResult(result)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, testFile := range testFiles {
+		jobs <- testFile
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(suiteResult.Results, func(i, j int) bool {
+		return suiteResult.Results[i].Name < suiteResult.Results[j].Name
+	})
+
+	if rev, err := GitRevision(); err == nil {
+		suiteResult.Revision = rev
+	}
+
+	// Save summary
+	summaryPath := filepath.Join(opts.OutputDir, "summary.json")
+	data, err := json.MarshalIndent(suiteResult, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(summaryPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save summary: %w", err)
+	}
+
+	// Save the properties the suite exercised
+	coveragePath := filepath.Join(opts.OutputDir, "coverage.json")
+	data, err = json.MarshalIndent(coverage.Report(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(coveragePath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save coverage: %w", err)
+	}
+
+	// Record the run per revision
+	if suiteResult.Revision != "" {
+		if _, err := SaveHistory(opts.HistoryDir(), suiteResult); err != nil {
+			return nil, fmt.Errorf("failed to save history: %w", err)
+		}
+	}
+
+	return suiteResult, nil
+}
+
+// HistoryDir returns the directory where per-revision results of the suite
+// are kept
+func (o Options) HistoryDir() string {
+	return filepath.Join(o.withDefaults().OutputDir, "history")
+}
+
+// selectTests picks the tests of this run's shard and samples MaxTests of
+// them. The selection only depends on the set of files and the options, so
+// the same options always select the same tests.
+func selectTests(testFiles []string, opts Options) []string {
+	selected := append([]string(nil), testFiles...)
+	sort.Strings(selected)
+
+	if opts.ShardCount > 0 {
+		var shard []string
+		for i, testFile := range selected {
+			if i%opts.ShardCount == opts.ShardIndex {
+				shard = append(shard, testFile)
+			}
+		}
+		selected = shard
+	}
+
+	if opts.MaxTests > 0 && len(selected) > opts.MaxTests {
+		rng := rand.New(rand.NewSource(opts.Seed))
+		rng.Shuffle(len(selected), func(i, j int) {
+			selected[i], selected[j] = selected[j], selected[i]
+		})
+		selected = selected[:opts.MaxTests]
+		sort.Strings(selected)
+	}
+
+	return selected
+}
+
+// ParseShard parses a shard specification of the form "i/n", where i is the
+// zero-based shard index
+func ParseShard(s string) (index, count int, err error) {
+	i, n, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid shard %q: expected i/n", s)
+	}
+
+	index, err = strconv.Atoi(i)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %w", s, err)
+	}
+	count, err = strconv.Atoi(n)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard %q: %w", s, err)
+	}
+
+	if count <= 0 || index < 0 || index >= count {
+		return 0, 0, fmt.Errorf("invalid shard %q: index must be in [0, %d)", s, count)
+	}
+	return index, count, nil
+}
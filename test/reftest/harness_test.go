@@ -0,0 +1,64 @@
+package reftest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSelectTestsDeterministic(t *testing.T) {
+	var files []string
+	for i := 0; i < 100; i++ {
+		files = append(files, fmt.Sprintf("css/t%03d.html", i))
+	}
+
+	opts := Options{MaxTests: 10, Seed: 42}
+	first := selectTests(files, opts)
+	second := selectTests(files, opts)
+	if len(first) != 10 {
+		t.Fatalf("expected 10 tests, got %d", len(first))
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("same seed selected different tests:\n%v\n%v", first, second)
+	}
+
+	if other := selectTests(files, Options{MaxTests: 10, Seed: 7}); reflect.DeepEqual(first, other) {
+		t.Errorf("different seeds selected the same tests: %v", first)
+	}
+}
+
+func TestSelectTestsShards(t *testing.T) {
+	var files []string
+	for i := 0; i < 25; i++ {
+		files = append(files, fmt.Sprintf("css/t%03d.html", i))
+	}
+
+	seen := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		for _, f := range selectTests(files, Options{ShardIndex: i, ShardCount: 4}) {
+			seen[f]++
+		}
+	}
+
+	if len(seen) != len(files) {
+		t.Errorf("shards cover %d of %d tests", len(seen), len(files))
+	}
+	for f, n := range seen {
+		if n != 1 {
+			t.Errorf("%s selected by %d shards", f, n)
+		}
+	}
+}
+
+func TestParseShard(t *testing.T) {
+	index, count, err := ParseShard("2/5")
+	if err != nil || index != 2 || count != 5 {
+		t.Errorf("ParseShard(2/5) = %d, %d, %v", index, count, err)
+	}
+
+	for _, s := range []string{"", "3", "5/5", "-1/2", "a/b", "1/0"} {
+		if _, _, err := ParseShard(s); err == nil {
+			t.Errorf("ParseShard(%q) should fail", s)
+		}
+	}
+}
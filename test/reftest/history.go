@@ -0,0 +1,176 @@
+package reftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GitRevision returns the short revision of the working tree, suffixed with
+// "-dirty" if there are uncommitted changes
+func GitRevision() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse failed: %w", err)
+	}
+	rev := strings.TrimSpace(string(out))
+
+	status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	if err == nil && len(strings.TrimSpace(string(status))) > 0 {
+		rev += "-dirty"
+	}
+
+	return rev, nil
+}
+
+// SaveHistory stores a suite result under historyDir, keyed by its revision
+func SaveHistory(historyDir string, result *WPTSuiteResult) (string, error) {
+	if result.Revision == "" {
+		return "", fmt.Errorf("suite result has no revision")
+	}
+
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(historyDir, result.Revision+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// LoadHistory loads the suite result recorded for a revision
+func LoadHistory(historyDir, revision string) (*WPTSuiteResult, error) {
+	return LoadSuiteResult(filepath.Join(historyDir, revision+".json"))
+}
+
+// LoadSuiteResult reads a summary.json or history file
+func LoadSuiteResult(path string) (*WPTSuiteResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result WPTSuiteResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &result, nil
+}
+
+// DiffDelta is the change in diff percentage of a test between two runs
+type DiffDelta struct {
+	Name   string  `json:"name"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+}
+
+// Comparison is the difference between two runs of the same suite
+type Comparison struct {
+	Base         string      `json:"base"` // revision
+	Head         string      `json:"head"` // revision
+	NewlyFailing []string    `json:"newly_failing,omitempty"`
+	NewlyPassing []string    `json:"newly_passing,omitempty"`
+	Added        []string    `json:"added,omitempty"`
+	Removed      []string    `json:"removed,omitempty"`
+	Deltas       []DiffDelta `json:"deltas,omitempty"` // tests whose diff percentage changed
+}
+
+// Regressed reports whether any test went from passing to failing
+func (c *Comparison) Regressed() bool {
+	return len(c.NewlyFailing) > 0
+}
+
+// CompareResults reports the tests whose status or diff percentage changed
+// between base and head
+func CompareResults(base, head *WPTSuiteResult) *Comparison {
+	c := &Comparison{
+		Base: base.Revision,
+		Head: head.Revision,
+	}
+
+	baseByName := make(map[string]WPTTestResult, len(base.Results))
+	for _, r := range base.Results {
+		baseByName[r.Name] = r
+	}
+
+	headNames := make(map[string]bool, len(head.Results))
+	for _, after := range head.Results {
+		headNames[after.Name] = true
+
+		before, ok := baseByName[after.Name]
+		if !ok {
+			c.Added = append(c.Added, after.Name)
+			continue
+		}
+
+		if before.Status == "pass" && after.Status != "pass" {
+			c.NewlyFailing = append(c.NewlyFailing, after.Name)
+		} else if before.Status != "pass" && after.Status == "pass" {
+			c.NewlyPassing = append(c.NewlyPassing, after.Name)
+		}
+
+		if before.DiffPercent != after.DiffPercent {
+			c.Deltas = append(c.Deltas, DiffDelta{
+				Name:   after.Name,
+				Before: before.DiffPercent,
+				After:  after.DiffPercent,
+			})
+		}
+	}
+
+	for _, r := range base.Results {
+		if !headNames[r.Name] {
+			c.Removed = append(c.Removed, r.Name)
+		}
+	}
+
+	sort.Strings(c.NewlyFailing)
+	sort.Strings(c.NewlyPassing)
+	sort.Strings(c.Added)
+	sort.Strings(c.Removed)
+	sort.Slice(c.Deltas, func(i, j int) bool {
+		return c.Deltas[i].Name < c.Deltas[j].Name
+	})
+
+	return c
+}
+
+func (c *Comparison) Dump() string {
+	var result string
+	result += fmt.Sprintf("Comparing %s -> %s\n", c.Base, c.Head)
+
+	dumpList := func(title string, names []string) {
+		if len(names) == 0 {
+			return
+		}
+		result += fmt.Sprintf("%s (%d):\n", title, len(names))
+		for _, name := range names {
+			result += "  " + name + "\n"
+		}
+	}
+
+	dumpList("Newly failing", c.NewlyFailing)
+	dumpList("Newly passing", c.NewlyPassing)
+	dumpList("Added", c.Added)
+	dumpList("Removed", c.Removed)
+
+	if len(c.Deltas) > 0 {
+		result += fmt.Sprintf("Diff changes (%d):\n", len(c.Deltas))
+		for _, d := range c.Deltas {
+			result += fmt.Sprintf("  %s: %.2f%% -> %.2f%% (%+.2f)\n", d.Name, d.Before, d.After, d.After-d.Before)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,74 @@
+package reftest
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareResults(t *testing.T) {
+	base := &WPTSuiteResult{
+		Revision: "aaaaaaa",
+		Results: []WPTTestResult{
+			{Name: "a.html", Status: "pass", DiffPercent: 1.0},
+			{Name: "b.html", Status: "fail", DiffPercent: 20.0},
+			{Name: "c.html", Status: "pass", DiffPercent: 0.5},
+			{Name: "d.html", Status: "pass", DiffPercent: 0},
+		},
+	}
+	head := &WPTSuiteResult{
+		Revision: "bbbbbbb",
+		Results: []WPTTestResult{
+			{Name: "a.html", Status: "fail", DiffPercent: 12.0},
+			{Name: "b.html", Status: "pass", DiffPercent: 3.0},
+			{Name: "c.html", Status: "pass", DiffPercent: 0.5},
+			{Name: "e.html", Status: "error"},
+		},
+	}
+
+	c := CompareResults(base, head)
+
+	if !reflect.DeepEqual(c.NewlyFailing, []string{"a.html"}) {
+		t.Errorf("unexpected newly failing: %v", c.NewlyFailing)
+	}
+	if !reflect.DeepEqual(c.NewlyPassing, []string{"b.html"}) {
+		t.Errorf("unexpected newly passing: %v", c.NewlyPassing)
+	}
+	if !reflect.DeepEqual(c.Added, []string{"e.html"}) {
+		t.Errorf("unexpected added: %v", c.Added)
+	}
+	if !reflect.DeepEqual(c.Removed, []string{"d.html"}) {
+		t.Errorf("unexpected removed: %v", c.Removed)
+	}
+	if len(c.Deltas) != 2 || c.Deltas[0].Name != "a.html" || c.Deltas[1].Name != "b.html" {
+		t.Errorf("unexpected deltas: %v", c.Deltas)
+	}
+	if !c.Regressed() {
+		t.Error("expected comparison to report a regression")
+	}
+
+	t.Logf("\n%s", c.Dump())
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	result := &WPTSuiteResult{
+		Suite:    "css/css-flexbox",
+		Revision: "abc1234",
+		Total:    1,
+		Passed:   1,
+		Results:  []WPTTestResult{{Name: "a.html", Status: "pass"}},
+	}
+
+	if _, err := SaveHistory(dir, result); err != nil {
+		t.Fatalf("SaveHistory failed: %v", err)
+	}
+
+	loaded, err := LoadHistory(dir, "abc1234")
+	if err != nil {
+		t.Fatalf("LoadHistory failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, result) {
+		t.Errorf("round trip mismatch: %+v != %+v", loaded, result)
+	}
+}
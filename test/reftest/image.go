@@ -0,0 +1,189 @@
+package reftest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+// DiffImages compares two renderings of the same size, such as penny's
+// renderings of two pages. It returns a and b, their diff and a heatmap of
+// it side by side, and the percentage of pixels that differ beyond the
+// tolerance for anti-aliasing.
+func DiffImages(a, b *image.RGBA) (*image.RGBA, float64) {
+	diff, percent := compareImages(a, b)
+	return createCombinedImage(a, b, diff), percent
+}
+
+func compareImages(img1, img2 *image.RGBA) (*image.RGBA, float64) {
+	bounds := img1.Bounds()
+	diffImg := image.NewRGBA(bounds)
+
+	totalPixels := bounds.Dx() * bounds.Dy()
+	diffPixels := 0
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c1 := img1.RGBAAt(x, y)
+			c2 := img2.RGBAAt(x, y)
+
+			if colorsEqual(c1, c2) {
+				// Same pixel - show dimmed version
+				diffImg.SetRGBA(x, y, color.RGBA{
+					R: c1.R / 3,
+					G: c1.G / 3,
+					B: c1.B / 3,
+					A: 255,
+				})
+			} else {
+				// Different pixel - show in red
+				diffImg.SetRGBA(x, y, color.RGBA{R: 255, G: 0, B: 0, A: 255})
+				diffPixels++
+			}
+		}
+	}
+
+	diffPercent := float64(diffPixels) / float64(totalPixels) * 100
+	return diffImg, diffPercent
+}
+
+func colorsEqual(c1, c2 color.RGBA) bool {
+	// Allow small tolerance for anti-aliasing differences
+	const tolerance = 5
+	return abs(int(c1.R)-int(c2.R)) <= tolerance &&
+		abs(int(c1.G)-int(c2.G)) <= tolerance &&
+		abs(int(c1.B)-int(c2.B)) <= tolerance
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// pixelStats returns the largest per-channel difference and the number of
+// differing pixels between two images, without any tolerance
+func pixelStats(img1, img2 *image.RGBA) (maxDiff, diffPixels int) {
+	bounds := img1.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c1 := img1.RGBAAt(x, y)
+			c2 := img2.RGBAAt(x, y)
+
+			d := max(
+				abs(int(c1.R)-int(c2.R)),
+				abs(int(c1.G)-int(c2.G)),
+				abs(int(c1.B)-int(c2.B)),
+				abs(int(c1.A)-int(c2.A)),
+			)
+			if d > 0 {
+				diffPixels++
+				if d > maxDiff {
+					maxDiff = d
+				}
+			}
+		}
+	}
+	return maxDiff, diffPixels
+}
+
+// createCombinedImage lays out the expected rendering, penny's rendering,
+// the diff and a heatmap of diff intensity side by side
+func createCombinedImage(chrome, penny, diff *image.RGBA) *image.RGBA {
+	bounds := chrome.Bounds()
+	width := bounds.Dx()
+	height := bounds.Dy()
+
+	// Create combined image: Chrome | Penny | Diff | Heatmap
+	combined := image.NewRGBA(image.Rect(0, 0, width*4, height+30))
+
+	// Fill with gray background
+	draw.Draw(combined, combined.Bounds(), &image.Uniform{color.RGBA{40, 40, 40, 255}}, image.Point{}, draw.Src)
+
+	// Draw Chrome image
+	draw.Draw(combined, image.Rect(0, 30, width, height+30), chrome, bounds.Min, draw.Src)
+
+	// Draw Penny image
+	draw.Draw(combined, image.Rect(width, 30, width*2, height+30), penny, bounds.Min, draw.Src)
+
+	// Draw Diff image
+	draw.Draw(combined, image.Rect(width*2, 30, width*3, height+30), diff, bounds.Min, draw.Src)
+
+	// Draw heatmap
+	heatmap := diffHeatmap(chrome, penny)
+	draw.Draw(combined, image.Rect(width*3, 30, width*4, height+30), heatmap, bounds.Min, draw.Src)
+
+	return combined
+}
+
+// diffHeatmap colors every pixel by how much the two images differ there,
+// from black (identical) through blue and red to yellow (maximal)
+func diffHeatmap(img1, img2 *image.RGBA) *image.RGBA {
+	bounds := img1.Bounds()
+	heatmap := image.NewRGBA(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c1 := img1.RGBAAt(x, y)
+			c2 := img2.RGBAAt(x, y)
+
+			d := max(
+				abs(int(c1.R)-int(c2.R)),
+				abs(int(c1.G)-int(c2.G)),
+				abs(int(c1.B)-int(c2.B)),
+			)
+			heatmap.SetRGBA(x, y, heatColor(d))
+		}
+	}
+	return heatmap
+}
+
+// heatColor maps a channel difference in [0, 255] onto the heatmap gradient
+func heatColor(d int) color.RGBA {
+	if d == 0 {
+		return color.RGBA{0, 0, 0, 255}
+	}
+
+	// Scale to [0, 3) and interpolate between the gradient stops
+	stops := []color.RGBA{
+		{0, 0, 96, 255},    // faint
+		{0, 64, 255, 255},  // blue
+		{255, 0, 0, 255},   // red
+		{255, 255, 0, 255}, // yellow
+	}
+	t := float64(d) / 255 * float64(len(stops)-1)
+	i := min(int(t), len(stops)-2)
+	f := t - float64(i)
+
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*f)
+	}
+	from, to := stops[i], stops[i+1]
+	return color.RGBA{lerp(from.R, to.R), lerp(from.G, to.G), lerp(from.B, to.B), 255}
+}
+
+func decodePNG(data []byte) (*image.RGBA, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert to RGBA
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(bounds)
+	draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+	return rgba, nil
+}
+
+func savePNG(img *image.RGBA, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
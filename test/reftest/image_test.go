@@ -0,0 +1,33 @@
+package reftest
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestDiffImages(t *testing.T) {
+	a := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(a, a.Bounds(), image.NewUniform(color.RGBA{255, 255, 255, 255}), image.Point{}, draw.Src)
+	b := image.NewRGBA(a.Bounds())
+	draw.Draw(b, b.Bounds(), a, image.Point{}, draw.Src)
+	// A quarter of b differs, and a pixel only by anti-aliasing
+	draw.Draw(b, image.Rect(0, 0, 5, 5), image.NewUniform(color.RGBA{0, 0, 0, 255}), image.Point{}, draw.Src)
+	b.SetRGBA(9, 9, color.RGBA{252, 252, 252, 255})
+
+	combined, percent := DiffImages(a, b)
+	if percent != 25 {
+		t.Errorf("expected 25%% of pixels to differ, got %v", percent)
+	}
+	if got := combined.Bounds().Size(); got != (image.Point{40, 40}) {
+		t.Errorf("expected four images side by side under a header, got %v", got)
+	}
+	// The diff marks the differing pixels red
+	if c := combined.RGBAAt(20, 30); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected a differing pixel red in the diff, got %v", c)
+	}
+	if c := combined.RGBAAt(29, 39); c.R == 255 && c.G == 0 {
+		t.Errorf("expected an anti-aliasing difference not marked, got %v", c)
+	}
+}
@@ -0,0 +1,54 @@
+package reftest
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime/debug"
+)
+
+// PanicError is returned when penny panics while rendering a page
+type PanicError struct {
+	Value any
+	Stack string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// recoverPanic turns a panic in the deferring function into a *PanicError
+// stored in err. It must be called directly with defer.
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: string(debug.Stack())}
+	}
+}
+
+// panicStack returns the stack trace carried by err, if it is a panic
+func panicStack(err error) string {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return panicErr.Stack
+	}
+	return ""
+}
+
+// runIsolated runs a single test, recording a panic anywhere in it as an
+// "error" result with its stack trace so that the rest of the suite keeps
+// going
+func runIsolated(run func(testFile string) WPTTestResult, wptRoot, testFile string) (result WPTTestResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			relPath, _ := filepath.Rel(wptRoot, testFile)
+			result = WPTTestResult{
+				Name:   relPath,
+				Status: "error",
+				Error:  fmt.Sprintf("panic: %v", r),
+				Stack:  string(debug.Stack()),
+			}
+		}
+	}()
+
+	return run(testFile)
+}
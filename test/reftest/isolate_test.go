@@ -0,0 +1,48 @@
+package reftest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	render := func() (err error) {
+		defer recoverPanic(&err)
+		var nodes []int
+		_ = nodes[3]
+		return nil
+	}
+
+	err := render()
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	if !strings.Contains(panicErr.Stack, "TestRecoverPanic") {
+		t.Errorf("stack trace does not mention the panicking function:\n%s", panicErr.Stack)
+	}
+	if panicStack(err) == "" {
+		t.Error("panicStack returned an empty stack")
+	}
+}
+
+func TestRunIsolated(t *testing.T) {
+	run := func(testFile string) WPTTestResult {
+		panic("layout exploded")
+	}
+
+	result := runIsolated(run, "wpt", "wpt/css/css-flexbox/a.html")
+	if result.Status != "error" {
+		t.Errorf("expected error status, got %q", result.Status)
+	}
+	if result.Name != "css/css-flexbox/a.html" {
+		t.Errorf("unexpected name: %q", result.Name)
+	}
+	if !strings.Contains(result.Error, "layout exploded") {
+		t.Errorf("unexpected error: %q", result.Error)
+	}
+	if result.Stack == "" {
+		t.Error("expected a stack trace")
+	}
+}
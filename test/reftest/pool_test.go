@@ -0,0 +1,19 @@
+package reftest
+
+import (
+	"testing"
+)
+
+// startPagePool launches Chromium and returns a page pool that is torn down
+// when the test finishes.
+func startPagePool(t *testing.T) *pagePool {
+	t.Helper()
+
+	pool, stop, err := startBrowser(EngineChrome, poolSize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stop)
+
+	return pool
+}
@@ -0,0 +1,121 @@
+package reftest
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+)
+
+// maxRegions is the number of boxes reported in a region breakdown
+const maxRegions = 5
+
+// RegionDiff is the share of differing pixels that fall in a layout box
+type RegionDiff struct {
+	Element    string      `json:"element"` // e.g. "div#main.box", or "#text"
+	Rect       layout.Rect `json:"rect"`
+	DiffPixels int         `json:"diff_pixels"`
+	Percent    float64     `json:"percent"` // of all differing pixels
+}
+
+// regionBreakdown attributes every differing pixel to the innermost layout box
+// penny drew there and returns the boxes that contributed the most. Pixels
+// outside of every box are attributed to "(viewport)".
+func regionBreakdown(d *dom.DOM, tree *layout.LayoutTree, img1, img2 *image.RGBA) []RegionDiff {
+	bounds := img1.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// owner[i] is the layout node covering pixel i, or -1
+	owner := make([]layout.LayoutNodeID, width*height)
+	for i := range owner {
+		owner[i] = layout.InvalidLayoutNodeID
+	}
+
+	// Later nodes in tree order are painted on top, so they win
+	layout.Walk(tree, tree.Root, func(node *layout.LayoutNode, depth int) layout.WalkAction {
+		x0 := max(int(math.Floor(float64(node.Rect.X))), 0)
+		y0 := max(int(math.Floor(float64(node.Rect.Y))), 0)
+		x1 := min(int(math.Ceil(float64(node.Rect.X+node.Rect.W))), width)
+		y1 := min(int(math.Ceil(float64(node.Rect.Y+node.Rect.H))), height)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				owner[y*width+x] = node.ID
+			}
+		}
+		return layout.WalkContinue
+	})
+
+	counts := make(map[layout.LayoutNodeID]int)
+	total := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			px, py := bounds.Min.X+x, bounds.Min.Y+y
+			if colorsEqual(img1.RGBAAt(px, py), img2.RGBAAt(px, py)) {
+				continue
+			}
+			counts[owner[y*width+x]]++
+			total++
+		}
+	}
+
+	regions := make([]RegionDiff, 0, len(counts))
+	for id, n := range counts {
+		region := RegionDiff{
+			Element:    "(viewport)",
+			DiffPixels: n,
+			Percent:    float64(n) / float64(total) * 100,
+		}
+		if node := tree.GetNode(id); node != nil {
+			region.Element = describeLayoutNode(d, node)
+			region.Rect = node.Rect
+		}
+		regions = append(regions, region)
+	}
+
+	sort.Slice(regions, func(i, j int) bool {
+		if regions[i].DiffPixels != regions[j].DiffPixels {
+			return regions[i].DiffPixels > regions[j].DiffPixels
+		}
+		return regions[i].Element < regions[j].Element
+	})
+	if len(regions) > maxRegions {
+		regions = regions[:maxRegions]
+	}
+	return regions
+}
+
+// describeLayoutNode names the element a layout box was generated for in
+// selector form
+func describeLayoutNode(d *dom.DOM, node *layout.LayoutNode) string {
+	if node.Text != "" {
+		return "#text"
+	}
+
+	domNode := d.GetNode(node.DomNode)
+	if domNode == nil || domNode.Type != dom.NodeTypeElement {
+		return "(anonymous)"
+	}
+
+	name := domNode.Tag
+	if id := domNode.Attr["id"]; id != "" {
+		name += "#" + id
+	}
+	for _, class := range strings.Fields(domNode.Attr["class"]) {
+		name += "." + class
+	}
+	return name
+}
+
+// dumpRegions formats a region breakdown one box per line
+func dumpRegions(regions []RegionDiff) string {
+	var lines []string
+	for _, r := range regions {
+		lines = append(lines, fmt.Sprintf("%5.1f%% %s (%.0f, %.0f, %.0f, %.0f)",
+			r.Percent, r.Element, r.Rect.X, r.Rect.Y, r.Rect.W, r.Rect.H))
+	}
+	return strings.Join(lines, "\n")
+}
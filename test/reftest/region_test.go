@@ -0,0 +1,65 @@
+package reftest
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/layout"
+)
+
+func TestRegionBreakdown(t *testing.T) {
+	document, err := dom.ParseString(`<html><body><div id="a" class="box"></div><div id="b"></div></body></html>`)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	tree := layout.BuildLayoutTree(document, nil)
+	layout.ComputeLayout(tree, 100, 100)
+
+	// Give the two divs known boxes: a at (0,0) 10x10, b at (0,10) 10x10
+	var divs []layout.LayoutNodeID
+	for i := range tree.Nodes {
+		if n := document.GetNode(tree.Nodes[i].DomNode); n != nil && n.Tag == "div" {
+			divs = append(divs, tree.Nodes[i].ID)
+		}
+	}
+	if len(divs) != 2 {
+		t.Fatalf("expected 2 div boxes, got %d", len(divs))
+	}
+	tree.Nodes[divs[0]].Rect = layout.Rect{X: 0, Y: 0, W: 10, H: 10}
+	tree.Nodes[divs[1]].Rect = layout.Rect{X: 0, Y: 10, W: 10, H: 10}
+
+	img1 := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	img2 := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	red := color.RGBA{255, 0, 0, 255}
+	// 30 differing pixels in a, 10 in b
+	for i := 0; i < 30; i++ {
+		img2.SetRGBA(i%10, i/10, red)
+	}
+	for i := 0; i < 10; i++ {
+		img2.SetRGBA(i, 15, red)
+	}
+
+	regions := regionBreakdown(document, tree, img1, img2)
+	if len(regions) != 2 {
+		t.Fatalf("expected 2 regions, got %d: %+v", len(regions), regions)
+	}
+	if regions[0].Element != "div#a.box" || regions[0].DiffPixels != 30 || regions[0].Percent != 75 {
+		t.Errorf("unexpected first region %+v", regions[0])
+	}
+	if regions[1].Element != "div#b" || regions[1].DiffPixels != 10 {
+		t.Errorf("unexpected second region %+v", regions[1])
+	}
+
+	t.Logf("Regions:\n%s", dumpRegions(regions))
+}
+
+func TestHeatColor(t *testing.T) {
+	if c := heatColor(0); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("expected black for no diff, got %v", c)
+	}
+	if c := heatColor(255); c != (color.RGBA{255, 255, 0, 255}) {
+		t.Errorf("expected yellow for maximal diff, got %v", c)
+	}
+}
@@ -0,0 +1,88 @@
+package reftest
+
+import (
+	"image"
+	"path/filepath"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/engine"
+	"github.com/myuon/penny/layout"
+	"github.com/myuon/penny/loader"
+	"github.com/myuon/penny/styleloader"
+)
+
+const (
+	viewportWidth  = 800
+	viewportHeight = 600
+)
+
+// pennyRender is penny's rendering of a page along with the trees it was
+// painted from
+type pennyRender struct {
+	Image  *image.RGBA
+	DOM    *dom.DOM
+	Layout *layout.LayoutTree
+}
+
+// capturePenny renders a local HTML file with penny. A panic during rendering
+// is returned as a *PanicError.
+func capturePenny(htmlFile string) (*image.RGBA, error) {
+	render, err := renderPenny(htmlFile)
+	if err != nil {
+		return nil, err
+	}
+	return render.Image, nil
+}
+
+// renderPenny renders a local HTML file with penny, keeping the DOM and
+// layout tree. A panic during rendering is returned as a *PanicError.
+func renderPenny(htmlFile string) (_ *pennyRender, err error) {
+	defer recoverPanic(&err)
+
+	page, err := engine.Open(htmlFile, nil)
+	if err != nil {
+		return nil, err
+	}
+	// A stylesheet that fails to load is left out, as a browser would
+	stylesheet, _ := page.Stylesheets()
+	rendering := engine.Render(page.DOM, stylesheet, engine.Options{Width: viewportWidth, Height: viewportHeight})
+	return &pennyRender{Image: rendering.Image, DOM: page.DOM, Layout: rendering.Layout}, nil
+}
+
+// capturePennyURL renders a remote page with penny, getting it and its
+// stylesheets with get. A panic during rendering is returned as a
+// *PanicError.
+func capturePennyURL(testURL string, get loader.Getter) (_ *image.RGBA, err error) {
+	defer recoverPanic(&err)
+
+	page, err := engine.Open(testURL, get)
+	if err != nil {
+		return nil, err
+	}
+	stylesheet, _ := page.Stylesheets()
+	return engine.Render(page.DOM, stylesheet, engine.Options{Width: viewportWidth, Height: viewportHeight}).Image, nil
+}
+
+// loadStylesheets collects the stylesheets of a parsed local page. One that
+// fails to load is left out, as a browser would.
+func loadStylesheets(d *dom.DOM, baseDir string) *css.Stylesheet {
+	stylesheet, _ := styleloader.Load(d, loader.Dir(baseDir), func(ref string) string {
+		return filepath.Join(baseDir, ref)
+	})
+	return stylesheet
+}
+
+// CollectCoverage records the declarations of the stylesheets a local HTML
+// file loads into cov. A panic while parsing is returned as a *PanicError.
+func CollectCoverage(cov *css.Coverage, htmlFile string) (err error) {
+	defer recoverPanic(&err)
+
+	page, err := engine.Open(htmlFile, nil)
+	if err != nil {
+		return err
+	}
+	stylesheet, _ := page.Stylesheets()
+	cov.AddStylesheet(stylesheet)
+	return nil
+}
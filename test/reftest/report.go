@@ -0,0 +1,141 @@
+package reftest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the suite result as JUnit XML
+func WriteJUnit(w io.Writer, result *WPTSuiteResult) error {
+	suite := junitTestSuite{
+		Name:     result.Suite,
+		Tests:    result.Total,
+		Failures: result.Failed,
+		Errors:   result.Errors,
+	}
+
+	for _, r := range result.Results {
+		tc := junitTestCase{
+			Name:      r.Name,
+			Classname: strings.ReplaceAll(result.Suite, "/", "."),
+			SystemOut: fmt.Sprintf("diff: %.2f%%", r.DiffPercent),
+		}
+
+		switch r.Status {
+		case "fail":
+			body := strings.Join(r.References, "\n")
+			if len(r.Regions) > 0 {
+				if body != "" {
+					body += "\n"
+				}
+				body += "Diff by element:\n" + dumpRegions(r.Regions)
+			}
+			tc.Failure = &junitMessage{
+				Message: failureMessage(r, result.Threshold),
+				Body:    body,
+			}
+		case "error":
+			tc.Error = &junitMessage{
+				Message: r.Error,
+				Body:    r.Stack,
+			}
+		}
+
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteGitHubAnnotations writes a GitHub Actions workflow command for every
+// failing or erroring test. Test names are resolved against wptRoot so that
+// annotations point at the test file in the repository.
+func WriteGitHubAnnotations(w io.Writer, result *WPTSuiteResult, wptRoot string) error {
+	for _, r := range result.Results {
+		var level, message string
+		switch r.Status {
+		case "fail":
+			level = "warning"
+			message = failureMessage(r, result.Threshold)
+		case "error":
+			level = "error"
+			message = r.Error
+		default:
+			continue
+		}
+
+		file := path.Join(wptRoot, r.Name)
+		_, err := fmt.Fprintf(w, "::%s file=%s,title=%s::%s\n",
+			level,
+			escapeAnnotationProperty(file),
+			escapeAnnotationProperty("reftest "+r.Status+": "+r.Name),
+			escapeAnnotationData(message))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func failureMessage(r WPTTestResult, threshold float64) string {
+	if len(r.References) > 0 {
+		return fmt.Sprintf("%.2f%% diff against %s", r.DiffPercent, strings.Join(r.References, ", "))
+	}
+	return fmt.Sprintf("%.2f%% diff exceeds threshold %.2f%%", r.DiffPercent, threshold)
+}
+
+// escapeAnnotationData escapes the message of a workflow command
+func escapeAnnotationData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	s = strings.ReplaceAll(s, "\n", "%0A")
+	return s
+}
+
+// escapeAnnotationProperty escapes a property value of a workflow command
+func escapeAnnotationProperty(s string) string {
+	s = escapeAnnotationData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	s = strings.ReplaceAll(s, ",", "%2C")
+	return s
+}
@@ -0,0 +1,59 @@
+package reftest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var reportFixture = &WPTSuiteResult{
+	Suite:     "css/css-flexbox",
+	Total:     3,
+	Passed:    1,
+	Failed:    1,
+	Errors:    1,
+	Threshold: 10,
+	Results: []WPTTestResult{
+		{Name: "css/css-flexbox/a.html", Status: "pass", DiffPercent: 1.5},
+		{Name: "css/css-flexbox/b.html", Status: "fail", DiffPercent: 42},
+		{Name: "css/css-flexbox/c.html", Status: "error", Error: "penny render failed: panic: boom", Stack: "goroutine 1\nlayout.go:10"},
+	},
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, reportFixture); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+	out := buf.String()
+	t.Log(out)
+
+	for _, want := range []string{
+		`<testsuite name="css/css-flexbox" tests="3" failures="1" errors="1">`,
+		`<testcase name="css/css-flexbox/a.html" classname="css.css-flexbox">`,
+		`<failure message="42.00% diff exceeds threshold 10.00%">`,
+		`<error message="penny render failed: panic: boom">goroutine 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q", want)
+		}
+	}
+}
+
+func TestWriteGitHubAnnotations(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGitHubAnnotations(&buf, reportFixture, "test/wpt"); err != nil {
+		t.Fatalf("WriteGitHubAnnotations failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 annotations, got %d: %q", len(lines), lines)
+	}
+	if want := "::warning file=test/wpt/css/css-flexbox/b.html,title=reftest fail%3A css/css-flexbox/b.html::42.00%25 diff exceeds threshold 10.00%25"; lines[0] != want {
+		t.Errorf("unexpected annotation:\n got: %s\nwant: %s", lines[0], want)
+	}
+	if !strings.HasPrefix(lines[1], "::error file=test/wpt/css/css-flexbox/c.html,") {
+		t.Errorf("unexpected annotation: %s", lines[1])
+	}
+}
@@ -0,0 +1,41 @@
+package reftest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestServeResources(t *testing.T) {
+	server, err := startTestServer("testdata")
+	if err != nil {
+		t.Fatalf("failed to start test server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	cases := []struct {
+		path        string
+		contentType string
+	}{
+		{"resources/checker.png", "image/png"},
+		{"resources/GoRegular.ttf", "font/ttf"},
+	}
+
+	for _, c := range cases {
+		resp, err := http.Get(fmt.Sprintf("http://%s/%s", server.Addr, c.path))
+		if err != nil {
+			t.Fatalf("GET %s: %v", c.path, err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status %d", c.path, resp.StatusCode)
+		}
+		if got := resp.Header.Get("Content-Type"); got != c.contentType {
+			t.Errorf("GET %s: expected content type %s, got %s", c.path, c.contentType, got)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("GET %s: expected CORS header, got %q", c.path, got)
+		}
+	}
+}
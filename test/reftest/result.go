@@ -0,0 +1,47 @@
+package reftest
+
+// WPTTestResult holds the result of a single WPT test
+type WPTTestResult struct {
+	Name        string             `json:"name"`
+	URL         string             `json:"url"`
+	DiffPercent float64            `json:"diff_percent"`
+	Status      string             `json:"status"` // "pass", "fail", "error", "skip"
+	Error       string             `json:"error,omitempty"`
+	Stack       string             `json:"stack,omitempty"` // stack trace if penny panicked
+	References  []string           `json:"references,omitempty"`
+	EngineDiffs map[string]float64 `json:"engine_diffs,omitempty"` // per-engine diff when comparing against several browsers
+	Regions     []RegionDiff       `json:"regions,omitempty"`      // boxes that contributed the most diff pixels, for failing tests
+}
+
+// WPTSuiteResult holds the results of a WPT test suite
+type WPTSuiteResult struct {
+	Suite     string          `json:"suite"`
+	Total     int             `json:"total"`
+	Passed    int             `json:"passed"`
+	Failed    int             `json:"failed"`
+	Errors    int             `json:"errors"`
+	Results   []WPTTestResult `json:"results"`
+	Threshold float64         `json:"threshold"`
+	Revision  string          `json:"revision,omitempty"`
+	Seed      int64           `json:"seed"`
+	Shard     string          `json:"shard,omitempty"` // "i/n" if the run was sharded
+}
+
+// add records a test result. Skipped tests are not counted.
+func (s *WPTSuiteResult) add(result WPTTestResult) {
+	if result.Status == "skip" {
+		return
+	}
+
+	s.Results = append(s.Results, result)
+	s.Total++
+
+	switch result.Status {
+	case "pass":
+		s.Passed++
+	case "fail":
+		s.Failed++
+	case "error":
+		s.Errors++
+	}
+}
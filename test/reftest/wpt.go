@@ -0,0 +1,263 @@
+package reftest
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/myuon/penny/dom"
+)
+
+// wptReference is a <link rel="match"> or <link rel="mismatch"> reference
+// declared by a WPT reftest
+type wptReference struct {
+	Rel  string // "match" or "mismatch"
+	Href string
+}
+
+// FindWPTTestFiles returns the test files of a WPT suite such as
+// "css/css-flexbox", excluding references and support files
+func FindWPTTestFiles(wptRoot, suite string) ([]string, error) {
+	suiteDir := filepath.Join(wptRoot, suite)
+
+	// Check if WPT is available
+	if _, err := os.Stat(suiteDir); err != nil {
+		return nil, fmt.Errorf("WPT suite not found: %s (run 'git submodule update --init'): %w", suiteDir, err)
+	}
+
+	// Find all HTML test files
+	var testFiles []string
+	err := filepath.Walk(suiteDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && (strings.HasSuffix(path, ".html") || strings.HasSuffix(path, ".htm")) {
+			// Skip reference files (used for WPT reftests)
+			if strings.Contains(path, "-ref.") || strings.Contains(path, "-ref-") {
+				return nil
+			}
+			// Skip support and shared reference files
+			if strings.Contains(path, "/support/") || strings.Contains(path, "/reference/") {
+				return nil
+			}
+			testFiles = append(testFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk suite directory: %w", err)
+	}
+
+	return testFiles, nil
+}
+
+// browserEngine is a running browser that test pages are captured with
+type browserEngine struct {
+	Name string
+	Pool *pagePool
+}
+
+// runWPTTest compares penny's rendering of a test against each browser
+// engine. The first engine decides the status; when there are several, every
+// engine's diff is reported in EngineDiffs.
+func runWPTTest(engines []browserEngine, serverAddr, wptRoot, testFile, outputDir string, threshold float64) WPTTestResult {
+	relPath, _ := filepath.Rel(wptRoot, testFile)
+	testURL := fmt.Sprintf("http://%s/%s", serverAddr, filepath.ToSlash(relPath))
+
+	result := WPTTestResult{
+		Name: relPath,
+		URL:  testURL,
+	}
+
+	// Get Penny rendering
+	render, err := renderPenny(testFile)
+	if err != nil {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("penny render failed: %v", err)
+		result.Stack = panicStack(err)
+		return result
+	}
+
+	pennyImg := render.Image
+	testName := strings.ReplaceAll(relPath, "/", "_")
+
+	for i, engine := range engines {
+		// Get browser screenshot
+		browserImg, err := captureBrowserURL(engine.Pool, testURL)
+		if err != nil {
+			msg := fmt.Sprintf("%s capture failed: %v", engine.Name, err)
+			if i == 0 {
+				result.Status = "error"
+				result.Error = msg
+				return result
+			}
+			// A secondary engine failing doesn't change the outcome
+			if result.Error != "" {
+				result.Error += "; "
+			}
+			result.Error += msg
+			continue
+		}
+
+		// Compare images
+		diffImg, diffPercent := compareImages(browserImg, pennyImg)
+		if len(engines) > 1 {
+			if result.EngineDiffs == nil {
+				result.EngineDiffs = make(map[string]float64)
+			}
+			result.EngineDiffs[engine.Name] = diffPercent
+		}
+
+		outputPath := filepath.Join(outputDir, testName+"_diff.png")
+		if i == 0 {
+			result.DiffPercent = diffPercent
+
+			// Determine pass/fail
+			if diffPercent <= threshold || fuzzyAllows(testFile, browserImg, pennyImg) {
+				result.Status = "pass"
+			} else {
+				result.Status = "fail"
+				result.Regions = regionBreakdown(render.DOM, render.Layout, browserImg, pennyImg)
+			}
+		} else {
+			outputPath = filepath.Join(outputDir, testName+"_"+engine.Name+"_diff.png")
+		}
+
+		// Save diff image for all tests
+		combinedImg := createCombinedImage(browserImg, pennyImg, diffImg)
+		savePNG(combinedImg, outputPath)
+	}
+
+	return result
+}
+
+// runWPTReftest renders a test and its rel=match/mismatch references with
+// penny and checks that they agree, the way WPT reftests are meant to be run
+func runWPTReftest(wptRoot, testFile, outputDir string) WPTTestResult {
+	relPath, _ := filepath.Rel(wptRoot, testFile)
+	result := WPTTestResult{
+		Name: relPath,
+	}
+
+	htmlContent, err := os.ReadFile(testFile)
+	if err != nil {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("failed to read test: %v", err)
+		return result
+	}
+
+	document, err := dom.ParseString(string(htmlContent))
+	if err != nil {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("failed to parse test: %v", err)
+		return result
+	}
+
+	refs := findWPTReferences(document)
+	fuzzies := findWPTFuzzy(document)
+	if len(refs) == 0 {
+		result.Status = "skip"
+		result.Error = "not a reftest"
+		return result
+	}
+
+	render, err := renderPenny(testFile)
+	if err != nil {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("penny render failed: %v", err)
+		result.Stack = panicStack(err)
+		return result
+	}
+
+	testImg := render.Image
+
+	// Every reference must hold for the test to pass
+	result.Status = "pass"
+	for _, ref := range refs {
+		refFile := resolveWPTReference(wptRoot, testFile, ref.Href)
+		result.References = append(result.References, ref.Rel+":"+ref.Href)
+
+		refImg, err := capturePenny(refFile)
+		if err != nil {
+			result.Status = "error"
+			result.Error = fmt.Sprintf("penny render of %s failed: %v", ref.Href, err)
+			result.Stack = panicStack(err)
+			return result
+		}
+
+		diffImg, diffPercent := compareImages(testImg, refImg)
+		if diffPercent > result.DiffPercent {
+			result.DiffPercent = diffPercent
+		}
+
+		maxDiff, diffPixels := pixelStats(testImg, refImg)
+		matched := diffPixels == 0
+		if fuzzy, ok := fuzzyFor(fuzzies, ref.Href); ok {
+			matched = fuzzy.allows(maxDiff, diffPixels)
+		}
+		if matched != (ref.Rel == "match") {
+			result.Status = "fail"
+			if ref.Rel == "match" && result.Regions == nil {
+				result.Regions = regionBreakdown(render.DOM, render.Layout, refImg, testImg)
+			}
+
+			combinedImg := createCombinedImage(refImg, testImg, diffImg)
+			testName := strings.ReplaceAll(relPath, "/", "_")
+			savePNG(combinedImg, filepath.Join(outputDir, testName+"_diff.png"))
+		}
+	}
+
+	return result
+}
+
+// findWPTReferences returns the match/mismatch references declared in the
+// document, in document order
+func findWPTReferences(d *dom.DOM) []wptReference {
+	var refs []wptReference
+
+	dom.Walk(d, d.Root, func(node *dom.Node, depth int) dom.WalkAction {
+		if node.Type == dom.NodeTypeElement && node.Tag == "link" {
+			rel := strings.ToLower(node.Attr["rel"])
+			href, hasHref := node.Attr["href"]
+			if (rel == "match" || rel == "mismatch") && hasHref {
+				refs = append(refs, wptReference{Rel: rel, Href: href})
+			}
+		}
+
+		return dom.WalkContinue
+	})
+	return refs
+}
+
+// resolveWPTReference resolves a reference href against the test file.
+// Absolute hrefs are rooted at the WPT checkout.
+func resolveWPTReference(wptRoot, testFile, href string) string {
+	if strings.HasPrefix(href, "/") {
+		return filepath.Join(wptRoot, href)
+	}
+	return filepath.Join(filepath.Dir(testFile), href)
+}
+
+// fuzzyAllows reports whether the test file carries a fuzzy annotation that
+// tolerates the difference between the two images
+func fuzzyAllows(testFile string, img1, img2 *image.RGBA) bool {
+	htmlContent, err := os.ReadFile(testFile)
+	if err != nil {
+		return false
+	}
+
+	document, err := dom.ParseString(string(htmlContent))
+	if err != nil {
+		return false
+	}
+
+	fuzzy, ok := fuzzyFor(findWPTFuzzy(document), "")
+	if !ok {
+		return false
+	}
+
+	maxDiff, diffPixels := pixelStats(img1, img2)
+	return fuzzy.allows(maxDiff, diffPixels)
+}
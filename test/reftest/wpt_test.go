@@ -1,204 +1,85 @@
 package reftest
 
 import (
-	"encoding/json"
-	"fmt"
-	"image"
-	"math/rand"
+	"errors"
 	"os"
-	"path/filepath"
-	"strings"
+	"strconv"
 	"testing"
-
-	"github.com/playwright-community/playwright-go"
 )
 
 const wptRoot = "../wpt"
 
-// WPTTestResult holds the result of a single WPT test
-type WPTTestResult struct {
-	Name        string  `json:"name"`
-	URL         string  `json:"url"`
-	DiffPercent float64 `json:"diff_percent"`
-	Status      string  `json:"status"` // "pass", "fail", "error"
-	Error       string  `json:"error,omitempty"`
-}
-
-// WPTSuiteResult holds the results of a WPT test suite
-type WPTSuiteResult struct {
-	Suite      string          `json:"suite"`
-	Total      int             `json:"total"`
-	Passed     int             `json:"passed"`
-	Failed     int             `json:"failed"`
-	Errors     int             `json:"errors"`
-	Results    []WPTTestResult `json:"results"`
-	Threshold  float64         `json:"threshold"`
-}
-
 // TestWPTFlexbox runs WPT css-flexbox tests
 func TestWPTFlexbox(t *testing.T) {
-	runWPTSuite(t, "css/css-flexbox", 10.0) // 10% threshold
+	runWPTSuite(t, Options{
+		Suite:     "css/css-flexbox",
+		Engine:    EngineChrome,
+		Threshold: 10.0, // 10% threshold
+		MaxTests:  50,   // full suite takes too long
+	})
 }
 
-// runWPTSuite runs all HTML tests in a WPT suite directory
-func runWPTSuite(t *testing.T, suite string, threshold float64) {
-	suiteDir := filepath.Join(wptRoot, suite)
+// runWPTSuite runs a WPT suite and reports each test as a subtest
+func runWPTSuite(t *testing.T, opts Options) {
+	opts.WPTRoot = wptRoot
 
-	// Check if WPT is available
-	if _, err := os.Stat(suiteDir); os.IsNotExist(err) {
-		t.Skipf("WPT suite not found: %s (run 'git submodule update --init')", suiteDir)
-	}
-
-	// Find all HTML test files
-	var testFiles []string
-	err := filepath.Walk(suiteDir, func(path string, info os.FileInfo, err error) error {
+	// Make sampling reproducible and let CI split the suite across jobs
+	if v := os.Getenv("PENNY_REFTEST_SEED"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			return err
-		}
-		if !info.IsDir() && (strings.HasSuffix(path, ".html") || strings.HasSuffix(path, ".htm")) {
-			// Skip reference files (used for WPT reftests)
-			if strings.Contains(path, "-ref.") || strings.Contains(path, "-ref-") {
-				return nil
-			}
-			// Skip support files
-			if strings.Contains(path, "/support/") {
-				return nil
-			}
-			testFiles = append(testFiles, path)
+			t.Fatalf("invalid PENNY_REFTEST_SEED: %v", err)
 		}
-		return nil
-	})
-	if err != nil {
-		t.Fatalf("failed to walk suite directory: %v", err)
+		opts.Seed = seed
 	}
-
-	if len(testFiles) == 0 {
-		t.Skip("no test files found")
-	}
-
-	t.Logf("Found %d test files in %s", len(testFiles), suite)
-
-	// Randomly select tests (full suite takes too long)
-	maxTests := 50
-	if len(testFiles) > maxTests {
-		t.Logf("Randomly selecting %d tests from %d", maxTests, len(testFiles))
-		rand.Shuffle(len(testFiles), func(i, j int) {
-			testFiles[i], testFiles[j] = testFiles[j], testFiles[i]
-		})
-		testFiles = testFiles[:maxTests]
+	if v := os.Getenv("PENNY_REFTEST_SHARD"); v != "" {
+		index, count, err := ParseShard(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.ShardIndex, opts.ShardCount = index, count
+		opts.MaxTests = 0 // a shard runs all of its tests
 	}
 
-	// Start HTTP server for WPT files
-	server := startTestServer(wptRoot)
-	defer server.Close()
-
-	// Initialize Playwright
-	pw, err := playwright.Run()
-	if err != nil {
-		t.Fatalf("could not start playwright: %v", err)
+	suiteResult, err := RunSuite(opts)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip(err)
 	}
-	defer pw.Stop()
-
-	browser, err := pw.Chromium.Launch()
 	if err != nil {
-		t.Fatalf("could not launch browser: %v", err)
-	}
-	defer browser.Close()
-
-	// Create output directory
-	outputDir := filepath.Join("output", "wpt", suite)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		t.Fatalf("failed to create output dir: %v", err)
+		t.Fatalf("suite run failed: %v", err)
 	}
 
-	// Run tests
-	suiteResult := &WPTSuiteResult{
-		Suite:     suite,
-		Threshold: threshold,
-	}
-
-	for _, testFile := range testFiles {
-		relPath, _ := filepath.Rel(wptRoot, testFile)
-		testName := strings.ReplaceAll(relPath, "/", "_")
-		testName = strings.TrimSuffix(testName, ".html")
-		testName = strings.TrimSuffix(testName, ".htm")
-
-		t.Run(testName, func(t *testing.T) {
-			result := runWPTTest(t, browser, server.Addr, testFile, relPath, outputDir, threshold)
-			suiteResult.Results = append(suiteResult.Results, result)
-			suiteResult.Total++
-
+	for _, result := range suiteResult.Results {
+		t.Run(result.Name, func(t *testing.T) {
 			switch result.Status {
 			case "pass":
-				suiteResult.Passed++
+				t.Logf("PASS: %.2f%% diff %v", result.DiffPercent, result.References)
 			case "fail":
-				suiteResult.Failed++
+				t.Logf("FAIL: %.2f%% diff %v", result.DiffPercent, result.References)
 			case "error":
-				suiteResult.Errors++
+				t.Logf("ERROR: %s", result.Error)
+				if result.Stack != "" {
+					t.Logf("%s", result.Stack)
+				}
 			}
 		})
 	}
 
-	// Save summary
-	summaryPath := filepath.Join(outputDir, "summary.json")
-	if data, err := json.MarshalIndent(suiteResult, "", "  "); err == nil {
-		os.WriteFile(summaryPath, data, 0644)
+	// Compare against a previous run if asked
+	if baseRev := os.Getenv("PENNY_REFTEST_COMPARE"); baseRev != "" {
+		if base, err := LoadHistory(opts.HistoryDir(), baseRev); err == nil {
+			t.Logf("\n%s", CompareResults(base, suiteResult).Dump())
+		} else {
+			t.Logf("failed to load history for %s: %v", baseRev, err)
+		}
+	}
+
+	if suiteResult.Total == 0 {
+		t.Logf("WPT Suite %s: no tests run", suiteResult.Suite)
+		return
 	}
 
 	t.Logf("WPT Suite %s: %d/%d passed (%.1f%%), %d errors",
-		suite, suiteResult.Passed, suiteResult.Total,
+		suiteResult.Suite, suiteResult.Passed, suiteResult.Total,
 		float64(suiteResult.Passed)/float64(suiteResult.Total)*100,
 		suiteResult.Errors)
 }
-
-func runWPTTest(t *testing.T, browser playwright.Browser, serverAddr, testFile, relPath, outputDir string, threshold float64) WPTTestResult {
-	testURL := fmt.Sprintf("http://%s/%s", serverAddr, relPath)
-
-	result := WPTTestResult{
-		Name: relPath,
-		URL:  testURL,
-	}
-
-	// Get Chrome screenshot
-	chromeImg, err := captureChromeURL(browser, testURL)
-	if err != nil {
-		result.Status = "error"
-		result.Error = fmt.Sprintf("chrome capture failed: %v", err)
-		t.Logf("ERROR: %s", result.Error)
-		return result
-	}
-
-	// Get Penny rendering
-	pennyImg, err := capturePennyFile(testFile)
-	if err != nil {
-		result.Status = "error"
-		result.Error = fmt.Sprintf("penny render failed: %v", err)
-		t.Logf("ERROR: %s", result.Error)
-		return result
-	}
-
-	// Compare images
-	diffImg, diffPercent := compareImages(chromeImg, pennyImg)
-	result.DiffPercent = diffPercent
-
-	// Determine pass/fail
-	if diffPercent <= threshold {
-		result.Status = "pass"
-		t.Logf("PASS: %.2f%% diff", diffPercent)
-	} else {
-		result.Status = "fail"
-		t.Logf("FAIL: %.2f%% diff (threshold: %.2f%%)", diffPercent, threshold)
-	}
-
-	// Save diff image for all tests
-	combinedImg := createCombinedImage(chromeImg, pennyImg, diffImg)
-	testName := strings.ReplaceAll(relPath, "/", "_")
-	outputPath := filepath.Join(outputDir, testName+"_diff.png")
-	savePNG(combinedImg, outputPath)
-
-	return result
-}
-
-func capturePennyFile(htmlFile string) (*image.RGBA, error) {
-	return capturePenny(htmlFile)
-}
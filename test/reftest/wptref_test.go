@@ -0,0 +1,52 @@
+package reftest
+
+import (
+	"testing"
+
+	"github.com/myuon/penny/dom"
+)
+
+// TestWPTFlexboxReftest runs WPT css-flexbox reftests natively, rendering both
+// the test and its references with penny
+func TestWPTFlexboxReftest(t *testing.T) {
+	runWPTSuite(t, Options{
+		Suite:  "css/css-flexbox",
+		Engine: EngineBaseline,
+	})
+}
+
+func TestFindWPTReferences(t *testing.T) {
+	input := `<!DOCTYPE html>
+<html>
+<head>
+  <link rel="help" href="https://drafts.csswg.org/css-flexbox/">
+  <link rel="match" href="reference/green-ref.html">
+  <link rel="mismatch" href="/css/reference/blank.html">
+  <link rel="stylesheet" href="style.css">
+</head>
+<body><div></div></body>
+</html>`
+
+	document, err := dom.ParseString(input)
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+
+	refs := findWPTReferences(document)
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 references, got %d: %v", len(refs), refs)
+	}
+	if refs[0].Rel != "match" || refs[0].Href != "reference/green-ref.html" {
+		t.Errorf("unexpected first reference: %v", refs[0])
+	}
+	if refs[1].Rel != "mismatch" || refs[1].Href != "/css/reference/blank.html" {
+		t.Errorf("unexpected second reference: %v", refs[1])
+	}
+
+	if got := resolveWPTReference(wptRoot, "../wpt/css/css-flexbox/a.html", refs[0].Href); got != "../wpt/css/css-flexbox/reference/green-ref.html" {
+		t.Errorf("unexpected relative resolution: %s", got)
+	}
+	if got := resolveWPTReference(wptRoot, "../wpt/css/css-flexbox/a.html", refs[1].Href); got != "../wpt/css/reference/blank.html" {
+		t.Errorf("unexpected absolute resolution: %s", got)
+	}
+}
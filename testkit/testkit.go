@@ -0,0 +1,177 @@
+// Package testkit lets layout tests be written against penny without
+// comparing pixels: a document is rendered, and its elements, found by
+// selector, are asserted to have a rect or a computed style.
+//
+//	page := testkit.Render(t, `<div id="box" style="width: 100px">x</div>`, 800, 600)
+//	page.Element("#box").HasRect(layout.Rect{X: 8, Y: 8, W: 100, H: 18}, 0.5)
+package testkit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/myuon/penny/css"
+	"github.com/myuon/penny/dom"
+	"github.com/myuon/penny/engine"
+	"github.com/myuon/penny/layout"
+)
+
+// Page is a document rendered for assertions
+type Page struct {
+	t testing.TB
+	*engine.RenderResult
+	DOM *dom.DOM
+}
+
+// blank is the URL documents are rendered at
+var blank = &url.URL{Scheme: "about", Opaque: "blank"}
+
+// Render renders an HTML document in a viewport of w x h, with the user
+// agent stylesheet and the document's own. The document can't load
+// anything: its linked stylesheets and images are left out. It fails t if
+// the document can't be parsed.
+func Render(t testing.TB, html string, w, h int) *Page {
+	t.Helper()
+	page, err := engine.Parse([]byte(html), blank, func(ref string) ([]byte, error) {
+		return nil, errors.New("testkit: nothing can be loaded")
+	})
+	if err != nil {
+		t.Fatalf("testkit: %v", err)
+	}
+	defaults, err := engine.WithDefaults(nil, nil)
+	if err != nil {
+		t.Fatalf("testkit: %v", err)
+	}
+	media := css.DefaultMediaContext()
+	media.Width, media.Height = float32(w), float32(h)
+	result := engine.RenderPage(page, defaults, media, engine.Options{Width: w, Height: h})
+	return &Page{t: t, RenderResult: result, DOM: page.DOM}
+}
+
+// Element returns the element a selector matches, failing t unless it
+// matches exactly one that is rendered
+func (p *Page) Element(selector string) *Element {
+	p.t.Helper()
+	elements := p.Elements(selector)
+	if len(elements) != 1 {
+		p.t.Fatalf("testkit: %q matches %d rendered elements, want 1", selector, len(elements))
+	}
+	return elements[0]
+}
+
+// Elements returns the rendered elements a selector matches, in document
+// order. An element that isn't rendered, such as one that is display:none,
+// is left out.
+func (p *Page) Elements(selector string) []*Element {
+	p.t.Helper()
+	sheet, err := css.Parse(selector + " {}")
+	if err != nil || len(sheet.Rules) != 1 {
+		p.t.Fatalf("testkit: invalid selector %q", selector)
+	}
+	boxes := make(map[dom.NodeID]*layout.LayoutNode)
+	for i := range p.Layout.Nodes {
+		node := &p.Layout.Nodes[i]
+		if node.Text == "" {
+			boxes[node.DomNode] = node
+		}
+	}
+	var elements []*Element
+	for _, id := range layout.QuerySelectorAll(p.DOM, sheet.Rules[0].Selectors) {
+		if box, ok := boxes[id]; ok {
+			elements = append(elements, &Element{t: p.t, Selector: selector, Node: p.DOM.GetNode(id), Box: box})
+		}
+	}
+	return elements
+}
+
+// Element is a rendered element, whose assertions report to the test of
+// its page
+type Element struct {
+	t        testing.TB
+	Selector string // what the element was found by
+	Node     *dom.Node
+	Box      *layout.LayoutNode
+}
+
+// Rect returns the element's box as laid out
+func (e *Element) Rect() layout.Rect {
+	return e.Box.Rect
+}
+
+// Style returns the element's computed style
+func (e *Element) Style() css.Style {
+	return e.Box.Style
+}
+
+// HasRect asserts that the element's box is want, each of its edges within
+// tolerance pixels
+func (e *Element) HasRect(want layout.Rect, tolerance float32) *Element {
+	e.t.Helper()
+	got := e.Box.Rect
+	if !near(got.X, want.X, tolerance) || !near(got.Y, want.Y, tolerance) ||
+		!near(got.W, want.W, tolerance) || !near(got.H, want.H, tolerance) {
+		e.t.Errorf("testkit: %s: rect is %v, want %v (±%v)", e.Selector, got, want, tolerance)
+	}
+	return e
+}
+
+// HasSize asserts that the element's box is w x h, within tolerance pixels
+func (e *Element) HasSize(w, h, tolerance float32) *Element {
+	e.t.Helper()
+	got := e.Box.Rect
+	if !near(got.W, w, tolerance) || !near(got.H, h, tolerance) {
+		e.t.Errorf("testkit: %s: size is %vx%v, want %vx%v (±%v)", e.Selector, got.W, got.H, w, h, tolerance)
+	}
+	return e
+}
+
+// HasStyle asserts that the computed value of a longhand property is value,
+// as it would be written in CSS. Lengths are compared within tolerance
+// pixels; relative lengths, such as em, can't be compared, as the computed
+// value is in pixels.
+func (e *Element) HasStyle(property, value string, tolerance float32) *Element {
+	e.t.Helper()
+	decls := css.ParseDeclarations(property + ": " + value)
+	if len(decls) != 1 {
+		e.t.Errorf("testkit: %s: invalid declaration %s: %s", e.Selector, property, value)
+		return e
+	}
+	id, want, err := css.ParseValue(decls[0])
+	if err != nil {
+		e.t.Errorf("testkit: %s: %v", e.Selector, err)
+		return e
+	}
+	if want.Unit != css.UnitPx {
+		e.t.Errorf("testkit: %s: %s: %q is a relative length, want one in px", e.Selector, property, value)
+		return e
+	}
+	style := e.Box.Style
+	got := style.Get(id)
+	if !near(got.Length, want.Length, tolerance) || !sameValue(got, want) {
+		e.t.Errorf("testkit: %s: %s is %s, want %s", e.Selector, property, formatValue(got), value)
+	}
+	return e
+}
+
+// sameValue reports whether two values are equal but for their lengths
+func sameValue(a, b css.Value) bool {
+	a.Length, b.Length = 0, 0
+	return reflect.DeepEqual(a, b)
+}
+
+// formatValue describes a computed value in a failure: its length if it
+// has one, or else its fields
+func formatValue(v css.Value) string {
+	if sameValue(v, css.Value{}) {
+		return fmt.Sprintf("%vpx", v.Length)
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
+func near(a, b, tolerance float32) bool {
+	return math.Abs(float64(a-b)) <= float64(tolerance)
+}
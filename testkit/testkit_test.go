@@ -0,0 +1,56 @@
+package testkit
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/layout"
+)
+
+// recorder is a test whose failures are recorded instead of reported
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestElement(t *testing.T) {
+	page := Render(t, `<html><head><style>
+		body { margin: 0; }
+		#box { width: 100px; height: 20px; margin-left: 10px; color: red; }
+		</style></head><body><div id="box">x</div><p class="item">a</p><p class="item">b</p></body></html>`, 400, 300)
+
+	page.Element("#box").
+		HasRect(layout.Rect{X: 10, Y: 0, W: 90, H: 20}, 0).
+		HasSize(90.4, 19.6, 0.5).
+		HasStyle("color", "red", 0).
+		HasStyle("width", "100px", 0).
+		HasStyle("margin-left", "10.2px", 0.5)
+	if got := len(page.Elements(".item")); got != 2 {
+		t.Errorf("expected 2 items, got %d", got)
+	}
+
+	// Assertions that don't hold fail the test with what was found
+	r := &recorder{}
+	box := &Element{t: r, Selector: "#box", Box: page.Element("#box").Box}
+	box.HasRect(layout.Rect{X: 0, Y: 0, W: 90, H: 20}, 1).
+		HasSize(90, 40, 1).
+		HasStyle("color", "blue", 0).
+		HasStyle("width", "50px", 1).
+		HasStyle("width", "10em", 0).
+		HasStyle("no-such-property", "1px", 0)
+	if len(r.failures) != 6 {
+		t.Fatalf("expected 6 failures, got %q", r.failures)
+	}
+	for i, want := range []string{"rect is", "size is 90x20", "color is", "width is 100px, want 50px", "relative length", "unsupported property"} {
+		if !strings.Contains(r.failures[i], want) {
+			t.Errorf("expected failure %d to mention %q, got %q", i, want, r.failures[i])
+		}
+	}
+}
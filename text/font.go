@@ -0,0 +1,251 @@
+package text
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomedium"
+	"golang.org/x/image/font/gofont/gomediumitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+
+	"github.com/myuon/penny/css"
+)
+
+// FontID identifies a font of the registry. IDs are never reused, so one
+// can be kept, as paint ops do, for as long as the process runs.
+type FontID uint16
+
+// The regular fonts of the families penny ships with. There is no serif
+// among them, so serif text is set in the sans-serif family.
+const (
+	SansSerif FontID = iota
+	Monospace
+)
+
+// Variant is the weight and slant of a font of a family
+type Variant struct {
+	Weight uint16
+	Italic bool
+}
+
+// Synthesis is what is faked when text asks for a variant its family has no
+// font for
+type Synthesis uint8
+
+const (
+	SynthBold Synthesis = 1 << iota
+	SynthOblique
+)
+
+// Font is what a run of text is set in: a font of the registry at a size in
+// pixels, and what to fake about it
+type Font struct {
+	ID        FontID
+	Synthesis Synthesis
+	Size      float32
+}
+
+// FontOf returns the font the text of an element in style is set in
+func FontOf(style *css.Style) Font {
+	id, synthesis := Resolve(style.FontFamily, Variant{
+		Weight: style.FontWeight,
+		Italic: style.FontStyle != css.FontStyleNormal,
+	})
+	return Font{ID: id, Synthesis: synthesis, Size: style.FontSize}
+}
+
+type familyFont struct {
+	Variant
+	id FontID
+}
+
+type resolveKey struct {
+	list string
+	want Variant
+}
+
+type resolved struct {
+	id        FontID
+	synthesis Synthesis
+}
+
+// registry maps family names to the fonts loaded for them. Families are
+// matched without regard to case.
+var registry = struct {
+	sync.RWMutex
+	fonts    []*opentype.Font        // by FontID
+	families map[string][]familyFont // by lowercased name
+	resolved map[resolveKey]resolved
+}{
+	families: map[string][]familyFont{},
+	resolved: map[resolveKey]resolved{},
+}
+
+// generics maps the generic families to the families that stand in for them
+var generics = map[string]string{
+	"serif":         "go",
+	"sans-serif":    "go",
+	"system-ui":     "go",
+	"ui-serif":      "go",
+	"ui-sans-serif": "go",
+	"ui-rounded":    "go",
+	"monospace":     "go mono",
+	"ui-monospace":  "go mono",
+}
+
+func init() {
+	// SansSerif and Monospace come first
+	for _, f := range []struct {
+		family string
+		v      Variant
+		data   []byte
+	}{
+		{"Go", Variant{Weight: 400}, goregular.TTF},
+		{"Go Mono", Variant{Weight: 400}, gomono.TTF},
+		{"Go", Variant{Weight: 400, Italic: true}, goitalic.TTF},
+		{"Go", Variant{Weight: 500}, gomedium.TTF},
+		{"Go", Variant{Weight: 500, Italic: true}, gomediumitalic.TTF},
+		{"Go", Variant{Weight: 700}, gobold.TTF},
+		{"Go", Variant{Weight: 700, Italic: true}, gobolditalic.TTF},
+		{"Go Mono", Variant{Weight: 400, Italic: true}, gomonoitalic.TTF},
+		{"Go Mono", Variant{Weight: 700}, gomonobold.TTF},
+		{"Go Mono", Variant{Weight: 700, Italic: true}, gomonobolditalic.TTF},
+	} {
+		if _, err := Register(f.family, f.v, f.data); err != nil {
+			panic("text: parsing a built-in font: " + err.Error())
+		}
+	}
+}
+
+// Register loads a TrueType or OpenType font as the variant v of a family,
+// in place of any font registered for that variant before
+func Register(family string, v Variant, data []byte) (FontID, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return 0, err
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	id := FontID(len(registry.fonts))
+	registry.fonts = append(registry.fonts, f)
+
+	name := strings.ToLower(family)
+	fonts := registry.families[name]
+	for i := range fonts {
+		if fonts[i].Variant == v {
+			fonts = append(fonts[:i], fonts[i+1:]...)
+			break
+		}
+	}
+	registry.families[name] = append(fonts, familyFont{v, id})
+	clear(registry.resolved)
+	return id, nil
+}
+
+// Resolve returns the font text in a font-family list, in the canonical
+// form of css.Style.FontFamily, is set in when it asks for the variant want.
+// The first family of the list that is registered or generic is used, the
+// sans-serif family when none is. Within it the font closest to want is
+// chosen, with bold and oblique synthesized when it is lighter or upright.
+func Resolve(list string, want Variant) (FontID, Synthesis) {
+	key := resolveKey{list, want}
+	registry.RLock()
+	r, ok := registry.resolved[key]
+	registry.RUnlock()
+	if ok {
+		return r.id, r.synthesis
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	r.id, r.synthesis = match(family(list), want)
+	registry.resolved[key] = r
+	return r.id, r.synthesis
+}
+
+// family returns the fonts of the family a list resolves to. registry must
+// be locked.
+func family(list string) []familyFont {
+	for _, family := range css.FontFamilies(list) {
+		name := strings.ToLower(family.Name)
+		if family.Generic {
+			if name = generics[name]; name == "" {
+				continue
+			}
+		}
+		if fonts := registry.families[name]; len(fonts) > 0 {
+			return fonts
+		}
+	}
+	return registry.families["go"]
+}
+
+// match picks the font of a family for a variant: one of the same slant
+// if there is any, then the nearest weight as the CSS font matching
+// algorithm defines it
+func match(fonts []familyFont, want Variant) (FontID, Synthesis) {
+	best := -1
+	for i, f := range fonts {
+		if best < 0 {
+			best = i
+			continue
+		}
+		b := fonts[best]
+		if f.Italic == want.Italic && b.Italic != want.Italic ||
+			f.Italic == b.Italic && weightRank(f.Weight, want.Weight) < weightRank(b.Weight, want.Weight) {
+			best = i
+		}
+	}
+
+	f := fonts[best]
+	var synthesis Synthesis
+	if want.Weight >= 600 && f.Weight < 600 {
+		synthesis |= SynthBold
+	}
+	if want.Italic && !f.Italic {
+		synthesis |= SynthOblique
+	}
+	return f.id, synthesis
+}
+
+// weightRank orders the weights of a family for a wanted weight, the lowest
+// first. Below 400 lighter weights are tried first, above 500 heavier ones,
+// and in between the weights up to 500 before the lighter ones.
+func weightRank(weight, want uint16) int {
+	w, t := int(weight), int(want)
+	switch {
+	case t >= 400 && t <= 500:
+		if w >= t && w <= 500 {
+			return w - t
+		}
+		if w < t {
+			return 1000 + t - w
+		}
+		return 2000 + w - t
+	case t < 400:
+		if w <= t {
+			return t - w
+		}
+		return 1000 + w - t
+	default:
+		if w >= t {
+			return w - t
+		}
+		return 1000 + t - w
+	}
+}
+
+// fontOf returns the loaded font of an ID
+func fontOf(id FontID) *opentype.Font {
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.fonts[id]
+}
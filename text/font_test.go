@@ -0,0 +1,106 @@
+package text
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/gofont/gobold"
+
+	"github.com/myuon/penny/css"
+)
+
+func TestResolve(t *testing.T) {
+	regular := Variant{Weight: 400}
+	id, err := Register("Test Bold", regular, gobold.TTF)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if _, err := Register("Broken", regular, []byte("not a font")); err == nil {
+		t.Error("expected an error for data that isn't a font")
+	}
+
+	tests := []struct {
+		list string
+		want FontID
+	}{
+		{"serif", SansSerif},
+		{"monospace", Monospace},
+		{`"Missing", monospace`, Monospace},
+		{`"test bold", monospace`, id},
+		{`"Missing", fantasy, "Test Bold"`, id},
+		{`"monospace"`, SansSerif}, // a quoted name isn't the generic family
+		{`"Missing"`, SansSerif},
+	}
+	for _, tt := range tests {
+		if got, _ := Resolve(tt.list, regular); got != tt.want {
+			t.Errorf("%s: expected font %d, got %d", tt.list, tt.want, got)
+		}
+	}
+
+	// Text is measured in the resolved font
+	style := css.DefaultStyle()
+	style.FontFamily = "monospace"
+	mono := FontOf(&style)
+	if Width("iiii", mono) != Width("mmmm", mono) {
+		t.Error("expected monospace text to have even advances")
+	}
+	style.FontFamily = `"Test Bold"`
+	if bold := FontOf(&style); Width("mmmm", bold) == Width("mmmm", Font{Size: 16}) {
+		t.Error("expected the registered font to measure differently")
+	}
+}
+
+func TestResolveVariant(t *testing.T) {
+	if _, err := Register("Test Upright", Variant{Weight: 400}, gobold.TTF); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	light, _ := Register("Test Weights", Variant{Weight: 300}, gobold.TTF)
+	semi, _ := Register("Test Weights", Variant{Weight: 600}, gobold.TTF)
+	heavy, _ := Register("Test Weights", Variant{Weight: 900}, gobold.TTF)
+
+	regular, _ := Resolve("sans-serif", Variant{Weight: 400})
+	tests := []struct {
+		list      string
+		want      Variant
+		different bool // from the regular sans-serif font
+		synthesis Synthesis
+	}{
+		{"sans-serif", Variant{Weight: 700}, true, 0},
+		{"sans-serif", Variant{Weight: 400, Italic: true}, true, 0},
+		{"sans-serif", Variant{Weight: 900, Italic: true}, true, 0},
+		{`"Test Upright"`, Variant{Weight: 700}, true, SynthBold},
+		{`"Test Upright"`, Variant{Weight: 400, Italic: true}, true, SynthOblique},
+		{`"Test Upright"`, Variant{Weight: 800, Italic: true}, true, SynthBold | SynthOblique},
+	}
+	for _, tt := range tests {
+		id, synthesis := Resolve(tt.list, tt.want)
+		if (id != regular) != tt.different || synthesis != tt.synthesis {
+			t.Errorf("%s %+v: expected synthesis %b, got font %d with %b", tt.list, tt.want, tt.synthesis, id, synthesis)
+		}
+	}
+
+	// Weights are matched as CSS does: heavier first above 500, lighter
+	// first below 400
+	for _, tt := range []struct {
+		weight uint16
+		want   FontID
+	}{{100, light}, {400, light}, {500, light}, {550, semi}, {700, heavy}, {1000, heavy}} {
+		if id, _ := Resolve(`"Test Weights"`, Variant{Weight: tt.weight}); id != tt.want {
+			t.Errorf("weight %d: expected font %d, got %d", tt.weight, tt.want, id)
+		}
+	}
+}
+
+func TestSynthesizedMask(t *testing.T) {
+	plain := Mask("l", Font{Size: 32})
+	bold := Mask("l", Font{Size: 32, Synthesis: SynthBold})
+	if bold.Bounds().Dx() <= plain.Bounds().Dx() {
+		t.Errorf("expected a synthesized bold to be wider, got %v and %v", bold.Bounds(), plain.Bounds())
+	}
+	oblique := Mask("l", Font{Size: 32, Synthesis: SynthOblique})
+	if oblique.Bounds().Max.X <= plain.Bounds().Max.X || oblique.Bounds().Dy() != plain.Bounds().Dy() {
+		t.Errorf("expected an oblique to lean right, got %v and %v", oblique.Bounds(), plain.Bounds())
+	}
+	if Width("l", Font{Size: 32, Synthesis: SynthBold | SynthOblique}) != Width("l", Font{Size: 32}) {
+		t.Error("expected synthesis to leave the advance alone")
+	}
+}
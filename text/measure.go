@@ -0,0 +1,274 @@
+// Package text measures and rasterizes text in the face penny draws it with,
+// so that the boxes layout gives text and the glyphs paint draws in them
+// agree at every font size
+package text
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/math/fixed"
+)
+
+// maxFaces bounds the number of faces kept, one per font and size. When it
+// is reached the cache is emptied; pages rarely use more.
+const maxFaces = 64
+
+type faceKey struct {
+	id   FontID
+	size float32
+}
+
+// Faces are not safe for concurrent use, so every use of one holds mu
+var (
+	mu    sync.Mutex
+	faces = map[faceKey]font.Face{}
+)
+
+// face returns the face of a font. Synthesis is left to Mask. mu must be
+// held.
+func face(f Font) font.Face {
+	key := faceKey{f.ID, f.Size}
+	if face, ok := faces[key]; ok {
+		return face
+	}
+	face, err := opentype.NewFace(fontOf(f.ID), &opentype.FaceOptions{
+		Size:    float64(f.Size),
+		DPI:     72, // so that a point is a pixel
+		Hinting: font.HintingNone,
+	})
+	if err != nil {
+		panic("text: creating a face: " + err.Error())
+	}
+	if len(faces) >= maxFaces {
+		faces = map[faceKey]font.Face{}
+	}
+	faces[key] = face
+	return face
+}
+
+// Metrics are the vertical metrics of a font, in pixels
+type Metrics struct {
+	Ascent  float32 // from the baseline up to the top of the tallest glyphs
+	Descent float32 // from the baseline down
+	// LineHeight is the normal height of a line: the ascent and descent
+	// with the font's line gap
+	LineHeight float32
+}
+
+// Baseline returns how far below the top of a line of LineHeight its
+// baseline is, with the line gap split evenly above and below the glyphs
+func (m Metrics) Baseline() float32 {
+	return (m.LineHeight-m.Ascent-m.Descent)/2 + m.Ascent
+}
+
+// MetricsOf returns the metrics of a font
+func MetricsOf(f Font) Metrics {
+	if f.Size <= 0 {
+		return Metrics{}
+	}
+	f, k := atFaceSize(f)
+	mu.Lock()
+	m := face(f).Metrics()
+	mu.Unlock()
+	return Metrics{
+		Ascent:     fromFixed(m.Ascent) * k,
+		Descent:    fromFixed(m.Descent) * k,
+		LineHeight: fromFixed(m.Height) * k,
+	}
+}
+
+// Width returns the advance of a run of text in a font. Synthesized bold
+// doesn't widen it.
+func Width(s string, f Font) float32 {
+	if f.Size <= 0 || s == "" {
+		return 0
+	}
+	f, k := atFaceSize(f)
+	mu.Lock()
+	w := font.MeasureString(face(f), s)
+	mu.Unlock()
+	return fromFixed(w) * k
+}
+
+// maxFaceSize is the largest size of the faces text is measured and
+// rasterized in
+const maxFaceSize = 512
+
+// atFaceSize returns f at a size no larger than maxFaceSize, and how much
+// what is measured in it is to be scaled by for the size of f. Faces
+// overflow their fixed point arithmetic at a few ten thousand pixels, and a
+// glyph costs memory by the square of its size while showing little more
+// detail, so a larger font is measured and rasterized at maxFaceSize and
+// scaled up.
+func atFaceSize(f Font) (Font, float32) {
+	if f.Size <= maxFaceSize {
+		return f, 1
+	}
+	k := f.Size / maxFaceSize
+	f.Size = maxFaceSize
+	return f, k
+}
+
+// Mask rasterizes a run of text in a font. The mask holds the glyph
+// coverage with its bounds relative to the dot the run starts at, so it can
+// be composited in any color at any position.
+func Mask(s string, f Font) *image.Alpha {
+	return MaskIn(s, f, MaskBounds(s, f))
+}
+
+// MaskIn is Mask for only the part of the run within clip, relative to its
+// dot. It costs memory for that part alone, so a run in a huge font can be
+// drawn where little of it shows.
+func MaskIn(s string, f Font, clip image.Rectangle) *image.Alpha {
+	if f.Size <= 0 || s == "" {
+		return image.NewAlpha(image.Rectangle{})
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	area := maskBounds(s, f).Intersect(clip)
+	if area.Empty() {
+		return image.NewAlpha(image.Rectangle{})
+	}
+	small, k := atFaceSize(f)
+	if k == 1 {
+		return rasterize(s, f, area)
+	}
+
+	// The part at the face's size that covers area once scaled up, with a
+	// pixel more around it to blend with
+	src := rasterize(s, small, scaleRect(area, 1/k).Inset(-1).Intersect(maskBounds(s, small)))
+	mask := image.NewAlpha(area)
+	draw.BiLinear.Transform(mask, f64.Aff3{float64(k), 0, 0, 0, float64(k), 0}, src, src.Bounds(), draw.Src, nil)
+	return mask
+}
+
+// MaskBounds returns the bounds of the mask of a run of text in a font,
+// relative to its dot, without rasterizing it
+func MaskBounds(s string, f Font) image.Rectangle {
+	if f.Size <= 0 || s == "" {
+		return image.Rectangle{}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return maskBounds(s, f)
+}
+
+// maskBounds is MaskBounds with mu held
+func maskBounds(s string, f Font) image.Rectangle {
+	if small, k := atFaceSize(f); k != 1 {
+		return scaleRect(maskBounds(s, small), k)
+	}
+	b, _ := font.BoundString(face(f), s)
+	r := image.Rect(b.Min.X.Floor(), b.Min.Y.Floor(), b.Max.X.Ceil(), b.Max.Y.Ceil())
+	if f.Synthesis&SynthBold != 0 {
+		r.Max.X += boldSmear(f)
+	}
+	if f.Synthesis&SynthOblique != 0 {
+		r.Min.X += slantShift(r.Max.Y - 1)
+		r.Max.X += slantShift(r.Min.Y)
+	}
+	return r
+}
+
+// scaleRect returns the pixels r covers scaled by k
+func scaleRect(r image.Rectangle, k float32) image.Rectangle {
+	scale := func(v int, round func(float64) float64) int {
+		return int(round(float64(v) * float64(k)))
+	}
+	return image.Rect(scale(r.Min.X, math.Floor), scale(r.Min.Y, math.Floor), scale(r.Max.X, math.Ceil), scale(r.Max.Y, math.Ceil))
+}
+
+// rasterize draws the part of a run within area, which is within its
+// bounds, with any synthesis. f is no larger than maxFaceSize. mu must be
+// held.
+func rasterize(s string, f Font, area image.Rectangle) *image.Alpha {
+	// Synthesis moves pixels right and sideways, so the glyphs are drawn
+	// over what it can move into area
+	src := area
+	if f.Synthesis&SynthBold != 0 {
+		src.Min.X -= boldSmear(f)
+	}
+	if f.Synthesis&SynthOblique != 0 {
+		m := max(abs(slantShift(area.Min.Y)), abs(slantShift(area.Max.Y-1)))
+		src.Min.X -= m
+		src.Max.X += m
+	}
+	mask := image.NewAlpha(src)
+	drawer := &font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: face(f),
+		Dot:  fixed.Point26_6{},
+	}
+	drawer.DrawString(s)
+
+	if f.Synthesis&SynthBold != 0 {
+		mask = embolden(mask, boldSmear(f))
+	}
+	if f.Synthesis&SynthOblique != 0 {
+		mask = slant(mask)
+	}
+	if mask.Bounds() == area {
+		return mask
+	}
+	return mask.SubImage(area).(*image.Alpha)
+}
+
+// boldSmear returns how far synthesized bold smears glyphs right
+func boldSmear(f Font) int {
+	return max(1, int(f.Size/24+0.5))
+}
+
+// embolden thickens glyphs by smearing them right by n pixels
+func embolden(mask *image.Alpha, n int) *image.Alpha {
+	b := mask.Bounds()
+	out := image.NewAlpha(image.Rect(b.Min.X, b.Min.Y, b.Max.X+n, b.Max.Y))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			a := mask.AlphaAt(x, y).A
+			for dx := 0; dx <= n; dx++ {
+				if out.AlphaAt(x+dx, y).A < a {
+					out.SetAlpha(x+dx, y, color.Alpha{A: a})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// obliqueSlant is how far an oblique shifts right per pixel above the
+// baseline: the tangent of the 14 degrees CSS slants by
+const obliqueSlant = 0.25
+
+// slant shears glyphs around the baseline, y=0, into an oblique
+func slant(mask *image.Alpha) *image.Alpha {
+	b := mask.Bounds()
+	out := image.NewAlpha(image.Rect(b.Min.X+slantShift(b.Max.Y-1), b.Min.Y, b.Max.X+slantShift(b.Min.Y), b.Max.Y))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		dx := slantShift(y)
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.SetAlpha(x+dx, y, mask.AlphaAt(x, y))
+		}
+	}
+	return out
+}
+
+// slantShift returns how far an oblique shifts the pixels of row y right
+func slantShift(y int) int {
+	return int(math.Round(float64(-y) * obliqueSlant))
+}
+
+func abs(v int) int {
+	return max(v, -v)
+}
+
+func fromFixed(v fixed.Int26_6) float32 {
+	return float32(v) / 64
+}
@@ -0,0 +1,80 @@
+package text
+
+import (
+	"image"
+	"testing"
+)
+
+func TestWidthScalesWithSize(t *testing.T) {
+	small, large := Width("penny", Font{Size: 16}), Width("penny", Font{Size: 32})
+	// Advances are rounded to 1/64px per glyph
+	if small <= 0 || large < 2*small-1 || large > 2*small+1 {
+		t.Errorf("expected the width to double with the size, got %v and %v", small, large)
+	}
+	// A font larger than any face is measured in one and scaled
+	if huge := Width("penny", Font{Size: 100000}); huge < 6000*small || huge > 6300*small {
+		t.Errorf("expected the width to scale to huge sizes, got %v", huge)
+	}
+	if Width("i", Font{Size: 16}) >= Width("m", Font{Size: 16}) {
+		t.Error("expected a proportional font")
+	}
+	if Width("penny", Font{Size: 0}) != 0 || Width("", Font{Size: 16}) != 0 {
+		t.Error("expected no width for an empty run or size")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := MetricsOf(Font{Size: 16})
+	if m.Ascent <= 0 || m.Descent <= 0 || m.LineHeight < m.Ascent+m.Descent {
+		t.Errorf("expected the line to hold the ascent and descent, got %+v", m)
+	}
+	if b := m.Baseline(); b < m.Ascent || b > m.LineHeight-m.Descent {
+		t.Errorf("expected the baseline between the ascent and descent, got %v in %+v", b, m)
+	}
+}
+
+func TestMaskMatchesWidth(t *testing.T) {
+	mask := Mask("penny", Font{Size: 16})
+	bounds := mask.Bounds()
+	if bounds.Dy() <= 0 || float32(bounds.Max.X) < Width("penny", Font{Size: 16})-1 {
+		t.Errorf("expected glyphs across the run's width, got %v", bounds)
+	}
+	// Glyphs sit on the baseline at y=0, so most of their coverage is above
+	if bounds.Min.Y >= 0 || bounds.Max.Y > int(MetricsOf(Font{Size: 16}).Descent)+1 {
+		t.Errorf("expected the mask around the baseline, got %v", bounds)
+	}
+}
+
+func TestMaskIn(t *testing.T) {
+	for _, f := range []Font{{Size: 32}, {Size: 32, Synthesis: SynthBold | SynthOblique}} {
+		full := Mask("penny", f)
+		if full.Bounds() != MaskBounds("penny", f) {
+			t.Errorf("%+v: expected the mask within %v, got %v", f, MaskBounds("penny", f), full.Bounds())
+		}
+		clip := image.Rect(10, -15, 40, 3)
+		part := MaskIn("penny", f, clip)
+		if part.Bounds() != clip {
+			t.Fatalf("%+v: expected the mask clipped to %v, got %v", f, clip, part.Bounds())
+		}
+		for y := clip.Min.Y; y < clip.Max.Y; y++ {
+			for x := clip.Min.X; x < clip.Max.X; x++ {
+				if part.AlphaAt(x, y) != full.AlphaAt(x, y) {
+					t.Fatalf("%+v: expected the clipped mask to match the whole one at %d,%d", f, x, y)
+				}
+			}
+		}
+	}
+
+	// A huge font is rasterized at a smaller size and scaled up, only
+	// where it is wanted
+	f := Font{Size: 100000}
+	b := MaskBounds("H", f)
+	if b.Dy() < 60000 || b.Min.Y >= 0 || b.Max.Y > 0 {
+		t.Fatalf("expected the bounds of a huge glyph, got %v", b)
+	}
+	clip := image.Rect(b.Min.X+1000, b.Min.Y+b.Dy()/2, b.Min.X+1100, b.Min.Y+b.Dy()/2+100)
+	part := MaskIn("H", f, clip)
+	if part.Bounds() != clip || part.AlphaAt(clip.Min.X+50, clip.Min.Y+50).A != 255 {
+		t.Errorf("expected the clipped stem of the H, got %v with alpha %d", part.Bounds(), part.AlphaAt(clip.Min.X+50, clip.Min.Y+50).A)
+	}
+}
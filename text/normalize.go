@@ -0,0 +1,74 @@
+package text
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize prepares the text of a page for measuring and drawing. Invalid
+// UTF-8, which includes encoded surrogates, and control characters other
+// than tabs and newlines become U+FFFD; a carriage return, alone or before
+// a newline, becomes a newline, and a form feed, which HTML counts as white
+// space, a space. Invisible formatting characters, such as
+// the joiners and variation selectors of emoji sequences, are dropped, as
+// the fonts have no glyphs for them and would draw a box for each. The
+// result is in NFC, so that a letter and its combining accent are drawn as
+// the one precomposed glyph.
+func Normalize(s string) string {
+	if isPlain(s) {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == '\r':
+			sb.WriteByte('\n')
+			if i < len(s) && s[i] == '\n' {
+				i++
+			}
+		case r == '\f':
+			sb.WriteByte(' ')
+		case r == '\t' || r == '\n':
+			sb.WriteRune(r)
+		case r == utf8.RuneError || unicode.IsControl(r):
+			sb.WriteRune(utf8.RuneError)
+		case isIgnorable(r):
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return norm.NFC.String(sb.String())
+}
+
+// isPlain reports whether s is printable ASCII, tabs and newlines, which
+// Normalize leaves as they are
+func isPlain(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= utf8.RuneSelf || c < ' ' && c != '\t' && c != '\n' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// isIgnorable reports whether r is a format character that is drawn as
+// nothing
+func isIgnorable(r rune) bool {
+	switch {
+	case r == '\u00ad', // soft hyphen
+		r >= '\u200b' && r <= '\u200f', // zero width space, joiners and marks
+		r >= '\u2060' && r <= '\u2064', // word joiner and invisible operators
+		r == '\ufeff',                  // byte order mark
+		r >= '\ufe00' && r <= '\ufe0f', // variation selectors
+		r >= 0xe0000 && r <= 0xe007f,   // tags, as in subdivision flags
+		r >= 0xe0100 && r <= 0xe01ef:   // supplementary variation selectors
+		return true
+	}
+	return false
+}
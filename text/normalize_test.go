@@ -0,0 +1,35 @@
+package text
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{"plain", "penny\tprints\n", "penny\tprints\n"},
+		{"nfc", "cafe\u0301", "caf\u00e9"},
+		{"invalid utf-8", "a\xffb", "a\ufffdb"},
+		{"surrogate", "a\xed\xa0\x80b", "a\ufffd\ufffd\ufffdb"},
+		{"controls", "a\x00b\x1bc\u0085d\x7f", "a\ufffdb\ufffdc\ufffdd\ufffd"},
+		{"carriage returns", "a\r\nb\rc", "a\nb\nc"},
+		{"form feed", "a\fb", "a b"},
+		{"emoji sequence", "\U0001f469\u200d\U0001f4bb \u2764\ufe0f", "\U0001f469\U0001f4bb \u2764"},
+		{"soft hyphen", "pen\u00adny", "penny"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.input); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestNormalizedTextMeasures(t *testing.T) {
+	f := Font{Size: 16}
+	// Dropped characters take no space, and broken bytes don't panic
+	if w := Width(Normalize("pen\u200bny"), f); w != Width("penny", f) {
+		t.Errorf("expected the zero width space to be dropped, got %v", w)
+	}
+	if Mask(Normalize("\xc3\x28\x00"), f) == nil {
+		t.Error("expected a mask")
+	}
+}
@@ -0,0 +1,41 @@
+package text
+
+import (
+	"math"
+	"strings"
+
+	"github.com/myuon/penny/css"
+)
+
+// ExpandTabs replaces each tab in a line with spaces up to the next tab
+// stop. Stops are a multiple of the tab size apart in pixels, and the font
+// is proportional, so the spaces are as many as come closest to the stop.
+// The column is counted as the line is written out, measuring each run
+// between tabs once, so a line of many tabs takes linear time.
+func ExpandTabs(line string, tabSize css.TabSize, f Font) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	space := Width(" ", f)
+	stop := tabSize.Stop(space)
+
+	var sb strings.Builder
+	var x float32
+	for {
+		i := strings.IndexByte(line, '\t')
+		if i < 0 {
+			sb.WriteString(line)
+			return sb.String()
+		}
+		sb.WriteString(line[:i])
+		x += Width(line[:i], f)
+		line = line[i+1:]
+		if stop <= 0 || space <= 0 {
+			continue
+		}
+		next := (float32(math.Floor(float64(x/stop))) + 1) * stop
+		spaces := max(int(math.Round(float64((next-x)/space))), 1)
+		sb.WriteString(strings.Repeat(" ", spaces))
+		x += float32(spaces) * space
+	}
+}
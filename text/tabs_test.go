@@ -0,0 +1,37 @@
+package text
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/myuon/penny/css"
+)
+
+func TestExpandTabs(t *testing.T) {
+	tests := []struct {
+		line string
+		size css.TabSize
+		want string
+	}{
+		// The stops are in pixels, and "ab" is four spaces wide
+		{"ab\tc", css.TabSize{Spaces: 8}, "ab    c"},
+		{"\t\tx", css.TabSize{Spaces: 2}, "    x"},
+		{"a\tb", css.TabSize{}, "ab"},
+		{"a\tb", css.TabSize{Width: 3 * Width(" ", Font{Size: 16})}, "a b"},
+		{"abc", css.TabSize{Spaces: 8}, "abc"},
+	}
+	for _, tt := range tests {
+		if got := ExpandTabs(tt.line, tt.size, Font{Size: 16}); got != tt.want {
+			t.Errorf("%q with %v: expected %q, got %q", tt.line, tt.size, tt.want, got)
+		}
+	}
+}
+
+func TestExpandManyTabs(t *testing.T) {
+	// Measuring the whole line so far at each tab would take minutes
+	line := strings.Repeat("ab\t", 50000)
+	got := ExpandTabs(line, css.TabSize{Spaces: 4}, Font{Size: 16})
+	if strings.Contains(got, "\t") || len(got) <= len(line) {
+		t.Errorf("expected every tab expanded")
+	}
+}
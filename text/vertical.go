@@ -0,0 +1,64 @@
+package text
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// VerticalRun is a run of a line of vertical text set one way: upright,
+// with each glyph in a square as high as the font size, or turned sideways
+// a quarter turn clockwise, as wide text is in a vertical line
+type VerticalRun struct {
+	Text    string
+	Upright bool
+}
+
+// VerticalRuns splits a line of vertical text into its runs. Wide
+// characters, such as those of Chinese and Japanese, are set upright and
+// the rest sideways, as text-orientation: mixed sets them.
+func VerticalRuns(s string) []VerticalRun {
+	var runs []VerticalRun
+	start := 0
+	for i, r := range s {
+		upright := isUpright(r)
+		if len(runs) > 0 && upright == runs[len(runs)-1].Upright {
+			continue
+		}
+		if len(runs) > 0 {
+			runs[len(runs)-1].Text = s[start:i]
+		}
+		runs = append(runs, VerticalRun{Upright: upright})
+		start = i
+	}
+	if len(runs) > 0 {
+		runs[len(runs)-1].Text = s[start:]
+	}
+	return runs
+}
+
+// Advance returns how far down the line a run reaches in a font
+func (r VerticalRun) Advance(f Font) float32 {
+	if r.Upright {
+		return float32(utf8.RuneCountInString(r.Text)) * f.Size
+	}
+	return Width(r.Text, f)
+}
+
+// VerticalAdvance returns how far down the line a line of vertical text
+// reaches in a font
+func VerticalAdvance(s string, f Font) float32 {
+	var advance float32
+	for _, run := range VerticalRuns(s) {
+		advance += run.Advance(f)
+	}
+	return advance
+}
+
+func isUpright(r rune) bool {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return true
+	}
+	return false
+}
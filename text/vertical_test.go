@@ -0,0 +1,24 @@
+package text
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVerticalRuns(t *testing.T) {
+	got := VerticalRuns("縦書きはCSS3で")
+	want := []VerticalRun{{"縦書きは", true}, {"CSS3", false}, {"で", true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if VerticalRuns("") != nil {
+		t.Error("expected no runs for an empty line")
+	}
+}
+
+func TestVerticalAdvance(t *testing.T) {
+	f := Font{Size: 16}
+	if got, want := VerticalAdvance("縦書きCSS", f), 3*16+Width("CSS", f); got != want {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}